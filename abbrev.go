@@ -0,0 +1,113 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// DefaultAbbrev is the fewest hexadecimal characters to which object
+	// IDs are abbreviated by AbbrevLength, when the database is small
+	// enough that fewer would suffice. It matches the default used by Git.
+	DefaultAbbrev = 7
+)
+
+// Abbrev returns the first "n" hexadecimal characters of the object ID, or all
+// of them if it is no longer than that.
+func (oid Oid) Abbrev(n int) string {
+	s := oid.String()
+	if n >= 0 && n < len(s) {
+		return s[:n]
+	}
+	return s
+}
+
+// AbbrevLength returns the number of hexadecimal characters to which each of
+// the given object IDs may be abbreviated (see: Oid.Abbrev), such that no
+// abbreviation is ambiguous with any other object in the database, or with any
+// other of the given object IDs.
+//
+// The length returned is never shorter than the minimum set by the Abbrev
+// Option, or, if none was set, than that chosen by Git for a database of the
+// same size when `core.abbrev` is "auto": enough characters that collisions
+// are unlikely between any two of its objects, and at least DefaultAbbrev.
+// Staged objects which have not yet been flushed are not considered.
+func (o *ObjectDatabase) AbbrevLength(oids ...[]byte) (int, error) {
+	var names [][]byte
+	err := o.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		names = append(names, []byte(hex.EncodeToString(oid)))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n := o.abbrev
+	if n <= 0 {
+		n = autoAbbrevLength(len(names))
+	}
+
+	wanted := make([][]byte, 0, len(oids))
+	for _, oid := range oids {
+		wanted = append(wanted, []byte(hex.EncodeToString(oid)))
+	}
+	names = append(names, wanted...)
+
+	sort.Slice(names, func(i, j int) bool {
+		return bytes.Compare(names[i], names[j]) < 0
+	})
+
+	for _, name := range wanted {
+		// Only the object IDs on either side of "name" in sorted order
+		// can share a longer prefix with it than any other.
+		i := sort.Search(len(names), func(i int) bool {
+			return bytes.Compare(names[i], name) >= 0
+		})
+		for j := i - 1; j >= 0 && bytes.Equal(names[j], name); j-- {
+			i = j
+		}
+
+		if i > 0 {
+			n = maxAbbrevLength(n, name, names[i-1])
+		}
+		for j := i; j < len(names); j++ {
+			if !bytes.Equal(names[j], name) {
+				n = maxAbbrevLength(n, name, names[j])
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// maxAbbrevLength returns the larger of "n" and the number of hexadecimal
+// characters to which "name" must be abbreviated to distinguish it from
+// "other", but no more than the length of "name".
+func maxAbbrevLength(n int, name, other []byte) int {
+	common := 0
+	for common < len(name) && common < len(other) && name[common] == other[common] {
+		common++
+	}
+
+	if common < len(name) {
+		common++
+	}
+	if common > n {
+		return common
+	}
+	return n
+}
+
+// autoAbbrevLength returns the number of hexadecimal characters to which object
+// IDs are abbreviated in a database holding "count" objects, as when
+// `core.abbrev` is "auto": half the number of bits needed to count them,
+// rounded up, or DefaultAbbrev, if that is longer.
+func autoAbbrevLength(count int) int {
+	n := (bits.Len(uint(count)) + 1) / 2
+	if n < DefaultAbbrev {
+		return DefaultAbbrev
+	}
+	return n
+}
@@ -0,0 +1,70 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOidAbbrev(t *testing.T) {
+	sha, _ := hex.DecodeString("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+	oid := Oid(sha)
+
+	assert.Equal(t, "e69de29", oid.Abbrev(7))
+	assert.Equal(t, "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", oid.Abbrev(40))
+	assert.Equal(t, "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", oid.Abbrev(64))
+}
+
+func TestAbbrevLengthDefaultsForSmallDatabases(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	n, err := db.AbbrevLength(blob)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAbbrev, n)
+}
+
+func TestAbbrevLengthDisambiguatesObjectIDs(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	// Share the first 9 hexadecimal characters of "blob".
+	other := append([]byte(nil), blob...)
+	other[4] ^= 0x01
+
+	n, err := db.AbbrevLength(other)
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	n, err = db.AbbrevLength(blob, other)
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+}
+
+func TestAbbrevLengthWithAbbrevOption(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b, Abbrev(12))
+	require.NoError(t, err)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	n, err := db.View().AbbrevLength(blob)
+	require.NoError(t, err)
+	assert.Equal(t, 12, n)
+}
+
+func TestAutoAbbrevLength(t *testing.T) {
+	assert.Equal(t, DefaultAbbrev, autoAbbrevLength(0))
+	assert.Equal(t, DefaultAbbrev, autoAbbrevLength(1<<12))
+	assert.Equal(t, 8, autoAbbrevLength(1<<15))
+	assert.Equal(t, 12, autoAbbrevLength(8*1024*1024))
+}
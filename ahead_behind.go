@@ -0,0 +1,135 @@
+package gitobj
+
+import (
+	"container/heap"
+)
+
+const (
+	// reachableFromA is set on commits reachable from the first argument
+	// given to AheadBehind.
+	reachableFromA uint8 = 1 << iota
+	// reachableFromB is set on commits reachable from the second argument
+	// given to AheadBehind.
+	reachableFromB
+
+	reachableFromBoth = reachableFromA | reachableFromB
+)
+
+// AheadBehind returns the number of commits reachable from the commit named
+// by "a" but not "b" (ahead), and the number reachable from "b" but not "a"
+// (behind), equivalent to:
+//
+//	git rev-list --left-right --count a...b
+//
+// History is walked in descending order of committer date from both commits
+// at once, and the walk ends as soon as all remaining history is known to be
+// reachable from both. The parents and dates of commits are read as by
+// CommitInfo, and so from a commit-graph where there is one.
+func (o *ObjectDatabase) AheadBehind(a, b []byte) (ahead, behind int, err error) {
+	r := &reachWalker{
+		db:      o,
+		flags:   make(map[string]uint8),
+		queued:  make(map[string]bool),
+		commits: make(map[string]*CommitInfo),
+	}
+
+	if err := r.mark(a, reachableFromA); err != nil {
+		return 0, 0, err
+	}
+	if err := r.mark(b, reachableFromB); err != nil {
+		return 0, 0, err
+	}
+
+	for r.pending > 0 {
+		item := heap.Pop(&r.queue).(*commitQueueItem)
+		key := string(item.sha)
+
+		delete(r.queued, key)
+		flags := r.flags[key]
+		if flags != reachableFromBoth {
+			r.pending--
+		}
+
+		for _, parent := range item.parentIDs {
+			if err := r.mark(parent, flags); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	for _, flags := range r.flags {
+		switch flags {
+		case reachableFromA:
+			ahead++
+		case reachableFromB:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// reachWalker holds the state of a single walk which determines which of a
+// set of commits each commit in their history is reachable from.
+type reachWalker struct {
+	db *ObjectDatabase
+
+	// queue holds the commits whose flags must be propagated to their
+	// parents. Each commit is queued at most once at a time, and its flags
+	// are propagated as they are when it is popped.
+	queue commitQueue
+	// queued holds each commit which is in the queue.
+	queued map[string]bool
+	// pending is the number of queued commits which are not yet known to
+	// be reachable from both sides of the walk. The walk ends once it
+	// reaches zero.
+	pending int
+	// flags holds the set of commits from which each commit seen so far
+	// is known to be reachable.
+	flags map[string]uint8
+	// commits holds the metadata of each commit seen so far, so that each
+	// commit is read from the database (or commit-graph) at most once.
+	commits map[string]*CommitInfo
+	// seq is the number of commits which have been queued.
+	seq int
+}
+
+// mark records that the commit named by "sha" is reachable from the given set
+// of commits, queueing it if that adds to what was previously known, and it is
+// not already queued.
+func (r *reachWalker) mark(sha []byte, flags uint8) error {
+	key := string(sha)
+	if r.flags[key]|flags == r.flags[key] {
+		return nil
+	}
+	r.flags[key] |= flags
+
+	if r.queued[key] {
+		if r.flags[key] == reachableFromBoth {
+			r.pending--
+		}
+		return nil
+	}
+
+	info, ok := r.commits[key]
+	if !ok {
+		var err error
+		if info, err = r.db.CommitInfo(sha); err != nil {
+			return err
+		}
+		r.commits[key] = info
+	}
+
+	heap.Push(&r.queue, &commitQueueItem{
+		sha:       sha,
+		parentIDs: info.ParentIDs,
+		when:      info.CommitTime.Unix(),
+		seq:       r.seq,
+	})
+	r.queued[key] = true
+	if r.flags[key] != reachableFromBoth {
+		r.pending++
+	}
+	r.seq++
+
+	return nil
+}
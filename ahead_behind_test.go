@@ -0,0 +1,97 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAheadBehind(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	a1 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, base)
+	a2 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, a1)
+	b1 := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 250, base)
+
+	ahead, behind, err := db.AheadBehind(a2, b1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ahead)
+	assert.Equal(t, 1, behind)
+
+	ahead, behind, err = db.AheadBehind(b1, a2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ahead)
+	assert.Equal(t, 2, behind)
+}
+
+func TestAheadBehindWithAncestor(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+	c3 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, c2)
+
+	ahead, behind, err := db.AheadBehind(c3, c1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ahead)
+	assert.Equal(t, 0, behind)
+
+	ahead, behind, err = db.AheadBehind(c3, c3)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ahead)
+	assert.Equal(t, 0, behind)
+}
+
+func TestAheadBehindAcrossMerges(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	side := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, base)
+	main := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, base)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 400, main, side)
+
+	ahead, behind, err := db.AheadBehind(merge, side)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ahead)
+	assert.Equal(t, 0, behind)
+}
+
+func TestAheadBehindReadsFromCommitGraph(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-commit-graph")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	a1 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, base)
+	a2 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, a1)
+	b1 := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 250, base)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "info"), 0755))
+	writeTestCommitGraph(t, filepath.Join(root, "info", "commit-graph"), db,
+		nil, base, a1, a2, b1)
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+
+	// The commits themselves are removed, so that they can only be read
+	// from the commit-graph.
+	for _, sha := range [][]byte{base, a1, a2, b1} {
+		encoded := hex.EncodeToString(sha)
+		require.NoError(t, os.Remove(filepath.Join(root, encoded[:2], encoded[2:])))
+	}
+
+	ahead, behind, err := db.AheadBehind(a2, b1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ahead)
+	assert.Equal(t, 1, behind)
+}
@@ -0,0 +1,99 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// AlternateOutcome is the outcome of considering an alternate object database
+// while opening a repository (see: AlternateDecision).
+type AlternateOutcome int
+
+const (
+	// AlternateUsed indicates that the alternate's objects are searched.
+	AlternateUsed AlternateOutcome = iota
+	// AlternateMissing indicates that the alternate was skipped because
+	// its objects directory does not exist.
+	AlternateMissing
+	// AlternateCyclic indicates that the alternate was skipped because its
+	// objects directory had already been visited, as in a circular chain
+	// of alternates, or one listed twice.
+	AlternateCyclic
+	// AlternateTooDeep indicates that the alternate was skipped because it
+	// is nested more deeply than is allowed (see: MaxAlternatesDepth).
+	AlternateTooDeep
+)
+
+// String implements fmt.Stringer.
+func (o AlternateOutcome) String() string {
+	switch o {
+	case AlternateUsed:
+		return "used"
+	case AlternateMissing:
+		return "missing"
+	case AlternateCyclic:
+		return "cyclic"
+	case AlternateTooDeep:
+		return "too-deep"
+	}
+	return fmt.Sprintf("<unknown outcome %d>", int(o))
+}
+
+// AlternateDecision records whether an alternate object database was used while
+// opening a repository, and if not, why not (see: AlternatesLog).
+type AlternateDecision struct {
+	// Dir is the objects directory of the alternate, with any relative
+	// path resolved against the directory which listed it.
+	Dir string
+	// Depth is the depth at which the alternate was found: zero for those
+	// listed by the repository itself, one for their own alternates, and
+	// so on.
+	Depth int
+	// Outcome is the outcome of considering the alternate.
+	Outcome AlternateOutcome
+}
+
+// String returns a description of the decision, as it is written to the log
+// file (see: AlternatesLogFile).
+func (d *AlternateDecision) String() string {
+	return fmt.Sprintf("%s %d %s", d.Outcome, d.Depth, d.Dir)
+}
+
+// alternatesLogFile returns a function appending each decision it is given to
+// a buffer, and a function writing that buffer (headed by the time given by
+// "clock") to the file "info/gitobj-alternates-log" of the objects directory
+// "root", replacing the log written when the database was last opened.
+func alternatesLogFile(root string, clock func() time.Time) (func(*AlternateDecision), func() error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n", clock().UTC().Format(time.RFC3339))
+
+	record := func(d *AlternateDecision) {
+		fmt.Fprintln(&buf, d)
+	}
+	save := func() error {
+		path := filepath.Join(root, "info", "gitobj-alternates-log")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp_alternates_log_")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+
+		if _, err := tmp.Write(buf.Bytes()); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), path)
+	}
+	return record, save
+}
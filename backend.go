@@ -2,10 +2,11 @@ package gitobj
 
 import (
 	"bufio"
+	"fmt"
 	"hash"
 	"io"
 	"os"
-	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -20,80 +21,266 @@ import (
 // GIT_ALTERNATE_OBJECT_DIRECTORIES.  The hash algorithm used is specified by
 // the algo parameter.
 func NewFilesystemBackend(root, tmp, alternates string, algo hash.Hash) (storage.Backend, error) {
+	args := newOptions(nil)
+	args.alternates = alternates
+
+	return newFilesystemBackend(root, tmp, algo, args)
+}
+
+const (
+	// DefaultMaxAlternatesDepth is the maximum depth to which the
+	// alternates of alternates are followed unless the MaxAlternatesDepth
+	// option is given. It matches the limit used by Git.
+	DefaultMaxAlternatesDepth = 5
+)
+
+// newFilesystemBackend initializes a new filesystem-based backend as above,
+// configured according to the given set of options.
+func newFilesystemBackend(root, tmp string, algo hash.Hash, args *options) (*filesystemBackend, error) {
 	fsobj := newFileStorer(root, tmp)
-	packs, err := pack.NewStorage(root, algo)
-	if err != nil {
-		return nil, err
+
+	var files *pack.FileCache
+	if args.maxOpenPackFiles > 0 || args.packFileHooks != nil {
+		files = pack.NewFileCacheWithHooks(args.maxOpenPackFiles, args.packFileHooks)
 	}
 
-	storage, err := findAllBackends(fsobj, packs, root, algo)
+	packs, err := pack.NewStorageWithFileCache(longPath(root), algo, files)
 	if err != nil {
 		return nil, err
 	}
 
-	storage, err = addAlternatesFromEnvironment(storage, alternates, algo)
-	if err != nil {
+	backends := []storage.Storage{fsobj, packs}
+	alternates := newAlternateSet(algo, args.maxAlternatesDepth, files)
+	alternates.visit(root)
+
+	alternates.log = args.alternatesLog
+	var saveLog func() error
+	if args.alternatesLogFile {
+		var record func(*AlternateDecision)
+		record, saveLog = alternatesLogFile(longPath(root), args.clock)
+		alternates.log = func(d *AlternateDecision) {
+			record(d)
+			if args.alternatesLog != nil {
+				args.alternatesLog(d)
+			}
+		}
+	}
+
+	// Every storage found so far, other than those of any alternates,
+	// belongs to the repository itself.
+	local := 2
+
+	if len(args.quarantine) > 0 {
+		// Write into the quarantine directory, and search it before
+		// any other.
+		fsobj = newFileStorer(args.quarantine, tmp)
+		if packs, err = pack.NewStorageWithFileCache(longPath(args.quarantine), algo, files); err != nil {
+			return nil, err
+		}
+		alternates.visit(args.quarantine)
+
+		backends = append([]storage.Storage{fsobj, packs}, backends...)
+		local += 2
+	}
+
+	fsobj.batched = args.batchedWrites
+	fsobj.synced = args.syncedWrites
+	fsobj.durable = args.fsyncObjectFiles
+	fsobj.shared = args.sharedPerm
+	if args.packedWrites {
+		fsobj.packs = packs
+		fsobj.hasher = func() hash.Hash {
+			return hasher(args.objectFormat)
+		}
+	}
+
+	if err := alternates.addFile(root, 0); err != nil {
 		return nil, err
 	}
+	if len(args.alternates) > 0 {
+		for _, dir := range splitAlternateString(args.alternates, alternatesSeparator) {
+			if err := alternates.add(dir, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if args.gitRepoLayout {
+		if err := alternates.addLinkTarget(root, args.diagnose); err != nil {
+			return nil, err
+		}
+	}
+	backends = append(backends, alternates.storages...)
+
+	if saveLog != nil {
+		// The log is only diagnostic, and so failing to write it does
+		// not prevent the database from being opened.
+		saveLog()
+	}
+
+	for _, s := range backends {
+		switch s := s.(type) {
+		case *fileStorer:
+			s.compressor = args.compressor
+		case *pack.Storage:
+			if args.unpooled {
+				s.DisablePooling()
+			}
+			if args.verifiedPacks {
+				s.EnableVerification()
+			}
+			if args.deltaBaseCacheLimit != pack.DefaultDeltaBaseCacheLimit {
+				s.SetDeltaBaseCacheLimit(args.deltaBaseCacheLimit)
+			}
+			if args.maxDeltaDepth > 0 || args.maxObjectSize > 0 {
+				s.SetLimits(pack.Limits{
+					MaxDeltaDepth: args.maxDeltaDepth,
+					MaxObjectSize: args.maxObjectSize,
+				})
+			}
+		}
+	}
 
 	return &filesystemBackend{
 		fs:       fsobj,
-		backends: storage,
+		backends: backends,
+		local:    local,
+		graph:    openCommitGraph(longPath(root), algo),
 	}, nil
 }
 
-func findAllBackends(mainLoose *fileStorer, mainPacked *pack.Storage, root string, algo hash.Hash) ([]storage.Storage, error) {
-	storage := make([]storage.Storage, 2)
-	storage[0] = mainLoose
-	storage[1] = mainPacked
-	f, err := os.Open(path.Join(root, "info", "alternates"))
+// alternateSet collects the storages of a repository's alternates, and those of
+// their alternates in turn, visiting each objects directory at most once.
+type alternateSet struct {
+	// algo is the hash algorithm used to read packfiles.
+	algo hash.Hash
+	// maxDepth is the depth beyond which alternates are ignored.
+	maxDepth int
+	// files is the cache through which packfiles are read, or nil if they
+	// are held open (see: MaxOpenPackFiles).
+	files *pack.FileCache
+
+	// seen holds the absolute path of each objects directory visited so
+	// far, including the repository's own.
+	seen map[string]struct{}
+	// storages holds the loose and packed storages of each alternate, in
+	// the order in which they were found.
+	storages []storage.Storage
+	// log, if it is not nil, is called with the decision made about each
+	// alternate found (see: AlternatesLog).
+	log func(*AlternateDecision)
+}
+
+func newAlternateSet(algo hash.Hash, maxDepth int, files *pack.FileCache) *alternateSet {
+	return &alternateSet{
+		algo:     algo,
+		maxDepth: maxDepth,
+		files:    files,
+		seen:     make(map[string]struct{}),
+	}
+}
+
+// visit marks the objects directory "dir" as visited, and returns whether it had
+// not been visited before.
+func (a *alternateSet) visit(dir string) bool {
+	key := filepath.Clean(dir)
+	if abs, err := filepath.Abs(key); err == nil {
+		key = abs
+	}
+	key = longPath(key)
+
+	if _, ok := a.seen[key]; ok {
+		return false
+	}
+	a.seen[key] = struct{}{}
+	return true
+}
+
+// add adds the storages of the objects directory "dir", found at the given
+// depth, followed by those of its own alternates. Directories which have
+// already been visited (as in a circular chain of alternates), which are nested
+// too deeply, or which do not exist, are skipped, as they are by Git.
+//
+// The directory is opened by its extended-length path on Windows (see:
+// longPath), but is recorded as it was given.
+func (a *alternateSet) add(dir string, depth int) error {
+	if depth > a.maxDepth {
+		a.record(dir, depth, AlternateTooDeep)
+		return nil
+	}
+	if !a.visit(dir) {
+		a.record(dir, depth, AlternateCyclic)
+		return nil
+	}
+	if fi, err := os.Stat(longPath(dir)); err != nil || !fi.IsDir() {
+		a.record(dir, depth, AlternateMissing)
+		return nil
+	}
+
+	packs, err := pack.NewStorageWithFileCache(longPath(dir), a.algo, a.files)
+	if err != nil {
+		return err
+	}
+	a.storages = append(a.storages, newFileStorer(dir, ""), packs)
+	a.record(dir, depth, AlternateUsed)
+
+	return a.addFile(dir, depth+1)
+}
+
+// record calls the set's log, if it has one, with the outcome of considering the
+// alternate "dir" found at the given depth.
+func (a *alternateSet) record(dir string, depth int, outcome AlternateOutcome) {
+	if a.log != nil {
+		a.log(&AlternateDecision{Dir: dir, Depth: depth, Outcome: outcome})
+	}
+}
+
+// addFile adds each alternate listed in the "info/alternates" file of the
+// objects directory "dir" at the given depth. Relative paths are taken relative
+// to "dir", and blank lines and comments are skipped, as they are by Git.
+func (a *alternateSet) addFile(dir string, depth int) error {
+	f, err := os.Open(longPath(filepath.Join(dir, "info", "alternates")))
 	if err != nil {
 		// No alternates file, no problem.
-		if err != os.ErrNotExist {
-			return storage, nil
-		}
-		return nil, err
+		return nil
 	}
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		storage, err = addAlternateDirectory(storage, scanner.Text(), algo)
-		if err != nil {
-			return nil, err
+		alt := scanner.Text()
+		if len(alt) == 0 || strings.HasPrefix(alt, "#") {
+			continue
+		}
+		if !filepath.IsAbs(alt) {
+			alt = filepath.Join(dir, alt)
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+		if err := a.add(alt, depth); err != nil {
+			return err
+		}
 	}
-
-	return storage, nil
+	return scanner.Err()
 }
 
-func addAlternateDirectory(s []storage.Storage, dir string, algo hash.Hash) ([]storage.Storage, error) {
-	s = append(s, newFileStorer(dir, ""))
-	pack, err := pack.NewStorage(dir, algo)
-	if err != nil {
-		return s, err
+// addLinkTarget adds the target of the objects directory "dir" as an alternate,
+// along with its own alternates, if "dir" is a symbolic link, as it is in a
+// git-repo checkout (see: GitRepoLayout), and calls "diagnose" (if it is not
+// nil) to describe the link.
+func (a *alternateSet) addLinkTarget(dir string, diagnose func(msg string)) error {
+	fi, err := os.Lstat(longPath(dir))
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return nil
 	}
-	s = append(s, pack)
-	return s, nil
-}
 
-func addAlternatesFromEnvironment(s []storage.Storage, env string, algo hash.Hash) ([]storage.Storage, error) {
-	if len(env) == 0 {
-		return s, nil
+	target, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
 	}
-
-	for _, dir := range splitAlternateString(env, alternatesSeparator) {
-		var err error
-		s, err = addAlternateDirectory(s, dir, algo)
-		if err != nil {
-			return nil, err
-		}
+	if diagnose != nil {
+		diagnose(fmt.Sprintf("gitobj: objects directory %s is a symbolic link to %s; "+
+			"using its target as an alternate", dir, target))
 	}
-	return s, nil
+	return a.add(target, 0)
 }
 
 var (
@@ -149,15 +336,69 @@ func NewMemoryBackend(m map[string]io.ReadWriter) (storage.Backend, error) {
 	return &memoryBackend{ms: newMemoryStorer(m)}, nil
 }
 
+// NewSpillingBackend initializes a new backend which holds small objects in
+// memory, as NewMemoryBackend does, and writes those whose stored (that is,
+// compressed) form is larger than "threshold" bytes to the backend "spill",
+// such as one on the filesystem (see: NewFilesystemBackend), so that large
+// histories may be built quickly, without holding every object in memory.
+// Objects are read from memory first, and then from "spill".
+//
+// Closing a database using the backend closes "spill" (and flushes any objects
+// it has staged).
+func NewSpillingBackend(spill storage.Backend, threshold int64) (storage.Backend, error) {
+	if spill == nil {
+		return nil, fmt.Errorf("gitobj: no backend to spill objects to")
+	}
+
+	ro, rw := spill.Storage()
+	if rw == nil {
+		return nil, fmt.Errorf("gitobj: cannot spill objects to a read-only backend")
+	}
+
+	ms := newMemoryStorer(nil)
+	return &spillingBackend{
+		ro: storage.MultiStorage(ms, ro),
+		rw: &spillingStorer{ms: ms, spill: rw, threshold: threshold},
+	}, nil
+}
+
+// NewInfoPacksBackend initializes a new backend reading the packfiles listed by
+// the "objects/info/packs" file read from "r", whose files are opened with
+// "open" (see: pack.NewSetFromInfoPacks), such as those of a mirror which
+// publishes only that listing. The hash algorithm used is specified by the
+// algo parameter.
+//
+// Objects written to the backend are held in memory, as with NewMemoryBackend.
+func NewInfoPacksBackend(r io.Reader, open pack.PackOpener, algo hash.Hash) (storage.Backend, error) {
+	packs, err := pack.NewStorageFromInfoPacks(r, open, algo)
+	if err != nil {
+		return nil, err
+	}
+	return &infoPacksBackend{ms: newMemoryStorer(nil), packs: packs}, nil
+}
+
 type filesystemBackend struct {
 	fs       *fileStorer
 	backends []storage.Storage
+	// local is the number of storages at the beginning of "backends"
+	// which belong to the repository (or its quarantine directory), rather
+	// than to an alternate.
+	local int
+	graph *commitGraph
 }
 
 func (b *filesystemBackend) Storage() (storage.Storage, storage.WritableStorage) {
 	return storage.MultiStorage(b.backends...), b.fs
 }
 
+func (b *filesystemBackend) commitGraph() *commitGraph {
+	return b.graph
+}
+
+func (b *filesystemBackend) localStorages() int {
+	return b.local
+}
+
 type memoryBackend struct {
 	ms *memoryStorer
 }
@@ -165,3 +406,21 @@ type memoryBackend struct {
 func (b *memoryBackend) Storage() (storage.Storage, storage.WritableStorage) {
 	return b.ms, b.ms
 }
+
+type spillingBackend struct {
+	ro storage.Storage
+	rw *spillingStorer
+}
+
+func (b *spillingBackend) Storage() (storage.Storage, storage.WritableStorage) {
+	return b.ro, b.rw
+}
+
+type infoPacksBackend struct {
+	ms    *memoryStorer
+	packs *pack.Storage
+}
+
+func (b *infoPacksBackend) Storage() (storage.Storage, storage.WritableStorage) {
+	return storage.MultiStorage(b.ms, b.packs), b.ms
+}
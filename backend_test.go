@@ -2,13 +2,22 @@ package gitobj
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"encoding/hex"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
+	"github.com/git-lfs/gitobj/v2/storage/storagetest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewMemoryBackend(t *testing.T) {
@@ -86,3 +95,211 @@ func TestSplitAlternatesString(t *testing.T) {
 		}
 	}
 }
+
+func TestAlternatesAreFollowedTransitively(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-alternates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dirs := alternatesTestDirs(t, dir, "root", "a", "b")
+	root, a, b := dirs[0], dirs[1], dirs[2]
+	writeTestAlternates(t, root, a)
+	// Relative paths are relative to the objects directory listing them.
+	writeTestAlternates(t, a, "# comment", "", filepath.Join("..", "b"))
+
+	assert.Equal(t, []string{root, a, b}, alternateRoots(t, root))
+}
+
+func TestNewInfoPacksBackend(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	sha, err := packed.WriteBlob(NewBlobFromBytes([]byte("packed\n")))
+	require.NoError(t, err)
+	require.NoError(t, packed.Flush())
+	require.NoError(t, packed.Close())
+
+	paths, err := filepath.Glob(filepath.Join(root, "pack", "*.pack"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	listing := "P " + filepath.Base(paths[0]) + "\n"
+
+	backend, err := NewInfoPacksBackend(strings.NewReader(listing), func(name string) (pack.File, error) {
+		return os.Open(filepath.Join(root, "pack", name))
+	}, sha1.New())
+	require.NoError(t, err)
+
+	db, err := FromBackend(backend)
+	require.NoError(t, err)
+	defer db.Close()
+
+	blob, err := db.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "packed\n", string(contents))
+}
+
+func TestAlternatesLogRecordsDecisions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-alternates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dirs := alternatesTestDirs(t, dir, "root", "a", "b")
+	root, a, b := dirs[0], dirs[1], dirs[2]
+	missing := filepath.Join(dir, "missing")
+	writeTestAlternates(t, root, a, missing, root)
+	writeTestAlternates(t, a, b)
+
+	var decisions []AlternateDecision
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	roots := alternateRoots(t, root, MaxAlternatesDepth(0), AlternatesLogFile(),
+		Clock(func() time.Time { return now }),
+		AlternatesLog(func(d *AlternateDecision) {
+			decisions = append(decisions, *d)
+		}))
+	assert.Equal(t, []string{root, a}, roots)
+
+	assert.Equal(t, []AlternateDecision{
+		{Dir: a, Depth: 0, Outcome: AlternateUsed},
+		{Dir: b, Depth: 1, Outcome: AlternateTooDeep},
+		{Dir: missing, Depth: 0, Outcome: AlternateMissing},
+		{Dir: root, Depth: 0, Outcome: AlternateCyclic},
+	}, decisions)
+
+	log, err := ioutil.ReadFile(filepath.Join(root, "info", "gitobj-alternates-log"))
+	require.NoError(t, err)
+	assert.Equal(t, "# 2026-10-16T12:00:00Z\n"+
+		"used 0 "+a+"\n"+
+		"too-deep 1 "+b+"\n"+
+		"missing 0 "+missing+"\n"+
+		"cyclic 0 "+root+"\n", string(log))
+}
+
+func TestAlternatesSkipCyclesAndDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-alternates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dirs := alternatesTestDirs(t, dir, "root", "a", "b")
+	root, a, b := dirs[0], dirs[1], dirs[2]
+	writeTestAlternates(t, root, a, b, a)
+	writeTestAlternates(t, a, b, root)
+	writeTestAlternates(t, b, a+string(filepath.Separator))
+
+	assert.Equal(t, []string{root, a, b}, alternateRoots(t, root))
+}
+
+func TestAlternatesAreLimitedInDepth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-alternates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dirs := alternatesTestDirs(t, dir, "root", "a", "b", "c", "d", "e", "f", "g", "h")
+	for i := 0; i+1 < len(dirs); i++ {
+		writeTestAlternates(t, dirs[i], dirs[i+1])
+	}
+
+	// "a" is at depth zero, and so "g" at depth six is ignored by
+	// default.
+	assert.Equal(t, dirs[:7], alternateRoots(t, dirs[0]))
+	assert.Equal(t, dirs[:3], alternateRoots(t, dirs[0], MaxAlternatesDepth(1)))
+	assert.Equal(t, dirs[:1], alternateRoots(t, dirs[0], MaxAlternatesDepth(-1)))
+}
+
+func TestGitRepoLayoutAddsLinkTargetAsAlternate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-alternates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs := alternatesTestDirs(t, dir, "project-objects", "mirror")
+	target, mirror := dirs[0], dirs[1]
+	// As written by a "--reference" mirror, relative to the target.
+	writeTestAlternates(t, target, filepath.Join("..", "mirror"))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "projects"), 0755))
+	root := filepath.Join(dir, "projects", "objects")
+	if err := os.Symlink(target, root); err != nil {
+		t.Skipf("cannot create symbolic link: %s", err)
+	}
+
+	var diagnostics []string
+	roots := alternateRoots(t, root, GitRepoLayout(func(msg string) {
+		diagnostics = append(diagnostics, msg)
+	}))
+	// The target is searched after the repository's own alternates,
+	// which are read through the link.
+	require.True(t, len(roots) >= 3)
+	assert.Equal(t, root, roots[0])
+	assert.Equal(t, []string{target, mirror}, roots[len(roots)-2:])
+	assert.Equal(t, []string{"gitobj: objects directory " + root +
+		" is a symbolic link to " + target + "; using its target as an alternate"}, diagnostics)
+
+	// Without the option, the target's alternates are not found.
+	assert.NotContains(t, alternateRoots(t, root), mirror)
+
+	// Objects directories which are not links are unaffected.
+	diagnostics = nil
+	assert.Equal(t, []string{target, mirror}, alternateRoots(t, target, GitRepoLayout(func(msg string) {
+		diagnostics = append(diagnostics, msg)
+	})))
+	assert.Empty(t, diagnostics)
+}
+
+// alternatesTestDirs creates an objects directory beneath "dir" with each of the
+// given names, and returns their paths.
+func alternatesTestDirs(t *testing.T, dir string, names ...string) (dirs []string) {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Join(path, "info"), 0755))
+		dirs = append(dirs, path)
+	}
+	return dirs
+}
+
+// writeTestAlternates writes the given lines as the "info/alternates" file of
+// the objects directory "dir".
+func writeTestAlternates(t *testing.T, dir string, lines ...string) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "info", "alternates"),
+		[]byte(strings.Join(lines, "\n")+"\n"), 0644))
+}
+
+// alternateRoots returns the objects directories searched by a database opened
+// at "root", in order.
+func alternateRoots(t *testing.T, root string, setters ...Option) []string {
+	db, err := FromFilesystem(root, "", setters...)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var roots []string
+	for _, stat := range db.LookupStats() {
+		if !stat.Packed {
+			roots = append(roots, filepath.Clean(stat.Root))
+		}
+	}
+	return roots
+}
+
+func TestFileStorerConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	storagetest.TestStorage(t, func(t *testing.T) storage.WritableStorage {
+		root, err := ioutil.TempDir(dir, "objects")
+		require.NoError(t, err)
+		tmp, err := ioutil.TempDir(dir, "tmp")
+		require.NoError(t, err)
+
+		return newFileStorer(root, tmp)
+	})
+}
@@ -0,0 +1,210 @@
+package gitobj
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// WriteBitmap writes a reachability bitmap for the packfile at "path" next to
+// it (replacing the ".pack" extension with ".bitmap"), holding a bitmap for
+// each of the given commits.
+//
+// Every object reachable from each commit must be stored in the packfile,
+// otherwise an error is returned and no bitmap is written.
+//
+// The name hash (see: pack.NameHash) of the first path at which each tree and
+// blob is found is also written, so that a later "git repack" may consider
+// deltas between objects found at similar paths without walking history again
+// (see: pack.Packfile.NameHashes). Deltas are not chosen by this package, whose
+// packfiles store every object whole (see: pack.Writer).
+func (o *ObjectDatabase) WriteBitmap(path string, commits [][]byte) error {
+	p, err := pack.OpenPackfile(path, o.Hasher())
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	w, err := pack.NewBitmapWriter(p, o.Hasher())
+	if err != nil {
+		return err
+	}
+
+	hashes := make(map[string]uint32)
+	for _, commit := range commits {
+		reachable, err := o.reachable(commit, hashes)
+		if err != nil {
+			return err
+		}
+		if err := w.Add(commit, reachable); err != nil {
+			return err
+		}
+	}
+	for name, hash := range hashes {
+		if err := w.SetNameHash([]byte(name), hash); err != nil {
+			return err
+		}
+	}
+
+	dest := strings.TrimSuffix(path, ".pack") + ".bitmap"
+
+	tmp, err := ioutil.TempFile(filepath.Dir(dest), "tmp_bitmap_")
+	if err != nil {
+		return err
+	}
+	defer o.cleanup(tmp)
+
+	if _, err := w.WriteTo(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// Reachable returns whether the object named "target" is reachable from any of
+// the objects named in "from" (typically commits), by following the parents and
+// trees of commits, the entries of trees (other than submodules), and the
+// objects to which tags point.
+//
+// If a packfile from which objects are read has a reachability bitmap file
+// (see: WriteBitmap, and pack.BitmapIndex), the history and trees beneath each
+// commit with a bitmap are not walked; only the commits more recent than those
+// with bitmaps are read. As in Git, a bitmap file which cannot be read is
+// ignored, and at most one bitmap file is used.
+func (o *ObjectDatabase) Reachable(from [][]byte, target []byte) (bool, error) {
+	bitmaps := o.bitmapIndex()
+
+	var reached *pack.ObjectBitmap
+	if bitmaps != nil {
+		reached = bitmaps.NewObjectBitmap()
+	}
+
+	seen := make(map[string]struct{})
+	pending := append([][]byte(nil), from...)
+	for len(pending) > 0 {
+		sha := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if bytes.Equal(sha, target) {
+			return true, nil
+		}
+		if _, ok := seen[string(sha)]; ok {
+			continue
+		}
+		seen[string(sha)] = struct{}{}
+
+		if reached != nil {
+			if bitmaps.Has(sha) {
+				objects, err := bitmaps.Reachable(sha)
+				if err != nil {
+					return false, err
+				}
+				reached.Or(objects)
+
+				if ok, err := reached.Contains(target); err != nil || ok {
+					return ok, err
+				}
+				continue
+			}
+
+			// Objects reachable from a commit with a bitmap have
+			// been accounted for along with their own references.
+			if ok, err := reached.Contains(sha); err != nil {
+				return false, err
+			} else if ok {
+				continue
+			}
+		}
+
+		refs, err := o.references(sha)
+		if err != nil {
+			return false, err
+		}
+		pending = append(pending, refs...)
+	}
+	return false, nil
+}
+
+// bitmapIndex returns the reachability bitmap file of the first packfile from
+// which objects are read that has one which can be read, or nil if there is
+// none.
+func (o *ObjectDatabase) bitmapIndex() *pack.BitmapIndex {
+	for _, s := range storages(o.ro) {
+		packs, ok := s.(*pack.Storage)
+		if !ok {
+			continue
+		}
+
+		for _, p := range packs.Packs() {
+			if idx, err := p.BitmapIndex(); err == nil {
+				return idx
+			}
+		}
+	}
+	return nil
+}
+
+// reachable returns the names of every commit, tree, and blob reachable from
+// the given commit, including the commit itself. Submodule commits are not
+// included.
+//
+// If "hashes" is non-nil, the name hash of the path at which each tree and blob
+// is first found is recorded in it, unless it has been recorded already.
+func (o *ObjectDatabase) reachable(commit []byte, hashes map[string]uint32) ([][]byte, error) {
+	var objects [][]byte
+	seen := make(map[string]struct{})
+
+	visit := func(sha []byte) bool {
+		if _, ok := seen[string(sha)]; ok {
+			return false
+		}
+		seen[string(sha)] = struct{}{}
+		objects = append(objects, sha)
+		return true
+	}
+
+	name := func(sha []byte, path string) {
+		if hashes == nil {
+			return
+		}
+		if _, ok := hashes[string(sha)]; !ok {
+			if hash := pack.NameHash(path); hash != 0 {
+				hashes[string(sha)] = hash
+			}
+		}
+	}
+
+	err := o.WalkCommits([][]byte{commit}, func(sha []byte, c *Commit) error {
+		visit(sha)
+		if !visit(c.TreeID) {
+			return nil
+		}
+
+		return o.WalkTree(c.TreeID, func(path string, e *TreeEntry) error {
+			switch e.Filemode & sIFMT {
+			case sIFGITLINK:
+				return nil
+			case sIFDIR:
+				if !visit(e.Oid) {
+					return SkipSubtree
+				}
+				name(e.Oid, path)
+				return nil
+			}
+			if visit(e.Oid) {
+				name(e.Oid, path)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
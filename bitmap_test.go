@@ -0,0 +1,168 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReachableIncludesHistoryTreesAndBlobs(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	root := writeTestTree(t, db)
+	c1, err := db.WriteCommit(&Commit{
+		Author:    "A U Thor <author@example.com> 100 +0000",
+		Committer: "A U Thor <author@example.com> 100 +0000",
+		TreeID:    root,
+		Message:   "initial commit",
+	})
+	require.NoError(t, err)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+
+	objects, err := db.reachable(c2, nil)
+	require.NoError(t, err)
+
+	var names []string
+	for _, sha := range objects {
+		names = append(names, hex.EncodeToString(sha))
+	}
+
+	c, err := db.Commit(c2)
+	require.NoError(t, err)
+	tree2, err := db.Tree(c.TreeID)
+	require.NoError(t, err)
+
+	// Two commits, two root trees, and the two subtrees and single blob
+	// of the first, and the single blob of the second.
+	assert.Len(t, names, 8)
+	for _, sha := range [][]byte{c1, c2, root, c.TreeID, tree2.Entries[0].Oid} {
+		assert.Contains(t, names, hex.EncodeToString(sha))
+	}
+}
+
+func TestReachableSkipsSubmodules(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "sub", Oid: make([]byte, 20), Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	commit, err := db.WriteCommit(&Commit{
+		Author:    "A U Thor <author@example.com> 100 +0000",
+		Committer: "A U Thor <author@example.com> 100 +0000",
+		TreeID:    tree,
+		Message:   "add submodule",
+	})
+	require.NoError(t, err)
+
+	objects, err := db.reachable(commit, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]byte{commit, tree}, objects)
+}
+
+func TestReachableWalksHistory(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+
+	first, err := db.Commit(c1)
+	require.NoError(t, err)
+	tree, err := db.Tree(first.TreeID)
+	require.NoError(t, err)
+
+	tag, err := db.WriteTag(&Tag{
+		Object:     c2,
+		ObjectType: CommitObjectType,
+		Name:       "v1",
+		Tagger:     "A U Thor <author@example.com> 300 +0000",
+		Message:    "v1",
+	})
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		from   []byte
+		target []byte
+		want   bool
+	}{
+		{c2, c1, true},
+		{c2, tree.Entries[0].Oid, true},
+		{tag, first.TreeID, true},
+		{c1, c2, false},
+		{c1, tag, false},
+		{c1, make([]byte, 20), false},
+	} {
+		ok, err := db.Reachable([][]byte{test.from}, test.target)
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, ok, "%x reaches %x", test.from, test.target)
+	}
+}
+
+func TestReachableUsesBitmaps(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	unrelated, err := db.WriteBlob(NewBlobFromBytes([]byte("unrelated\n")))
+	require.NoError(t, err)
+	require.NoError(t, db.Flush())
+
+	paths, err := filepath.Glob(filepath.Join(root, "pack", "*.pack"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	// Record in the bitmap of the first commit that the unrelated blob is
+	// reachable from it, so that it is only found to be reachable if the
+	// bitmap is used.
+	objects, err := db.reachable(c1, nil)
+	require.NoError(t, err)
+
+	p, err := pack.OpenPackfile(paths[0], db.Hasher())
+	require.NoError(t, err)
+	w, err := pack.NewBitmapWriter(p, db.Hasher())
+	require.NoError(t, err)
+	require.NoError(t, w.Add(c1, append(objects, unrelated)))
+
+	f, err := os.Create(strings.TrimSuffix(paths[0], ".pack") + ".bitmap")
+	require.NoError(t, err)
+	_, err = w.WriteTo(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, p.Close())
+
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+	require.NoError(t, db.Close())
+
+	db, err = FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, test := range []struct {
+		from   []byte
+		target []byte
+		want   bool
+	}{
+		{c2, unrelated, true},
+		{c1, unrelated, true},
+		{c2, c1, true},
+		{c1, c2, false},
+	} {
+		ok, err := db.Reachable([][]byte{test.from}, test.target)
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, ok, "%x reaches %x", test.from, test.target)
+	}
+}
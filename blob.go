@@ -6,6 +6,7 @@ import (
 	"hash"
 	"io"
 	"os"
+	"path/filepath"
 )
 
 // Blob represents a Git object of type "blob".
@@ -20,6 +21,9 @@ type Blob struct {
 	// the Blob.  In particular, this will close a file, if the Blob is
 	// being read from a file on disk.
 	closeFn func() error
+	// decodeStats describes how the blob was read, if it was read with
+	// RecordDecodeStats.
+	decodeStats *DecodeStats
 }
 
 // NewBlobFromBytes returns a new *Blob that yields the data given.
@@ -30,6 +34,21 @@ func NewBlobFromBytes(contents []byte) *Blob {
 	}
 }
 
+// NewBlobFromSymlink returns a new *Blob holding the target of the symbolic
+// link at location "path" on disk, as Git stores a symbolic link, rather than
+// the contents of the file to which it links (see: NewBlobFromFile). Separators
+// in the target are written as forward slashes, as Git writes them on Windows.
+//
+// If the link cannot be read, an error will be returned.
+func NewBlobFromSymlink(path string) (*Blob, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, fmt.Errorf("gitobj: could not read link: %s: %s", path,
+			err)
+	}
+	return NewBlobFromBytes([]byte(filepath.ToSlash(target))), nil
+}
+
 // NewBlobFromFile returns a new *Blob that contains the contents of the file
 // at location "path" on disk. NewBlobFromFile does not read the file ahead of
 // time, and instead defers this task until encoding the blob to the object
@@ -0,0 +1,83 @@
+package gitobj
+
+import (
+	"bytes"
+	"io"
+)
+
+// blobsEqualChunkSize is the number of bytes of each blob compared at a time
+// by BlobsEqual.
+const blobsEqualChunkSize = 32 * 1024
+
+// BlobsEqual returns whether the blobs named "a" and "b" have the same
+// contents, without reading either into memory in its entirety.
+//
+// If the names are equal, the blobs are not read at all. Otherwise, their sizes
+// are compared before their contents, which are read a chunk at a time, and
+// only as far as the first difference. If either object is not a blob, an
+// *UnexpectedObjectType is returned.
+func (o *ObjectDatabase) BlobsEqual(a, b []byte) (bool, error) {
+	if bytes.Equal(a, b) {
+		return true, nil
+	}
+
+	var sizes [2]int64
+	for i, sha := range [][]byte{a, b} {
+		typ, size, err := o.ObjectInfo(sha)
+		if err != nil {
+			return false, err
+		}
+		if typ != BlobObjectType {
+			return false, &UnexpectedObjectType{
+				Oid:    sha,
+				Got:    typ,
+				Wanted: BlobObjectType,
+			}
+		}
+		sizes[i] = size
+	}
+	if sizes[0] != sizes[1] {
+		return false, nil
+	}
+
+	ba, err := o.Blob(a)
+	if err != nil {
+		return false, err
+	}
+	defer ba.Close()
+
+	bb, err := o.Blob(b)
+	if err != nil {
+		return false, err
+	}
+	defer bb.Close()
+
+	return readersEqual(ba.Contents, bb.Contents)
+}
+
+// readersEqual returns whether "a" and "b" yield the same bytes, comparing them
+// a chunk at a time.
+func readersEqual(a, b io.Reader) (bool, error) {
+	bufa := make([]byte, blobsEqualChunkSize)
+	bufb := make([]byte, blobsEqualChunkSize)
+
+	for {
+		na, erra := io.ReadFull(a, bufa)
+		if erra != nil && erra != io.EOF && erra != io.ErrUnexpectedEOF {
+			return false, erra
+		}
+		nb, errb := io.ReadFull(b, bufb)
+		if errb != nil && errb != io.EOF && errb != io.ErrUnexpectedEOF {
+			return false, errb
+		}
+
+		if !bytes.Equal(bufa[:na], bufb[:nb]) {
+			return false, nil
+		}
+		if erra != nil || errb != nil {
+			// At least one reader is exhausted, so the two are
+			// equal only if both are.
+			return erra != nil && errb != nil, nil
+		}
+	}
+}
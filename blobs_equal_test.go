@@ -0,0 +1,72 @@
+package gitobj
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobsEqual(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	long := strings.Repeat("a", 3*blobsEqualChunkSize/2)
+
+	write := func(contents string) []byte {
+		sha, err := db.WriteBlob(NewBlobFromBytes([]byte(contents)))
+		require.NoError(t, err)
+		return sha
+	}
+
+	a := write(long + "a")
+	b := write(long + "b")
+	c := write(long)
+
+	for _, test := range []struct {
+		a, b  []byte
+		equal bool
+	}{
+		{a, a, true},
+		{a, append([]byte(nil), a...), true},
+		{a, b, false},
+		{a, c, false},
+		{c, a, false},
+	} {
+		equal, err := db.BlobsEqual(test.a, test.b)
+		require.NoError(t, err)
+		assert.Equal(t, test.equal, equal)
+	}
+}
+
+func TestReadersEqual(t *testing.T) {
+	long := strings.Repeat("a", 2*blobsEqualChunkSize)
+
+	equal, err := readersEqual(strings.NewReader(long), strings.NewReader(long))
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	equal, err = readersEqual(strings.NewReader(long), strings.NewReader(long[1:]+"b"))
+	require.NoError(t, err)
+	assert.False(t, equal)
+
+	equal, err = readersEqual(strings.NewReader(long), strings.NewReader(long+"a"))
+	require.NoError(t, err)
+	assert.False(t, equal)
+}
+
+func TestBlobsEqualRejectsOtherTypes(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	tree, err := db.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	_, err = db.BlobsEqual(blob, tree)
+	assert.Equal(t, &UnexpectedObjectType{
+		Oid:    tree,
+		Got:    TreeObjectType,
+		Wanted: BlobObjectType,
+	}, err)
+}
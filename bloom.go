@@ -0,0 +1,125 @@
+package gitobj
+
+import (
+	"math/bits"
+)
+
+const (
+	// bloomSeed0 and bloomSeed1 are the seeds of the two murmur3 hashes
+	// from which the keys of a changed-path Bloom filter are derived.
+	bloomSeed0 = 0x293ae76f
+	bloomSeed1 = 0x7e646e2c
+
+	// bloomDataHeaderWidth is the width of the header of the Bloom filter
+	// data chunk of a commit-graph, which gives the version of the hash
+	// function, the number of hashes per key, and the number of bits per
+	// entry.
+	bloomDataHeaderWidth = 12
+)
+
+// bloomFilter is the changed-path Bloom filter of a single commit, as written
+// into a commit-graph by "git commit-graph write --changed-paths". It holds
+// each path changed between the commit and its first parent, along with each
+// of the directories leading to them.
+type bloomFilter struct {
+	// data is the filter itself.
+	data []byte
+	// hashes is the number of hashes computed for each key.
+	hashes uint32
+	// version is the version of the murmur3 hash function with which keys
+	// are computed.
+	version uint32
+}
+
+// mayContain returns whether the path "path" may have changed between the
+// commit and its first parent. If it returns false, the path is certainly
+// unchanged. A filter which is empty is treated as if it held every path, as
+// it is by Git.
+func (f *bloomFilter) mayContain(path string) bool {
+	mod := uint64(len(f.data)) * 8
+	if mod == 0 {
+		return true
+	}
+
+	for _, h := range bloomKey(path, f.hashes, f.version) {
+		pos := uint64(h) % mod
+		if f.data[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomKey returns the hashes of the key by which "path" is recorded in a
+// changed-path Bloom filter.
+func bloomKey(path string, hashes, version uint32) []uint32 {
+	h0 := murmur3([]byte(path), bloomSeed0, version)
+	h1 := murmur3([]byte(path), bloomSeed1, version)
+
+	key := make([]uint32, hashes)
+	for i := range key {
+		key[i] = h0 + uint32(i)*h1
+	}
+	return key
+}
+
+// murmur3 returns the 32-bit murmur3 hash of "data" with the given seed, as
+// computed by the given version of Git's changed-path Bloom filters. Version 1
+// sign-extends each byte with the high bit set, which version 2 corrects; the
+// two agree on ASCII paths.
+func murmur3(data []byte, seed, version uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+		n  = 0xe6546b64
+	)
+
+	word := func(b byte) uint32 {
+		if version == 1 {
+			return uint32(int32(int8(b)))
+		}
+		return uint32(b)
+	}
+
+	h := seed
+	blocks := len(data) / 4
+	for i := 0; i < blocks; i++ {
+		b := data[i*4:]
+		k := word(b[0]) | word(b[1])<<8 | word(b[2])<<16 | word(b[3])<<24
+
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + n
+	}
+
+	var k uint32
+	tail := data[blocks*4:]
+	switch len(tail) {
+	case 3:
+		k ^= word(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= word(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= word(tail[0])
+
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+
+	return h
+}
@@ -0,0 +1,72 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The expected values below are those given by Git's own tests of its
+// changed-path Bloom filters (see: t/t0095-bloom.sh).
+
+func TestMurmur3(t *testing.T) {
+	for data, want := range map[string]uint32{
+		"":             0x00000000,
+		"Hello world!": 0x627b0c2c,
+		"The quick brown fox jumps over the lazy dog": 0x2e4ff723,
+	} {
+		for _, version := range []uint32{1, 2} {
+			assert.Equal(t, want, murmur3([]byte(data), 0, version), data)
+		}
+	}
+}
+
+func TestMurmur3HighBitVersions(t *testing.T) {
+	data := []byte{0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+
+	assert.Equal(t, uint32(0xa183ccfd), murmur3(data, 0, 2))
+	assert.NotEqual(t, murmur3(data, 0, 2), murmur3(data, 0, 1))
+}
+
+func TestBloomKey(t *testing.T) {
+	assert.Equal(t, []uint32{
+		0x5615800c, 0x5b966560, 0x61174ab4, 0x66983008,
+		0x6c19155c, 0x7199fab0, 0x771ae004,
+	}, bloomKey("", 7, 2))
+}
+
+func TestBloomFilterMayContain(t *testing.T) {
+	f := newTestBloomFilter("dir/file.txt", "dir")
+
+	assert.True(t, f.mayContain("dir/file.txt"))
+	assert.True(t, f.mayContain("dir"))
+	assert.False(t, f.mayContain("other.txt"))
+}
+
+func TestEmptyBloomFilterMayContainAnything(t *testing.T) {
+	f := &bloomFilter{hashes: 7, version: 2}
+
+	assert.True(t, f.mayContain("anything"))
+}
+
+// newTestBloomFilter returns a changed-path Bloom filter holding the given
+// paths, sized as Git sizes them, with ten bits per path.
+func newTestBloomFilter(paths ...string) *bloomFilter {
+	f := &bloomFilter{
+		data:    make([]byte, (len(paths)*10+7)/8),
+		hashes:  7,
+		version: 2,
+	}
+	if len(f.data) == 0 {
+		f.data = make([]byte, 1)
+	}
+
+	mod := uint64(len(f.data)) * 8
+	for _, path := range paths {
+		for _, h := range bloomKey(path, f.hashes, f.version) {
+			pos := uint64(h) % mod
+			f.data[pos/8] |= 1 << (pos % 8)
+		}
+	}
+	return f
+}
@@ -0,0 +1,65 @@
+package gitobj
+
+import (
+	"context"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// newBudget returns a new *pack.Budget allowing "limit" bytes to be reserved at
+// once, or nil if "limit" is not positive.
+func newBudget(limit int64) *pack.Budget {
+	if limit <= 0 {
+		return nil
+	}
+	return pack.NewBudget(limit)
+}
+
+// WithMemoryLimit returns a copy of the given context which limits the memory
+// allocated to read objects using it to "limit" bytes at once, for instance to
+// bound the memory used by a single request. The memory reserved is also
+// counted against the database's own limit, if it has one (see: MemoryLimit).
+//
+// Reads which would exceed either limit fail with a *pack.BudgetExceededErr.
+//
+// As with MemoryLimit, a "limit" of zero or less is unlimited, and so the
+// context is returned as-is.
+func (o *ObjectDatabase) WithMemoryLimit(ctx context.Context, limit int64) context.Context {
+	if limit <= 0 {
+		return ctx
+	}
+	if o.budget == nil {
+		return pack.WithBudget(ctx, newBudget(limit))
+	}
+	return pack.WithBudget(ctx, o.budget.Child(limit))
+}
+
+// withBudget returns the given context, carrying the database's budget if it
+// has one and the context does not already carry a budget of its own.
+func (o *ObjectDatabase) withBudget(ctx context.Context) context.Context {
+	if o.budget == nil || pack.BudgetFromContext(ctx) != nil {
+		return ctx
+	}
+	return pack.WithBudget(ctx, o.budget)
+}
+
+// decodeWithin is as decode, but reserves the memory needed to decode an object
+// other than a blob (whose contents are read on demand) from the given budget
+// for as long as it is being decoded. If the reservation fails, "r" is closed.
+func (o *ObjectDatabase) decodeWithin(budget *pack.Budget, r *ObjectReader, into Object) error {
+	if budget == nil || into.Type() == BlobObjectType {
+		return o.decode(r, into)
+	}
+
+	_, size, err := r.Header()
+	if err != nil {
+		return err
+	}
+	if err := budget.Reserve(size); err != nil {
+		r.Close()
+		return err
+	}
+	defer budget.Release(size)
+
+	return o.decode(r, into)
+}
@@ -0,0 +1,66 @@
+package gitobj
+
+import (
+	"context"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b, MemoryLimit(16))
+	require.NoError(t, err)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	_, err = db.Tree(tree)
+	require.IsType(t, &pack.BudgetExceededErr{}, err)
+	assert.EqualValues(t, 16, err.(*pack.BudgetExceededErr).Limit)
+	assert.EqualValues(t, 0, db.budget.Used())
+
+	// Blobs are read on demand, and so are not limited by their size.
+	_, err = db.Blob(blob)
+	assert.NoError(t, err)
+
+	db, err = FromBackend(b, MemoryLimit(1024))
+	require.NoError(t, err)
+
+	_, err = db.TreeContext(context.Background(), tree)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, db.budget.Used())
+
+	ctx := db.WithMemoryLimit(context.Background(), 16)
+	_, err = db.TreeContext(ctx, tree)
+	assert.IsType(t, &pack.BudgetExceededErr{}, err)
+}
+
+func TestWithMemoryLimitIsUnlimitedWhenNotPositive(t *testing.T) {
+	for _, setters := range [][]Option{nil, {MemoryLimit(1024)}} {
+		b, err := NewMemoryBackend(nil)
+		require.NoError(t, err)
+
+		db, err := FromBackend(b, setters...)
+		require.NoError(t, err)
+
+		tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+			{Name: "hello.txt", Oid: make([]byte, 20), Filemode: 0100644},
+		}})
+		require.NoError(t, err)
+
+		for _, limit := range []int64{0, -1} {
+			ctx := db.WithMemoryLimit(context.Background(), limit)
+			_, err = db.TreeContext(ctx, tree)
+			assert.NoError(t, err)
+		}
+	}
+}
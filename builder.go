@@ -0,0 +1,482 @@
+package gitobj
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// commitHeaders are the headers of a commit which are not extra headers, and so
+// may not be given as such.
+var commitHeaders = map[string]struct{}{
+	"tree":      {},
+	"parent":    {},
+	"author":    {},
+	"committer": {},
+}
+
+// CommitBuilder constructs a *Commit to be written to an *ObjectDatabase,
+// validating each field as it is set, so that mistakes are caught where they
+// are made, rather than when the commit is written (or read by Git).
+type CommitBuilder struct {
+	hashlen int
+	clock   func() time.Time
+	// identity is the identity with which a missing author or committer
+	// is filled in, or nil (see: DefaultIdentity).
+	identity *Signature
+	commit   Commit
+}
+
+// NewCommitBuilder returns a new *CommitBuilder for a commit to be written to
+// the database, whose object IDs must be in its object format. Identities given
+// by name and email are stamped with the time given by the database's clock
+// (see: Clock).
+func (o *ObjectDatabase) NewCommitBuilder() *CommitBuilder {
+	return &CommitBuilder{
+		hashlen:  o.Hasher().Size(),
+		clock:    o.clock,
+		identity: o.identity,
+	}
+}
+
+// SetTree sets the root tree of the commit.
+func (b *CommitBuilder) SetTree(oid []byte) error {
+	if err := validateOid(b.hashlen, "tree", oid); err != nil {
+		return err
+	}
+	b.commit.TreeID = append([]byte(nil), oid...)
+	return nil
+}
+
+// AddParent adds a parent to the commit, after any added so far.
+func (b *CommitBuilder) AddParent(oid []byte) error {
+	if err := validateOid(b.hashlen, "parent", oid); err != nil {
+		return err
+	}
+	b.commit.ParentIDs = append(b.commit.ParentIDs, append([]byte(nil), oid...))
+	return nil
+}
+
+// SetAuthor sets the author of the commit, which must be an identity of the
+// form "Name <email> timestamp timezone". Its timestamp is normalized as by
+// StrictSignatures.
+func (b *CommitBuilder) SetAuthor(ident string) (err error) {
+	b.commit.Author, err = validateIdent("author", ident, b.commit.Author)
+	return err
+}
+
+// SetCommitter sets the committer of the commit (see: SetAuthor).
+func (b *CommitBuilder) SetCommitter(ident string) (err error) {
+	b.commit.Committer, err = validateIdent("committer", ident, b.commit.Committer)
+	return err
+}
+
+// SetAuthorIdentity sets the author of the commit to the identity with the given
+// name and email, stamped with the current time, as given by the database's
+// clock (see: Clock).
+func (b *CommitBuilder) SetAuthorIdentity(name, email string) error {
+	return b.SetAuthor(stamp(b.clock, name, email))
+}
+
+// SetCommitterIdentity sets the committer of the commit as SetAuthorIdentity
+// sets its author.
+func (b *CommitBuilder) SetCommitterIdentity(name, email string) error {
+	return b.SetCommitter(stamp(b.clock, name, email))
+}
+
+// AddExtraHeader adds an extra header, such as "encoding" or "gpgsig", to the
+// commit, after any added so far (see: Commit.AddExtraHeader).
+func (b *CommitBuilder) AddExtraHeader(k, v string) error {
+	return b.commit.AddExtraHeader(k, v)
+}
+
+// SetMessage sets the message of the commit.
+func (b *CommitBuilder) SetMessage(message string) {
+	b.commit.Message = message
+}
+
+// Commit returns the commit built so far, or an error if its tree, author, or
+// committer has not been set. The builder may continue to be used afterwards
+// without affecting the returned commit.
+//
+// If the database has a default identity (see: DefaultIdentity), a missing
+// author or committer is set to that identity, stamped with the current time,
+// instead.
+func (b *CommitBuilder) Commit() (*Commit, error) {
+	if b.identity != nil {
+		ident := stamp(b.clock, b.identity.Name, b.identity.Email)
+		if len(b.commit.Author) == 0 {
+			if err := b.SetAuthor(ident); err != nil {
+				return nil, err
+			}
+		}
+		if len(b.commit.Committer) == 0 {
+			if err := b.SetCommitter(ident); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch {
+	case b.commit.TreeID == nil:
+		return nil, fmt.Errorf("gitobj: commit has no tree")
+	case len(b.commit.Author) == 0:
+		return nil, fmt.Errorf("gitobj: commit has no author")
+	case len(b.commit.Committer) == 0:
+		return nil, fmt.Errorf("gitobj: commit has no committer")
+	}
+	return copyCommit(&b.commit), nil
+}
+
+// TreeBuilder constructs a *Tree to be written to an *ObjectDatabase,
+// validating each entry as it is added (see: CommitBuilder). Entries may also
+// be added by their paths, such as "a/b/c.txt", in which case the subtrees
+// leading to them are built alongside the tree, and written with it (see:
+// AddPath and Write).
+type TreeBuilder struct {
+	db      *ObjectDatabase
+	hashlen int
+	entries map[string]*TreeEntry
+	// subtrees are the builders of the subtrees to which entries have been
+	// added by path, by name. No name is used by both an entry and a
+	// subtree.
+	subtrees map[string]*TreeBuilder
+}
+
+// NewTreeBuilder returns a new *TreeBuilder for a tree to be written to the
+// database, whose object IDs must be in its object format.
+func (o *ObjectDatabase) NewTreeBuilder() *TreeBuilder {
+	return &TreeBuilder{
+		db:       o,
+		hashlen:  o.Hasher().Size(),
+		entries:  make(map[string]*TreeEntry),
+		subtrees: make(map[string]*TreeBuilder),
+	}
+}
+
+// checkTreeEntryName returns an error if the given name is not a single,
+// non-empty path component other than "." or "..".
+func checkTreeEntryName(name string) error {
+	switch {
+	case len(name) == 0, name == ".", name == "..", strings.ContainsAny(name, "/\x00"):
+		return fmt.Errorf("gitobj: invalid tree entry name %q", name)
+	}
+	return nil
+}
+
+// AddEntry adds an entry to the tree. Its name must be a single, non-empty path
+// component other than "." or "..", which is not already in use by another
+// entry, and its mode must be one which Git writes: 0100644 or 0100755 for a
+// file, 0120000 for a symbolic link, 040000 for a subtree, or 0160000 for a
+// submodule.
+func (b *TreeBuilder) AddEntry(name string, oid []byte, mode int32) error {
+	if err := checkTreeEntryName(name); err != nil {
+		return err
+	}
+	if _, ok := b.entries[name]; ok {
+		return fmt.Errorf("gitobj: duplicate tree entry %q", name)
+	}
+	if _, ok := b.subtrees[name]; ok {
+		return fmt.Errorf("gitobj: duplicate tree entry %q", name)
+	}
+
+	switch mode {
+	case sIFREG | 0644, sIFREG | 0755, sIFLNK, sIFDIR, sIFGITLINK:
+	default:
+		return fmt.Errorf("gitobj: invalid mode %o for tree entry %q", mode, name)
+	}
+
+	if err := validateOid(b.hashlen, fmt.Sprintf("tree entry %q", name), oid); err != nil {
+		return err
+	}
+
+	b.entries[name] = &TreeEntry{
+		Name:     name,
+		Oid:      append([]byte(nil), oid...),
+		Filemode: mode,
+	}
+	return nil
+}
+
+// AddPath adds an entry to the tree, or to one of its subtrees, at the given
+// slash-separated path, such as "a/b/c.txt", building each subtree leading to
+// it which is not already being built. The entry itself is validated as by
+// AddEntry.
+//
+// If a subtree leading to the entry is already an entry of the tree with mode
+// 040000, that subtree is read from the database, and the entry is added to
+// its entries, replacing it when the tree is written.
+func (b *TreeBuilder) AddPath(path string, oid []byte, mode int32) error {
+	dirs := strings.Split(path, "/")
+	name := dirs[len(dirs)-1]
+	dirs = dirs[:len(dirs)-1]
+
+	at := b
+	for i, dir := range dirs {
+		sub, err := at.subtree(dir)
+		if err != nil {
+			return err
+		}
+		if sub == nil {
+			return fmt.Errorf("gitobj: cannot add %q: %q is not a tree",
+				path, strings.Join(dirs[:i+1], "/"))
+		}
+		at = sub
+	}
+	return at.AddEntry(name, oid, mode)
+}
+
+// RemovePath removes the entry at the given slash-separated path from the tree,
+// or from one of its subtrees, if there is one (see: AddPath).
+func (b *TreeBuilder) RemovePath(path string) error {
+	dirs := strings.Split(path, "/")
+	name := dirs[len(dirs)-1]
+	dirs = dirs[:len(dirs)-1]
+
+	at := b
+	for _, dir := range dirs {
+		if _, ok := at.entries[dir]; !ok && at.subtrees[dir] == nil {
+			return nil
+		}
+
+		sub, err := at.subtree(dir)
+		if err != nil {
+			return err
+		}
+		if sub == nil {
+			return nil
+		}
+		at = sub
+	}
+	at.RemoveEntry(name)
+	return nil
+}
+
+// subtree returns the builder of the subtree with the given name, creating it
+// if it is not yet being built, or nil if the name is in use by an entry which
+// is not a subtree.
+func (b *TreeBuilder) subtree(name string) (*TreeBuilder, error) {
+	if sub, ok := b.subtrees[name]; ok {
+		return sub, nil
+	}
+	if err := checkTreeEntryName(name); err != nil {
+		return nil, err
+	}
+
+	sub := b.db.NewTreeBuilder()
+	if e, ok := b.entries[name]; ok {
+		if e.Filemode != sIFDIR {
+			return nil, nil
+		}
+
+		tree, err := b.db.Tree(e.Oid)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range tree.Entries {
+			sub.entries[e.Name] = e
+		}
+		delete(b.entries, name)
+	}
+
+	b.subtrees[name] = sub
+	return sub, nil
+}
+
+// RemoveEntry removes the entry with the given name from the tree, if there is
+// one, along with any subtree being built under that name.
+func (b *TreeBuilder) RemoveEntry(name string) {
+	delete(b.entries, name)
+	delete(b.subtrees, name)
+}
+
+// Tree returns the tree built so far, with its entries in the order in which
+// they must be written (see: SubtreeOrder). The builder may continue to be used
+// afterwards without affecting the returned tree.
+//
+// Subtrees to which entries have been added by path are not included, since
+// their object IDs are not known until they have been written (see: Write).
+func (b *TreeBuilder) Tree() *Tree {
+	entries := make([]*TreeEntry, 0, len(b.entries))
+	for _, e := range b.entries {
+		entries = append(entries, &TreeEntry{
+			Name:     e.Name,
+			Oid:      append([]byte(nil), e.Oid...),
+			Filemode: e.Filemode,
+		})
+	}
+	sort.Sort(SubtreeOrder(entries))
+
+	return &Tree{Entries: entries}
+}
+
+// Write writes the tree built so far to the database, along with each subtree
+// to which entries have been added by path, deepest first, and returns the
+// object ID of the tree. Subtrees left without entries are omitted, as Git
+// does not record empty directories, and identical subtrees are written only
+// once (see: WriteTrees). The builder may continue to be used afterwards.
+func (b *TreeBuilder) Write() ([]byte, error) {
+	batch := b.db.newTreeBatch()
+
+	tree, err := b.build(batch)
+	if err != nil {
+		return nil, err
+	}
+	return batch.write(tree)
+}
+
+// build writes each subtree being built as part of the given batch, and returns
+// the tree built so far, including them.
+func (b *TreeBuilder) build(batch *treeBatch) (*Tree, error) {
+	tree := b.Tree()
+	for name, sub := range b.subtrees {
+		subtree, err := sub.build(batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(subtree.Entries) == 0 {
+			continue
+		}
+
+		oid, err := batch.write(subtree)
+		if err != nil {
+			return nil, err
+		}
+		tree.Entries = append(tree.Entries, &TreeEntry{
+			Name:     name,
+			Oid:      oid,
+			Filemode: sIFDIR,
+		})
+	}
+	sort.Sort(SubtreeOrder(tree.Entries))
+
+	return tree, nil
+}
+
+// TagBuilder constructs a *Tag to be written to an *ObjectDatabase, validating
+// each field as it is set (see: CommitBuilder).
+type TagBuilder struct {
+	hashlen  int
+	clock    func() time.Time
+	identity *Signature
+	tag      Tag
+}
+
+// NewTagBuilder returns a new *TagBuilder for a tag to be written to the
+// database, whose object IDs must be in its object format (see:
+// NewCommitBuilder).
+func (o *ObjectDatabase) NewTagBuilder() *TagBuilder {
+	return &TagBuilder{
+		hashlen:  o.Hasher().Size(),
+		clock:    o.clock,
+		identity: o.identity,
+	}
+}
+
+// SetObject sets the object which is tagged, along with its type.
+func (b *TagBuilder) SetObject(oid []byte, typ ObjectType) error {
+	switch typ {
+	case BlobObjectType, TreeObjectType, CommitObjectType, TagObjectType:
+	default:
+		return fmt.Errorf("gitobj: cannot tag object of type %q", typ)
+	}
+	if err := validateOid(b.hashlen, "tagged object", oid); err != nil {
+		return err
+	}
+
+	b.tag.Object = append([]byte(nil), oid...)
+	b.tag.ObjectType = typ
+	return nil
+}
+
+// SetName sets the name of the tag, which may not be empty, nor contain spaces
+// or newlines.
+func (b *TagBuilder) SetName(name string) error {
+	if len(name) == 0 || strings.ContainsAny(name, " \n") {
+		return fmt.Errorf("gitobj: invalid tag name %q", name)
+	}
+	b.tag.Name = name
+	return nil
+}
+
+// SetTagger sets the tagger of the tag (see: CommitBuilder.SetAuthor). Tags
+// need not have a tagger.
+func (b *TagBuilder) SetTagger(ident string) (err error) {
+	b.tag.Tagger, err = validateIdent("tagger", ident, b.tag.Tagger)
+	return err
+}
+
+// SetTaggerIdentity sets the tagger of the tag to the identity with the given
+// name and email (see: CommitBuilder.SetAuthorIdentity).
+func (b *TagBuilder) SetTaggerIdentity(name, email string) error {
+	return b.SetTagger(stamp(b.clock, name, email))
+}
+
+// SetMessage sets the message of the tag.
+func (b *TagBuilder) SetMessage(message string) {
+	b.tag.Message = message
+}
+
+// Tag returns the tag built so far, or an error if its object or name has not
+// been set. The builder may continue to be used afterwards without affecting
+// the returned tag.
+//
+// If the database has a default identity (see: DefaultIdentity), a missing
+// tagger is set to that identity, stamped with the current time.
+func (b *TagBuilder) Tag() (*Tag, error) {
+	if b.identity != nil && len(b.tag.Tagger) == 0 {
+		if err := b.SetTaggerIdentity(b.identity.Name, b.identity.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case b.tag.Object == nil:
+		return nil, fmt.Errorf("gitobj: tag has no object")
+	case len(b.tag.Name) == 0:
+		return nil, fmt.Errorf("gitobj: tag has no name")
+	}
+
+	t := b.tag
+	t.Object = append([]byte(nil), b.tag.Object...)
+	return &t, nil
+}
+
+// stamp returns the identity with the given name and email, stamped with the
+// time given by "clock".
+func stamp(clock func() time.Time, name, email string) string {
+	return (&Signature{Name: name, Email: email, When: clock()}).String()
+}
+
+// validateOid returns an error if "oid", which names the given thing, is not the
+// length of an object ID of "hashlen" bytes.
+func validateOid(hashlen int, what string, oid []byte) error {
+	if len(oid) != hashlen {
+		return fmt.Errorf("gitobj: invalid object ID %x for %s: expected %d bytes, got %d",
+			oid, what, hashlen, len(oid))
+	}
+	return nil
+}
+
+// validateIdent returns the normalized form of the identity "ident", held by the
+// named header, or "current" along with an *InvalidSignature if it is invalid.
+func validateIdent(header, ident, current string) (string, error) {
+	invalid := func(reason string) (string, error) {
+		return current, &InvalidSignature{Header: header, Ident: ident, Reason: reason}
+	}
+
+	if strings.ContainsAny(ident, "\n\x00") {
+		return invalid("contains newline or NUL")
+	}
+	lt, gt := strings.IndexByte(ident, '<'), strings.LastIndexByte(ident, '>')
+	if lt < 0 || gt < lt || strings.IndexByte(ident[lt+1:], '<') >= 0 {
+		return invalid("missing email")
+	}
+
+	normalized, err := normalizeSignature(header, ident)
+	if err != nil {
+		return current, err
+	}
+	return normalized, nil
+}
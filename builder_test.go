@@ -0,0 +1,256 @@
+package gitobj
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitBuilderBuildsCommits(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	b := db.NewCommitBuilder()
+	require.NoError(t, b.SetTree(make([]byte, 20)))
+	require.NoError(t, b.AddParent([]byte("aaaaaaaaaaaaaaaaaaaa")))
+	require.NoError(t, b.SetAuthor("A U Thor <author@example.com> 0100 +0000"))
+	require.NoError(t, b.SetCommitter("C O Mitter <committer@example.com>  200 -0700"))
+	require.NoError(t, b.AddExtraHeader("encoding", "ISO-8859-1"))
+	b.SetMessage("initial commit\n")
+
+	c, err := b.Commit()
+	require.NoError(t, err)
+
+	assert.True(t, c.Equal(&Commit{
+		Author:       "A U Thor <author@example.com> 100 +0000",
+		Committer:    "C O Mitter <committer@example.com> 200 -0700",
+		ParentIDs:    [][]byte{[]byte("aaaaaaaaaaaaaaaaaaaa")},
+		TreeID:       make([]byte, 20),
+		ExtraHeaders: []*ExtraHeader{{K: "encoding", V: "ISO-8859-1"}},
+		Message:      "initial commit\n",
+	}))
+
+	_, err = db.WriteCommit(c)
+	assert.NoError(t, err)
+}
+
+func TestCommitBuilderRejectsInvalidFields(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	b := db.NewCommitBuilder()
+
+	assert.EqualError(t, b.SetTree(make([]byte, 32)),
+		"gitobj: invalid object ID 0000000000000000000000000000000000000000000000000000000000000000 for tree: expected 20 bytes, got 32")
+	assert.Error(t, b.AddParent([]byte("short")))
+	assert.IsType(t, &InvalidSignature{}, b.SetAuthor("A U Thor 100 +0000"))
+	assert.IsType(t, &InvalidSignature{}, b.SetCommitter("A U Thor <author@example.com> 100 +2500"))
+	assert.IsType(t, &InvalidSignature{}, b.SetCommitter("A U\nThor <author@example.com> 100 +0000"))
+	assert.EqualError(t, b.AddExtraHeader("parent", "x"), `gitobj: "parent" is not an extra header`)
+	assert.EqualError(t, b.AddExtraHeader("two words", "x"), `gitobj: invalid commit header "two words"`)
+
+	_, err := b.Commit()
+	assert.EqualError(t, err, "gitobj: commit has no tree")
+
+	require.NoError(t, b.SetTree(make([]byte, 20)))
+	_, err = b.Commit()
+	assert.EqualError(t, err, "gitobj: commit has no author")
+}
+
+func TestTreeBuilderBuildsSortedTrees(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	b := db.NewTreeBuilder()
+	require.NoError(t, b.AddEntry("a.txt", make([]byte, 20), 0100644))
+	require.NoError(t, b.AddEntry("a", make([]byte, 20), 040000))
+	require.NoError(t, b.AddEntry("a-", make([]byte, 20), 0100755))
+	require.NoError(t, b.AddEntry("removed", make([]byte, 20), 0120000))
+	b.RemoveEntry("removed")
+
+	tree := b.Tree()
+
+	var names []string
+	for _, e := range tree.Entries {
+		names = append(names, e.Name)
+	}
+	assert.Equal(t, []string{"a-", "a.txt", "a"}, names)
+}
+
+func TestTreeBuilderRejectsInvalidEntries(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	b := db.NewTreeBuilder()
+	require.NoError(t, b.AddEntry("a.txt", make([]byte, 20), 0100644))
+
+	for _, name := range []string{"", ".", "..", "a/b", "a\x00b"} {
+		assert.Error(t, b.AddEntry(name, make([]byte, 20), 0100644), name)
+	}
+	assert.EqualError(t, b.AddEntry("a.txt", make([]byte, 20), 0100644),
+		`gitobj: duplicate tree entry "a.txt"`)
+	assert.EqualError(t, b.AddEntry("b.txt", make([]byte, 20), 0100664),
+		`gitobj: invalid mode 100664 for tree entry "b.txt"`)
+	assert.Error(t, b.AddEntry("b.txt", make([]byte, 19), 0100644))
+
+	assert.Len(t, b.Tree().Entries, 1)
+}
+
+func TestTreeBuilderWritesNestedTreesFromPaths(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	b := db.NewTreeBuilder()
+	require.NoError(t, b.AddPath("a/b/c.txt", blob, 0100644))
+	require.NoError(t, b.AddPath("a/b.txt", blob, 0100755))
+	require.NoError(t, b.AddPath("a-", blob, 0100644))
+	require.NoError(t, b.AddPath("empty/removed.txt", blob, 0100644))
+	require.NoError(t, b.RemovePath("empty/removed.txt"))
+
+	assert.EqualError(t, b.AddPath("a/b.txt/d.txt", blob, 0100644),
+		`gitobj: cannot add "a/b.txt/d.txt": "a/b.txt" is not a tree`)
+	assert.EqualError(t, b.AddPath("a/b/c.txt", blob, 0100644),
+		`gitobj: duplicate tree entry "c.txt"`)
+	assert.Error(t, b.AddPath("a//c.txt", blob, 0100644))
+
+	root, err := b.Write()
+	require.NoError(t, err)
+
+	sub := db.NewTreeBuilder()
+	require.NoError(t, sub.AddEntry("c.txt", blob, 0100644))
+	ab, err := sub.Write()
+	require.NoError(t, err)
+
+	sub = db.NewTreeBuilder()
+	require.NoError(t, sub.AddEntry("b", ab, 040000))
+	require.NoError(t, sub.AddEntry("b.txt", blob, 0100755))
+	a, err := sub.Write()
+	require.NoError(t, err)
+
+	tree, err := db.Tree(root)
+	require.NoError(t, err)
+	assert.Equal(t, []*TreeEntry{
+		{Name: "a-", Oid: blob, Filemode: 0100644},
+		{Name: "a", Oid: a, Filemode: 040000},
+	}, tree.Entries)
+}
+
+func TestTreeBuilderAddsPathsToExistingSubtrees(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	sub := db.NewTreeBuilder()
+	require.NoError(t, sub.AddPath("dir/a.txt", blob, 0100644))
+	require.NoError(t, sub.AddPath("dir/b.txt", blob, 0100644))
+	before, err := sub.Write()
+	require.NoError(t, err)
+
+	tree, err := db.Tree(before)
+	require.NoError(t, err)
+
+	b := db.NewTreeBuilder()
+	for _, e := range tree.Entries {
+		require.NoError(t, b.AddEntry(e.Name, e.Oid, e.Filemode))
+	}
+	require.NoError(t, b.RemovePath("dir/a.txt"))
+	require.NoError(t, b.AddPath("dir/c.txt", blob, 0100644))
+	require.NoError(t, b.RemovePath("missing/a.txt"))
+	after, err := b.Write()
+	require.NoError(t, err)
+
+	expected := db.NewTreeBuilder()
+	require.NoError(t, expected.AddPath("dir/b.txt", blob, 0100644))
+	require.NoError(t, expected.AddPath("dir/c.txt", blob, 0100644))
+	want, err := expected.Write()
+	require.NoError(t, err)
+
+	assert.Equal(t, want, after)
+}
+
+func TestTagBuilderBuildsTags(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	b := db.NewTagBuilder()
+	_, err := b.Tag()
+	assert.EqualError(t, err, "gitobj: tag has no object")
+
+	assert.Error(t, b.SetObject(make([]byte, 20), UnknownObjectType))
+	require.NoError(t, b.SetObject(make([]byte, 20), CommitObjectType))
+	assert.EqualError(t, b.SetName("v 1"), `gitobj: invalid tag name "v 1"`)
+	require.NoError(t, b.SetName("v1.0.0"))
+	require.NoError(t, b.SetTagger("A U Thor <author@example.com> 100 +0000"))
+	b.SetMessage("v1.0.0\n")
+
+	tag, err := b.Tag()
+	require.NoError(t, err)
+	assert.Equal(t, &Tag{
+		Object:     make([]byte, 20),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "A U Thor <author@example.com> 100 +0000",
+		Message:    "v1.0.0\n",
+	}, tag)
+}
+
+func TestBuildersStampIdentitiesWithClock(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	when := time.Unix(1494258422, 0).In(time.FixedZone("", -6*60*60))
+	db, err := FromBackend(b, Clock(func() time.Time { return when }))
+	require.NoError(t, err)
+
+	cb := db.NewCommitBuilder()
+	require.NoError(t, cb.SetTree(make([]byte, 20)))
+	require.NoError(t, cb.SetAuthorIdentity("A U Thor", "author@example.com"))
+	require.NoError(t, cb.SetCommitterIdentity("C O Mitter", "committer@example.com"))
+
+	c, err := cb.Commit()
+	require.NoError(t, err)
+	assert.Equal(t, "A U Thor <author@example.com> 1494258422 -0600", c.Author)
+	assert.Equal(t, "C O Mitter <committer@example.com> 1494258422 -0600", c.Committer)
+
+	tb := db.NewTagBuilder()
+	require.NoError(t, tb.SetObject(make([]byte, 20), CommitObjectType))
+	require.NoError(t, tb.SetName("v1.0.0"))
+	require.NoError(t, tb.SetTaggerIdentity("T A Gger", "tagger@example.com"))
+
+	tag, err := tb.Tag()
+	require.NoError(t, err)
+	assert.Equal(t, "T A Gger <tagger@example.com> 1494258422 -0600", tag.Tagger)
+}
+
+func TestBuildersFillInDefaultIdentity(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	when := time.Unix(100, 0).UTC()
+	db, err := FromBackend(b,
+		Clock(func() time.Time { return when }),
+		DefaultIdentity("A U Thor", "author@example.com"))
+	require.NoError(t, err)
+
+	cb := db.View().NewCommitBuilder()
+	require.NoError(t, cb.SetTree(make([]byte, 20)))
+	require.NoError(t, cb.SetCommitter("C O Mitter <committer@example.com> 200 -0700"))
+
+	c, err := cb.Commit()
+	require.NoError(t, err)
+	assert.Equal(t, "A U Thor <author@example.com> 100 +0000", c.Author)
+	assert.Equal(t, "C O Mitter <committer@example.com> 200 -0700", c.Committer)
+
+	tb := db.NewTagBuilder()
+	require.NoError(t, tb.SetObject(make([]byte, 20), CommitObjectType))
+	require.NoError(t, tb.SetName("v1.0.0"))
+
+	tag, err := tb.Tag()
+	require.NoError(t, err)
+	assert.Equal(t, "A U Thor <author@example.com> 100 +0000", tag.Tagger)
+
+	// Without a default identity, the author must still be given.
+	cb = newTestMemoryDatabase(t).NewCommitBuilder()
+	require.NoError(t, cb.SetTree(make([]byte, 20)))
+	_, err = cb.Commit()
+	assert.EqualError(t, err, "gitobj: commit has no author")
+}
@@ -0,0 +1,57 @@
+package gitobj
+
+import (
+	"sort"
+)
+
+// Capability is an optional feature of Git's object storage which gitobj may
+// support, such that programs using it may check for the feature before relying
+// upon it, and report which are available when diagnosing problems (see:
+// Capabilities).
+type Capability string
+
+const (
+	// CapabilitySHA256 indicates that repositories whose objects are named
+	// by SHA-256 may be read and written (see: ObjectFormat).
+	CapabilitySHA256 Capability = "sha256"
+	// CapabilityMultiPackIndex indicates that objects are located using a
+	// repository's multi-pack-index, if it has one.
+	CapabilityMultiPackIndex Capability = "multi-pack-index"
+	// CapabilityBitmaps indicates that reachability bitmaps may be written
+	// and read (see: WriteBitmap and Reachable).
+	CapabilityBitmaps Capability = "bitmaps"
+	// CapabilityCommitGraph indicates that commits are read from a
+	// repository's commit-graph file, if it has one, rather than being
+	// inflated and parsed.
+	CapabilityCommitGraph Capability = "commit-graph"
+	// CapabilityTransitiveAlternates indicates that the alternates of a
+	// repository's alternates are searched in turn, to a limited depth
+	// (see: MaxAlternatesDepth).
+	CapabilityTransitiveAlternates Capability = "transitive-alternates"
+)
+
+// Capabilities returns the optional features supported by this build of
+// gitobj, in ascending order of their names.
+func Capabilities() []Capability {
+	caps := []Capability{
+		CapabilitySHA256,
+		CapabilityMultiPackIndex,
+		CapabilityBitmaps,
+		CapabilityCommitGraph,
+		CapabilityTransitiveAlternates,
+	}
+
+	sort.Slice(caps, func(i, j int) bool { return caps[i] < caps[j] })
+	return caps
+}
+
+// HasCapability returns whether this build of gitobj supports the given
+// optional feature (see: Capabilities).
+func HasCapability(c Capability) bool {
+	for _, have := range Capabilities() {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package gitobj
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	caps := Capabilities()
+
+	assert.True(t, sort.SliceIsSorted(caps, func(i, j int) bool { return caps[i] < caps[j] }))
+	for _, c := range []Capability{
+		CapabilitySHA256,
+		CapabilityMultiPackIndex,
+		CapabilityBitmaps,
+		CapabilityCommitGraph,
+		CapabilityTransitiveAlternates,
+	} {
+		assert.Contains(t, caps, c)
+		assert.True(t, HasCapability(c), "%s", c)
+	}
+	assert.False(t, HasCapability("partial-clone-v3"))
+}
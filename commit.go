@@ -16,7 +16,8 @@ import (
 // specifies a name, email, and time that the signature was created.
 //
 // NOTE: this type is _not_ used by the `*Commit` instance, as it does not
-// preserve cruft bytes. It is kept as a convenience type to test with.
+// preserve cruft bytes. Identities may instead be parsed into a Signature on
+// demand (see: ParseSignature, Commit.AuthorSignature).
 type Signature struct {
 	// Name is the first and last name of the individual holding this
 	// signature.
@@ -75,11 +76,21 @@ type Commit struct {
 	// TreeID is the root Tree associated with this commit.
 	TreeID []byte
 	// ExtraHeaders stores headers not listed above, for instance
-	// "encoding", "gpgsig", or "mergetag" (among others).
+	// "encoding", "gpgsig", or "mergetag" (among others), in the order in
+	// which they appear (see: ExtraHeaderValues, AddExtraHeader).
 	ExtraHeaders []*ExtraHeader
 	// Message is the commit message, including any signing information
 	// associated with this commit.
 	Message string
+
+	// unterminated indicates whether the decoded commit's message lacked
+	// a trailing newline (or the commit had an empty message), so that it
+	// is encoded again without one, and keeps its object ID (and any
+	// signature over it).
+	unterminated bool
+	// decodeStats describes how the commit was read, if it was read with
+	// RecordDecodeStats.
+	decodeStats *DecodeStats
 }
 
 // Type implements Object.ObjectType by returning the correct object type for
@@ -96,8 +107,10 @@ func (c *Commit) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err
 	var finishedHeaders bool
 	var messageParts []string
 
+	var unterminated bool
 	s := bufio.NewScanner(from)
 	s.Buffer(nil, 10*1024*1024)
+	s.Split(scanObjectLines(&unterminated))
 	for s.Scan() {
 		text := s.Text()
 		n = n + len(text+"\n")
@@ -169,6 +182,11 @@ func (c *Commit) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err
 	}
 
 	c.Message = strings.Join(messageParts, "\n")
+	if unterminated {
+		n--
+	}
+	c.unterminated = finishedHeaders &&
+		(len(messageParts) == 0 || unterminated)
 
 	if err = s.Err(); err != nil {
 		return n, fmt.Errorf("failed to parse commit buffer: %s", err)
@@ -176,6 +194,24 @@ func (c *Commit) Decode(hash hash.Hash, from io.Reader, size int64) (n int, err
 	return n, err
 }
 
+// scanObjectLines returns a bufio.SplitFunc which splits the lines of a commit
+// or tag on newlines alone, rather than as bufio.ScanLines does, which would
+// strip any carriage return preceding them, and which sets "unterminated" if
+// the last line lacks a newline, so that the object may be encoded again
+// exactly as it was decoded.
+func scanObjectLines(unterminated *bool) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			*unterminated = true
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
 // Encode encodes the commit's contents to the given io.Writer, "w". If there was
 // any error copying the commit's contents, that error will be returned.
 //
@@ -215,8 +251,13 @@ func (c *Commit) Encode(to io.Writer) (n int, err error) {
 	// c.Message is built from messageParts in the Decode() function.
 	//
 	// Since each entry in messageParts _does not_ contain its trailing LF,
-	// append an empty string to capture the final newline.
-	n4, err := fmt.Fprintf(to, "\n%s\n", c.Message)
+	// append an empty string to capture the final newline, unless the
+	// decoded message had none.
+	format := "\n%s\n"
+	if c.unterminated {
+		format = "\n%s"
+	}
+	n4, err := fmt.Fprintf(to, format, c.Message)
 	if err != nil {
 		return n, err
 	}
@@ -224,6 +265,38 @@ func (c *Commit) Encode(to io.Writer) (n int, err error) {
 	return n + n4, err
 }
 
+// ExtraHeaderValues returns the value of each of the commit's extra headers
+// with the given key, in the order in which they appear, or nil if it has
+// none. Multi-line values, such as those of "gpgsig" and "mergetag" headers,
+// are returned with their lines separated by newlines.
+func (c *Commit) ExtraHeaderValues(k string) []string {
+	var values []string
+	for _, hdr := range c.ExtraHeaders {
+		if hdr.K == k {
+			values = append(values, hdr.V)
+		}
+	}
+	return values
+}
+
+// AddExtraHeader adds an extra header, such as "encoding" or "mergetag", to the
+// commit, after any others. Its key may not contain spaces or newlines, nor be
+// that of one of the commit's own headers ("tree", "parent", "author", or
+// "committer"); its value may span several lines.
+//
+// Adding a header changes the commit's object ID, and so invalidates any
+// signature over it.
+func (c *Commit) AddExtraHeader(k, v string) error {
+	if len(k) == 0 || strings.ContainsAny(k, " \n") {
+		return fmt.Errorf("gitobj: invalid commit header %q", k)
+	}
+	if _, ok := commitHeaders[k]; ok {
+		return fmt.Errorf("gitobj: %q is not an extra header", k)
+	}
+	c.ExtraHeaders = append(c.ExtraHeaders, &ExtraHeader{K: k, V: v})
+	return nil
+}
+
 // Equal returns whether the receiving and given commits are equal, or in other
 // words, whether they are represented by the same SHA-1 when saved to the
 // object database.
@@ -260,6 +333,7 @@ func (c *Commit) Equal(other *Commit) bool {
 		return c.Author == other.Author &&
 			c.Committer == other.Committer &&
 			c.Message == other.Message &&
+			c.unterminated == other.unterminated &&
 			bytes.Equal(c.TreeID, other.TreeID)
 	}
 	return true
@@ -0,0 +1,641 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+const (
+	// commitGraphHeaderWidth is the width of the header of a commit-graph.
+	commitGraphHeaderWidth = 8
+	// commitGraphChunkEntryWidth is the width of each entry in the table
+	// of chunks following the header.
+	commitGraphChunkEntryWidth = 12
+	// commitGraphFanoutWidth is the width of the fanout table.
+	commitGraphFanoutWidth = 256 * 4
+
+	// commitGraphNoParent is the position recorded in place of a parent
+	// which a commit does not have.
+	commitGraphNoParent = 0x70000000
+	// commitGraphExtraEdges is set in the position of the second parent of
+	// an octopus merge, whose remaining bits give the position of its
+	// parents after the first in the table of extra edges. It is also set
+	// on the last of those.
+	commitGraphExtraEdges = 0x80000000
+)
+
+var (
+	// commitGraphHeader is the first four "magic" bytes of a commit-graph.
+	commitGraphHeader = []byte("CGPH")
+
+	// commitGraphChunkFanout, commitGraphChunkLookup,
+	// commitGraphChunkData, commitGraphChunkExtraEdges,
+	// commitGraphChunkBloomIndex, and commitGraphChunkBloomData are the
+	// identifiers of the chunks of a commit-graph which are understood by
+	// this package. Other chunks are ignored.
+	commitGraphChunkFanout     = []byte("OIDF")
+	commitGraphChunkLookup     = []byte("OIDL")
+	commitGraphChunkData       = []byte("CDAT")
+	commitGraphChunkExtraEdges = []byte("EDGE")
+	commitGraphChunkBloomIndex = []byte("BIDX")
+	commitGraphChunkBloomData  = []byte("BDAT")
+)
+
+// CommitInfo holds the metadata of a commit which is needed to traverse
+// history, which may be read from a commit-graph without decoding the commit
+// itself (see: ObjectDatabase.CommitInfo).
+type CommitInfo struct {
+	// TreeID is the object ID of the commit's root tree.
+	TreeID []byte
+	// ParentIDs are the object IDs of the commit's parents, in order.
+	ParentIDs [][]byte
+	// CommitTime is the time at which the commit was committed. Since a
+	// commit-graph does not record time zones, it is always given in the
+	// local time zone.
+	CommitTime time.Time
+	// Generation is the generation number (topological level) of the
+	// commit: one more than the greatest generation number of its parents,
+	// or one if it has none. It is zero if the commit is not present in a
+	// commit-graph.
+	Generation uint32
+}
+
+// CommitInfo returns the metadata of the commit named by "sha".
+//
+// If the database is backed by a filesystem whose objects directory holds a
+// commit-graph ("info/commit-graph", or a chain of them in
+// "info/commit-graphs"), and the commit is present in it, its metadata is read
+// from there, unless any objects are replaced (see: ReplaceObjects). Otherwise,
+// the commit is read and decoded as by Commit.
+//
+// If the CheckCommitGraph option is given, metadata read from a commit-graph is
+// checked against the commit, and a *CommitGraphMismatch returned if they
+// differ.
+func (o *ObjectDatabase) CommitInfo(sha []byte) (*CommitInfo, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	if o.graph != nil && len(o.replacements) == 0 {
+		info, err := o.graph.commit(sha)
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			if o.checkCommitGraph {
+				c, err := o.Commit(sha)
+				if err != nil {
+					return nil, err
+				}
+				if m := commitGraphMismatch(sha, info, c); m != nil {
+					return nil, m
+				}
+			}
+			return info, nil
+		}
+	}
+
+	c, err := o.Commit(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommitInfo{
+		TreeID:     c.TreeID,
+		ParentIDs:  c.ParentIDs,
+		CommitTime: time.Unix(committerTime(c), 0),
+	}, nil
+}
+
+// VerifyCommitGraph checks the metadata of every commit in the commit-graph
+// (see: CommitInfo) against the commits themselves, as "git commit-graph
+// verify" does, and returns each discrepancy found. The generation number of
+// each commit is checked against those of its parents. If there is no
+// commit-graph, nothing is checked.
+//
+// The commits themselves are checked, even if they have been replaced (see:
+// ReplaceObjects). An error is returned if any of them cannot be read.
+func (o *ObjectDatabase) VerifyCommitGraph() ([]*CommitGraphMismatch, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+	if o.graph == nil {
+		return nil, nil
+	}
+
+	var mismatches []*CommitGraphMismatch
+	for pos := uint32(0); pos < o.graph.baseCount+o.graph.count(); pos++ {
+		sha, err := o.graph.name(pos)
+		if err != nil {
+			return nil, err
+		}
+		info, err := o.graph.info(pos)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := o.openExact(context.Background(), sha)
+		if err != nil {
+			return nil, err
+		}
+		var c Commit
+		if err := o.decode(r, &c); err != nil {
+			return nil, err
+		}
+
+		if m := commitGraphMismatch(sha, info, &c); m != nil {
+			mismatches = append(mismatches, m)
+			continue
+		}
+
+		// Commit-graphs written by older versions of Git record a
+		// generation number of zero, which is not checked.
+		if info.Generation == 0 {
+			continue
+		}
+		var want uint32
+		for _, parent := range info.ParentIDs {
+			p, err := o.graph.commit(parent)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil && p.Generation > want {
+				want = p.Generation
+			}
+		}
+		if want+1 != info.Generation {
+			mismatches = append(mismatches, &CommitGraphMismatch{
+				Oid:    sha,
+				Field:  "generation",
+				Graph:  strconv.FormatUint(uint64(info.Generation), 10),
+				Commit: strconv.FormatUint(uint64(want+1), 10),
+			})
+		}
+	}
+	return mismatches, nil
+}
+
+// commitGraphMismatch returns a *CommitGraphMismatch describing the first
+// difference between the metadata "info" read from a commit-graph and the
+// commit "c" named by "sha", or nil if there is none. Generation numbers are not
+// compared, since they are not recorded in the commit.
+func commitGraphMismatch(sha []byte, info *CommitInfo, c *Commit) *CommitGraphMismatch {
+	mismatch := func(field, graph, commit string) *CommitGraphMismatch {
+		return &CommitGraphMismatch{Oid: sha, Field: field, Graph: graph, Commit: commit}
+	}
+
+	if !bytes.Equal(info.TreeID, c.TreeID) {
+		return mismatch("tree", hex.EncodeToString(info.TreeID), hex.EncodeToString(c.TreeID))
+	}
+
+	graph, commit := oidList(info.ParentIDs), oidList(c.ParentIDs)
+	if graph != commit {
+		return mismatch("parents", graph, commit)
+	}
+
+	// The commit time is recorded in 34 bits.
+	if when := committerTime(c) & (1<<34 - 1); info.CommitTime.Unix() != when {
+		return mismatch("commit time",
+			strconv.FormatInt(info.CommitTime.Unix(), 10), strconv.FormatInt(when, 10))
+	}
+	return nil
+}
+
+// oidList returns the given object IDs in hexadecimal, separated by commas, or
+// "(none)" if there are none.
+func oidList(oids [][]byte) string {
+	if len(oids) == 0 {
+		return "(none)"
+	}
+
+	names := make([]string, 0, len(oids))
+	for _, oid := range oids {
+		names = append(names, hex.EncodeToString(oid))
+	}
+	return strings.Join(names, ",")
+}
+
+// commitGraph is a single commit-graph file, which may be a layer in a chain
+// of them, in which case it refers to the layers on which it is based.
+type commitGraph struct {
+	// fanout is the fanout table of commit names in this layer, as in the
+	// fanout table of a packfile index.
+	fanout []uint32
+
+	// hashlen is the length of the object names in this commit-graph.
+	hashlen int64
+	// lookup, data, and edges are the positions at which the respective
+	// chunks begin. edges is zero if there is no table of extra edges.
+	lookup, data, edges int64
+
+	// bloomIndex and bloomData are the positions at which the index and
+	// data of the changed-path Bloom filters begin (see: bloomFilter),
+	// after the header of the latter. Both are zero if this layer has no
+	// Bloom filters, or they use a version of the hash function which is
+	// not understood.
+	bloomIndex, bloomData int64
+	// bloomHashes and bloomVersion are the number of hashes per key, and
+	// the version of the hash function, of this layer's Bloom filters.
+	bloomHashes, bloomVersion uint32
+
+	// base is the layer on which this one is based, or nil if there is
+	// none.
+	base *commitGraph
+	// baseCount is the number of commits in all of the layers on which
+	// this one is based. Positions below it refer to those layers.
+	baseCount uint32
+
+	// r is the underlying data of this layer.
+	r io.ReaderAt
+}
+
+// openCommitGraph opens the commit-graph in the given objects directory, if
+// there is one, preferring a single commit-graph file to a chain of them, as Git
+// does.
+//
+// If there is no usable commit-graph, nil is returned; as in Git, one which
+// cannot be read is ignored.
+func openCommitGraph(root string, algo hash.Hash) *commitGraph {
+	g, err := openCommitGraphFile(filepath.Join(root, "info", "commit-graph"), nil, algo)
+	if err == nil {
+		return g
+	}
+
+	g, err = openCommitGraphChain(filepath.Join(root, "info", "commit-graphs"), algo)
+	if err != nil {
+		return nil
+	}
+	return g
+}
+
+// openCommitGraphChain opens each layer of the chain of commit-graphs in the
+// given directory, and returns the topmost one.
+func openCommitGraphChain(dir string, algo hash.Hash) (*commitGraph, error) {
+	f, err := os.Open(filepath.Join(dir, "commit-graph-chain"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var g *commitGraph
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if len(name) == 0 {
+			continue
+		}
+
+		layer, err := openCommitGraphFile(filepath.Join(dir, fmt.Sprintf("graph-%s.graph", name)), g, algo)
+		if err != nil {
+			if g != nil {
+				g.Close()
+			}
+			return nil, err
+		}
+		g = layer
+	}
+
+	if err := scanner.Err(); err != nil {
+		if g != nil {
+			g.Close()
+		}
+		return nil, err
+	}
+	if g == nil {
+		return nil, fmt.Errorf("gitobj: empty commit-graph chain")
+	}
+	return g, nil
+}
+
+// openCommitGraphFile opens the commit-graph at the given path, based on the
+// given layer (if any).
+func openCommitGraphFile(path string, base *commitGraph, algo hash.Hash) (*commitGraph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := decodeCommitGraph(f, base, algo)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// decodeCommitGraph decodes a commit-graph whose underlying data is supplied by
+// "r", and which is based on the given layer (if any). It reads only its
+// header, table of chunks, and fanout table.
+func decodeCommitGraph(r io.ReaderAt, base *commitGraph, algo hash.Hash) (*commitGraph, error) {
+	var hdr [commitGraphHeaderWidth]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(hdr[:4], commitGraphHeader) {
+		return nil, fmt.Errorf("gitobj: invalid commit-graph header")
+	}
+	if hdr[4] != 1 {
+		return nil, fmt.Errorf("gitobj: unsupported commit-graph version: %d", hdr[4])
+	}
+
+	if version := pack.HashVersion(algo); hdr[5] != version {
+		return nil, fmt.Errorf("gitobj: commit-graph has hash version %d, expected %d", hdr[5], version)
+	}
+
+	var layers byte
+	for b := base; b != nil; b = b.base {
+		layers++
+	}
+	if hdr[7] != layers {
+		return nil, fmt.Errorf("gitobj: commit-graph is based on %d layer(s), expected %d", hdr[7], layers)
+	}
+
+	chunks := make([]byte, (int(hdr[6])+1)*commitGraphChunkEntryWidth)
+	if _, err := r.ReadAt(chunks, commitGraphHeaderWidth); err != nil {
+		return nil, err
+	}
+
+	g := &commitGraph{
+		hashlen: int64(algo.Size()),
+		base:    base,
+		r:       r,
+	}
+	if base != nil {
+		g.baseCount = base.baseCount + base.count()
+	}
+
+	var fanout []byte
+	for i := 0; i < int(hdr[6]); i++ {
+		entry := chunks[i*commitGraphChunkEntryWidth:]
+
+		id := entry[:4]
+		start := int64(binary.BigEndian.Uint64(entry[4:]))
+
+		switch {
+		case bytes.Equal(id, commitGraphChunkFanout):
+			fanout = make([]byte, commitGraphFanoutWidth)
+			if _, err := r.ReadAt(fanout, start); err != nil {
+				return nil, err
+			}
+		case bytes.Equal(id, commitGraphChunkLookup):
+			g.lookup = start
+		case bytes.Equal(id, commitGraphChunkData):
+			g.data = start
+		case bytes.Equal(id, commitGraphChunkExtraEdges):
+			g.edges = start
+		case bytes.Equal(id, commitGraphChunkBloomIndex):
+			g.bloomIndex = start
+		case bytes.Equal(id, commitGraphChunkBloomData):
+			var hdr [bloomDataHeaderWidth]byte
+			if _, err := r.ReadAt(hdr[:], start); err != nil {
+				return nil, err
+			}
+			g.bloomVersion = binary.BigEndian.Uint32(hdr[0:])
+			g.bloomHashes = binary.BigEndian.Uint32(hdr[4:])
+			g.bloomData = start + bloomDataHeaderWidth
+		}
+	}
+	if g.bloomIndex == 0 || g.bloomData == 0 ||
+		(g.bloomVersion != 1 && g.bloomVersion != 2) {
+		g.bloomIndex, g.bloomData = 0, 0
+	}
+
+	if fanout == nil || g.lookup == 0 || g.data == 0 {
+		return nil, fmt.Errorf("gitobj: commit-graph is missing a required chunk")
+	}
+
+	g.fanout = make([]uint32, 256)
+	for i := range g.fanout {
+		g.fanout[i] = binary.BigEndian.Uint32(fanout[i*4:])
+		if i > 0 && g.fanout[i] < g.fanout[i-1] {
+			return nil, fmt.Errorf("gitobj: commit-graph has non-monotonic fanout table at entry %d", i)
+		}
+	}
+	return g, nil
+}
+
+// count returns the number of commits in this layer.
+func (g *commitGraph) count() uint32 {
+	return g.fanout[255]
+}
+
+// Close closes this layer, and each layer on which it is based.
+func (g *commitGraph) Close() error {
+	var err error
+	if close, ok := g.r.(io.Closer); ok {
+		err = close.Close()
+	}
+	if g.base != nil {
+		if berr := g.base.Close(); err == nil {
+			err = berr
+		}
+	}
+	return err
+}
+
+// commit returns the metadata of the commit named by "sha", or nil if it is not
+// present in the commit-graph.
+func (g *commitGraph) commit(sha []byte) (*CommitInfo, error) {
+	pos, ok, err := g.position(sha)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return g.info(pos)
+}
+
+// position returns the position of the commit named by "sha" in this layer or
+// any on which it is based, and whether it was found.
+func (g *commitGraph) position(sha []byte) (uint32, bool, error) {
+	if int64(len(sha)) != g.hashlen {
+		return 0, false, nil
+	}
+
+	var left, right int64
+	if sha[0] > 0 {
+		left = int64(g.fanout[sha[0]-1])
+	}
+	right = int64(g.fanout[sha[0]])
+
+	got := make([]byte, g.hashlen)
+	for left < right {
+		mid := left + ((right - left) / 2)
+
+		if _, err := g.r.ReadAt(got, g.lookup+(mid*g.hashlen)); err != nil {
+			return 0, false, err
+		}
+
+		if cmp := bytes.Compare(sha, got); cmp == 0 {
+			return g.baseCount + uint32(mid), true, nil
+		} else if cmp < 0 {
+			right = mid
+		} else {
+			left = mid + 1
+		}
+	}
+
+	if g.base != nil {
+		return g.base.position(sha)
+	}
+	return 0, false, nil
+}
+
+// layer returns the layer holding the commit at the given position.
+func (g *commitGraph) layer(pos uint32) (*commitGraph, error) {
+	for l := g; l != nil; l = l.base {
+		if pos >= l.baseCount {
+			if pos-l.baseCount >= l.count() {
+				break
+			}
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("gitobj: commit-graph position %d out of range", pos)
+}
+
+// name returns the object ID of the commit at the given position.
+func (g *commitGraph) name(pos uint32) ([]byte, error) {
+	l, err := g.layer(pos)
+	if err != nil {
+		return nil, err
+	}
+
+	sha := make([]byte, l.hashlen)
+	if _, err := l.r.ReadAt(sha, l.lookup+int64(pos-l.baseCount)*l.hashlen); err != nil {
+		return nil, err
+	}
+	return sha, nil
+}
+
+// info returns the metadata of the commit at the given position.
+func (g *commitGraph) info(pos uint32) (*CommitInfo, error) {
+	l, err := g.layer(pos)
+	if err != nil {
+		return nil, err
+	}
+
+	width := l.hashlen + 16
+	data := make([]byte, width)
+	if _, err := l.r.ReadAt(data, l.data+int64(pos-l.baseCount)*width); err != nil {
+		return nil, err
+	}
+
+	info := &CommitInfo{TreeID: data[:l.hashlen]}
+
+	parents := data[l.hashlen:]
+	for _, p := range []uint32{
+		binary.BigEndian.Uint32(parents[0:]),
+		binary.BigEndian.Uint32(parents[4:]),
+	} {
+		if p == commitGraphNoParent {
+			break
+		}
+		if p&commitGraphExtraEdges != 0 {
+			extra, err := l.extraParents(p &^ commitGraphExtraEdges)
+			if err != nil {
+				return nil, err
+			}
+			info.ParentIDs = append(info.ParentIDs, extra...)
+			break
+		}
+
+		parent, err := g.name(p)
+		if err != nil {
+			return nil, err
+		}
+		info.ParentIDs = append(info.ParentIDs, parent)
+	}
+
+	hi := binary.BigEndian.Uint32(parents[8:])
+	lo := binary.BigEndian.Uint32(parents[12:])
+
+	info.Generation = hi >> 2
+	info.CommitTime = time.Unix(int64(hi&0x3)<<32|int64(lo), 0)
+
+	return info, nil
+}
+
+// bloomFilter returns the changed-path Bloom filter of the commit at the given
+// position, or nil if the layer holding it has none.
+func (g *commitGraph) bloomFilter(pos uint32) (*bloomFilter, error) {
+	l, err := g.layer(pos)
+	if err != nil {
+		return nil, err
+	}
+	if l.bloomIndex == 0 {
+		return nil, nil
+	}
+
+	// Each entry of the index gives the offset at which the filter of
+	// the corresponding commit ends, and so that at which the next begins.
+	i := int64(pos - l.baseCount)
+
+	var buf [8]byte
+	var start, end uint32
+	if i == 0 {
+		if _, err := l.r.ReadAt(buf[4:], l.bloomIndex); err != nil {
+			return nil, err
+		}
+		end = binary.BigEndian.Uint32(buf[4:])
+	} else {
+		if _, err := l.r.ReadAt(buf[:], l.bloomIndex+(i-1)*4); err != nil {
+			return nil, err
+		}
+		start = binary.BigEndian.Uint32(buf[0:])
+		end = binary.BigEndian.Uint32(buf[4:])
+	}
+	if end < start {
+		return nil, fmt.Errorf("gitobj: commit-graph has invalid Bloom filter index at position %d", pos)
+	}
+
+	data := make([]byte, end-start)
+	if _, err := l.r.ReadAt(data, l.bloomData+int64(start)); err != nil {
+		return nil, err
+	}
+	return &bloomFilter{
+		data:    data,
+		hashes:  l.bloomHashes,
+		version: l.bloomVersion,
+	}, nil
+}
+
+// extraParents returns the object IDs of the parents of an octopus merge after
+// the first, which are listed in the table of extra edges of this layer
+// beginning at the given index.
+func (g *commitGraph) extraParents(at uint32) ([][]byte, error) {
+	if g.edges == 0 {
+		return nil, fmt.Errorf("gitobj: commit-graph is missing extra edges")
+	}
+
+	var parents [][]byte
+	for buf := make([]byte, 4); ; at++ {
+		if _, err := g.r.ReadAt(buf, g.edges+int64(at)*4); err != nil {
+			return nil, err
+		}
+		p := binary.BigEndian.Uint32(buf)
+
+		parent, err := g.name(p &^ commitGraphExtraEdges)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, parent)
+
+		if p&commitGraphExtraEdges != 0 {
+			return parents, nil
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package gitobj
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitInfoReadsFromCommitGraph(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-commit-graph")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "a"}, 1000)
+	x := writeTestCommit(t, db, map[string]string{"a.txt": "x"}, 2000, base)
+	y := writeTestCommit(t, db, map[string]string{"a.txt": "y"}, 3000, base)
+	z := writeTestCommit(t, db, map[string]string{"a.txt": "z"}, 4000, x)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "m"}, 5000, z, x, y)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "info"), 0755))
+	writeTestCommitGraph(t, filepath.Join(root, "info", "commit-graph"), db,
+		nil, base, x, y, z, merge)
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+	require.NotNil(t, db.graph)
+
+	info, err := db.CommitInfo(merge)
+	require.NoError(t, err)
+
+	commit, err := db.Commit(merge)
+	require.NoError(t, err)
+
+	assert.Equal(t, commit.TreeID, info.TreeID)
+	assert.Equal(t, [][]byte{z, x, y}, info.ParentIDs)
+	assert.EqualValues(t, 5000, info.CommitTime.Unix())
+	assert.EqualValues(t, 4, info.Generation)
+
+	info, err = db.CommitInfo(base)
+	require.NoError(t, err)
+
+	assert.Empty(t, info.ParentIDs)
+	assert.EqualValues(t, 1, info.Generation)
+}
+
+func TestCommitInfoReadsFromCommitGraphChains(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-commit-graph")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	a := writeTestCommit(t, db, map[string]string{"a.txt": "a"}, 1000)
+	b := writeTestCommit(t, db, map[string]string{"a.txt": "b"}, 2000, a)
+	c := writeTestCommit(t, db, map[string]string{"a.txt": "c"}, 3000, b)
+	d := writeTestCommit(t, db, map[string]string{"a.txt": "d"}, 4000, c, a)
+	unknown := writeTestCommit(t, db, map[string]string{"a.txt": "e"}, 5000, d)
+
+	dir := filepath.Join(root, "info", "commit-graphs")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	lower := sortedNames(a, b)
+	writeTestCommitGraph(t, filepath.Join(dir, "graph-1111.graph"), db, nil, lower...)
+	writeTestCommitGraph(t, filepath.Join(dir, "graph-2222.graph"), db, lower, c, d)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "commit-graph-chain"),
+		[]byte("1111\n2222\n"), 0644))
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+	require.NotNil(t, db.graph)
+
+	info, err := db.CommitInfo(d)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{c, a}, info.ParentIDs)
+	assert.EqualValues(t, 4, info.Generation)
+
+	info, err = db.CommitInfo(b)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{a}, info.ParentIDs)
+	assert.EqualValues(t, 2, info.Generation)
+
+	info, err = db.CommitInfo(unknown)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{d}, info.ParentIDs)
+	assert.EqualValues(t, 5000, info.CommitTime.Unix())
+	assert.EqualValues(t, 0, info.Generation)
+}
+
+func TestCommitInfoWithoutACommitGraph(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	parent := writeTestCommit(t, db, map[string]string{"a.txt": "a"}, 1000)
+	sha := writeTestCommit(t, db, map[string]string{"a.txt": "b"}, 2000, parent)
+
+	info, err := db.CommitInfo(sha)
+	require.NoError(t, err)
+
+	commit, err := db.Commit(sha)
+	require.NoError(t, err)
+
+	assert.Equal(t, commit.TreeID, info.TreeID)
+	assert.Equal(t, [][]byte{parent}, info.ParentIDs)
+	assert.EqualValues(t, 2000, info.CommitTime.Unix())
+	assert.EqualValues(t, 0, info.Generation)
+}
+
+func TestCommitGraphRejectsOtherHashes(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	sha := writeTestCommit(t, db, map[string]string{"a.txt": "a"}, 1000)
+
+	dir, err := ioutil.TempDir("", "gitobj-commit-graph")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "commit-graph")
+	writeTestCommitGraph(t, path, db, nil, sha)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	_, err = decodeCommitGraph(bytes.NewReader(data), nil, hasher(ObjectFormatSHA256))
+	assert.EqualError(t, err, "gitobj: commit-graph has hash version 1, expected 2")
+}
+
+func TestVerifyCommitGraphReportsStaleCommits(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-commit-graph")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "a"}, 1000)
+	x := writeTestCommit(t, db, map[string]string{"a.txt": "x"}, 2000, base)
+	y := writeTestCommit(t, db, map[string]string{"a.txt": "y"}, 3000, x)
+
+	path := filepath.Join(root, "info", "commit-graph")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	writeTestCommitGraph(t, path, db, nil, base, x, y)
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+
+	mismatches, err := db.VerifyCommitGraph()
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	// Record the tree of "y" in place of that of "x".
+	cx, err := db.Commit(x)
+	require.NoError(t, err)
+	cy, err := db.Commit(y)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	i := bytes.Index(data, cx.TreeID)
+	require.True(t, i >= 0)
+	copy(data[i:], cy.TreeID)
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+
+	mismatches, err = db.VerifyCommitGraph()
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, x, mismatches[0].Oid)
+	assert.Equal(t, "tree", mismatches[0].Field)
+	assert.Equal(t, hex.EncodeToString(cy.TreeID), mismatches[0].Graph)
+	assert.Equal(t, hex.EncodeToString(cx.TreeID), mismatches[0].Commit)
+
+	// The stale commit-graph is trusted by default.
+	info, err := db.CommitInfo(x)
+	require.NoError(t, err)
+	assert.Equal(t, cy.TreeID, info.TreeID)
+
+	checked, err := FromFilesystem(root, "", CheckCommitGraph())
+	require.NoError(t, err)
+	defer checked.Close()
+
+	_, err = checked.CommitInfo(x)
+	assert.EqualError(t, err, fmt.Sprintf(
+		"gitobj: commit-graph has tree %x for commit %x, expected %x",
+		cy.TreeID, x, cx.TreeID))
+
+	info, err = checked.CommitInfo(y)
+	require.NoError(t, err)
+	assert.Equal(t, cy.TreeID, info.TreeID)
+	assert.EqualValues(t, 3, info.Generation)
+}
+
+// writeTestCommitGraph writes a commit-graph holding the given commits, which
+// are read from "db", to "path". If "base" is non-empty, the commit-graph is
+// written as a layer based on a single other layer holding those commits, in
+// order.
+func writeTestCommitGraph(t *testing.T, path string, db *ObjectDatabase, base [][]byte, shas ...[]byte) {
+	writeTestCommitGraphWithBloomFilters(t, path, db, base, nil, shas...)
+}
+
+// writeTestCommitGraphWithBloomFilters is as writeTestCommitGraph, but also
+// writes the given changed-path Bloom filters, keyed by the name of each
+// commit, if there are any. Commits without a filter are given an empty one.
+func writeTestCommitGraphWithBloomFilters(t *testing.T, path string, db *ObjectDatabase, base [][]byte, filters map[string]*bloomFilter, shas ...[]byte) {
+	shas = sortedNames(shas...)
+
+	positions := make(map[string]uint32)
+	for i, sha := range append(append([][]byte{}, base...), shas...) {
+		positions[string(sha)] = uint32(i)
+	}
+
+	generations := make(map[string]uint32)
+	var generation func(sha []byte) uint32
+	generation = func(sha []byte) uint32 {
+		if gen, ok := generations[string(sha)]; ok {
+			return gen
+		}
+
+		commit, err := db.Commit(sha)
+		require.NoError(t, err)
+
+		var gen uint32
+		for _, parent := range commit.ParentIDs {
+			if g := generation(parent); g > gen {
+				gen = g
+			}
+		}
+		generations[string(sha)] = gen + 1
+		return gen + 1
+	}
+
+	var fanout, lookup, data, edges, bloomIndex, bloomData bytes.Buffer
+	binary.Write(&bloomData, binary.BigEndian, []uint32{2, 7, 10})
+
+	var counts [256]uint32
+	for _, sha := range shas {
+		for i := int(sha[0]); i < len(counts); i++ {
+			counts[i]++
+		}
+		lookup.Write(sha)
+
+		commit, err := db.Commit(sha)
+		require.NoError(t, err)
+
+		data.Write(commit.TreeID)
+
+		parents := []uint32{commitGraphNoParent, commitGraphNoParent}
+		for i, parent := range commit.ParentIDs {
+			pos, ok := positions[string(parent)]
+			require.True(t, ok)
+
+			if i == 0 {
+				parents[0] = pos
+			} else if len(commit.ParentIDs) == 2 {
+				parents[1] = pos
+			} else {
+				if i == 1 {
+					parents[1] = uint32(edges.Len()/4) | commitGraphExtraEdges
+				}
+				if i == len(commit.ParentIDs)-1 {
+					pos |= commitGraphExtraEdges
+				}
+				binary.Write(&edges, binary.BigEndian, pos)
+			}
+		}
+		binary.Write(&data, binary.BigEndian, parents)
+
+		when := uint64(committerTime(commit))
+		binary.Write(&data, binary.BigEndian, generation(sha)<<2|uint32(when>>32))
+		binary.Write(&data, binary.BigEndian, uint32(when))
+
+		if f, ok := filters[string(sha)]; ok {
+			bloomData.Write(f.data)
+		}
+		binary.Write(&bloomIndex, binary.BigEndian, uint32(bloomData.Len()-bloomDataHeaderWidth))
+	}
+	binary.Write(&fanout, binary.BigEndian, counts[:])
+
+	chunks := []struct {
+		id   []byte
+		data []byte
+	}{
+		{commitGraphChunkFanout, fanout.Bytes()},
+		{commitGraphChunkLookup, lookup.Bytes()},
+		{commitGraphChunkData, data.Bytes()},
+		{commitGraphChunkExtraEdges, edges.Bytes()},
+	}
+	if len(filters) > 0 {
+		chunks = append(chunks, []struct {
+			id   []byte
+			data []byte
+		}{
+			{commitGraphChunkBloomIndex, bloomIndex.Bytes()},
+			{commitGraphChunkBloomData, bloomData.Bytes()},
+		}...)
+	}
+
+	var layers byte
+	if len(base) > 0 {
+		layers = 1
+	}
+
+	var buf bytes.Buffer
+	buf.Write(commitGraphHeader)
+	buf.Write([]byte{1, 1, byte(len(chunks)), layers})
+
+	offset := uint64(commitGraphHeaderWidth + (len(chunks)+1)*commitGraphChunkEntryWidth)
+	for _, c := range chunks {
+		buf.Write(c.id)
+		binary.Write(&buf, binary.BigEndian, offset)
+		offset += uint64(len(c.data))
+	}
+	buf.Write([]byte{0, 0, 0, 0})
+	binary.Write(&buf, binary.BigEndian, offset)
+
+	for _, c := range chunks {
+		buf.Write(c.data)
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+}
+
+// sortedNames returns the given object IDs in ascending order.
+func sortedNames(shas ...[]byte) [][]byte {
+	sorted := append([][]byte{}, shas...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	return sorted
+}
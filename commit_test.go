@@ -469,3 +469,71 @@ initial commit`
 	_, err := c.Decode(sha1.New(), strings.NewReader(cc), int64(len(cc)))
 	assert.NoError(t, err)
 }
+
+func TestCommitDecodingRoundTripsExactly(t *testing.T) {
+	headers := "tree 2aedfd35087c75d17bdbaf4dd56069d44fc75b71\n" +
+		"parent 75158117eb8efe60453f8c077527ac3530c81e38\n" +
+		"author Jane Doe <jane@example.com> 1503956287 -0400\n" +
+		"committer Jane Doe <jane@example.com> 1503956287 -0400\n" +
+		"encoding ISO-8859-1\n" +
+		"mergetag object 75158117eb8efe60453f8c077527ac3530c81e38\n" +
+		" type commit\n" +
+		" tag v1.0\n" +
+		" \n" +
+		" v1.0\n" +
+		"HG:extra rebase_source:c3c4b8\r\n"
+
+	for desc, cc := range map[string]string{
+		"message":              headers + "\ninitial commit\n",
+		"carriage returns":     headers + "\ninitial commit\r\n\r\nbody\r\n",
+		"unterminated message": headers + "\ninitial commit",
+		"empty message":        headers + "\n",
+		"blank message":        headers + "\n\n",
+	} {
+		var c Commit
+		n, err := c.Decode(sha1.New(), strings.NewReader(cc), int64(len(cc)))
+		require.NoError(t, err, desc)
+		assert.Equal(t, len(cc), n, desc)
+
+		var buf bytes.Buffer
+		_, err = c.Encode(&buf)
+		require.NoError(t, err, desc)
+		assert.Equal(t, cc, buf.String(), desc)
+	}
+}
+
+func TestCommitExtraHeaderValues(t *testing.T) {
+	cc := "tree 2aedfd35087c75d17bdbaf4dd56069d44fc75b71\n" +
+		"author Jane Doe <jane@example.com> 1503956287 -0400\n" +
+		"committer Jane Doe <jane@example.com> 1503956287 -0400\n" +
+		"mergetag object a\n" +
+		" type commit\n" +
+		"encoding UTF-8\n" +
+		"mergetag object b\n" +
+		"\ninitial commit\n"
+
+	var c Commit
+	_, err := c.Decode(sha1.New(), strings.NewReader(cc), int64(len(cc)))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"object a\ntype commit", "object b"},
+		c.ExtraHeaderValues("mergetag"))
+	assert.Equal(t, []string{"UTF-8"}, c.ExtraHeaderValues("encoding"))
+	assert.Nil(t, c.ExtraHeaderValues("gpgsig"))
+}
+
+func TestCommitAddExtraHeader(t *testing.T) {
+	var c Commit
+	require.NoError(t, c.AddExtraHeader("encoding", "UTF-8"))
+	require.NoError(t, c.AddExtraHeader("HG:extra", "branch:default"))
+
+	assert.Equal(t, []*ExtraHeader{
+		{K: "encoding", V: "UTF-8"},
+		{K: "HG:extra", V: "branch:default"},
+	}, c.ExtraHeaders)
+
+	assert.EqualError(t, c.AddExtraHeader("", "x"), `gitobj: invalid commit header ""`)
+	assert.EqualError(t, c.AddExtraHeader("a b", "x"), `gitobj: invalid commit header "a b"`)
+	assert.EqualError(t, c.AddExtraHeader("parent", "x"), `gitobj: "parent" is not an extra header`)
+	assert.Len(t, c.ExtraHeaders, 2)
+}
@@ -0,0 +1,588 @@
+package gitobj
+
+import (
+	"bytes"
+	"container/heap"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultWalkSlop is the default number of commits older than the
+	// cutoff given by WalkSince whose parents are still followed, in order
+	// to tolerate clock skew. It matches the value used by Git.
+	DefaultWalkSlop = 5
+)
+
+// CommitWalkFunc is the type of the function called by WalkCommits for each
+// commit visited. The "sha" argument is the object ID of the commit.
+//
+// If the function returns StopWalk, WalkCommits stops visiting commits and
+// returns nil. Any other non-nil error stops the walk, and is returned by
+// WalkCommits.
+type CommitWalkFunc func(sha []byte, commit *Commit) error
+
+// CommitWalkOption is a function which configures a walk performed by
+// WalkCommits.
+type CommitWalkOption func(*commitWalkOptions)
+
+type commitWalkOptions struct {
+	paths       []string
+	firstParent bool
+	excluded    [][]byte
+	topo        bool
+
+	since, until *time.Time
+	slop         int
+}
+
+// WalkPaths is a CommitWalkOption which limits the walk to commits that modify
+// any of the given paths (or, in the case of directories, anything beneath
+// them).
+//
+// History is simplified in the same way as Git's default mode: if a commit is
+// identical to any of its parents with respect to the given paths, only that
+// parent is followed, and the commit itself is not visited.
+//
+// Where a commit-graph holds changed-path Bloom filters (as written by "git
+// commit-graph write --changed-paths"), commits which they show to leave the
+// given paths unchanged since their first parent are simplified without
+// comparing their trees.
+func WalkPaths(paths ...string) CommitWalkOption {
+	return func(args *commitWalkOptions) {
+		for _, path := range paths {
+			if path = strings.Trim(path, "/"); len(path) > 0 {
+				args.paths = append(args.paths, path)
+			}
+		}
+	}
+}
+
+// WalkFirstParent is a CommitWalkOption which follows only the first parent of
+// each commit, ignoring the history brought in by merges.
+func WalkFirstParent() CommitWalkOption {
+	return func(args *commitWalkOptions) {
+		args.firstParent = true
+	}
+}
+
+// WalkExcluding is a CommitWalkOption which excludes the given commits, and
+// every commit reachable from them, from the walk, as with "^<commit>" in
+// git-rev-list(1). The walk ends once every commit remaining to be visited is
+// known to be excluded.
+//
+// Excluded commits are never passed to the CommitWalkFunc, so where a
+// commit-graph is present, their parents and dates are read from it (see:
+// CommitInfo), rather than from the commits themselves.
+func WalkExcluding(shas ...[]byte) CommitWalkOption {
+	return func(args *commitWalkOptions) {
+		args.excluded = append(args.excluded, shas...)
+	}
+}
+
+// WalkTopoOrder is a CommitWalkOption which visits commits in topological
+// order, as with "git rev-list --topo-order": no commit is visited before all
+// of its children (among the commits walked) have been, and the commits along
+// each line of history are visited together, rather than interleaved by date.
+//
+// Since no commit can be visited until all of its children are known, the
+// walk is completed before the first commit is visited.
+func WalkTopoOrder() CommitWalkOption {
+	return func(args *commitWalkOptions) {
+		args.topo = true
+	}
+}
+
+// WalkSince is a CommitWalkOption which limits the walk to commits whose
+// committer date is no earlier than "since".
+//
+// Since history is walked in descending order of committer date, the walk
+// ends shortly after the first commit older than "since" is reached. To
+// tolerate clock skew, parents of the first few such commits (see: WalkSlop)
+// are still followed, in case they lead back into the requested range.
+func WalkSince(since time.Time) CommitWalkOption {
+	return func(args *commitWalkOptions) {
+		args.since = &since
+	}
+}
+
+// WalkUntil is a CommitWalkOption which limits the walk to commits whose
+// committer date is no later than "until". Newer commits are not visited, but
+// their history is still walked.
+func WalkUntil(until time.Time) CommitWalkOption {
+	return func(args *commitWalkOptions) {
+		args.until = &until
+	}
+}
+
+// WalkSlop is a CommitWalkOption which sets the number of consecutive commits
+// older than the cutoff given by WalkSince whose parents are followed before
+// the walk is pruned. If not given, it defaults to DefaultWalkSlop.
+func WalkSlop(n int) CommitWalkOption {
+	return func(args *commitWalkOptions) {
+		args.slop = n
+	}
+}
+
+// WalkCommits walks the history reachable from each of the commits named in
+// "tips", calling "fn" for each commit visited. Commits are visited at most
+// once, in descending order of their committer date, unless the WalkTopoOrder
+// option is given.
+func (o *ObjectDatabase) WalkCommits(tips [][]byte, fn CommitWalkFunc, setters ...CommitWalkOption) error {
+	args := &commitWalkOptions{slop: DefaultWalkSlop}
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	w := &commitWalker{
+		db:    o,
+		args:  args,
+		nodes: make(map[string]*commitQueueItem),
+		slop:  args.slop,
+	}
+
+	for _, sha := range args.excluded {
+		if err := w.push(sha, nil, true); err != nil {
+			return err
+		}
+	}
+	for _, tip := range tips {
+		if err := w.push(tip, nil, false); err != nil {
+			return err
+		}
+	}
+
+	err := w.walk(fn)
+	if err == nil && args.topo {
+		err = w.visitTopo(fn)
+	}
+	if err == StopWalk {
+		return nil
+	}
+	return err
+}
+
+// commitWalker holds the state of a single call to WalkCommits.
+type commitWalker struct {
+	db   *ObjectDatabase
+	args *commitWalkOptions
+
+	// queue holds the commits which are yet to be visited.
+	queue commitQueue
+	// nodes holds each commit which has been queued, by object ID.
+	nodes map[string]*commitQueueItem
+	// interesting is the number of commits in the queue which are not
+	// excluded.
+	interesting int
+	// seq is the number of commits which have been queued.
+	seq int
+	// slop is the number of further commits older than the cutoff given by
+	// WalkSince whose parents may be followed.
+	slop int
+
+	// walked holds, in the order in which they were walked, each commit
+	// which was not excluded when it was taken from the queue. It is only
+	// recorded when visiting commits in topological order.
+	walked []*commitQueueItem
+}
+
+// push queues the commit named by "sha" to be visited, unless it has been
+// queued before, in which case it is only marked as excluded if "excluded" is
+// true. If "commit" is nil, it is loaded from the database.
+func (w *commitWalker) push(sha []byte, commit *Commit, excluded bool) error {
+	if item, ok := w.nodes[string(sha)]; ok {
+		if excluded {
+			return w.exclude(item)
+		}
+		return nil
+	}
+
+	item := &commitQueueItem{
+		sha:      sha,
+		commit:   commit,
+		excluded: excluded,
+		seq:      w.seq,
+	}
+
+	switch {
+	case commit != nil:
+	case excluded:
+		// Excluded commits are never visited, so only their parents
+		// and dates are needed.
+		info, err := w.db.CommitInfo(sha)
+		if err != nil {
+			return err
+		}
+		item.parentIDs, item.when = info.ParentIDs, info.CommitTime.Unix()
+	default:
+		var err error
+		if commit, err = w.db.Commit(sha); err != nil {
+			return err
+		}
+		item.commit = commit
+	}
+
+	if commit != nil {
+		item.parentIDs, item.when = commit.ParentIDs, committerTime(commit)
+	}
+
+	w.nodes[string(sha)] = item
+	if !excluded {
+		w.interesting++
+	}
+
+	heap.Push(&w.queue, item)
+	w.seq++
+
+	return nil
+}
+
+// exclude marks the given commit, and every commit reachable from it, as
+// excluded from the walk. The parents of commits which have already been taken
+// from the queue are marked immediately; those of commits which are yet to be
+// taken are marked when they are.
+func (w *commitWalker) exclude(item *commitQueueItem) error {
+	stack := []*commitQueueItem{item}
+	for len(stack) > 0 {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if item.excluded {
+			continue
+		}
+		item.excluded = true
+
+		if !item.popped {
+			w.interesting--
+			continue
+		}
+
+		for _, sha := range item.parentIDs {
+			if parent, ok := w.nodes[string(sha)]; ok {
+				stack = append(stack, parent)
+			} else if err := w.push(sha, nil, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walk visits each queued commit in turn, queueing its parents as it goes. If
+// commits are to be visited in topological order, they are instead recorded in
+// "w.walked", to be visited by visitTopo.
+func (w *commitWalker) walk(fn CommitWalkFunc) error {
+	for w.queue.Len() > 0 {
+		if len(w.args.excluded) > 0 && w.interesting == 0 {
+			// Everything left to walk is excluded.
+			return nil
+		}
+
+		item := heap.Pop(&w.queue).(*commitQueueItem)
+		item.popped = true
+
+		if item.excluded {
+			for _, sha := range item.parentIDs {
+				if err := w.push(sha, nil, true); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		w.interesting--
+
+		if w.args.since != nil && item.when < w.args.since.Unix() {
+			// The commit is too old to be visited. Prune its
+			// history, unless we can still afford to look beyond
+			// it for newer commits.
+			if w.slop <= 0 {
+				continue
+			}
+			w.slop--
+		} else {
+			w.slop = w.args.slop
+		}
+
+		parents, visit, err := w.simplify(item.sha, item.commit)
+		if err != nil {
+			return err
+		}
+		if !w.inRange(item.when) {
+			visit = false
+		}
+
+		if w.args.topo {
+			item.visit = visit
+			for _, parent := range parents {
+				item.followed = append(item.followed, parent.sha)
+			}
+			w.walked = append(w.walked, item)
+		} else if visit {
+			if err := fn(item.sha, item.commit); err != nil {
+				return err
+			}
+		}
+
+		for _, parent := range parents {
+			if err := w.push(parent.sha, parent.commit, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// visitTopo visits each walked commit which was not later excluded, in
+// topological order.
+func (w *commitWalker) visitTopo(fn CommitWalkFunc) error {
+	walked := make(map[string]*commitQueueItem, len(w.walked))
+	for _, item := range w.walked {
+		walked[string(item.sha)] = item
+	}
+
+	// children counts the walked children of each walked commit which are
+	// yet to be visited.
+	children := make(map[string]int, len(w.walked))
+	for _, item := range w.walked {
+		for _, sha := range item.followed {
+			if _, ok := walked[string(sha)]; ok {
+				children[string(sha)]++
+			}
+		}
+	}
+
+	// Commits are visited depth-first from the most recent commit without
+	// children, so that each line of history is visited in turn.
+	var ready []*commitQueueItem
+	for i := len(w.walked) - 1; i >= 0; i-- {
+		if children[string(w.walked[i].sha)] == 0 {
+			ready = append(ready, w.walked[i])
+		}
+	}
+
+	for len(ready) > 0 {
+		item := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+
+		if item.visit && !item.excluded {
+			if err := fn(item.sha, item.commit); err != nil {
+				return err
+			}
+		}
+
+		for i := len(item.followed) - 1; i >= 0; i-- {
+			sha := string(item.followed[i])
+			parent, ok := walked[sha]
+			if !ok {
+				continue
+			}
+
+			if children[sha]--; children[sha] == 0 {
+				ready = append(ready, parent)
+			}
+		}
+	}
+	return nil
+}
+
+// inRange returns whether a commit with the given committer date falls within
+// the bounds given by WalkSince and WalkUntil.
+func (w *commitWalker) inRange(when int64) bool {
+	if w.args.since != nil && when < w.args.since.Unix() {
+		return false
+	}
+	if w.args.until != nil && when > w.args.until.Unix() {
+		return false
+	}
+	return true
+}
+
+// commitParent is a parent of a commit, which may or may not have been loaded.
+type commitParent struct {
+	sha    []byte
+	commit *Commit
+}
+
+// simplify returns the parents of "commit", named by "sha", which should be
+// followed, and whether or not "commit" should itself be visited.
+func (w *commitWalker) simplify(sha []byte, commit *Commit) ([]*commitParent, bool, error) {
+	parents := make([]*commitParent, 0, len(commit.ParentIDs))
+	for _, sha := range commit.ParentIDs {
+		parents = append(parents, &commitParent{sha: sha})
+
+		if w.args.firstParent {
+			break
+		}
+	}
+
+	if len(w.args.paths) == 0 {
+		return parents, true, nil
+	}
+
+	if len(parents) == 0 {
+		// A root commit is interesting only if it introduces any of
+		// the given paths.
+		same, err := w.treesame(commit.TreeID, nil)
+		return nil, !same, err
+	}
+
+	for i, parent := range parents {
+		if i == 0 {
+			same, err := w.unchanged(sha)
+			if err != nil {
+				return nil, false, err
+			}
+			if same {
+				return []*commitParent{parent}, false, nil
+			}
+		}
+
+		var err error
+		if parent.commit, err = w.db.Commit(parent.sha); err != nil {
+			return nil, false, err
+		}
+
+		same, err := w.treesame(commit.TreeID, parent.commit.TreeID)
+		if err != nil {
+			return nil, false, err
+		}
+		if same {
+			return []*commitParent{parent}, false, nil
+		}
+	}
+	return parents, true, nil
+}
+
+// unchanged returns whether the commit named by "sha" is known from its
+// changed-path Bloom filter (see: bloomFilter) to be identical to its first
+// parent with respect to the paths given to the walk. If it has no such filter,
+// unchanged returns false, and its trees must be compared instead.
+//
+// As with CommitInfo, the commit-graph is not used if any objects are replaced.
+func (w *commitWalker) unchanged(sha []byte) (bool, error) {
+	g := w.db.graph
+	if g == nil || len(w.db.replacements) > 0 {
+		return false, nil
+	}
+
+	pos, ok, err := g.position(sha)
+	if err != nil || !ok {
+		return false, err
+	}
+	f, err := g.bloomFilter(pos)
+	if err != nil || f == nil {
+		return false, err
+	}
+
+	for _, path := range w.args.paths {
+		if f.mayContain(path) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// treesame returns whether the trees named by "a" and "b" are identical with
+// respect to the paths given to the walk. A nil tree is treated as if it were
+// empty.
+func (w *commitWalker) treesame(a, b []byte) (bool, error) {
+	if bytes.Equal(a, b) {
+		return true, nil
+	}
+
+	for _, path := range w.args.paths {
+		ea, err := w.db.entryAtPath(a, path)
+		if err != nil {
+			return false, err
+		}
+		eb, err := w.db.entryAtPath(b, path)
+		if err != nil {
+			return false, err
+		}
+
+		if !ea.Equal(eb) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// committerTime returns the Unix timestamp at which the given commit was
+// committed, or zero if it cannot be determined.
+func committerTime(c *Commit) int64 {
+	return identTime(c.Committer)
+}
+
+// identTime returns the Unix timestamp encoded in the given identity (as found
+// in the author, committer, and tagger headers), or zero if it cannot be
+// determined.
+func identTime(ident string) int64 {
+	if i := strings.LastIndexByte(ident, '>'); i >= 0 {
+		ident = ident[i+1:]
+	}
+
+	fields := strings.Fields(ident)
+	if len(fields) == 0 {
+		return 0
+	}
+
+	when, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return when
+}
+
+// commitQueueItem is a single commit queued to be visited by a walk.
+type commitQueueItem struct {
+	sha []byte
+	// commit is the commit itself, which is nil if the commit was excluded
+	// when it was queued, and has not been loaded.
+	commit *Commit
+	// parentIDs holds the object IDs of the commit's parents.
+	parentIDs [][]byte
+	// when is the commit's committer date, as a Unix timestamp.
+	when int64
+	// seq is the order in which the commit was queued, and breaks ties
+	// between commits with identical committer dates.
+	seq int
+
+	// excluded indicates whether the commit is reachable from any of the
+	// commits given to WalkExcluding.
+	excluded bool
+	// popped indicates whether the commit has been taken from the queue.
+	popped bool
+
+	// visit indicates whether the commit is to be visited, and followed
+	// holds the object IDs of the parents which were followed from it.
+	// They are only recorded when visiting commits in topological order.
+	visit    bool
+	followed [][]byte
+}
+
+// commitQueue is an implementation of heap.Interface which orders commits by
+// descending committer date.
+type commitQueue []*commitQueueItem
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	if q[i].when != q[j].when {
+		return q[i].when > q[j].when
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x interface{}) {
+	*q = append(*q, x.(*commitQueueItem))
+}
+
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,372 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCommit writes a commit of the tree given by "files" (a mapping of
+// file name to contents) with the given committer date and parents, and
+// returns its object ID.
+func writeTestCommit(t *testing.T, db *ObjectDatabase, files map[string]string, when int64, parents ...[]byte) []byte {
+	var entries []*TreeEntry
+	for name, contents := range files {
+		blob, err := db.WriteBlob(NewBlobFromBytes([]byte(contents)))
+		require.NoError(t, err)
+
+		entries = append(entries, &TreeEntry{
+			Name:     name,
+			Oid:      blob,
+			Filemode: 0100644,
+		})
+	}
+
+	tree, err := db.WriteTree((&Tree{}).Merge(entries...))
+	require.NoError(t, err)
+
+	ident := fmt.Sprintf("A U Thor <author@example.com> %d +0000", when)
+	sha, err := db.WriteCommit(&Commit{
+		Author:    ident,
+		Committer: ident,
+		ParentIDs: parents,
+		TreeID:    tree,
+		Message:   fmt.Sprintf("commit at %d", when),
+	})
+	require.NoError(t, err)
+
+	return sha
+}
+
+// collectCommits walks the history reachable from "tips", returning the
+// object IDs of the commits visited, in order.
+func collectCommits(t *testing.T, db *ObjectDatabase, tips [][]byte, setters ...CommitWalkOption) []string {
+	var shas []string
+	err := db.WalkCommits(tips, func(sha []byte, commit *Commit) error {
+		shas = append(shas, hex.EncodeToString(sha))
+		return nil
+	}, setters...)
+	require.NoError(t, err)
+
+	return shas
+}
+
+func TestWalkCommitsVisitsHistoryByDate(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 300, c1)
+	c3 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 200, c1)
+	m := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 400, c3, c2)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(m),
+		hex.EncodeToString(c2),
+		hex.EncodeToString(c3),
+		hex.EncodeToString(c1),
+	}, collectCommits(t, db, [][]byte{m}))
+}
+
+func TestWalkCommitsStopsEarly(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+
+	var n int
+	err := db.WalkCommits([][]byte{c2}, func(sha []byte, commit *Commit) error {
+		n++
+		return StopWalk
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestWalkCommitsLimitedToPaths(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "1", "b.txt": "1"}, 200, c1)
+	c3 := writeTestCommit(t, db, map[string]string{"a.txt": "2", "b.txt": "1"}, 300, c2)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c3),
+		hex.EncodeToString(c1),
+	}, collectCommits(t, db, [][]byte{c3}, WalkPaths("a.txt")))
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c2),
+	}, collectCommits(t, db, [][]byte{c3}, WalkPaths("/b.txt")))
+}
+
+func TestWalkCommitsLimitedToPathsSimplifiesMerges(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	side := writeTestCommit(t, db, map[string]string{"a.txt": "1", "b.txt": "1"}, 200, base)
+	main := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 300, base)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "2", "b.txt": "1"}, 400, main, side)
+
+	// The merge is TREESAME to "main" with respect to "a.txt", so "side"
+	// is never walked.
+	assert.Equal(t, []string{
+		hex.EncodeToString(main),
+		hex.EncodeToString(base),
+	}, collectCommits(t, db, [][]byte{merge}, WalkPaths("a.txt")))
+}
+
+func TestWalkCommitsFollowsFirstParent(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	side := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 300, base)
+	main := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 200, base)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 400, main, side)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(merge),
+		hex.EncodeToString(main),
+		hex.EncodeToString(base),
+	}, collectCommits(t, db, [][]byte{merge}, WalkFirstParent()))
+}
+
+func TestWalkCommitsFollowsFirstParentLimitedToPaths(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	side := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 300, base)
+	main := writeTestCommit(t, db, map[string]string{"a.txt": "1", "b.txt": "1"}, 200, base)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "2", "b.txt": "1"}, 400, main, side)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(merge),
+		hex.EncodeToString(base),
+	}, collectCommits(t, db, [][]byte{merge}, WalkFirstParent(), WalkPaths("a.txt")))
+}
+
+func TestWalkCommitsSince(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+	c3 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, c2)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c3),
+		hex.EncodeToString(c2),
+	}, collectCommits(t, db, [][]byte{c3}, WalkSince(time.Unix(200, 0))))
+}
+
+func TestWalkCommitsSinceToleratesClockSkew(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 400)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 100, c1)
+	c3 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, c2)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c3),
+		hex.EncodeToString(c1),
+	}, collectCommits(t, db, [][]byte{c3}, WalkSince(time.Unix(200, 0))))
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c3),
+	}, collectCommits(t, db, [][]byte{c3}, WalkSince(time.Unix(200, 0)), WalkSlop(0)))
+}
+
+func TestWalkCommitsUntil(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+	c3 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, c2)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c2),
+		hex.EncodeToString(c1),
+	}, collectCommits(t, db, [][]byte{c3}, WalkUntil(time.Unix(250, 0))))
+}
+
+func TestWalkCommitsExcluding(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, base)
+	side := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, base)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 400, c1)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "5"}, 500, c2, side)
+
+	// Equivalent to "git rev-list merge ^c1".
+	assert.Equal(t, []string{
+		hex.EncodeToString(merge),
+		hex.EncodeToString(c2),
+		hex.EncodeToString(side),
+	}, collectCommits(t, db, [][]byte{merge}, WalkExcluding(c1)))
+
+	assert.Empty(t, collectCommits(t, db, [][]byte{c2}, WalkExcluding(merge)))
+}
+
+func TestWalkCommitsExcludingToleratesClockSkew(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	// "old" claims to be older than its parent, so it is walked before
+	// the excluded commit which reaches its parent.
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 300)
+	old := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 100, base)
+	hidden := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 200, base)
+	tip := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 400, old)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(tip),
+		hex.EncodeToString(old),
+	}, collectCommits(t, db, [][]byte{tip}, WalkExcluding(hidden)))
+}
+
+func TestWalkCommitsInTopoOrder(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	// "base" claims to be newer than one of its children, "b".
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 350)
+	a := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 300, base)
+	b := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 100, base)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 400, a, b)
+
+	// By date, "base" is visited before "b".
+	assert.Equal(t, []string{
+		hex.EncodeToString(merge),
+		hex.EncodeToString(a),
+		hex.EncodeToString(base),
+		hex.EncodeToString(b),
+	}, collectCommits(t, db, [][]byte{merge}))
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(merge),
+		hex.EncodeToString(a),
+		hex.EncodeToString(b),
+		hex.EncodeToString(base),
+	}, collectCommits(t, db, [][]byte{merge}, WalkTopoOrder()))
+}
+
+func TestWalkCommitsInTopoOrderVisitsLinesOfHistoryTogether(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	a1 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, base)
+	b1 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 250, base)
+	a2 := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 300, a1)
+	b2 := writeTestCommit(t, db, map[string]string{"a.txt": "5"}, 350, b1)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "6"}, 400, a2, b2)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(merge),
+		hex.EncodeToString(b2),
+		hex.EncodeToString(a2),
+		hex.EncodeToString(b1),
+		hex.EncodeToString(a1),
+		hex.EncodeToString(base),
+	}, collectCommits(t, db, [][]byte{merge}))
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(merge),
+		hex.EncodeToString(a2),
+		hex.EncodeToString(a1),
+		hex.EncodeToString(b2),
+		hex.EncodeToString(b1),
+		hex.EncodeToString(base),
+	}, collectCommits(t, db, [][]byte{merge}, WalkTopoOrder()))
+}
+
+func TestWalkCommitsInTopoOrderExcluding(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	a1 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, base)
+	b1 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, base)
+	merge := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 400, a1, b1)
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(merge),
+		hex.EncodeToString(a1),
+	}, collectCommits(t, db, [][]byte{merge}, WalkTopoOrder(), WalkExcluding(b1)))
+}
+
+func TestWalkCommitsLimitedToPathsWithBloomFilters(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-commit-graph")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "1", "b.txt": "1"}, 200, c1)
+	c3 := writeTestCommit(t, db, map[string]string{"a.txt": "2", "b.txt": "1"}, 300, c2)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "info"), 0755))
+	writeTestCommitGraphWithBloomFilters(t, filepath.Join(root, "info", "commit-graph"), db,
+		nil, map[string]*bloomFilter{
+			string(c1): newTestBloomFilter("a.txt"),
+			string(c2): newTestBloomFilter("b.txt"),
+			string(c3): newTestBloomFilter("a.txt"),
+		}, c1, c2, c3)
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c3),
+		hex.EncodeToString(c1),
+	}, collectCommits(t, db, [][]byte{c3}, WalkPaths("a.txt")))
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c2),
+	}, collectCommits(t, db, [][]byte{c3}, WalkPaths("b.txt")))
+}
+
+func TestWalkCommitsLimitedToPathsSkipsTreesRuledOutByBloomFilters(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-commit-graph")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+
+	// The filter of "c2" claims that "a.txt" is unchanged, although it is
+	// not, so that the walk can only skip "c2" by trusting the filter
+	// rather than comparing trees.
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "info"), 0755))
+	writeTestCommitGraphWithBloomFilters(t, filepath.Join(root, "info", "commit-graph"), db,
+		nil, map[string]*bloomFilter{
+			string(c2): newTestBloomFilter("b.txt"),
+		}, c1, c2)
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c1),
+	}, collectCommits(t, db, [][]byte{c2}, WalkPaths("a.txt")))
+
+	// Without the commit-graph, the trees are compared.
+	require.NoError(t, os.Remove(filepath.Join(root, "info", "commit-graph")))
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+
+	assert.Equal(t, []string{
+		hex.EncodeToString(c2),
+		hex.EncodeToString(c1),
+	}, collectCommits(t, db, [][]byte{c2}, WalkPaths("a.txt")))
+}
@@ -0,0 +1,368 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// compatMapHeader is the first line of the loose-object-idx file in
+	// which Git records the compatibility object IDs of loose objects.
+	compatMapHeader = "# loose-object-idx\n"
+)
+
+// compatMap maps the object IDs of objects in a repository's object format to
+// those in its compatibility object format (see: CompatObjectFormat), and back,
+// as Git records them in the "loose-object-idx" file of the objects directory.
+// It is shared by a database and its views.
+type compatMap struct {
+	// path is the path of the loose-object-idx file, or the empty string
+	// if the mapping is held only in memory.
+	path string
+	// algo and compat are the repository's object format and its
+	// compatibility object format.
+	algo, compat ObjectFormatAlgorithm
+
+	once sync.Once
+	err  error
+
+	mu         sync.RWMutex
+	toCompat   map[string][]byte
+	fromCompat map[string][]byte
+}
+
+// newCompatMap returns a new, unloaded *compatMap between the given object
+// formats, which reads and records its mapping in the file at the given path,
+// if it is not empty.
+func newCompatMap(path string, algo, compat ObjectFormatAlgorithm) *compatMap {
+	return &compatMap{
+		path:       path,
+		algo:       algo,
+		compat:     compat,
+		toCompat:   make(map[string][]byte),
+		fromCompat: make(map[string][]byte),
+	}
+}
+
+// load reads the mapping from the loose-object-idx file the first time it is
+// called, and returns any error encountered in doing so on every call. A
+// missing file holds no mapping. The empty tree and blob are always mapped, as
+// Git maps them.
+func (m *compatMap) load() error {
+	m.once.Do(func() {
+		algo, compat := lookupFormat(m.algo), lookupFormat(m.compat)
+		m.insert(algo.EmptyTree, compat.EmptyTree)
+		m.insert(algo.EmptyBlob, compat.EmptyBlob)
+
+		if len(m.path) == 0 {
+			return
+		}
+
+		f, err := os.Open(m.path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				m.err = err
+			}
+			return
+		}
+		defer f.Close()
+
+		m.err = m.read(f)
+	})
+	return m.err
+}
+
+// read reads the mapping from the given loose-object-idx file.
+func (m *compatMap) read(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	if !s.Scan() || s.Text()+"\n" != compatMapHeader {
+		if err := s.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("gitobj: invalid loose-object-idx header in %s", m.path)
+	}
+
+	for s.Scan() {
+		fields := strings.Split(s.Text(), " ")
+		if len(fields) != 2 {
+			return fmt.Errorf("gitobj: invalid loose-object-idx line %q", s.Text())
+		}
+
+		oid, err := hex.DecodeString(fields[0])
+		if err != nil || len(oid) != hasher(m.algo).Size() {
+			return fmt.Errorf("gitobj: invalid loose-object-idx line %q", s.Text())
+		}
+		compat, err := hex.DecodeString(fields[1])
+		if err != nil || len(compat) != hasher(m.compat).Size() {
+			return fmt.Errorf("gitobj: invalid loose-object-idx line %q", s.Text())
+		}
+		m.insert(oid, compat)
+	}
+	return s.Err()
+}
+
+// insert maps the given object IDs to one another in memory.
+func (m *compatMap) insert(oid, compat []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.toCompat[string(oid)] = compat
+	m.fromCompat[string(compat)] = oid
+}
+
+// lookup returns the object ID to which the given one is mapped, from the
+// repository's object format to its compatibility object format if "toCompat"
+// is set, and the other way if not, and whether it is mapped at all.
+func (m *compatMap) lookup(oid []byte, toCompat bool) ([]byte, bool, error) {
+	if err := m.load(); err != nil {
+		return nil, false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var mapped []byte
+	var ok bool
+	if toCompat {
+		mapped, ok = m.toCompat[string(oid)]
+	} else {
+		mapped, ok = m.fromCompat[string(oid)]
+	}
+	return mapped, ok, nil
+}
+
+// add maps the given object IDs to one another, and records the mapping in
+// the loose-object-idx file, if there is one and they were not already mapped,
+// appending to it (and writing its header, if it is empty), as Git does.
+func (m *compatMap) add(oid, compat []byte) error {
+	if _, ok, err := m.lookup(oid, true); err != nil || ok {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.toCompat[string(oid)]; ok {
+		return nil
+	}
+
+	if len(m.path) > 0 {
+		f, err := os.OpenFile(m.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+		if err != nil {
+			return err
+		}
+
+		var line bytes.Buffer
+		if fi, err := f.Stat(); err != nil {
+			f.Close()
+			return err
+		} else if fi.Size() == 0 {
+			line.WriteString(compatMapHeader)
+		}
+		fmt.Fprintf(&line, "%x %x\n", oid, compat)
+
+		if _, err = f.Write(line.Bytes()); err != nil {
+			f.Close()
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+	}
+
+	m.toCompat[string(oid)] = compat
+	m.fromCompat[string(compat)] = oid
+	return nil
+}
+
+// CompatOid returns the name in the database's compatibility object format
+// (see: CompatObjectFormat) of the object named "oid" in its own object format,
+// as recorded when the object was written, either by Git or by this package.
+//
+// An error is returned if the database has no compatibility object format, or
+// if the object's name in that format is not known.
+func (o *ObjectDatabase) CompatOid(oid []byte) ([]byte, error) {
+	return o.translate(oid, true)
+}
+
+// StorageOid returns the name in the database's own object format of the object
+// named "compat" in its compatibility object format (see: CompatObjectFormat),
+// as recorded when the object was written, either by Git or by this package.
+//
+// Objects may also be read by their compatibility names directly, as they are
+// translated when they are opened.
+//
+// An error is returned if the database has no compatibility object format, or
+// if the object's name in its own format is not known.
+func (o *ObjectDatabase) StorageOid(compat []byte) ([]byte, error) {
+	return o.translate(compat, false)
+}
+
+// translate returns the name of the object named "oid" in the database's
+// compatibility object format if "toCompat" is set, or in its own object
+// format if not.
+func (o *ObjectDatabase) translate(oid []byte, toCompat bool) ([]byte, error) {
+	if o.compat == nil {
+		return nil, fmt.Errorf("gitobj: no compatibility object format")
+	}
+
+	mapped, ok, err := o.compat.lookup(oid, toCompat)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		algo := o.compatObjectFormat
+		if !toCompat {
+			algo = o.objectFormat
+		}
+		return nil, fmt.Errorf("gitobj: no %s object ID for %x", algo, oid)
+	}
+	return mapped, nil
+}
+
+// storageOid returns the name in the database's own object format of the object
+// named "sha", which is translated (see: StorageOid) if it is the length of an
+// object ID in the database's compatibility object format (and not of one in
+// its own), and is otherwise returned as-is.
+func (o *ObjectDatabase) storageOid(sha []byte) ([]byte, error) {
+	if o.compat == nil || len(sha) != hasher(o.compatObjectFormat).Size() ||
+		len(sha) == o.Hasher().Size() {
+		return sha, nil
+	}
+	return o.translate(sha, false)
+}
+
+// encodedCompatSha returns the name in the database's compatibility object
+// format of the encoded object of the given type and size held by "buf", which
+// is either a *bytes.Buffer, or an io.Seeker positioned at its start, and is
+// left as it was found.
+func (o *ObjectDatabase) encodedCompatSha(typ ObjectType, size int64, buf io.Reader) ([]byte, error) {
+	if b, ok := buf.(*bytes.Buffer); ok {
+		return o.compatSha(typ, size, bytes.NewReader(b.Bytes()))
+	}
+
+	seek, ok := buf.(io.Seeker)
+	if !ok {
+		return nil, fmt.Errorf("gitobj: cannot compute %s object ID", o.compatObjectFormat)
+	}
+	compat, err := o.compatSha(typ, size, buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = seek.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return compat, nil
+}
+
+// compatSha returns the name of the encoded object of the given type and size,
+// read from "r", in the database's compatibility object format, translating
+// the names of the objects it refers to, as Git does.
+func (o *ObjectDatabase) compatSha(typ ObjectType, size int64, r io.Reader) ([]byte, error) {
+	h := hasher(o.compatObjectFormat)
+	if typ == BlobObjectType {
+		fmt.Fprintf(h, "%s %d\x00", typ, size)
+		if _, err := io.Copy(h, r); err != nil {
+			return nil, err
+		}
+		return h.Sum(nil), nil
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case TreeObjectType:
+		data, err = o.compatTree(data)
+	case CommitObjectType:
+		data, err = o.compatHeaders(data, "tree ", "parent ", "mergetag object ")
+	case TagObjectType:
+		data, err = o.compatHeaders(data, "object ")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(h, "%s %d\x00", typ, len(data))
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// compatTree returns the encoded tree "data", with the name of each of its
+// entries translated to the database's compatibility object format.
+func (o *ObjectDatabase) compatTree(data []byte) ([]byte, error) {
+	size := o.Hasher().Size()
+
+	var buf bytes.Buffer
+	for len(data) > 0 {
+		nul := bytes.IndexByte(data, 0)
+		if nul < 0 || len(data) < nul+1+size {
+			return nil, fmt.Errorf("gitobj: malformed tree")
+		}
+
+		compat, err := o.CompatOid(data[nul+1 : nul+1+size])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data[:nul+1])
+		buf.Write(compat)
+
+		data = data[nul+1+size:]
+	}
+	return buf.Bytes(), nil
+}
+
+// compatHeaders returns the encoded commit or tag "data", with the names of
+// the objects given by its headers which begin with any of the given prefixes
+// translated to the database's compatibility object format. For commits, these
+// are the "tree" and "parent" headers, and the "object" header of any tag given
+// in a "mergetag" header; for tags, the "object" header.
+func (o *ObjectDatabase) compatHeaders(data []byte, prefixes ...string) ([]byte, error) {
+	var buf bytes.Buffer
+	for len(data) > 0 {
+		line := data
+		if eol := bytes.IndexByte(data, '\n'); eol >= 0 {
+			line = data[:eol+1]
+		}
+		data = data[len(line):]
+
+		if len(bytes.TrimSuffix(line, []byte("\n"))) == 0 {
+			// The message follows, and refers to no objects.
+			buf.Write(line)
+			buf.Write(data)
+			break
+		}
+
+		translated := false
+		for _, prefix := range prefixes {
+			if !bytes.HasPrefix(line, []byte(prefix)) {
+				continue
+			}
+
+			oid, err := hex.DecodeString(strings.TrimSuffix(string(line[len(prefix):]), "\n"))
+			if err != nil {
+				return nil, fmt.Errorf("gitobj: invalid %q header: %s", strings.TrimSpace(prefix), err)
+			}
+			compat, err := o.CompatOid(oid)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buf, "%s%x\n", prefix, compat)
+			translated = true
+			break
+		}
+		if !translated {
+			buf.Write(line)
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,141 @@
+package gitobj
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCompatTestObjects writes a blob, a tree holding it, a commit of that
+// tree, and a tag of that commit to the given database, and returns their
+// names.
+func writeCompatTestObjects(t *testing.T, db *ObjectDatabase) [][]byte {
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	root, err := db.WriteCommit(&Commit{
+		Author:    "A U Thor <author@example.com> 1234567890 +0000",
+		Committer: "A U Thor <author@example.com> 1234567890 +0000",
+		TreeID:    tree,
+		Message:   "Initial commit",
+	})
+	require.NoError(t, err)
+
+	commit, err := db.WriteCommit(&Commit{
+		Author:    "A U Thor <author@example.com> 1234567890 +0000",
+		Committer: "A U Thor <author@example.com> 1234567890 +0000",
+		TreeID:    tree,
+		ParentIDs: [][]byte{root},
+		ExtraHeaders: []*ExtraHeader{
+			{K: "mergetag", V: fmt.Sprintf("object %x\ntype commit\ntag v0\n\nv0", root)},
+		},
+		Message: "Second commit",
+	})
+	require.NoError(t, err)
+
+	tag, err := db.WriteTag(&Tag{
+		Object:     commit,
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "A U Thor <author@example.com> 1234567890 +0000",
+		Message:    "v1.0.0",
+	})
+	require.NoError(t, err)
+
+	return [][]byte{blob, tree, root, commit, tag}
+}
+
+func TestCompatOidsMatchThoseOfCompatObjectFormat(t *testing.T) {
+	sha1DB := newTestMemoryDatabase(t)
+	want := writeCompatTestObjects(t, sha1DB)
+
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	db, err := FromBackend(b, ObjectFormat(ObjectFormatSHA256),
+		CompatObjectFormat(ObjectFormatSHA1))
+	require.NoError(t, err)
+	oids := writeCompatTestObjects(t, db)
+
+	for i, oid := range oids {
+		compat, err := db.CompatOid(oid)
+		require.NoError(t, err)
+		assert.Equal(t, want[i], compat)
+
+		storage, err := db.StorageOid(compat)
+		require.NoError(t, err)
+		assert.Equal(t, oid, storage)
+	}
+
+	commit, err := db.Commit(want[3])
+	require.NoError(t, err)
+	assert.Equal(t, oids[1], commit.TreeID)
+	assert.Equal(t, [][]byte{oids[2]}, commit.ParentIDs)
+}
+
+func TestCompatOidOfUnknownObject(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	db, err := FromBackend(b, ObjectFormat(ObjectFormatSHA256),
+		CompatObjectFormat(ObjectFormatSHA1))
+	require.NoError(t, err)
+
+	_, err = db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "missing.txt", Oid: make([]byte, 32), Filemode: 0100644},
+	}})
+	assert.EqualError(t, err, "gitobj: no sha1 object ID for "+
+		"0000000000000000000000000000000000000000000000000000000000000000")
+
+	_, err = db.Blob(make([]byte, 20))
+	assert.EqualError(t, err, "gitobj: no sha256 object ID for "+
+		"0000000000000000000000000000000000000000")
+
+	_, err = newTestMemoryDatabase(t).CompatOid(make([]byte, 20))
+	assert.EqualError(t, err, "gitobj: no compatibility object format")
+}
+
+func TestCompatOidsAreRecordedInLooseObjectIdx(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	setters := []Option{
+		ObjectFormat(ObjectFormatSHA256),
+		CompatObjectFormat(ObjectFormatSHA1),
+	}
+
+	db, err := FromFilesystem(root, "", setters...)
+	require.NoError(t, err)
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	compat, err := db.CompatOid(blob)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	idx, err := ioutil.ReadFile(filepath.Join(root, "loose-object-idx"))
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("# loose-object-idx\n%x %x\n", blob, compat), string(idx))
+
+	db, err = FromFilesystem(root, "", setters...)
+	require.NoError(t, err)
+	defer db.Close()
+
+	storage, err := db.StorageOid(compat)
+	require.NoError(t, err)
+	assert.Equal(t, blob, storage)
+
+	b, err := db.Blob(compat)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(b.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+}
@@ -0,0 +1,103 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectDatabaseConcurrentReads(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	const n = 64
+
+	// Half of the objects are packed, and half are loose.
+	var blobs, trees [][]byte
+	for _, packed := range []bool{true, false} {
+		var setters []Option
+		if packed {
+			setters = append(setters, PackedWrites())
+		}
+
+		db, err := FromFilesystem(root, "", setters...)
+		require.NoError(t, err)
+		for i := 0; i < n/2; i++ {
+			contents := []byte(fmt.Sprintf("blob %d, packed: %t\n", i, packed))
+			blob, err := db.WriteBlob(NewBlobFromBytes(contents))
+			require.NoError(t, err)
+			tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+				{Name: "blob.txt", Oid: blob, Filemode: 0100644},
+			}})
+			require.NoError(t, err)
+
+			blobs, trees = append(blobs, blob), append(trees, tree)
+		}
+		require.NoError(t, db.Flush())
+		require.NoError(t, db.Close())
+	}
+
+	db, err := FromFilesystem(root, "", DeltaBaseCacheLimit(1<<10), ObjectCache(1<<10))
+	require.NoError(t, err)
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for round := 0; round < 8; round++ {
+				for i := range trees {
+					// Each worker reads the objects in a
+					// different order.
+					i = (i + w*7) % len(trees)
+
+					tree, err := db.Tree(trees[i])
+					if err != nil {
+						errs <- err
+						return
+					}
+					if len(tree.Entries) != 1 || !bytes.Equal(tree.Entries[0].Oid, blobs[i]) {
+						errs <- fmt.Errorf("tree %x has unexpected entries", trees[i])
+						return
+					}
+
+					blob, err := db.Blob(blobs[i])
+					if err != nil {
+						errs <- err
+						return
+					}
+					contents, err := ioutil.ReadAll(blob.Contents)
+					blob.Close()
+					if err != nil {
+						errs <- err
+						return
+					}
+
+					packed := i < n/2
+					want := fmt.Sprintf("blob %d, packed: %t\n", i%(n/2), packed)
+					if string(contents) != want {
+						errs <- fmt.Errorf("blob %x has contents %q, expected %q",
+							blobs[i], contents, want)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,250 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+var (
+	// connectivityStateHeader is the magic number at the beginning of a
+	// persisted *ConnectivityState.
+	connectivityStateHeader = []byte("GOCS")
+)
+
+const (
+	// connectivityStateVersion is the version of the persisted
+	// *ConnectivityState format.
+	connectivityStateVersion = 1
+)
+
+// ConnectivityState is the set of objects which a previous connectivity check
+// (see: CheckConnectivity) found to be present, along with every object
+// reachable from them. Later checks assume that this remains true, and do not
+// examine those objects again, so that repeated checks of a large repository
+// only examine objects reachable from new tips.
+//
+// Since objects are not expected to be removed from a repository while they
+// remain reachable, a *ConnectivityState should be discarded after pruning.
+type ConnectivityState struct {
+	known map[string]struct{}
+}
+
+// NewConnectivityState returns a new, empty *ConnectivityState.
+func NewConnectivityState() *ConnectivityState {
+	return &ConnectivityState{known: make(map[string]struct{})}
+}
+
+// ReadConnectivityState reads a *ConnectivityState written by WriteTo from
+// "r".
+func ReadConnectivityState(r io.Reader) (*ConnectivityState, error) {
+	br := bufio.NewReader(r)
+
+	var hdr struct {
+		Magic   [4]byte
+		Version uint32
+		Hashlen uint32
+		Count   uint32
+	}
+	if err := binary.Read(br, binary.BigEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr.Magic[:], connectivityStateHeader) {
+		return nil, fmt.Errorf("gitobj: invalid connectivity state header")
+	}
+	if hdr.Version != connectivityStateVersion {
+		return nil, fmt.Errorf("gitobj: unsupported connectivity state version %d", hdr.Version)
+	}
+	if hdr.Hashlen > pack.MaxHashSize {
+		return nil, fmt.Errorf("gitobj: invalid connectivity state hash length %d", hdr.Hashlen)
+	}
+
+	s := &ConnectivityState{known: make(map[string]struct{}, hdr.Count)}
+	for i := uint32(0); i < hdr.Count; i++ {
+		sha := make([]byte, hdr.Hashlen)
+		if _, err := io.ReadFull(br, sha); err != nil {
+			return nil, err
+		}
+		s.known[string(sha)] = struct{}{}
+	}
+	return s, nil
+}
+
+// LoadConnectivityState reads a *ConnectivityState saved by Save from the file
+// at "path". If there is no such file, an empty *ConnectivityState is returned.
+func LoadConnectivityState(path string) (*ConnectivityState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewConnectivityState(), nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadConnectivityState(f)
+}
+
+// Len returns the number of objects known to be connected.
+func (s *ConnectivityState) Len() int {
+	return len(s.known)
+}
+
+// Contains returns whether the object named "sha" is known to be connected.
+func (s *ConnectivityState) Contains(sha []byte) bool {
+	_, ok := s.known[string(sha)]
+	return ok
+}
+
+// WriteTo writes the *ConnectivityState to "w", in a form which may be read by
+// ReadConnectivityState.
+func (s *ConnectivityState) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(s.known))
+	for sha := range s.known {
+		names = append(names, sha)
+	}
+	sort.Strings(names)
+
+	var hashlen int
+	if len(names) > 0 {
+		hashlen = len(names[0])
+	}
+
+	var buf bytes.Buffer
+	buf.Write(connectivityStateHeader)
+	binary.Write(&buf, binary.BigEndian, []uint32{
+		connectivityStateVersion, uint32(hashlen), uint32(len(names)),
+	})
+	for _, sha := range names {
+		buf.WriteString(sha)
+	}
+	return buf.WriteTo(w)
+}
+
+// Save writes the *ConnectivityState to the file at "path", replacing it
+// atomically.
+func (s *ConnectivityState) Save(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp_connectivity_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := s.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// CheckConnectivity verifies that every object reachable from the given tips
+// (which may be commits, trees, blobs, or tags) is present in the database,
+// returning a *MissingObject naming the first which is not. Submodule commits
+// are not considered.
+//
+// If "state" is non-nil, objects which it records are assumed to be connected,
+// and are not examined. If the check succeeds, every object examined is added
+// to it; otherwise it is left unchanged.
+func (o *ObjectDatabase) CheckConnectivity(tips [][]byte, state *ConnectivityState) error {
+	type pending struct {
+		sha []byte
+		// from is the name of the object which refers to "sha", or
+		// nil if it is a tip.
+		from []byte
+	}
+
+	var stack []pending
+	for i := len(tips) - 1; i >= 0; i-- {
+		stack = append(stack, pending{sha: tips[i]})
+	}
+
+	seen := make(map[string]struct{})
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if _, ok := seen[string(next.sha)]; ok {
+			continue
+		}
+		if state != nil && state.Contains(next.sha) {
+			continue
+		}
+		seen[string(next.sha)] = struct{}{}
+
+		refs, err := o.references(next.sha)
+		if err != nil {
+			if errors.IsNoSuchObject(err) {
+				return &MissingObject{Oid: next.sha, ReferencedBy: next.from}
+			}
+			return err
+		}
+
+		for i := len(refs) - 1; i >= 0; i-- {
+			stack = append(stack, pending{sha: refs[i], from: next.sha})
+		}
+	}
+
+	if state != nil {
+		for sha := range seen {
+			state.known[sha] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// references returns the names of the objects to which the object named "sha"
+// refers: the tree and parents of a commit, the entries of a tree (other than
+// submodules), or the object to which a tag points. Blobs are not read, since
+// they refer to no other objects.
+func (o *ObjectDatabase) references(sha []byte) ([][]byte, error) {
+	typ, _, err := o.ObjectInfo(sha)
+	if err != nil {
+		return nil, err
+	}
+	return o.referencesOf(sha, typ)
+}
+
+// referencesOf returns the names of the objects to which the object named
+// "sha", of type "typ", refers (see: references).
+func (o *ObjectDatabase) referencesOf(sha []byte, typ ObjectType) ([][]byte, error) {
+	switch typ {
+	case CommitObjectType:
+		c, err := o.Commit(sha)
+		if err != nil {
+			return nil, err
+		}
+		return append([][]byte{c.TreeID}, c.ParentIDs...), nil
+	case TreeObjectType:
+		t, err := o.Tree(sha)
+		if err != nil {
+			return nil, err
+		}
+
+		refs := make([][]byte, 0, len(t.Entries))
+		for _, e := range t.Entries {
+			if !e.IsSubmodule() {
+				refs = append(refs, e.Oid)
+			}
+		}
+		return refs, nil
+	case TagObjectType:
+		t, err := o.Tag(sha)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{t.Object}, nil
+	}
+	return nil, nil
+}
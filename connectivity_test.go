@@ -0,0 +1,93 @@
+package gitobj
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConnectivity(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+	tag := writeTestTagID(t, db, c2, CommitObjectType, "v1", 200)
+
+	assert.NoError(t, db.CheckConnectivity([][]byte{tag}, nil))
+}
+
+func TestCheckConnectivityReportsMissingObjects(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	missing := make([]byte, 20)
+	missing[0] = 0xaa
+
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: missing, Filemode: 0100644},
+		{Name: "sub", Oid: make([]byte, 20), Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	state := NewConnectivityState()
+	err = db.CheckConnectivity([][]byte{tree}, state)
+	assert.Equal(t, &MissingObject{Oid: missing, ReferencedBy: tree}, err)
+	assert.Equal(t, 0, state.Len())
+}
+
+func TestCheckConnectivityReusesState(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+
+	state := NewConnectivityState()
+	require.NoError(t, db.CheckConnectivity([][]byte{c1}, state))
+	// The commit, its tree, and its blob.
+	assert.Equal(t, 3, state.Len())
+	assert.True(t, state.Contains(c1))
+
+	// Objects known to be connected are not examined again, so a commit
+	// whose parent is recorded is considered connected, even if the
+	// parent's history is not.
+	missing := make([]byte, 20)
+	missing[0] = 0xaa
+	state.known[string(missing)] = struct{}{}
+
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1, missing)
+	require.NoError(t, db.CheckConnectivity([][]byte{c2}, state))
+	assert.True(t, state.Contains(c2))
+
+	err := db.CheckConnectivity([][]byte{c2}, nil)
+	assert.Equal(t, &MissingObject{Oid: missing, ReferencedBy: c2}, err)
+}
+
+func TestConnectivityStateRoundTrips(t *testing.T) {
+	state := NewConnectivityState()
+	state.known[string(bytes.Repeat([]byte{0x01}, 20))] = struct{}{}
+	state.known[string(bytes.Repeat([]byte{0x02}, 20))] = struct{}{}
+
+	dir, err := ioutil.TempDir("", "gitobj-connectivity")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "connectivity")
+
+	empty, err := LoadConnectivityState(path)
+	require.NoError(t, err)
+	assert.Equal(t, 0, empty.Len())
+
+	require.NoError(t, state.Save(path))
+
+	loaded, err := LoadConnectivityState(path)
+	require.NoError(t, err)
+	assert.Equal(t, state, loaded)
+}
+
+func TestReadConnectivityStateRejectsOtherData(t *testing.T) {
+	_, err := ReadConnectivityState(bytes.NewReader(bytes.Repeat([]byte{0}, 16)))
+	assert.EqualError(t, err, "gitobj: invalid connectivity state header")
+}
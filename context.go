@@ -0,0 +1,56 @@
+package gitobj
+
+import (
+	"context"
+	"io"
+)
+
+// contextReader is an io.Reader which fails with the error of its context once
+// that context is done, and otherwise reads from the underlying io.Reader.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader.
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// contextReadCloser is a contextReader which closes the underlying
+// io.ReadCloser when closed, regardless of whether its context is done.
+type contextReadCloser struct {
+	ctx context.Context
+	r   io.ReadCloser
+}
+
+// Read implements io.Reader.
+func (c *contextReadCloser) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Close implements io.Closer.
+func (c *contextReadCloser) Close() error {
+	return c.r.Close()
+}
+
+// contextWriter is an io.Writer which fails with the error of its context once
+// that context is done, and otherwise writes to the underlying io.Writer.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// Write implements io.Writer.
+func (c *contextWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
@@ -0,0 +1,50 @@
+package gitobj
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Copy writes the (uncompressed) contents of the object named by "sha" to "w",
+// and returns its type and size. Unlike reading a *Blob, or the contents of any
+// other decoded object, the contents are streamed directly from the object's
+// storage, so that they need not be held in memory, which is useful when
+// exporting many objects in bulk.
+//
+// The object's header is not written. If fewer or more bytes are read than
+// were given by the header, an error is returned, though the contents read
+// will already have been written to "w".
+func (o *ObjectDatabase) Copy(sha []byte, w io.Writer) (ObjectType, int64, error) {
+	return o.CopyContext(context.Background(), sha, w)
+}
+
+// CopyContext is as Copy, but abandons copying the object, and returns the
+// context's error, once the given context is done.
+func (o *ObjectDatabase) CopyContext(ctx context.Context, sha []byte, w io.Writer) (ObjectType, int64, error) {
+	r, err := o.open(ctx, sha)
+	if err != nil {
+		return UnknownObjectType, 0, err
+	}
+	if err := o.checkAllowed(sha, r); err != nil {
+		return UnknownObjectType, 0, err
+	}
+	defer r.Close()
+
+	typ, size, err := r.Header()
+	if err != nil {
+		return UnknownObjectType, 0, err
+	}
+
+	// Read one byte more than the header gives, so that an object
+	// which is longer than it claims to be is detected.
+	n, err := io.Copy(w, io.LimitReader(r, size+1))
+	if err != nil {
+		return UnknownObjectType, 0, err
+	}
+	if n != size {
+		return UnknownObjectType, 0, fmt.Errorf(
+			"gitobj: object %x has %d byte(s), expected %d", sha, n, size)
+	}
+	return typ, size, nil
+}
@@ -0,0 +1,85 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyWritesObjectContents(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	typ, size, err := db.Copy(sha, &buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, BlobObjectType, typ)
+	assert.EqualValues(t, 14, size)
+	assert.Equal(t, "Hello, world!\n", buf.String())
+}
+
+func TestCopyWritesPackedObjectContents(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	defer db.Close()
+
+	tree := writeTestTree(t, db)
+	require.NoError(t, db.Flush())
+
+	var buf bytes.Buffer
+	typ, size, err := db.Copy(tree, &buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, TreeObjectType, typ)
+	assert.EqualValues(t, buf.Len(), size)
+
+	want, err := db.Tree(tree)
+	require.NoError(t, err)
+	var got Tree
+	_, err = got.Decode(db.Hasher(), &buf, size)
+	require.NoError(t, err)
+	assert.True(t, want.Equal(&got))
+}
+
+func TestCopyDetectsTruncatedObjects(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := io.WriteString(zw, "blob 14\x00Hello\n")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	sha := "0000000000000000000000000000000000000000"
+	b, err := NewMemoryBackend(map[string]io.ReadWriter{sha: &buf})
+	require.NoError(t, err)
+	db, err := FromBackend(b)
+	require.NoError(t, err)
+
+	oid, _ := hex.DecodeString(sha)
+	_, _, err = db.Copy(oid, ioutil.Discard)
+
+	assert.EqualError(t, err, "gitobj: object 0000000000000000000000000000000000000000 has 6 byte(s), expected 14")
+}
+
+func TestCopyOfAMissingObject(t *testing.T) {
+	sha, _ := hex.DecodeString("af5626b4a114abcb82d63db7c8082c3c4756e51b")
+
+	db := newTestMemoryDatabase(t)
+
+	_, _, err := db.Copy(sha, ioutil.Discard)
+	assert.True(t, errors.IsNoSuchObject(err))
+}
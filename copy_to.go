@@ -0,0 +1,86 @@
+package gitobj
+
+import (
+	"context"
+	"fmt"
+)
+
+// CopyTo writes each object in the database (see: ForEachObject) to "dst",
+// under the same name, as when persisting objects prototyped in a database
+// created with NewMemoryBackend to a repository on disk. Objects which "dst"
+// already holds are not written again.
+//
+// The contents of each object are streamed from the database into "dst", and
+// hashed as they are, so that an object whose contents do not match its name
+// is not written, and an error is returned instead. Objects are copied as they
+// are stored, rather than as they have been replaced (see: ReplaceObjects).
+//
+// Both databases must have the same object format. If writes to "dst" are
+// staged (see: PackedWrites), they must still be flushed (see: Flush).
+func (o *ObjectDatabase) CopyTo(dst *ObjectDatabase) error {
+	return o.CopyToContext(context.Background(), dst)
+}
+
+// CopyToContext is as CopyTo, but stops copying, and returns the context's
+// error, once the given context is done.
+func (o *ObjectDatabase) CopyToContext(ctx context.Context, dst *ObjectDatabase) error {
+	if dst.isClosed() {
+		return ErrDatabaseClosed
+	}
+	if o.objectFormat != dst.objectFormat {
+		return fmt.Errorf("gitobj: cannot copy %s objects to a %s database",
+			o.objectFormat, dst.objectFormat)
+	}
+
+	// Enumerate every object before copying any, so that copying to a
+	// database sharing storage with this one does not disturb the
+	// enumeration.
+	var oids [][]byte
+	err := o.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		oids = append(oids, oid)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, oid := range oids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ok, err := dst.Has(oid)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+
+		if err := o.copyObject(ctx, dst, oid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyObject writes the object named "sha" to "dst", returning an error if its
+// contents do not match its name.
+func (o *ObjectDatabase) copyObject(ctx context.Context, dst *ObjectDatabase, sha []byte) error {
+	r, err := o.openExact(ctx, sha)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := o.checkAllowed(sha, r); err != nil {
+		return err
+	}
+
+	typ, size, err := r.Header()
+	if err != nil {
+		return err
+	}
+	_, err = dst.writeRaw(ctx, sha, typ, size, r)
+	return err
+}
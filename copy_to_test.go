@@ -0,0 +1,90 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyToPersistsMemoryObjects(t *testing.T) {
+	src := newTestMemoryDatabase(t)
+
+	tree := writeTestTree(t, src)
+	commit, err := src.WriteCommit(&Commit{
+		Author:    "Jane Doe <jane@example.com> 1503956287 -0400",
+		Committer: "Jane Doe <jane@example.com> 1503956287 -0400",
+		TreeID:    tree,
+		Message:   "initial commit",
+	})
+	require.NoError(t, err)
+
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	dst, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer dst.Close()
+
+	require.NoError(t, src.CopyTo(dst))
+
+	var want, got [][]byte
+	require.NoError(t, src.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		want = append(want, oid)
+		return nil
+	}))
+	require.NoError(t, dst.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		got = append(got, oid)
+		return nil
+	}))
+	assert.ElementsMatch(t, want, got)
+
+	c, err := dst.Commit(commit)
+	require.NoError(t, err)
+	assert.Equal(t, tree, c.TreeID)
+
+	// Copying again writes nothing new.
+	require.NoError(t, src.CopyTo(dst))
+}
+
+func TestCopyToRejectsMismatchedObjects(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := io.WriteString(zw, "blob 6\x00Hello\n")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	sha := "0000000000000000000000000000000000000000"
+	b, err := NewMemoryBackend(map[string]io.ReadWriter{sha: &buf})
+	require.NoError(t, err)
+	src, err := FromBackend(b)
+	require.NoError(t, err)
+
+	dst := newTestMemoryDatabase(t)
+
+	err = src.CopyTo(dst)
+	assert.EqualError(t, err, "gitobj: object 0000000000000000000000000000000000000000 has contents of e965047ad7c57865823c7d992b1d046ea66edf78")
+
+	oid, _ := hex.DecodeString(sha)
+	ok, err := dst.Has(oid)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCopyToRequiresMatchingObjectFormats(t *testing.T) {
+	src := newTestMemoryDatabase(t)
+
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	dst, err := FromBackend(b, ObjectFormat(ObjectFormatSHA256))
+	require.NoError(t, err)
+
+	assert.EqualError(t, src.CopyTo(dst), "gitobj: cannot copy sha1 objects to a sha256 database")
+}
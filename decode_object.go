@@ -0,0 +1,56 @@
+package gitobj
+
+import (
+	"fmt"
+)
+
+// DecodeObject decodes the object read by "r", whose header gives its type and
+// size, into a *Blob, *Tree, *Commit, or *Tag, independently of any database,
+// as when objects are received over a transport of the caller's own. The object
+// names held by trees are taken to be of the given object format.
+//
+// The stream read by "r" is either zlib-compressed, as it is stored in a loose
+// object (see: NewObjectReader), or already inflated (see:
+// NewUncompressedObjectReader). As when read from a database, the contents of a
+// blob are read from "r" only as they are used, and "r" is closed when the blob
+// is closed; otherwise, "r" is closed once the object is decoded.
+func DecodeObject(r *ObjectReader, format ObjectFormatAlgorithm) (Object, error) {
+	hash := hasher(format)
+	if hash == nil {
+		return nil, &UnknownObjectFormat{Format: format}
+	}
+
+	typ, size, err := r.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	into, err := newObject(typ)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = into.Decode(hash, r, size); err != nil {
+		return into, err
+	}
+
+	if typ == BlobObjectType {
+		return into, nil
+	}
+	return into, r.Close()
+}
+
+// newObject returns a new, empty Object of the given type, into which an object
+// of that type may be decoded.
+func newObject(typ ObjectType) (Object, error) {
+	switch typ {
+	case BlobObjectType:
+		return new(Blob), nil
+	case TreeObjectType:
+		return new(Tree), nil
+	case CommitObjectType:
+		return new(Commit), nil
+	case TagObjectType:
+		return new(Tag), nil
+	}
+	return nil, fmt.Errorf("gitobj: unknown object type: %s", typ)
+}
@@ -0,0 +1,61 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeObjectDecodesCompressedBlobs(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	fmt.Fprintf(zw, "blob 14\x00Hello, world!\n")
+	require.NoError(t, zw.Close())
+
+	r, err := NewObjectReader(&buf)
+	require.NoError(t, err)
+
+	obj, err := DecodeObject(r, ObjectFormatSHA1)
+	require.NoError(t, err)
+
+	blob, ok := obj.(*Blob)
+	require.True(t, ok)
+	defer blob.Close()
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+}
+
+func TestDecodeObjectDecodesUncompressedTrees(t *testing.T) {
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: bytes.Repeat([]byte{0x1}, 32), Filemode: 0100644},
+	}}
+
+	var body bytes.Buffer
+	_, err := tree.Encode(&body)
+	require.NoError(t, err)
+
+	r, err := NewUncompressedObjectReader(io.MultiReader(
+		bytes.NewReader([]byte(fmt.Sprintf("tree %d\x00", body.Len()))),
+		&body))
+	require.NoError(t, err)
+
+	obj, err := DecodeObject(r, ObjectFormatSHA256)
+	require.NoError(t, err)
+	assert.Equal(t, tree, obj)
+}
+
+func TestDecodeObjectRejectsUnknownObjectFormats(t *testing.T) {
+	r, err := NewUncompressedObjectReader(bytes.NewReader([]byte("blob 0\x00")))
+	require.NoError(t, err)
+
+	_, err = DecodeObject(r, ObjectFormatAlgorithm("md5"))
+	assert.EqualError(t, err, `gitobj: unknown object format "md5"`)
+}
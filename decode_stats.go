@@ -0,0 +1,151 @@
+package gitobj
+
+import (
+	"os"
+	"time"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// maxDeltaDepth is the deepest delta-base chain followed in finding the delta
+// depth of an object (see: DecodeStats), beyond which the chain is assumed to
+// be cyclic.
+const maxDeltaDepth = 1 << 12
+
+// DecodeStats describes how an object was read from the database, as recorded
+// when it is read with RecordDecodeStats, to help investigate the performance
+// of programs reading many objects.
+type DecodeStats struct {
+	// Source is the path of the packfile, or of the loose object, from
+	// which the object was read. It is empty if the object was read from
+	// storage other than the filesystem, or was injected (see: Inject).
+	Source string
+	// Packed indicates whether the object was read from a packfile.
+	Packed bool
+	// DeltaDepth is the number of deltas applied to reconstruct a packed
+	// object from the base of its delta-base chain, or zero if it is not
+	// stored as a delta.
+	DeltaDepth int
+	// CompressedSize is the number of bytes occupied by the object in its
+	// storage: the size of its packfile entry (but not those of its delta
+	// bases), or of its loose object. It is zero if Source is empty.
+	CompressedSize int64
+	// Duration is the time spent opening and decoding the object. Since
+	// the contents of a blob are read only as they are used, for blobs it
+	// covers only opening the blob.
+	Duration time.Duration
+}
+
+// DecodeStats returns a description of how the blob was read, or nil if it was
+// not read with RecordDecodeStats.
+func (b *Blob) DecodeStats() *DecodeStats { return b.decodeStats }
+
+// DecodeStats returns a description of how the tree was read, or nil if it was
+// not read with RecordDecodeStats. A tree returned from the object cache (see:
+// ObjectCache) describes how it was first read.
+func (t *Tree) DecodeStats() *DecodeStats { return t.decodeStats }
+
+// DecodeStats returns a description of how the commit was read, or nil if it
+// was not read with RecordDecodeStats (see: Tree.DecodeStats).
+func (c *Commit) DecodeStats() *DecodeStats { return c.decodeStats }
+
+// DecodeStats returns a description of how the tag was read, or nil if it was
+// not read with RecordDecodeStats.
+func (t *Tag) DecodeStats() *DecodeStats { return t.decodeStats }
+
+// recordDecodeStats attaches a description of how the object named "sha" was
+// read to "obj", which was opened and decoded beginning at "start", if the
+// database records decode statistics (see: RecordDecodeStats). Since they are
+// only informational, the statistics are left unset if they cannot be found.
+func (o *ObjectDatabase) recordDecodeStats(sha []byte, obj Object, start time.Time) {
+	if !o.decodeStats {
+		return
+	}
+
+	elapsed := time.Since(start)
+
+	stats, err := o.objectSource(sha)
+	if err != nil {
+		return
+	}
+	stats.Duration = elapsed
+
+	switch obj := obj.(type) {
+	case *Blob:
+		obj.decodeStats = stats
+	case *Tree:
+		obj.decodeStats = stats
+	case *Commit:
+		obj.decodeStats = stats
+	case *Tag:
+		obj.decodeStats = stats
+	}
+}
+
+// objectSource returns a description of where the object named "sha" (or its
+// replacement) is stored (see: storedSource).
+func (o *ObjectDatabase) objectSource(sha []byte) (*DecodeStats, error) {
+	sha, err := o.replace(sha)
+	if err != nil {
+		return nil, err
+	}
+	return o.storedSource(sha)
+}
+
+// storedSource returns a description of where the object named "sha" itself is
+// stored, searching the database's storages in the order in which objects are
+// read from them.
+func (o *ObjectDatabase) storedSource(sha []byte) (*DecodeStats, error) {
+	stats := new(DecodeStats)
+	if r, ok := o.openInjected(sha); ok {
+		r.Close()
+		return stats, nil
+	}
+
+	for _, s := range storages(o.ro) {
+		switch s := s.(type) {
+		case *fileStorer:
+			path := s.objectPath(sha)
+			fi, err := os.Stat(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+
+			stats.Source, stats.CompressedSize = path, fi.Size()
+			return stats, nil
+		case *pack.Storage:
+			for _, p := range s.Packs() {
+				if p.Index() == nil {
+					continue
+				}
+
+				hdr, base, err := entryBase(p, sha)
+				if err != nil {
+					if pack.IsNotFound(err) {
+						continue
+					}
+					return nil, err
+				}
+
+				stats.Source, stats.Packed = p.Path(), true
+				stats.CompressedSize = hdr.Length
+				for base != nil && stats.DeltaDepth < maxDeltaDepth {
+					stats.DeltaDepth++
+					if _, base, err = entryBase(p, base); err != nil {
+						if pack.IsNotFound(err) {
+							// The base of a thin pack's delta
+							// is held elsewhere.
+							break
+						}
+						return nil, err
+					}
+				}
+				return stats, nil
+			}
+		}
+	}
+	return stats, nil
+}
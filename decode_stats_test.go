@@ -0,0 +1,80 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordDecodeStatsOfLooseObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", RecordDecodeStats())
+	require.NoError(t, err)
+	defer db.Close()
+
+	sha := writeTestTree(t, db)
+	tree, err := db.Tree(sha)
+	require.NoError(t, err)
+
+	path := filepath.Join(root, hex.EncodeToString(sha)[:2], hex.EncodeToString(sha)[2:])
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+
+	stats := tree.DecodeStats()
+	require.NotNil(t, stats)
+	assert.Equal(t, path, stats.Source)
+	assert.False(t, stats.Packed)
+	assert.Equal(t, 0, stats.DeltaDepth)
+	assert.Equal(t, fi.Size(), stats.CompressedSize)
+
+	obj, err := db.Object(sha)
+	require.NoError(t, err)
+	assert.Equal(t, path, obj.(*Tree).DecodeStats().Source)
+}
+
+func TestRecordDecodeStatsOfPackedObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, base, world, _ := newDeltaTestDatabase(t, root, RecordDecodeStats())
+	defer db.Close()
+
+	for _, test := range []struct {
+		sha   []byte
+		depth int
+		size  int64
+	}{
+		{base, 0, 19},
+		{world, 1, 49},
+	} {
+		blob, err := db.Blob(test.sha)
+		require.NoError(t, err)
+		require.NoError(t, blob.Close())
+
+		stats := blob.DecodeStats()
+		require.NotNil(t, stats)
+		assert.True(t, strings.HasSuffix(stats.Source, ".pack"), stats.Source)
+		assert.True(t, stats.Packed)
+		assert.Equal(t, test.depth, stats.DeltaDepth)
+		assert.Equal(t, test.size, stats.CompressedSize)
+	}
+}
+
+func TestDecodeStatsAreNotRecordedByDefault(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha := writeTestTree(t, db)
+	tree, err := db.Tree(sha)
+	require.NoError(t, err)
+	assert.Nil(t, tree.DecodeStats())
+}
@@ -0,0 +1,134 @@
+package gitobj
+
+import (
+	"bytes"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// Delta describes an object which is stored in a packfile as a delta of
+// another (see: DeltaBetween).
+type Delta struct {
+	// Oid is the name of the object stored as a delta, and Base the name
+	// of the object of which it is a delta.
+	Oid  []byte
+	Base []byte
+	// Size is the size of the delta's instructions, uncompressed.
+	Size int64
+	// PackedSize is the number of bytes occupied by the delta's entry in
+	// its packfile, including its header and compressed instructions.
+	PackedSize int64
+}
+
+// DeltaBetween returns how either of the objects named "a" and "b" is stored
+// as a delta of the other, or nil if neither is. Blobs stored as deltas of one
+// another are usually near-duplicates, and the size of the delta gives an idea
+// of how near, which makes large blobs which are so stored good candidates for
+// moving to Git LFS.
+//
+// Only the entries holding the objects themselves are considered, so an object
+// stored as a delta of a third object, which is a delta of the other, is not
+// reported. Where an object is stored more than once, the entry from which it
+// would be read is considered. Loose objects are never stored as deltas.
+// Replaced objects are considered as they are stored, rather than as they have
+// been replaced (see: ReplaceObjects).
+//
+// If either object is missing, an error satisfying errors.IsNoSuchObject is
+// returned.
+func (o *ObjectDatabase) DeltaBetween(a, b []byte) (*Delta, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	oids := [2][]byte{a, b}
+
+	var shas [2][]byte
+	for i, oid := range oids {
+		sha, err := o.storageOid(oid)
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := o.Has(sha)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.NoSuchObject(oid)
+		}
+		shas[i] = sha
+	}
+
+	for i, sha := range shas {
+		base, hdr, err := o.deltaBase(sha)
+		if err != nil {
+			return nil, err
+		}
+		if hdr == nil || !bytes.Equal(base, shas[1-i]) {
+			continue
+		}
+		return &Delta{
+			Oid:        oids[i],
+			Base:       oids[1-i],
+			Size:       int64(hdr.Size),
+			PackedSize: hdr.Length,
+		}, nil
+	}
+	return nil, nil
+}
+
+// deltaBase returns the name of the base of the packed entry holding the object
+// named "sha", and the entry's header, if it is a delta. Otherwise, it returns
+// a nil header.
+func (o *ObjectDatabase) deltaBase(sha []byte) ([]byte, *pack.EntryHeader, error) {
+	for _, s := range storages(o.ro) {
+		packs, ok := s.(*pack.Storage)
+		if !ok {
+			continue
+		}
+
+		for _, p := range packs.Packs() {
+			if p.Index() == nil {
+				continue
+			}
+
+			hdr, base, err := entryBase(p, sha)
+			if err != nil {
+				if pack.IsNotFound(err) {
+					continue
+				}
+				return nil, nil, err
+			}
+			if base == nil {
+				return nil, nil, nil
+			}
+			return base, hdr, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// entryBase returns the header of the entry holding the object named "sha" in
+// the packfile "p", along with the name of its base if it is a delta, or nil if
+// it is not. If the object is not in the packfile, an error satisfying
+// pack.IsNotFound is returned. The packfile must have an index.
+func entryBase(p *pack.Packfile, sha []byte) (*pack.EntryHeader, []byte, error) {
+	hdr, r, err := p.RawEntry(sha)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Close()
+
+	switch hdr.Type {
+	case pack.TypeObjectOffsetDelta:
+		base, err := p.NameAt(hdr.BaseOffset)
+		if err != nil {
+			return nil, nil, err
+		}
+		return hdr, base, nil
+	case pack.TypeObjectReferenceDelta:
+		return hdr, hdr.BaseName, nil
+	}
+	return hdr, nil, nil
+}
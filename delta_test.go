@@ -0,0 +1,116 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaBetweenReportsDeltas(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, base, world, bang := newDeltaTestDatabase(t, root)
+	defer db.Close()
+
+	for _, test := range []struct {
+		a, b []byte
+		want *Delta
+	}{
+		{world, base, &Delta{Oid: world, Base: base, Size: 15, PackedSize: 49}},
+		{base, world, &Delta{Oid: world, Base: base, Size: 15, PackedSize: 49}},
+		{bang, base, &Delta{Oid: bang, Base: base, Size: 8, PackedSize: 23}},
+		{world, bang, nil},
+	} {
+		got, err := db.DeltaBetween(test.a, test.b)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, got)
+	}
+}
+
+func TestDeltaBetweenMissingObject(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, base, _, _ := newDeltaTestDatabase(t, root)
+	defer db.Close()
+
+	_, err = db.DeltaBetween(base, make([]byte, 20))
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+// newDeltaTestDatabase returns a database in "root" holding a packfile holding the
+// blob "Hello", an OBJ_REF_DELTA of it holding "Hello, world!\n", and an
+// OBJ_OFS_DELTA of it holding "Hello!\n", along with their names. The database
+// is opened with the given options.
+func newDeltaTestDatabase(t *testing.T, root string, setters ...Option) (db *ObjectDatabase, base, world, bang []byte) {
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(data)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+	name := func(contents string) []byte {
+		sum := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", len(contents), contents)))
+		return sum[:]
+	}
+
+	base, world, bang = name("Hello"), name("Hello, world!\n"), name("Hello!\n")
+
+	data := []byte{'P', 'A', 'C', 'K', 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0, 0x3}
+
+	// An OBJ_BLOB holding "Hello".
+	baseOffset := len(data)
+	data = append(data, byte(pack.TypeBlob)<<4|5)
+	data = append(data, compress([]byte("Hello"))...)
+
+	// An OBJ_REF_DELTA appending ", world!\n" to it.
+	data = append(data, byte(pack.TypeObjectReferenceDelta)<<4|15)
+	data = append(data, base...)
+	data = append(data, compress([]byte{
+		0x05, 0x0e, 0x91, 0x00, 0x05,
+		0x09, ',', ' ', 'w', 'o', 'r', 'l', 'd', '!', '\n',
+	})...)
+
+	// An OBJ_OFS_DELTA appending "!\n" to it.
+	ofsOffset := len(data)
+	data = append(data, byte(pack.TypeObjectOffsetDelta)<<4|8)
+	data = append(data, byte(ofsOffset-baseOffset))
+	data = append(data, compress([]byte{
+		0x05, 0x07, 0x91, 0x00, 0x05,
+		0x02, '!', '\n',
+	})...)
+
+	sum := sha1.Sum(data)
+	data = append(data, sum[:]...)
+
+	iw, err := pack.IndexPackfile(bytes.NewReader(data), sha1.New())
+	require.NoError(t, err)
+	var idx bytes.Buffer
+	_, err = iw.WriteTo(&idx)
+	require.NoError(t, err)
+
+	prefix := filepath.Join(root, "pack", "pack-"+hex.EncodeToString(sum[:]))
+	require.NoError(t, os.MkdirAll(filepath.Dir(prefix), 0755))
+	require.NoError(t, ioutil.WriteFile(prefix+".pack", data, 0644))
+	require.NoError(t, ioutil.WriteFile(prefix+".idx", idx.Bytes(), 0644))
+
+	db, err = FromFilesystem(root, "", setters...)
+	require.NoError(t, err)
+
+	return db, base, world, bang
+}
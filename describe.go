@@ -0,0 +1,162 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// DefaultDescribeAbbrev is the number of hexadecimal characters of
+	// the described commit's object ID included in a Description.
+	DefaultDescribeAbbrev = 7
+
+	// maxDescribeCandidates is the number of tagged commits considered by
+	// Describe before the closest is chosen. It matches the default used
+	// by Git.
+	maxDescribeCandidates = 10
+)
+
+// Description is a human-readable name for a commit given relative to the
+// nearest tag in its history, as produced by `git describe`.
+type Description struct {
+	// Tag is the name of the nearest tag.
+	Tag string
+	// TagID is the object ID of the nearest tag.
+	TagID []byte
+	// Distance is the number of commits in the history of the described
+	// commit which are not in the history of the tagged commit. It is zero
+	// if the described commit is itself tagged.
+	Distance int
+	// Abbrev is the abbreviated, hex-encoded object ID of the described
+	// commit.
+	Abbrev string
+}
+
+// String implements the fmt.Stringer interface and formats the Description in
+// the same way as `git describe`. For instance:
+//
+//	v2.4.0-14-g2414721
+//
+// If the described commit is itself tagged, only the name of the tag is
+// returned.
+func (d *Description) String() string {
+	if d.Distance == 0 {
+		return d.Tag
+	}
+	return fmt.Sprintf("%s-%d-g%s", d.Tag, d.Distance, d.Abbrev)
+}
+
+// Describe returns a Description of the commit named by "sha" relative to the
+// nearest of the given annotated tags, where "tags" maps the hex-encoded
+// object ID of each tag to its name. Tags which point at other tags are
+// peeled, and tags which do not ultimately point at a commit are ignored.
+//
+// As with `git describe`, the history of the commit is walked in descending
+// order of committer date until a number of tagged commits have been found,
+// and the one fewest commits away is chosen. If more than one tag points at
+// that commit, the one with the most recent tagger date is used.
+//
+// If none of the tags are reachable from the commit, an error is returned.
+func (o *ObjectDatabase) Describe(sha []byte, tags map[string]string) (*Description, error) {
+	tagged, err := o.peelDescribeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates [][]byte
+	err = o.WalkCommits([][]byte{sha}, func(csha []byte, commit *Commit) error {
+		if _, ok := tagged[string(csha)]; !ok {
+			return nil
+		}
+
+		candidates = append(candidates, csha)
+		if len(candidates) == maxDescribeCandidates {
+			return StopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("gitobj: no tags can describe %x", sha)
+	}
+
+	best, distance := candidates[0], -1
+	for _, candidate := range candidates {
+		ahead, _, err := o.AheadBehind(sha, candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		if distance < 0 || ahead < distance {
+			best, distance = candidate, ahead
+		}
+	}
+
+	abbrev := hex.EncodeToString(sha)
+	if len(abbrev) > DefaultDescribeAbbrev {
+		abbrev = abbrev[:DefaultDescribeAbbrev]
+	}
+
+	tag := tagged[string(best)]
+	return &Description{
+		Tag:      tag.name,
+		TagID:    tag.sha,
+		Distance: distance,
+		Abbrev:   abbrev,
+	}, nil
+}
+
+// describeTag is an annotated tag considered by Describe.
+type describeTag struct {
+	sha  []byte
+	name string
+	// when is the tagger date of the tag, as a Unix timestamp.
+	when int64
+}
+
+// peelDescribeTags peels each of the given tags to the commit it ultimately
+// points at, and returns a mapping of each such commit's object ID to the
+// preferred tag which points at it.
+func (o *ObjectDatabase) peelDescribeTags(tags map[string]string) (map[string]*describeTag, error) {
+	tagged := make(map[string]*describeTag, len(tags))
+
+	for id, name := range tags {
+		sha, err := hex.DecodeString(id)
+		if err != nil {
+			return nil, fmt.Errorf("gitobj: invalid tag object ID %q: %s", id, err)
+		}
+
+		tag, err := o.Tag(sha)
+		if err != nil {
+			return nil, err
+		}
+
+		candidate := &describeTag{
+			sha:  sha,
+			name: name,
+			when: identTime(tag.Tagger),
+		}
+
+		for tag.ObjectType == TagObjectType {
+			if tag, err = o.Tag(tag.Object); err != nil {
+				return nil, err
+			}
+		}
+		if tag.ObjectType != CommitObjectType {
+			continue
+		}
+
+		key := string(tag.Object)
+		if existing, ok := tagged[key]; ok {
+			if existing.when > candidate.when ||
+				(existing.when == candidate.when && existing.name < candidate.name) {
+				continue
+			}
+		}
+		tagged[key] = candidate
+	}
+	return tagged, nil
+}
@@ -0,0 +1,76 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestTag writes an annotated tag of the given name pointing at the
+// object named by "sha", and returns the hex-encoded object ID of the tag.
+func writeTestTag(t *testing.T, db *ObjectDatabase, sha []byte, typ ObjectType, name string, when int64) string {
+	tag, err := db.WriteTag(&Tag{
+		Object:     sha,
+		ObjectType: typ,
+		Name:       name,
+		Tagger:     fmt.Sprintf("A U Thor <author@example.com> %d +0000", when),
+		Message:    name,
+	})
+	require.NoError(t, err)
+
+	return hex.EncodeToString(tag)
+}
+
+func TestDescribeNearestTag(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	c2 := writeTestCommit(t, db, map[string]string{"a.txt": "2"}, 200, c1)
+	c3 := writeTestCommit(t, db, map[string]string{"a.txt": "3"}, 300, c2)
+	c4 := writeTestCommit(t, db, map[string]string{"a.txt": "4"}, 400, c3)
+
+	tags := map[string]string{
+		writeTestTag(t, db, c1, CommitObjectType, "v1.0.0", 100): "v1.0.0",
+		writeTestTag(t, db, c2, CommitObjectType, "v1.1.0", 200): "v1.1.0",
+	}
+
+	d, err := db.Describe(c4, tags)
+	require.NoError(t, err)
+
+	assert.Equal(t, "v1.1.0", d.Tag)
+	assert.Equal(t, 2, d.Distance)
+	assert.Equal(t, hex.EncodeToString(c4)[:7], d.Abbrev)
+	assert.Equal(t, fmt.Sprintf("v1.1.0-2-g%s", d.Abbrev), d.String())
+}
+
+func TestDescribeExactMatch(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	outer := writeTestTag(t, db, c1, CommitObjectType, "v1.0.0", 100)
+	outerSha, _ := hex.DecodeString(outer)
+
+	tags := map[string]string{
+		writeTestTag(t, db, outerSha, TagObjectType, "v1.0.0-signed", 200): "v1.0.0-signed",
+		outer: "v1.0.0",
+	}
+
+	d, err := db.Describe(c1, tags)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, d.Distance)
+	assert.Equal(t, "v1.0.0-signed", d.String())
+}
+
+func TestDescribeWithoutTags(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	c1 := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+
+	d, err := db.Describe(c1, nil)
+	assert.Error(t, err)
+	assert.Nil(t, d)
+}
@@ -0,0 +1,128 @@
+package gitobj
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// ObjectLocation is a single place in which an object is stored.
+type ObjectLocation struct {
+	// Path is the path of the loose object file holding the object, or of
+	// the packfile holding it.
+	Path string
+	// Packed is true if the object is stored in a packfile, and false if
+	// it is stored as a loose object.
+	Packed bool
+	// Size is the number of bytes occupied by the object in this location:
+	// the size of the loose object file, or of its entry in the packfile.
+	Size int64
+}
+
+// DuplicateObject is an object which is stored in more than one location.
+type DuplicateObject struct {
+	// Oid is the name of the object.
+	Oid []byte
+	// Locations are the places in which the object is stored, in the order
+	// in which they are searched when reading the object.
+	Locations []*ObjectLocation
+	// Wasted is the number of bytes occupied by every copy of the object
+	// other than the smallest, which is the space that would be reclaimed
+	// by storing it only once.
+	Wasted int64
+}
+
+// Duplicates returns each object which is stored redundantly, either in more
+// than one packfile, or both in a packfile and as a loose object, in ascending
+// order of their names. Objects in alternate object databases are included.
+//
+// Staged objects which have not yet been flushed are not included, and nor are
+// objects held by storage other than the filesystem.
+func (o *ObjectDatabase) Duplicates() ([]*DuplicateObject, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	hashlen := o.Hasher().Size()
+	locations := make(map[string][]*ObjectLocation)
+
+	add := func(sha []byte, loc *ObjectLocation) {
+		if len(sha) != hashlen {
+			return
+		}
+		locations[string(sha)] = append(locations[string(sha)], loc)
+	}
+
+	for _, s := range storages(o.ro) {
+		var err error
+
+		switch s := s.(type) {
+		case *fileStorer:
+			err = s.each(func(sha []byte, path string, size int64) error {
+				add(sha, &ObjectLocation{Path: path, Size: size})
+				return nil
+			})
+		case *pack.Storage:
+			err = s.ForEach(func(p *pack.Packfile, hdr *pack.EntryHeader) error {
+				add(hdr.Name, &ObjectLocation{
+					Path:   p.Path(),
+					Packed: true,
+					Size:   hdr.Length,
+				})
+				return nil
+			})
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var dups []*DuplicateObject
+	for sha, locs := range locations {
+		if len(locs) < 2 {
+			continue
+		}
+
+		smallest := locs[0].Size
+		var total int64
+		for _, loc := range locs {
+			total += loc.Size
+			if loc.Size < smallest {
+				smallest = loc.Size
+			}
+		}
+
+		dups = append(dups, &DuplicateObject{
+			Oid:       []byte(sha),
+			Locations: locs,
+			Wasted:    total - smallest,
+		})
+	}
+
+	sort.Slice(dups, func(i, j int) bool {
+		return bytes.Compare(dups[i].Oid, dups[j].Oid) < 0
+	})
+	return dups, nil
+}
+
+// storages returns the individual storage implementations making up the given
+// storage, in the order in which they are searched.
+func storages(s storage.Storage) []storage.Storage {
+	type multi interface {
+		Storages() []storage.Storage
+	}
+
+	m, ok := s.(multi)
+	if !ok {
+		return []storage.Storage{s}
+	}
+
+	var all []storage.Storage
+	for _, impl := range m.Storages() {
+		all = append(all, storages(impl)...)
+	}
+	return all
+}
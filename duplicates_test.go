@@ -0,0 +1,76 @@
+package gitobj
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicatesAcrossPacksAndLooseObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	// write writes blobs with the given contents into a new packfile (or
+	// as loose objects), returning the name of the first.
+	write := func(setters []Option, contents ...string) []byte {
+		db, err := FromFilesystem(root, "", setters...)
+		require.NoError(t, err)
+		defer db.Close()
+
+		var shas [][]byte
+		for _, c := range contents {
+			sha, err := db.WriteBlob(NewBlobFromBytes([]byte(c)))
+			require.NoError(t, err)
+			shas = append(shas, sha)
+		}
+		require.NoError(t, db.Close())
+		return shas[0]
+	}
+
+	dup := write([]Option{PackedWrites()}, "duplicated\n")
+	write([]Option{PackedWrites()}, "duplicated\n", "unique\n")
+	write(nil, "duplicated\n")
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	dups, err := db.Duplicates()
+	require.NoError(t, err)
+	require.Len(t, dups, 1)
+
+	assert.Equal(t, dup, dups[0].Oid)
+	require.Len(t, dups[0].Locations, 3)
+
+	var packed int
+	var total, smallest int64 = 0, dups[0].Locations[0].Size
+	for _, loc := range dups[0].Locations {
+		if loc.Packed {
+			packed++
+		}
+		assert.FileExists(t, loc.Path)
+		assert.True(t, loc.Size > 0)
+
+		total += loc.Size
+		if loc.Size < smallest {
+			smallest = loc.Size
+		}
+	}
+	assert.Equal(t, 2, packed)
+	assert.Equal(t, total-smallest, dups[0].Wasted)
+}
+
+func TestDuplicatesWithoutDuplicates(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	_, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	dups, err := db.Duplicates()
+	assert.NoError(t, err)
+	assert.Empty(t, dups)
+}
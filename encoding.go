@@ -0,0 +1,185 @@
+package gitobj
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// charset is an 8-bit character encoding, in which commit messages may be
+// written (see: Commit.Encoding).
+type charset struct {
+	// high maps each byte from 0x80 to 0xff to the rune it encodes, or to
+	// utf8.RuneError if it encodes none. Bytes below 0x80 encode the ASCII
+	// character of the same value. If high is nil, such bytes are invalid.
+	high *[0x80]rune
+}
+
+var (
+	// latin1 maps bytes from 0x80 to 0xff to the runes of ISO-8859-1, which
+	// are those of the same value.
+	latin1 [0x80]rune
+	// cp1252 maps bytes from 0x80 to 0xff to the runes of Windows-1252,
+	// which are those of ISO-8859-1, save for 0x80 to 0x9f.
+	cp1252 [0x80]rune
+)
+
+func init() {
+	for i := range latin1 {
+		latin1[i] = rune(0x80 + i)
+	}
+
+	cp1252 = latin1
+	copy(cp1252[:0x20], []rune{
+		'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡',
+		'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+		utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—',
+		'˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+	})
+}
+
+// charsets maps the normalized name (see: normalizeEncoding) of each
+// encoding other than UTF-8 which may be transcoded to its charset.
+var charsets = map[string]*charset{
+	"ascii":       {},
+	"usascii":     {},
+	"iso88591":    {high: &latin1},
+	"latin1":      {high: &latin1},
+	"cp1252":      {high: &cp1252},
+	"windows1252": {high: &cp1252},
+}
+
+// normalizeEncoding returns the name of an encoding with its case and
+// punctuation removed, so that "ISO-8859-1" and "iso8859_1" are alike.
+func normalizeEncoding(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// isUTF8 returns whether the encoding with the given name is UTF-8, as Git
+// assumes a commit's message to be if the commit has no encoding.
+func isUTF8(name string) bool {
+	switch normalizeEncoding(name) {
+	case "", "utf8":
+		return true
+	}
+	return false
+}
+
+// Encoding returns the encoding of the commit's message, as given by its
+// "encoding" header, or "UTF-8" if it has none.
+func (c *Commit) Encoding() string {
+	if values := c.ExtraHeaderValues("encoding"); len(values) > 0 {
+		return values[0]
+	}
+	return "UTF-8"
+}
+
+// UTF8Message returns the commit's message, transcoded from its encoding (see:
+// Encoding) to UTF-8. UTF-8, US-ASCII, ISO-8859-1, and Windows-1252 are
+// supported.
+//
+// An error is returned if the message is not valid in its encoding (as when a
+// commit without an "encoding" header holds a message written in ISO-8859-1),
+// or if its encoding is not supported.
+func (c *Commit) UTF8Message() (string, error) {
+	enc := c.Encoding()
+	if isUTF8(enc) {
+		if !utf8.ValidString(c.Message) {
+			return "", fmt.Errorf("gitobj: commit message is not valid %s", enc)
+		}
+		return c.Message, nil
+	}
+
+	cs, ok := charsets[normalizeEncoding(enc)]
+	if !ok {
+		return "", fmt.Errorf("gitobj: unsupported commit encoding %q", enc)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(c.Message); i++ {
+		ch := c.Message[i]
+		if ch < 0x80 {
+			b.WriteByte(ch)
+			continue
+		}
+		if cs.high == nil || cs.high[ch-0x80] == utf8.RuneError {
+			return "", fmt.Errorf("gitobj: commit message is not valid %s", enc)
+		}
+		b.WriteRune(cs.high[ch-0x80])
+	}
+	return b.String(), nil
+}
+
+// SetUTF8Message sets the commit's message to the given UTF-8 message,
+// transcoded to the encoding with the given name (see: UTF8Message), and sets
+// the commit's "encoding" header to that name, replacing any it already has. If
+// the encoding is UTF-8, the message is set as-is, and the header is removed,
+// as Git writes no header for UTF-8 messages.
+//
+// An error is returned, and the commit left unchanged, if the message is not
+// valid UTF-8, or holds characters which the encoding cannot represent, or if
+// the encoding is not supported.
+func (c *Commit) SetUTF8Message(message, encoding string) error {
+	if !utf8.ValidString(message) {
+		return fmt.Errorf("gitobj: commit message is not valid UTF-8")
+	}
+
+	if isUTF8(encoding) {
+		c.setEncoding("")
+		c.Message = message
+		return nil
+	}
+
+	cs, ok := charsets[normalizeEncoding(encoding)]
+	if !ok {
+		return fmt.Errorf("gitobj: unsupported commit encoding %q", encoding)
+	}
+
+	encoded := make([]byte, 0, len(message))
+	for _, r := range message {
+		if r < 0x80 {
+			encoded = append(encoded, byte(r))
+			continue
+		}
+
+		ch := -1
+		if cs.high != nil && r != utf8.RuneError {
+			for i, hr := range cs.high {
+				if hr == r {
+					ch = 0x80 + i
+					break
+				}
+			}
+		}
+		if ch < 0 {
+			return fmt.Errorf("gitobj: cannot encode %q in %s", r, encoding)
+		}
+		encoded = append(encoded, byte(ch))
+	}
+
+	c.setEncoding(encoding)
+	c.Message = string(encoded)
+	return nil
+}
+
+// setEncoding sets the commit's "encoding" header to the given encoding, before
+// its other extra headers, where Git writes it, and removes any it already
+// has. If the encoding is empty, the header is only removed.
+func (c *Commit) setEncoding(encoding string) {
+	var headers []*ExtraHeader
+	if encoding != "" {
+		headers = append(headers, &ExtraHeader{K: "encoding", V: encoding})
+	}
+	for _, hdr := range c.ExtraHeaders {
+		if hdr.K != "encoding" {
+			headers = append(headers, hdr)
+		}
+	}
+	c.ExtraHeaders = headers
+}
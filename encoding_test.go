@@ -0,0 +1,101 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitEncodingDefaultsToUTF8(t *testing.T) {
+	c := &Commit{Message: "café"}
+	assert.Equal(t, "UTF-8", c.Encoding())
+
+	msg, err := c.UTF8Message()
+	require.NoError(t, err)
+	assert.Equal(t, "café", msg)
+}
+
+func TestCommitUTF8MessageTranscodes(t *testing.T) {
+	for enc, raw := range map[string]string{
+		"ISO-8859-1":   "caf\xe9 \xa3",
+		"latin1":       "caf\xe9 \xa3",
+		"Windows-1252": "caf\xe9 \xa3\x80",
+		"US-ASCII":     "cafe",
+	} {
+		c := &Commit{
+			ExtraHeaders: []*ExtraHeader{{K: "encoding", V: enc}},
+			Message:      raw,
+		}
+		assert.Equal(t, enc, c.Encoding(), enc)
+
+		msg, err := c.UTF8Message()
+		require.NoError(t, err, enc)
+
+		switch enc {
+		case "Windows-1252":
+			assert.Equal(t, "café £€", msg)
+		case "US-ASCII":
+			assert.Equal(t, "cafe", msg)
+		default:
+			assert.Equal(t, "café £", msg, enc)
+		}
+	}
+}
+
+func TestCommitUTF8MessageRejectsInvalidMessages(t *testing.T) {
+	for desc, c := range map[string]*Commit{
+		"latin1 without header": {Message: "caf\xe9"},
+		"undefined in cp1252": {
+			ExtraHeaders: []*ExtraHeader{{K: "encoding", V: "cp1252"}},
+			Message:      "\x81",
+		},
+		"non-ascii": {
+			ExtraHeaders: []*ExtraHeader{{K: "encoding", V: "ascii"}},
+			Message:      "caf\xe9",
+		},
+	} {
+		_, err := c.UTF8Message()
+		assert.Error(t, err, desc)
+	}
+
+	c := &Commit{
+		ExtraHeaders: []*ExtraHeader{{K: "encoding", V: "EUC-JP"}},
+		Message:      "x",
+	}
+	_, err := c.UTF8Message()
+	assert.EqualError(t, err, `gitobj: unsupported commit encoding "EUC-JP"`)
+}
+
+func TestCommitSetUTF8Message(t *testing.T) {
+	c := &Commit{
+		ExtraHeaders: []*ExtraHeader{
+			{K: "mergetag", V: "object a"},
+			{K: "encoding", V: "UTF-8"},
+		},
+	}
+
+	require.NoError(t, c.SetUTF8Message("café\n", "ISO-8859-1"))
+	assert.Equal(t, "caf\xe9\n", c.Message)
+	assert.Equal(t, []*ExtraHeader{
+		{K: "encoding", V: "ISO-8859-1"},
+		{K: "mergetag", V: "object a"},
+	}, c.ExtraHeaders)
+
+	msg, err := c.UTF8Message()
+	require.NoError(t, err)
+	assert.Equal(t, "café\n", msg)
+
+	require.NoError(t, c.SetUTF8Message("☃", "utf8"))
+	assert.Equal(t, "☃", c.Message)
+	assert.Equal(t, []*ExtraHeader{{K: "mergetag", V: "object a"}}, c.ExtraHeaders)
+}
+
+func TestCommitSetUTF8MessageRejectsUnrepresentableMessages(t *testing.T) {
+	c := &Commit{Message: "unchanged"}
+
+	err := c.SetUTF8Message("☃", "ISO-8859-1")
+	assert.EqualError(t, err, `gitobj: cannot encode '☃' in ISO-8859-1`)
+	assert.Equal(t, "unchanged", c.Message)
+	assert.Nil(t, c.ExtraHeaders)
+}
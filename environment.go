@@ -0,0 +1,133 @@
+package gitobj
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FromEnvironment constructs an *ObjectDatabase for the repository given by the
+// environment, as Git would when run with it (for instance, from a hook):
+//
+//   - the objects directory is GIT_OBJECT_DIRECTORY if it is set, otherwise
+//     the "objects" directory of the common directory, GIT_COMMON_DIR, if it
+//     is set, otherwise that of the repository, GIT_DIR;
+//   - if GIT_DIR is not set, the repository is found by searching the working
+//     directory and each of its parents, as by "git rev-parse --git-dir";
+//   - if GIT_COMMON_DIR is not set, the repository's "commondir" file is
+//     consulted, as it is for linked worktrees;
+//   - GIT_ALTERNATE_OBJECT_DIRECTORIES is used as by the Alternates option.
+//
+// Inside the "pre-receive" hook, Git receives pushed objects into a quarantine
+// directory, given by GIT_QUARANTINE_PATH (and GIT_OBJECT_DIRECTORY), until
+// the hook accepts them. The database is then opened in quarantine mode (see:
+// Quarantine): objects are read from both the quarantine directory and the
+// repository, and new objects are written into the quarantine directory, so
+// that they are discarded along with the push if it is rejected.
+//
+// The given options are applied as by FromFilesystem with the
+// EnvironmentOverrides option.
+func FromEnvironment(tmp string, setters ...Option) (*ObjectDatabase, error) {
+	root, err := objectsFromEnvironment(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	return FromFilesystem(root, tmp, append([]Option{EnvironmentOverrides()}, setters...)...)
+}
+
+// objectsFromEnvironment returns the objects directory given by the
+// environment, whose variables are read by "getenv" (see: FromEnvironment). If
+// a quarantine directory is given, the objects directory of the repository
+// itself is returned.
+func objectsFromEnvironment(getenv func(string) string) (string, error) {
+	if root := getenv("GIT_OBJECT_DIRECTORY"); len(root) > 0 && len(getenv("GIT_QUARANTINE_PATH")) == 0 {
+		return root, nil
+	}
+
+	gitdir := getenv("GIT_DIR")
+	if len(gitdir) == 0 {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		if gitdir, err = discoverGitDir(wd); err != nil {
+			return "", err
+		}
+	}
+
+	common := getenv("GIT_COMMON_DIR")
+	if len(common) == 0 {
+		var err error
+		if common, err = commonDir(gitdir); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(common, "objects"), nil
+}
+
+// discoverGitDir returns the Git directory of the repository containing "dir",
+// searching "dir" and each of its parents in turn for either a ".git" directory
+// (or file pointing to one), or a bare repository.
+func discoverGitDir(dir string) (string, error) {
+	for {
+		dotgit := filepath.Join(dir, ".git")
+		if fi, err := os.Stat(dotgit); err == nil {
+			if fi.Mode().IsRegular() {
+				return readGitFile(dotgit)
+			}
+			if isRepository(dotgit) {
+				return dotgit, nil
+			}
+		}
+		if isRepository(dir) {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("gitobj: not a git repository (or any of the parent directories)")
+		}
+		dir = parent
+	}
+}
+
+// readGitFile returns the Git directory named by the ".git" file at "path", as
+// is used by submodules and linked worktrees.
+func readGitFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimRight(string(data), "\r\n")
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return "", fmt.Errorf("gitobj: invalid gitfile format: %s", path)
+	}
+
+	gitdir := strings.TrimPrefix(line, "gitdir: ")
+	if !filepath.IsAbs(gitdir) {
+		gitdir = filepath.Join(filepath.Dir(path), gitdir)
+	}
+	return gitdir, nil
+}
+
+// commonDir returns the directory holding the objects (among other things) of
+// the Git directory "gitdir": either that named by its "commondir" file, if it
+// has one, or "gitdir" itself.
+func commonDir(gitdir string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(gitdir, "commondir"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return gitdir, nil
+		}
+		return "", err
+	}
+
+	common := strings.TrimRight(string(data), "\r\n")
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitdir, common)
+	}
+	return common, nil
+}
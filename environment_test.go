@@ -0,0 +1,300 @@
+package gitobj
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectsFromEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-environment")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gitdir := filepath.Join(dir, "repo", ".git")
+	writeTestRepository(t, gitdir)
+
+	worktree := filepath.Join(gitdir, "worktrees", "wt")
+	require.NoError(t, os.MkdirAll(worktree, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(worktree, "commondir"),
+		[]byte("../..\n"), 0644))
+
+	for _, test := range []struct {
+		env  map[string]string
+		root string
+	}{
+		{
+			env:  map[string]string{"GIT_DIR": gitdir},
+			root: filepath.Join(gitdir, "objects"),
+		},
+		{
+			env:  map[string]string{"GIT_DIR": worktree},
+			root: filepath.Join(gitdir, "objects"),
+		},
+		{
+			env: map[string]string{
+				"GIT_DIR":        worktree,
+				"GIT_COMMON_DIR": filepath.Join(dir, "common"),
+			},
+			root: filepath.Join(dir, "common", "objects"),
+		},
+		{
+			env: map[string]string{
+				"GIT_DIR":                          gitdir,
+				"GIT_OBJECT_DIRECTORY":             filepath.Join(dir, "incoming"),
+				"GIT_ALTERNATE_OBJECT_DIRECTORIES": filepath.Join(gitdir, "objects"),
+			},
+			root: filepath.Join(dir, "incoming"),
+		},
+		{
+			env: map[string]string{
+				"GIT_DIR":              gitdir,
+				"GIT_OBJECT_DIRECTORY": filepath.Join(dir, "quarantine"),
+				"GIT_QUARANTINE_PATH":  filepath.Join(dir, "quarantine"),
+			},
+			root: filepath.Join(gitdir, "objects"),
+		},
+	} {
+		root, err := objectsFromEnvironment(func(k string) string {
+			return test.env[k]
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, test.root, root, "%v", test.env)
+	}
+}
+
+func TestDiscoverGitDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-environment")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gitdir := filepath.Join(dir, "repo", ".git")
+	writeTestRepository(t, gitdir)
+	bare := filepath.Join(dir, "bare.git")
+	writeTestRepository(t, bare)
+
+	sub := filepath.Join(dir, "repo", "a", "b")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+
+	linked := filepath.Join(dir, "linked")
+	require.NoError(t, os.MkdirAll(linked, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(linked, ".git"),
+		[]byte("gitdir: ../repo/.git/worktrees/linked\n"), 0644))
+
+	for _, test := range []struct {
+		dir    string
+		gitdir string
+	}{
+		{filepath.Join(dir, "repo"), gitdir},
+		{sub, gitdir},
+		{bare, bare},
+		{linked, filepath.Join(gitdir, "worktrees", "linked")},
+	} {
+		got, err := discoverGitDir(test.dir)
+		require.NoError(t, err)
+		assert.Equal(t, test.gitdir, got, test.dir)
+	}
+
+	_, err = discoverGitDir(dir)
+	assert.EqualError(t, err, "gitobj: not a git repository (or any of the parent directories)")
+}
+
+func TestFromEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-environment")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gitdir := filepath.Join(dir, ".git")
+	writeTestRepository(t, gitdir)
+
+	defer setTestEnvironment(map[string]string{"GIT_DIR": gitdir})()
+
+	db, err := FromEnvironment("")
+	require.NoError(t, err)
+	defer db.Close()
+
+	root, ok := db.Root()
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(gitdir, "objects"), root)
+}
+
+func TestEnvironmentOverrides(t *testing.T) {
+	for _, test := range []struct {
+		env        map[string]string
+		alternates string
+		quarantine string
+		root       string
+		want       string
+		wantQ      string
+	}{
+		{
+			env:  map[string]string{},
+			root: "objects",
+			want: "objects",
+		},
+		{
+			env:  map[string]string{"GIT_OBJECT_DIRECTORY": "incoming"},
+			root: "objects",
+			want: "incoming",
+		},
+		{
+			env:        map[string]string{"GIT_ALTERNATE_OBJECT_DIRECTORIES": `"a"`},
+			alternates: "b",
+			root:       "objects",
+			want:       "objects",
+		},
+		{
+			env: map[string]string{
+				"GIT_OBJECT_DIRECTORY": "quarantine",
+				"GIT_QUARANTINE_PATH":  "quarantine",
+			},
+			root:  "objects",
+			want:  "objects",
+			wantQ: "quarantine",
+		},
+		{
+			env:        map[string]string{"GIT_QUARANTINE_PATH": "quarantine"},
+			quarantine: "explicit",
+			root:       "objects",
+			want:       "objects",
+			wantQ:      "explicit",
+		},
+	} {
+		args := newOptions([]Option{Alternates(test.alternates), Quarantine(test.quarantine)})
+
+		root := args.applyEnvironment(test.root, func(k string) string {
+			return test.env[k]
+		})
+		assert.Equal(t, test.want, root)
+		assert.Equal(t, test.wantQ, args.quarantine)
+
+		want := test.alternates
+		if alternates := test.env["GIT_ALTERNATE_OBJECT_DIRECTORIES"]; len(alternates) > 0 {
+			want = test.alternates + alternatesSeparator + alternates
+		}
+		assert.Equal(t, want, args.alternates)
+	}
+}
+
+func TestFromFilesystemWithEnvironmentOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-environment")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "objects")
+	incoming := filepath.Join(dir, "incoming")
+	for _, d := range []string{root, incoming} {
+		require.NoError(t, os.MkdirAll(d, 0755))
+	}
+
+	adb, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	shared, err := adb.WriteBlob(NewBlobFromBytes([]byte("shared\n")))
+	require.NoError(t, err)
+	require.NoError(t, adb.Close())
+
+	defer setTestEnvironment(map[string]string{
+		"GIT_OBJECT_DIRECTORY":             incoming,
+		"GIT_ALTERNATE_OBJECT_DIRECTORIES": root,
+	})()
+
+	db, err := FromFilesystem(root, "", EnvironmentOverrides())
+	require.NoError(t, err)
+	defer db.Close()
+
+	got, ok := db.Root()
+	assert.True(t, ok)
+	assert.Equal(t, incoming, got)
+
+	b, err := db.Blob(shared)
+	require.NoError(t, err)
+	assert.NoError(t, b.Close())
+
+	// Without the option, the environment is ignored.
+	plain, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer plain.Close()
+
+	got, _ = plain.Root()
+	assert.Equal(t, root, got)
+}
+
+func TestFromEnvironmentInQuarantine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-environment")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gitdir := filepath.Join(dir, ".git")
+	writeTestRepository(t, gitdir)
+
+	objects := filepath.Join(gitdir, "objects")
+	quarantine := filepath.Join(objects, "incoming-abc123")
+	require.NoError(t, os.MkdirAll(quarantine, 0755))
+
+	adb, err := FromFilesystem(objects, "")
+	require.NoError(t, err)
+	existing, err := adb.WriteBlob(NewBlobFromBytes([]byte("existing\n")))
+	require.NoError(t, err)
+	require.NoError(t, adb.Close())
+
+	defer setTestEnvironment(map[string]string{
+		"GIT_DIR":                          gitdir,
+		"GIT_OBJECT_DIRECTORY":             quarantine,
+		"GIT_ALTERNATE_OBJECT_DIRECTORIES": objects,
+		"GIT_QUARANTINE_PATH":              quarantine,
+	})()
+
+	db, err := FromEnvironment("")
+	require.NoError(t, err)
+	defer db.Close()
+
+	root, ok := db.Root()
+	assert.True(t, ok)
+	assert.Equal(t, quarantine, root)
+
+	b, err := db.Blob(existing)
+	require.NoError(t, err)
+	assert.NoError(t, b.Close())
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("incoming\n")))
+	require.NoError(t, err)
+
+	hex := fmt.Sprintf("%x", sha)
+	_, err = os.Stat(filepath.Join(quarantine, hex[:2], hex[2:]))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(objects, hex[:2], hex[2:]))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// setTestEnvironment sets the given environment variables, and clears the
+// others which name a repository, returning a function which restores their
+// previous values.
+func setTestEnvironment(env map[string]string) func() {
+	var restore []func()
+	for _, k := range []string{"GIT_DIR", "GIT_OBJECT_DIRECTORY", "GIT_COMMON_DIR", "GIT_ALTERNATE_OBJECT_DIRECTORIES", "GIT_QUARANTINE_PATH"} {
+		k := k
+		if v, ok := os.LookupEnv(k); ok {
+			restore = append(restore, func() { os.Setenv(k, v) })
+		} else {
+			restore = append(restore, func() { os.Unsetenv(k) })
+		}
+
+		if v, ok := env[k]; ok {
+			os.Setenv(k, v)
+		} else {
+			os.Unsetenv(k)
+		}
+	}
+
+	return func() {
+		for _, fn := range restore {
+			fn()
+		}
+	}
+}
@@ -1,18 +1,267 @@
 package gitobj
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDatabaseClosed is returned when reading from an *ObjectDatabase
+	// (or a view of one) which has been closed.
+	//
+	// Its message is retained from earlier versions, which returned an
+	// otherwise-identical untyped error.
+	ErrDatabaseClosed = errors.New("gitobj: cannot use closed *pack.Set")
+)
+
+// UnknownObjectFormat is an error type returned when opening a database with,
+// or decoding objects in, an object format which has not been registered (see:
+// ObjectFormat and RegisterObjectFormat).
+type UnknownObjectFormat struct {
+	// Format is the name of the object format.
+	Format ObjectFormatAlgorithm
+}
+
+// Error implements the error.Error() function.
+func (e *UnknownObjectFormat) Error() string {
+	return fmt.Sprintf("gitobj: unknown object format %q", e.Format)
+}
+
+// InvalidSignature is an error type returned when writing a commit or tag whose
+// author, committer, or tagger identity has a malformed or out-of-range
+// timestamp or timezone offset (see: StrictSignatures).
+type InvalidSignature struct {
+	// Header is the name of the header holding the identity: "author",
+	// "committer", or "tagger", or "signature" if it was given to
+	// ParseSignature.
+	Header string
+	// Ident is the identity itself.
+	Ident string
+	// Reason describes what is wrong with the identity.
+	Reason string
+}
+
+// Error implements the error.Error() function.
+func (e *InvalidSignature) Error() string {
+	return fmt.Sprintf("gitobj: invalid %s %q: %s", e.Header, e.Ident, e.Reason)
+}
+
+// UnknownObjectTypeError is an error type returned when parsing the name of an
+// object type which is not known (see: ParseObjectType), or when reading an
+// object whose header gives one (see: StrictObjectTypes).
+type UnknownObjectTypeError struct {
+	// Type is the name of the type, exactly as it was given.
+	Type []byte
+}
+
+// Error implements the error.Error() function.
+func (e *UnknownObjectTypeError) Error() string {
+	return fmt.Sprintf("gitobj: unknown object type %q", e.Type)
+}
+
+// InvalidObject is an error type returned when writing a tree, commit, or tag
+// which Git would reject (see: StrictWrites).
+type InvalidObject struct {
+	// Type is the type of the object.
+	Type ObjectType
+	// Findings are the problems found with the object. Since the object
+	// has not been written, their Oid fields are nil.
+	Findings []*VerifyFinding
+}
+
+// Error implements the error.Error() function.
+func (e *InvalidObject) Error() string {
+	f := e.Findings[0]
+	msg := fmt.Sprintf("gitobj: invalid %s: %s: %s", e.Type, f.ID, f.Message)
+	if len(e.Findings) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Findings)-1)
+	}
+	return msg
+}
+
+// CorruptLooseObject is an error type returned when a loose object cannot be
+// inflated, as when it was truncated by a crash mid-write, and no intact copy of
+// it is held elsewhere in the database (see: RecoverTruncatedObjects).
+type CorruptLooseObject struct {
+	// Oid is the name of the object.
+	Oid []byte
+	// Path is the path of the file holding the loose object.
+	Path string
+	// Err is the error encountered in inflating the object.
+	Err error
+}
+
+// Error implements the error.Error() function.
+func (e *CorruptLooseObject) Error() string {
+	return fmt.Sprintf("gitobj: corrupt loose object %x at %s: %s", e.Oid, e.Path, e.Err)
+}
+
+// Unwrap returns the error encountered in inflating the object.
+func (e *CorruptLooseObject) Unwrap() error {
+	return e.Err
+}
+
+// ObjectError is the failure of an operation over many objects (such as
+// VerifyAll) to read a single object, as collected in an *ObjectErrors.
+type ObjectError struct {
+	// Oid is the name of the object.
+	Oid []byte
+	// Source is the path of the packfile, or of the loose object, holding
+	// the object, or empty if it is not known (see: DecodeStats).
+	Source string
+	// Err is the error encountered in reading the object.
+	Err error
+}
+
+// Error implements the error.Error() function.
+func (e *ObjectError) Error() string {
+	if len(e.Source) == 0 {
+		return fmt.Sprintf("gitobj: object %x: %s", e.Oid, e.Err)
+	}
+	return fmt.Sprintf("gitobj: object %x in %s: %s", e.Oid, e.Source, e.Err)
+}
+
+// Unwrap returns the error encountered in reading the object.
+func (e *ObjectError) Unwrap() error {
+	return e.Err
+}
+
+// ObjectErrors is an error type returned by operations over many objects (such
+// as VerifyAll) which continue past objects which cannot be read, so that a
+// single bad object does not abort the whole operation. It holds each failure,
+// and the number of objects for which the operation succeeded.
+type ObjectErrors struct {
+	// Errors holds each object which could not be read, in the order in
+	// which they were found.
+	Errors []*ObjectError
+	// Succeeded is the number of objects which were read successfully.
+	Succeeded int
+}
+
+// Error implements the error.Error() function.
+func (e *ObjectErrors) Error() string {
+	msg := e.Errors[0].Error()
+	if len(e.Errors) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Errors)-1)
+	}
+	return fmt.Sprintf("%s; %d object(s) read successfully", msg, e.Succeeded)
+}
+
+// add records the failure to read the object named "sha" from "source".
+func (e *ObjectErrors) add(sha []byte, source string, err error) {
+	e.Errors = append(e.Errors, &ObjectError{Oid: sha, Source: source, Err: err})
+}
+
+// err returns the *ObjectErrors, or nil if no failures were recorded.
+func (e *ObjectErrors) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// CommitGraphMismatch is an error type returned when the metadata of a commit
+// recorded in a commit-graph differs from that of the commit itself (see:
+// CheckCommitGraph and VerifyCommitGraph).
+type CommitGraphMismatch struct {
+	// Oid is the name of the commit.
+	Oid []byte
+	// Field names the metadata which differs: "tree", "parents", "commit
+	// time", or "generation".
+	Field string
+	// Graph is the value recorded in the commit-graph.
+	Graph string
+	// Commit is the value given by the commit itself.
+	Commit string
+}
+
+// Error implements the error.Error() function.
+func (e *CommitGraphMismatch) Error() string {
+	return fmt.Sprintf("gitobj: commit-graph has %s %s for commit %x, expected %s",
+		e.Field, e.Graph, e.Oid, e.Commit)
+}
+
+// DisallowedObjectType is an error type returned when opening an object whose
+// type the database has not been configured to decode (see: AllowedTypes).
+type DisallowedObjectType struct {
+	// Oid is the name of the object.
+	Oid []byte
+	// Type is the type of the object.
+	Type ObjectType
+}
+
+// Error implements the error.Error() function.
+func (e *DisallowedObjectType) Error() string {
+	return fmt.Sprintf("gitobj: object %x has disallowed type %q", e.Oid, e.Type)
+}
+
+// ObjectTooLarge is an error type returned when opening an object whose header
+// declares a greater size than the database has been configured to read (see:
+// MaxObjectSize). Packed objects are reported before any memory is allocated to
+// unpack them.
+type ObjectTooLarge struct {
+	// Oid is the name of the object, if known.
+	Oid []byte
+	// Size is the size declared by the object's header.
+	Size uint64
+	// Limit is the greatest size of object which may be read.
+	Limit int64
+}
+
+// Error implements the error.Error() function.
+func (e *ObjectTooLarge) Error() string {
+	return fmt.Sprintf("gitobj: object %x has %d byte(s), exceeding limit of %d",
+		e.Oid, e.Size, e.Limit)
+}
+
+// MissingObject is an error type returned by a connectivity check (see:
+// CheckConnectivity) when an object reachable from one of its tips is missing.
+type MissingObject struct {
+	// Oid is the name of the missing object.
+	Oid []byte
+	// ReferencedBy is the name of the object which refers to the missing
+	// object, or nil if it is itself one of the tips.
+	ReferencedBy []byte
+}
+
+// Error implements the error.Error() function.
+func (e *MissingObject) Error() string {
+	if e.ReferencedBy == nil {
+		return fmt.Sprintf("gitobj: missing object %x", e.Oid)
+	}
+	return fmt.Sprintf("gitobj: missing object %x, referenced by %x", e.Oid, e.ReferencedBy)
+}
+
+// PathNotFound is an error type returned by EntryAtPath when there is no entry
+// at the given path.
+type PathNotFound struct {
+	// Tree is the name of the tree searched.
+	Tree []byte
+	// Path is the path which was not found.
+	Path string
+}
+
+// Error implements the error.Error() function.
+func (e *PathNotFound) Error() string {
+	return fmt.Sprintf("gitobj: path %q not found in tree %x", e.Path, e.Tree)
+}
 
 // UnexpectedObjectType is an error type that represents a scenario where an
 // object was requested of a given type "Wanted", and received as a different
-// _other_ type, "Wanted".
+// _other_ type, "Got".
 type UnexpectedObjectType struct {
-	// Got was the object type requested.
+	// Oid is the name of the object, if known.
+	Oid []byte
+	// Got was the object type received.
 	Got ObjectType
-	// Wanted was the object type received.
+	// Wanted was the object type requested.
 	Wanted ObjectType
 }
 
 // Error implements the error.Error() function.
 func (e *UnexpectedObjectType) Error() string {
+	if e.Oid != nil {
+		return fmt.Sprintf("gitobj: unexpected object type for %x, got: %q, wanted: %q", e.Oid, e.Got, e.Wanted)
+	}
 	return fmt.Sprintf("gitobj: unexpected object type, got: %q, wanted: %q", e.Got, e.Wanted)
 }
@@ -4,6 +4,15 @@ import (
 	"fmt"
 )
 
+var (
+	// ErrNoSuchObject is the error which errors satisfying IsNoSuchObject
+	// match when compared with errors.Is.
+	ErrNoSuchObject = fmt.Errorf("gitobj: no such object")
+	// ErrMissingPromisorObject is the error which errors satisfying
+	// IsMissingPromisorObject match when compared with errors.Is.
+	ErrMissingPromisorObject = fmt.Errorf("gitobj: missing promisor object")
+)
+
 // noSuchObject is an error type that occurs when no object with a given object
 // ID is available.
 type noSuchObject struct {
@@ -15,14 +24,78 @@ func (e *noSuchObject) Error() string {
 	return fmt.Sprintf("gitobj: no such object: %x", e.oid)
 }
 
+// Is returns whether the error matches "target" (see: ErrNoSuchObject).
+func (e *noSuchObject) Is(target error) bool {
+	return target == ErrNoSuchObject
+}
+
 // NoSuchObject creates a new error representing a missing object with a given
 // object ID.
 func NoSuchObject(oid []byte) error {
 	return &noSuchObject{oid: oid}
 }
 
-// IsNoSuchObject indicates whether an error is a noSuchObject and is non-nil.
+// IsNoSuchObject indicates whether an error is a noSuchObject and is non-nil,
+// or wraps one (that is, returns one from its Unwrap method).
+//
+// Since a missing promisor object is also missing, it is true of an error
+// satisfying IsMissingPromisorObject, too.
 func IsNoSuchObject(e error) bool {
-	err, ok := e.(*noSuchObject)
-	return ok && err != nil
+	for ; e != nil; e = unwrap(e) {
+		switch err := e.(type) {
+		case *noSuchObject:
+			return err != nil
+		case *missingPromisorObject:
+			return err != nil
+		}
+	}
+	return false
+}
+
+// missingPromisorObject is an error type that occurs when no object with a given
+// object ID is available in a partial clone, and so may instead be fetched
+// from a promisor remote.
+type missingPromisorObject struct {
+	oid []byte
+}
+
+// Error implements the error.Error() function.
+func (e *missingPromisorObject) Error() string {
+	return fmt.Sprintf("gitobj: missing promisor object: %x", e.oid)
+}
+
+// Is returns whether the error matches "target". Since a missing promisor object
+// is also missing, it matches ErrNoSuchObject, as well as
+// ErrMissingPromisorObject.
+func (e *missingPromisorObject) Is(target error) bool {
+	return target == ErrMissingPromisorObject || target == ErrNoSuchObject
+}
+
+// MissingPromisorObject creates a new error representing a missing object with
+// a given object ID, which may be available from a promisor remote.
+func MissingPromisorObject(oid []byte) error {
+	return &missingPromisorObject{oid: oid}
+}
+
+// IsMissingPromisorObject indicates whether an error is a missingPromisorObject
+// and is non-nil, or wraps one.
+func IsMissingPromisorObject(e error) bool {
+	for ; e != nil; e = unwrap(e) {
+		if err, ok := e.(*missingPromisorObject); ok {
+			return err != nil
+		}
+	}
+	return false
+}
+
+// unwrap returns the error wrapped by "e", or nil if it does not wrap one.
+func unwrap(e error) error {
+	type wrapper interface {
+		Unwrap() error
+	}
+
+	if w, ok := e.(wrapper); ok {
+		return w.Unwrap()
+	}
+	return nil
 }
@@ -22,3 +22,52 @@ func TestIsNoSuchObjectNilHandling(t *testing.T) {
 	assert.Equal(t, IsNoSuchObject((*noSuchObject)(nil)), false)
 	assert.Equal(t, IsNoSuchObject(nil), false)
 }
+
+func TestMissingPromisorObjectTypeErrFormatting(t *testing.T) {
+	sha := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	oid, err := hex.DecodeString(sha)
+	assert.NoError(t, err)
+
+	err = MissingPromisorObject(oid)
+
+	assert.Equal(t, "gitobj: missing promisor object: aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", err.Error())
+	assert.Equal(t, IsMissingPromisorObject(err), true)
+	assert.Equal(t, IsNoSuchObject(err), true)
+	assert.Equal(t, IsMissingPromisorObject(NoSuchObject(oid)), false)
+}
+
+func TestIsMissingPromisorObjectNilHandling(t *testing.T) {
+	assert.Equal(t, IsMissingPromisorObject((*missingPromisorObject)(nil)), false)
+	assert.Equal(t, IsNoSuchObject((*missingPromisorObject)(nil)), false)
+	assert.Equal(t, IsMissingPromisorObject(nil), false)
+}
+
+func TestNoSuchObjectErrorsMatchSentinels(t *testing.T) {
+	type is interface {
+		Is(error) bool
+	}
+
+	missing := NoSuchObject([]byte{0xaa}).(is)
+	assert.True(t, missing.Is(ErrNoSuchObject))
+	assert.False(t, missing.Is(ErrMissingPromisorObject))
+
+	promisor := MissingPromisorObject([]byte{0xaa}).(is)
+	assert.True(t, promisor.Is(ErrNoSuchObject))
+	assert.True(t, promisor.Is(ErrMissingPromisorObject))
+}
+
+func TestIsNoSuchObjectUnwrapsErrors(t *testing.T) {
+	err := &wrappedErr{&wrappedErr{MissingPromisorObject([]byte{0xaa})}}
+
+	assert.True(t, IsNoSuchObject(err))
+	assert.True(t, IsMissingPromisorObject(err))
+	assert.False(t, IsNoSuchObject(&wrappedErr{nil}))
+}
+
+// wrappedErr is an error wrapping another.
+type wrappedErr struct {
+	err error
+}
+
+func (w *wrappedErr) Error() string { return "wrapped" }
+func (w *wrappedErr) Unwrap() error { return w.err }
@@ -1,9 +1,11 @@
 package gitobj
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUnexpectedObjectTypeErrFormatting(t *testing.T) {
@@ -13,3 +15,49 @@ func TestUnexpectedObjectTypeErrFormatting(t *testing.T) {
 
 	assert.Equal(t, "gitobj: unexpected object type, got: \"tree\", wanted: \"blob\"", err.Error())
 }
+
+func TestUnexpectedObjectTypeErrFormattingWithOid(t *testing.T) {
+	err := &UnexpectedObjectType{
+		Oid: []byte{0xde, 0xad, 0xbe, 0xef},
+		Got: TreeObjectType, Wanted: CommitObjectType,
+	}
+
+	assert.Equal(t, "gitobj: unexpected object type for deadbeef, got: \"tree\", wanted: \"commit\"", err.Error())
+}
+
+func TestDisallowedObjectTypeErrFormatting(t *testing.T) {
+	err := &DisallowedObjectType{
+		Oid: []byte{0xde, 0xad, 0xbe, 0xef}, Type: TreeObjectType,
+	}
+
+	assert.Equal(t, "gitobj: object deadbeef has disallowed type \"tree\"", err.Error())
+}
+
+func TestObjectErrorsErrFormatting(t *testing.T) {
+	err := &ObjectErrors{
+		Errors: []*ObjectError{
+			{Oid: []byte{0xde, 0xad}, Source: "objects/de/ad", Err: errors.New("corrupt")},
+			{Oid: []byte{0xbe, 0xef}, Err: errors.New("missing")},
+		},
+		Succeeded: 3,
+	}
+
+	assert.Equal(t, "gitobj: object dead in objects/de/ad: corrupt (and 1 more); 3 object(s) read successfully", err.Error())
+	assert.Equal(t, "gitobj: object beef: missing", err.Errors[1].Error())
+}
+
+func TestErrorsUnwrapTheirCauses(t *testing.T) {
+	cause := errors.New("cause")
+
+	assert.Equal(t, cause, (&CorruptLooseObject{Err: cause}).Unwrap())
+	assert.Equal(t, cause, (&ObjectError{Err: cause}).Unwrap())
+}
+
+func TestUnknownObjectFormatErr(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	_, err = FromBackend(b, ObjectFormat("md5"))
+	assert.Equal(t, &UnknownObjectFormat{Format: "md5"}, err)
+	assert.EqualError(t, err, `gitobj: unknown object format "md5"`)
+}
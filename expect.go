@@ -0,0 +1,87 @@
+package gitobj
+
+import "context"
+
+// ExpectCommit returns the commit named by "sha", peeling any annotated tags
+// which point (perhaps indirectly) at it, as with "<rev>^{commit}".
+//
+// If "sha" does not ultimately name a commit, an *UnexpectedObjectType is
+// returned naming the object which was found instead, and its type.
+func (o *ObjectDatabase) ExpectCommit(sha []byte) (*Commit, error) {
+	sha, typ, err := o.peel(sha)
+	if err != nil {
+		return nil, err
+	}
+	if typ != CommitObjectType {
+		return nil, &UnexpectedObjectType{Oid: sha, Got: typ, Wanted: CommitObjectType}
+	}
+	return o.Commit(sha)
+}
+
+// ExpectTree returns the tree named by "sha", peeling any annotated tags which
+// point (perhaps indirectly) at it, as with "<rev>^{tree}". If "sha" names a
+// commit (or a tag of one), the commit's tree is returned.
+//
+// If "sha" does not ultimately name a tree or commit, an *UnexpectedObjectType
+// is returned naming the object which was found instead, and its type.
+func (o *ObjectDatabase) ExpectTree(sha []byte) (*Tree, error) {
+	sha, typ, err := o.peel(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case CommitObjectType:
+		commit, err := o.Commit(sha)
+		if err != nil {
+			return nil, err
+		}
+		return o.Tree(commit.TreeID)
+	case TreeObjectType:
+		return o.Tree(sha)
+	}
+	return nil, &UnexpectedObjectType{Oid: sha, Got: typ, Wanted: TreeObjectType}
+}
+
+// ExpectBlob returns the blob named by "sha", peeling any annotated tags which
+// point (perhaps indirectly) at it, as with "<rev>^{blob}".
+//
+// If "sha" does not ultimately name a blob, an *UnexpectedObjectType is
+// returned naming the object which was found instead, and its type.
+func (o *ObjectDatabase) ExpectBlob(sha []byte) (*Blob, error) {
+	sha, typ, err := o.peel(sha)
+	if err != nil {
+		return nil, err
+	}
+	if typ != BlobObjectType {
+		return nil, &UnexpectedObjectType{Oid: sha, Got: typ, Wanted: BlobObjectType}
+	}
+	return o.Blob(sha)
+}
+
+// peel follows the chain of annotated tags beginning with the object named
+// "sha", if it is a tag, and returns the name and type of the first object in
+// the chain which is not. Only the header of that object is read.
+func (o *ObjectDatabase) peel(sha []byte) ([]byte, ObjectType, error) {
+	for {
+		r, err := o.open(context.Background(), sha)
+		if err != nil {
+			return nil, UnknownObjectType, err
+		}
+		if err := o.checkAllowed(sha, r); err != nil {
+			return nil, UnknownObjectType, err
+		}
+
+		typ, _, err := r.Header()
+		if err != nil || typ != TagObjectType {
+			r.Close()
+			return sha, typ, err
+		}
+
+		var tag Tag
+		if err := o.decode(r, &tag); err != nil {
+			return nil, UnknownObjectType, err
+		}
+		sha = tag.Object
+	}
+}
@@ -0,0 +1,101 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectCommitPeelsTags(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha := writeTestCommit(t, db, map[string]string{"a.txt": "a"}, 1000)
+	tag := writeTestTagID(t, db, sha, CommitObjectType, "v1", 1000)
+	outer := writeTestTagID(t, db, tag, TagObjectType, "v1-outer", 1000)
+
+	for _, name := range [][]byte{sha, tag, outer} {
+		commit, err := db.ExpectCommit(name)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1000, committerTime(commit))
+	}
+}
+
+func TestExpectCommitWithATree(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	tree := writeTestTree(t, db)
+	tag := writeTestTagID(t, db, tree, TreeObjectType, "v1", 1000)
+
+	_, err := db.ExpectCommit(tag)
+	require.Error(t, err)
+
+	e, ok := err.(*UnexpectedObjectType)
+	require.True(t, ok)
+	assert.Equal(t, tree, e.Oid)
+	assert.Equal(t, TreeObjectType, e.Got)
+	assert.Equal(t, CommitObjectType, e.Wanted)
+}
+
+func TestExpectTreePeelsTagsAndCommits(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha := writeTestCommit(t, db, map[string]string{"a.txt": "a"}, 1000)
+	commit, err := db.Commit(sha)
+	require.NoError(t, err)
+
+	tag := writeTestTagID(t, db, sha, CommitObjectType, "v1", 1000)
+	treeTag := writeTestTagID(t, db, commit.TreeID, TreeObjectType, "v1-tree", 1000)
+
+	for _, name := range [][]byte{sha, tag, commit.TreeID, treeTag} {
+		tree, err := db.ExpectTree(name)
+		require.NoError(t, err)
+		require.Len(t, tree.Entries, 1)
+		assert.Equal(t, "a.txt", tree.Entries[0].Name)
+	}
+}
+
+func TestExpectTreeWithABlob(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob := writeTestBlob(t, db)
+
+	_, err := db.ExpectTree(blob)
+	assert.Equal(t, &UnexpectedObjectType{
+		Oid: blob, Got: BlobObjectType, Wanted: TreeObjectType,
+	}, err)
+}
+
+func TestExpectBlobPeelsTags(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	tag := writeTestTagID(t, db, blob, BlobObjectType, "v1", 1000)
+
+	b, err := db.ExpectBlob(tag)
+	require.NoError(t, err)
+	defer b.Close()
+	assert.EqualValues(t, 14, b.Size)
+}
+
+func TestCommitOfATreeNamesTheObject(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	tree := writeTestTree(t, db)
+
+	_, err := db.Commit(tree)
+	assert.Equal(t, &UnexpectedObjectType{
+		Oid: tree, Got: TreeObjectType, Wanted: CommitObjectType,
+	}, err)
+}
+
+// writeTestTagID is as writeTestTag, but returns the object ID of the tag as a
+// []byte.
+func writeTestTagID(t *testing.T, db *ObjectDatabase, sha []byte, typ ObjectType, name string, when int64) []byte {
+	tag, err := hex.DecodeString(writeTestTag(t, db, sha, typ, name, when))
+	require.NoError(t, err)
+
+	return tag
+}
@@ -0,0 +1,77 @@
+package gitobj
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// ExtractObject copies the object named by "sha", wherever it is stored (for
+// instance, in a packfile), into the objects directory "root" as a loose
+// object, compressed in the same way as the objects written to this database.
+// This is useful for promoting individual objects out of a quarantine directory
+// (see: Quarantine), or for mirroring a selection of objects into another
+// repository.
+//
+// The contents of the object are verified against "sha" as they are copied.
+// If a loose object named "sha" already exists in "root", it is left as is.
+// The object itself is copied, even if it has been replaced (see:
+// ReplaceObjects).
+func (o *ObjectDatabase) ExtractObject(sha []byte, root string) error {
+	return o.ExtractObjectContext(context.Background(), sha, root)
+}
+
+// ExtractObjectContext is as ExtractObject, but abandons copying the object,
+// and returns the context's error, once the given context is done.
+func (o *ObjectDatabase) ExtractObjectContext(ctx context.Context, sha []byte, root string) error {
+	fs := newFileStorer(root, o.tmp)
+	if _, err := os.Stat(fs.path(sha)); err == nil {
+		return nil
+	}
+
+	r, err := o.openExact(ctx, sha)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	typ, size, err := r.Header()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(o.tmp, "")
+	if err != nil {
+		return err
+	}
+	defer o.cleanup(tmp)
+
+	zw, err := o.compressor.NewWriter(tmp, o.compressionLevel)
+	if err != nil {
+		return err
+	}
+
+	to := newObjectWriteCloser(&nopCloser{tmp}, zw, o.Hasher())
+	if _, err = to.WriteHeader(typ, size); err != nil {
+		return err
+	}
+	if _, err = io.Copy(to, r); err != nil {
+		return err
+	}
+	if err = to.Close(); err != nil {
+		return err
+	}
+
+	if got := to.Sha(); !bytes.Equal(got, sha) {
+		return fmt.Errorf("gitobj: object %x has contents of object %x", sha, got)
+	}
+
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = fs.Store(sha, &contextReader{ctx: ctx, r: tmp})
+	return err
+}
@@ -0,0 +1,68 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractObjectWritesLooseObject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-extract")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db := newTestMemoryDatabase(t)
+	sha := writeTestTree(t, db)
+
+	require.NoError(t, db.ExtractObject(sha, dir))
+
+	encoded := hex.EncodeToString(sha)
+	_, err = os.Stat(filepath.Join(dir, encoded[:2], encoded[2:]))
+	require.NoError(t, err)
+
+	other, err := FromFilesystem(dir, "")
+	require.NoError(t, err)
+	defer other.Close()
+
+	want, err := db.Tree(sha)
+	require.NoError(t, err)
+	got, err := other.Tree(sha)
+	require.NoError(t, err)
+	assert.True(t, want.Equal(got))
+
+	// Extracting an object which is already present is a no-op.
+	assert.NoError(t, db.ExtractObject(sha, dir))
+}
+
+func TestExtractObjectVerifiesContents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-extract")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err = io.WriteString(zw, "blob 6\x00Hello\n")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	sha := "0000000000000000000000000000000000000000"
+	b, err := NewMemoryBackend(map[string]io.ReadWriter{sha: &buf})
+	require.NoError(t, err)
+	db, err := FromBackend(b)
+	require.NoError(t, err)
+
+	oid, _ := hex.DecodeString(sha)
+	err = db.ExtractObject(oid, dir)
+
+	assert.EqualError(t, err, "gitobj: object 0000000000000000000000000000000000000000 has contents of object e965047ad7c57865823c7d992b1d046ea66edf78")
+	_, err = os.Stat(filepath.Join(dir, "00"))
+	assert.True(t, os.IsNotExist(err))
+}
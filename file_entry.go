@@ -0,0 +1,58 @@
+package gitobj
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileEntry writes the blob for the file or symbolic link at location
+// "path" on disk, and returns a *TreeEntry naming it, as "git add" would, for
+// building a tree from the state of the filesystem.
+//
+// A symbolic link is written as a blob holding its target (see:
+// NewBlobFromSymlink) with a filemode of 0120000, rather than being followed.
+// A regular file is written with a filemode of 0100755 if it is executable by
+// its owner, and 0100644 otherwise. The entry is named by the last element of
+// the path. Directories, and other kinds of file, cannot be written as blobs,
+// and so an error is returned; a submodule is instead recorded with
+// NewGitlinkEntry.
+func (o *ObjectDatabase) WriteFileEntry(path string) (*TreeEntry, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		blob *Blob
+		mode int32
+	)
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		if blob, err = NewBlobFromSymlink(path); err != nil {
+			return nil, err
+		}
+		mode = sIFLNK
+	case fi.Mode().IsRegular():
+		if blob, err = NewBlobFromFile(path); err != nil {
+			return nil, err
+		}
+		mode = sIFREG | 0644
+		if fi.Mode()&0100 != 0 {
+			mode = sIFREG | 0755
+		}
+	default:
+		return nil, fmt.Errorf("gitobj: cannot write %s as a blob: it is not a file or symbolic link", path)
+	}
+
+	sha, err := o.WriteBlob(blob)
+	if err != nil {
+		blob.Close()
+		return nil, err
+	}
+	return &TreeEntry{
+		Name:     filepath.Base(path),
+		Oid:      sha,
+		Filemode: mode,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package gitobj
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileEntryWritesFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-files")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db := newTestMemoryDatabase(t)
+
+	path := filepath.Join(dir, "hello.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("Hello, world!\n"), 0644))
+
+	entry, err := db.WriteFileEntry(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hello.txt", entry.Name)
+	assert.Equal(t, int32(0100644), entry.Filemode)
+	assert.Equal(t, "af5626b4a114abcb82d63db7c8082c3c4756e51b", hex.EncodeToString(entry.Oid))
+
+	if runtime.GOOS != "windows" {
+		script := filepath.Join(dir, "script.sh")
+		require.NoError(t, ioutil.WriteFile(script, []byte("#!/bin/sh\n"), 0755))
+
+		entry, err = db.WriteFileEntry(script)
+		require.NoError(t, err)
+		assert.Equal(t, int32(0100755), entry.Filemode)
+	}
+
+	_, err = db.WriteFileEntry(dir)
+	assert.Error(t, err)
+}
+
+func TestWriteFileEntryWritesSymlinkTargets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-files")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(filepath.Join("sub", "target.txt"), link); err != nil {
+		t.Skipf("cannot create symbolic link: %s", err)
+	}
+
+	db := newTestMemoryDatabase(t)
+
+	entry, err := db.WriteFileEntry(link)
+	require.NoError(t, err)
+	assert.Equal(t, "link", entry.Name)
+	assert.True(t, entry.IsLink())
+
+	want := sha1.Sum([]byte("blob 14\x00sub/target.txt"))
+	assert.Equal(t, want[:], entry.Oid)
+}
+
+func TestNewGitlinkEntry(t *testing.T) {
+	commit := make([]byte, 20)
+	entry := NewGitlinkEntry("vendor/lib", commit)
+
+	assert.Equal(t, "vendor/lib", entry.Name)
+	assert.Equal(t, CommitObjectType, entry.Type())
+	commit[0] = 1
+	assert.Equal(t, make([]byte, 20), entry.Oid)
+}
@@ -3,29 +3,94 @@ package gitobj
 import (
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
 )
 
 // fileStorer implements the storer interface by writing to the .git/objects
 // directory on disc.
 type fileStorer struct {
-	// root is the top level /objects directory's path on disc.
+	// root is the top level /objects directory's path on disc, in its
+	// extended-length form on Windows (see: longPath).
 	root string
+	// name is the path of the root as it was given, which Root returns.
+	name string
 
 	// temp directory, defaults to os.TempDir
 	tmp string
+
+	// batched indicates whether objects are staged in temporary files
+	// until a call to Flush(), rather than being moved into place
+	// immediately.
+	batched bool
+	// synced indicates whether objects which are moved into place
+	// immediately are synced to stable storage, along with their
+	// directories, by the next call to Flush() (see: SyncedWrites).
+	synced bool
+	// durable indicates whether objects which are moved into place
+	// immediately are first written to a temporary file beside them, and
+	// synced, along with their directories, before Store returns (see:
+	// FsyncObjectFiles).
+	durable bool
+	// shared is the policy for the permissions of the files and
+	// directories created in the root (see: SharedRepository).
+	shared SharedPerm
+	// mu guards "pending" and "unsynced" below.
+	mu sync.Mutex
+	// pending maps the final path of each staged object to the path of the
+	// temporary file holding its contents.
+	pending map[string]string
+	// unsynced holds the final path of each object moved into place since
+	// the last call to Flush(), if "synced" is set.
+	unsynced map[string]struct{}
+
+	// packs, if non-nil, indicates that staged objects are written into a
+	// new packfile when flushed, rather than being moved into place as
+	// loose objects. Once written, the packfile is added to "packs" so that
+	// its objects may be read.
+	packs *pack.Storage
+	// hasher returns a new instance of the hash algorithm used to name
+	// objects. It is only used when writing packfiles.
+	hasher func() hash.Hash
+	// compressor is the Compressor with which loose objects are
+	// decompressed, or nil if storage.Zlib is used.
+	compressor storage.Compressor
+
+	// caseOnce guards "folded", which indicates whether the file system
+	// holding the objects directory ignores the case of names (see:
+	// foldsCase).
+	caseOnce sync.Once
+	folded   bool
+	// aliases holds the path of each object written under an uppercase or
+	// mixed-case name (see: alias), by the lowercase path of each, where
+	// case is significant. "scanned" holds the lowercase path of each
+	// fan-out directory which has been searched for such names. Both are
+	// guarded by "mu".
+	aliases map[string]string
+	scanned map[string]struct{}
 }
 
 // NewFileStorer returns a new fileStorer instance with the given root.
 func newFileStorer(root, tmp string) *fileStorer {
 	return &fileStorer{
-		root: root,
-		tmp:  tmp,
+		root:     longPath(root),
+		name:     root,
+		tmp:      longPath(tmp),
+		pending:  make(map[string]string),
+		unsynced: make(map[string]struct{}),
+		aliases:  make(map[string]string),
+		scanned:  make(map[string]struct{}),
 	}
 }
 
@@ -36,7 +101,12 @@ func newFileStorer(root, tmp string) *fileStorer {
 // It is the caller's responsibility to close the given file "f" after its use
 // is complete.
 func (fs *fileStorer) Open(sha []byte) (f io.ReadCloser, err error) {
-	f, err = fs.open(fs.path(sha), os.O_RDONLY)
+	f, err = fs.open(fs.objectPath(sha), os.O_RDONLY)
+	if os.IsNotExist(err) {
+		if alias, ok := fs.alias(fs.path(sha)); ok {
+			f, err = fs.open(alias, os.O_RDONLY)
+		}
+	}
 	if os.IsNotExist(err) {
 		return nil, errors.NoSuchObject(sha)
 	}
@@ -52,7 +122,8 @@ func (fs *fileStorer) Store(sha []byte, r io.Reader) (n int64, err error) {
 	path := fs.path(sha)
 	dir := filepath.Dir(path)
 
-	if stat, err := os.Stat(path); stat != nil || os.IsExist(err) {
+	_, isStaged := fs.staged(path)
+	if stat, err := os.Stat(path); stat != nil || os.IsExist(err) || isStaged {
 		// If the file already exists, there is no work left for us to
 		// do, since the object already exists (or there is a SHA1
 		// collision).
@@ -64,56 +135,670 @@ func (fs *fileStorer) Store(sha []byte, r io.Reader) (n int64, err error) {
 		return 0, nil
 	}
 
+	if fs.durable && !fs.batched && fs.packs == nil {
+		return fs.storeDurably(path, r)
+	}
+
 	tmp, err := ioutil.TempFile(fs.tmp, "")
 	if err != nil {
 		return 0, err
 	}
 
 	n, err = io.Copy(tmp, r)
-	if err = tmp.Close(); err != nil {
-		return n, err
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
 	}
 	if err != nil {
+		// Never move a partially-written object into place (or stage
+		// it), since it would then be read under the name of the
+		// complete object.
+		os.Remove(tmp.Name())
 		return n, err
 	}
 
+	if fs.batched || fs.packs != nil {
+		fs.mu.Lock()
+		fs.pending[path] = tmp.Name()
+		fs.mu.Unlock()
+
+		return n, nil
+	}
+
 	// Since .git/objects partitions objects based on the first two
 	// characters of their ASCII-encoded SHA1 object ID, ensure that
 	// the directory exists before copying a file into it.
-	if err = os.MkdirAll(dir, 0755); err != nil {
+	if err = fs.mkdir(dir); err != nil {
 		return n, err
 	}
 
+	if err = adjustSharedPerm(tmp.Name(), fs.shared); err != nil {
+		return n, err
+	}
 	if err = os.Rename(tmp.Name(), path); err != nil {
 		return n, err
 	}
 
+	if fs.synced {
+		fs.mu.Lock()
+		fs.unsynced[path] = struct{}{}
+		fs.mu.Unlock()
+	}
+	return n, nil
+}
+
+// storeDurably writes the object at "path" with the contents of "r" as Git does
+// when "core.fsyncObjectFiles" is set: the object is written to a temporary file
+// in the fan-out directory which will hold it, and synced to stable storage,
+// before it is made read-only and atomically renamed into place, after which
+// the directory (and, if it was created, the root) is synced, so that a crash
+// cannot leave a truncated object in place.
+func (fs *fileStorer) storeDurably(path string, r io.Reader) (n int64, err error) {
+	dir := filepath.Dir(path)
+
+	_, err = os.Stat(dir)
+	created := os.IsNotExist(err)
+	if err = fs.mkdir(dir); err != nil {
+		return 0, err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "tmp_obj_")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err = io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0444)
+	}
+	if err == nil {
+		err = adjustSharedPerm(tmp.Name(), fs.shared)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return n, err
+	}
+
+	if err = syncDir(dir); err != nil {
+		return n, err
+	}
+	if created {
+		if err = syncDir(fs.root); err != nil {
+			return n, err
+		}
+	}
 	return n, nil
 }
 
-// Root gives the absolute (fully-qualified) path to the file storer on disk.
+// mkdir creates the directory "dir", along with any missing parents, if it does
+// not already exist, and adjusts its permissions if the root is shared (see:
+// SharedRepository).
+func (fs *fileStorer) mkdir(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return adjustSharedPerm(dir, fs.shared)
+}
+
+// objectPath returns the path of the file holding the object named "sha": the
+// temporary file holding it, if it is staged, or its loose path otherwise.
+func (fs *fileStorer) objectPath(sha []byte) string {
+	path := fs.path(sha)
+	if staged, ok := fs.staged(path); ok {
+		return staged
+	}
+	return path
+}
+
+// Root gives the absolute (fully-qualified) path to the file storer on disk, as
+// it was given, rather than in the extended-length form in which it is opened
+// on Windows.
 func (fs *fileStorer) Root() string {
-	return fs.root
+	return fs.name
 }
 
-// Close closes the file storer.
-func (fs *fileStorer) Close() error {
+// Flush moves all objects staged since the last call to Flush() into place,
+// syncing their contents to disk before doing so. Once every object has been
+// renamed, each of the affected fan-out directories is synced exactly once.
+//
+// Objects which were moved into place immediately since the last call to Flush
+// are synced to disk in the same way, along with their directories, if writes
+// are synced (see: SyncedWrites).
+//
+// If any object could not be moved into place, Flush returns an error, and
+// the objects which were not yet moved remain staged.
+//
+// If objects are written into packfiles, Flush instead writes all staged
+// objects into a single new packfile (and index), which is then made available
+// for reading.
+func (fs *fileStorer) Flush() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.packs != nil {
+		return fs.flushPack()
+	}
+
+	paths := make([]string, 0, len(fs.pending))
+	for path := range fs.pending {
+		paths = append(paths, path)
+	}
+	// Sort the paths so that objects in the same fan-out directory are
+	// moved into place together.
+	sort.Strings(paths)
+
+	dirs := make(map[string]struct{})
+	for path := range fs.unsynced {
+		if err := syncFile(path); err != nil {
+			return err
+		}
+		dirs[filepath.Dir(path)] = struct{}{}
+	}
+
+	for _, path := range paths {
+		tmp := fs.pending[path]
+
+		if err := syncFile(tmp); err != nil {
+			return err
+		}
+
+		dir := filepath.Dir(path)
+		if _, ok := dirs[dir]; !ok {
+			if err := fs.mkdir(dir); err != nil {
+				return err
+			}
+			dirs[dir] = struct{}{}
+		}
+
+		if err := adjustSharedPerm(tmp, fs.shared); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+		delete(fs.pending, path)
+	}
+
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+
+	for path := range fs.unsynced {
+		delete(fs.unsynced, path)
+	}
+	return nil
+}
+
+// flushPack writes every staged object into a new packfile in the "pack"
+// subdirectory of the root, along with its index, and removes the staged
+// objects once the packfile has been added to "fs.packs".
+//
+// The caller must hold "fs.mu".
+func (fs *fileStorer) flushPack() error {
+	if len(fs.pending) == 0 {
+		return nil
+	}
+
+	files := make(map[string]string, len(fs.pending))
+	for path, tmp := range fs.pending {
+		sha, err := hex.DecodeString(filepath.Base(filepath.Dir(path)) + filepath.Base(path))
+		if err != nil {
+			return err
+		}
+		files[string(sha)] = tmp
+	}
+
+	name, err := fs.writePack(files, fs.hasher())
+	if err != nil {
+		return err
+	}
+
+	p, err := pack.OpenPackfile(name, fs.hasher())
+	if err != nil {
+		return err
+	}
+	fs.packs.Add(p)
+
+	for path, tmp := range fs.pending {
+		os.Remove(tmp)
+		delete(fs.pending, path)
+	}
+	return nil
+}
+
+// writePack writes the objects held by the given files, which are compressed
+// as loose objects are, into a new packfile in the "pack" subdirectory of the
+// root, along with its index, and returns the path of the packfile. "files"
+// maps the name of each object to the path of the file holding it, and "algo"
+// is the hash algorithm with which the packfile is checksummed.
+//
+// The packfile and its index are synced to disk before being moved into place,
+// and so the files given may be removed once writePack returns.
+func (fs *fileStorer) writePack(files map[string]string, algo hash.Hash) (string, error) {
+	shas := make([]string, 0, len(files))
+	for sha := range files {
+		shas = append(shas, sha)
+	}
+	// Write the objects in order of their names, so that the same objects
+	// are always written into the same packfile.
+	sort.Strings(shas)
+
+	dir := filepath.Join(fs.root, "pack")
+	if err := fs.mkdir(dir); err != nil {
+		return "", err
+	}
+
+	packf, err := ioutil.TempFile(dir, "tmp_pack_")
+	if err != nil {
+		return "", err
+	}
+	defer fs.cleanup(packf)
+
+	w, err := pack.NewWriter(packf, uint32(len(shas)), algo)
+	if err != nil {
+		return "", err
+	}
+
+	for _, sha := range shas {
+		if err := fs.addToPack(w, []byte(sha), files[sha]); err != nil {
+			return "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	idxf, err := ioutil.TempFile(dir, "tmp_idx_")
+	if err != nil {
+		return "", err
+	}
+	defer fs.cleanup(idxf)
+
+	if err := w.WriteIndex(idxf); err != nil {
+		return "", err
+	}
+
+	for _, f := range []*os.File{packf, idxf} {
+		if err := f.Sync(); err != nil {
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+		if err := adjustSharedPerm(f.Name(), fs.shared); err != nil {
+			return "", err
+		}
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("pack-%x", w.Checksum()))
+
+	// Move the index into place last, since readers only consider packfiles
+	// for which an index exists.
+	if err := os.Rename(packf.Name(), name+".pack"); err != nil {
+		return "", err
+	}
+	if err := os.Rename(idxf.Name(), name+".idx"); err != nil {
+		return "", err
+	}
+	if err := syncDir(dir); err != nil {
+		return "", err
+	}
+	return name + ".pack", nil
+}
+
+// addToPack writes the object named "sha", held by the file at "path", into the
+// packfile being written by "w".
+func (fs *fileStorer) addToPack(w *pack.Writer, sha []byte, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	r, err := newObjectReadCloser(f, fs.Compressor(), false)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	defer r.Close()
+
+	typ, size, err := r.Header()
+	if err != nil {
+		return err
+	}
+	return w.Add(sha, packedObjectType(typ), size, r)
+}
+
+// Has implements the storage.HasStorage interface, and returns whether a loose
+// object with the given SHA exists, either at its path, or staged to be moved
+// there.
+func (fs *fileStorer) Has(sha []byte) (bool, error) {
+	path := fs.path(sha)
+	if _, ok := fs.staged(path); ok {
+		return true, nil
+	}
+
+	if _, err := os.Stat(fs.loosePath(sha)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Enumerate implements the storage.EnumerableStorage interface, and calls "fn"
+// with the name of each loose object in the root (see: each).
+func (fs *fileStorer) Enumerate(fn func(sha []byte) error) error {
+	return fs.each(func(sha []byte, path string, size int64) error {
+		return fn(sha)
+	})
+}
+
+// each calls "fn" with the name, path, and size of each loose object in the
+// root, in no particular order. Objects which are staged but not yet flushed
+// are not included.
+//
+// If "fn" returns an error, each stops, and returns that error.
+func (fs *fileStorer) each(fn func(sha []byte, path string, size int64) error) error {
+	return fs.eachFile(func(sha []byte, path string, fi os.FileInfo) error {
+		return fn(sha, path, fi.Size())
+	})
+}
+
+// eachFile calls "fn" with the name, path, and file information of each loose
+// object in the root, as each does.
+func (fs *fileStorer) eachFile(fn func(sha []byte, path string, fi os.FileInfo) error) error {
+	dirs, err := ioutil.ReadDir(fs.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		if _, err := hex.DecodeString(dir.Name()); err != nil {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(fs.root, dir.Name()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The directory was removed since the
+				// objects directory was read, as by a
+				// concurrent "git prune".
+				continue
+			}
+			return err
+		}
+		for _, f := range files {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+
+			sha, err := hex.DecodeString(dir.Name() + f.Name())
+			if err != nil {
+				// Skip temporary files, and other files which
+				// are not objects.
+				continue
+			}
+
+			path := filepath.Join(fs.root, dir.Name(), f.Name())
+			if name := dir.Name() + f.Name(); name != strings.ToLower(name) && !fs.foldsCase() {
+				// The object was written by another tool in
+				// uppercase (or mixed-case) hex, and so cannot
+				// be opened by its lowercase path (see: alias),
+				// unless it is also stored there, in which case
+				// it is visited by that path instead.
+				canonical := fs.path(sha)
+				if _, err := os.Lstat(canonical); err == nil {
+					continue
+				}
+
+				fs.mu.Lock()
+				fs.aliases[canonical] = path
+				fs.mu.Unlock()
+			}
+
+			if err := fn(sha, path, f); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// loosePath returns the path of the loose object named "sha": its lowercase
+// path (see: path), unless no file exists there, but one does under an
+// uppercase or mixed-case name (see: alias), in which case that name is
+// returned.
+func (fs *fileStorer) loosePath(sha []byte) string {
+	path := fs.path(sha)
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		if alias, ok := fs.alias(path); ok {
+			return alias
+		}
+	}
+	return path
+}
+
+// alias returns the path of the file holding the loose object whose lowercase
+// path is "path", if it was written by another tool under an uppercase (or
+// mixed-case) name instead, as Git always names objects in lowercase hex.
+//
+// Where the file system ignores case (see: foldsCase), such objects may be
+// opened by their lowercase path, and so alias always returns false. Otherwise,
+// only the fan-out directory holding "path" is searched for such names (see:
+// scanAliases), once, when alias is first called for an object within it, and
+// the whole root again each time the objects are enumerated (see: eachFile).
+func (fs *fileStorer) alias(path string) (string, bool) {
+	if fs.foldsCase() {
+		return "", false
+	}
+
+	dir := filepath.Dir(path)
+
+	fs.mu.Lock()
+	_, scanned := fs.scanned[dir]
+	fs.mu.Unlock()
+
+	if !scanned {
+		fs.scanAliases(dir)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	alias, ok := fs.aliases[path]
+	return alias, ok
+}
+
+// scanAliases searches the fan-out directory whose lowercase path is "dir",
+// along with each directory whose name differs from it only in case, for
+// objects written under an uppercase or mixed-case name, and records the path
+// of each in "aliases" (see: alias). Objects which are also stored by their
+// lowercase path are ignored.
+func (fs *fileStorer) scanAliases(dir string) {
+	parent, prefix := filepath.Split(dir)
+
+	aliases := make(map[string]string)
+	for _, name := range caseVariants(prefix) {
+		files, err := ioutil.ReadDir(filepath.Join(parent, name))
+		if err != nil {
+			continue
+		}
+
+		for _, f := range files {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			if name+f.Name() == strings.ToLower(name+f.Name()) {
+				continue
+			}
+
+			sha, err := hex.DecodeString(name + f.Name())
+			if err != nil {
+				continue
+			}
+
+			canonical := fs.path(sha)
+			if _, err := os.Lstat(canonical); err == nil {
+				continue
+			}
+			aliases[canonical] = filepath.Join(parent, name, f.Name())
+		}
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for canonical, path := range aliases {
+		fs.aliases[canonical] = path
+	}
+	fs.scanned[dir] = struct{}{}
+}
+
+// foldsCase returns whether the file system holding the objects directory
+// ignores the case of names, as those of Windows and macOS do by default. It is
+// determined once, without writing anything, by looking up the root (or, if its
+// name has no letters, its nearest ancestor whose name does) by its name with
+// the case of each letter swapped, and comparing the result to the original. If
+// no such name can be found, case is assumed to be significant.
+func (fs *fileStorer) foldsCase() bool {
+	fs.caseOnce.Do(func() {
+		dir, err := filepath.Abs(fs.root)
+		if err != nil {
+			return
+		}
+
+		for {
+			parent, name := filepath.Split(dir)
+			if swapped := swapCase(name); swapped != name {
+				fi, err := os.Stat(dir)
+				if err != nil {
+					return
+				}
+				sfi, err := os.Stat(filepath.Join(parent, swapped))
+				fs.folded = err == nil && os.SameFile(fi, sfi)
+				return
+			}
+
+			parent = filepath.Dir(dir)
+			if parent == dir {
+				return
+			}
+			dir = parent
+		}
+	})
+	return fs.folded
+}
+
+// caseVariants returns each string which differs from "s" only in the case of
+// its letters, including "s" itself.
+func caseVariants(s string) []string {
+	variants := []string{""}
+	for _, r := range s {
+		lower, upper := unicode.ToLower(r), unicode.ToUpper(r)
+
+		next := make([]string, 0, 2*len(variants))
+		for _, v := range variants {
+			next = append(next, v+string(lower))
+			if upper != lower {
+				next = append(next, v+string(upper))
+			}
+		}
+		variants = next
+	}
+	return variants
+}
+
+// swapCase returns "s" with each uppercase letter made lowercase, and each
+// lowercase letter made uppercase.
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// cleanup closes and removes the given temporary file, ignoring any errors
+// (which are expected if it has already been moved into place).
+func (fs *fileStorer) cleanup(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// staged returns the path of the temporary file holding the contents of the
+// object at "path", and whether or not the object has been staged but not yet
+// flushed.
+func (fs *fileStorer) staged(path string) (string, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	tmp, ok := fs.pending[path]
+	return tmp, ok
+}
+
+// Close closes the file storer, flushing any staged objects first.
+func (fs *fileStorer) Close() error {
+	return fs.Flush()
+}
+
 // IsCompressed returns true, because the file storer returns compressed data.
 func (fs *fileStorer) IsCompressed() bool {
 	return true
 }
 
+// Compressor implements the storage.CompressorStorage interface by returning the
+// Compressor with which loose objects are decompressed.
+func (fs *fileStorer) Compressor() storage.Compressor {
+	if fs.compressor == nil {
+		return storage.Zlib
+	}
+	return fs.compressor
+}
+
 // open opens a given file.
 func (fs *fileStorer) open(path string, flag int) (*os.File, error) {
 	return os.OpenFile(path, flag, 0)
 }
 
-// path returns an absolute path on disk to the object given by the OID "sha".
+// path returns an absolute path on disk to the object given by the OID "sha",
+// which is named by lowercase hex, as Git names it.
 func (fs *fileStorer) path(sha []byte) string {
 	encoded := hex.EncodeToString(sha)
 
 	return filepath.Join(fs.root, encoded[:2], encoded[2:])
 }
+
+// syncFile opens the file or directory at "path" and commits its contents to
+// stable storage.
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,9 @@
+// +build !windows
+
+package gitobj
+
+// syncDir commits the directory entries of the directory at "path" to stable
+// storage.
+func syncDir(path string) error {
+	return syncFile(path)
+}
@@ -0,0 +1,8 @@
+// +build windows
+
+package gitobj
+
+// syncDir does nothing, since directories cannot be synced on Windows.
+func syncDir(path string) error {
+	return nil
+}
@@ -0,0 +1,107 @@
+package gitobj
+
+import (
+	"context"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// ForEachObject calls "fn" with the name, type, and size of each object in the
+// database: each loose object, and each object in each packfile, including
+// those in alternate object databases, as well as each object held by any
+// other storage which may be enumerated (see: storage.EnumerableStorage).
+// Objects are visited in no particular order, but each is visited only once,
+// even if it is stored more than once.
+//
+// The types and sizes of packed objects are read from their headers (and those
+// of their delta bases), so enumerating a database does not require resolving
+// any deltas. Staged objects which have not yet been flushed are not included.
+//
+// ForEachObject may be called while other processes, such as "git gc", write
+// objects to the database and remove them from it. Each object present in the
+// database throughout is visited, even if it is moved while the database is
+// being enumerated, as from a loose object into a packfile, or from one
+// packfile into another; objects added or removed in the meantime may or may
+// not be visited. Loose objects and packfiles removed before they can be read
+// are skipped, rather than causing an error, and packfiles written while the
+// database's packfiles are enumerated are enumerated in turn (see:
+// pack.Set.ForEach).
+//
+// If "fn" returns an error, ForEachObject stops, and returns that error.
+func (o *ObjectDatabase) ForEachObject(fn func(oid []byte, typ ObjectType, size int64) error) error {
+	return o.forEachObject(fn, func(sha []byte, err error) error {
+		return err
+	})
+}
+
+// forEachObject calls "fn" with each object in the database as ForEachObject
+// does, but calls "failed" with the name of each object whose header cannot be
+// read, and the error encountered in reading it. If "failed" returns nil, the
+// object is skipped; otherwise, forEachObject stops, and returns that error.
+func (o *ObjectDatabase) forEachObject(fn func(oid []byte, typ ObjectType, size int64) error, failed func(sha []byte, err error) error) error {
+	if o.isClosed() {
+		return ErrDatabaseClosed
+	}
+
+	hashlen := o.Hasher().Size()
+	seen := make(map[string]struct{})
+
+	// visit calls "fn" with the given object, unless it has already been
+	// visited. If "typ" is UnknownObjectType, the type and size are read
+	// from the object's header.
+	visit := func(sha []byte, typ ObjectType, size int64) error {
+		if len(sha) != hashlen {
+			return nil
+		}
+		if _, ok := seen[string(sha)]; ok {
+			return nil
+		}
+		seen[string(sha)] = struct{}{}
+
+		if typ == UnknownObjectType {
+			r, err := o.openExact(context.Background(), sha)
+			if errors.IsNoSuchObject(err) {
+				// The object was removed since it was
+				// listed, and will be visited in the packfile
+				// holding it, if it was only moved.
+				delete(seen, string(sha))
+				return nil
+			}
+			if err != nil {
+				return failed(sha, err)
+			}
+			typ, size, err = r.Header()
+			r.Close()
+			if err != nil {
+				return failed(sha, err)
+			}
+		}
+		return fn(sha, typ, size)
+	}
+
+	for _, s := range storages(o.ro) {
+		var err error
+
+		switch s := s.(type) {
+		case *fileStorer:
+			err = s.each(func(sha []byte, path string, size int64) error {
+				return visit(sha, UnknownObjectType, 0)
+			})
+		case *pack.Storage:
+			err = s.ForEachObject(func(name []byte, typ pack.PackedObjectType, size int64) error {
+				return visit(name, objectType(typ), size)
+			})
+		case storage.EnumerableStorage:
+			err = s.Enumerate(func(sha []byte) error {
+				return visit(sha, UnknownObjectType, 0)
+			})
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEachObjectVisitsLooseAndPackedObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+
+	blob, err := packed.WriteBlob(NewBlobFromBytes([]byte("packed\n")))
+	require.NoError(t, err)
+	tree, err := packed.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "packed.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, packed.Close())
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	loose, err := db.WriteBlob(NewBlobFromBytes([]byte("loose\n")))
+	require.NoError(t, err)
+
+	type info struct {
+		typ  ObjectType
+		size int64
+	}
+
+	seen := make(map[string]info)
+	err = db.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		_, ok := seen[string(oid)]
+		assert.False(t, ok, "object %x visited more than once", oid)
+
+		seen[string(oid)] = info{typ, size}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]info{
+		string(blob):  {BlobObjectType, 7},
+		string(tree):  {TreeObjectType, int64(len("100644 packed.txt\x00") + len(blob))},
+		string(loose): {BlobObjectType, 6},
+	}, seen)
+}
+
+func TestForEachObjectWithAMemoryDatabase(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	var oids [][]byte
+	err = db.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		oids = append(oids, oid)
+		assert.Equal(t, BlobObjectType, typ)
+		assert.EqualValues(t, 14, size)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{blob}, oids)
+}
+
+func TestForEachObjectStopsOnError(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	for _, contents := range []string{"a\n", "b\n"} {
+		_, err := db.WriteBlob(NewBlobFromBytes([]byte(contents)))
+		require.NoError(t, err)
+	}
+
+	var calls int
+	err := db.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		calls++
+		return os.ErrInvalid
+	})
+	assert.Equal(t, os.ErrInvalid, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestForEachObjectNormalizesMixedCaseObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	lower, err := db.WriteBlob(NewBlobFromBytes([]byte("lower\n")))
+	require.NoError(t, err)
+	upper, err := db.WriteBlob(NewBlobFromBytes([]byte("upper\n")))
+	require.NoError(t, err)
+
+	var fs *fileStorer
+	for _, s := range storages(db.ro) {
+		if s, ok := s.(*fileStorer); ok {
+			fs = s
+		}
+	}
+	require.NotNil(t, fs)
+
+	// Rename the object as a tool writing uppercase hex would have.
+	path := fs.path(upper)
+	dir, name := filepath.Split(path)
+	mixed := filepath.Join(root, strings.ToUpper(filepath.Base(dir)), strings.ToUpper(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(mixed), 0755))
+	require.NoError(t, os.Rename(path, mixed))
+	os.Remove(dir)
+
+	// Deciding whether case is significant writes nothing to the root.
+	before, err := ioutil.ReadDir(root)
+	require.NoError(t, err)
+	fs.foldsCase()
+	after, err := ioutil.ReadDir(root)
+	require.NoError(t, err)
+	assert.Equal(t, len(before), len(after))
+
+	// The object is both enumerated and opened by its lowercase name.
+	seen := make(map[string]bool)
+	require.NoError(t, db.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		seen[hex.EncodeToString(oid)] = true
+		return nil
+	}))
+	assert.Equal(t, map[string]bool{
+		hex.EncodeToString(lower): true,
+		hex.EncodeToString(upper): true,
+	}, seen)
+
+	blob, err := db.Blob(upper)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "upper\n", string(contents))
+}
+
+func TestOpenFindsMixedCaseObjectsBeforeEnumeration(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+
+	upper, err := db.WriteBlob(NewBlobFromBytes([]byte("upper\n")))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	encoded := hex.EncodeToString(upper)
+	dir := filepath.Join(root, encoded[:2])
+	require.NoError(t, os.Rename(
+		filepath.Join(dir, encoded[2:]),
+		filepath.Join(dir, strings.ToUpper(encoded[2:]))))
+
+	db, err = FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ok, err := db.Has(upper)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	blob, err := db.Blob(upper)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "upper\n", string(contents))
+}
+
+func TestOpenSearchesOnlyTheMissedFanOutDirectoryForMixedCaseObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+
+	upper, err := db.WriteBlob(NewBlobFromBytes([]byte("upper\n")))
+	require.NoError(t, err)
+	other, err := db.WriteBlob(NewBlobFromBytes([]byte("other\n")))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	require.NotEqual(t, upper[0], other[0])
+
+	for _, sha := range [][]byte{upper, other} {
+		encoded := hex.EncodeToString(sha)
+		dir := filepath.Join(root, encoded[:2])
+		require.NoError(t, os.Rename(
+			filepath.Join(dir, encoded[2:]),
+			filepath.Join(dir, strings.ToUpper(encoded[2:]))))
+	}
+
+	// The other object's fan-out directory is named in uppercase, too.
+	encoded := hex.EncodeToString(other)
+	require.NoError(t, os.Rename(
+		filepath.Join(root, encoded[:2]),
+		filepath.Join(root, strings.ToUpper(encoded[:2]))))
+
+	db, err = FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var fs *fileStorer
+	for _, s := range storages(db.ro) {
+		if s, ok := s.(*fileStorer); ok {
+			fs = s
+		}
+	}
+	require.NotNil(t, fs)
+	if fs.foldsCase() {
+		t.Skip("file system ignores case")
+	}
+
+	ok, err := db.Has(upper)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Only the fan-out directory of the object which was looked up has
+	// been searched.
+	assert.Equal(t, map[string]string{
+		fs.path(upper): filepath.Join(filepath.Dir(fs.path(upper)),
+			strings.ToUpper(hex.EncodeToString(upper)[2:])),
+	}, fs.aliases)
+	assert.Len(t, fs.scanned, 1)
+
+	ok, err = db.Has(other)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, fs.scanned, 2)
+}
+
+func TestForEachObjectVisitsObjectsPackedDuringEnumeration(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var contents [][]byte
+	for i := 0; i < 16; i++ {
+		contents = append(contents, []byte(strings.Repeat("x", i)))
+		_, err := db.WriteBlob(NewBlobFromBytes(contents[i]))
+		require.NoError(t, err)
+	}
+
+	// Once the first object has been visited, every object is moved into
+	// a packfile, and the loose objects removed, as by "git gc".
+	var gc bool
+	seen := make(map[string]int)
+	err = db.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		seen[string(oid)]++
+		if gc {
+			return nil
+		}
+		gc = true
+
+		other, err := ioutil.TempDir("", "gitobj-objects")
+		require.NoError(t, err)
+		defer os.RemoveAll(other)
+
+		packed, err := FromFilesystem(other, "", PackedWrites())
+		require.NoError(t, err)
+		for _, c := range contents {
+			_, err := packed.WriteBlob(NewBlobFromBytes(c))
+			require.NoError(t, err)
+		}
+		require.NoError(t, packed.Flush())
+		require.NoError(t, packed.Close())
+
+		files, err := ioutil.ReadDir(filepath.Join(other, "pack"))
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "pack"), 0755))
+		for _, f := range files {
+			require.NoError(t, os.Rename(
+				filepath.Join(other, "pack", f.Name()),
+				filepath.Join(root, "pack", f.Name())))
+		}
+
+		dirs, err := ioutil.ReadDir(root)
+		require.NoError(t, err)
+		for _, dir := range dirs {
+			if len(dir.Name()) == 2 {
+				require.NoError(t, os.RemoveAll(filepath.Join(root, dir.Name())))
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, seen, len(contents))
+	for oid, n := range seen {
+		assert.Equal(t, 1, n, "object %x visited %d times", oid, n)
+	}
+}
@@ -0,0 +1,91 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// HashBlob returns the name under which the given blob would be written to the
+// database, using its object format, without writing it, as with "git
+// hash-object" (without "-w").
+//
+// The blob's contents are read and hashed as they are read, rather than
+// buffered, so its Size must be the number of bytes in its contents; if it is
+// not, an error is returned. The blob is closed once its contents are read.
+func (o *ObjectDatabase) HashBlob(b *Blob) ([]byte, error) {
+	sum := o.Hasher()
+	fmt.Fprintf(sum, "%s %d\x00", BlobObjectType, b.Size)
+
+	n, err := io.Copy(sum, b.Contents)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	if err = b.Close(); err != nil {
+		return nil, err
+	}
+
+	if n != b.Size {
+		return nil, fmt.Errorf("gitobj: blob has %d byte(s), expected %d", n, b.Size)
+	}
+	return sum.Sum(nil), nil
+}
+
+// HashTree returns the name under which the given tree would be written to the
+// database, without writing it (see: HashBlob).
+func (o *ObjectDatabase) HashTree(t *Tree) ([]byte, error) {
+	return o.hash(t)
+}
+
+// HashTreeEntries returns the name of the tree holding the given entries, as it
+// would be written to the database, without writing it (see: HashBlob). Unlike
+// HashTree, the entries are sorted as Git requires (see: SubtreeOrder), whether
+// or not trees are written in canonical order (see: CanonicalTrees), so that
+// callers planning to write a tree can find whether it is already stored before
+// building it. The given entries are not modified.
+func (o *ObjectDatabase) HashTreeEntries(entries []*TreeEntry) ([]byte, error) {
+	sorted := &Tree{Entries: append([]*TreeEntry(nil), entries...)}
+	sort.Sort(SubtreeOrder(sorted.Entries))
+
+	return o.hash(sorted)
+}
+
+// HashCommit returns the name under which the given commit would be written to
+// the database, without writing it (see: HashBlob). If identities are
+// validated (see: StrictSignatures), they are validated and normalized in the
+// same way as if it were written.
+func (o *ObjectDatabase) HashCommit(c *Commit) ([]byte, error) {
+	return o.hash(c)
+}
+
+// HashTag returns the name under which the given tag would be written to the
+// database, without writing it (see: HashCommit).
+func (o *ObjectDatabase) HashTag(t *Tag) ([]byte, error) {
+	return o.hash(t)
+}
+
+// hash encodes the given object as it would be written by encode, and returns
+// its name.
+func (o *ObjectDatabase) hash(object Object) (sha []byte, err error) {
+	if object, err = o.prepare(object); err != nil {
+		return nil, err
+	}
+
+	buf := o.scratch
+	if buf == nil {
+		buf = new(bytes.Buffer)
+	}
+	buf.Reset()
+
+	if _, err := object.Encode(buf); err != nil {
+		return nil, err
+	}
+
+	sum := o.Hasher()
+	fmt.Fprintf(sum, "%s %d\x00", object.Type(), buf.Len())
+	sum.Write(buf.Bytes())
+
+	return sum.Sum(nil), nil
+}
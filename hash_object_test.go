@@ -0,0 +1,108 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashBlobDoesNotWrite(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha, err := db.HashBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	assert.Equal(t, "af5626b4a114abcb82d63db7c8082c3c4756e51b", hex.EncodeToString(sha))
+	assert.Empty(t, db.rw.(*memoryStorer).fs)
+}
+
+func TestHashBlobUsesObjectFormat(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	db, err := FromBackend(b, ObjectFormat(ObjectFormatSHA256))
+	require.NoError(t, err)
+
+	sha, err := db.HashBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	written, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	assert.Equal(t, written, sha)
+	assert.Len(t, sha, 32)
+}
+
+func TestHashBlobRejectsIncorrectSize(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	_, err := db.HashBlob(&Blob{
+		Size:     3,
+		Contents: bytes.NewReader([]byte("Hello")),
+	})
+	assert.EqualError(t, err, "gitobj: blob has 5 byte(s), expected 3")
+}
+
+func TestHashTreeCommitAndTagMatchWrittenNames(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: make([]byte, 20), Filemode: 0100644},
+	}}
+	commit := &Commit{
+		Author:    "A U Thor <author@example.com> 100 +0000",
+		Committer: "A U Thor <author@example.com> 100 +0000",
+		TreeID:    make([]byte, 20),
+		Message:   "initial commit",
+	}
+	tag := &Tag{
+		Object:     make([]byte, 20),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "A U Thor <author@example.com> 100 +0000",
+		Message:    "v1.0.0",
+	}
+
+	treeSha, err := db.HashTree(tree)
+	require.NoError(t, err)
+	commitSha, err := db.HashCommit(commit)
+	require.NoError(t, err)
+	tagSha, err := db.HashTag(tag)
+	require.NoError(t, err)
+
+	assert.Empty(t, db.rw.(*memoryStorer).fs)
+
+	written, err := db.WriteTree(tree)
+	require.NoError(t, err)
+	assert.Equal(t, written, treeSha)
+
+	written, err = db.WriteCommit(commit)
+	require.NoError(t, err)
+	assert.Equal(t, written, commitSha)
+
+	written, err = db.WriteTag(tag)
+	require.NoError(t, err)
+	assert.Equal(t, written, tagSha)
+}
+
+func TestHashTreeEntriesSortsEntries(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	entries := []*TreeEntry{
+		{Name: "foo.txt", Oid: make([]byte, 20), Filemode: 0100644},
+		{Name: "foo", Oid: make([]byte, 20), Filemode: 040000},
+		{Name: "bar", Oid: make([]byte, 20), Filemode: 0100644},
+	}
+
+	sha, err := db.HashTreeEntries(entries)
+	require.NoError(t, err)
+	assert.Empty(t, db.rw.(*memoryStorer).fs)
+	assert.Equal(t, "foo.txt", entries[0].Name)
+
+	written, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		entries[2], entries[0], entries[1],
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, written, sha)
+}
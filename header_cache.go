@@ -0,0 +1,251 @@
+package gitobj
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+var (
+	// headerCacheHeader is the magic number at the beginning of a persisted
+	// header cache.
+	headerCacheHeader = []byte("GOHC")
+)
+
+const (
+	// headerCacheVersion is the version of the persisted header cache
+	// format.
+	headerCacheVersion = 1
+
+	// maxHeaderCacheEntries is the most objects whose headers are held by
+	// a header cache, so that it remains small. Objects looked up once it
+	// is full are not added.
+	maxHeaderCacheEntries = 1 << 18
+)
+
+// headerCacheEntry is the type and size of an object, as held by a header
+// cache.
+type headerCacheEntry struct {
+	typ  ObjectType
+	size int64
+}
+
+// headerCache holds the types and sizes of objects looked up by ObjectInfo,
+// persisted in a file in the objects directory between uses of the database
+// (see: HeaderCache). It is shared by a database and its views.
+//
+// The cache is discarded when the set of packfiles in the database (and its
+// alternates) changes, as when objects are repacked, or pruned.
+type headerCache struct {
+	// path is the path of the file in which the cache is persisted.
+	path string
+
+	once sync.Once
+
+	mu sync.Mutex
+	// fingerprint identifies the set of packfiles with which the cache
+	// was loaded.
+	fingerprint []byte
+	entries     map[string]headerCacheEntry
+	// dirty indicates whether entries have been added since the cache
+	// was loaded.
+	dirty bool
+}
+
+// newHeaderCache returns a new, unloaded *headerCache persisted in the file at
+// the given path.
+func newHeaderCache(path string) *headerCache {
+	return &headerCache{path: path, entries: make(map[string]headerCacheEntry)}
+}
+
+// load reads the cache from its file the first time it is called, provided
+// that it was saved with the fingerprint returned by "fingerprint" (see:
+// packFingerprint). A missing, unreadable, or stale file is ignored, leaving
+// the cache empty, since it is only an optimization.
+func (c *headerCache) load(fingerprint func() []byte) {
+	c.once.Do(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.fingerprint = fingerprint()
+
+		f, err := os.Open(c.path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		if entries, err := readHeaderCache(f, c.fingerprint); err == nil {
+			c.entries = entries
+		}
+	})
+}
+
+// get returns the type and size of the object named "sha", and whether they
+// are cached.
+func (c *headerCache) get(sha []byte) (ObjectType, int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[string(sha)]
+	return e.typ, e.size, ok
+}
+
+// add caches the type and size of the object named "sha".
+func (c *headerCache) add(sha []byte, typ ObjectType, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[string(sha)]; ok || len(c.entries) >= maxHeaderCacheEntries {
+		return
+	}
+	c.entries[string(sha)] = headerCacheEntry{typ: typ, size: size}
+	c.dirty = true
+}
+
+// save writes the cache to its file, replacing it atomically, if any entries
+// have been added since it was loaded.
+func (c *headerCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), "tmp_header_cache_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeHeaderCache(tmp, c.fingerprint, c.entries); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// readHeaderCache reads the entries of a header cache written by
+// writeHeaderCache from "r", returning an error if it was written with a
+// fingerprint other than that given.
+func readHeaderCache(r io.Reader, fingerprint []byte) (map[string]headerCacheEntry, error) {
+	br := bufio.NewReader(r)
+
+	var hdr struct {
+		Magic   [4]byte
+		Version uint32
+		Hashlen uint32
+		Count   uint32
+	}
+	if err := binary.Read(br, binary.BigEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr.Magic[:], headerCacheHeader) {
+		return nil, fmt.Errorf("gitobj: invalid header cache header")
+	}
+	if hdr.Version != headerCacheVersion {
+		return nil, fmt.Errorf("gitobj: unsupported header cache version %d", hdr.Version)
+	}
+	if hdr.Hashlen > pack.MaxHashSize || int(hdr.Hashlen) != len(fingerprint) {
+		return nil, fmt.Errorf("gitobj: invalid header cache hash length %d", hdr.Hashlen)
+	}
+	if hdr.Count > maxHeaderCacheEntries {
+		return nil, fmt.Errorf("gitobj: invalid header cache entry count %d", hdr.Count)
+	}
+
+	saved := make([]byte, hdr.Hashlen)
+	if _, err := io.ReadFull(br, saved); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(saved, fingerprint) {
+		return nil, fmt.Errorf("gitobj: stale header cache")
+	}
+
+	entries := make(map[string]headerCacheEntry, hdr.Count)
+	for i := uint32(0); i < hdr.Count; i++ {
+		sha := make([]byte, hdr.Hashlen)
+		if _, err := io.ReadFull(br, sha); err != nil {
+			return nil, err
+		}
+
+		var e struct {
+			Type uint8
+			Size uint64
+		}
+		if err := binary.Read(br, binary.BigEndian, &e); err != nil {
+			return nil, err
+		}
+		entries[string(sha)] = headerCacheEntry{
+			typ:  ObjectType(e.Type),
+			size: int64(e.Size),
+		}
+	}
+	return entries, nil
+}
+
+// writeHeaderCache writes the given entries of a header cache to "w", along
+// with the fingerprint of the set of packfiles for which they hold, in a form
+// which may be read by readHeaderCache.
+func writeHeaderCache(w io.Writer, fingerprint []byte, entries map[string]headerCacheEntry) error {
+	names := make([]string, 0, len(entries))
+	for sha := range entries {
+		names = append(names, sha)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	bw.Write(headerCacheHeader)
+	binary.Write(bw, binary.BigEndian, []uint32{
+		headerCacheVersion, uint32(len(fingerprint)), uint32(len(names)),
+	})
+	bw.Write(fingerprint)
+	for _, sha := range names {
+		e := entries[sha]
+
+		bw.WriteString(sha)
+		bw.WriteByte(byte(e.typ))
+		binary.Write(bw, binary.BigEndian, uint64(e.size))
+	}
+	return bw.Flush()
+}
+
+// packFingerprint returns a hash of the names of the packfiles in the database
+// and its alternates, which are given by their checksums, and so changes
+// whenever the set of packfiles does.
+func (o *ObjectDatabase) packFingerprint() []byte {
+	var names []string
+	for _, s := range storages(o.ro) {
+		if packs, ok := s.(*pack.Storage); ok {
+			for _, p := range packs.Packs() {
+				names = append(names, filepath.Base(p.Path()))
+			}
+		}
+	}
+	sort.Strings(names)
+
+	h := o.Hasher()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\n", name)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,101 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderCachePersistsObjectInfo(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", HeaderCache())
+	require.NoError(t, err)
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	typ, size, err := db.ObjectInfo(sha)
+	require.NoError(t, err)
+	assert.Equal(t, BlobObjectType, typ)
+	assert.EqualValues(t, 14, size)
+	require.NoError(t, db.Close())
+
+	_, err = os.Stat(filepath.Join(root, "info", "gitobj-header-cache"))
+	require.NoError(t, err)
+
+	// Remove the object itself, so that its type and size may only be
+	// given by the cache.
+	encoded := hex.EncodeToString(sha)
+	require.NoError(t, os.Remove(filepath.Join(root, encoded[:2], encoded[2:])))
+
+	db, err = FromFilesystem(root, "", HeaderCache())
+	require.NoError(t, err)
+	typ, size, err = db.ObjectInfo(sha)
+	require.NoError(t, err)
+	assert.Equal(t, BlobObjectType, typ)
+	assert.EqualValues(t, 14, size)
+	require.NoError(t, db.Close())
+
+	// Without the option, the cache is not consulted.
+	db, err = FromFilesystem(root, "")
+	require.NoError(t, err)
+	_, _, err = db.ObjectInfo(sha)
+	assert.Error(t, err)
+	require.NoError(t, db.Close())
+}
+
+func TestHeaderCacheIsDiscardedWhenPacksChange(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", HeaderCache())
+	require.NoError(t, err)
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	_, _, err = db.ObjectInfo(sha)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	encoded := hex.EncodeToString(sha)
+	require.NoError(t, os.Remove(filepath.Join(root, encoded[:2], encoded[2:])))
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	_, err = packed.WriteBlob(NewBlobFromBytes([]byte("packed\n")))
+	require.NoError(t, err)
+	require.NoError(t, packed.Close())
+
+	db, err = FromFilesystem(root, "", HeaderCache())
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, _, err = db.ObjectInfo(sha)
+	assert.Error(t, err)
+}
+
+func TestHeaderCacheRoundTrip(t *testing.T) {
+	fingerprint := bytes.Repeat([]byte{0x1}, 20)
+	entries := map[string]headerCacheEntry{
+		string(bytes.Repeat([]byte{0x2}, 20)): {typ: CommitObjectType, size: 250},
+		string(bytes.Repeat([]byte{0x3}, 20)): {typ: BlobObjectType, size: 1 << 40},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeHeaderCache(&buf, fingerprint, entries))
+
+	got, err := readHeaderCache(bytes.NewReader(buf.Bytes()), fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, entries, got)
+
+	_, err = readHeaderCache(bytes.NewReader(buf.Bytes()), bytes.Repeat([]byte{0x4}, 20))
+	assert.EqualError(t, err, "gitobj: stale header cache")
+}
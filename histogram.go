@@ -0,0 +1,151 @@
+package gitobj
+
+import (
+	"github.com/git-lfs/gitobj/v2/errors"
+)
+
+// ObjectCount is a number of objects, and their total size.
+type ObjectCount struct {
+	// Count is the number of objects.
+	Count int
+	// Size is the sum of the sizes of the objects' contents, once
+	// decompressed (and, for packed objects, once their deltas have been
+	// applied), which is not the space they occupy on disk.
+	Size int64
+}
+
+// add counts an object of the given size.
+func (c *ObjectCount) add(size int64) {
+	c.Count++
+	c.Size += size
+}
+
+// ObjectHistogram counts a set of objects by their type.
+type ObjectHistogram struct {
+	Commits ObjectCount
+	Trees   ObjectCount
+	Blobs   ObjectCount
+	Tags    ObjectCount
+}
+
+// Total returns the number of objects counted, of any type, and their total
+// size.
+func (h *ObjectHistogram) Total() ObjectCount {
+	return ObjectCount{
+		Count: h.Commits.Count + h.Trees.Count + h.Blobs.Count + h.Tags.Count,
+		Size:  h.Commits.Size + h.Trees.Size + h.Blobs.Size + h.Tags.Size,
+	}
+}
+
+// add counts an object of the given type and size.
+func (h *ObjectHistogram) add(typ ObjectType, size int64) {
+	switch typ {
+	case CommitObjectType:
+		h.Commits.add(size)
+	case TreeObjectType:
+		h.Trees.add(size)
+	case BlobObjectType:
+		h.Blobs.add(size)
+	case TagObjectType:
+		h.Tags.add(size)
+	}
+}
+
+// RootHistogram counts the objects reachable from a root, such as the tip of a
+// branch (see: HistogramByRoot).
+type RootHistogram struct {
+	// Root is the name of the root.
+	Root []byte
+	// Reachable counts every object reachable from the root, including the
+	// root itself.
+	Reachable ObjectHistogram
+	// Unique counts those objects which are reachable from the root, and
+	// from none of the other roots given, which is the storage attributable
+	// to the root alone.
+	Unique ObjectHistogram
+}
+
+// HistogramByRoot counts the commits, trees, blobs, and tags reachable from each
+// of the given roots (which may be commits, trees, blobs, or tags), and their
+// total sizes, so that the growth of a repository may be attributed to the
+// branches whose tips are given. Submodule commits are not counted.
+//
+// The histograms are returned in the order of the roots given. An object
+// reachable from several roots is counted as reachable from each of them, and
+// as unique to none. If an object reachable from a root is not present in the
+// database, a *MissingObject naming it is returned.
+func (o *ObjectDatabase) HistogramByRoot(roots [][]byte) ([]*RootHistogram, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	type reached struct {
+		typ  ObjectType
+		size int64
+		// first is the index of the first root from which the object
+		// was reached, and last that of the most recent.
+		first, last int
+		// roots is the number of roots from which the object was
+		// reached.
+		roots int
+	}
+
+	type pending struct {
+		sha  []byte
+		from []byte
+	}
+
+	objects := make(map[string]*reached)
+	histograms := make([]*RootHistogram, 0, len(roots))
+	for i, root := range roots {
+		h := &RootHistogram{Root: root}
+		histograms = append(histograms, h)
+
+		stack := []pending{{sha: root}}
+		for len(stack) > 0 {
+			next := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			obj, ok := objects[string(next.sha)]
+			if ok {
+				if obj.last == i {
+					continue
+				}
+				obj.last = i
+				obj.roots++
+				h.Reachable.add(obj.typ, obj.size)
+
+				// The objects to which it refers are not
+				// necessarily reached from this root yet, so
+				// must be visited again.
+			} else {
+				typ, size, err := o.ObjectInfo(next.sha)
+				if err != nil {
+					if errors.IsNoSuchObject(err) {
+						return nil, &MissingObject{Oid: next.sha, ReferencedBy: next.from}
+					}
+					return nil, err
+				}
+
+				obj = &reached{typ: typ, size: size, first: i, last: i, roots: 1}
+				objects[string(next.sha)] = obj
+				h.Reachable.add(typ, size)
+			}
+
+			refs, err := o.referencesOf(next.sha, obj.typ)
+			if err != nil {
+				return nil, err
+			}
+			for j := len(refs) - 1; j >= 0; j-- {
+				stack = append(stack, pending{sha: refs[j], from: next.sha})
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		if obj.roots == 1 {
+			histograms[obj.first].Unique.add(obj.typ, obj.size)
+		}
+	}
+	return histograms, nil
+}
@@ -0,0 +1,74 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramByRoot(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	base := writeTestCommit(t, db, map[string]string{"a.txt": "1"}, 100)
+	main := writeTestCommit(t, db, map[string]string{"a.txt": "1", "b.txt": "22"}, 200, base)
+	topic := writeTestCommit(t, db, map[string]string{"a.txt": "333"}, 300, base)
+
+	size := func(sha []byte) int64 {
+		_, size, err := db.ObjectInfo(sha)
+		require.NoError(t, err)
+		return size
+	}
+	tree := func(sha []byte) []byte {
+		c, err := db.Commit(sha)
+		require.NoError(t, err)
+		return c.TreeID
+	}
+
+	histograms, err := db.HistogramByRoot([][]byte{main, topic})
+	require.NoError(t, err)
+	require.Len(t, histograms, 2)
+
+	h := histograms[0]
+	assert.Equal(t, main, h.Root)
+	assert.Equal(t, ObjectHistogram{
+		Commits: ObjectCount{2, size(main) + size(base)},
+		Trees:   ObjectCount{2, size(tree(main)) + size(tree(base))},
+		Blobs:   ObjectCount{2, 3},
+	}, h.Reachable)
+	assert.Equal(t, ObjectHistogram{
+		Commits: ObjectCount{1, size(main)},
+		Trees:   ObjectCount{1, size(tree(main))},
+		Blobs:   ObjectCount{1, 2},
+	}, h.Unique)
+
+	h = histograms[1]
+	assert.Equal(t, topic, h.Root)
+	assert.Equal(t, ObjectHistogram{
+		Commits: ObjectCount{2, size(topic) + size(base)},
+		Trees:   ObjectCount{2, size(tree(topic)) + size(tree(base))},
+		Blobs:   ObjectCount{2, 4},
+	}, h.Reachable)
+	assert.Equal(t, ObjectHistogram{
+		Commits: ObjectCount{1, size(topic)},
+		Trees:   ObjectCount{1, size(tree(topic))},
+		Blobs:   ObjectCount{1, 3},
+	}, h.Unique)
+	assert.Equal(t, 3, h.Unique.Total().Count)
+}
+
+func TestHistogramByRootReportsMissingObjects(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	missing := make([]byte, 20)
+	missing[0] = 0xaa
+
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: missing, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	histograms, err := db.HistogramByRoot([][]byte{tree})
+	assert.Nil(t, histograms)
+	assert.Equal(t, &MissingObject{Oid: missing, ReferencedBy: tree}, err)
+}
@@ -0,0 +1,80 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+)
+
+// Inject adds the given object to a set of objects held in memory by the
+// database, which is searched before its storage whenever an object is read,
+// and returns the object's name without writing it. It allows objects built
+// speculatively, such as the trees of a commit being rewritten, to be read
+// back (and referenced by other objects which are read) before deciding
+// whether to write them.
+//
+// Injected objects are seen only by the *ObjectDatabase (or view) into which
+// they were injected, and are held in memory until they are discarded (see:
+// DiscardInjected), or the database is closed. They are not enumerated (as by
+// ForEachObject), nor may they be opened in their stored form (see: OpenRaw).
+//
+// The object is prepared as if it were being written (see: StrictWrites,
+// CanonicalTrees), and the contents of a *Blob are consumed.
+func (o *ObjectDatabase) Inject(object Object) ([]byte, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	object, err := o.prepare(object)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents bytes.Buffer
+	if _, err := object.Encode(&contents); err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	fmt.Fprintf(&data, "%s %d\x00", object.Type(), contents.Len())
+	data.Write(contents.Bytes())
+
+	sum := o.Hasher()
+	sum.Write(data.Bytes())
+	sha := sum.Sum(nil)
+
+	o.injectedMu.Lock()
+	defer o.injectedMu.Unlock()
+
+	if o.injected == nil {
+		o.injected = make(map[string][]byte)
+	}
+	o.injected[string(sha)] = data.Bytes()
+
+	return sha, nil
+}
+
+// DiscardInjected discards each object injected into the database (see:
+// Inject), after which they may only be read if they were written.
+func (o *ObjectDatabase) DiscardInjected() {
+	o.injectedMu.Lock()
+	defer o.injectedMu.Unlock()
+
+	o.injected = nil
+}
+
+// openInjected returns an *ObjectReader over the injected object named "sha",
+// and whether there is one.
+func (o *ObjectDatabase) openInjected(sha []byte) (*ObjectReader, bool) {
+	o.injectedMu.RLock()
+	data, ok := o.injected[string(sha)]
+	o.injectedMu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	r := newUncompressedObjectReadCloser(ioutil.NopCloser(bytes.NewReader(data)), false)
+	r.strict = o.strictObjectTypes
+	return r, true
+}
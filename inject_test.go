@@ -0,0 +1,62 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectedObjectsAreReadWithoutBeingWritten(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: blob, Filemode: 0100644},
+	}}
+	sha, err := db.Inject(tree)
+	require.NoError(t, err)
+
+	expected, err := db.HashTree(tree)
+	require.NoError(t, err)
+	assert.Equal(t, expected, sha)
+
+	got, err := db.Tree(sha)
+	require.NoError(t, err)
+	assert.True(t, tree.Equal(got))
+
+	typ, size, err := db.ObjectInfo(sha)
+	require.NoError(t, err)
+	assert.Equal(t, TreeObjectType, typ)
+	assert.EqualValues(t, 33, size)
+
+	// Walking the injected tree reads the stored blob.
+	entry, err := db.EntryAtPath(sha, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, blob, entry.Oid)
+
+	// The tree was not written.
+	db.DiscardInjected()
+	_, err = db.Tree(sha)
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+func TestInjectedObjectsAreNotSharedWithViews(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	view := db.View()
+	defer view.Close()
+
+	sha, err := view.Inject(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	_, _, err = view.ObjectInfo(sha)
+	assert.NoError(t, err)
+
+	_, _, err = db.ObjectInfo(sha)
+	assert.True(t, errors.IsNoSuchObject(err))
+}
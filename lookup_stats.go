@@ -0,0 +1,99 @@
+package gitobj
+
+import (
+	"math"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// LookupStat records how many objects were read from a single storage tier:
+// the loose objects or the packfiles of one objects directory.
+type LookupStat struct {
+	// Root is the objects directory of the tier.
+	Root string
+	// Packed is true if the tier holds the directory's packfiles, and false
+	// if it holds its loose objects.
+	Packed bool
+	// Alternate is true if the directory is an alternate object database,
+	// rather than the repository's own (or its quarantine directory).
+	Alternate bool
+	// Lookups is the number of objects read from the tier.
+	Lookups uint64
+}
+
+// LookupStats returns the number of objects read from each storage tier since
+// the database was opened (or ResetLookupStats was last called), in the order
+// in which tiers are searched, so that it can be seen where objects are
+// actually read from when alternates are in use.
+//
+// Reads through every view of the database are included. Objects whose types
+// and sizes were read by ObjectInfo or ForEachObject are not counted. If the
+// database is not backed by the filesystem, LookupStats returns nil.
+func (o *ObjectDatabase) LookupStats() []*LookupStat {
+	type counter interface {
+		Storages() []storage.Storage
+		Lookups() []uint64
+	}
+
+	c, ok := o.ro.(counter)
+	if !ok {
+		return nil
+	}
+
+	lookups := c.Lookups()
+
+	var stats []*LookupStat
+	var loose *fileStorer
+	for i, s := range c.Storages() {
+		stat := &LookupStat{
+			Alternate: i >= o.local,
+			Lookups:   lookups[i],
+		}
+
+		switch s := s.(type) {
+		case *fileStorer:
+			stat.Root, loose = s.Root(), s
+		case *pack.Storage:
+			stat.Root, stat.Packed = s.Root(), true
+			if loose != nil && loose.root == s.Root() {
+				// Report the objects directory as it was given,
+				// rather than the extended-length form in which
+				// it is opened on Windows.
+				stat.Root = loose.Root()
+			}
+		default:
+			continue
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// ResetLookupStats resets the counts returned by LookupStats to zero, for
+// instance between runs of a long-lived process.
+func (o *ObjectDatabase) ResetLookupStats() {
+	type resetter interface {
+		ResetLookups()
+	}
+
+	if r, ok := o.ro.(resetter); ok {
+		r.ResetLookups()
+	}
+}
+
+// backendLocalStorages returns the number of storages provided by the given
+// storage backend which belong to the repository itself, rather than to
+// alternates. If the backend does not distinguish them, all are considered to
+// belong to the repository.
+func backendLocalStorages(b storage.Backend) int {
+	type localStorer interface {
+		localStorages() int
+	}
+
+	if l, ok := b.(localStorer); ok {
+		return l.localStorages()
+	}
+
+	return math.MaxInt32
+}
@@ -0,0 +1,62 @@
+package gitobj
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupStatsRecordAlternateUsage(t *testing.T) {
+	alternate, err := ioutil.TempDir("", "gitobj-alternate")
+	require.NoError(t, err)
+	defer os.RemoveAll(alternate)
+
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	adb, err := FromFilesystem(alternate, "", PackedWrites())
+	require.NoError(t, err)
+	shared, err := adb.WriteBlob(NewBlobFromBytes([]byte("shared\n")))
+	require.NoError(t, err)
+	require.NoError(t, adb.Close())
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "info"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "info", "alternates"),
+		[]byte(alternate+"\n"), 0644))
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	local, err := db.WriteBlob(NewBlobFromBytes([]byte("local\n")))
+	require.NoError(t, err)
+
+	for _, sha := range [][]byte{local, shared, shared} {
+		b, err := db.View().Blob(sha)
+		require.NoError(t, err)
+		require.NoError(t, b.Close())
+	}
+
+	assert.Equal(t, []*LookupStat{
+		{Root: root, Packed: false, Alternate: false, Lookups: 1},
+		{Root: root, Packed: true, Alternate: false, Lookups: 0},
+		{Root: alternate, Packed: false, Alternate: true, Lookups: 0},
+		{Root: alternate, Packed: true, Alternate: true, Lookups: 2},
+	}, db.LookupStats())
+
+	db.ResetLookupStats()
+	for _, stat := range db.LookupStats() {
+		assert.EqualValues(t, 0, stat.Lookups)
+	}
+}
+
+func TestLookupStatsWithAMemoryDatabase(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	assert.Nil(t, db.LookupStats())
+}
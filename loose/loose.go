@@ -0,0 +1,197 @@
+// Package loose implements the encoding of Git's loose objects, each of which
+// is stored in its own file in an objects directory: a header, "<type>
+// <size>\x00", followed by the object's contents, all compressed with zlib.
+//
+// It is used by gitobj to read and write loose objects, and may be used
+// without an object database by programs which handle individual object files,
+// such as those inspecting a damaged repository.
+package loose
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// DefaultMaxHeaderLength is the greatest length of a header, including
+	// its terminating NUL byte, which is read by ReadHeader. It leaves ample
+	// room for the header of any object written by Git.
+	DefaultMaxHeaderLength = 64
+)
+
+// HeaderTooLongErr is a type implementing 'error' which indicates that the
+// header of a loose object is not terminated within the greatest length which
+// may be read (see: ReadHeaderLimit).
+type HeaderTooLongErr struct {
+	// Limit is the greatest length of header which may be read.
+	Limit int
+}
+
+// Error implements 'error.Error()'.
+func (h *HeaderTooLongErr) Error() string {
+	return fmt.Sprintf("gitobj/loose: object header exceeds %d byte(s)", h.Limit)
+}
+
+// ReadHeader reads the header of a loose object, "<type> <size>\x00", from "r",
+// which reads the object's inflated contents, and returns the type and size
+// which it gives. The type is returned as it is given, without checking that
+// it is one known to Git. Once the header is read, "r" is positioned at the
+// start of the object's contents.
+//
+// Headers longer than DefaultMaxHeaderLength are not read, and a
+// *HeaderTooLongErr is returned instead (see: ReadHeaderLimit).
+func ReadHeader(r *bufio.Reader) (typ string, size int64, err error) {
+	return ReadHeaderLimit(r, DefaultMaxHeaderLength)
+}
+
+// ReadHeaderLimit is as ReadHeader, but reads headers of up to "max" bytes,
+// including the terminating NUL byte, so that objects from an untrusted source
+// cannot cause an unbounded read. If "max" is not positive, the length of the
+// header is not limited.
+func ReadHeaderLimit(r *bufio.Reader, max int) (typ string, size int64, err error) {
+	var hdr []byte
+	for {
+		if max > 0 && len(hdr) == max {
+			return "", 0, &HeaderTooLongErr{Limit: max}
+		}
+
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", 0, err
+		}
+		if c == 0 {
+			break
+		}
+		hdr = append(hdr, c)
+	}
+
+	sp := bytes.IndexByte(hdr, ' ')
+	if sp < 0 {
+		return "", 0, fmt.Errorf("gitobj/loose: object header has no size")
+	}
+	typ = string(hdr[:sp])
+	if len(typ) == 0 {
+		return "", 0, fmt.Errorf("gitobj/loose: object type must not be empty")
+	}
+
+	size, err = strconv.ParseInt(string(hdr[sp+1:]), 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	if size < 0 {
+		return "", 0, fmt.Errorf("gitobj/loose: invalid object size %d", size)
+	}
+	return typ, size, nil
+}
+
+// WriteHeader writes the header of a loose object of the given type and size to
+// "w", and returns the number of bytes written.
+func WriteHeader(w io.Writer, typ string, size int64) (int, error) {
+	return fmt.Fprintf(w, "%s %d\x00", typ, size)
+}
+
+// Reader reads the contents of a loose object from its compressed form.
+type Reader struct {
+	// Type is the type of the object, as given by its header.
+	Type string
+	// Size is the size of the object's contents, as given by its header.
+	Size int64
+
+	// zr is the reader inflating the object.
+	zr io.ReadCloser
+	// r reads the object's contents, following its header.
+	r io.Reader
+}
+
+// NewReader returns a *Reader inflating the loose object read from "r", once
+// its header has been read, or an error if the object is not compressed, or its
+// header is malformed.
+func NewReader(r io.Reader) (*Reader, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(zr)
+	typ, size, err := ReadHeader(br)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+
+	return &Reader{
+		Type: typ,
+		Size: size,
+		zr:   zr,
+		r:    io.LimitReader(br, size),
+	}, nil
+}
+
+// Read reads the contents of the object into "p". Reading stops once Size bytes
+// have been read, and fails with io.ErrUnexpectedEOF if the object holds fewer.
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF && r.r.(*io.LimitedReader).N > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Close releases the resources held by the *Reader. It does not close the
+// io.Reader from which the object was read.
+func (r *Reader) Close() error {
+	return r.zr.Close()
+}
+
+// Writer writes a loose object in its compressed form.
+type Writer struct {
+	// zw is the writer compressing the object.
+	zw *zlib.Writer
+	// remaining is the number of bytes of the object's contents which
+	// remain to be written.
+	remaining int64
+}
+
+// NewWriter returns a *Writer which writes the loose object of the given type
+// and size to "w", compressed at the given zlib compression level (such as
+// zlib.DefaultCompression), once its header has been written.
+func NewWriter(w io.Writer, typ string, size int64, level int) (*Writer, error) {
+	zw, err := zlib.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := WriteHeader(zw, typ, size); err != nil {
+		return nil, err
+	}
+	return &Writer{zw: zw, remaining: size}, nil
+}
+
+// Write writes "p" to the object's contents, or returns an error, without
+// writing anything, if it holds more bytes than remain to be written of the
+// size given to NewWriter.
+func (w *Writer) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.remaining {
+		return 0, fmt.Errorf("gitobj/loose: object has more bytes than its header gives")
+	}
+
+	n, err := w.zw.Write(p)
+	w.remaining -= int64(n)
+	return n, err
+}
+
+// Close flushes the compressed object to the io.Writer given to NewWriter, or
+// returns an error if fewer bytes were written than the size given to
+// NewWriter. It does not close that io.Writer.
+func (w *Writer) Close() error {
+	if err := w.zw.Close(); err != nil {
+		return err
+	}
+	if w.remaining > 0 {
+		return fmt.Errorf("gitobj/loose: object has %d byte(s) fewer than its header gives", w.remaining)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package loose
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHeader(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("blob 14\x00Hello, world!\n"))
+
+	typ, size, err := ReadHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, "blob", typ)
+	assert.EqualValues(t, 14, size)
+
+	rest, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(rest))
+}
+
+func TestReadHeaderRejectsMalformedHeaders(t *testing.T) {
+	for _, header := range []string{" 14\x00", "blob", "blob 14", "blob x\x00"} {
+		_, _, err := ReadHeader(bufio.NewReader(strings.NewReader(header)))
+		assert.Error(t, err, "header %q", header)
+	}
+}
+
+func TestWriterAndReaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := NewWriter(&buf, "blob", 14, zlib.BestSpeed)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "Hello, world!\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	defer r.Close()
+
+	assert.Equal(t, "blob", r.Type)
+	assert.EqualValues(t, 14, r.Size)
+
+	contents, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+}
+
+func TestWriterRejectsContentsOfTheWrongSize(t *testing.T) {
+	w, err := NewWriter(ioutil.Discard, "blob", 5, zlib.DefaultCompression)
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "Hello, world!\n")
+	assert.EqualError(t, err, "gitobj/loose: object has more bytes than its header gives")
+
+	_, err = io.WriteString(w, "Hi")
+	require.NoError(t, err)
+	assert.EqualError(t, w.Close(), "gitobj/loose: object has 3 byte(s) fewer than its header gives")
+}
+
+func TestReaderReportsTruncatedObjects(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := io.WriteString(zw, "blob 14\x00Hello")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	defer r.Close()
+
+	_, err = ioutil.ReadAll(r)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestReadHeaderLimit(t *testing.T) {
+	_, _, err := ReadHeaderLimit(bufio.NewReader(strings.NewReader("blob 14\x00")), 7)
+	assert.Equal(t, &HeaderTooLongErr{Limit: 7}, err)
+
+	typ, size, err := ReadHeaderLimit(bufio.NewReader(strings.NewReader("blob 14\x00")), 8)
+	require.NoError(t, err)
+	assert.Equal(t, "blob", typ)
+	assert.EqualValues(t, 14, size)
+
+	long := strings.Repeat("x", DefaultMaxHeaderLength)
+	_, _, err = ReadHeader(bufio.NewReader(strings.NewReader(long + " 14\x00")))
+	assert.Equal(t, &HeaderTooLongErr{Limit: DefaultMaxHeaderLength}, err)
+
+	typ, _, err = ReadHeaderLimit(bufio.NewReader(strings.NewReader(long+" 14\x00")), 0)
+	require.NoError(t, err)
+	assert.Equal(t, long, typ)
+}
@@ -0,0 +1,174 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// LooseObject is an object stored as a loose object file in the repository's
+// objects directory.
+type LooseObject struct {
+	// Oid is the name of the object.
+	Oid []byte
+	// Path is the path of the loose object file.
+	Path string
+	// Size is the size of the loose object file, which holds the object's
+	// compressed contents.
+	Size int64
+	// ModTime is the time at which the loose object file was last
+	// modified, which is usually when the object was written.
+	ModTime time.Time
+}
+
+// LooseObjects returns each loose object in the objects directory to which the
+// database writes (that is, excluding those of its alternates) whose file was
+// last modified before "cutoff", in ascending order of their names. A zero
+// "cutoff" returns every loose object. Staged objects which have not yet been
+// flushed are not included.
+//
+// Together with RemoveLooseObjects and PackLooseObjects, this allows temporary
+// objects written during a rewrite to be cleaned up without running "git gc".
+//
+// If the database is not stored on disk, an error is returned.
+func (o *ObjectDatabase) LooseObjects(cutoff time.Time) ([]*LooseObject, error) {
+	fs, err := o.looseStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*LooseObject
+	err = fs.eachFile(func(sha []byte, path string, fi os.FileInfo) error {
+		if !cutoff.IsZero() && !fi.ModTime().Before(cutoff) {
+			return nil
+		}
+		objects = append(objects, &LooseObject{
+			Oid:     sha,
+			Path:    path,
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return bytes.Compare(objects[i].Oid, objects[j].Oid) < 0
+	})
+	return objects, nil
+}
+
+// RemoveLooseObjects removes the loose object files of the objects with the
+// given names from the objects directory to which the database writes, along
+// with each fan-out directory left empty, and returns the number of files
+// removed. Objects which are not stored as loose objects there, such as those
+// which are packed, staged, or held by an alternate, are left as they are.
+//
+// Objects are removed regardless of whether anything refers to them, so the
+// caller must be sure that they are no longer needed, or are also stored
+// elsewhere (see: PackLooseObjects).
+func (o *ObjectDatabase) RemoveLooseObjects(oids [][]byte) (int, error) {
+	fs, err := o.looseStorage()
+	if err != nil {
+		return 0, err
+	}
+
+	hashlen := o.Hasher().Size()
+	dirs := make(map[string]struct{})
+
+	var n int
+	for _, sha := range oids {
+		if len(sha) != hashlen {
+			return n, fmt.Errorf("gitobj: invalid object name %x", sha)
+		}
+
+		path := fs.loosePath(sha)
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return n, err
+		}
+		dirs[filepath.Dir(path)] = struct{}{}
+		n++
+	}
+
+	for dir := range dirs {
+		// Directories which still hold other objects are not empty,
+		// and so are not removed.
+		os.Remove(dir)
+	}
+	return n, nil
+}
+
+// PackLooseObjects writes the loose objects with the given names, from the
+// objects directory to which the database writes, into a new packfile (and
+// index) there, which is made available for reading, and then removes them as
+// RemoveLooseObjects does, returning the path of the packfile. Objects which
+// are not stored as loose objects there are not packed, and if none are, no
+// packfile is written, and the empty string is returned.
+//
+// To consolidate every loose object into a packfile, pass the names of those
+// returned by LooseObjects.
+func (o *ObjectDatabase) PackLooseObjects(oids [][]byte) (string, error) {
+	fs, err := o.looseStorage()
+	if err != nil {
+		return "", err
+	}
+
+	hashlen := o.Hasher().Size()
+	files := make(map[string]string, len(oids))
+	for _, sha := range oids {
+		if len(sha) != hashlen {
+			return "", fmt.Errorf("gitobj: invalid object name %x", sha)
+		}
+
+		path := fs.loosePath(sha)
+		if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		files[string(sha)] = path
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	path, err := fs.writePack(files, o.Hasher())
+	if err != nil {
+		return "", err
+	}
+	// Read the new packfile before removing the loose objects, so that
+	// they remain readable throughout.
+	if err := o.Reload(); err != nil {
+		return "", err
+	}
+
+	packed := make([][]byte, 0, len(files))
+	for sha := range files {
+		packed = append(packed, []byte(sha))
+	}
+	if _, err := o.RemoveLooseObjects(packed); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// looseStorage returns the storage holding the repository's own loose objects,
+// to which objects are written, or an error if the database is closed, or is
+// not stored on disk.
+func (o *ObjectDatabase) looseStorage() (*fileStorer, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	fs, ok := o.rw.(*fileStorer)
+	if !ok {
+		return nil, fmt.Errorf("gitobj: database has no loose objects")
+	}
+	return fs, nil
+}
@@ -0,0 +1,125 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLooseObjectsReturnsObjectsOlderThanCutoff(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	old, err := db.WriteBlob(NewBlobFromBytes([]byte("old\n")))
+	require.NoError(t, err)
+	recent, err := db.WriteBlob(NewBlobFromBytes([]byte("recent\n")))
+	require.NoError(t, err)
+
+	then := time.Now().Add(-48 * time.Hour)
+	path := filepath.Join(root, hex.EncodeToString(old)[:2], hex.EncodeToString(old)[2:])
+	require.NoError(t, os.Chtimes(path, then, then))
+
+	objects, err := db.LooseObjects(time.Now().Add(-24 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, old, objects[0].Oid)
+	assert.Equal(t, path, objects[0].Path)
+	assert.Equal(t, then.Unix(), objects[0].ModTime.Unix())
+
+	objects, err = db.LooseObjects(time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, objects, 2)
+	for _, o := range objects {
+		assert.Contains(t, [][]byte{old, recent}, o.Oid)
+	}
+}
+
+func TestRemoveLooseObjectsRemovesEmptyDirectories(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	blob := writeTestBlob(t, db)
+	missing := make([]byte, 20)
+
+	n, err := db.RemoveLooseObjects([][]byte{blob, missing})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = os.Stat(filepath.Join(root, hex.EncodeToString(blob)[:2]))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = db.Blob(blob)
+	assert.Error(t, err)
+}
+
+func TestPackLooseObjectsConsolidatesLooseObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	blob := writeTestBlob(t, db)
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	objects, err := db.LooseObjects(time.Time{})
+	require.NoError(t, err)
+
+	var oids [][]byte
+	for _, o := range objects {
+		oids = append(oids, o.Oid)
+	}
+
+	path, err := db.PackLooseObjects(oids)
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+	assert.FileExists(t, path[:len(path)-len(".pack")]+".idx")
+
+	objects, err = db.LooseObjects(time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+
+	tr, err := db.Tree(tree)
+	require.NoError(t, err)
+	assert.Equal(t, blob, tr.Entries[0].Oid)
+
+	b, err := db.Blob(blob)
+	require.NoError(t, err)
+	defer b.Close()
+	contents, err := ioutil.ReadAll(b.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+
+	// There are no loose objects left to pack.
+	path, err = db.PackLooseObjects(oids)
+	require.NoError(t, err)
+	assert.Empty(t, path)
+}
+
+func TestLooseObjectsWithAMemoryDatabase(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	_, err := db.LooseObjects(time.Time{})
+	assert.EqualError(t, err, "gitobj: database has no loose objects")
+}
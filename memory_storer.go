@@ -2,8 +2,10 @@ package gitobj
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"sync"
 
 	"github.com/git-lfs/gitobj/v2/errors"
@@ -38,29 +40,85 @@ func newMemoryStorer(m map[string]io.ReadWriter) *memoryStorer {
 
 // Store implements the storer.Store function and copies the data given in "r"
 // into an object entry in the memory. If an object given by that SHA "sha" is
-// already indexed in the database, Store will panic().
+// already indexed in the database, it is replaced.
+//
+// The object is only added once all of "r" has been copied, so that a failed
+// copy leaves no partial object behind.
 func (ms *memoryStorer) Store(sha []byte, r io.Reader) (n int64, err error) {
+	buf := new(bytes.Buffer)
+	if n, err = io.Copy(buf, r); err != nil {
+		return n, err
+	}
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	key := fmt.Sprintf("%x", sha)
+	ms.fs[fmt.Sprintf("%x", sha)] = &bufCloser{buf}
+	return n, nil
+}
 
-	ms.fs[key] = &bufCloser{new(bytes.Buffer)}
-	return io.Copy(ms.fs[key], r)
+// Has implements the storage.HasStorage interface, and returns whether an
+// object with the given SHA is held by the memory storer.
+func (ms *memoryStorer) Has(sha []byte) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	_, ok := ms.fs[fmt.Sprintf("%x", sha)]
+	return ok, nil
 }
 
-// Open implements the storer.Open function, and returns a io.ReadWriteCloser
-// for the given SHA. If a reader for the given SHA does not exist an error will
-// be returned.
+// Enumerate implements the storage.EnumerableStorage interface, and calls "fn"
+// with the name of each object held by the memory storer, in no particular
+// order.
+//
+// If "fn" returns an error, Enumerate stops, and returns that error.
+func (ms *memoryStorer) Enumerate(fn func(sha []byte) error) error {
+	ms.mu.Lock()
+	keys := make([]string, 0, len(ms.fs))
+	for key := range ms.fs {
+		keys = append(keys, key)
+	}
+	ms.mu.Unlock()
+
+	for _, key := range keys {
+		sha, err := hex.DecodeString(key)
+		if err != nil {
+			return err
+		}
+		if err := fn(sha); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open implements the storer.Open function, and returns a io.ReadCloser for
+// the given SHA. If a reader for the given SHA does not exist an error will be
+// returned.
 func (ms *memoryStorer) Open(sha []byte) (f io.ReadCloser, err error) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
 	key := fmt.Sprintf("%x", sha)
-	if _, ok := ms.fs[key]; !ok {
+	entry, ok := ms.fs[key]
+	if !ok {
 		return nil, errors.NoSuchObject(sha)
 	}
-	return ms.fs[key], nil
+
+	// Reading from the stored io.ReadWriter would consume its contents,
+	// so that the object could only be opened once. Instead, hand out an
+	// independent reader over a buffered copy of those contents.
+	buf, ok := entry.ReadWriter.(*bytes.Buffer)
+	if !ok {
+		data, err := ioutil.ReadAll(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		buf = bytes.NewBuffer(data)
+		ms.fs[key] = &bufCloser{buf}
+	}
+	return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
 }
 
 // Close closes the memory storer.
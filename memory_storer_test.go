@@ -9,6 +9,8 @@ import (
 	"testing"
 
 	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/storage"
+	"github.com/git-lfs/gitobj/v2/storage/storagetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -87,3 +89,28 @@ func TestMemoryStorerStoresExistingEntries(t *testing.T) {
 	assert.Nil(t, err)
 	assert.EqualValues(t, 0, n)
 }
+
+func TestMemoryStorerOpensEntriesMoreThanOnce(t *testing.T) {
+	hex, err := hex.DecodeString("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	assert.Nil(t, err)
+
+	ms := newMemoryStorer(nil)
+
+	_, err = ms.Store(hex, strings.NewReader("hello"))
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		got, err := ms.Open(hex)
+		assert.Nil(t, err)
+
+		contents, err := ioutil.ReadAll(got)
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", string(contents))
+	}
+}
+
+func TestMemoryStorerConformance(t *testing.T) {
+	storagetest.TestStorage(t, func(t *testing.T) storage.WritableStorage {
+		return newMemoryStorer(nil)
+	})
+}
@@ -0,0 +1,161 @@
+package gitobj
+
+import (
+	"strings"
+)
+
+// NormalizeMessage normalizes a commit or tag message in the same way as Git
+// (see: git-stripspace(1)). It converts CRLF and CR line endings to LF, removes
+// trailing whitespace from each line, collapses consecutive blank lines into a
+// single blank line, and removes blank lines from the beginning and end of the
+// message. Unless the result is empty, it is terminated by a single newline.
+//
+// Lines beginning with a comment character are not removed.
+func NormalizeMessage(msg string) string {
+	lines := messageLines(msg)
+
+	var b strings.Builder
+	var blank bool
+	for _, line := range lines {
+		if len(line) == 0 {
+			blank = b.Len() > 0
+			continue
+		}
+
+		if blank {
+			b.WriteByte('\n')
+			blank = false
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// MessageSubject returns the subject of a commit or tag message, which, as in
+// Git, is its first paragraph with each of its lines joined by a single space.
+func MessageSubject(msg string) string {
+	subject, _ := splitMessage(msg)
+	return strings.Join(subject, " ")
+}
+
+// MessageBody returns the body of a commit or tag message, which is everything
+// following its subject (see: MessageSubject), normalized as by
+// NormalizeMessage. If the message has no body, the empty string is returned.
+func MessageBody(msg string) string {
+	_, body := splitMessage(msg)
+	return NormalizeMessage(strings.Join(body, "\n"))
+}
+
+// WrapMessage normalizes the given commit or tag message (see:
+// NormalizeMessage), and then re-wraps each paragraph of its body such that no
+// line is longer than "width" characters, unless it holds a single word which
+// is longer than that.
+//
+// The subject, and any paragraph containing a line which begins with
+// whitespace (such as an indented code sample or a list), are left as-is.
+func WrapMessage(msg string, width int) string {
+	subject, body := splitMessage(msg)
+	if len(subject) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(subject, "\n"))
+	b.WriteByte('\n')
+
+	for _, para := range messageParagraphs(body) {
+		b.WriteByte('\n')
+
+		if isPreformatted(para) {
+			b.WriteString(strings.Join(para, "\n"))
+			b.WriteByte('\n')
+			continue
+		}
+
+		var n int
+		for _, word := range strings.Fields(strings.Join(para, " ")) {
+			if n > 0 && n+1+len(word) > width {
+				b.WriteByte('\n')
+				n = 0
+			}
+			if n > 0 {
+				b.WriteByte(' ')
+				n++
+			}
+			b.WriteString(word)
+			n += len(word)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Subject returns the subject of the commit's message (see: MessageSubject).
+func (c *Commit) Subject() string {
+	return MessageSubject(c.Message)
+}
+
+// Body returns the body of the commit's message (see: MessageBody).
+func (c *Commit) Body() string {
+	return MessageBody(c.Message)
+}
+
+// messageLines returns the lines of the given message, with line endings
+// converted to LF, and trailing whitespace removed from each line.
+func messageLines(msg string) []string {
+	msg = strings.Replace(msg, "\r\n", "\n", -1)
+	msg = strings.Replace(msg, "\r", "\n", -1)
+
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\v\f")
+	}
+	return lines
+}
+
+// splitMessage returns the lines of the first paragraph of the normalized
+// message, followed by the lines of the remainder.
+func splitMessage(msg string) (subject, body []string) {
+	lines := messageLines(NormalizeMessage(msg))
+	// Drop the empty element following the final newline.
+	lines = lines[:len(lines)-1]
+
+	for i, line := range lines {
+		if len(line) == 0 {
+			return lines[:i], lines[i+1:]
+		}
+	}
+	return lines, nil
+}
+
+// messageParagraphs splits the given normalized lines into paragraphs, which
+// are separated by a single blank line.
+func messageParagraphs(lines []string) [][]string {
+	var paras [][]string
+	var para []string
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			paras = append(paras, para)
+			para = nil
+			continue
+		}
+		para = append(para, line)
+	}
+	if len(para) > 0 {
+		paras = append(paras, para)
+	}
+	return paras
+}
+
+// isPreformatted returns whether any line of the given paragraph begins with
+// whitespace.
+func isPreformatted(para []string) bool {
+	for _, line := range para {
+		if line[0] == ' ' || line[0] == '\t' {
+			return true
+		}
+	}
+	return false
+}
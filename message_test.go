@@ -0,0 +1,71 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	for desc, c := range map[string]struct {
+		msg, want string
+	}{
+		"empty":           {"", ""},
+		"only blank":      {"\n \n\t\n", ""},
+		"missing newline": {"subject", "subject\n"},
+		"crlf":            {"subject\r\n\r\nbody\r\n", "subject\n\nbody\n"},
+		"cr":              {"subject\r\rbody\r", "subject\n\nbody\n"},
+		"trailing space":  {"subject  \n\nbody\t\n", "subject\n\nbody\n"},
+		"blank runs":      {"\n\nsubject\n\n\n\nbody\n\n\n", "subject\n\nbody\n"},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, c.want, NormalizeMessage(c.msg))
+		})
+	}
+}
+
+func TestMessageSubjectAndBody(t *testing.T) {
+	msg := "Fix the thing\r\nin two lines\r\n\r\n\r\nBecause it was broken.\r\n\r\nSigned-off-by: A U Thor <author@example.com>"
+
+	assert.Equal(t, "Fix the thing in two lines", MessageSubject(msg))
+	assert.Equal(t, "Because it was broken.\n\nSigned-off-by: A U Thor <author@example.com>\n", MessageBody(msg))
+}
+
+func TestMessageWithoutBody(t *testing.T) {
+	assert.Equal(t, "Subject only", MessageSubject("Subject only\n\n"))
+	assert.Equal(t, "", MessageBody("Subject only\n\n"))
+
+	assert.Equal(t, "", MessageSubject(""))
+	assert.Equal(t, "", MessageBody(""))
+}
+
+func TestCommitSubjectAndBody(t *testing.T) {
+	c := &Commit{Message: "Subject\n\nBody"}
+
+	assert.Equal(t, "Subject", c.Subject())
+	assert.Equal(t, "Body\n", c.Body())
+}
+
+func TestWrapMessage(t *testing.T) {
+	msg := "A subject which is longer than the width is left alone\n" +
+		"\n" +
+		"This paragraph is\nwrapped to fit within the given width.\n" +
+		"\n" +
+		"    indented code\n" +
+		"    is left alone\n" +
+		"\n" +
+		"Averyveryverylongword is not split."
+
+	assert.Equal(t, "A subject which is longer than the width is left alone\n"+
+		"\n"+
+		"This paragraph is\n"+
+		"wrapped to fit\n"+
+		"within the given\n"+
+		"width.\n"+
+		"\n"+
+		"    indented code\n"+
+		"    is left alone\n"+
+		"\n"+
+		"Averyveryverylongword\n"+
+		"is not split.\n", WrapMessage(msg, 20))
+}
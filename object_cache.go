@@ -0,0 +1,179 @@
+package gitobj
+
+import (
+	"container/list"
+	"sync"
+)
+
+// objectCache is a least-recently-used cache of decoded trees and commits,
+// keyed by their names, and shared between a database and its views. It is safe
+// for concurrent use.
+//
+// Objects are copied as they are added to and returned from the cache, so that
+// callers may modify them freely.
+type objectCache struct {
+	// limit is the maximum (approximate) number of bytes held by the
+	// cache.
+	limit int64
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// size is the (approximate) number of bytes held by the cache.
+	size int64
+	// lru holds an *objectCacheEntry for each cached object, with the most
+	// recently used at the front.
+	lru *list.List
+	// entries maps the name of each cached object to its element in
+	// "lru".
+	entries map[string]*list.Element
+	// hits and misses are the number of lookups of objects which were, and
+	// were not, present in the cache.
+	hits, misses uint64
+}
+
+// objectCacheEntry is a single object held by an *objectCache.
+type objectCacheEntry struct {
+	sha    string
+	object Object
+	size   int64
+}
+
+// newObjectCache returns a new *objectCache holding at most "limit" bytes, or
+// nil if "limit" is not positive.
+func newObjectCache(limit int64) *objectCache {
+	if limit <= 0 {
+		return nil
+	}
+
+	return &objectCache{
+		limit:   limit,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Tree returns a copy of the cached tree named by "sha", if there is one.
+func (c *objectCache) Tree(sha []byte) (*Tree, bool) {
+	if t, ok := c.get(sha).(*Tree); ok {
+		return copyTree(t), true
+	}
+	return nil, false
+}
+
+// Commit returns a copy of the cached commit named by "sha", if there is one.
+func (c *objectCache) Commit(sha []byte) (*Commit, bool) {
+	if commit, ok := c.get(sha).(*Commit); ok {
+		return copyCommit(commit), true
+	}
+	return nil, false
+}
+
+// AddTree adds a copy of the tree named by "sha" to the cache.
+func (c *objectCache) AddTree(sha []byte, t *Tree) {
+	if c == nil {
+		return
+	}
+
+	size := int64(len(sha))
+	for _, e := range t.Entries {
+		size += int64(len(e.Name)+len(e.Oid)) + 8
+	}
+	c.add(sha, copyTree(t), size)
+}
+
+// AddCommit adds a copy of the commit named by "sha" to the cache.
+func (c *objectCache) AddCommit(sha []byte, commit *Commit) {
+	if c == nil {
+		return
+	}
+
+	size := int64(len(sha) + len(commit.TreeID) + len(commit.Author) +
+		len(commit.Committer) + len(commit.Message))
+	for _, parent := range commit.ParentIDs {
+		size += int64(len(parent))
+	}
+	for _, hdr := range commit.ExtraHeaders {
+		size += int64(len(hdr.K) + len(hdr.V))
+	}
+	c.add(sha, copyCommit(commit), size)
+}
+
+// get returns the cached object named by "sha", or nil if there is none.
+func (c *objectCache) get(sha []byte) Object {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[string(sha)]
+	if !ok {
+		c.misses++
+		return nil
+	}
+	c.hits++
+	c.lru.MoveToFront(e)
+
+	return e.Value.(*objectCacheEntry).object
+}
+
+// stats returns the number of lookups of objects which were, and were not,
+// present in the cache.
+func (c *objectCache) stats() (hits, misses uint64) {
+	if c == nil {
+		return 0, 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+// add adds the given object, of the given size, to the cache, evicting the least
+// recently used objects as necessary to remain within its limit. Objects larger
+// than the limit are not cached.
+func (c *objectCache) add(sha []byte, object Object, size int64) {
+	if size > c.limit {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[string(sha)]; ok {
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	for c.size+size > c.limit {
+		oldest := c.lru.Back()
+		entry := oldest.Value.(*objectCacheEntry)
+
+		c.lru.Remove(oldest)
+		delete(c.entries, entry.sha)
+		c.size -= entry.size
+	}
+
+	c.entries[string(sha)] = c.lru.PushFront(&objectCacheEntry{
+		sha:    string(sha),
+		object: object,
+		size:   size,
+	})
+	c.size += size
+}
+
+// copyTree returns a deep copy of the given tree, which may be modified without
+// affecting the original.
+func copyTree(t *Tree) *Tree {
+	entries := make([]*TreeEntry, 0, len(t.Entries))
+	for _, e := range t.Entries {
+		entries = append(entries, &TreeEntry{
+			Name:     e.Name,
+			Oid:      append([]byte(nil), e.Oid...),
+			Filemode: e.Filemode,
+		})
+	}
+	return &Tree{Entries: entries}
+}
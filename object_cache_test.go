@@ -0,0 +1,96 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectCacheReadsTreesAndCommitsFromMemory(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b, ObjectCache(1024*1024))
+	require.NoError(t, err)
+
+	tree := writeTestTree(t, db)
+	commit := writeTestCommit(t, db, map[string]string{"a.txt": "a\n"}, 1000)
+
+	want, err := db.Tree(tree)
+	require.NoError(t, err)
+	wantCommit, err := db.Commit(commit)
+	require.NoError(t, err)
+
+	ms := db.rw.(*memoryStorer)
+	delete(ms.fs, hex.EncodeToString(tree))
+	delete(ms.fs, hex.EncodeToString(commit))
+
+	got, err := db.Tree(tree)
+	require.NoError(t, err)
+	assert.True(t, want.Equal(got))
+
+	gotCommit, err := db.View().Commit(commit)
+	require.NoError(t, err)
+	assert.True(t, wantCommit.Equal(gotCommit))
+}
+
+func TestObjectCacheReturnsCopies(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b, ObjectCache(1024*1024))
+	require.NoError(t, err)
+
+	sha := writeTestTree(t, db)
+
+	t1, err := db.Tree(sha)
+	require.NoError(t, err)
+	name := t1.Entries[0].Name
+	t1.Entries[0].Name = "modified"
+	t1.Entries = t1.Entries[1:]
+
+	t2, err := db.Tree(sha)
+	require.NoError(t, err)
+	assert.Len(t, t2.Entries, len(t1.Entries)+1)
+	assert.Equal(t, name, t2.Entries[0].Name)
+}
+
+func TestObjectCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newObjectCache(100)
+
+	tree := func(name string) *Tree {
+		return &Tree{Entries: []*TreeEntry{
+			{Name: name, Oid: make([]byte, 20), Filemode: 0100644},
+		}}
+	}
+
+	// Each tree is estimated at 20 + (4 + 20 + 8) = 52 bytes, so only one
+	// fits at a time.
+	c.AddTree([]byte("aaaaaaaaaaaaaaaaaaaa"), tree("aaaa"))
+	c.AddTree([]byte("bbbbbbbbbbbbbbbbbbbb"), tree("bbbb"))
+
+	_, ok := c.Tree([]byte("aaaaaaaaaaaaaaaaaaaa"))
+	assert.False(t, ok)
+	got, ok := c.Tree([]byte("bbbbbbbbbbbbbbbbbbbb"))
+	require.True(t, ok)
+	assert.Equal(t, "bbbb", got.Entries[0].Name)
+
+	_, ok = c.Commit([]byte("bbbbbbbbbbbbbbbbbbbb"))
+	assert.False(t, ok)
+}
+
+func TestObjectCacheDisabledByDefault(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha := writeTestTree(t, db)
+	_, err := db.Tree(sha)
+	require.NoError(t, err)
+
+	assert.Nil(t, db.cache)
+
+	delete(db.rw.(*memoryStorer).fs, hex.EncodeToString(sha))
+	_, err = db.Tree(sha)
+	assert.Error(t, err)
+}
@@ -2,20 +2,36 @@ package gitobj
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"crypto/sha256"
+	"compress/zlib"
+	"context"
 	"fmt"
 	"hash"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
 	"github.com/git-lfs/gitobj/v2/storage"
 )
 
 // ObjectDatabase enables the reading and writing of objects against a storage
 // backend.
+//
+// An *ObjectDatabase is safe for concurrent use by multiple goroutines, so that
+// a tree walk may be fanned out across a pool of workers sharing one database:
+// each object read draws a zlib reader of its own, and reads packfiles at
+// offsets of its own, while the state shared between reads, such as the
+// packfiles and their indexes, and the caches of objects and delta bases (see:
+// ObjectCache and DeltaBaseCacheLimit), is guarded by locks. The database must
+// not be closed while objects are being read from it. Each goroutine may
+// instead use a view of its own (see: View), which reuses its buffers between
+// writes.
 type ObjectDatabase struct {
 	// members managed via sync/atomic must be aligned at the top of this
 	// structure (see: https://github.com/git-lfs/git-lfs/pull/2880).
@@ -24,22 +40,181 @@ type ObjectDatabase struct {
 	// yields a value of 0 if the *ObjectDatabase it is stored upon is open,
 	// and a value of 1 if it is closed.
 	closed uint32
+	// generation is a uint32 managed by sync/atomic, which counts the
+	// number of times the *ObjectDatabase has been reopened. A view
+	// records the generation of its parent when it is created, and is
+	// closed once the parent's generation differs.
+	generation uint32
 
 	// ro is the locations from which we can read objects.
 	ro storage.Storage
 	// rw is the location to which we write objects.
 	rw storage.WritableStorage
+	// graph is the commit-graph from which commit metadata may be read,
+	// or nil if there is none.
+	graph *commitGraph
+	// checkCommitGraph indicates whether the metadata read from "graph"
+	// is checked against the commits themselves (see: CheckCommitGraph).
+	checkCommitGraph bool
+	// recoverTruncated indicates whether loose objects are checked before
+	// they are read, and intact copies of corrupt ones searched for
+	// elsewhere (see: RecoverTruncatedObjects).
+	recoverTruncated bool
+	// decodeStats indicates whether a description of how each object was
+	// read is attached to it (see: RecordDecodeStats).
+	decodeStats bool
+	// budget bounds the memory allocated to read objects from the
+	// database, and is shared with its views. It is nil if no limit was
+	// requested (see: MemoryLimit).
+	budget *pack.Budget
+	// abbrev is the fewest hexadecimal characters to which object IDs are
+	// abbreviated, or zero if it is chosen by the size of the database
+	// (see: AbbrevLength).
+	abbrev int
+	// local is the number of storages (see: storages) searched by "ro"
+	// which belong to the repository itself, rather than to alternates.
+	local int
+	// writes counts the objects written to the database, and is shared
+	// with its views.
+	writes *writeCounters
+	// reads counts the work done in reading objects from the database,
+	// and is shared with its views (see: Stats).
+	reads *readCounters
+	// cache holds recently decoded trees and commits, and is shared with
+	// its views. It is nil if no cache was requested (see: ObjectCache).
+	cache *objectCache
 
 	// temp directory, defaults to os.TempDir
 	tmp string
 
 	// objectFormat is the object format (hash algorithm)
 	objectFormat ObjectFormatAlgorithm
+	// compatObjectFormat is the compatibility object format, whose object
+	// IDs are also accepted by ParseOid, or the empty string if there is
+	// none (see: CompatObjectFormat).
+	compatObjectFormat ObjectFormatAlgorithm
+	// compat maps object IDs between the object format and the
+	// compatibility object format, and is shared with its views. It is nil
+	// if there is no compatibility object format.
+	compat *compatMap
+
+	// clock returns the time at which builders stamp identities given by
+	// name and email (see: Clock).
+	clock func() time.Time
+	// identity is the name and email of the identity with which builders
+	// fill in missing authors, committers, and taggers, or nil if there is
+	// none (see: DefaultIdentity).
+	identity *Signature
+	// headerCache holds the types and sizes of objects looked up by
+	// ObjectInfo, and is shared with its views. It is nil if no header
+	// cache was requested (see: HeaderCache).
+	headerCache *headerCache
+	// strictSignatures indicates whether the identities in commits and
+	// tags are validated (and normalized) as they are written.
+	strictSignatures bool
+	// strictWrites indicates whether trees, commits, and tags are
+	// validated as they are written (see: StrictWrites).
+	strictWrites bool
+	// strictObjectTypes indicates whether objects whose headers give an
+	// unknown type are rejected as they are read (see: StrictObjectTypes).
+	strictObjectTypes bool
+	// maxHeaderLength is the greatest length of object header which is
+	// read, or zero for loose.DefaultMaxHeaderLength (see: MaxHeaderLength).
+	maxHeaderLength int
+	// maxObjectSize is the greatest size of object which is read, or zero
+	// if sizes are not limited (see: MaxObjectSize).
+	maxObjectSize int64
+	// canonicalTrees indicates whether the entries of trees are sorted as
+	// they are written (see: CanonicalTrees).
+	canonicalTrees bool
+	// allowedTypes is a bitmask of the object types which may be decoded,
+	// with bit N set if the type whose value is N is allowed. If it is
+	// zero, all types are allowed.
+	allowedTypes uint8
+	// compressor is the Compressor with which loose objects are compressed
+	// as they are written, and decompressed as they are read.
+	compressor storage.Compressor
+	// compressionLevel is the level at which loose objects are compressed.
+	compressionLevel int
+	// unpooled indicates whether the buffers used to read objects are
+	// allocated anew for each object, rather than drawn from a pool.
+	unpooled bool
+	// replacements maps the hex-encoded names of replaced objects to
+	// those of the objects replacing them (see: ReplaceObjects).
+	replacements map[string]string
+
+	// backend returns the storage backend from which "ro" and "rw" are
+	// (re-)initialized when reopening a closed *ObjectDatabase. It is nil
+	// for databases which cannot be reopened.
+	backend func() (storage.Backend, error)
+
+	// parent is the *ObjectDatabase from which this one was created by a
+	// call to View(), or nil if it was not created that way.
+	parent *ObjectDatabase
+	// snapshot indicates whether this view was created by a call to
+	// Snapshot(), and so may not be reopened.
+	snapshot bool
+	// scratch is a buffer reused when encoding objects written through a
+	// view. It is nil for databases which are not views, since they may be
+	// used from many goroutines at once.
+	scratch *bytes.Buffer
+
+	// injectedMu guards "injected", which holds the loose form (that is,
+	// the header and contents, uncompressed) of each object injected into
+	// the database, keyed by its name (see: Inject).
+	injectedMu sync.RWMutex
+	injected   map[string][]byte
 }
 
 type options struct {
-	alternates   string
-	objectFormat ObjectFormatAlgorithm
+	alternates    string
+	objectFormat  ObjectFormatAlgorithm
+	batchedWrites bool
+	packedWrites  bool
+	syncedWrites  bool
+	quarantine    string
+
+	fsyncObjectFiles bool
+	sharedPerm       SharedPerm
+
+	maxAlternatesDepth int
+	environment        bool
+	gitRepoLayout      bool
+	diagnose           func(msg string)
+	alternatesLog      func(*AlternateDecision)
+	alternatesLogFile  bool
+
+	strictSignatures  bool
+	strictWrites      bool
+	strictObjectTypes bool
+	canonicalTrees    bool
+	allowedTypes      []ObjectType
+	maxHeaderLength   int
+	maxDeltaDepth     int
+	maxObjectSize     int64
+
+	compressor       storage.Compressor
+	compressionLevel int
+	unpooled         bool
+
+	deltaBaseCacheLimit int64
+	objectCacheSize     int64
+	checkCommitGraph    bool
+	headerCache         bool
+	recoverTruncated    bool
+	decodeStats         bool
+	verifiedPacks       bool
+	memoryLimit         int64
+	abbrev              int
+	maxOpenPackFiles    int
+	packFileHooks       *pack.FileHooks
+
+	replacements map[string]string
+
+	compatObjectFormat ObjectFormatAlgorithm
+
+	clock    func() time.Time
+	identity *Signature
 }
 
 type Option func(*options)
@@ -60,26 +235,602 @@ func Alternates(alternates string) Option {
 	}
 }
 
+// GitRepoLayout is an Option to support the layout of checkouts managed by
+// git-repo (as used by AOSP), in which the objects directory of each project is
+// a symbolic link to one shared beneath ".repo/project-objects", whose own
+// alternates (as of a "--reference" mirror) are written relative to where it
+// really is, rather than to the link.
+//
+// If the objects directory given to FromFilesystem is a symbolic link, its
+// target is registered as an alternate, after any others, so that its
+// alternates are found, and "diagnose" (if it is not nil) is called with a
+// message describing the link, so that users may be told to migrate to
+// alternates of their own.
+func GitRepoLayout(diagnose func(msg string)) Option {
+	return func(args *options) {
+		args.gitRepoLayout = true
+		args.diagnose = diagnose
+	}
+}
+
+// AlternatesLog is an Option to call "fn" with the decision made about each
+// alternate object database found while opening a repository with
+// FromFilesystem (or reopening it): whether it is used, or why it was skipped,
+// so that operators of shared object stores can audit why some were not used.
+// Alternates are reported in the order in which they are found.
+func AlternatesLog(fn func(d *AlternateDecision)) Option {
+	return func(args *options) {
+		args.alternatesLog = fn
+	}
+}
+
+// AlternatesLogFile is an Option to record the decision made about each
+// alternate object database found while opening a repository with
+// FromFilesystem, as by AlternatesLog, in the file
+// "info/gitobj-alternates-log" of its objects directory, one per line, headed
+// by the time at which it was opened. The file is replaced each time the
+// database is opened. Since the log is only diagnostic, any error in writing it
+// is not reported.
+func AlternatesLogFile() Option {
+	return func(args *options) {
+		args.alternatesLogFile = true
+	}
+}
+
+// EnvironmentOverrides is an Option to consult the GIT_OBJECT_DIRECTORY and
+// GIT_ALTERNATE_OBJECT_DIRECTORIES environment variables when opening a
+// database with FromFilesystem, as Git does, so that tools run from hooks (such
+// as "pre-receive", which receives objects into a quarantine directory) see the
+// same objects as Git itself.
+//
+// If GIT_OBJECT_DIRECTORY is set, objects are read from and written to it,
+// rather than the given root. The alternates listed in
+// GIT_ALTERNATE_OBJECT_DIRECTORIES (see: Alternates) are searched after any
+// given by the Alternates option.
+//
+// If GIT_QUARANTINE_PATH is set, as it is in the "pre-receive" hook, that
+// directory is used as by the Quarantine option (unless it is also given),
+// rather than in place of the given root, which should be the repository's own
+// objects directory.
+//
+// The environment is read once, when the database is opened.
+func EnvironmentOverrides() Option {
+	return func(args *options) {
+		args.environment = true
+	}
+}
+
+// MaxAlternatesDepth is an Option to limit the depth to which the alternates of
+// alternates are followed to "depth", rather than DefaultMaxAlternatesDepth.
+// The alternates listed by the repository itself (or given by the Alternates
+// option) are at depth zero, their own alternates are at depth one, and so on.
+// Alternates nested more deeply than "depth" are ignored, as they are by Git.
+func MaxAlternatesDepth(depth int) Option {
+	return func(args *options) {
+		args.maxAlternatesDepth = depth
+	}
+}
+
 // ObjectFormat is an Option to specify the hash algorithm (object format) in
-// use in Git.  If not specified, it defaults to ObjectFormatSHA1.
+// use in Git.  If not specified, it defaults to ObjectFormatSHA1. Formats other
+// than SHA-1 and SHA-256 must first be registered (see: RegisterObjectFormat).
 func ObjectFormat(algo ObjectFormatAlgorithm) Option {
 	return func(args *options) {
 		args.objectFormat = algo
 	}
 }
 
+// CompatObjectFormat is an Option to specify the compatibility object format of
+// a repository which is being converted between object formats, as with Git's
+// "extensions.compatObjectFormat". Object IDs in that format are accepted by
+// ParseOid, as well as those in the repository's own object format (see:
+// ObjectFormat).
+//
+// Objects may be read by their names in either format, and the names in the
+// compatibility format of objects written are recorded, as Git records them,
+// in the "loose-object-idx" file of the objects directory (see: CompatOid and
+// StorageOid). Objects written may only refer to objects whose names in the
+// compatibility format are known in this way.
+func CompatObjectFormat(algo ObjectFormatAlgorithm) Option {
+	return func(args *options) {
+		args.compatObjectFormat = algo
+	}
+}
+
+// BatchedWrites is an Option to stage loose objects written to a filesystem
+// backend in temporary files, deferring moving them into place (and syncing
+// their contents and directories to disk) until the next call to Flush() or
+// Close().
+//
+// Objects which have been written but not yet flushed may still be read from
+// the same *ObjectDatabase.
+func BatchedWrites() Option {
+	return func(args *options) {
+		args.batchedWrites = true
+	}
+}
+
+// SyncedWrites is an Option to sync loose objects written to a filesystem
+// backend to stable storage, along with the directories holding them, on the
+// next call to Flush() or Close(), as with Git's "core.fsync" setting. Objects
+// are still moved into place as they are written, but are not guaranteed to
+// survive a crash until they have been flushed.
+//
+// Objects written with BatchedWrites or PackedWrites are always synced as they
+// are flushed.
+func SyncedWrites() Option {
+	return func(args *options) {
+		args.syncedWrites = true
+	}
+}
+
+// FsyncObjectFiles is an Option to write each loose object to a filesystem
+// backend durably, as with Git's "core.fsyncObjectFiles" setting (or
+// "core.fsyncMethod=fsync"), so that a crash mid-write cannot leave a truncated
+// object behind. Each object is written to a temporary file in the directory
+// which will hold it, synced to stable storage, made read-only, and atomically
+// renamed into place, after which the directory is synced, all before the
+// write returns.
+//
+// This is slower than SyncedWrites, which defers syncing objects (as with
+// "core.fsyncMethod=batch") until they are flushed. Objects written with
+// BatchedWrites or PackedWrites are unaffected, since they are always synced as
+// they are flushed.
+func FsyncObjectFiles() Option {
+	return func(args *options) {
+		args.fsyncObjectFiles = true
+	}
+}
+
+// SharedRepository is an Option to give the permissions of the loose objects,
+// packfiles, and fan-out directories created in a filesystem backend, so that
+// they may be shared among the members of a group (or among all users), as
+// with Git's "core.sharedRepository" setting (see: ParseSharedRepository). New
+// directories are also made set-group-ID, so that the files created within
+// them belong to the same group.
+func SharedRepository(perm SharedPerm) Option {
+	return func(args *options) {
+		args.sharedPerm = perm
+	}
+}
+
+// PackedWrites is an Option to write objects to a filesystem backend into
+// packfiles, rather than as loose objects. Objects are staged in temporary
+// files until the next call to Flush() or Close(), which writes all of them
+// into a single new packfile, along with its index.
+//
+// As with BatchedWrites, objects which have been written but not yet flushed
+// may still be read from the same *ObjectDatabase.
+func PackedWrites() Option {
+	return func(args *options) {
+		args.packedWrites = true
+	}
+}
+
+// Quarantine is an Option to write all objects stored in a filesystem backend
+// into the given objects directory, rather than the main one, so that they may
+// be validated (for instance, by a hook) before they are moved into the main
+// directory. Objects are read from the quarantine directory first, and then
+// from the main directory and its alternates.
+//
+// This is similar to the quarantine environment which Git itself provides to
+// hooks (see: git-receive-pack(1)), except that it is configured explicitly
+// (see: FromEnvironment and EnvironmentOverrides, which use the quarantine
+// directory given by Git).
+// Moving quarantined objects into the main directory is left to the caller.
+//
+// Since it is the directory written to, Root() returns the quarantine
+// directory.
+func Quarantine(dir string) Option {
+	return func(args *options) {
+		args.quarantine = dir
+	}
+}
+
+// StrictSignatures is an Option to validate the author and committer of each
+// commit, and the tagger of each tag, as they are written, returning an
+// *InvalidSignature rather than writing an object which "git fsck" would
+// reject.
+//
+// Each identity must end with a timestamp, given in seconds since the Unix
+// epoch, and a timezone offset of the form "+HHMM" or "-HHMM", where HH is less
+// than 24 and MM is less than 60. Timestamps padded with leading zeros are
+// normalized, as is the whitespace separating the timestamp and offset.
+func StrictSignatures() Option {
+	return func(args *options) {
+		args.strictSignatures = true
+	}
+}
+
+// StrictWrites is an Option to validate each tree, commit, and tag as it is
+// written, as Verify does, returning an *InvalidObject rather than writing an
+// object which Git would reject when receiving it (for instance, with
+// "receive.fsckObjects" set): a tree whose entries are not sorted or are
+// duplicated, or which have names such as ".." or ".git", or null object IDs;
+// or a commit or tag with missing or malformed headers. Problems which Git
+// tolerates (those of severity VerifyInfo) are not rejected.
+//
+// Trees must be sorted as by SubtreeOrder, unless the CanonicalTrees option is
+// also given.
+func StrictWrites() Option {
+	return func(args *options) {
+		args.strictWrites = true
+	}
+}
+
+// StrictObjectTypes is an Option to reject objects whose headers give a type
+// other than "blob", "tree", "commit", or "tag", as they are read, returning an
+// *UnknownObjectTypeError holding the type exactly as it was stored (see:
+// ParseObjectType). By default, such objects are read with the type
+// UnknownObjectType.
+func StrictObjectTypes() Option {
+	return func(args *options) {
+		args.strictObjectTypes = true
+	}
+}
+
+// MaxHeaderLength is an Option to read object headers of up to "n" bytes,
+// rather than loose.DefaultMaxHeaderLength, so that malformed objects cannot
+// cause an unbounded read. Objects whose headers are longer fail to open with a
+// *loose.HeaderTooLongErr. If "n" is negative, the length of headers is not
+// limited.
+func MaxHeaderLength(n int) Option {
+	return func(args *options) {
+		args.maxHeaderLength = n
+	}
+}
+
+// MaxDeltaDepth is an Option to apply at most "n" deltas in reconstructing an
+// object from the packfiles in each objects directory, rather than
+// pack.DefaultMaxDeltaDepth. Objects at the end of longer (or cyclic)
+// delta-base chains fail to open with a *pack.DeltaDepthExceededErr (see:
+// pack.Limits).
+func MaxDeltaDepth(n int) Option {
+	return func(args *options) {
+		args.maxDeltaDepth = n
+	}
+}
+
+// MaxObjectSize is an Option to refuse to read objects whose headers declare a
+// size greater than "n" bytes, as when reading objects from an untrusted
+// source. Such objects fail to open with an *ObjectTooLarge error, which, for
+// packed objects (and deltas), is returned before any memory is allocated to
+// unpack them (see: pack.Limits). Their types and sizes may still be read (see:
+// ObjectInfo).
+//
+// If not specified, or if "n" is not positive, the size of objects is not
+// limited.
+func MaxObjectSize(n int64) Option {
+	return func(args *options) {
+		args.maxObjectSize = n
+	}
+}
+
+// CanonicalTrees is an Option to sort the entries of each tree as it is written
+// (see: SubtreeOrder), as Git requires, so that callers need not do so
+// themselves. The given *Tree is not modified.
+func CanonicalTrees() Option {
+	return func(args *options) {
+		args.canonicalTrees = true
+	}
+}
+
+// AllowedTypes is an Option to restrict the types of objects which may be
+// decoded to those given. Opening an object of any other type (as by Object(),
+// Commit(), and so on) returns a *DisallowedObjectType once its header has
+// been read, without reading its contents.
+//
+// For instance, a scanner which only considers blobs may pass
+// AllowedTypes(BlobObjectType). Writing objects, and reading their types and
+// sizes (see: ObjectInfo), are not restricted.
+func AllowedTypes(types ...ObjectType) Option {
+	return func(args *options) {
+		args.allowedTypes = append(args.allowedTypes, types...)
+	}
+}
+
+// Compression is an Option to compress and decompress loose objects with the
+// given Compressor, rather than with the standard library's compress/zlib
+// package (see: storage.Zlib), for instance to use a faster implementation.
+//
+// Packfiles are always read (and written) with compress/zlib.
+func Compression(c storage.Compressor) Option {
+	return func(args *options) {
+		args.compressor = c
+	}
+}
+
+// CompressionLevel is an Option to set the level at which loose objects are
+// compressed as they are written, from zlib.NoCompression (which may be
+// useful for temporary object stores) to zlib.BestCompression. If not
+// specified, it defaults to zlib.DefaultCompression.
+func CompressionLevel(level int) Option {
+	return func(args *options) {
+		args.compressionLevel = level
+	}
+}
+
+// UnpooledReads is an Option to allocate new zlib readers and buffers for each
+// object read, rather than reusing those of objects which have been closed.
+// Pooling reduces the number of allocations made when reading many objects,
+// but means that objects must not be read once they have been closed.
+//
+// If a Compressor other than storage.Zlib is given (see: Compression), it is
+// responsible for any pooling of its own readers.
+func UnpooledReads() Option {
+	return func(args *options) {
+		args.unpooled = true
+	}
+}
+
+// DeltaBaseCacheLimit is an Option to set the number of bytes of delta bases
+// cached while reading objects from the packfiles in each objects directory (the
+// repository's own, and those of its alternates), so that objects whose
+// delta-base chains share a common base can be read without resolving that
+// base again (see: pack.DeltaBaseCache). A limit of zero disables the cache.
+//
+// If not specified, it defaults to pack.DefaultDeltaBaseCacheLimit.
+func DeltaBaseCacheLimit(limit int64) Option {
+	return func(args *options) {
+		args.deltaBaseCacheLimit = limit
+	}
+}
+
+// VerifiedPacks is an Option to verify the checksums of packfiles as they are
+// read, rather than trusting their contents: the trailing checksums of each
+// packfile and of its index are verified when it is first read from, and the
+// packed data of each object read is checked against the CRC32 recorded for it
+// in the index. A *pack.CorruptPackErr (or *pack.CorruptIndexErr) identifying
+// the packfile is returned when they do not match (see:
+// pack.Set.EnableVerification). Verifying a packfile reads it in full.
+func VerifiedPacks() Option {
+	return func(args *options) {
+		args.verifiedPacks = true
+	}
+}
+
+// MaxOpenPackFiles is an Option to hold at most "n" packfile and index files
+// open at once, across the repository and its alternates, so that repositories
+// with many packfiles may be read without exceeding the limit on open file
+// descriptors. Files are opened as they are read, and the least recently used
+// are closed once the limit is reached (see: pack.FileCache).
+//
+// If not specified, or if "n" is not positive, every packfile and index is held
+// open for as long as the database is.
+func MaxOpenPackFiles(n int) Option {
+	return func(args *options) {
+		args.maxOpenPackFiles = n
+	}
+}
+
+// PackFileHooks is an Option to call the given hooks with the path of each
+// packfile and index file, across the repository and its alternates, as it is
+// opened, closed, or evicted to remain within the limit given by
+// MaxOpenPackFiles, so that applications may coordinate their own caching of
+// the files with their lifetime (see: pack.FileHooks).
+//
+// Files are read through a pack.FileCache, as by MaxOpenPackFiles, but without
+// that option, they are never evicted.
+func PackFileHooks(hooks *pack.FileHooks) Option {
+	return func(args *options) {
+		args.packFileHooks = hooks
+	}
+}
+
+// MemoryLimit is an Option to limit the memory allocated to read objects from
+// the database to "limit" bytes at once, across all of the goroutines (and
+// views) reading from it, as when serving many tenants from one process. Memory
+// is reserved as objects are decoded, and as their delta-base chains are
+// resolved, and reads which would exceed the limit fail with a
+// *pack.BudgetExceededErr. See also: WithMemoryLimit.
+//
+// Memory held by the contents of an object is released once it has been
+// closed, and so objects must be closed promptly when a limit is set.
+func MemoryLimit(limit int64) Option {
+	return func(args *options) {
+		args.memoryLimit = limit
+	}
+}
+
+// Abbrev is an Option to abbreviate object IDs to at least "n" hexadecimal
+// characters, as when `core.abbrev` is set to a number, rather than choosing
+// the minimum by the size of the database (see: AbbrevLength).
+func Abbrev(n int) Option {
+	return func(args *options) {
+		args.abbrev = n
+	}
+}
+
+// ObjectCache is an Option to cache up to (approximately) "size" bytes of
+// recently decoded trees and commits in memory, so that reading them again (as
+// when walking the history reachable from many references, which shares most
+// of its trees) does not read and decode them from storage each time. The
+// least recently used objects are evicted once the cache is full.
+//
+// Callers receive their own copy of each cached object, which they may modify.
+// If not specified, or if "size" is not positive, nothing is cached.
+func ObjectCache(size int64) Option {
+	return func(args *options) {
+		args.objectCacheSize = size
+	}
+}
+
+// ReplaceObjects is an Option to read the objects named by the keys of
+// "replacements" as the objects named by their values instead, as Git does for
+// each reference "refs/replace/<name>", so that history is read as "git log"
+// shows it. Both are hex-encoded object IDs. Replacements may themselves be
+// replaced, to a depth of at most five, as in Git.
+//
+// Objects are replaced wherever they are read by name (as by Object(),
+// Commit(), ObjectInfo(), and so on), but not when they are copied verbatim
+// (see: ExtractObject), nor when every stored object is visited (see:
+// ForEachObject). Since replaced commits may have different parents, the
+// commit-graph is not consulted when any objects are replaced.
+//
+// The option may be given more than once, in which case the replacements are
+// merged.
+func ReplaceObjects(replacements map[string]string) Option {
+	return func(args *options) {
+		if args.replacements == nil {
+			args.replacements = make(map[string]string, len(replacements))
+		}
+		for k, v := range replacements {
+			args.replacements[k] = v
+		}
+	}
+}
+
+// CheckCommitGraph is an Option to check the metadata of each commit read from
+// a commit-graph (see: CommitInfo) against the commit itself, returning a
+// *CommitGraphMismatch if they differ, rather than trusting the commit-graph.
+// This guards against a stale or damaged commit-graph silently corrupting the
+// results of a traversal, at the cost of reading each commit (which the
+// commit-graph would otherwise avoid). See also: VerifyCommitGraph.
+func CheckCommitGraph() Option {
+	return func(args *options) {
+		args.checkCommitGraph = true
+	}
+}
+
+// HeaderCache is an Option to cache the types and sizes of objects looked up by
+// ObjectInfo in a small file in the objects directory of a filesystem backend
+// ("info/gitobj-header-cache"), so that batch lookups repeated across runs of
+// a program need not read (and inflate) the objects' headers again. The file
+// is written when the database is closed, and is discarded if the packfiles in
+// the database (or its alternates) have changed since, as when objects are
+// repacked or pruned.
+//
+// Since it is only an optimization, the cache is ignored if it cannot be read,
+// and any error in writing it is not reported.
+func HeaderCache() Option {
+	return func(args *options) {
+		args.headerCache = true
+	}
+}
+
+// RecoverTruncatedObjects is an Option to check that each loose object may be
+// inflated in full before it is read, and, if it cannot be (as when a crash
+// mid-write left it truncated), to read an intact copy of the object from the
+// database's packfiles or alternates instead. If there is none, a
+// *CorruptLooseObject is returned, giving the path of the damaged file so that
+// it may be removed.
+//
+// Since each loose object is inflated twice, this makes reading loose objects
+// slower. Packed objects are unaffected.
+func RecoverTruncatedObjects() Option {
+	return func(args *options) {
+		args.recoverTruncated = true
+	}
+}
+
+// RecordDecodeStats is an Option to attach a description of how each object is
+// read to the *Blob, *Tree, *Commit, or *Tag returned: where it was stored, how
+// deep its delta-base chain is, how large it is as stored, and how long it took
+// to read (see: DecodeStats). Since finding where each object is stored takes
+// time of its own, this is meant for performance investigations, rather than
+// for general use.
+func RecordDecodeStats() Option {
+	return func(args *options) {
+		args.decodeStats = true
+	}
+}
+
+// Clock is an Option to give the clock from which the builders of commits and
+// tags (see: NewCommitBuilder and NewTagBuilder) take the time of identities
+// given by name and email, rather than time.Now, so that objects created in
+// tests or reproducible builds are deterministic. The time zone of each time
+// returned is that of the identity. A nil clock restores the default.
+func Clock(now func() time.Time) Option {
+	return func(args *options) {
+		if now == nil {
+			now = time.Now
+		}
+		args.clock = now
+	}
+}
+
+// DefaultIdentity is an Option to give the name and email of the identity with
+// which the builders of commits and tags fill in an author, committer, or
+// tagger which has not been set, stamped with the time given by the database's
+// clock (see: Clock), as Git does with "user.name" and "user.email".
+func DefaultIdentity(name, email string) Option {
+	return func(args *options) {
+		args.identity = &Signature{Name: name, Email: email}
+	}
+}
+
+// newOptions returns the options given by "setters", applied over the
+// defaults.
+func newOptions(setters []Option) *options {
+	args := &options{
+		objectFormat:     ObjectFormatSHA1,
+		compressor:       storage.Zlib,
+		compressionLevel: zlib.DefaultCompression,
+		clock:            time.Now,
+
+		maxAlternatesDepth: DefaultMaxAlternatesDepth,
+
+		deltaBaseCacheLimit: pack.DefaultDeltaBaseCacheLimit,
+	}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	if args.unpooled && args.compressor == storage.Zlib {
+		args.compressor = storage.UnpooledZlib
+	}
+	return args
+}
+
+// applyEnvironment applies the overrides given by the environment, whose
+// variables are read by "getenv", to the options (see: EnvironmentOverrides),
+// and returns the objects directory to be used in place of "root".
+func (args *options) applyEnvironment(root string, getenv func(string) string) string {
+	if alternates := getenv("GIT_ALTERNATE_OBJECT_DIRECTORIES"); len(alternates) > 0 {
+		if len(args.alternates) > 0 {
+			args.alternates += alternatesSeparator + alternates
+		} else {
+			args.alternates = alternates
+		}
+	}
+
+	if dir := getenv("GIT_QUARANTINE_PATH"); len(dir) > 0 {
+		// GIT_OBJECT_DIRECTORY names the quarantine directory
+		// itself, so the root is kept.
+		if len(args.quarantine) == 0 {
+			args.quarantine = dir
+		}
+		return root
+	}
+
+	if dir := getenv("GIT_OBJECT_DIRECTORY"); len(dir) > 0 {
+		return dir
+	}
+	return root
+}
+
 // FromFilesystem constructs an *ObjectDatabase instance that is backed by a
 // directory on the filesystem. Specifically, this should point to:
 //
-//  /absolute/repo/path/.git/objects
+//	/absolute/repo/path/.git/objects
+//
+// On Windows, files beneath the objects directory, the temporary directory,
+// and those of any alternates are opened by their extended-length paths
+// (prefixed by `\\?\`), so that they may be nested more deeply than MAX_PATH
+// allows, or be on network (UNC) shares. The paths reported by the database,
+// as by Root, remain as they were given.
 func FromFilesystem(root, tmp string, setters ...Option) (*ObjectDatabase, error) {
-	args := &options{objectFormat: ObjectFormatSHA1}
+	args := newOptions(setters)
+	if args.environment {
+		root = args.applyEnvironment(root, os.Getenv)
+	}
 
-	for _, setter := range setters {
-		setter(args)
+	if hasher(args.objectFormat) == nil {
+		return nil, &UnknownObjectFormat{Format: args.objectFormat}
 	}
 
-	b, err := NewFilesystemBackend(root, tmp, args.alternates, hasher(args.objectFormat))
+	b, err := newFilesystemBackend(root, tmp, hasher(args.objectFormat), args)
 	if err != nil {
 		return nil, err
 	}
@@ -88,42 +839,279 @@ func FromFilesystem(root, tmp string, setters ...Option) (*ObjectDatabase, error
 	if err != nil {
 		return nil, err
 	}
-	odb.tmp = tmp
+	odb.tmp = longPath(tmp)
+	if odb.compat != nil {
+		odb.compat.path = filepath.Join(longPath(root), "loose-object-idx")
+	}
+	if args.headerCache {
+		odb.headerCache = newHeaderCache(filepath.Join(longPath(root), "info", "gitobj-header-cache"))
+	}
+	odb.backend = func() (storage.Backend, error) {
+		return newFilesystemBackend(root, tmp, hasher(args.objectFormat), args)
+	}
 	return odb, nil
 }
 
 func FromBackend(b storage.Backend, setters ...Option) (*ObjectDatabase, error) {
-	args := &options{objectFormat: ObjectFormatSHA1}
-
-	for _, setter := range setters {
-		setter(args)
+	args := newOptions(setters)
+	if hasher(args.objectFormat) == nil {
+		return nil, &UnknownObjectFormat{Format: args.objectFormat}
 	}
 
 	ro, rw := b.Storage()
 	odb := &ObjectDatabase{
 		ro:           ro,
 		rw:           rw,
+		graph:        backendCommitGraph(b),
+		local:        backendLocalStorages(b),
+		writes:       new(writeCounters),
+		reads:        new(readCounters),
+		cache:        newObjectCache(args.objectCacheSize),
+		budget:       newBudget(args.memoryLimit),
+		abbrev:       args.abbrev,
 		objectFormat: args.objectFormat,
+
+		strictSignatures:  args.strictSignatures,
+		strictWrites:      args.strictWrites,
+		strictObjectTypes: args.strictObjectTypes,
+		maxHeaderLength:   args.maxHeaderLength,
+		maxObjectSize:     args.maxObjectSize,
+		canonicalTrees:    args.canonicalTrees,
+		allowedTypes:      allowedTypesMask(args.allowedTypes),
+		compressor:        args.compressor,
+		compressionLevel:  args.compressionLevel,
+		unpooled:          args.unpooled,
+		replacements:      args.replacements,
+		checkCommitGraph:  args.checkCommitGraph,
+		recoverTruncated:  args.recoverTruncated,
+		decodeStats:       args.decodeStats,
+
+		compatObjectFormat: args.compatObjectFormat,
+
+		clock:    args.clock,
+		identity: args.identity,
+
+		backend: func() (storage.Backend, error) {
+			return b, nil
+		},
+	}
+
+	if args.compatObjectFormat != "" && args.compatObjectFormat != args.objectFormat &&
+		hasher(args.compatObjectFormat) != nil {
+		odb.compat = newCompatMap("", args.objectFormat, args.compatObjectFormat)
 	}
 	return odb, nil
 }
 
+// View returns a lightweight handle onto the receiving *ObjectDatabase, for use
+// by a single goroutine. Views share the storage backend (and therefore any
+// open packfiles and their indexes) of the database they were created from,
+// but keep their own read and write state, such as the scratch buffers used to
+// encode objects, so that no locking is needed between them.
+//
+// A view is not itself safe for concurrent use; each goroutine should call
+// View() to obtain its own. Closing a view does not close the database it was
+// created from, but closing that database closes all of its views.
+func (o *ObjectDatabase) View() *ObjectDatabase {
+	parent := o
+	if o.parent != nil {
+		parent = o.parent
+	}
+
+	return &ObjectDatabase{
+		generation: atomic.LoadUint32(&parent.generation),
+
+		ro:           parent.ro,
+		rw:           parent.rw,
+		graph:        parent.graph,
+		local:        parent.local,
+		writes:       parent.writes,
+		reads:        parent.reads,
+		cache:        parent.cache,
+		budget:       parent.budget,
+		abbrev:       parent.abbrev,
+		tmp:          parent.tmp,
+		objectFormat: parent.objectFormat,
+
+		strictSignatures:  parent.strictSignatures,
+		strictWrites:      parent.strictWrites,
+		strictObjectTypes: parent.strictObjectTypes,
+		maxHeaderLength:   parent.maxHeaderLength,
+		maxObjectSize:     parent.maxObjectSize,
+		canonicalTrees:    parent.canonicalTrees,
+		allowedTypes:      parent.allowedTypes,
+		compressor:        parent.compressor,
+		compressionLevel:  parent.compressionLevel,
+		unpooled:          parent.unpooled,
+		replacements:      parent.replacements,
+		checkCommitGraph:  parent.checkCommitGraph,
+		recoverTruncated:  parent.recoverTruncated,
+		decodeStats:       parent.decodeStats,
+
+		compatObjectFormat: parent.compatObjectFormat,
+		compat:             parent.compat,
+		headerCache:        parent.headerCache,
+
+		clock:    parent.clock,
+		identity: parent.identity,
+
+		parent:  parent,
+		scratch: new(bytes.Buffer),
+	}
+}
+
 // Close closes the *ObjectDatabase, freeing any open resources (namely: the
 // `*git.ObjectScanner instance), and returning any errors encountered in
 // closing them.
 //
+// If the *ObjectDatabase is a view (see: View), Close only prevents further use
+// of the view, and leaves the underlying storage open.
+//
 // If Close() has already been called, this function will return an error.
 func (o *ObjectDatabase) Close() error {
 	if !atomic.CompareAndSwapUint32(&o.closed, 0, 1) {
 		return fmt.Errorf("gitobj: *ObjectDatabase already closed")
 	}
+	o.DiscardInjected()
 
-	if err := o.ro.Close(); err != nil {
+	if o.parent != nil {
+		return nil
+	}
+	return o.closeStorage()
+}
+
+// Reopen reopens a closed *ObjectDatabase, so that it may be used again, for
+// instance after being returned to a pool. It returns an error if the
+// *ObjectDatabase is still open.
+//
+// Databases constructed by FromFilesystem reopen their storage afresh,
+// rescanning for packfiles. Those constructed by FromBackend reuse the storage
+// provided by their backend, which must therefore remain usable after being
+// closed.
+//
+// Views (see: View) created before a database was closed remain closed when it
+// is reopened. A view may itself be reopened once the database from which it
+// was created is open.
+func (o *ObjectDatabase) Reopen() error {
+	if o.snapshot {
+		return fmt.Errorf("gitobj: snapshot cannot be reopened")
+	}
+	if o.parent != nil {
+		if o.parent.isClosed() {
+			return ErrDatabaseClosed
+		}
+
+		generation := atomic.LoadUint32(&o.parent.generation)
+		if atomic.LoadUint32(&o.closed) == 0 &&
+			atomic.LoadUint32(&o.generation) == generation {
+			return fmt.Errorf("gitobj: *ObjectDatabase is not closed")
+		}
+
+		o.ro, o.rw, o.graph = o.parent.ro, o.parent.rw, o.parent.graph
+		o.local = o.parent.local
+		atomic.StoreUint32(&o.generation, generation)
+		atomic.StoreUint32(&o.closed, 0)
+		return nil
+	}
+
+	if atomic.LoadUint32(&o.closed) == 0 {
+		return fmt.Errorf("gitobj: *ObjectDatabase is not closed")
+	}
+	if o.backend == nil {
+		return fmt.Errorf("gitobj: *ObjectDatabase cannot be reopened")
+	}
+
+	b, err := o.backend()
+	if err != nil {
 		return err
 	}
+
+	o.ro, o.rw = b.Storage()
+	o.graph = backendCommitGraph(b)
+	o.local = backendLocalStorages(b)
+	atomic.AddUint32(&o.generation, 1)
+	atomic.StoreUint32(&o.closed, 0)
+	return nil
+}
+
+// Reload rescans the pack directory of the repository, and of each of its
+// alternates, for packfiles written since they were last scanned, as by a
+// concurrent `git fetch` or `git gc`, so that the objects in them may be read.
+//
+// Packfiles are also rescanned when an object is not found, though at most once
+// per pack.DefaultRescanInterval, so calling Reload is only needed to read
+// objects written within that interval.
+func (o *ObjectDatabase) Reload() error {
+	if o.isClosed() {
+		return ErrDatabaseClosed
+	}
+
+	for _, s := range storages(o.ro) {
+		if packs, ok := s.(*pack.Storage); ok {
+			if err := packs.Reload(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// closeStorage closes the storage from which objects are read and written.
+//
+// The writable storage is closed first, since doing so may flush staged
+// objects into a packfile which the readable storage must then close.
+func (o *ObjectDatabase) closeStorage() error {
+	if o.headerCache != nil {
+		o.headerCache.save()
+	}
 	if err := o.rw.Close(); err != nil {
 		return err
 	}
+	if err := o.ro.Close(); err != nil {
+		return err
+	}
+	if o.graph != nil {
+		return o.graph.Close()
+	}
+	return nil
+}
+
+// backendCommitGraph returns the commit-graph provided by the given storage
+// backend, or nil if it does not provide one.
+func backendCommitGraph(b storage.Backend) *commitGraph {
+	type commitGrapher interface {
+		commitGraph() *commitGraph
+	}
+
+	if g, ok := b.(commitGrapher); ok {
+		return g.commitGraph()
+	}
+	return nil
+}
+
+// Flush completes any writes which were staged by the storage backend (see:
+// BatchedWrites and PackedWrites), making every object written so far visible
+// at its final location. It returns any error encountered in doing so.
+//
+// Flush acts as a barrier for crash-consistent writes: once it has returned
+// without error, every object written before it was called is in place, and,
+// if it was staged or writes are synced (see: SyncedWrites), durably so, along
+// with its directory. References to those objects should therefore only be
+// updated once Flush has returned.
+//
+// If the storage backend neither stages nor syncs writes, Flush does nothing.
+func (o *ObjectDatabase) Flush() error {
+	if o.isClosed() {
+		return ErrDatabaseClosed
+	}
+
+	type flusher interface {
+		Flush() error
+	}
+
+	if f, ok := o.rw.(flusher); ok {
+		return f.Flush()
+	}
 	return nil
 }
 
@@ -133,38 +1121,150 @@ func (o *ObjectDatabase) Close() error {
 // If the object could not be opened, is of unknown type, or could not be
 // decoded, than an appropriate error is returned instead.
 func (o *ObjectDatabase) Object(sha []byte) (Object, error) {
-	r, err := o.open(sha)
+	return o.ObjectContext(context.Background(), sha)
+}
+
+// ObjectContext is as Object, but abandons reading the object, and returns the
+// context's error, once the given context is done.
+//
+// If the object is a *Blob, reading its contents fails in the same way.
+func (o *ObjectDatabase) ObjectContext(ctx context.Context, sha []byte) (Object, error) {
+	start := time.Now()
+
+	r, err := o.open(ctx, sha)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := o.checkAllowed(sha, r); err != nil {
+		return nil, err
+	}
+
 	typ, _, err := r.Header()
 	if err != nil {
 		return nil, err
 	}
 
-	var into Object
-	switch typ {
-	case BlobObjectType:
-		into = new(Blob)
-	case TreeObjectType:
-		into = new(Tree)
-	case CommitObjectType:
-		into = new(Commit)
-	case TagObjectType:
-		into = new(Tag)
-	default:
-		return nil, fmt.Errorf("gitobj: unknown object type: %s", typ)
+	into, err := newObject(typ)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.decode(r, into); err != nil {
+		return into, err
+	}
+	o.recordDecodeStats(sha, into, start)
+	return into, nil
+}
+
+// ObjectInfo returns the type and (uncompressed) size of the object named
+// "sha", as with "git cat-file -t" and "git cat-file -s", without decoding the
+// object.
+//
+// Only the header of a loose object is read. For a packed object, the type and
+// size are read from the headers of its entry and of any delta bases, without
+// resolving its delta-base chain. If the database has a header cache (see:
+// HeaderCache), neither is read for objects already in the cache.
+func (o *ObjectDatabase) ObjectInfo(sha []byte) (ObjectType, int64, error) {
+	if o.isClosed() {
+		return UnknownObjectType, 0, ErrDatabaseClosed
+	}
+
+	sha, err := o.replace(sha)
+	if err != nil {
+		return UnknownObjectType, 0, err
+	}
+
+	if o.headerCache == nil {
+		return o.objectInfo(sha)
+	}
+
+	o.headerCache.load(o.packFingerprint)
+	if typ, size, ok := o.headerCache.get(sha); ok {
+		return typ, size, nil
+	}
+
+	typ, size, err := o.objectInfo(sha)
+	if err != nil {
+		return UnknownObjectType, 0, err
+	}
+	o.headerCache.add(sha, typ, size)
+	return typ, size, nil
+}
+
+// Has returns whether the object named "sha" is stored in the database, or in
+// any of its alternates, without opening it where its storage allows (see:
+// storage.HasStorage): loose objects are found without reading them, and packed
+// objects from their packfiles' indexes alone. Replacements (see:
+// ReplaceObjects) are not followed, and injected objects (see: Inject) are not
+// included.
+//
+// An object named in the compatibility object format (see: CompatObjectFormat)
+// for which no name in the database's own format is known is not stored, and
+// so false is returned. Otherwise, an error is returned only if the storages
+// could not be searched, so that a missing object is distinguished from one
+// which cannot be read.
+func (o *ObjectDatabase) Has(sha []byte) (bool, error) {
+	if o.isClosed() {
+		return false, ErrDatabaseClosed
+	}
+
+	if o.compat != nil && len(sha) == hasher(o.compatObjectFormat).Size() &&
+		len(sha) != o.Hasher().Size() {
+		mapped, ok, err := o.compat.lookup(sha, false)
+		if err != nil || !ok {
+			return false, err
+		}
+		sha = mapped
+	}
+	return storage.Has(o.ro, sha)
+}
+
+// objectInfo is as ObjectInfo, but looks up the object named "sha" itself,
+// even if it has been replaced, and without consulting the header cache.
+func (o *ObjectDatabase) objectInfo(sha []byte) (ObjectType, int64, error) {
+
+	// Since an object has the same contents wherever it is stored, packed
+	// objects may be looked up before loose ones, regardless of the order
+	// in which storages are otherwise searched.
+	for _, s := range storages(o.ro) {
+		packs, ok := s.(*pack.Storage)
+		if !ok {
+			continue
+		}
+
+		typ, size, err := packs.ObjectInfo(sha)
+		if err != nil {
+			if errors.IsNoSuchObject(err) {
+				continue
+			}
+			return UnknownObjectType, 0, err
+		}
+		return objectType(typ), size, nil
+	}
+
+	r, err := o.openExact(context.Background(), sha)
+	if err != nil {
+		return UnknownObjectType, 0, err
 	}
-	return into, o.decode(r, into)
+	defer r.Close()
+
+	return r.Header()
 }
 
 // Blob returns a *Blob as identified by the SHA given, or an error if one was
 // encountered.
 func (o *ObjectDatabase) Blob(sha []byte) (*Blob, error) {
+	return o.BlobContext(context.Background(), sha)
+}
+
+// BlobContext is as Blob, but abandons reading the blob, and returns the
+// context's error, once the given context is done.
+//
+// Reading the contents of the returned *Blob fails in the same way.
+func (o *ObjectDatabase) BlobContext(ctx context.Context, sha []byte) (*Blob, error) {
 	var b Blob
 
-	if err := o.openDecode(sha, &b); err != nil {
+	if err := o.openDecode(ctx, sha, &b); err != nil {
 		return nil, err
 	}
 	return &b, nil
@@ -173,45 +1273,135 @@ func (o *ObjectDatabase) Blob(sha []byte) (*Blob, error) {
 // Tree returns a *Tree as identified by the SHA given, or an error if one was
 // encountered.
 func (o *ObjectDatabase) Tree(sha []byte) (*Tree, error) {
+	return o.TreeContext(context.Background(), sha)
+}
+
+// TreeContext is as Tree, but abandons reading the tree, and returns the
+// context's error, once the given context is done.
+func (o *ObjectDatabase) TreeContext(ctx context.Context, sha []byte) (*Tree, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+	if t, ok := o.cache.Tree(sha); ok {
+		return t, nil
+	}
+
 	var t Tree
-	if err := o.openDecode(sha, &t); err != nil {
+
+	if err := o.openDecode(ctx, sha, &t); err != nil {
 		return nil, err
 	}
+	o.cache.AddTree(sha, &t)
 	return &t, nil
 }
 
 // Commit returns a *Commit as identified by the SHA given, or an error if one
 // was encountered.
 func (o *ObjectDatabase) Commit(sha []byte) (*Commit, error) {
+	return o.CommitContext(context.Background(), sha)
+}
+
+// CommitContext is as Commit, but abandons reading the commit, and returns the
+// context's error, once the given context is done.
+func (o *ObjectDatabase) CommitContext(ctx context.Context, sha []byte) (*Commit, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+	if c, ok := o.cache.Commit(sha); ok {
+		return c, nil
+	}
+
 	var c Commit
 
-	if err := o.openDecode(sha, &c); err != nil {
+	if err := o.openDecode(ctx, sha, &c); err != nil {
 		return nil, err
 	}
+	o.cache.AddCommit(sha, &c)
 	return &c, nil
 }
 
 // Tag returns a *Tag as identified by the SHA given, or an error if one was
 // encountered.
 func (o *ObjectDatabase) Tag(sha []byte) (*Tag, error) {
+	return o.TagContext(context.Background(), sha)
+}
+
+// TagContext is as Tag, but abandons reading the tag, and returns the
+// context's error, once the given context is done.
+func (o *ObjectDatabase) TagContext(ctx context.Context, sha []byte) (*Tag, error) {
 	var t Tag
 
-	if err := o.openDecode(sha, &t); err != nil {
+	if err := o.openDecode(ctx, sha, &t); err != nil {
 		return nil, err
 	}
 	return &t, nil
 }
 
+// ReadRange returns a reader over at most "n" bytes of the contents of the
+// object named "sha", beginning at offset "off" into its contents (i.e., not
+// including the object header). It is the caller's responsibility to close
+// the returned reader.
+//
+// Storage backends that support ranged reads (see: storage.RangeStorage) serve
+// the range without reading the object in its entirety.
+func (o *ObjectDatabase) ReadRange(sha []byte, off, n int64) (io.ReadCloser, error) {
+	return o.ReadRangeContext(context.Background(), sha, off, n)
+}
+
+// ReadRangeContext is as ReadRange, but reading from the returned reader fails
+// with the context's error once the given context is done.
+func (o *ObjectDatabase) ReadRangeContext(ctx context.Context, sha []byte, off, n int64) (io.ReadCloser, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sha, err := o.replace(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := storage.ReadRange(o.ro, sha, off, n)
+	if err != nil {
+		return nil, err
+	}
+	return &contextReadCloser{ctx: ctx, r: r}, nil
+}
+
 // WriteBlob stores a *Blob on disk and returns the SHA it is uniquely
 // identified by, or an error if one was encountered.
 func (o *ObjectDatabase) WriteBlob(b *Blob) ([]byte, error) {
+	return o.WriteBlobContext(context.Background(), b)
+}
+
+// WriteBlobContext is as WriteBlob, but abandons writing the blob, and returns
+// the context's error, once the given context is done.
+func (o *ObjectDatabase) WriteBlobContext(ctx context.Context, b *Blob) ([]byte, error) {
+	return o.WriteBlobTeeContext(ctx, b)
+}
+
+// WriteBlobTee is as WriteBlob, but also computes each of the given hashes over
+// the blob's contents (excluding its header) in the same pass as its name (see:
+// ObjectWriter.Tee), for instance to compute the SHA-256 of a file's contents
+// for a Git LFS pointer while migrating it, without reading it twice. Each hash
+// is reset before use, and its sum may be read once WriteBlobTee returns
+// without error.
+func (o *ObjectDatabase) WriteBlobTee(b *Blob, sums ...hash.Hash) ([]byte, error) {
+	return o.WriteBlobTeeContext(context.Background(), b, sums...)
+}
+
+// WriteBlobTeeContext is as WriteBlobTee, but abandons writing the blob, and
+// returns the context's error, once the given context is done.
+func (o *ObjectDatabase) WriteBlobTeeContext(ctx context.Context, b *Blob, sums ...hash.Hash) ([]byte, error) {
 	buf, err := ioutil.TempFile(o.tmp, "")
 	if err != nil {
 		return nil, err
 	}
 	defer o.cleanup(buf)
 
-	sha, _, err := o.encodeBuffer(b, buf)
+	sha, _, err := o.encodeBuffer(ctx, b, buf, sums...)
 	if err != nil {
 		return nil, err
 	}
@@ -226,7 +1416,13 @@ func (o *ObjectDatabase) WriteBlob(b *Blob) ([]byte, error) {
 // WriteTree stores a *Tree on disk and returns the SHA it is uniquely
 // identified by, or an error if one was encountered.
 func (o *ObjectDatabase) WriteTree(t *Tree) ([]byte, error) {
-	sha, _, err := o.encode(t)
+	return o.WriteTreeContext(context.Background(), t)
+}
+
+// WriteTreeContext is as WriteTree, but abandons writing the tree, and returns
+// the context's error, once the given context is done.
+func (o *ObjectDatabase) WriteTreeContext(ctx context.Context, t *Tree) ([]byte, error) {
+	sha, _, err := o.encode(ctx, t)
 	if err != nil {
 		return nil, err
 	}
@@ -236,7 +1432,13 @@ func (o *ObjectDatabase) WriteTree(t *Tree) ([]byte, error) {
 // WriteCommit stores a *Commit on disk and returns the SHA it is uniquely
 // identified by, or an error if one was encountered.
 func (o *ObjectDatabase) WriteCommit(c *Commit) ([]byte, error) {
-	sha, _, err := o.encode(c)
+	return o.WriteCommitContext(context.Background(), c)
+}
+
+// WriteCommitContext is as WriteCommit, but abandons writing the commit, and
+// returns the context's error, once the given context is done.
+func (o *ObjectDatabase) WriteCommitContext(ctx context.Context, c *Commit) ([]byte, error) {
+	sha, _, err := o.encode(ctx, c)
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +1448,13 @@ func (o *ObjectDatabase) WriteCommit(c *Commit) ([]byte, error) {
 // WriteTag stores a *Tag on disk and returns the SHA it is uniquely identified
 // by, or an error if one was encountered.
 func (o *ObjectDatabase) WriteTag(t *Tag) ([]byte, error) {
-	sha, _, err := o.encode(t)
+	return o.WriteTagContext(context.Background(), t)
+}
+
+// WriteTagContext is as WriteTag, but abandons writing the tag, and returns
+// the context's error, once the given context is done.
+func (o *ObjectDatabase) WriteTagContext(ctx context.Context, t *Tag) ([]byte, error) {
+	sha, _, err := o.encode(ctx, t)
 	if err != nil {
 		return nil, err
 	}
@@ -274,16 +1482,105 @@ func (o *ObjectDatabase) Hasher() hash.Hash {
 	return hasher(o.objectFormat)
 }
 
+// isClosed returns whether the *ObjectDatabase, or the database from which it
+// was created (if it is a view), has been closed.
+func (o *ObjectDatabase) isClosed() bool {
+	if atomic.LoadUint32(&o.closed) == 1 {
+		return true
+	}
+	if o.parent == nil {
+		return false
+	}
+	return o.parent.isClosed() ||
+		atomic.LoadUint32(&o.generation) != atomic.LoadUint32(&o.parent.generation)
+}
+
 // encode encodes and saves an object to the storage backend and uses an
 // in-memory buffer to calculate the object's encoded body.
-func (d *ObjectDatabase) encode(object Object) (sha []byte, n int64, err error) {
-	return d.encodeBuffer(object, bytes.NewBuffer(nil))
+func (d *ObjectDatabase) encode(ctx context.Context, object Object) (sha []byte, n int64, err error) {
+	if object, err = d.prepare(object); err != nil {
+		return nil, 0, err
+	}
+
+	if d.scratch != nil {
+		d.scratch.Reset()
+		return d.encodeBuffer(ctx, object, d.scratch)
+	}
+	return d.encodeBuffer(ctx, object, bytes.NewBuffer(nil))
+}
+
+// prepare returns the given object as it is to be written, with its entries
+// sorted (see: CanonicalTrees) and its identities normalized (see:
+// StrictSignatures), or an error if it is invalid (see: StrictWrites). The
+// given object is not modified.
+func (d *ObjectDatabase) prepare(object Object) (Object, error) {
+	var err error
+
+	if t, ok := object.(*Tree); ok && d.canonicalTrees {
+		sorted := &Tree{Entries: append([]*TreeEntry(nil), t.Entries...)}
+		sort.Sort(SubtreeOrder(sorted.Entries))
+		object = sorted
+	}
+
+	if d.strictSignatures {
+		if object, err = normalizeSignatures(object); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.strictWrites {
+		if err = d.validate(object); err != nil {
+			return nil, err
+		}
+	}
+	return object, nil
+}
+
+// validate returns an *InvalidObject if the given tree, commit, or tag has any
+// problems which Git would reject (see: StrictWrites).
+func (d *ObjectDatabase) validate(object Object) error {
+	var buf bytes.Buffer
+	if _, err := object.Encode(&buf); err != nil {
+		return err
+	}
+
+	v := &verifier{typ: object.Type(), hashlen: d.Hasher().Size()}
+	switch object.Type() {
+	case TreeObjectType:
+		v.tree(buf.Bytes())
+	case CommitObjectType:
+		v.commit(buf.Bytes())
+	case TagObjectType:
+		v.tag(buf.Bytes())
+	}
+
+	var rejected []*VerifyFinding
+	for _, f := range v.findings {
+		if f.Severity != VerifyInfo {
+			rejected = append(rejected, f)
+		}
+	}
+	if len(rejected) > 0 {
+		return &InvalidObject{Type: object.Type(), Findings: rejected}
+	}
+	return nil
 }
 
 // encodeBuffer encodes and saves an object to the storage backend by using the
-// given buffer to calculate and store the object's encoded body.
-func (d *ObjectDatabase) encodeBuffer(object Object, buf io.ReadWriter) (sha []byte, n int64, err error) {
-	cn, err := object.Encode(buf)
+// given buffer to calculate and store the object's encoded body. It stops, and
+// returns the context's error, once the given context is done.
+//
+// If the database has a compatibility object format (see: CompatObjectFormat),
+// the object's name in that format is recorded along with it.
+//
+// Each of the given hashes is computed over the object's encoded contents, as
+// by ObjectWriter.Tee.
+func (d *ObjectDatabase) encodeBuffer(ctx context.Context, object Object, buf io.ReadWriter, sums ...hash.Hash) (sha []byte, n int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	cn, err := object.Encode(&contextWriter{ctx: ctx, w: buf})
 	if err != nil {
 		return nil, 0, err
 	}
@@ -294,7 +1591,13 @@ func (d *ObjectDatabase) encodeBuffer(object Object, buf io.ReadWriter) (sha []b
 	}
 	defer d.cleanup(tmp)
 
-	to := NewObjectWriter(tmp, d.Hasher())
+	zw, err := d.compressor.NewWriter(tmp, d.compressionLevel)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	to := newObjectWriteCloser(&nopCloser{tmp}, zw, d.Hasher())
+	to.Tee(sums...)
 	if _, err = to.WriteHeader(object.Type(), int64(cn)); err != nil {
 		return nil, 0, err
 	}
@@ -305,6 +1608,13 @@ func (d *ObjectDatabase) encodeBuffer(object Object, buf io.ReadWriter) (sha []b
 		}
 	}
 
+	var compat []byte
+	if d.compat != nil {
+		if compat, err = d.encodedCompatSha(object.Type(), int64(cn), buf); err != nil {
+			return nil, 0, err
+		}
+	}
+
 	if _, err = io.Copy(to, buf); err != nil {
 		return nil, 0, err
 	}
@@ -316,42 +1626,183 @@ func (d *ObjectDatabase) encodeBuffer(object Object, buf io.ReadWriter) (sha []b
 	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
 		return nil, 0, err
 	}
-	return d.save(to.Sha(), tmp)
+
+	sha, n, err = d.save(ctx, to.Sha(), tmp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if compat != nil {
+		if err = d.compat.add(sha, compat); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	d.writes.add(object.Type(), int64(cn), n)
+	return sha, n, nil
 }
 
 // save writes the given buffer to the location given by the storer "o.s" as
 // identified by the sha []byte.
-func (o *ObjectDatabase) save(sha []byte, buf io.Reader) ([]byte, int64, error) {
-	n, err := o.rw.Store(sha, buf)
+func (o *ObjectDatabase) save(ctx context.Context, sha []byte, buf io.Reader) ([]byte, int64, error) {
+	n, err := o.rw.Store(sha, &contextReader{ctx: ctx, r: buf})
 
 	return sha, n, err
 }
 
 // open gives an `*ObjectReader` for the given loose object keyed by the given
 // "sha" []byte, or an error.
-func (o *ObjectDatabase) open(sha []byte) (*ObjectReader, error) {
-	if atomic.LoadUint32(&o.closed) == 1 {
-		return nil, fmt.Errorf("gitobj: cannot use closed *pack.Set")
+//
+// If the object has been replaced (see: ReplaceObjects), its replacement is
+// opened instead.
+//
+// Reading from the returned *ObjectReader fails with the context's error once
+// the given context is done.
+func (o *ObjectDatabase) open(ctx context.Context, sha []byte) (*ObjectReader, error) {
+	sha, err := o.replace(sha)
+	if err != nil {
+		return nil, err
+	}
+	return o.openExact(ctx, sha)
+}
+
+// openExact is as open, but opens the object named "sha" itself, even if it has
+// been replaced.
+//
+// If the object is missing from a partial clone (see: hasPromisorPacks), the
+// error satisfies errors.IsMissingPromisorObject, as well as
+// errors.IsNoSuchObject.
+func (o *ObjectDatabase) openExact(ctx context.Context, sha []byte) (*ObjectReader, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
 	}
 
-	f, err := o.ro.Open(sha)
+	if r, ok := o.openInjected(sha); ok {
+		return r, nil
+	}
+
+	sctx := pack.WithStats(o.withBudget(ctx), &o.reads.packs)
+
+	var f io.ReadCloser
+	var err error
+	if o.recoverTruncated {
+		f, err = o.openIntact(sctx, sha)
+	} else {
+		f, err = storage.Open(sctx, o.ro, sha)
+	}
 	if err != nil {
+		if errors.IsNoSuchObject(err) && o.hasPromisorPacks() {
+			return nil, errors.MissingPromisorObject(sha)
+		}
+		if large, ok := err.(*pack.ObjectTooLargeErr); ok {
+			return nil, &ObjectTooLarge{Oid: sha, Size: large.Size, Limit: large.Limit}
+		}
 		return nil, err
 	}
+
+	f = &contextReadCloser{ctx: ctx, r: f}
+
+	var r *ObjectReader
 	if o.ro.IsCompressed() {
-		return NewObjectReadCloser(f)
+		if r, err = newObjectReadCloser(f, o.compressor, !o.unpooled); err != nil {
+			return nil, err
+		}
+	} else {
+		r = newUncompressedObjectReadCloser(f, !o.unpooled)
+	}
+	r.strict = o.strictObjectTypes
+	r.headerLimit = o.maxHeaderLength
+	r.sizeLimit = o.maxObjectSize
+	r.oid = sha
+	r.inflated = &o.reads.inflated
+	return r, nil
+}
+
+// openIntact opens the object named "sha" from the first storage holding an
+// intact copy of it, skipping loose objects which cannot be inflated in full
+// (see: RecoverTruncatedObjects). If every copy is corrupt, a
+// *CorruptLooseObject describing the first is returned.
+func (o *ObjectDatabase) openIntact(ctx context.Context, sha []byte) (io.ReadCloser, error) {
+	var corrupt *CorruptLooseObject
+	for _, s := range storages(o.ro) {
+		f, err := storage.Open(ctx, s, sha)
+		if err != nil {
+			if errors.IsNoSuchObject(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		fs, ok := s.(*fileStorer)
+		if !ok {
+			return f, nil
+		}
+
+		if err = o.inflate(f); err == nil {
+			return storage.Open(ctx, s, sha)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if corrupt == nil {
+			corrupt = &CorruptLooseObject{Oid: sha, Path: fs.objectPath(sha), Err: err}
+		}
+	}
+
+	if corrupt != nil {
+		return nil, corrupt
+	}
+	return nil, errors.NoSuchObject(sha)
+}
+
+// inflate inflates the compressed loose object "f" in full, discarding its
+// contents, and closes it, returning any error encountered in doing so.
+func (o *ObjectDatabase) inflate(f io.ReadCloser) error {
+	defer f.Close()
+
+	zr, err := o.compressor.NewReader(f)
+	if err != nil {
+		return err
 	}
-	return NewUncompressedObjectReadCloser(f)
+	_, err = io.Copy(ioutil.Discard, zr)
+	zr.Close()
+	return err
+}
+
+// hasPromisorPacks returns whether any of the storages from which objects are
+// read holds a promisor pack, in which case the database belongs to a partial
+// clone, and objects missing from it may be available from a promisor remote.
+func (o *ObjectDatabase) hasPromisorPacks() bool {
+	for _, s := range storages(o.ro) {
+		if packs, ok := s.(*pack.Storage); ok && packs.HasPromisorPacks() {
+			return true
+		}
+	}
+	return false
 }
 
 // openDecode calls decode (see: below) on the object named "sha" after openin
 // it.
-func (o *ObjectDatabase) openDecode(sha []byte, into Object) error {
-	r, err := o.open(sha)
+func (o *ObjectDatabase) openDecode(ctx context.Context, sha []byte, into Object) error {
+	start := time.Now()
+
+	r, err := o.open(ctx, sha)
+	if err != nil {
+		return err
+	}
+	if err := o.checkAllowed(sha, r); err != nil {
+		return err
+	}
+
+	err = o.decodeWithin(pack.BudgetFromContext(o.withBudget(ctx)), r, into)
+	if e, ok := err.(*UnexpectedObjectType); ok {
+		e.Oid = sha
+	}
 	if err != nil {
 		return err
 	}
-	return o.decode(r, into)
+	o.recordDecodeStats(sha, into, start)
+	return nil
 }
 
 // decode decodes an object given by the sha "sha []byte" into the given object
@@ -380,18 +1831,37 @@ func (o *ObjectDatabase) decode(r *ObjectReader, into Object) error {
 	return r.Close()
 }
 
-func (o *ObjectDatabase) cleanup(f *os.File) {
-	f.Close()
-	os.Remove(f.Name())
+// checkAllowed returns a *DisallowedObjectType, after closing "r", if the
+// object named "sha" which "r" reads is of a type which may not be decoded
+// (see: AllowedTypes). Otherwise, it returns nil, or any error encountered in
+// reading the object's header.
+func (o *ObjectDatabase) checkAllowed(sha []byte, r *ObjectReader) error {
+	if o.allowedTypes == 0 {
+		return nil
+	}
+
+	typ, _, err := r.Header()
+	if err != nil {
+		return err
+	}
+	if o.allowedTypes&(1<<typ) == 0 {
+		r.Close()
+		return &DisallowedObjectType{Oid: sha, Type: typ}
+	}
+	return nil
 }
 
-func hasher(algo ObjectFormatAlgorithm) hash.Hash {
-	switch algo {
-	case ObjectFormatSHA1:
-		return sha1.New()
-	case ObjectFormatSHA256:
-		return sha256.New()
-	default:
-		return nil
+// allowedTypesMask returns the bitmask of the given object types stored in
+// ObjectDatabase.allowedTypes.
+func allowedTypesMask(types []ObjectType) uint8 {
+	var mask uint8
+	for _, typ := range types {
+		mask |= 1 << typ
 	}
+	return mask
+}
+
+func (o *ObjectDatabase) cleanup(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
 }
@@ -3,15 +3,26 @@ package gitobj
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/loose"
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -282,6 +293,33 @@ func TestWriteBlob(t *testing.T) {
 	}
 }
 
+func TestWriteBlobTeeComputesAdditionalHashes(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	sum := sha256.New()
+	sha, err := odb.WriteBlobTee(&Blob{
+		Size:     14,
+		Contents: strings.NewReader("Hello, world!\n"),
+	}, sum)
+	require.NoError(t, err)
+
+	assert.Equal(t, "af5626b4a114abcb82d63db7c8082c3c4756e51b", hex.EncodeToString(sha))
+	// The hash of the contents alone, as in a Git LFS pointer.
+	assert.Equal(t, "d9014c4624844aa5bac314773d6b689ad467fa4e1d1a50a1b8a99d5a95f72ff5", hex.EncodeToString(sum.Sum(nil)))
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+}
+
 func TestWriteTree(t *testing.T) {
 	testCases := []struct {
 		options []Option
@@ -473,6 +511,219 @@ func TestWriteTag(t *testing.T) {
 	}
 }
 
+func TestReadRange(t *testing.T) {
+	const sha = "af5626b4a114abcb82d63db7c8082c3c4756e51b"
+
+	var buf bytes.Buffer
+
+	zw := zlib.NewWriter(&buf)
+	fmt.Fprintf(zw, "blob 14\x00Hello, world!\n")
+	zw.Close()
+
+	b, err := NewMemoryBackend(map[string]io.ReadWriter{
+		sha: &buf,
+	})
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	shaHex, _ := hex.DecodeString(sha)
+	r, err := odb.ReadRange(shaHex, 7, 5)
+	require.NoError(t, err)
+
+	got, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(got))
+	assert.NoError(t, r.Close())
+}
+
+func TestReadRangeOfAMissingObject(t *testing.T) {
+	sha, _ := hex.DecodeString("af5626b4a114abcb82d63db7c8082c3c4756e51b")
+
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(b)
+	require.NoError(t, err)
+
+	r, err := odb.ReadRange(sha, 0, 1)
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Nil(t, r)
+}
+
+func TestBatchedWritesAreStagedUntilFlush(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	tmp, err := ioutil.TempDir("", "gitobj-tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	odb, err := FromFilesystem(root, tmp, BatchedWrites())
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	path := filepath.Join(root, hex.EncodeToString(sha)[:2], hex.EncodeToString(sha)[2:])
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+	assert.NoError(t, blob.Close())
+
+	require.NoError(t, odb.Flush())
+
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
+
+func TestSyncedWritesAreSyncedByFlush(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", SyncedWrites())
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	// The object is in place immediately, but is not synced until the
+	// next flush.
+	path := filepath.Join(root, hex.EncodeToString(sha)[:2], hex.EncodeToString(sha)[2:])
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+
+	fs := odb.rw.(*fileStorer)
+	assert.Equal(t, map[string]struct{}{path: {}}, fs.unsynced)
+
+	require.NoError(t, odb.Flush())
+	assert.Empty(t, fs.unsynced)
+}
+
+func TestFsyncObjectFilesWritesReadOnlyObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", FsyncObjectFiles())
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	// The object is in place, and synced, immediately.
+	dir := filepath.Join(root, hex.EncodeToString(sha)[:2])
+	path := filepath.Join(dir, hex.EncodeToString(sha)[2:])
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0444), fi.Mode().Perm())
+	}
+	assert.Empty(t, odb.rw.(*fileStorer).unsynced)
+
+	// No temporary file is left behind.
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// Writing the object again leaves it in place.
+	_, err = odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+	contents, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+}
+
+func TestBatchedWritesAreFlushedOnClose(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	tmp, err := ioutil.TempDir("", "gitobj-tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	odb, err := FromFilesystem(root, tmp, BatchedWrites())
+	require.NoError(t, err)
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, odb.Close())
+
+	path := filepath.Join(root, hex.EncodeToString(sha)[:2], hex.EncodeToString(sha)[2:])
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
+
+func TestPackedWritesAreWrittenToAPackfile(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	tmp, err := ioutil.TempDir("", "gitobj-tmp")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	odb, err := FromFilesystem(root, tmp, PackedWrites())
+	require.NoError(t, err)
+	defer odb.Close()
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	require.NoError(t, odb.Flush())
+
+	packs, err := filepath.Glob(filepath.Join(root, "pack", "pack-*.pack"))
+	require.NoError(t, err)
+	assert.Len(t, packs, 1)
+
+	idxs, err := filepath.Glob(filepath.Join(root, "pack", "pack-*.idx"))
+	require.NoError(t, err)
+	assert.Len(t, idxs, 1)
+
+	for _, sha := range [][]byte{blob, tree} {
+		path := filepath.Join(root, hex.EncodeToString(sha)[:2], hex.EncodeToString(sha)[2:])
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	got, err := odb.Tree(tree)
+	require.NoError(t, err)
+	assert.Equal(t, blob, got.Entries[0].Oid)
+
+	// The packfile remains readable once the database is reopened.
+	require.NoError(t, odb.Close())
+	require.NoError(t, odb.Reopen())
+
+	b, err := odb.Blob(blob)
+	require.NoError(t, err)
+
+	contents, err := ioutil.ReadAll(b.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+	assert.NoError(t, b.Close())
+}
+
 func TestReadingAMissingObjectAfterClose(t *testing.T) {
 	sha, _ := hex.DecodeString("af5626b4a114abcb82d63db7c8082c3c4756e51b")
 
@@ -515,3 +766,848 @@ func TestObjectDatabaseRootWithoutRoot(t *testing.T) {
 	assert.Equal(t, "", root)
 	assert.False(t, ok)
 }
+
+func TestViewsShareStorage(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	view := db.View()
+
+	sha, err := view.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: make([]byte, 20), Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	tree, err := db.Tree(sha)
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", tree.Entries[0].Name)
+
+	tree, err = db.View().Tree(sha)
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", tree.Entries[0].Name)
+}
+
+func TestViewsMayBeUsedConcurrently(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			view := db.View()
+			for j := 0; j < 16; j++ {
+				sha, err := view.WriteCommit(&Commit{
+					Author:    "A U Thor <author@example.com> 100 +0000",
+					Committer: "A U Thor <author@example.com> 100 +0000",
+					TreeID:    make([]byte, 20),
+					Message:   fmt.Sprintf("commit %d-%d", i, j),
+				})
+				assert.NoError(t, err)
+
+				commit, err := view.Commit(sha)
+				if assert.NoError(t, err) {
+					assert.Equal(t, fmt.Sprintf("commit %d-%d", i, j), commit.Message)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestClosingAViewLeavesTheDatabaseOpen(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	view := db.View()
+	require.NoError(t, view.Close())
+
+	_, err = view.Blob(sha)
+	assert.Equal(t, ErrDatabaseClosed, err)
+
+	_, err = db.Blob(sha)
+	assert.NoError(t, err)
+}
+
+func TestClosingADatabaseClosesItsViews(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	view := db.View().View()
+	require.NoError(t, db.Close())
+
+	_, err := view.Blob(make([]byte, 20))
+	assert.Equal(t, ErrDatabaseClosed, err)
+}
+
+func TestReopeningAClosedDatabase(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-reopen")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+
+	_, err = db.Blob(sha)
+	assert.Equal(t, ErrDatabaseClosed, err)
+
+	require.NoError(t, db.Reopen())
+
+	blob, err := db.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	assert.EqualValues(t, 14, blob.Size)
+	assert.NoError(t, db.Close())
+}
+
+func TestReloadFindsNewPackfiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Write a packfile as another process would, once "db" is open.
+	writer, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+
+	sha, err := writer.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	require.NoError(t, db.Reload())
+
+	blob, err := db.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	assert.EqualValues(t, 14, blob.Size)
+}
+
+func TestReopeningAnOpenDatabase(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	assert.EqualError(t, db.Reopen(), "gitobj: *ObjectDatabase is not closed")
+}
+
+func TestReopeningADatabaseLeavesOldViewsClosed(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	view := db.View()
+	require.NoError(t, db.Close())
+
+	assert.Equal(t, ErrDatabaseClosed, view.Reopen())
+	require.NoError(t, db.Reopen())
+
+	_, err = view.Blob(sha)
+	assert.Equal(t, ErrDatabaseClosed, err)
+
+	require.NoError(t, view.Reopen())
+
+	_, err = view.Blob(sha)
+	assert.NoError(t, err)
+}
+
+func TestQuarantinedWritesAreWrittenToTheQuarantineDirectory(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	quarantine, err := ioutil.TempDir("", "gitobj-quarantine")
+	require.NoError(t, err)
+	defer os.RemoveAll(quarantine)
+
+	mdb, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	existing, err := mdb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, mdb.Close())
+
+	odb, err := FromFilesystem(root, "", Quarantine(quarantine))
+	require.NoError(t, err)
+	defer odb.Close()
+
+	blob, err := odb.WriteBlob(NewBlobFromBytes([]byte("Goodbye, world!\n")))
+	require.NoError(t, err)
+	tree, err := odb.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "goodbye.txt", Oid: blob, Filemode: 0100644},
+		{Name: "hello.txt", Oid: existing, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	for _, sha := range [][]byte{blob, tree} {
+		name := hex.EncodeToString(sha)
+
+		_, err = os.Stat(filepath.Join(quarantine, name[:2], name[2:]))
+		assert.NoError(t, err)
+		_, err = os.Stat(filepath.Join(root, name[:2], name[2:]))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	got, err := odb.Tree(tree)
+	require.NoError(t, err)
+	require.Len(t, got.Entries, 2)
+
+	for _, entry := range got.Entries {
+		_, err := odb.Blob(entry.Oid)
+		assert.NoError(t, err)
+	}
+
+	dir, ok := odb.Root()
+	assert.True(t, ok)
+	assert.Equal(t, quarantine, dir)
+}
+
+func TestContextVariantsStopWhenCancelled(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = db.BlobContext(ctx, sha)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = db.ObjectContext(ctx, sha)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = db.ReadRangeContext(ctx, sha, 0, 5)
+	assert.Equal(t, context.Canceled, err)
+
+	blob := NewBlobFromBytes([]byte("Goodbye, world!\n"))
+	_, err = db.WriteBlobContext(ctx, blob)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = db.WriteTreeContext(ctx, &Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: sha, Filemode: 0100644},
+	}})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestBlobContextContentsStopWhenCancelled(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	// The contents must be large enough (even when compressed) not to be
+	// read entirely along with the blob's header.
+	contents := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(contents)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes(contents))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	blob, err := db.BlobContext(ctx, sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	cancel()
+
+	_, err = ioutil.ReadAll(blob.Contents)
+	assert.Equal(t, context.Canceled, err)
+}
+
+// cancellingReader is an io.Reader which cancels a context after its first
+// read.
+type cancellingReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (c *cancellingReader) Read(p []byte) (int, error) {
+	defer c.cancel()
+	return c.r.Read(p)
+}
+
+func TestSaveLeavesNoObjectWhenCancelled(t *testing.T) {
+	for desc, setters := range map[string][]Option{
+		"immediate": nil,
+		"batched":   {BatchedWrites()},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "gitobj-objects")
+			require.NoError(t, err)
+			defer os.RemoveAll(root)
+
+			tmp, err := ioutil.TempDir("", "gitobj-tmp")
+			require.NoError(t, err)
+			defer os.RemoveAll(tmp)
+
+			odb, err := FromFilesystem(root, tmp, setters...)
+			require.NoError(t, err)
+			defer odb.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sha := make([]byte, 20)
+			sha[0] = 0xaa
+
+			r := &cancellingReader{
+				r:      bytes.NewReader(make([]byte, 1024*1024)),
+				cancel: cancel,
+			}
+			_, _, err = odb.save(ctx, sha, r)
+			assert.Equal(t, context.Canceled, err)
+
+			require.NoError(t, odb.Flush())
+
+			_, err = os.Stat(filepath.Join(root, "aa"))
+			assert.True(t, os.IsNotExist(err))
+			ok, err := odb.Has(sha)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			files, err := ioutil.ReadDir(tmp)
+			require.NoError(t, err)
+			assert.Empty(t, files)
+		})
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		odb := newTestMemoryDatabase(t)
+		defer odb.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		sha := make([]byte, 20)
+		sha[0] = 0xaa
+
+		r := &cancellingReader{
+			r:      bytes.NewReader(make([]byte, 1024*1024)),
+			cancel: cancel,
+		}
+		_, _, err := odb.save(ctx, sha, r)
+		assert.Equal(t, context.Canceled, err)
+
+		ok, err := odb.Has(sha)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestObjectInfoReadsLooseObjectHeaders(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	typ, size, err := db.ObjectInfo(blob)
+	assert.NoError(t, err)
+	assert.Equal(t, BlobObjectType, typ)
+	assert.EqualValues(t, 14, size)
+}
+
+func TestObjectInfoReadsPackedObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	defer db.Close()
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, db.Flush())
+
+	typ, size, err := db.ObjectInfo(blob)
+	assert.NoError(t, err)
+	assert.Equal(t, BlobObjectType, typ)
+	assert.EqualValues(t, 14, size)
+
+	typ, size, err = db.ObjectInfo(tree)
+	assert.NoError(t, err)
+	assert.Equal(t, TreeObjectType, typ)
+	assert.EqualValues(t, len("100644 hello.txt\x00")+len(blob), size)
+}
+
+func TestObjectInfoOfAMissingObject(t *testing.T) {
+	sha, _ := hex.DecodeString("af5626b4a114abcb82d63db7c8082c3c4756e51b")
+
+	db := newTestMemoryDatabase(t)
+
+	_, _, err := db.ObjectInfo(sha)
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+func TestMissingObjectsInAPartialCloneArePromised(t *testing.T) {
+	missing, _ := hex.DecodeString("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	defer db.Close()
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, db.Flush())
+
+	_, err = db.Blob(missing)
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.False(t, errors.IsMissingPromisorObject(err))
+
+	packs, err := filepath.Glob(filepath.Join(root, "pack", "pack-*.pack"))
+	require.NoError(t, err)
+	require.Len(t, packs, 1)
+	require.NoError(t, ioutil.WriteFile(
+		strings.TrimSuffix(packs[0], ".pack")+".promisor", nil, 0644))
+
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Reopen())
+
+	_, err = db.Blob(missing)
+	assert.True(t, errors.IsMissingPromisorObject(err))
+	assert.True(t, errors.IsNoSuchObject(err))
+
+	_, _, err = db.ObjectInfo(missing)
+	assert.True(t, errors.IsMissingPromisorObject(err))
+
+	b, err := db.Blob(blob)
+	require.NoError(t, err)
+	assert.NoError(t, b.Close())
+}
+
+func TestMaxObjectSizeRejectsLargeObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	looseSha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	packedSha, err := db.WriteBlob(NewBlobFromBytes([]byte("Packed, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, db.Flush())
+	require.NoError(t, db.Close())
+
+	db, err = FromFilesystem(root, "", MaxObjectSize(13))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Blob(looseSha)
+	assert.Equal(t, &ObjectTooLarge{Oid: looseSha, Size: 14, Limit: 13}, err)
+	_, err = db.Blob(packedSha)
+	assert.Equal(t, &ObjectTooLarge{Oid: packedSha, Size: 15, Limit: 13}, err)
+
+	typ, size, err := db.ObjectInfo(packedSha)
+	assert.NoError(t, err)
+	assert.Equal(t, BlobObjectType, typ)
+	assert.EqualValues(t, 15, size)
+}
+
+func TestMaxHeaderLengthRejectsLongHeaders(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b, MaxHeaderLength(7))
+	require.NoError(t, err)
+
+	short, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello\n")))
+	require.NoError(t, err)
+	long, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	blob, err := db.Blob(short)
+	require.NoError(t, err)
+	blob.Close()
+
+	_, err = db.Blob(long)
+	assert.Equal(t, &loose.HeaderTooLongErr{Limit: 7}, err)
+}
+
+func TestAllowedTypesRejectsOtherTypes(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b, AllowedTypes(BlobObjectType))
+	require.NoError(t, err)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	o, err := db.Object(blob)
+	require.NoError(t, err)
+	assert.Equal(t, BlobObjectType, o.Type())
+
+	_, err = db.Object(tree)
+	assert.Equal(t, &DisallowedObjectType{Oid: tree, Type: TreeObjectType}, err)
+
+	_, err = db.Tree(tree)
+	assert.Equal(t, &DisallowedObjectType{Oid: tree, Type: TreeObjectType}, err)
+
+	// Views are restricted in the same way.
+	_, err = db.View().Tree(tree)
+	assert.Equal(t, &DisallowedObjectType{Oid: tree, Type: TreeObjectType}, err)
+
+	typ, _, err := db.ObjectInfo(tree)
+	assert.NoError(t, err)
+	assert.Equal(t, TreeObjectType, typ)
+}
+
+func TestCompressionLevelIsUsedForLooseObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", CompressionLevel(zlib.NoCompression))
+	require.NoError(t, err)
+	defer odb.Close()
+
+	contents := strings.Repeat("Hello, world!\n", 100)
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte(contents)))
+	require.NoError(t, err)
+
+	path := filepath.Join(root, hex.EncodeToString(sha)[:2], hex.EncodeToString(sha)[2:])
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, fi.Size() > int64(len(contents)))
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	got, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, contents, string(got))
+}
+
+type countingCompressor struct {
+	readers, writers int
+}
+
+func (c *countingCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	c.readers++
+	return zlib.NewReader(r)
+}
+
+func (c *countingCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	c.writers++
+	return zlib.NewWriterLevel(w, level)
+}
+
+func TestCompressionIsUsedForLooseObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	c := new(countingCompressor)
+
+	odb, err := FromFilesystem(root, "", Compression(c))
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.writers)
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	require.NoError(t, blob.Close())
+	assert.Equal(t, 1, c.readers)
+
+	mdb, err := FromBackend(&memoryBackend{ms: newMemoryStorer(nil)}, Compression(c))
+	require.NoError(t, err)
+	defer mdb.Close()
+
+	sha, err = mdb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.writers)
+
+	blob, err = mdb.Blob(sha)
+	require.NoError(t, err)
+	require.NoError(t, blob.Close())
+	assert.Equal(t, 2, c.readers)
+}
+
+func TestVerifiedPacks(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, odb.Close())
+
+	paths, err := filepath.Glob(filepath.Join(root, "pack", "*.pack"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	data, err := ioutil.ReadFile(paths[0])
+	require.NoError(t, err)
+	data[len(data)-odb.Hasher().Size()-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(paths[0], data, 0644))
+
+	odb, err = FromFilesystem(root, "", VerifiedPacks())
+	require.NoError(t, err)
+	defer odb.Close()
+
+	_, err = odb.Blob(sha)
+	require.IsType(t, &pack.CorruptPackErr{}, err)
+	assert.Equal(t, paths[0], err.(*pack.CorruptPackErr).Name)
+}
+
+func TestMaxOpenPackFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	var shas [][]byte
+	for _, data := range []string{"a\n", "b\n", "c\n"} {
+		odb, err := FromFilesystem(root, "", PackedWrites())
+		require.NoError(t, err)
+		sha, err := odb.WriteBlob(NewBlobFromBytes([]byte(data)))
+		require.NoError(t, err)
+		require.NoError(t, odb.Close())
+
+		shas = append(shas, sha)
+	}
+
+	odb, err := FromFilesystem(root, "", MaxOpenPackFiles(2))
+	require.NoError(t, err)
+	defer odb.Close()
+
+	for _, sha := range shas {
+		blob, err := odb.Blob(sha)
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadAll(blob.Contents)
+		require.NoError(t, err)
+		require.NoError(t, blob.Close())
+		assert.Len(t, data, 2)
+	}
+}
+
+func TestPackFileHooks(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, odb.Close())
+
+	var mu sync.Mutex
+	events := make(map[string][]string)
+	record := func(event string) func(string) {
+		return func(path string) {
+			mu.Lock()
+			defer mu.Unlock()
+			events[event] = append(events[event], filepath.Ext(path))
+		}
+	}
+
+	odb, err = FromFilesystem(root, "", PackFileHooks(&pack.FileHooks{
+		Open:  record("open"),
+		Evict: record("evict"),
+		Close: record("close"),
+	}))
+	require.NoError(t, err)
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	require.NoError(t, blob.Close())
+
+	assert.ElementsMatch(t, []string{".idx", ".pack"}, events["open"])
+
+	require.NoError(t, odb.Close())
+	assert.ElementsMatch(t, []string{".idx", ".pack"}, events["close"])
+	assert.Empty(t, events["evict"])
+}
+
+func TestUnpooledReads(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", UnpooledReads())
+	require.NoError(t, err)
+	defer odb.Close()
+
+	assert.Equal(t, storage.UnpooledZlib, odb.compressor)
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+
+	require.NoError(t, blob.Close())
+
+	r, err := odb.open(context.Background(), sha)
+	require.NoError(t, err)
+	assert.False(t, r.pooled)
+	assert.NoError(t, r.Close())
+}
+
+func TestHasReportsPresentObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	inPack, err := packed.WriteBlob(NewBlobFromBytes([]byte("packed\n")))
+	require.NoError(t, err)
+	require.NoError(t, packed.Flush())
+	require.NoError(t, packed.Close())
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	loose, err := db.WriteBlob(NewBlobFromBytes([]byte("loose\n")))
+	require.NoError(t, err)
+
+	for _, sha := range [][]byte{inPack, loose} {
+		ok, err := db.Has(sha)
+		assert.NoError(t, err)
+		assert.True(t, ok, "expected %x to be present", sha)
+	}
+
+	ok, err := db.Has(make([]byte, 20))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestHasSearchesAlternates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-alternates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dirs := alternatesTestDirs(t, dir, "root", "a", "b")
+	writeTestAlternates(t, dirs[0], dirs[1])
+	writeTestAlternates(t, dirs[1], dirs[2])
+
+	alt, err := FromFilesystem(dirs[2], "", PackedWrites())
+	require.NoError(t, err)
+	sha, err := alt.WriteBlob(NewBlobFromBytes([]byte("alternate\n")))
+	require.NoError(t, err)
+	require.NoError(t, alt.Flush())
+	require.NoError(t, alt.Close())
+
+	db, err := FromFilesystem(dirs[0], "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ok, err := db.Has(sha)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestHasOfUnmappedCompatOid(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	db, err := FromBackend(b, ObjectFormat(ObjectFormatSHA256),
+		CompatObjectFormat(ObjectFormatSHA1))
+	require.NoError(t, err)
+
+	ok, err := db.Has(make([]byte, 20))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRecoverTruncatedObjectsReadsIntactCopies(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	sha, err := packed.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, packed.Close())
+
+	// Leave a truncated loose copy of the packed object, which is read in
+	// preference to it.
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err = zw.Write([]byte("blob 14\x00Hello, world!\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	path := filepath.Join(root, hex.EncodeToString(sha)[:2], hex.EncodeToString(sha)[2:])
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes()[:buf.Len()/2], 0444))
+
+	odb, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	_, _, err = odb.Copy(sha, ioutil.Discard)
+	assert.Error(t, err)
+	require.NoError(t, odb.Close())
+
+	odb, err = FromFilesystem(root, "", RecoverTruncatedObjects())
+	require.NoError(t, err)
+	defer odb.Close()
+
+	blob, err := odb.Blob(sha)
+	require.NoError(t, err)
+	defer blob.Close()
+	contents, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(contents))
+}
+
+func TestRecoverTruncatedObjectsReportsCorruptObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", RecoverTruncatedObjects())
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	path := filepath.Join(root, hex.EncodeToString(sha)[:2], hex.EncodeToString(sha)[2:])
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, data[:len(data)/2], 0644))
+
+	_, err = odb.Object(sha)
+	require.IsType(t, &CorruptLooseObject{}, err)
+	assert.Equal(t, sha, err.(*CorruptLooseObject).Oid)
+	assert.Equal(t, path, err.(*CorruptLooseObject).Path)
+}
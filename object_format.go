@@ -0,0 +1,129 @@
+package gitobj
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sync"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// ObjectFormatInfo describes an object format: the hash algorithm by which
+// objects are named, and the names it gives to well-known objects.
+type ObjectFormatInfo struct {
+	// Name is the name of the object format, as given to ObjectFormat.
+	Name ObjectFormatAlgorithm
+	// New returns a new instance of the object format's hash algorithm.
+	New func() hash.Hash
+	// Size is the length of the object format's object IDs, in bytes.
+	Size int
+	// EmptyTree is the name of the empty tree in the object format.
+	EmptyTree Oid
+	// EmptyBlob is the name of the empty blob in the object format.
+	EmptyBlob Oid
+}
+
+var (
+	formatsMu sync.RWMutex
+	// formats holds the registered object formats, in the order in which
+	// they were registered.
+	formats []*ObjectFormatInfo
+)
+
+func init() {
+	RegisterObjectFormat(ObjectFormatSHA1, sha1.New, 1)
+	RegisterObjectFormat(ObjectFormatSHA256, sha256.New, 2)
+}
+
+// RegisterObjectFormat registers an object format with the given name, whose
+// objects are named by the hash algorithm whose instances are returned by
+// "newHash", so that it may be given to ObjectFormat (or CompatObjectFormat),
+// as with an experimental format, or a truncated hash for testing. The version
+// identifies the format in the headers of multi-pack-indexes, reverse indexes,
+// and commit-graphs (see: pack.RegisterHash). SHA-1 (version 1) and SHA-256
+// (version 2) are registered already.
+//
+// An error is returned if a format with the same name has been registered, or
+// if the hash's object IDs are longer than pack.MaxHashSize.
+func RegisterObjectFormat(name ObjectFormatAlgorithm, newHash func() hash.Hash, version byte) error {
+	if len(name) == 0 {
+		return fmt.Errorf("gitobj: object format has no name")
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	for _, f := range formats {
+		if f.Name == name {
+			return fmt.Errorf("gitobj: object format %q already registered", name)
+		}
+	}
+	if err := pack.RegisterHash(newHash, version); err != nil {
+		return err
+	}
+
+	info := &ObjectFormatInfo{
+		Name:      name,
+		New:       newHash,
+		Size:      newHash().Size(),
+		EmptyTree: emptyOid(newHash(), TreeObjectType),
+		EmptyBlob: emptyOid(newHash(), BlobObjectType),
+	}
+	formats = append(formats, info)
+	return nil
+}
+
+// LookupObjectFormat returns a description of the registered object format
+// with the given name, and whether there is one (see: RegisterObjectFormat).
+func LookupObjectFormat(name ObjectFormatAlgorithm) (ObjectFormatInfo, bool) {
+	if f := lookupFormat(name); f != nil {
+		return *f, true
+	}
+	return ObjectFormatInfo{}, false
+}
+
+// lookupFormat returns the registered object format with the given name, or nil
+// if there is none.
+func lookupFormat(name ObjectFormatAlgorithm) *ObjectFormatInfo {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for _, f := range formats {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// oidFormat returns the first registered object format whose object IDs are
+// "n" bytes long, or the empty string if there is none.
+func oidFormat(n int) ObjectFormatAlgorithm {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for _, f := range formats {
+		if f.Size == n {
+			return f.Name
+		}
+	}
+	return ""
+}
+
+// hasher returns a new instance of the hash algorithm of the object format
+// with the given name, or nil if it has not been registered.
+func hasher(algo ObjectFormatAlgorithm) hash.Hash {
+	if f := lookupFormat(algo); f != nil {
+		return f.New()
+	}
+	return nil
+}
+
+// emptyOid returns the name given by the hash "h" to the empty object of the
+// given type.
+func emptyOid(h hash.Hash, typ ObjectType) Oid {
+	fmt.Fprintf(h, "%s 0\x00", typ)
+	return h.Sum(nil)
+}
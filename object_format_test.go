@@ -0,0 +1,99 @@
+package gitobj
+
+import (
+	"crypto/sha256"
+	"hash"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// truncatedHash is a hash.Hash whose sums are those of the hash it wraps,
+// truncated to "n" bytes.
+type truncatedHash struct {
+	hash.Hash
+	n int
+}
+
+func (h *truncatedHash) Size() int { return h.n }
+
+func (h *truncatedHash) Sum(b []byte) []byte {
+	return h.Hash.Sum(b)[:len(b)+h.n]
+}
+
+const truncatedObjectFormat = ObjectFormatAlgorithm("sha256-24")
+
+var registerTruncatedOnce sync.Once
+
+func registerTruncatedObjectFormat(t *testing.T) {
+	registerTruncatedOnce.Do(func() {
+		require.NoError(t, RegisterObjectFormat(truncatedObjectFormat, func() hash.Hash {
+			return &truncatedHash{Hash: sha256.New(), n: 24}
+		}, 0x80))
+	})
+}
+
+func TestLookupObjectFormat(t *testing.T) {
+	sha1Format, ok := LookupObjectFormat(ObjectFormatSHA1)
+	require.True(t, ok)
+	assert.Equal(t, 20, sha1Format.Size)
+	assert.Equal(t, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", sha1Format.EmptyTree.String())
+	assert.Equal(t, "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", sha1Format.EmptyBlob.String())
+
+	sha256Format, ok := LookupObjectFormat(ObjectFormatSHA256)
+	require.True(t, ok)
+	assert.Equal(t, 32, sha256Format.Size)
+	assert.Equal(t, "6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321",
+		sha256Format.EmptyTree.String())
+
+	_, ok = LookupObjectFormat("md5")
+	assert.False(t, ok)
+}
+
+func TestRegisterObjectFormatRejectsDuplicates(t *testing.T) {
+	err := RegisterObjectFormat(ObjectFormatSHA1, sha256.New, 2)
+	assert.EqualError(t, err, `gitobj: object format "sha1" already registered`)
+}
+
+func TestUnknownObjectFormat(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	_, err = FromBackend(b, ObjectFormat("md5"))
+	assert.EqualError(t, err, `gitobj: unknown object format "md5"`)
+}
+
+func TestRegisteredObjectFormat(t *testing.T) {
+	registerTruncatedObjectFormat(t)
+
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", ObjectFormat(truncatedObjectFormat))
+	require.NoError(t, err)
+	defer db.Close()
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	assert.Len(t, blob, 24)
+
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	read, err := db.Tree(tree)
+	require.NoError(t, err)
+	require.Len(t, read.Entries, 1)
+	assert.Equal(t, blob, read.Entries[0].Oid)
+
+	oid, err := db.ParseOid(Oid(blob).String())
+	require.NoError(t, err)
+	assert.Equal(t, blob, []byte(oid))
+	assert.Equal(t, truncatedObjectFormat, Oid(blob).Format())
+}
@@ -2,12 +2,14 @@ package gitobj
 
 import (
 	"bufio"
-	"compress/zlib"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/git-lfs/gitobj/v2/loose"
+	"github.com/git-lfs/gitobj/v2/storage"
 )
 
 // ObjectReader provides an io.Reader implementation that can read Git object
@@ -35,6 +37,46 @@ type ObjectReader struct {
 	//
 	// It is allowed to be nil.
 	closeFn func() error
+	// pooled indicates whether "r" was drawn from bufferedReaders, to which
+	// it is returned once the *ObjectReader is closed.
+	pooled bool
+	// strict indicates whether Header returns an error if the header gives
+	// an unknown type (see: StrictObjectTypes).
+	strict bool
+	// headerLimit is the greatest length of header read by Header, or
+	// zero for loose.DefaultMaxHeaderLength (see: MaxHeaderLength).
+	headerLimit int
+	// sizeLimit is the greatest size of object whose header is accepted by
+	// Header, or zero if sizes are not limited (see: MaxObjectSize).
+	sizeLimit int64
+	// oid is the name of the object being read, if known, with which an
+	// *ObjectTooLarge error is reported.
+	oid []byte
+	// inflated, if it is not nil, counts the bytes of the object's
+	// contents read, and is managed by sync/atomic (see: Stats).
+	inflated *uint64
+}
+
+var (
+	// bufferedReaders is a pool of *bufio.Readers, which are reused by the
+	// *ObjectReaders opened from an *ObjectDatabase.
+	bufferedReaders sync.Pool
+
+	// errObjectReaderClosed is returned when reading from an *ObjectReader
+	// whose buffer has been returned to the pool.
+	errObjectReaderClosed = fmt.Errorf("gitobj: read from closed object reader")
+)
+
+// newBufferedReader returns a *bufio.Reader reading from "r", which is drawn
+// from bufferedReaders if "pooled" is true.
+func newBufferedReader(r io.Reader, pooled bool) *bufio.Reader {
+	if pooled {
+		if br, ok := bufferedReaders.Get().(*bufio.Reader); ok {
+			br.Reset(r)
+			return br
+		}
+	}
+	return bufio.NewReader(r)
 }
 
 // NewObjectReader takes a given io.Reader that yields zlib-compressed data, and
@@ -58,13 +100,21 @@ func NewUncompressedObjectReader(r io.Reader) (*ObjectReader, error) {
 // It also calls the Close() function given by the implementation "r" of the
 // type io.Closer.
 func NewObjectReadCloser(r io.ReadCloser) (*ObjectReader, error) {
-	zr, err := zlib.NewReader(r)
+	return newObjectReadCloser(r, storage.Zlib, false)
+}
+
+// newObjectReadCloser is as NewObjectReadCloser, but decompresses the data read
+// from "r" with the given Compressor, and draws its buffer from a pool if
+// "pooled" is true.
+func newObjectReadCloser(r io.ReadCloser, c storage.Compressor, pooled bool) (*ObjectReader, error) {
+	zr, err := c.NewReader(r)
 	if err != nil {
 		return nil, err
 	}
 
 	return &ObjectReader{
-		r: bufio.NewReader(zr),
+		r:      newBufferedReader(zr, pooled),
+		pooled: pooled,
 		closeFn: func() error {
 			if err := zr.Close(); err != nil {
 				return err
@@ -84,10 +134,17 @@ func NewObjectReadCloser(r io.ReadCloser) (*ObjectReader, error) {
 // It also calls the Close() function given by the implementation "r" of the
 // type io.Closer.
 func NewUncompressedObjectReadCloser(r io.ReadCloser) (*ObjectReader, error) {
+	return newUncompressedObjectReadCloser(r, false), nil
+}
+
+// newUncompressedObjectReadCloser is as NewUncompressedObjectReadCloser, but
+// draws its buffer from a pool if "pooled" is true.
+func newUncompressedObjectReadCloser(r io.ReadCloser, pooled bool) *ObjectReader {
 	return &ObjectReader{
-		r:       bufio.NewReader(r),
+		r:       newBufferedReader(r, pooled),
 		closeFn: r.Close,
-	}, nil
+		pooled:  pooled,
+	}
 }
 
 // Header returns information about the Object's header, or an error if one
@@ -99,34 +156,36 @@ func (r *ObjectReader) Header() (typ ObjectType, size int64, err error) {
 	if r.header != nil {
 		return r.header.typ, r.header.size, nil
 	}
-
-	typs, err := r.r.ReadString(' ')
-	if err != nil {
-		return UnknownObjectType, 0, err
+	if r.r == nil {
+		return UnknownObjectType, 0, errObjectReaderClosed
 	}
-	if len(typs) == 0 {
-		return UnknownObjectType, 0, fmt.Errorf(
-			"gitobj: object type must not be empty",
-		)
+
+	limit := r.headerLimit
+	if limit == 0 {
+		limit = loose.DefaultMaxHeaderLength
 	}
-	typs = strings.TrimSuffix(typs, " ")
 
-	sizeStr, err := r.r.ReadString('\x00')
+	typs, size, err := loose.ReadHeaderLimit(r.r, limit)
 	if err != nil {
 		return UnknownObjectType, 0, err
 	}
-	sizeStr = strings.TrimSuffix(sizeStr, "\x00")
+	if r.sizeLimit > 0 && size > r.sizeLimit {
+		return UnknownObjectType, 0, &ObjectTooLarge{Oid: r.oid,
+			Size: uint64(size), Limit: r.sizeLimit}
+	}
 
-	size, err = strconv.ParseInt(sizeStr, 10, 64)
-	if err != nil {
-		return UnknownObjectType, 0, err
+	typ = ObjectTypeFromString(typs)
+	if r.strict {
+		if typ, err = ParseObjectType(typs); err != nil {
+			return UnknownObjectType, 0, err
+		}
 	}
 
 	r.header = &struct {
 		typ  ObjectType
 		size int64
 	}{
-		ObjectTypeFromString(typs),
+		typ,
 		size,
 	}
 
@@ -143,7 +202,15 @@ func (r *ObjectReader) Read(p []byte) (n int, err error) {
 	if _, _, err = r.Header(); err != nil {
 		return 0, err
 	}
-	return r.r.Read(p)
+	if r.r == nil {
+		return 0, errObjectReaderClosed
+	}
+
+	n, err = r.r.Read(p)
+	if r.inflated != nil {
+		atomic.AddUint64(r.inflated, uint64(n))
+	}
+	return n, err
 }
 
 // Close frees any resources held by the ObjectReader and must be called before
@@ -151,8 +218,15 @@ func (r *ObjectReader) Read(p []byte) (n int, err error) {
 //
 // It returns any error encountered by the *ObjectReader during close.
 func (r *ObjectReader) Close() error {
-	if r.closeFn == nil {
-		return nil
+	var err error
+	if r.closeFn != nil {
+		err = r.closeFn()
+	}
+
+	if r.pooled && r.r != nil {
+		r.r.Reset(nil)
+		bufferedReaders.Put(r.r)
+		r.r = nil
 	}
-	return r.closeFn()
+	return err
 }
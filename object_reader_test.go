@@ -5,6 +5,7 @@ import (
 	"compress/zlib"
 	"errors"
 	"io"
+	"io/ioutil"
 	"sync/atomic"
 	"testing"
 
@@ -74,3 +75,23 @@ func TestObjectReaderCallsClose(t *testing.T) {
 	assert.EqualValues(t, 1, atomic.LoadUint32(&calls))
 
 }
+
+func TestObjectReaderReturnsPooledBufferOnClose(t *testing.T) {
+	or := newUncompressedObjectReadCloser(
+		ioutil.NopCloser(bytes.NewBufferString("blob 4\x00asdf")), true)
+
+	contents, err := ioutil.ReadAll(or)
+	assert.Nil(t, err)
+	assert.Equal(t, "asdf", string(contents))
+
+	assert.Nil(t, or.Close())
+	assert.Nil(t, or.r)
+
+	typ, size, err := or.Header()
+	assert.Nil(t, err)
+	assert.Equal(t, BlobObjectType, typ)
+	assert.EqualValues(t, 4, size)
+
+	_, err = or.Read(make([]byte, 1))
+	assert.Equal(t, errObjectReaderClosed, err)
+}
@@ -1,6 +1,10 @@
 package gitobj
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
 
 // ObjectType is a constant enumeration type for identifying the kind of object
 // type an implementing instance of the Object interface is.
@@ -15,7 +19,8 @@ const (
 )
 
 // ObjectTypeFromString converts from a given string to an ObjectType
-// enumeration instance.
+// enumeration instance. It ignores case, and returns UnknownObjectType for any
+// other string (see: ParseObjectType).
 func ObjectTypeFromString(s string) ObjectType {
 	switch strings.ToLower(s) {
 	case "blob":
@@ -31,6 +36,25 @@ func ObjectTypeFromString(s string) ObjectType {
 	}
 }
 
+// ParseObjectType converts from the name of an object type, exactly as Git
+// writes it in object headers and in the "type" header of tags ("blob",
+// "tree", "commit", or "tag"), to an ObjectType enumeration instance. Unlike
+// ObjectTypeFromString, it returns an *UnknownObjectTypeError holding the name
+// for any other string, including those which differ only in case.
+func ParseObjectType(s string) (ObjectType, error) {
+	switch s {
+	case "blob":
+		return BlobObjectType, nil
+	case "tree":
+		return TreeObjectType, nil
+	case "commit":
+		return CommitObjectType, nil
+	case "tag":
+		return TagObjectType, nil
+	}
+	return UnknownObjectType, &UnknownObjectTypeError{Type: []byte(s)}
+}
+
 // String implements the fmt.Stringer interface and returns a string
 // representation of the ObjectType enumeration instance.
 func (t ObjectType) String() string {
@@ -48,3 +72,35 @@ func (t ObjectType) String() string {
 	}
 	return "<unknown>"
 }
+
+// objectType returns the type of an object stored in a packfile with the given
+// type, or UnknownObjectType if it is not the type of an object.
+func objectType(t pack.PackedObjectType) ObjectType {
+	switch t {
+	case pack.TypeBlob:
+		return BlobObjectType
+	case pack.TypeTree:
+		return TreeObjectType
+	case pack.TypeCommit:
+		return CommitObjectType
+	case pack.TypeTag:
+		return TagObjectType
+	}
+	return UnknownObjectType
+}
+
+// packedObjectType returns the type with which an object of the given type is
+// stored in a packfile, or pack.TypeNone if it cannot be.
+func packedObjectType(t ObjectType) pack.PackedObjectType {
+	switch t {
+	case BlobObjectType:
+		return pack.TypeBlob
+	case TreeObjectType:
+		return pack.TypeTree
+	case CommitObjectType:
+		return pack.TypeCommit
+	case TagObjectType:
+		return pack.TypeTag
+	}
+	return pack.TypeNone
+}
@@ -1,10 +1,15 @@
 package gitobj
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
 	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestObjectTypeFromString(t *testing.T) {
@@ -35,3 +40,65 @@ func TestObjectTypeToString(t *testing.T) {
 		})
 	}
 }
+
+func TestParseObjectType(t *testing.T) {
+	for str, typ := range map[string]ObjectType{
+		"blob":   BlobObjectType,
+		"tree":   TreeObjectType,
+		"commit": CommitObjectType,
+		"tag":    TagObjectType,
+	} {
+		t.Run(str, func(t *testing.T) {
+			got, err := ParseObjectType(str)
+			assert.NoError(t, err)
+			assert.Equal(t, typ, got)
+		})
+	}
+}
+
+func TestParseObjectTypeRejectsUnknownTypes(t *testing.T) {
+	for _, str := range []string{"", "Blob", "blob ", "unknown", "\x00tree"} {
+		t.Run(str, func(t *testing.T) {
+			typ, err := ParseObjectType(str)
+			assert.Equal(t, UnknownObjectType, typ)
+			assert.Equal(t, &UnknownObjectTypeError{Type: []byte(str)}, err)
+		})
+	}
+}
+
+func TestStrictObjectTypesRejectsUnknownTypes(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := io.WriteString(zw, "blub 6\x00Hello\n")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	sha := "0000000000000000000000000000000000000000"
+	oid, _ := hex.DecodeString(sha)
+	stored := buf.Bytes()
+
+	for _, strict := range []bool{false, true} {
+		b, err := NewMemoryBackend(map[string]io.ReadWriter{
+			sha: bytes.NewBuffer(append([]byte(nil), stored...)),
+		})
+		require.NoError(t, err)
+
+		var opts []Option
+		if strict {
+			opts = append(opts, StrictObjectTypes())
+		}
+		db, err := FromBackend(b, opts...)
+		require.NoError(t, err)
+
+		typ, size, err := db.ObjectInfo(oid)
+		if !strict {
+			assert.NoError(t, err)
+			assert.Equal(t, UnknownObjectType, typ)
+			assert.EqualValues(t, 6, size)
+			continue
+		}
+
+		assert.Equal(t, UnknownObjectType, typ)
+		assert.EqualError(t, err, `gitobj: unknown object type "blub"`)
+	}
+}
@@ -2,10 +2,11 @@ package gitobj
 
 import (
 	"compress/zlib"
-	"fmt"
 	"hash"
 	"io"
 	"sync/atomic"
+
+	"github.com/git-lfs/gitobj/v2/loose"
 )
 
 // ObjectWriter provides an implementation of io.Writer that compresses and
@@ -23,6 +24,9 @@ type ObjectWriter struct {
 	w io.Writer
 	// sum is the in-progress hash calculation.
 	sum hash.Hash
+	// tees are additional in-progress hash calculations over the contents
+	// of the object, not including its header.
+	tees []hash.Hash
 
 	// closeFn supplies an optional function that, when called, frees an
 	// resources (open files, memory, etc) held by this instance of the
@@ -61,7 +65,14 @@ func NewObjectWriter(w io.Writer, hash hash.Hash) *ObjectWriter {
 //
 // Upon closing, it calls the given Close() function of the io.WriteCloser.
 func NewObjectWriteCloser(w io.WriteCloser, sum hash.Hash) *ObjectWriter {
-	zw := zlib.NewWriter(w)
+	return newObjectWriteCloser(w, zlib.NewWriter(w), sum)
+}
+
+// newObjectWriteCloser returns a new *ObjectWriter instance that drains
+// incoming writes into the compressing writer "zw", which writes into "w".
+//
+// Upon closing, it closes "zw", followed by "w".
+func newObjectWriteCloser(w, zw io.WriteCloser, sum hash.Hash) *ObjectWriter {
 	sum.Reset()
 
 	return &ObjectWriter{
@@ -88,7 +99,22 @@ func (w *ObjectWriter) WriteHeader(typ ObjectType, len int64) (n int, err error)
 	if !atomic.CompareAndSwapUint32(&w.wroteHeader, 0, 1) {
 		panic("gitobj: cannot write headers more than once")
 	}
-	return fmt.Fprintf(w, "%s %d\x00", typ, len)
+	return loose.WriteHeader(w.w, typ.String(), len)
+}
+
+// Tee registers additional hashes which are computed over the uncompressed
+// contents of the object (excluding its header) in the same pass as its SHA,
+// for instance to compute the SHA-256 of a blob's contents while writing it.
+// Each hash is reset before use, and its sum may be read once the contents
+// have been written.
+//
+// Tee must be called before the first call to Write, otherwise the hashes
+// will not see the entire contents of the object.
+func (w *ObjectWriter) Tee(sums ...hash.Hash) {
+	for _, sum := range sums {
+		sum.Reset()
+	}
+	w.tees = append(w.tees, sums...)
 }
 
 // Write writes the given buffer "p" of uncompressed bytes into the underlying
@@ -101,7 +127,12 @@ func (w *ObjectWriter) Write(p []byte) (n int, err error) {
 	if atomic.LoadUint32(&w.wroteHeader) != 1 {
 		panic("gitobj: cannot write data without header")
 	}
-	return w.w.Write(p)
+
+	n, err = w.w.Write(p)
+	for _, sum := range w.tees {
+		sum.Write(p[:n])
+	}
+	return n, err
 }
 
 // Sha returns the in-progress SHA1 of the compressed object contents.
@@ -117,6 +117,24 @@ func TestObjectWriterKeepsTrackOfHash(t *testing.T) {
 	assert.Equal(t, "3a68c454a6eb75cc55bda147a53756f0f581497eb80b9b67156fb8a8d3931cd7", hex.EncodeToString(w.Sha()))
 }
 
+func TestObjectWriterTeesContentsToAdditionalHashes(t *testing.T) {
+	sum := sha256.New()
+	sum.Write([]byte("garbage"))
+
+	w := NewObjectWriter(new(bytes.Buffer), sha1.New())
+	w.Tee(sum)
+
+	_, err := w.WriteHeader(BlobObjectType, 14)
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("Hello, world!\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+
+	// The hash of the contents alone, excluding the object header.
+	assert.Equal(t, "d9014c4624844aa5bac314773d6b689ad467fa4e1d1a50a1b8a99d5a95f72ff5", hex.EncodeToString(sum.Sum(nil)))
+	assert.Equal(t, "af5626b4a114abcb82d63db7c8082c3c4756e51b", hex.EncodeToString(w.Sha()))
+}
+
 type WriteCloserFn struct {
 	io.Writer
 	closeFn func() error
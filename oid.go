@@ -0,0 +1,58 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// Oid is an object ID: the name of an object, as given by the hash of its
+// contents in one of the object formats.
+//
+// Since it is a byte slice, an Oid may be passed wherever an object ID is
+// expected.
+type Oid []byte
+
+// String returns the object ID in hexadecimal, as it is written by Git.
+func (oid Oid) String() string {
+	return hex.EncodeToString(oid)
+}
+
+// Format returns the object format in which the object ID was computed, as
+// given by its length, or the empty string if its length is not that of any
+// registered object format. If several formats' object IDs are as long, the
+// first to have been registered is returned (see: RegisterObjectFormat).
+func (oid Oid) Format() ObjectFormatAlgorithm {
+	return oidFormat(len(oid))
+}
+
+// ParseOid parses the hexadecimal object ID "s", which may be in either the
+// database's object format (see: ObjectFormat) or its compatibility object
+// format, if it has one (see: CompatObjectFormat), and returns it.
+//
+// If "s" is not hexadecimal, or if it is not the length of an object ID in
+// either format, a descriptive error is returned.
+func (o *ObjectDatabase) ParseOid(s string) (Oid, error) {
+	oid, err := hex.DecodeString(s)
+	if err != nil && err != hex.ErrLength {
+		return nil, fmt.Errorf("gitobj: invalid object ID %q: not hexadecimal", s)
+	}
+
+	size := hasher(o.objectFormat).Size()
+	if err == nil && len(oid) == size {
+		return oid, nil
+	}
+	if compat := hasher(o.compatObjectFormat); err == nil && compat != nil && len(oid) == compat.Size() {
+		return oid, nil
+	}
+
+	var format ObjectFormatAlgorithm
+	if err == nil {
+		format = oidFormat(len(oid))
+	}
+	if len(format) == 0 {
+		return nil, fmt.Errorf("gitobj: invalid object ID %q: %d hex digit(s), expected %d",
+			s, len(s), 2*size)
+	}
+	return nil, fmt.Errorf("gitobj: invalid object ID %q: %s object ID in %s repository",
+		s, format, o.objectFormat)
+}
@@ -0,0 +1,90 @@
+package gitobj
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOid(t *testing.T) {
+	const (
+		sha1Oid   = "af5626b4a114abcb82d63db7c8082c3c4756e51b"
+		sha256Oid = "473a0f4c3be8a93681a267e3b1e9a7dcda1185436fe141f7749120a303721813"
+	)
+
+	sha1DB := newTestMemoryDatabase(t)
+
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	sha256DB, err := FromBackend(b, ObjectFormat(ObjectFormatSHA256))
+	require.NoError(t, err)
+	compatDB, err := FromBackend(b, ObjectFormat(ObjectFormatSHA256),
+		CompatObjectFormat(ObjectFormatSHA1))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		db     *ObjectDatabase
+		s      string
+		format ObjectFormatAlgorithm
+		err    string
+	}{
+		{db: sha1DB, s: sha1Oid, format: ObjectFormatSHA1},
+		{db: sha1DB, s: strings.ToUpper(sha1Oid), format: ObjectFormatSHA1},
+		{db: sha256DB, s: sha256Oid, format: ObjectFormatSHA256},
+		{db: compatDB, s: sha256Oid, format: ObjectFormatSHA256},
+		{db: compatDB, s: sha1Oid, format: ObjectFormatSHA1},
+		{
+			db:  sha1DB,
+			s:   sha256Oid,
+			err: `gitobj: invalid object ID "` + sha256Oid + `": sha256 object ID in sha1 repository`,
+		},
+		{
+			db:  sha256DB,
+			s:   sha1Oid,
+			err: `gitobj: invalid object ID "` + sha1Oid + `": sha1 object ID in sha256 repository`,
+		},
+		{
+			db:  sha1DB,
+			s:   sha1Oid[:39],
+			err: `gitobj: invalid object ID "` + sha1Oid[:39] + `": 39 hex digit(s), expected 40`,
+		},
+		{
+			db:  sha256DB,
+			s:   "",
+			err: `gitobj: invalid object ID "": 0 hex digit(s), expected 64`,
+		},
+		{
+			db:  sha1DB,
+			s:   "zz" + sha1Oid[2:],
+			err: `gitobj: invalid object ID "zz` + sha1Oid[2:] + `": not hexadecimal`,
+		},
+	} {
+		oid, err := test.db.ParseOid(test.s)
+		if len(test.err) > 0 {
+			assert.EqualError(t, err, test.err)
+			assert.Nil(t, oid)
+			continue
+		}
+
+		require.NoError(t, err, test.s)
+		assert.Equal(t, strings.ToLower(test.s), oid.String())
+		assert.Equal(t, test.format, oid.Format())
+	}
+}
+
+func TestParseOidNamesObjects(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	oid, err := db.ParseOid("af5626b4a114abcb82d63db7c8082c3c4756e51b")
+	require.NoError(t, err)
+	assert.Equal(t, sha, []byte(oid))
+
+	blob, err := db.Blob(oid)
+	require.NoError(t, err)
+	assert.NoError(t, blob.Close())
+}
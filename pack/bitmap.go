@@ -0,0 +1,305 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+const (
+	// bitmapEntryHeaderWidth is the width of the fields which precede the
+	// bitmap of each commit in a bitmap file: the commit's position in the
+	// packfile's index, the XOR offset, and flags.
+	bitmapEntryHeaderWidth = 6
+)
+
+// BitmapIndex is a reachability bitmap (".bitmap") file, as written by Git (or
+// by a *BitmapWriter), which records the set of objects reachable from a
+// selection of commits in a packfile, so that reachability queries may be
+// answered without walking any history.
+//
+// Each bit in a bitmap corresponds to a single object in the packfile, ordered
+// by the object's offset in that packfile (see: ReverseIndex). As Git requires,
+// each bitmap holds the full closure of the objects reachable from its commit,
+// every one of which is stored in the packfile.
+type BitmapIndex struct {
+	// p is the packfile whose objects are described.
+	p *Packfile
+	// rev is the reverse index of "p", which maps the positions of bits to
+	// objects.
+	rev *ReverseIndex
+	// n is the number of bits in each bitmap.
+	n int
+
+	// entries holds each commit's entry in the bitmap file, in order.
+	entries []*bitmapIndexEntry
+	// commits maps the name of each commit with a bitmap to its entry.
+	commits map[string]*bitmapIndexEntry
+
+	// r is the underlying data of the bitmap file.
+	r io.ReaderAt
+	// mu guards the "bits" of each entry, which are resolved on demand.
+	mu sync.Mutex
+}
+
+// bitmapIndexEntry is the bitmap of a single commit in a bitmap file.
+type bitmapIndexEntry struct {
+	// at is the offset of the commit's EWAH-compressed bitmap.
+	at int64
+	// xor is the entry against whose bitmap the commit's bitmap is XOR-ed,
+	// or nil if it is stored as-is.
+	xor *bitmapIndexEntry
+	// bits is the commit's resolved bitmap, or nil if it has not yet been
+	// read.
+	bits bitmap
+}
+
+// BitmapIndex returns the reachability bitmap file of the packfile, which must
+// have been opened from disk. If the packfile has no bitmap file, the error
+// returned satisfies os.IsNotExist.
+func (p *Packfile) BitmapIndex() (*BitmapIndex, error) {
+	p.bitmapOnce.Do(func() {
+		if p.idx == nil || len(p.path) == 0 {
+			p.bitmapErr = fmt.Errorf("gitobj/pack: cannot read bitmap without index")
+			return
+		}
+
+		// The bitmap file is held in memory, since its bitmaps
+		// are read on demand.
+		data, err := ioutil.ReadFile(strings.TrimSuffix(p.path, ".pack") + ".bitmap")
+		if err != nil {
+			p.bitmapErr = err
+			return
+		}
+
+		p.bitmap, p.bitmapErr = DecodeBitmapIndex(bytes.NewReader(data), p)
+	})
+	return p.bitmap, p.bitmapErr
+}
+
+// DecodeBitmapIndex reads the bitmap file "r" of the given packfile, whose index
+// must be available. A bitmap file written for a different packfile is
+// rejected.
+//
+// The type bitmaps, and the name hash cache, if any, are not read, and the
+// bitmap of each commit is not read until it is first needed, so "r" must
+// remain readable for as long as the returned *BitmapIndex is used.
+func DecodeBitmapIndex(r io.ReaderAt, p *Packfile) (*BitmapIndex, error) {
+	if p.idx == nil {
+		return nil, fmt.Errorf("gitobj/pack: cannot read bitmap without index")
+	}
+
+	checksum, err := p.idx.PackChecksum()
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := make([]byte, 12+len(checksum))
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr[:4], bitmapHeader) {
+		return nil, fmt.Errorf("gitobj/pack: invalid bitmap header")
+	}
+	if v := binary.BigEndian.Uint16(hdr[4:]); v != bitmapVersion {
+		return nil, fmt.Errorf("gitobj/pack: unsupported bitmap version %d", v)
+	}
+	if !bytes.Equal(hdr[12:], checksum) {
+		return nil, fmt.Errorf("gitobj/pack: bitmap does not match packfile %x", checksum)
+	}
+
+	rev, err := p.ReverseIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BitmapIndex{
+		p:   p,
+		rev: rev,
+		n:   rev.Count(),
+
+		entries: make([]*bitmapIndexEntry, 0, binary.BigEndian.Uint32(hdr[8:])),
+		commits: make(map[string]*bitmapIndexEntry),
+
+		r: r,
+	}
+
+	// Skip the type bitmaps.
+	at := int64(len(hdr))
+	for i := 0; i < 4; i++ {
+		if at, err = skipEWAH(r, at); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < cap(b.entries); i++ {
+		var meta [bitmapEntryHeaderWidth]byte
+		if _, err := r.ReadAt(meta[:], at); err != nil {
+			return nil, err
+		}
+
+		pos := int64(binary.BigEndian.Uint32(meta[0:]))
+		if pos >= p.idx.count() {
+			return nil, fmt.Errorf("gitobj/pack: bitmap names index position %d out of range", pos)
+		}
+		name, err := p.idx.version.Name(p.idx, pos)
+		if err != nil {
+			return nil, err
+		}
+
+		e := &bitmapIndexEntry{at: at + bitmapEntryHeaderWidth}
+		if xor := int(meta[4]); xor > 0 {
+			if xor > i || xor > maxBitmapXorOffset {
+				return nil, fmt.Errorf("gitobj/pack: invalid bitmap XOR offset %d for commit %x", xor, name)
+			}
+			e.xor = b.entries[i-xor]
+		}
+
+		if at, err = skipEWAH(r, e.at); err != nil {
+			return nil, err
+		}
+
+		b.entries = append(b.entries, e)
+		b.commits[string(name)] = e
+	}
+
+	return b, nil
+}
+
+// skipEWAH returns the offset following the EWAH-compressed bitmap beginning at
+// offset "at" in "r", without reading the bitmap itself.
+func skipEWAH(r io.ReaderAt, at int64) (int64, error) {
+	var words [4]byte
+	if _, err := r.ReadAt(words[:], at+4); err != nil {
+		return 0, err
+	}
+	return at + 8 + 8*int64(binary.BigEndian.Uint32(words[:])) + 4, nil
+}
+
+// resolve returns the bitmap of the given entry, reading it (and the bitmap
+// against which it is XOR-ed, in turn) if it has not already been read.
+func (b *BitmapIndex) resolve(e *bitmapIndexEntry) (bitmap, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Find the nearest entry in the chain of XOR bases which has already
+	// been resolved, and resolve each entry after it in turn.
+	var chain []*bitmapIndexEntry
+	for ; e != nil && e.bits == nil; e = e.xor {
+		chain = append(chain, e)
+	}
+
+	var bits bitmap
+	if e != nil {
+		bits = e.bits
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		read, n, _, err := readEWAH(b.r, chain[i].at)
+		if err != nil {
+			return nil, err
+		}
+		// Git rounds the number of bits in each bitmap up to a whole
+		// word, so any bits past the last object are ignored.
+		if n < b.n || n > (b.n+63)/64*64 {
+			return nil, fmt.Errorf("gitobj/pack: bitmap has %d bit(s), expected %d", n, b.n)
+		}
+		read = read.truncate(b.n)
+
+		if chain[i].xor != nil {
+			read = read.Xor(bits)
+		}
+		chain[i].bits = read
+		bits = read
+	}
+	return bits, nil
+}
+
+// Has returns whether the commit named "commit" has a bitmap.
+func (b *BitmapIndex) Has(commit []byte) bool {
+	_, ok := b.commits[string(commit)]
+	return ok
+}
+
+// Reachable returns the set of objects in the packfile reachable from the
+// commit named "commit", including the commit itself. If the commit does not
+// have a bitmap, (nil, errNotFound) is returned (see: IsNotFound).
+func (b *BitmapIndex) Reachable(commit []byte) (*ObjectBitmap, error) {
+	e, ok := b.commits[string(commit)]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	bits, err := b.resolve(e)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectBitmap{idx: b, bits: append(bitmap(nil), bits...)}, nil
+}
+
+// NewObjectBitmap returns a new, empty set of the objects in the packfile.
+func (b *BitmapIndex) NewObjectBitmap() *ObjectBitmap {
+	return &ObjectBitmap{idx: b, bits: newBitmap(b.n)}
+}
+
+// position returns the position in pack order of the object named "name", and
+// whether it is stored in the packfile at all.
+func (b *BitmapIndex) position(name []byte) (int, bool, error) {
+	entry, err := b.p.idx.Entry(name)
+	if err != nil {
+		if IsNotFound(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	n, err := b.rev.Find(int64(entry.PackOffset))
+	if err != nil {
+		return 0, false, err
+	}
+	return n, true, nil
+}
+
+// ObjectBitmap is a set of the objects in a packfile which has a reachability
+// bitmap file (see: BitmapIndex).
+type ObjectBitmap struct {
+	// idx is the bitmap file describing the packfile.
+	idx *BitmapIndex
+	// bits holds the set of objects, in pack order.
+	bits bitmap
+}
+
+// Or adds each object in the given set, which must describe the same
+// packfile, to the receiving set.
+func (o *ObjectBitmap) Or(other *ObjectBitmap) {
+	o.bits.Or(other.bits)
+}
+
+// Add adds the object named "name" to the set, and returns whether it was
+// stored in the packfile (and so could be added).
+func (o *ObjectBitmap) Add(name []byte) (bool, error) {
+	n, ok, err := o.idx.position(name)
+	if err != nil || !ok {
+		return false, err
+	}
+	o.bits.Set(n)
+	return true, nil
+}
+
+// Contains returns whether the object named "name" is in the set. Objects which
+// are not stored in the packfile are never in the set.
+func (o *ObjectBitmap) Contains(name []byte) (bool, error) {
+	n, ok, err := o.idx.position(name)
+	if err != nil || !ok {
+		return false, err
+	}
+	return o.bits.Get(n), nil
+}
+
+// Count returns the number of objects in the set.
+func (o *ObjectBitmap) Count() int {
+	return o.bits.Count()
+}
@@ -0,0 +1,136 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitmapIndexReadsCommitBitmaps(t *testing.T) {
+	p := packWithObjects(t, []packedTestObject{
+		{"cccccccccccccccccccccccccccccccccccccccc", TypeCommit, "commit 1"},
+		{"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", TypeTree, "tree 1"},
+		{"dddddddddddddddddddddddddddddddddddddddd", TypeBlob, "blob 1"},
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", TypeCommit, "commit 2"},
+		{"eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", TypeTag, "tag 1"},
+	})
+
+	a := DecodeHex(t, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	b := DecodeHex(t, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	c := DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc")
+	d := DecodeHex(t, "dddddddddddddddddddddddddddddddddddddddd")
+	e := DecodeHex(t, "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+
+	w, err := NewBitmapWriter(p, sha1.New())
+	require.NoError(t, err)
+	require.NoError(t, w.Add(c, [][]byte{b, d}))
+	require.NoError(t, w.Add(a, [][]byte{c, b, d}))
+
+	var buf bytes.Buffer
+	_, err = w.WriteTo(&buf)
+	require.NoError(t, err)
+
+	idx, err := DecodeBitmapIndex(bytes.NewReader(buf.Bytes()), p)
+	require.NoError(t, err)
+
+	assert.True(t, idx.Has(a))
+	assert.True(t, idx.Has(c))
+	assert.False(t, idx.Has(e))
+
+	_, err = idx.Reachable(e)
+	assert.True(t, IsNotFound(err))
+
+	for _, test := range []struct {
+		commit    []byte
+		reachable [][]byte
+	}{
+		// The bitmap of "a" may be stored relative to that of "c",
+		// in which case resolving it first resolves both.
+		{a, [][]byte{a, b, c, d}},
+		{c, [][]byte{b, c, d}},
+	} {
+		objects, err := idx.Reachable(test.commit)
+		require.NoError(t, err)
+		assert.Equal(t, len(test.reachable), objects.Count())
+
+		for _, name := range test.reachable {
+			ok, err := objects.Contains(name)
+			assert.NoError(t, err)
+			assert.True(t, ok, "%x reaches %x", test.commit, name)
+		}
+		ok, err := objects.Contains(e)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	objects := idx.NewObjectBitmap()
+	assert.Equal(t, 0, objects.Count())
+
+	ok, err := objects.Add(e)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = objects.Add(DecodeHex(t, "ffffffffffffffffffffffffffffffffffffffff"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	reachable, err := idx.Reachable(c)
+	require.NoError(t, err)
+	objects.Or(reachable)
+	assert.Equal(t, 4, objects.Count())
+
+	// Modifying the result does not affect the bitmap of the commit.
+	reachable, err = idx.Reachable(c)
+	require.NoError(t, err)
+	assert.Equal(t, 3, reachable.Count())
+}
+
+func TestDecodeBitmapIndexRejectsOtherPacks(t *testing.T) {
+	p := packWithObjects(t, []packedTestObject{
+		{"cccccccccccccccccccccccccccccccccccccccc", TypeCommit, "commit 1"},
+	})
+
+	w, err := NewBitmapWriter(p, sha1.New())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = w.WriteTo(&buf)
+	require.NoError(t, err)
+
+	data := buf.Bytes()
+	data[12] ^= 0xff
+
+	_, err = DecodeBitmapIndex(bytes.NewReader(data), p)
+	assert.EqualError(t, err, "gitobj/pack: bitmap does not match packfile 0101010101010101010101010101010101010101")
+}
+
+func TestBitmapIndexReadsBitmapsWrittenByGit(t *testing.T) {
+	// The packfile, index, and bitmap were written by "git repack -adb" in
+	// a repository of ten commits, holding 30 objects in all. Git rounds
+	// the number of bits in each bitmap up to a whole word (64).
+	p, err := OpenPackfile("testdata/git-repack-adb.pack", sha1.New())
+	require.NoError(t, err)
+	defer p.Close()
+
+	idx, err := p.BitmapIndex()
+	require.NoError(t, err)
+
+	head := DecodeHex(t, "f6b84d0a9a8302cc9ae0a478e26aae6e3ce48481")
+	require.True(t, idx.Has(head))
+
+	objects, err := idx.Reachable(head)
+	require.NoError(t, err)
+	assert.Equal(t, 30, objects.Count())
+
+	for _, name := range []string{
+		"f6b84d0a9a8302cc9ae0a478e26aae6e3ce48481",
+		"642fdcc18da1e554c945eba8f3b64023b3b7a622",
+		"d00491fd7e5bb6fa28c517a0bb32b8b506539d4d",
+	} {
+		ok, err := objects.Contains(DecodeHex(t, name))
+		assert.NoError(t, err)
+		assert.True(t, ok, name)
+	}
+}
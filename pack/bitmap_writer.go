@@ -0,0 +1,285 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	// bitmapVersion is the version of the bitmap file format written by
+	// the BitmapWriter.
+	bitmapVersion = 1
+
+	// bitmapOptFullDAG indicates that each bitmap in a bitmap file holds
+	// the full closure of the objects reachable from its commit. It is
+	// required by Git.
+	bitmapOptFullDAG = 0x1
+	// bitmapOptHashCache indicates that a bitmap file ends with the name
+	// hash (see: NameHash) of each object in the packfile, in index order.
+	bitmapOptHashCache = 0x4
+
+	// maxBitmapXorOffset is the largest distance (in entries) between a
+	// bitmap and the earlier bitmap against which it may be XOR-ed.
+	maxBitmapXorOffset = 160
+	// bitmapXorSearch is the number of preceding bitmaps considered as XOR
+	// bases when writing a bitmap. It matches the value used by Git.
+	bitmapXorSearch = 10
+)
+
+var (
+	// bitmapHeader is the expected header that begins all bitmap files.
+	bitmapHeader = []byte{'B', 'I', 'T', 'M'}
+)
+
+// BitmapWriter writes reachability bitmap (".bitmap") files for a packfile,
+// allowing the set of objects reachable from a selection of commits to be
+// determined without walking any history.
+//
+// Each bit in a bitmap corresponds to a single object in the packfile, ordered
+// by the object's offset in that packfile.
+type BitmapWriter struct {
+	// p is the packfile for which bitmaps are written.
+	p *Packfile
+	// sum is the hash used to compute the checksum of the bitmap file.
+	sum hash.Hash
+
+	// checksum is the checksum of the packfile "p".
+	checksum []byte
+	// positions maps each object name in the packfile to its position
+	// in pack order.
+	positions map[string]int
+	// indexPositions maps each object name in the packfile to its
+	// position in the packfile's index.
+	indexPositions map[string]int
+	// types holds bitmaps of the commits, trees, blobs, and tags in the
+	// packfile, respectively.
+	types [4]bitmap
+	// nameHashes holds the name hash of each object in the packfile, in
+	// index order, or zero if it is not known.
+	nameHashes []uint32
+
+	// entries holds the bitmaps of each commit added, in order.
+	entries []*bitmapEntry
+}
+
+// bitmapEntry is the reachability bitmap of a single commit.
+type bitmapEntry struct {
+	// indexPos is the position of the commit in the packfile's index.
+	indexPos int
+	// bits holds the set of objects reachable from the commit.
+	bits bitmap
+}
+
+// NewBitmapWriter returns a new *BitmapWriter which writes bitmaps for the
+// given packfile, whose index must be available. "sum" is a new instance of
+// the hash algorithm used by the packfile, and is used to compute the
+// checksum of the bitmap file.
+//
+// Creating a *BitmapWriter reads the type of every object in the packfile,
+// but does not inflate any of them.
+func NewBitmapWriter(p *Packfile, sum hash.Hash) (*BitmapWriter, error) {
+	if p.idx == nil {
+		return nil, fmt.Errorf("gitobj/pack: cannot write bitmap without index")
+	}
+
+	checksum, err := p.idx.PackChecksum()
+	if err != nil {
+		return nil, err
+	}
+
+	type object struct {
+		name   []byte
+		offset int64
+	}
+
+	if p.idx.count() > math.MaxInt32 {
+		return nil, fmt.Errorf("gitobj/pack: too many objects for bitmap: %d", p.idx.count())
+	}
+
+	total := int(p.idx.count())
+	objects := make([]*object, 0, total)
+	indexPositions := make(map[string]int, total)
+
+	for at := 0; at < total; at++ {
+		name, entry, err := p.idx.entryAt(int64(at))
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, &object{
+			name:   name,
+			offset: int64(entry.PackOffset),
+		})
+		indexPositions[string(name)] = at
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].offset < objects[j].offset
+	})
+
+	w := &BitmapWriter{
+		p:   p,
+		sum: sum,
+
+		checksum:       checksum,
+		positions:      make(map[string]int, total),
+		indexPositions: indexPositions,
+		nameHashes:     make([]uint32, total),
+	}
+	for i := range w.types {
+		w.types[i] = newBitmap(total)
+	}
+
+	for pos, o := range objects {
+		w.positions[string(o.name)] = pos
+
+		typ, err := p.typeAt(o.offset)
+		if err != nil {
+			return nil, err
+		}
+		if typ < TypeCommit || typ > TypeTag {
+			return nil, fmt.Errorf("gitobj/pack: unknown object type %d at offset %d", typ, o.offset)
+		}
+		w.types[typ-TypeCommit].Set(pos)
+	}
+	return w, nil
+}
+
+// Add records the set of objects reachable from the commit named "commit",
+// which must include every commit, tree, and blob in its history (including
+// the commit itself).
+//
+// Every object given must be stored in the packfile, otherwise an error is
+// returned and the commit is not added.
+func (w *BitmapWriter) Add(commit []byte, reachable [][]byte) error {
+	at, ok := w.indexPositions[string(commit)]
+	if !ok {
+		return fmt.Errorf("gitobj/pack: commit %x not in pack", commit)
+	}
+	if pos := w.positions[string(commit)]; !w.types[0].Get(pos) {
+		return fmt.Errorf("gitobj/pack: object %x is not a commit", commit)
+	}
+
+	bits := newBitmap(len(w.positions))
+	bits.Set(w.positions[string(commit)])
+
+	for _, name := range reachable {
+		pos, ok := w.positions[string(name)]
+		if !ok {
+			return fmt.Errorf("gitobj/pack: reachable object %x not in pack", name)
+		}
+		bits.Set(pos)
+	}
+
+	w.entries = append(w.entries, &bitmapEntry{
+		indexPos: at,
+		bits:     bits,
+	})
+	return nil
+}
+
+// SetNameHash records the name hash (see: NameHash) of the object named "name",
+// which is written to the bitmap file so that later repacks (by Git) may group
+// objects found at similar paths without walking any trees. A hash of zero
+// indicates that the object's path is not known.
+//
+// It returns an error if the object is not stored in the packfile.
+func (w *BitmapWriter) SetNameHash(name []byte, hash uint32) error {
+	at, ok := w.indexPositions[string(name)]
+	if !ok {
+		return fmt.Errorf("gitobj/pack: object %x not in pack", name)
+	}
+	w.nameHashes[at] = hash
+	return nil
+}
+
+// WriteTo writes the bitmap file, including each commit added so far, to the
+// given io.Writer, and returns the number of bytes written.
+func (w *BitmapWriter) WriteTo(to io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	total := len(w.positions)
+
+	flags := uint16(bitmapOptFullDAG)
+	if w.hasNameHashes() {
+		flags |= bitmapOptHashCache
+	}
+
+	hdr := make([]byte, 12)
+	copy(hdr, bitmapHeader)
+	binary.BigEndian.PutUint16(hdr[4:], bitmapVersion)
+	binary.BigEndian.PutUint16(hdr[6:], flags)
+	binary.BigEndian.PutUint32(hdr[8:], uint32(len(w.entries)))
+
+	buf.Write(hdr)
+	buf.Write(w.checksum)
+
+	for _, b := range w.types {
+		if _, err := writeEWAH(&buf, b, total); err != nil {
+			return 0, err
+		}
+	}
+
+	for i, e := range w.entries {
+		offset, bits := w.xorBase(i)
+
+		var meta [6]byte
+		binary.BigEndian.PutUint32(meta[0:], uint32(e.indexPos))
+		meta[4] = byte(offset)
+		meta[5] = 0
+
+		buf.Write(meta[:])
+		if _, err := writeEWAH(&buf, bits, total); err != nil {
+			return 0, err
+		}
+	}
+
+	if flags&bitmapOptHashCache != 0 {
+		var hash [4]byte
+		for _, h := range w.nameHashes {
+			binary.BigEndian.PutUint32(hash[:], h)
+			buf.Write(hash[:])
+		}
+	}
+
+	w.sum.Reset()
+	w.sum.Write(buf.Bytes())
+	buf.Write(w.sum.Sum(nil))
+
+	return buf.WriteTo(to)
+}
+
+// hasNameHashes returns whether the name hash of any object has been recorded.
+func (w *BitmapWriter) hasNameHashes() bool {
+	for _, h := range w.nameHashes {
+		if h != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// xorBase returns the distance to the preceding entry whose bitmap the entry
+// at position "i" is to be XOR-ed against (or zero if it is to be stored as-is)
+// along with the resulting bitmap to store.
+//
+// The base chosen is the one which results in the smallest compressed bitmap.
+func (w *BitmapWriter) xorBase(i int) (int, bitmap) {
+	n := len(w.positions)
+
+	best, bestBits := 0, w.entries[i].bits
+	bestWords, _ := encodeEWAH(bestBits, n)
+
+	for offset := 1; offset <= bitmapXorSearch && offset <= maxBitmapXorOffset && offset <= i; offset++ {
+		bits := w.entries[i].bits.Xor(w.entries[i-offset].bits)
+		if words, _ := encodeEWAH(bits, n); len(words) < len(bestWords) {
+			best, bestBits, bestWords = offset, bits, words
+		}
+	}
+	return best, bestBits
+}
@@ -0,0 +1,184 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitmapWriterWritesTypeAndCommitBitmaps(t *testing.T) {
+	p := packWithObjects(t, []packedTestObject{
+		{"cccccccccccccccccccccccccccccccccccccccc", TypeCommit, "commit 1"},
+		{"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", TypeTree, "tree 1"},
+		{"dddddddddddddddddddddddddddddddddddddddd", TypeBlob, "blob 1"},
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", TypeCommit, "commit 2"},
+		{"eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", TypeTag, "tag 1"},
+	})
+
+	w, err := NewBitmapWriter(p, sha1.New())
+	require.NoError(t, err)
+
+	require.NoError(t, w.Add(
+		DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"),
+		[][]byte{
+			DecodeHex(t, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+			DecodeHex(t, "dddddddddddddddddddddddddddddddddddddddd"),
+		}))
+	require.NoError(t, w.Add(
+		DecodeHex(t, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		[][]byte{
+			DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"),
+			DecodeHex(t, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+			DecodeHex(t, "dddddddddddddddddddddddddddddddddddddddd"),
+		}))
+
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), n)
+
+	data := buf.Bytes()
+	assert.Equal(t, []byte("BITM"), data[:4])
+	assert.EqualValues(t, 1, binary.BigEndian.Uint16(data[4:]))
+	assert.EqualValues(t, bitmapOptFullDAG, binary.BigEndian.Uint16(data[6:]))
+	assert.EqualValues(t, 2, binary.BigEndian.Uint32(data[8:]))
+	assert.Equal(t, bytes.Repeat([]byte{0x01}, 20), data[12:32])
+
+	sum := sha1.Sum(data[:len(data)-20])
+	assert.Equal(t, sum[:], data[len(data)-20:])
+
+	r := bytes.NewReader(data[:len(data)-20])
+	at := int64(32)
+
+	// Objects are numbered in pack order: c, b, d, a, e.
+	for _, want := range [][]int{{0, 3}, {1}, {2}, {4}} {
+		b, nbits, read, err := readEWAH(r, at)
+		require.NoError(t, err)
+		at += read
+
+		assert.Equal(t, 5, nbits)
+		assert.Equal(t, bitsOf(5, want...), b)
+	}
+
+	// Commit "c" is at index position 2.
+	assert.EqualValues(t, 2, binary.BigEndian.Uint32(data[at:]))
+	assert.EqualValues(t, 0, data[at+4])
+	first, _, read, err := readEWAH(r, at+6)
+	require.NoError(t, err)
+	at += 6 + read
+
+	assert.Equal(t, bitsOf(5, 0, 1, 2), first)
+
+	// Commit "a" is at index position 0, and may be stored relative to
+	// the first bitmap.
+	assert.EqualValues(t, 0, binary.BigEndian.Uint32(data[at:]))
+	second, _, read, err := readEWAH(r, at+6)
+	require.NoError(t, err)
+	at += 6 + read
+
+	if data[at-read-6+4] == 1 {
+		second = second.Xor(first)
+	}
+	assert.Equal(t, bitsOf(5, 0, 1, 2, 3), second)
+	assert.EqualValues(t, len(data)-20, at)
+}
+
+func TestBitmapWriterRejectsObjectsNotInPack(t *testing.T) {
+	p := packWithObjects(t, []packedTestObject{
+		{"cccccccccccccccccccccccccccccccccccccccc", TypeCommit, "commit 1"},
+	})
+
+	w, err := NewBitmapWriter(p, sha1.New())
+	require.NoError(t, err)
+
+	err = w.Add(DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"),
+		[][]byte{DecodeHex(t, "dddddddddddddddddddddddddddddddddddddddd")})
+	assert.EqualError(t, err, "gitobj/pack: reachable object dddddddddddddddddddddddddddddddddddddddd not in pack")
+
+	err = w.Add(DecodeHex(t, "dddddddddddddddddddddddddddddddddddddddd"), nil)
+	assert.EqualError(t, err, "gitobj/pack: commit dddddddddddddddddddddddddddddddddddddddd not in pack")
+}
+
+func TestBitmapWriterRejectsNonCommits(t *testing.T) {
+	p := packWithObjects(t, []packedTestObject{
+		{"dddddddddddddddddddddddddddddddddddddddd", TypeBlob, "blob 1"},
+	})
+
+	w, err := NewBitmapWriter(p, sha1.New())
+	require.NoError(t, err)
+
+	err = w.Add(DecodeHex(t, "dddddddddddddddddddddddddddddddddddddddd"), nil)
+	assert.EqualError(t, err, "gitobj/pack: object dddddddddddddddddddddddddddddddddddddddd is not a commit")
+}
+
+type packedTestObject struct {
+	name string
+	typ  PackedObjectType
+	data string
+}
+
+// packWithObjects returns a *Packfile containing each of the given objects, in
+// order, along with an index whose trailer records a packfile checksum of all
+// 0x01 bytes.
+func packWithObjects(t *testing.T, objects []packedTestObject) *Packfile {
+	pack := make([]byte, 32)
+	offsets := make(map[string]uint32)
+
+	for _, o := range objects {
+		require.True(t, len(o.data) < 16)
+
+		compressed, err := compress(o.data)
+		require.NoError(t, err)
+
+		offsets[o.name] = uint32(len(pack))
+		pack = append(pack, byte(o.typ)<<4|byte(len(o.data)))
+		pack = append(pack, compressed...)
+	}
+
+	idx := IndexWith(offsets)
+
+	r := idx.r.(*bytes.Reader)
+	buf := make([]byte, r.Size())
+	_, err := r.ReadAt(buf, 0)
+	require.NoError(t, err)
+
+	buf = append(buf, bytes.Repeat([]byte{0x01}, 20)...)
+	buf = append(buf, bytes.Repeat([]byte{0x02}, 20)...)
+	idx.r = bytes.NewReader(buf)
+
+	return &Packfile{
+		idx:  idx,
+		r:    bytes.NewReader(pack),
+		hash: sha1.New(),
+	}
+}
+
+func bitsOf(n int, set ...int) bitmap {
+	b := newBitmap(n)
+	for _, i := range set {
+		b.Set(i)
+	}
+	return b
+}
+
+func TestBitmapWriterWritesEmptyPacks(t *testing.T) {
+	p, err := DecodePackfile(bytes.NewReader(emptyPack()), sha1.New())
+	require.NoError(t, err)
+	p.idx, err = DecodeIndex(bytes.NewReader(emptyIndex()), sha1.New())
+	require.NoError(t, err)
+
+	w, err := NewBitmapWriter(p, sha1.New())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = w.WriteTo(&buf)
+	require.NoError(t, err)
+
+	pack := emptyPack()
+	assert.Equal(t, pack[len(pack)-sha1.Size:], buf.Bytes()[12:32])
+	assert.EqualValues(t, 0, binary.BigEndian.Uint32(buf.Bytes()[8:]))
+}
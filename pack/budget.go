@@ -0,0 +1,149 @@
+package pack
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Budget limits the memory allocated to hold the contents of objects as they
+// are unpacked, for instance to bound the memory used on behalf of each of the
+// tenants of a server. A Budget is carried by the context given when reading
+// objects (see: WithBudget), and memory is reserved from it before the contents
+// of each delta-base chain element, and of each delta applied to it, are
+// allocated. If a reservation would exceed the budget's limit, unpacking stops
+// and a *BudgetExceededErr is returned.
+//
+// Memory is released once it is no longer held: the contents of intermediate
+// chain elements once the next delta has been applied, and those of the object
+// itself once the reader over them is closed (see: Object.ReaderContext), or
+// once they are returned (see: Object.UnpackContext). Memory held by the delta
+// base cache is bounded separately (see: DeltaBaseCache).
+//
+// A nil *Budget is unlimited. A *Budget is safe for concurrent use.
+type Budget struct {
+	// limit is the number of bytes which may be reserved at once.
+	limit int64
+	// parent is the budget from which reservations are also made, or nil
+	// if there is none (see: Child).
+	parent *Budget
+
+	// mu guards "used".
+	mu sync.Mutex
+	// used is the number of bytes currently reserved.
+	used int64
+}
+
+// NewBudget returns a new *Budget allowing at most "limit" bytes to be reserved
+// at once.
+func NewBudget(limit int64) *Budget {
+	return &Budget{limit: limit}
+}
+
+// Child returns a new *Budget allowing at most "limit" bytes to be reserved at
+// once, each reservation from which is also made from the receiving budget.
+// It may be used to give each operation a budget of its own, bounded in total
+// by that of the database which they share.
+func (b *Budget) Child(limit int64) *Budget {
+	return &Budget{limit: limit, parent: b}
+}
+
+// Limit returns the number of bytes which may be reserved from the budget at
+// once, or -1 if it is unlimited.
+func (b *Budget) Limit() int64 {
+	if b == nil {
+		return -1
+	}
+	return b.limit
+}
+
+// Used returns the number of bytes currently reserved from the budget.
+func (b *Budget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.used
+}
+
+// Reserve reserves "n" bytes from the budget, and from its parent, if it has
+// one, or returns a *BudgetExceededErr, reserving nothing, if doing so would
+// exceed the limit of either.
+func (b *Budget) Reserve(n int64) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.used+n > b.limit {
+		err := &BudgetExceededErr{Limit: b.limit, Used: b.used, Requested: n}
+		b.mu.Unlock()
+		return err
+	}
+	b.used += n
+	b.mu.Unlock()
+
+	if err := b.parent.Reserve(n); err != nil {
+		b.release(n)
+		return err
+	}
+	return nil
+}
+
+// Release returns "n" previously reserved bytes to the budget, and to its
+// parent, if it has one.
+func (b *Budget) Release(n int64) {
+	for ; b != nil && n > 0; b = b.parent {
+		b.release(n)
+	}
+}
+
+// release returns "n" bytes to the budget, but not to its parent.
+func (b *Budget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used -= n
+	if b.used < 0 {
+		b.used = 0
+	}
+}
+
+// budgetKey is the key under which a *Budget is stored in a context.
+type budgetKey struct{}
+
+// WithBudget returns a copy of the given context carrying the given budget,
+// from which memory is reserved as objects are read using it.
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// BudgetFromContext returns the budget carried by the given context, or nil if
+// it carries none.
+func BudgetFromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// budgetedReader is an io.ReadCloser over the contents of an unpacked object,
+// which releases the memory reserved to hold them once it is closed.
+type budgetedReader struct {
+	io.Reader
+
+	// budget is the budget from which "n" bytes were reserved.
+	budget *Budget
+	n      int64
+	// once guards releasing the reservation.
+	once sync.Once
+}
+
+// Close implements io.Closer.
+func (r *budgetedReader) Close() error {
+	r.once.Do(func() {
+		r.budget.Release(r.n)
+	})
+	return nil
+}
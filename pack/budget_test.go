@@ -0,0 +1,115 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetReserve(t *testing.T) {
+	parent := NewBudget(10)
+	child := parent.Child(6)
+
+	require.NoError(t, child.Reserve(4))
+	assert.EqualValues(t, 4, child.Used())
+	assert.EqualValues(t, 4, parent.Used())
+
+	err := child.Reserve(3)
+	require.IsType(t, &BudgetExceededErr{}, err)
+	assert.Equal(t, &BudgetExceededErr{Limit: 6, Used: 4, Requested: 3}, err)
+	assert.EqualError(t, err, "gitobj/pack: memory budget exceeded: 3 byte(s) requested, 4 of 6 in use")
+
+	require.NoError(t, parent.Reserve(5))
+
+	// The parent's limit is exceeded, so nothing is reserved from the
+	// child either.
+	err = child.Reserve(2)
+	assert.Equal(t, &BudgetExceededErr{Limit: 10, Used: 9, Requested: 2}, err)
+	assert.EqualValues(t, 4, child.Used())
+
+	child.Release(4)
+	assert.EqualValues(t, 0, child.Used())
+	assert.EqualValues(t, 5, parent.Used())
+}
+
+func TestNilBudgetIsUnlimited(t *testing.T) {
+	var b *Budget
+
+	assert.NoError(t, b.Reserve(1<<40))
+	assert.EqualValues(t, -1, b.Limit())
+	assert.EqualValues(t, 0, b.Used())
+	b.Release(1 << 40)
+
+	assert.Nil(t, BudgetFromContext(context.Background()))
+}
+
+// newTestBudgetObject returns an *Object holding "Hello!\n" as a delta over a
+// base holding "Hello". Resolving it requires 20 bytes at most: 8 for the
+// delta instructions, 5 for the base, and 7 for the result.
+func newTestBudgetObject(t *testing.T) *Object {
+	compressed, err := compress("Hello")
+	require.NoError(t, err)
+
+	return &Object{
+		data: &ChainDelta{
+			base: &ChainBase{
+				size: 5,
+				typ:  TypeBlob,
+
+				r: bytes.NewReader(compressed),
+			},
+			delta: []byte{
+				0x05, // Source size: 5.
+				0x07, // Destination size: 7.
+
+				0x91, // (1001 0001) (copy, smask=0001, omask=0001)
+				0x00, // (0000 0000) (offset=0)
+				0x05, // (0000 0101) (size=5)
+
+				0x02, // (0000 0010) (add, length=2)
+				'!', '\n',
+			},
+		},
+		typ: TypeBlob,
+	}
+}
+
+func TestObjectUnpackContextWithinBudget(t *testing.T) {
+	b := NewBudget(20)
+
+	data, err := newTestBudgetObject(t).UnpackContext(WithBudget(context.Background(), b))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello!\n", string(data))
+	assert.EqualValues(t, 0, b.Used())
+}
+
+func TestObjectUnpackContextExceedingBudget(t *testing.T) {
+	b := NewBudget(19)
+
+	data, err := newTestBudgetObject(t).UnpackContext(WithBudget(context.Background(), b))
+	assert.Nil(t, data)
+	assert.Equal(t, &BudgetExceededErr{Limit: 19, Used: 13, Requested: 7}, err)
+	assert.EqualValues(t, 0, b.Used())
+}
+
+func TestObjectReaderContextHoldsBudgetUntilClosed(t *testing.T) {
+	b := NewBudget(20)
+
+	r, size, err := newTestBudgetObject(t).ReaderContext(WithBudget(context.Background(), b))
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, size)
+	assert.EqualValues(t, 7, b.Used())
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello!\n", string(data))
+
+	require.NoError(t, r.Close())
+	assert.EqualValues(t, 0, b.Used())
+	require.NoError(t, r.Close())
+	assert.EqualValues(t, 0, b.Used())
+}
@@ -19,3 +19,36 @@ type Chain interface {
 	// Type returns the type of the receiving chain element.
 	Type() PackedObjectType
 }
+
+// chainLocation is the location of a delta-base chain element: the packfile
+// holding it, and the offset of its header.
+type chainLocation struct {
+	p      *Packfile
+	offset int64
+}
+
+// cache adds the given unpacked contents of the chain element at this location
+// to its packfile's delta base cache, if it has one.
+func (l chainLocation) cache(typ PackedObjectType, data []byte) {
+	if l.p == nil || l.p.cache == nil {
+		return
+	}
+	l.p.cache.add(l, typ, data)
+}
+
+// cachedChain is a delta-base chain element whose unpacked contents were found
+// in a *DeltaBaseCache.
+type cachedChain struct {
+	typ  PackedObjectType
+	data []byte
+}
+
+// Unpack implements Chain.Unpack by returning a copy of the cached contents.
+func (c *cachedChain) Unpack() ([]byte, error) {
+	return append([]byte(nil), c.data...), nil
+}
+
+// Type implements Chain.Type.
+func (c *cachedChain) Type() PackedObjectType {
+	return c.typ
+}
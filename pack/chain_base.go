@@ -1,10 +1,16 @@
 package pack
 
 import (
-	"compress/zlib"
+	"context"
 	"io"
 )
 
+const (
+	// chainBaseChunkSize is the number of bytes inflated at a time by
+	// unpack, between checks of whether its context is done.
+	chainBaseChunkSize = 32 * 1024
+)
+
 // ChainBase represents the "base" component of a delta-base chain.
 type ChainBase struct {
 	// offset returns the offset into the given io.ReaderAt where the read
@@ -17,6 +23,11 @@ type ChainBase struct {
 
 	// r is the io.ReaderAt yielding a stream of zlib-compressed data.
 	r io.ReaderAt
+	// unpooled indicates whether a new zlib reader is allocated to inflate
+	// the data, rather than one being drawn from a pool.
+	unpooled bool
+	// loc is the location at which the base is packed.
+	loc chainLocation
 }
 
 // Unpack inflates and returns the uncompressed data encoded in the base
@@ -25,10 +36,20 @@ type ChainBase struct {
 // If there was any error in reading the compressed data (invalid headers,
 // etc.), it will be returned immediately.
 func (b *ChainBase) Unpack() ([]byte, error) {
-	zr, err := zlib.NewReader(&OffsetReaderAt{
+	return b.unpack(context.Background())
+}
+
+// unpack is as Unpack, but stops inflating data, and returns the context's
+// error, once the given context is done.
+func (b *ChainBase) unpack(ctx context.Context) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	zr, err := newZlibReader(&OffsetReaderAt{
 		r: b.r,
 		o: b.offset,
-	})
+	}, b.unpooled)
 
 	if err != nil {
 		return nil, err
@@ -37,8 +58,18 @@ func (b *ChainBase) Unpack() ([]byte, error) {
 	defer zr.Close()
 
 	buf := make([]byte, b.size)
-	if _, err := io.ReadFull(zr, buf); err != nil {
-		return nil, err
+	for n := int64(0); n < b.size; n += chainBaseChunkSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		end := n + chainBaseChunkSize
+		if end > b.size {
+			end = b.size
+		}
+		if _, err := io.ReadFull(zr, buf[n:end]); err != nil {
+			return nil, err
+		}
 	}
 	return buf, nil
 }
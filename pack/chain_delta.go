@@ -1,6 +1,10 @@
 package pack
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"math/bits"
+)
 
 // ChainDelta represents a "delta" component of a delta-base chain.
 type ChainDelta struct {
@@ -12,19 +16,20 @@ type ChainDelta struct {
 	// delta is the set of copy/add instructions to apply on top of the
 	// base.
 	delta []byte
+	// loc is the location at which the delta is packed.
+	loc chainLocation
 }
 
+// errInvalidDelta is returned when the instructions of a delta are malformed,
+// or cannot be applied to its base.
+var errInvalidDelta = fmt.Errorf("gitobj/pack: invalid delta data")
+
 // Unpack applies the delta operation to the previous delta-base chain, "base".
 //
 // If any of the delta-base instructions were invalid, an error will be
 // returned.
 func (d *ChainDelta) Unpack() ([]byte, error) {
-	base, err := d.base.Unpack()
-	if err != nil {
-		return nil, err
-	}
-
-	return patch(base, d.delta)
+	return unpackChain(context.Background(), d)
 }
 
 // Type returns the type of the base of the delta-base chain.
@@ -40,20 +45,32 @@ func (d *ChainDelta) Type() PackedObjectType {
 // applied to the given base, an error will returned, along with an empty set of
 // data.
 func patch(base, delta []byte) ([]byte, error) {
-	srcSize, pos := patchDeltaHeader(delta, 0)
+	srcSize, pos, err := patchDeltaHeader(delta, 0)
+	if err != nil {
+		return nil, err
+	}
 	if srcSize != int64(len(base)) {
 		// The header of the delta gives the size of the source contents
 		// that it is a patch over.
 		//
 		// If this does not match with the srcSize, return an error
 		// early so as to avoid a possible bounds error below.
-		return nil, fmt.Errorf("gitobj/pack: invalid delta data")
+		return nil, errInvalidDelta
 	}
 
 	// The remainder of the delta header contains the destination size, and
 	// moves the "pos" offset to the correct position to begin the set of
 	// delta instructions.
-	destSize, pos := patchDeltaHeader(delta, pos)
+	destSize, pos, err := patchDeltaHeader(delta, pos)
+	if err != nil {
+		return nil, err
+	}
+	// No instruction yields more than 0xffffff bytes, so a destination
+	// size greater than that for each byte of instructions cannot be met,
+	// and is not allocated.
+	if destSize > int64(len(delta)-pos)*0xffffff {
+		return nil, errInvalidDelta
+	}
 
 	dest := make([]byte, 0, destSize)
 
@@ -68,6 +85,13 @@ func patch(base, delta []byte) ([]byte, error) {
 			// for the copy offset and size instructions.
 			pos -= 1
 
+			// The copy offset and size are encoded in as many
+			// bytes as there are bits set in the lower seven of
+			// "c".
+			if pos+bits.OnesCount8(uint8(c&0x7f)) >= len(delta) {
+				return nil, errInvalidDelta
+			}
+
 			var co, cs int
 
 			// The lower-half of "c" (0000 1111) defines a "bitmask"
@@ -112,6 +136,10 @@ func patch(base, delta []byte) ([]byte, error) {
 			}
 			pos += 1
 
+			if co < 0 || co+cs > len(base) || len(dest)+cs > cap(dest) {
+				return nil, errInvalidDelta
+			}
+
 			// Once we have the copy offset and length defined, copy
 			// that number of bytes from the base into the
 			// destination. Since we are copying from the base and
@@ -126,6 +154,9 @@ func patch(base, delta []byte) ([]byte, error) {
 			//
 			// Copy the bytes and increment the read pointer
 			// forward.
+			if pos+c > len(delta) || len(dest)+c > cap(dest) {
+				return nil, errInvalidDelta
+			}
 			dest = append(dest, delta[pos:int(pos)+c]...)
 
 			pos += int(c)
@@ -134,8 +165,7 @@ func patch(base, delta []byte) ([]byte, error) {
 			// instruction.
 			//
 			// Return immediately.
-			return nil, fmt.Errorf(
-				"gitobj/pack: invalid delta data")
+			return nil, errInvalidDelta
 		}
 	}
 
@@ -145,21 +175,22 @@ func patch(base, delta []byte) ([]byte, error) {
 		// an invalid set of patch instructions.
 		//
 		// Return immediately.
-		return nil, fmt.Errorf("gitobj/pack: invalid delta data")
+		return nil, errInvalidDelta
 	}
 	return dest, nil
 }
 
 // patchDeltaHeader examines the header within delta at the given offset, and
 // returns the size encoded within it, as well as the ending offset where begins
-// the next header, or the patch instructions.
-func patchDeltaHeader(delta []byte, pos int) (size int64, end int) {
+// the next header, or the patch instructions. If the header is truncated, or
+// gives a size too large to hold, errInvalidDelta is returned.
+func patchDeltaHeader(delta []byte, pos int) (size int64, end int, err error) {
 	var shift uint
 	var c int64
 
 	for shift == 0 || c&0x80 != 0 {
-		if len(delta) <= pos {
-			panic("gitobj/pack: invalid delta header")
+		if len(delta) <= pos || shift > 56 {
+			return 0, pos, errInvalidDelta
 		}
 
 		c = int64(delta[pos])
@@ -169,5 +200,5 @@ func patchDeltaHeader(delta []byte, pos int) (size int64, end int) {
 		shift += 7
 	}
 
-	return size, pos
+	return size, pos, nil
 }
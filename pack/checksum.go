@@ -0,0 +1,213 @@
+package pack
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// Checksum returns the trailing checksum of the packfile, which identifies it,
+// and by which it is conventionally named ("pack-<checksum>.pack"). Unlike its
+// name, the checksum cannot be changed without rewriting the packfile, and so
+// may be used to refer to the packfile stably, for instance between processes.
+func (p *Packfile) Checksum() ([]byte, error) {
+	size, ok := readerSize(p.r)
+	if !ok {
+		return nil, fmt.Errorf("gitobj/pack: cannot determine size of packfile")
+	}
+
+	hashlen := int64(p.hash.Size())
+	if size < 12+hashlen {
+		return nil, fmt.Errorf("gitobj/pack: packfile too short to hold checksum")
+	}
+
+	sum := make([]byte, hashlen)
+	if _, err := p.r.ReadAt(sum, size-hashlen); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// VerifyIndex checks that the packfile's checksum matches that recorded in its
+// index, and returns a *ChecksumMismatchErr if it does not, as when either has
+// been replaced (or truncated) without the other. As in Git, only the
+// checksums are compared; the contents of the packfile are not re-hashed.
+func (p *Packfile) VerifyIndex() error {
+	if p.idx == nil {
+		return fmt.Errorf("gitobj/pack: cannot verify packfile without index")
+	}
+
+	sum, err := p.Checksum()
+	if err != nil {
+		return err
+	}
+	expected, err := p.idx.PackChecksum()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(sum, expected) {
+		return &ChecksumMismatchErr{Name: p.path, Pack: sum, Index: expected}
+	}
+	return nil
+}
+
+// VerifyChecksums re-hashes the contents of the packfile and of its index, and
+// returns a *CorruptPackErr or *CorruptIndexErr if either does not match its own
+// trailing checksum, or a *ChecksumMismatchErr if they do not match each other
+// (see: VerifyIndex). Unlike VerifyIndex, it reads both files in full.
+func (p *Packfile) VerifyChecksums() error {
+	if p.idx == nil {
+		return fmt.Errorf("gitobj/pack: cannot verify packfile without index")
+	}
+
+	size, ok := readerSize(p.r)
+	if !ok {
+		return fmt.Errorf("gitobj/pack: cannot determine size of packfile")
+	}
+	expected, err := p.Checksum()
+	if err != nil {
+		return err
+	}
+
+	sum := newHash(p.hash)
+	end := size - int64(len(expected))
+	if _, err := io.Copy(sum, io.NewSectionReader(p.r, 0, end)); err != nil {
+		return err
+	}
+	if got := sum.Sum(nil); !bytes.Equal(got, expected) {
+		return &CorruptPackErr{
+			Name:   p.path,
+			Offset: end,
+			Reason: fmt.Sprintf("checksum %x, expected %x", got, expected),
+		}
+	}
+
+	if err := p.idx.verifyChecksum(sum, p.indexPath()); err != nil {
+		return err
+	}
+	return p.VerifyIndex()
+}
+
+// verifyChecksum re-hashes the contents of the index using "sum", and returns a
+// *CorruptIndexErr naming it "name" if they do not match its trailing checksum.
+func (i *Index) verifyChecksum(sum hash.Hash, name string) error {
+	expected, err := i.Checksum()
+	if err != nil {
+		return err
+	}
+	at, err := i.trailerOffset()
+	if err != nil {
+		return err
+	}
+
+	// The checksum covers everything before it, including the copy of
+	// the packfile's checksum which begins the trailer.
+	sum.Reset()
+	if _, err := io.Copy(sum, io.NewSectionReader(i.r, 0, at+int64(len(expected)))); err != nil {
+		return err
+	}
+	if got := sum.Sum(nil); !bytes.Equal(got, expected) {
+		return &CorruptIndexErr{
+			Name:   name,
+			Reason: fmt.Sprintf("checksum %x, expected %x", got, expected),
+		}
+	}
+	return nil
+}
+
+// indexPath returns the path of the packfile's index, if the packfile was
+// opened from disk, or the empty string otherwise.
+func (p *Packfile) indexPath() string {
+	if len(p.path) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(p.path, ".pack") + ".idx"
+}
+
+// verifyOnce verifies the trailing checksums of the packfile and its index
+// (see: VerifyChecksums) the first time that it is called, and returns the
+// result of doing so each time.
+func (p *Packfile) verifyOnce() error {
+	p.verified.Do(func() {
+		p.verifyErr = p.VerifyChecksums()
+	})
+	return p.verifyErr
+}
+
+// verifyEntry checks the CRC32 of the packed data of the entry beginning at
+// "offset" against that recorded in the packfile's index, and returns a
+// *CorruptPackErr if they differ, or if no entry begins at that offset.
+func (p *Packfile) verifyEntry(offset int64) error {
+	if err := p.verifyOnce(); err != nil {
+		return err
+	}
+
+	if _, ok := p.idx.version.(*V2); !ok {
+		// Only version 2 indexes record the CRC32 of each entry.
+		return nil
+	}
+
+	rev, n, end, err := p.entryExtent(offset)
+	if err != nil {
+		return err
+	}
+
+	expected, _, err := p.idx.crcAt(int64(rev.positions[n]))
+	if err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	if _, err := io.Copy(crc, io.NewSectionReader(p.r, offset, end-offset)); err != nil {
+		return err
+	}
+	if got := crc.Sum32(); got != expected {
+		return &CorruptPackErr{
+			Name:   p.path,
+			Offset: offset,
+			Reason: fmt.Sprintf("CRC32 %08x, index expects %08x", got, expected),
+		}
+	}
+	return nil
+}
+
+// entryExtent returns the reverse index of the packfile, along with the position
+// in it of the entry beginning at "offset", and the offset at which that entry
+// ends. If no entry begins at that offset, a *CorruptPackErr is returned.
+func (p *Packfile) entryExtent(offset int64) (*ReverseIndex, int, int64, error) {
+	rev, err := p.ReverseIndex()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	n, err := rev.Find(offset)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, 0, 0, &CorruptPackErr{
+				Name:   p.path,
+				Offset: offset,
+				Reason: "no object begins at offset",
+			}
+		}
+		return nil, 0, 0, err
+	}
+
+	// The entry ends where the next begins, or where the packfile's
+	// trailing checksum begins.
+	var end int64
+	if n+1 < rev.Count() {
+		if end, err = rev.offset(n + 1); err != nil {
+			return nil, 0, 0, err
+		}
+	} else {
+		size, ok := readerSize(p.r)
+		if !ok {
+			return nil, 0, 0, fmt.Errorf("gitobj/pack: cannot determine size of packfile")
+		}
+		end = size - int64(p.idx.hashSize())
+	}
+	return rev, n, end, nil
+}
@@ -0,0 +1,204 @@
+package pack
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackfileChecksumMatchesName(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	idx, _ := writeTestPack(t, pd, "Hello, world!\n")
+	path := filepath.Join(pd, strings.TrimSuffix(idx, ".idx")+".pack")
+
+	p, err := OpenPackfile(path, sha1.New())
+	require.NoError(t, err)
+	defer p.Close()
+
+	sum, err := p.Checksum()
+	require.NoError(t, err)
+	assert.Equal(t, strings.TrimSuffix(strings.TrimPrefix(idx, "pack-"), ".idx"),
+		hex.EncodeToString(sum))
+
+	expected, err := p.idx.PackChecksum()
+	require.NoError(t, err)
+	assert.Equal(t, sum, expected)
+
+	idxSum, err := p.idx.Checksum()
+	require.NoError(t, err)
+	assert.Len(t, idxSum, 20)
+	assert.NotEqual(t, sum, idxSum)
+
+	assert.NoError(t, p.VerifyIndex())
+}
+
+func TestPackfileVerifyIndexDetectsMismatchedPairs(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	a, _ := writeTestPack(t, pd, "a\n")
+	b, _ := writeTestPack(t, pd, "b\n")
+
+	// Replace the index of "a" with that of "b".
+	data, err := ioutil.ReadFile(filepath.Join(pd, b))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, a), data, 0644))
+
+	path := filepath.Join(pd, strings.TrimSuffix(a, ".idx")+".pack")
+	p, err := OpenPackfile(path, sha1.New())
+	require.NoError(t, err)
+	defer p.Close()
+
+	err = p.VerifyIndex()
+	require.IsType(t, &ChecksumMismatchErr{}, err)
+	assert.Equal(t, path, err.(*ChecksumMismatchErr).Name)
+	assert.Equal(t, strings.TrimSuffix(strings.TrimPrefix(b, "pack-"), ".idx"),
+		hex.EncodeToString(err.(*ChecksumMismatchErr).Index))
+}
+
+func TestSetPacks(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(pd, "pack"), 0755))
+	writeTestPack(t, filepath.Join(pd, "pack"), "a\n")
+	writeTestPack(t, filepath.Join(pd, "pack"), "b\n")
+
+	s, err := NewSet(pd, sha1.New())
+	require.NoError(t, err)
+	defer s.Close()
+
+	packs := s.Packs()
+	assert.Len(t, packs, 2)
+	for _, p := range packs {
+		assert.NoError(t, p.VerifyIndex())
+	}
+}
+
+func TestPackfileVerifyChecksums(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	idx, _ := writeTestPack(t, pd, "Hello, world!\n")
+	path := filepath.Join(pd, strings.TrimSuffix(idx, ".idx")+".pack")
+
+	p, err := OpenPackfile(path, sha1.New())
+	require.NoError(t, err)
+	assert.NoError(t, p.VerifyChecksums())
+	require.NoError(t, p.Close())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-sha1.Size-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	p, err = OpenPackfile(path, sha1.New())
+	require.NoError(t, err)
+	defer p.Close()
+
+	err = p.VerifyChecksums()
+	require.IsType(t, &CorruptPackErr{}, err)
+	assert.Equal(t, path, err.(*CorruptPackErr).Name)
+	assert.EqualValues(t, len(data)-sha1.Size, err.(*CorruptPackErr).Offset)
+}
+
+func TestSetEnableVerificationDetectsCorruptEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	idx, name := writeTestPack(t, pd, "Hello, world!\n")
+	path := filepath.Join(pd, strings.TrimSuffix(idx, ".idx")+".pack")
+
+	// Damage the only entry in the packfile, but update the checksums of
+	// the packfile and its index to match, so that only the CRC32 of the
+	// entry can detect it.
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-sha1.Size-1] ^= 0xff
+	sum := sha1.Sum(data[:len(data)-sha1.Size])
+	copy(data[len(data)-sha1.Size:], sum[:])
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	index, err := ioutil.ReadFile(filepath.Join(pd, idx))
+	require.NoError(t, err)
+	copy(index[len(index)-2*sha1.Size:], sum[:])
+	sum = sha1.Sum(index[:len(index)-sha1.Size])
+	copy(index[len(index)-sha1.Size:], sum[:])
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, idx), index, 0644))
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	for _, p := range set.Packs() {
+		assert.NoError(t, p.VerifyChecksums())
+	}
+
+	o, err := set.Object(name)
+	require.NoError(t, err)
+	assert.Equal(t, TypeBlob, o.Type())
+
+	set.EnableVerification()
+
+	o, err = set.Object(name)
+	assert.Nil(t, o)
+	require.IsType(t, &CorruptPackErr{}, err)
+	assert.Equal(t, path, err.(*CorruptPackErr).Name)
+	assert.EqualValues(t, 12, err.(*CorruptPackErr).Offset)
+	assert.Contains(t, err.Error(), "CRC32")
+}
+
+func TestSetEnableVerificationDetectsCorruptPacks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	_, good := writeTestPack(t, pd, "a\n")
+	idx, bad := writeTestPack(t, pd, "b\n")
+	path := filepath.Join(pd, strings.TrimSuffix(idx, ".idx")+".pack")
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-sha1.Size-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	set.EnableVerification()
+
+	o, err := set.Object(good)
+	require.NoError(t, err)
+	unpacked, err := o.Unpack()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a\n"), unpacked)
+
+	_, _, err = set.ObjectInfo(bad)
+	require.IsType(t, &CorruptPackErr{}, err)
+	assert.Equal(t, path, err.(*CorruptPackErr).Name)
+	assert.EqualValues(t, len(data)-sha1.Size, err.(*CorruptPackErr).Offset)
+
+	_, err = set.Object(bad)
+	assert.IsType(t, &CorruptPackErr{}, err)
+}
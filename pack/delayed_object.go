@@ -1,7 +1,7 @@
 package pack
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -9,33 +9,46 @@ import (
 
 // delayedObjectReader provides an interface for reading from an Object while
 // loading object data into memory only on demand.  It implements io.ReadCloser.
+//
+// Objects which are stored as bases (i.e., which are not deltified) are
+// inflated as they are read (see: Object.Reader), rather than being loaded into
+// memory at all.
 type delayedObjectReader struct {
 	obj *Object
+	ctx context.Context
 	mr  io.Reader
+	// r is the reader over the object's contents, or nil if it has not
+	// yet been opened.
+	r io.ReadCloser
 }
 
 // Read implements the io.Reader method by instantiating a new underlying reader
 // only on demand.
 func (d *delayedObjectReader) Read(b []byte) (int, error) {
 	if d.mr == nil {
-		data, err := d.obj.Unpack()
+		r, size, err := d.obj.ReaderContext(d.ctx)
 		if err != nil {
 			return 0, err
 		}
+		d.r = r
 		d.mr = io.MultiReader(
 			// Git object header:
 			strings.NewReader(fmt.Sprintf("%s %d\x00",
-				d.obj.Type(), len(data),
+				d.obj.Type(), size,
 			)),
 
 			// Git object (uncompressed) contents:
-			bytes.NewReader(data),
+			r,
 		)
 	}
 	return d.mr.Read(b)
 }
 
-// Close implements the io.Closer interface.
+// Close implements the io.Closer interface, closing the reader over the
+// object's contents if it was opened.
 func (d *delayedObjectReader) Close() error {
-	return nil
+	if d.r == nil {
+		return nil
+	}
+	return d.r.Close()
 }
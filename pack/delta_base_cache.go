@@ -0,0 +1,145 @@
+package pack
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	// DefaultDeltaBaseCacheLimit is the default number of bytes of
+	// resolved delta bases held by a *Set's cache, as with Git's
+	// "core.deltaBaseCacheLimit".
+	DefaultDeltaBaseCacheLimit = 96 * 1024 * 1024
+)
+
+// DeltaBaseCache is a least-recently-used cache of the unpacked contents of
+// objects which have been used as the bases of deltas, similar to Git's
+// "delta_base_cache". It allows objects whose delta-base chains share a common
+// prefix (as nearby objects often do) to be resolved without re-inflating and
+// re-applying that prefix each time.
+//
+// A *DeltaBaseCache may be shared between many packfiles, and is safe for
+// concurrent use.
+type DeltaBaseCache struct {
+	// limit is the maximum number of bytes of data held by the cache.
+	limit int64
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// size is the number of bytes of data currently held by the cache.
+	size int64
+	// lru holds a *deltaBaseEntry for each cached base, with the most
+	// recently used at the front.
+	lru *list.List
+	// entries maps the location of each cached base to its element in
+	// "lru".
+	entries map[chainLocation]*list.Element
+	// hits and misses are the number of lookups of objects which were, and
+	// were not, present in the cache.
+	hits, misses uint64
+}
+
+// deltaBaseEntry is a single delta base held by a *DeltaBaseCache.
+type deltaBaseEntry struct {
+	loc  chainLocation
+	typ  PackedObjectType
+	data []byte
+}
+
+// NewDeltaBaseCache returns a new *DeltaBaseCache holding at most "limit" bytes
+// of data.
+func NewDeltaBaseCache(limit int64) *DeltaBaseCache {
+	return &DeltaBaseCache{
+		limit:   limit,
+		lru:     list.New(),
+		entries: make(map[chainLocation]*list.Element),
+	}
+}
+
+// Len returns the number of delta bases held by the cache.
+func (c *DeltaBaseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Size returns the number of bytes of data held by the cache.
+func (c *DeltaBaseCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+// Hits returns the number of times an object (or delta base) being read was
+// found in the cache, rather than being unpacked.
+func (c *DeltaBaseCache) Hits() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits
+}
+
+// Misses returns the number of times an object (or delta base) being read was
+// not found in the cache, and so was unpacked.
+func (c *DeltaBaseCache) Misses() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.misses
+}
+
+// get returns the type and contents of the object packed at the given location,
+// and whether it was present in the cache. The contents are shared with the
+// cache, and must not be modified.
+func (c *DeltaBaseCache) get(loc chainLocation) (PackedObjectType, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[loc]
+	if !ok {
+		c.misses++
+		return TypeNone, nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(e)
+
+	entry := e.Value.(*deltaBaseEntry)
+	return entry.typ, entry.data, true
+}
+
+// add adds the contents of the object packed at the given location to the
+// cache, evicting the least recently used bases as necessary to remain within
+// its limit. Objects larger than the limit are not cached. The cache takes
+// ownership of "data", which must not be modified afterwards.
+func (c *DeltaBaseCache) add(loc chainLocation, typ PackedObjectType, data []byte) {
+	size := int64(len(data))
+	if size > c.limit {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[loc]; ok {
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	for c.size+size > c.limit {
+		oldest := c.lru.Back()
+		entry := oldest.Value.(*deltaBaseEntry)
+
+		c.lru.Remove(oldest)
+		delete(c.entries, entry.loc)
+		c.size -= int64(len(entry.data))
+	}
+
+	c.entries[loc] = c.lru.PushFront(&deltaBaseEntry{
+		loc:  loc,
+		typ:  typ,
+		data: data,
+	})
+	c.size += size
+}
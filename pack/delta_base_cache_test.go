@@ -0,0 +1,93 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaBaseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	p := &Packfile{}
+	c := NewDeltaBaseCache(8)
+
+	c.add(chainLocation{p: p, offset: 1}, TypeBlob, []byte("aaaa"))
+	c.add(chainLocation{p: p, offset: 2}, TypeBlob, []byte("bbbb"))
+
+	// Use the first base, so that the second is evicted in favor of the
+	// third.
+	_, _, ok := c.get(chainLocation{p: p, offset: 1})
+	assert.True(t, ok)
+
+	c.add(chainLocation{p: p, offset: 3}, TypeTree, []byte("cccc"))
+
+	assert.Equal(t, 2, c.Len())
+	assert.EqualValues(t, 8, c.Size())
+
+	typ, data, ok := c.get(chainLocation{p: p, offset: 1})
+	assert.True(t, ok)
+	assert.Equal(t, TypeBlob, typ)
+	assert.Equal(t, []byte("aaaa"), data)
+
+	_, _, ok = c.get(chainLocation{p: p, offset: 2})
+	assert.False(t, ok)
+
+	typ, data, ok = c.get(chainLocation{p: p, offset: 3})
+	assert.True(t, ok)
+	assert.Equal(t, TypeTree, typ)
+	assert.Equal(t, []byte("cccc"), data)
+
+	_, _, ok = c.get(chainLocation{p: &Packfile{}, offset: 3})
+	assert.False(t, ok)
+}
+
+func TestDeltaBaseCacheIgnoresLargeBases(t *testing.T) {
+	c := NewDeltaBaseCache(4)
+	c.add(chainLocation{p: &Packfile{}, offset: 1}, TypeBlob, []byte("aaaaa"))
+
+	assert.Equal(t, 0, c.Len())
+	assert.EqualValues(t, 0, c.Size())
+}
+
+func TestPackfileCachesDeltaBases(t *testing.T) {
+	pack, _, baseOffset, _ := deltaTestPack(t)
+
+	iw, err := IndexPackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+
+	var idx bytes.Buffer
+	_, err = iw.WriteTo(&idx)
+	require.NoError(t, err)
+
+	p, err := DecodePackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+	p.idx, err = DecodeIndex(bytes.NewReader(idx.Bytes()), sha1.New())
+	require.NoError(t, err)
+	p.cache = NewDeltaBaseCache(1024)
+
+	unpack := func(contents string) []byte {
+		o, err := p.Object(objectName(TypeBlob, contents))
+		require.NoError(t, err)
+		data, err := o.Unpack()
+		require.NoError(t, err)
+		return data
+	}
+
+	assert.Equal(t, []byte("Hello, world!\n"), unpack("Hello, world!\n"))
+	assert.Equal(t, 1, p.cache.Len())
+
+	chain, err := p.find(int64(baseOffset))
+	require.NoError(t, err)
+	assert.IsType(t, &cachedChain{}, chain)
+
+	// Modifying the contents of a cached base must not affect the cache.
+	base := unpack("Hello")
+	assert.Equal(t, []byte("Hello"), base)
+	copy(base, "HELLO")
+
+	assert.Equal(t, []byte("Hello!\n"), unpack("Hello!\n"))
+	assert.Equal(t, []byte("Hello, world!\n"), unpack("Hello, world!\n"))
+	assert.Equal(t, 1, p.cache.Len())
+}
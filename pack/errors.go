@@ -13,3 +13,129 @@ type UnsupportedVersionErr struct {
 func (u *UnsupportedVersionErr) Error() string {
 	return fmt.Sprintf("gitobj/pack: unsupported version: %d", u.Got)
 }
+
+// CorruptIndexErr is a type implementing 'error' which indicates that an index
+// file is internally inconsistent, for instance because it has been truncated.
+type CorruptIndexErr struct {
+	// Name is the name of the index file, if known.
+	Name string
+	// Reason describes the inconsistency that was detected.
+	Reason string
+}
+
+// Error implements 'error.Error()'.
+func (c *CorruptIndexErr) Error() string {
+	if len(c.Name) == 0 {
+		return fmt.Sprintf("gitobj/pack: corrupt index: %s", c.Reason)
+	}
+	return fmt.Sprintf("gitobj/pack: corrupt index %s: %s", c.Name, c.Reason)
+}
+
+// ChecksumMismatchErr is a type implementing 'error' which indicates that a
+// packfile does not match its index, as when one of them has been replaced
+// without the other.
+type ChecksumMismatchErr struct {
+	// Name is the path of the packfile, if known.
+	Name string
+	// Pack is the checksum of the packfile.
+	Pack []byte
+	// Index is the checksum of the packfile recorded in its index.
+	Index []byte
+}
+
+// Error implements 'error.Error()'.
+func (c *ChecksumMismatchErr) Error() string {
+	if len(c.Name) == 0 {
+		return fmt.Sprintf("gitobj/pack: packfile does not match index: checksum %x, index expects %x",
+			c.Pack, c.Index)
+	}
+	return fmt.Sprintf("gitobj/pack: packfile %s does not match index: checksum %x, index expects %x",
+		c.Name, c.Pack, c.Index)
+}
+
+// CorruptPackErr is a type implementing 'error' which indicates that the
+// contents of a packfile do not match the checksums which describe them, as
+// when it has been damaged on disk.
+type CorruptPackErr struct {
+	// Name is the path of the packfile, if known.
+	Name string
+	// Offset is the offset of the corrupt entry in the packfile, or that of
+	// its trailing checksum if the packfile as a whole does not match it.
+	Offset int64
+	// Reason describes the corruption that was detected.
+	Reason string
+}
+
+// Error implements 'error.Error()'.
+func (c *CorruptPackErr) Error() string {
+	if len(c.Name) == 0 {
+		return fmt.Sprintf("gitobj/pack: corrupt packfile at offset %d: %s",
+			c.Offset, c.Reason)
+	}
+	return fmt.Sprintf("gitobj/pack: corrupt packfile %s at offset %d: %s",
+		c.Name, c.Offset, c.Reason)
+}
+
+// BudgetExceededErr is a type implementing 'error' which indicates that
+// unpacking an object would have exceeded the limit of the *Budget from which
+// the memory to hold it is reserved.
+type BudgetExceededErr struct {
+	// Limit is the number of bytes which may be reserved from the budget.
+	Limit int64
+	// Used is the number of bytes which were already reserved.
+	Used int64
+	// Requested is the number of bytes for which a reservation was made.
+	Requested int64
+}
+
+// Error implements 'error.Error()'.
+func (b *BudgetExceededErr) Error() string {
+	return fmt.Sprintf("gitobj/pack: memory budget exceeded: %d byte(s) requested, %d of %d in use",
+		b.Requested, b.Used, b.Limit)
+}
+
+// DeltaDepthExceededErr is a type implementing 'error' which indicates that an
+// object is stored at the end of a longer delta-base chain than is allowed by
+// the Limits of its packfile, or that the chain is cyclic.
+type DeltaDepthExceededErr struct {
+	// Name is the path of the packfile, if known.
+	Name string
+	// Offset is the offset of the entry beyond the limit.
+	Offset int64
+	// Limit is the greatest number of deltas which may be applied.
+	Limit int
+}
+
+// Error implements 'error.Error()'.
+func (d *DeltaDepthExceededErr) Error() string {
+	if len(d.Name) == 0 {
+		return fmt.Sprintf("gitobj/pack: delta chain at offset %d exceeds depth %d",
+			d.Offset, d.Limit)
+	}
+	return fmt.Sprintf("gitobj/pack: delta chain in %s at offset %d exceeds depth %d",
+		d.Name, d.Offset, d.Limit)
+}
+
+// ObjectTooLargeErr is a type implementing 'error' which indicates that an
+// entry of a packfile declares a greater size than is allowed by the Limits of
+// its packfile.
+type ObjectTooLargeErr struct {
+	// Name is the path of the packfile, if known.
+	Name string
+	// Offset is the offset of the entry.
+	Offset int64
+	// Size is the size declared by the entry.
+	Size uint64
+	// Limit is the greatest size which may be unpacked.
+	Limit int64
+}
+
+// Error implements 'error.Error()'.
+func (o *ObjectTooLargeErr) Error() string {
+	if len(o.Name) == 0 {
+		return fmt.Sprintf("gitobj/pack: object at offset %d has %d byte(s), exceeding limit of %d",
+			o.Offset, o.Size, o.Limit)
+	}
+	return fmt.Sprintf("gitobj/pack: object in %s at offset %d has %d byte(s), exceeding limit of %d",
+		o.Name, o.Offset, o.Size, o.Limit)
+}
@@ -0,0 +1,202 @@
+package pack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+const (
+	// ewahRunningLengthBits is the number of bits in a running-length word
+	// (RLW) that encode the number of clean words it represents.
+	ewahRunningLengthBits = 32
+	// ewahLiteralBits is the number of bits in a running-length word that
+	// encode the number of literal words that follow it.
+	ewahLiteralBits = 64 - 1 - ewahRunningLengthBits
+
+	// ewahMaxRunningLength is the largest number of clean words that may
+	// be represented by a single running-length word.
+	ewahMaxRunningLength = (1 << ewahRunningLengthBits) - 1
+	// ewahMaxLiterals is the largest number of literal words that may
+	// follow a single running-length word.
+	ewahMaxLiterals = (1 << ewahLiteralBits) - 1
+)
+
+// bitmap is an uncompressed set of bits, where bit "i" is stored at position
+// (i % 64) of the word at index (i / 64).
+type bitmap []uint64
+
+// newBitmap returns an empty bitmap with room for at least "n" bits.
+func newBitmap(n int) bitmap {
+	return make(bitmap, (n+63)/64)
+}
+
+// Set sets the bit at position "i", growing the bitmap as necessary.
+func (b *bitmap) Set(i int) {
+	for len(*b) <= i/64 {
+		*b = append(*b, 0)
+	}
+	(*b)[i/64] |= 1 << uint(i%64)
+}
+
+// Get returns whether the bit at position "i" is set.
+func (b bitmap) Get(i int) bool {
+	if i < 0 || i/64 >= len(b) {
+		return false
+	}
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+// truncate returns the bitmap with every bit at or past position "n" cleared,
+// and room for no more than "n" bits.
+func (b bitmap) truncate(n int) bitmap {
+	words := (n + 63) / 64
+	if len(b) > words {
+		b = b[:words]
+	}
+	if rem := n % 64; rem != 0 && len(b) == words {
+		b[words-1] &= 1<<uint(rem) - 1
+	}
+	return b
+}
+
+// Count returns the number of bits which are set.
+func (b bitmap) Count() int {
+	var n int
+	for _, w := range b {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Xor returns a new bitmap containing the bits set in exactly one of the
+// receiving and given bitmaps.
+func (b bitmap) Xor(other bitmap) bitmap {
+	if len(b) < len(other) {
+		b, other = other, b
+	}
+
+	out := make(bitmap, len(b))
+	copy(out, b)
+	for i, w := range other {
+		out[i] ^= w
+	}
+	return out
+}
+
+// Or sets every bit in the receiving bitmap that is set in the given one.
+func (b *bitmap) Or(other bitmap) {
+	for len(*b) < len(other) {
+		*b = append(*b, 0)
+	}
+	for i, w := range other {
+		(*b)[i] |= w
+	}
+}
+
+// encodeEWAH returns the words of the EWAH-compressed representation of the
+// first "n" bits of the given bitmap, along with the index of the last
+// running-length word among them.
+func encodeEWAH(b bitmap, n int) ([]uint64, int) {
+	words := b
+	if want := (n + 63) / 64; len(words) > want {
+		words = words[:want]
+	}
+
+	var out []uint64
+	var rlw int
+
+	for i := 0; i < len(words) || len(out) == 0; {
+		var running uint64
+		var run, literals uint64
+
+		if i < len(words) && (words[i] == 0 || words[i] == ^uint64(0)) {
+			clean := words[i]
+			if clean != 0 {
+				running = 1
+			}
+			for i < len(words) && words[i] == clean && run < ewahMaxRunningLength {
+				run++
+				i++
+			}
+		}
+
+		start := i
+		for i < len(words) && words[i] != 0 && words[i] != ^uint64(0) && literals < ewahMaxLiterals {
+			literals++
+			i++
+		}
+
+		rlw = len(out)
+		out = append(out, running|run<<1|literals<<(1+ewahRunningLengthBits))
+		out = append(out, words[start:i]...)
+	}
+	return out, rlw
+}
+
+// writeEWAH writes the EWAH-compressed representation of the first "n" bits
+// of the given bitmap to "w" in the format used by Git, and returns the number
+// of bytes written.
+func writeEWAH(w io.Writer, b bitmap, n int) (int, error) {
+	words, rlw := encodeEWAH(b, n)
+
+	buf := make([]byte, 4+4+8*len(words)+4)
+	binary.BigEndian.PutUint32(buf[0:], uint32(n))
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(words)))
+	for i, word := range words {
+		binary.BigEndian.PutUint64(buf[8+8*i:], word)
+	}
+	binary.BigEndian.PutUint32(buf[8+8*len(words):], uint32(rlw))
+
+	return w.Write(buf)
+}
+
+// readEWAH reads an EWAH-compressed bitmap in the format used by Git from the
+// given io.ReaderAt at offset "at", and returns it, uncompressed, along with
+// the number of bits it holds and the number of bytes read.
+func readEWAH(r io.ReaderAt, at int64) (bitmap, int, int64, error) {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], at); err != nil {
+		return nil, 0, 0, err
+	}
+
+	n := int(binary.BigEndian.Uint32(hdr[0:]))
+	count := int64(binary.BigEndian.Uint32(hdr[4:]))
+
+	buf := make([]byte, 8*count+4)
+	if _, err := r.ReadAt(buf, at+8); err != nil {
+		return nil, 0, 0, err
+	}
+
+	b := newBitmap(n)
+	var pos int
+	for i := int64(0); i < count; {
+		rlw := binary.BigEndian.Uint64(buf[8*i:])
+		i++
+
+		run := int((rlw >> 1) & ewahMaxRunningLength)
+		literals := int64(rlw >> (1 + ewahRunningLengthBits))
+
+		if pos+run > len(b) || i+literals > count {
+			return nil, 0, 0, fmt.Errorf("gitobj/pack: malformed EWAH bitmap")
+		}
+
+		if rlw&1 != 0 {
+			for j := 0; j < run; j++ {
+				b[pos+j] = ^uint64(0)
+			}
+		}
+		pos += run
+
+		for j := int64(0); j < literals; j++ {
+			if pos >= len(b) {
+				return nil, 0, 0, fmt.Errorf("gitobj/pack: malformed EWAH bitmap")
+			}
+			b[pos] = binary.BigEndian.Uint64(buf[8*i:])
+			pos++
+			i++
+		}
+	}
+	return b, n, 8 + int64(len(buf)), nil
+}
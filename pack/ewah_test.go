@@ -0,0 +1,74 @@
+package pack
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEWAHRoundTrip(t *testing.T) {
+	b := newBitmap(1000)
+	for _, i := range []int{0, 3, 63, 64, 500, 999} {
+		b.Set(i)
+	}
+	// Fill a run of clean words.
+	for i := 128; i < 448; i++ {
+		b.Set(i)
+	}
+
+	var buf bytes.Buffer
+	n, err := writeEWAH(&buf, b, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, buf.Len(), n)
+
+	got, nbits, read, err := readEWAH(bytes.NewReader(buf.Bytes()), 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1000, nbits)
+	assert.EqualValues(t, buf.Len(), read)
+	assert.Equal(t, b, got)
+	assert.Equal(t, 6+320, got.Count())
+}
+
+func TestEWAHEncodesEmptyBitmaps(t *testing.T) {
+	words, rlw := encodeEWAH(nil, 0)
+
+	assert.Equal(t, []uint64{0}, words)
+	assert.Equal(t, 0, rlw)
+}
+
+func TestEWAHCompressesCleanWords(t *testing.T) {
+	b := newBitmap(64 * 4)
+	for i := 64; i < 64*3; i++ {
+		b.Set(i)
+	}
+
+	words, rlw := encodeEWAH(b, 64*4)
+
+	assert.Equal(t, []uint64{
+		// One zero word, with no literals.
+		1 << 1,
+		// Two one words, with no literals.
+		1 | 2<<1,
+		// One zero word, with no literals.
+		1 << 1,
+	}, words)
+	assert.Equal(t, 2, rlw)
+}
+
+func TestBitmapXor(t *testing.T) {
+	a, b := newBitmap(128), newBitmap(64)
+	a.Set(1)
+	a.Set(100)
+	b.Set(1)
+	b.Set(2)
+
+	x := a.Xor(b)
+
+	assert.False(t, x.Get(1))
+	assert.True(t, x.Get(2))
+	assert.True(t, x.Get(100))
+	assert.Equal(t, 2, x.Count())
+}
@@ -0,0 +1,245 @@
+package pack
+
+import (
+	"container/list"
+	"os"
+	"sync"
+)
+
+// FileCache limits the number of packfile and index files held open at once,
+// so that repositories with many thousands of packfiles may be read without
+// exceeding the limit on open file descriptors. Files read through a
+// *FileCache are opened on demand, and the least recently used are closed once
+// the limit is reached, to be opened again when they are next read.
+//
+// Files being read when the limit is reached are not closed until they are no
+// longer in use, and so the limit may be exceeded briefly when more files than
+// that are read at once. Since files are re-opened by path, a packfile which is
+// removed (as by `git gc`) while its file is closed can no longer be read.
+//
+// A *FileCache may be shared between many sets of packfiles (see:
+// NewSetWithFileCache), and is safe for concurrent use.
+type FileCache struct {
+	// limit is the maximum number of files held open at once, or zero if
+	// it is unlimited.
+	limit int
+	// hooks are called as files are opened and closed.
+	hooks FileHooks
+
+	// mu guards the fields below, and those of each *cachedFile.
+	mu sync.Mutex
+	// lru holds each open *cachedFile, with the most recently used at the
+	// front.
+	lru *list.List
+}
+
+// FileHooks are functions called with the path of each packfile and index file
+// read through a *FileCache as it is opened and closed, so that applications
+// may coordinate their own caching (as of pages of the files mapped into
+// memory) with the lifetime of the files. Any of them may be nil.
+//
+// Hooks are called after the event they describe, without any lock held by the
+// cache, and so may be called concurrently, and may themselves read from the
+// files. A file may be opened, and evicted, many times.
+type FileHooks struct {
+	// Open is called as a file is opened, when it is first read, or read
+	// again after being evicted.
+	Open func(path string)
+	// Evict is called as an idle file is closed by the cache, to remain
+	// within its limit. The file is opened again when it is next read.
+	Evict func(path string)
+	// Close is called as an open file is closed for good, as when the
+	// packfile (or set of packfiles) holding it is closed.
+	Close func(path string)
+}
+
+// NewFileCache returns a new *FileCache holding at most "limit" files open at
+// once. If "limit" is not positive, files are never evicted.
+func NewFileCache(limit int) *FileCache {
+	return NewFileCacheWithHooks(limit, nil)
+}
+
+// NewFileCacheWithHooks returns a new *FileCache as NewFileCache does, which
+// calls the given hooks as files are opened and closed. If "hooks" is nil,
+// none are called.
+func NewFileCacheWithHooks(limit int, hooks *FileHooks) *FileCache {
+	c := &FileCache{
+		limit: limit,
+		lru:   list.New(),
+	}
+	if c.limit < 0 {
+		c.limit = 0
+	}
+	if hooks != nil {
+		c.hooks = *hooks
+	}
+	return c
+}
+
+// Len returns the number of files currently held open by the cache.
+func (c *FileCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// open returns an io.ReaderAt over the file at the given path, which is not
+// itself opened until it is first read. It returns an error if the file does
+// not exist.
+func (c *FileCache) open(path string) (*cachedFile, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedFile{cache: c, path: path, size: fi.Size()}, nil
+}
+
+// evict closes the least recently used files which are not in use until no
+// more than the limit remain open, or none which are idle remain, and returns
+// the paths of those closed. The caller must hold "mu".
+func (c *FileCache) evict() []string {
+	if c.limit == 0 {
+		return nil
+	}
+
+	var evicted []string
+	for e := c.lru.Back(); e != nil && c.lru.Len() > c.limit; {
+		prev := e.Prev()
+		if f := e.Value.(*cachedFile); f.refs == 0 {
+			f.closeFile()
+			evicted = append(evicted, f.path)
+		}
+		e = prev
+	}
+	return evicted
+}
+
+// fire calls the hook "fn", if it is not nil, with each of the given paths. The
+// caller must not hold "mu".
+func (c *FileCache) fire(fn func(path string), paths ...string) {
+	if fn == nil {
+		return
+	}
+	for _, path := range paths {
+		fn(path)
+	}
+}
+
+// cachedFile is an io.ReaderAt over a file which is opened from a *FileCache
+// when it is read, and which may be closed by the cache while idle.
+type cachedFile struct {
+	cache *FileCache
+	path  string
+	// size is the size of the file, as it was when first opened.
+	size int64
+
+	// f is the open file, or nil if it is not open. It is guarded by the
+	// cache's "mu", as are the fields below.
+	f *os.File
+	// e is the element of the cache's "lru" holding the file while it is
+	// open.
+	e *list.Element
+	// refs is the number of reads of the file in progress.
+	refs int
+	// closed indicates whether the file has been closed by Close, after
+	// which it may not be read.
+	closed bool
+}
+
+// ReadAt implements io.ReaderAt, opening the file if it is not already open.
+func (f *cachedFile) ReadAt(p []byte, off int64) (int, error) {
+	file, err := f.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer f.release()
+
+	return file.ReadAt(p, off)
+}
+
+// Size returns the size of the file.
+func (f *cachedFile) Size() int64 {
+	return f.size
+}
+
+// Close closes the file if it is open, after which it may not be read.
+func (f *cachedFile) Close() error {
+	c := f.cache
+
+	c.mu.Lock()
+	if f.closed {
+		c.mu.Unlock()
+		return os.ErrClosed
+	}
+	f.closed = true
+
+	if f.f == nil {
+		c.mu.Unlock()
+		return nil
+	}
+	err := f.closeFile()
+	c.mu.Unlock()
+
+	c.fire(c.hooks.Close, f.path)
+	return err
+}
+
+// acquire opens the file if it is not already open, marks it in use and most
+// recently used, and returns it.
+func (f *cachedFile) acquire() (*os.File, error) {
+	c := f.cache
+
+	c.mu.Lock()
+	if f.closed {
+		c.mu.Unlock()
+		return nil, &os.PathError{Op: "read", Path: f.path, Err: os.ErrClosed}
+	}
+
+	var opened bool
+	if f.f == nil {
+		file, err := os.Open(f.path)
+		if err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		f.f = file
+		f.e = c.lru.PushFront(f)
+		opened = true
+	} else {
+		c.lru.MoveToFront(f.e)
+	}
+	f.refs++
+
+	file, evicted := f.f, c.evict()
+	c.mu.Unlock()
+
+	if opened {
+		c.fire(c.hooks.Open, f.path)
+	}
+	c.fire(c.hooks.Evict, evicted...)
+	return file, nil
+}
+
+// release marks a read of the file as finished, closing files beyond the
+// cache's limit which are no longer in use.
+func (f *cachedFile) release() {
+	c := f.cache
+
+	c.mu.Lock()
+	f.refs--
+	evicted := c.evict()
+	c.mu.Unlock()
+
+	c.fire(c.hooks.Evict, evicted...)
+}
+
+// closeFile closes the open file, and removes it from the cache. The caller
+// must hold the cache's "mu".
+func (f *cachedFile) closeFile() error {
+	f.cache.lru.Remove(f.e)
+	err := f.f.Close()
+
+	f.f, f.e = nil, nil
+	return err
+}
@@ -0,0 +1,153 @@
+package pack
+
+import (
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetWithFileCacheLimitsOpenFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-pack-files")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	pd := filepath.Join(root, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	contents := []string{"a\n", "b\n", "c\n", "d\n"}
+	names := make([][]byte, 0, len(contents))
+	for _, data := range contents {
+		_, name := writeTestPack(t, pd, data)
+		names = append(names, name)
+	}
+
+	files := NewFileCache(3)
+
+	set, err := NewSetWithFileCache(root, sha1.New(), files)
+	require.NoError(t, err)
+	assert.Len(t, set.Packs(), 4)
+	assert.True(t, files.Len() <= 3)
+
+	for i := 0; i < 2; i++ {
+		for j, name := range names {
+			o, err := set.Object(name)
+			require.NoError(t, err)
+
+			data, err := o.Unpack()
+			require.NoError(t, err)
+			assert.Equal(t, []byte(contents[j]), data)
+
+			assert.True(t, files.Len() <= 3)
+		}
+	}
+
+	require.NoError(t, set.Close())
+	assert.Equal(t, 0, files.Len())
+}
+
+func TestCachedFileReopensClosedFiles(t *testing.T) {
+	f, err := ioutil.TempFile("", "gitobj-pack-files")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("Hello, world!\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	files := NewFileCache(1)
+
+	a, err := files.open(f.Name())
+	require.NoError(t, err)
+	b, err := files.open(f.Name())
+	require.NoError(t, err)
+	assert.EqualValues(t, 14, a.Size())
+
+	buf := make([]byte, 5)
+	for _, r := range []*cachedFile{a, b, a} {
+		_, err := r.ReadAt(buf, 7)
+		require.NoError(t, err)
+		assert.Equal(t, "world", string(buf))
+		assert.Equal(t, 1, files.Len())
+	}
+
+	require.NoError(t, a.Close())
+	assert.Equal(t, 0, files.Len())
+
+	_, err = a.ReadAt(buf, 0)
+	assert.Error(t, err)
+	assert.Equal(t, os.ErrClosed, a.Close())
+}
+
+func TestFileCacheHooks(t *testing.T) {
+	var paths []string
+	for _, data := range []string{"a", "b"} {
+		f, err := ioutil.TempFile("", "gitobj-pack-files")
+		require.NoError(t, err)
+		defer os.Remove(f.Name())
+
+		_, err = f.WriteString(data)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		paths = append(paths, f.Name())
+	}
+
+	var events []string
+	record := func(event string) func(string) {
+		return func(path string) {
+			events = append(events, event+" "+path)
+		}
+	}
+
+	files := NewFileCacheWithHooks(1, &FileHooks{
+		Open:  record("open"),
+		Evict: record("evict"),
+		Close: record("close"),
+	})
+
+	a, err := files.open(paths[0])
+	require.NoError(t, err)
+	b, err := files.open(paths[1])
+	require.NoError(t, err)
+
+	buf := make([]byte, 1)
+	for _, r := range []*cachedFile{a, a, b} {
+		_, err := r.ReadAt(buf, 0)
+		require.NoError(t, err)
+	}
+	require.NoError(t, a.Close())
+	require.NoError(t, b.Close())
+
+	assert.Equal(t, []string{
+		"open " + paths[0],
+		"open " + paths[1],
+		"evict " + paths[0],
+		"close " + paths[1],
+	}, events)
+}
+
+func TestFileCacheWithoutLimitNeverEvicts(t *testing.T) {
+	f, err := ioutil.TempFile("", "gitobj-pack-files")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, f.Close())
+
+	files := NewFileCache(0)
+
+	var opened []*cachedFile
+	for i := 0; i < 3; i++ {
+		r, err := files.open(f.Name())
+		require.NoError(t, err)
+		defer r.Close()
+
+		_, err = r.ReadAt(make([]byte, 1), 0)
+		assert.Error(t, err)
+		opened = append(opened, r)
+	}
+	assert.Equal(t, len(opened), files.Len())
+}
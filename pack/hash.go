@@ -0,0 +1,103 @@
+package pack
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"reflect"
+	"sync"
+)
+
+// hashKey identifies a hash algorithm by the type and size of its instances,
+// so that hashes which wrap (or truncate) the same underlying hash may be told
+// apart by their sizes.
+type hashKey struct {
+	typ  reflect.Type
+	size int
+}
+
+// hashFunction is a registered hash algorithm (see: RegisterHash).
+type hashFunction struct {
+	// new returns a new instance of the hash.
+	new func() hash.Hash
+	// version is the identifier by which multi-pack-indexes, reverse
+	// indexes, and commit-graphs record that their object names are
+	// computed by the hash.
+	version byte
+}
+
+var (
+	hashesMu sync.RWMutex
+	// hashes holds the registered hash algorithms.
+	hashes = make(map[hashKey]*hashFunction)
+)
+
+func init() {
+	RegisterHash(sha1.New, 1)
+	RegisterHash(sha256.New, 2)
+}
+
+// RegisterHash registers the hash algorithm whose instances are returned by
+// "newHash", so that packfiles, indexes, and multi-pack-indexes whose objects
+// are named by it may be read and verified, identifying it in their headers by
+// the given version. SHA-1 (version 1) and SHA-256 (version 2) are registered
+// already.
+//
+// Hashes are told apart by the type and size of their instances, so that those
+// wrapping or truncating another must be of their own types, or sizes. Object
+// names may be no longer than MaxHashSize.
+func RegisterHash(newHash func() hash.Hash, version byte) error {
+	h := newHash()
+	if h.Size() <= 0 || h.Size() > MaxHashSize {
+		return fmt.Errorf("gitobj/pack: unsupported hash size: %d", h.Size())
+	}
+
+	hashesMu.Lock()
+	defer hashesMu.Unlock()
+
+	hashes[keyOf(h)] = &hashFunction{new: newHash, version: version}
+	return nil
+}
+
+// HashVersion returns the identifier by which multi-pack-indexes, reverse
+// indexes, and commit-graphs record that their object names are computed by
+// the hash algorithm of which "h" is an instance (see: RegisterHash). Hashes
+// which have not been registered are identified as SHA-256 is if they are as
+// long, and as SHA-1 is otherwise.
+func HashVersion(h hash.Hash) byte {
+	if fn := lookupHash(h); fn != nil {
+		return fn.version
+	}
+	if h.Size() == sha256.Size {
+		return 2
+	}
+	return 1
+}
+
+// newHash returns a new instance of the hash algorithm of which "h" is an
+// instance, so that it may be used without disturbing "h".
+func newHash(h hash.Hash) hash.Hash {
+	if fn := lookupHash(h); fn != nil {
+		return fn.new()
+	}
+	if h.Size() == sha256.Size {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// lookupHash returns the registered hash algorithm of which "h" is an instance,
+// or nil if it has not been registered.
+func lookupHash(h hash.Hash) *hashFunction {
+	hashesMu.RLock()
+	defer hashesMu.RUnlock()
+
+	return hashes[keyOf(h)]
+}
+
+// keyOf returns the key by which the hash algorithm of which "h" is an instance
+// is registered.
+func keyOf(h hash.Hash) hashKey {
+	return hashKey{typ: reflect.TypeOf(h), size: h.Size()}
+}
@@ -0,0 +1,32 @@
+package pack
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashVersion(t *testing.T) {
+	assert.Equal(t, byte(1), HashVersion(sha1.New()))
+	assert.Equal(t, byte(2), HashVersion(sha256.New()))
+
+	assert.NoError(t, RegisterHash(sha256.New224, 0x80))
+	assert.Equal(t, byte(0x80), HashVersion(sha256.New224()))
+	assert.Equal(t, byte(2), HashVersion(sha256.New()))
+}
+
+func TestNewHashReturnsRegisteredHash(t *testing.T) {
+	assert.NoError(t, RegisterHash(sha256.New224, 0x80))
+
+	h := newHash(sha256.New224())
+	assert.Equal(t, sha256.Size224, h.Size())
+}
+
+func TestRegisterHashRejectsLongHashes(t *testing.T) {
+	err := RegisterHash(func() hash.Hash { return sha512.New() }, 0x81)
+	assert.EqualError(t, err, "gitobj/pack: unsupported hash size: 64")
+}
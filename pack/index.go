@@ -3,6 +3,7 @@ package pack
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"io"
 )
@@ -29,10 +30,28 @@ type Index struct {
 }
 
 // Count returns the number of objects in the packfile.
+//
+// On platforms where int is 32 bits wide, Count overflows for indexes holding
+// more than 2^31-1 objects. The index search code does not rely on it, and
+// instead works with 64-bit counts and positions throughout.
 func (i *Index) Count() int {
 	return int(i.fanout[255])
 }
 
+// count returns the number of objects in the packfile, without any risk of
+// overflow.
+func (i *Index) count() int64 {
+	return int64(i.fanout[255])
+}
+
+// Name returns the name of the "n"th object in the index, in sorted order.
+func (i *Index) Name(n int64) ([]byte, error) {
+	if n < 0 || n >= i.count() {
+		return nil, fmt.Errorf("gitobj/pack: index position %d out of range", n)
+	}
+	return i.version.Name(i, n)
+}
+
 // Close closes the packfile index if the underlying data stream is closeable.
 // If so, it returns any error involved in closing.
 func (i *Index) Close() error {
@@ -65,6 +84,12 @@ func IsNotFound(err error) bool {
 //
 // Otherwise, (entry, nil) will be returned.
 func (i *Index) Entry(name []byte) (*IndexEntry, error) {
+	if len(name) == 0 || i.count() == 0 {
+		// Empty indexes (which some tools emit for packs with no
+		// objects) contain nothing to search.
+		return nil, errNotFound
+	}
+
 	var last *bounds
 	bounds := i.bounds(name)
 
@@ -110,6 +135,108 @@ func (i *Index) Entry(name []byte) (*IndexEntry, error) {
 	return nil, errNotFound
 }
 
+// entryAt returns the name of the object at position "at" in the sorted list of
+// names in this index, along with its entry.
+func (i *Index) entryAt(at int64) ([]byte, *IndexEntry, error) {
+	name, err := i.version.Name(i, at)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry, err := i.version.Entry(i, at)
+	if err != nil {
+		return nil, nil, err
+	}
+	return name, entry, nil
+}
+
+// crcAt returns the CRC32 of the packed data of the object at position "at" in
+// the sorted list of names in this index, and whether the index records one
+// (only version 2 indexes do).
+func (i *Index) crcAt(at int64) (uint32, bool, error) {
+	if _, ok := i.version.(*V2); !ok {
+		return 0, false, nil
+	}
+
+	var crc [indexObjectCRCWidth]byte
+	if _, err := i.readAt(crc[:], v2CRCOffset(at, i.count(), int64(i.hashSize()))); err != nil {
+		return 0, false, err
+	}
+	return binary.BigEndian.Uint32(crc[:]), true, nil
+}
+
+// hashSize returns the length of the object names stored in this index.
+func (i *Index) hashSize() int {
+	switch v := i.version.(type) {
+	case *V1:
+		return v.hash.Size()
+	case *V2:
+		return v.hash.Size()
+	}
+	return 0
+}
+
+// trailerOffset returns the offset at which the trailer of this index begins.
+// The trailer holds a copy of the corresponding packfile's checksum, followed
+// by the checksum of the index itself.
+func (i *Index) trailerOffset() (int64, error) {
+	total := i.count()
+	hashlen := int64(i.hashSize())
+
+	switch i.version.(type) {
+	case *V1:
+		return v1EntryOffset(total, hashlen), nil
+	case *V2:
+		if total == 0 {
+			return v2LargeOffsetOffset(0, 0, hashlen), nil
+		}
+
+		// The trailer follows the table of large offsets, whose size
+		// can only be determined by counting the small offsets which
+		// refer to it.
+		offs := make([]byte, indexObjectSmallOffsetWidth*total)
+		if _, err := i.readAt(offs, v2SmallOffsetOffset(0, total, hashlen)); err != nil {
+			return 0, err
+		}
+
+		var large int64
+		for at := int64(0); at < total; at++ {
+			if offs[indexObjectSmallOffsetWidth*at]&0x80 != 0 {
+				large++
+			}
+		}
+		return v2LargeOffsetOffset(large, total, hashlen), nil
+	}
+	return 0, &UnsupportedVersionErr{}
+}
+
+// PackChecksum returns the checksum of the packfile corresponding to this
+// index, as recorded in the index's trailer. It should be equal to the
+// packfile's own checksum (see: Packfile.Checksum).
+func (i *Index) PackChecksum() ([]byte, error) {
+	return i.trailer(0)
+}
+
+// Checksum returns the checksum of the index itself, which is the last entry in
+// its trailer.
+func (i *Index) Checksum() ([]byte, error) {
+	return i.trailer(1)
+}
+
+// trailer returns the n-th checksum in the index's trailer.
+func (i *Index) trailer(n int64) ([]byte, error) {
+	at, err := i.trailerOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := make([]byte, i.hashSize())
+	if _, err := i.readAt(sum, at+n*int64(len(sum))); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
 // readAt is a convenience method that allow reading into the underlying data
 // source from other callers within this package.
 func (i *Index) readAt(p []byte, at int64) (n int, err error) {
@@ -134,7 +261,7 @@ func (i *Index) bounds(name []byte) *bounds {
 	if name[0] == 255 {
 		// As above, if the upper bound is the max byte value, make the
 		// upper bound the last object in the list.
-		right = int64(i.Count())
+		right = i.count()
 	} else {
 		// Otherwise, make the upper bound the first object which is not
 		// within the given slot.
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"os"
 )
 
 const (
@@ -58,7 +59,9 @@ var (
 // DecodeIndex decodes an index whose underlying data is supplied by "r".
 //
 // DecodeIndex reads only the header and fanout table, and does not eagerly
-// parse index entries.
+// parse index entries. The fanout table must be monotonic, and, if "r" is an
+// *os.File, consistent with the size of that file, otherwise a
+// *CorruptIndexErr is returned.
 //
 // If there was an error parsing, it will be returned immediately.
 func DecodeIndex(r io.ReaderAt, hash hash.Hash) (*Index, error) {
@@ -72,6 +75,10 @@ func DecodeIndex(r io.ReaderAt, hash hash.Hash) (*Index, error) {
 		return nil, err
 	}
 
+	if err := validateIndexFanout(r, version, hash.Size(), fanout); err != nil {
+		return nil, err
+	}
+
 	return &Index{
 		version: version,
 		fanout:  fanout,
@@ -123,3 +130,67 @@ func decodeIndexFanout(r io.ReaderAt, offset int64) ([]uint32, error) {
 
 	return fanout, nil
 }
+
+// validateIndexFanout ensures that the given fanout table is monotonic, and, if
+// the size of "r" can be determined, that the number of objects it declares is
+// consistent with that size.
+func validateIndexFanout(r io.ReaderAt, version IndexVersion, hashlen int, fanout []uint32) error {
+	type namer interface {
+		Name() string
+	}
+	type stater interface {
+		Stat() (os.FileInfo, error)
+	}
+
+	var name string
+	if n, ok := r.(namer); ok {
+		name = n.Name()
+	}
+
+	for i := 1; i < len(fanout); i++ {
+		if fanout[i] < fanout[i-1] {
+			return &CorruptIndexErr{
+				Name:   name,
+				Reason: fmt.Sprintf("non-monotonic fanout table at entry %d", i),
+			}
+		}
+	}
+
+	s, ok := r.(stater)
+	if !ok {
+		return nil
+	}
+	fi, err := s.Stat()
+	if err != nil {
+		return err
+	}
+
+	total := int64(fanout[indexFanoutEntries-1])
+	size := fi.Size()
+	hlen := int64(hashlen)
+
+	var min, max int64
+	switch version.(type) {
+	case *V1:
+		min = v1EntryOffset(total, hlen) + 2*hlen
+		max = min
+	case *V2:
+		min = v2LargeOffsetOffset(0, total, hlen) + 2*hlen
+		max = min
+		if total > 0 {
+			// Every object but the first may have a large offset.
+			max += (total - 1) * indexObjectLargeOffsetWidth
+		}
+	default:
+		return nil
+	}
+
+	if size < min || size > max {
+		return &CorruptIndexErr{
+			Name: name,
+			Reason: fmt.Sprintf("size %d is inconsistent with %d object(s)",
+				size, total),
+		}
+	}
+	return nil
+}
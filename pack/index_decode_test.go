@@ -5,9 +5,12 @@ import (
 	"crypto/sha1"
 	"encoding/binary"
 	"io"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDecodeIndexV2(t *testing.T) {
@@ -71,3 +74,67 @@ func TestDecodeIndexEmptyContents(t *testing.T) {
 	assert.Equal(t, io.EOF, err)
 	assert.Nil(t, idx)
 }
+
+func TestDecodeIndexNonMonotonicFanout(t *testing.T) {
+	buf := make([]byte, indexFanoutWidth)
+	binary.BigEndian.PutUint32(buf[10*indexFanoutEntryWidth:], 2)
+	binary.BigEndian.PutUint32(buf[11*indexFanoutEntryWidth:], 1)
+
+	idx, err := DecodeIndex(bytes.NewReader(buf), sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: corrupt index: non-monotonic fanout table at entry 11")
+	assert.IsType(t, &CorruptIndexErr{}, err)
+	assert.Nil(t, idx)
+}
+
+func TestDecodeIndexFileOfConsistentSize(t *testing.T) {
+	f := writeIndexFile(t, indexV2WithObjects(2), 0)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	idx, err := DecodeIndex(f, sha1.New())
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, idx.Count())
+}
+
+func TestDecodeIndexTruncatedFile(t *testing.T) {
+	f := writeIndexFile(t, indexV2WithObjects(2), 1)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	idx, err := DecodeIndex(f, sha1.New())
+
+	assert.EqualError(t, err, "gitobj/pack: corrupt index "+f.Name()+
+		": size 1127 is inconsistent with 2 object(s)")
+	assert.Nil(t, idx)
+}
+
+// indexV2WithObjects returns the contents of a version 2 index with "n"
+// objects, whose names, CRCs, and offsets are all zero.
+func indexV2WithObjects(n int) []byte {
+	buf := []byte{0xff, 0x74, 0x4f, 0x63, 0x0, 0x0, 0x0, 0x2}
+	for i := 0; i < indexFanoutEntries; i++ {
+		x := make([]byte, 4)
+		binary.BigEndian.PutUint32(x, uint32(n))
+
+		buf = append(buf, x...)
+	}
+
+	buf = append(buf, make([]byte, n*(20+indexObjectCRCWidth+indexObjectSmallOffsetWidth))...)
+	return append(buf, make([]byte, 2*20)...)
+}
+
+// writeIndexFile writes all but the last "truncate" bytes of "contents" to a
+// temporary file, and returns it opened for reading.
+func writeIndexFile(t *testing.T, contents []byte, truncate int) *os.File {
+	f, err := ioutil.TempFile("", "pack-*.idx")
+	require.NoError(t, err)
+
+	_, err = f.Write(contents[:len(contents)-truncate])
+	require.NoError(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	return f
+}
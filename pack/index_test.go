@@ -5,6 +5,7 @@ import (
 	"crypto/sha1"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -75,6 +76,19 @@ func TestIndexCount(t *testing.T) {
 	assert.EqualValues(t, 255, idx.Count())
 }
 
+func TestIndexName(t *testing.T) {
+	name, err := idx.Name(6)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x1, 0x1, 0x1, 0x1, 0x1, 0x1, 0x1, 0x1, 0x1, 0x1,
+		0x1, 0x1, 0x1, 0x1, 0x1, 0x1, 0x1, 0x1, 0x1, 0x1,
+	}, name)
+
+	_, err = idx.Name(int64(idx.Count()))
+	assert.EqualError(t, err, "gitobj/pack: index position 1280 out of range")
+}
+
 func TestIndexIsNotFound(t *testing.T) {
 	assert.True(t, IsNotFound(errNotFound),
 		"expected 'errNotFound' to satisfy 'IsNotFound()'")
@@ -177,3 +191,31 @@ func init() {
 		r: bytes.NewReader(buf.Bytes()),
 	}
 }
+
+func TestIndexBoundsWithMoreThan2To31Objects(t *testing.T) {
+	fanout := make([]uint32, 256)
+	for i := 0; i < len(fanout); i++ {
+		fanout[i] = math.MaxUint32 - uint32(255-i)
+	}
+
+	idx := &Index{fanout: fanout}
+
+	b := idx.bounds([]byte{0xff})
+	assert.EqualValues(t, int64(math.MaxUint32)-1, b.Left())
+	assert.EqualValues(t, int64(math.MaxUint32), b.Right())
+
+	b = idx.bounds([]byte{0x80})
+	assert.EqualValues(t, int64(math.MaxUint32)-128, b.Left())
+	assert.EqualValues(t, int64(math.MaxUint32)-126, b.Right())
+
+	assert.EqualValues(t, int64(math.MaxUint32), idx.count())
+}
+
+func TestIndexV2EntryOffsetsWithMoreThan2To31Objects(t *testing.T) {
+	total := int64(math.MaxUint32)
+
+	assert.EqualValues(t, indexOffsetV2Start+(20+4)*total+4*(total-1),
+		v2SmallOffsetOffset(total-1, total, 20))
+	assert.EqualValues(t, indexOffsetV2Start+(20+4+4)*total,
+		v2LargeOffsetOffset(0, total, 20))
+}
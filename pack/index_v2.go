@@ -31,7 +31,7 @@ func (v *V2) Entry(idx *Index, at int64) (*IndexEntry, error) {
 
 	hashlen := v.hash.Size()
 
-	if _, err := idx.readAt(offs[:], v2SmallOffsetOffset(at, int64(idx.Count()), int64(hashlen))); err != nil {
+	if _, err := idx.readAt(offs[:], v2SmallOffsetOffset(at, idx.count(), int64(hashlen))); err != nil {
 		return nil, err
 	}
 
@@ -42,7 +42,7 @@ func (v *V2) Entry(idx *Index, at int64) (*IndexEntry, error) {
 		//
 		// Mask away (offs&0x7fffffff) the MSB to use as an index to
 		// find the offset of the 8-byte pack offset.
-		lo := v2LargeOffsetOffset(int64(loc&0x7fffffff), int64(idx.Count()), int64(hashlen))
+		lo := v2LargeOffsetOffset(int64(loc&0x7fffffff), idx.count(), int64(hashlen))
 
 		var offs [8]byte
 		if _, err := idx.readAt(offs[:], lo); err != nil {
@@ -68,6 +68,17 @@ func v2ShaOffset(at int64, hashlen int64) int64 {
 		(hashlen * at)
 }
 
+// v2CRCOffset returns the offset of the CRC32 of the packed object given by
+// "at".
+func v2CRCOffset(at, total, hashlen int64) int64 {
+	// Skip the packfile index header and the L1 fanout table.
+	return indexOffsetV2Start +
+		// Skip the name table.
+		(hashlen * total) +
+		// Skip until the desired CRC in the CRC table.
+		(indexObjectCRCWidth * at)
+}
+
 // v2SmallOffsetOffset returns the offset of an object's small (4-byte) offset
 // given by "at".
 func v2SmallOffsetOffset(at, total, hashlen int64) int64 {
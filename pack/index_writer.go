@@ -0,0 +1,327 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+const (
+	// maxSmallOffset is the largest offset which may be stored directly in
+	// the table of small offsets in a version 2 index.
+	maxSmallOffset = 0x7fffffff
+)
+
+// IndexWriter writes version 2 index (".idx") files, giving the name, CRC32,
+// and offset of each object in a corresponding packfile.
+type IndexWriter struct {
+	// checksum is the trailing checksum of the corresponding packfile.
+	checksum []byte
+	// sum is the hash used to compute the checksum of the index.
+	sum hash.Hash
+
+	// entries holds each object added so far, in order.
+	entries []*indexedEntry
+}
+
+// indexedEntry records the location of an object in a packfile.
+type indexedEntry struct {
+	// name is the name of the object.
+	name []byte
+	// offset is the offset of the object's header in the packfile.
+	offset int64
+	// crc is the CRC32 of the object's header and compressed contents.
+	crc uint32
+}
+
+// NewIndexWriter returns a new *IndexWriter for the packfile whose trailing
+// checksum is given. "sum" is a new instance of the hash algorithm used by that
+// packfile.
+func NewIndexWriter(checksum []byte, sum hash.Hash) *IndexWriter {
+	return &IndexWriter{
+		checksum: checksum,
+		sum:      sum,
+	}
+}
+
+// Add records the object named "name", which is stored at the given offset in
+// the packfile, and whose header and compressed contents have the given CRC32.
+func (w *IndexWriter) Add(name []byte, offset int64, crc uint32) {
+	w.entries = append(w.entries, &indexedEntry{
+		name:   append([]byte(nil), name...),
+		offset: offset,
+		crc:    crc,
+	})
+}
+
+// WriteTo writes the index, including each object added so far, to the given
+// io.Writer, and returns the number of bytes written.
+//
+// Offsets which do not fit in 31 bits are written to the table of large
+// offsets.
+func (w *IndexWriter) WriteTo(to io.Writer) (int64, error) {
+	entries := make([]*indexedEntry, len(w.entries))
+	copy(entries, w.entries)
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].name, entries[j].name) < 0
+	})
+
+	for i := 1; i < len(entries); i++ {
+		if bytes.Equal(entries[i-1].name, entries[i].name) {
+			return 0, fmt.Errorf("gitobj/pack: duplicate object %x", entries[i].name)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	buf.Write(indexHeader)
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+
+	var fanout [indexFanoutEntries]uint32
+	for _, e := range entries {
+		fanout[e.name[0]]++
+	}
+	for i := 1; i < len(fanout); i++ {
+		fanout[i] += fanout[i-1]
+	}
+	binary.Write(&buf, binary.BigEndian, fanout[:])
+
+	for _, e := range entries {
+		buf.Write(e.name)
+	}
+	for _, e := range entries {
+		binary.Write(&buf, binary.BigEndian, e.crc)
+	}
+
+	var large []uint64
+	for _, e := range entries {
+		if e.offset <= maxSmallOffset {
+			binary.Write(&buf, binary.BigEndian, uint32(e.offset))
+			continue
+		}
+
+		binary.Write(&buf, binary.BigEndian, uint32(len(large))|0x80000000)
+		large = append(large, uint64(e.offset))
+	}
+	binary.Write(&buf, binary.BigEndian, large)
+
+	buf.Write(w.checksum)
+
+	w.sum.Reset()
+	w.sum.Write(buf.Bytes())
+	buf.Write(w.sum.Sum(nil))
+
+	return buf.WriteTo(to)
+}
+
+// IndexPackfile reads the packfile given by "r" in its entirety, and returns
+// an *IndexWriter holding each of its objects, from which its index may be
+// written. "sum" is a new instance of the hash algorithm used by the packfile.
+//
+// The name of each object is computed by resolving its delta-base chain, if it
+// has one. Every base must be stored in the packfile itself (that is, "thin"
+// packfiles are not supported), and its trailing checksum must be valid,
+// otherwise an error is returned.
+func IndexPackfile(r io.ReaderAt, sum hash.Hash) (*IndexWriter, error) {
+	p, err := DecodePackfile(r, sum)
+	if err != nil {
+		return nil, err
+	}
+
+	type scanned struct {
+		offset int64
+		crc    uint32
+		name   []byte
+	}
+
+	objects := make([]*scanned, 0, p.Objects)
+
+	offset := int64(12)
+	for i := uint32(0); i < p.Objects; i++ {
+		obj := &scanned{offset: offset}
+
+		hdr, err := p.entryHeader(offset)
+		if err != nil {
+			return nil, err
+		}
+		typ := hdr.Type
+
+		br := &byteReaderAt{r: r, o: hdr.DataOffset}
+		zr, err := newZlibReader(br, p.unpooled)
+		if err != nil {
+			return nil, err
+		}
+
+		// Compute the names of non-deltified objects as they are
+		// inflated; the contents of deltas are discarded.
+		sum.Reset()
+		if typ < TypeObjectOffsetDelta {
+			fmt.Fprintf(sum, "%s %d\x00", typ, hdr.Size)
+		}
+		if _, err := io.Copy(sum, zr); err != nil {
+			zr.Close()
+			return nil, err
+		}
+		if err := zr.Close(); err != nil {
+			return nil, err
+		}
+		if typ < TypeObjectOffsetDelta {
+			obj.name = sum.Sum(nil)
+		}
+
+		end := br.Offset()
+
+		crc := crc32.NewIEEE()
+		if _, err := io.Copy(crc, io.NewSectionReader(r, offset, end-offset)); err != nil {
+			return nil, err
+		}
+		obj.crc = crc.Sum32()
+
+		objects = append(objects, obj)
+		offset = end
+	}
+
+	checksum := make([]byte, sum.Size())
+	if _, err := r.ReadAt(checksum, offset); err != nil {
+		return nil, err
+	}
+
+	sum.Reset()
+	if _, err := io.Copy(sum, io.NewSectionReader(r, 0, offset)); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(checksum, sum.Sum(nil)) {
+		return nil, fmt.Errorf("gitobj/pack: packfile checksum mismatch")
+	}
+
+	// Resolve the names of deltified objects, making the names of those
+	// resolved so far available to OBJ_REF_DELTA objects via an index,
+	// until no more can be resolved.
+	for {
+		w := NewIndexWriter(checksum, sum)
+
+		var unresolved int
+		for _, obj := range objects {
+			if obj.name != nil {
+				w.Add(obj.name, obj.offset, obj.crc)
+			} else {
+				unresolved++
+			}
+		}
+
+		if unresolved == 0 {
+			return w, nil
+		}
+
+		var idx bytes.Buffer
+		if _, err := w.WriteTo(&idx); err != nil {
+			return nil, err
+		}
+		if p.idx, err = DecodeIndex(bytes.NewReader(idx.Bytes()), sum); err != nil {
+			return nil, err
+		}
+
+		var resolved int
+		for _, obj := range objects {
+			if obj.name != nil {
+				continue
+			}
+
+			chain, err := p.find(obj.offset)
+			if err != nil {
+				if IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+
+			data, err := chain.Unpack()
+			if err != nil {
+				return nil, err
+			}
+
+			sum.Reset()
+			fmt.Fprintf(sum, "%s %d\x00", chain.Type(), len(data))
+			sum.Write(data)
+
+			obj.name = sum.Sum(nil)
+			resolved++
+		}
+
+		if resolved == 0 {
+			return nil, fmt.Errorf("gitobj/pack: %d object(s) have missing delta bases", unresolved)
+		}
+	}
+}
+
+// byteReaderAt reads from an io.ReaderAt beginning at a given offset, and
+// implements io.ByteReader so that a decompressor reading from it does not
+// read beyond the end of the compressed data.
+type byteReaderAt struct {
+	// r is the underlying data source.
+	r io.ReaderAt
+	// o is the offset in "r" at which "buf" begins.
+	o int64
+
+	// buf holds data read from "r" but not yet consumed.
+	buf []byte
+	// pos is the number of bytes in "buf" which have been consumed.
+	pos int
+}
+
+// Read implements io.Reader.
+func (b *byteReaderAt) Read(p []byte) (int, error) {
+	if err := b.fill(); err != nil {
+		return 0, err
+	}
+
+	n := copy(p, b.buf[b.pos:])
+	b.pos += n
+	return n, nil
+}
+
+// ReadByte implements io.ByteReader.
+func (b *byteReaderAt) ReadByte() (byte, error) {
+	if err := b.fill(); err != nil {
+		return 0, err
+	}
+
+	c := b.buf[b.pos]
+	b.pos++
+	return c, nil
+}
+
+// Offset returns the offset in the underlying data source of the next byte to
+// be read.
+func (b *byteReaderAt) Offset() int64 {
+	return b.o + int64(b.pos)
+}
+
+// fill reads more data from the underlying data source if every byte read so
+// far has been consumed.
+func (b *byteReaderAt) fill() error {
+	if b.pos < len(b.buf) {
+		return nil
+	}
+
+	b.o += int64(len(b.buf))
+	b.pos = 0
+	if b.buf == nil {
+		b.buf = make([]byte, 4096)
+	}
+	b.buf = b.buf[:cap(b.buf)]
+
+	n, err := b.r.ReadAt(b.buf, b.o)
+	b.buf = b.buf[:n]
+	if n > 0 {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	return err
+}
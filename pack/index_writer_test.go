@@ -0,0 +1,176 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexWriterWritesLargeOffsets(t *testing.T) {
+	small := objectName(TypeBlob, "small")
+	large := objectName(TypeBlob, "large")
+	checksum := bytes.Repeat([]byte{0x01}, sha1.Size)
+
+	w := NewIndexWriter(checksum, sha1.New())
+	w.Add(small, 12, 0xdecafbad)
+	w.Add(large, 1<<32+12, 0xcafebabe)
+
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), n)
+
+	idx, err := DecodeIndex(bytes.NewReader(buf.Bytes()), sha1.New())
+	require.NoError(t, err)
+
+	e, err := idx.Entry(small)
+	require.NoError(t, err)
+	assert.EqualValues(t, 12, e.PackOffset)
+
+	e, err = idx.Entry(large)
+	require.NoError(t, err)
+	assert.EqualValues(t, int64(1<<32+12), e.PackOffset)
+
+	sum, err := idx.PackChecksum()
+	require.NoError(t, err)
+	assert.Equal(t, checksum, sum)
+
+	trailer := sha1.Sum(buf.Bytes()[:buf.Len()-sha1.Size])
+	assert.Equal(t, trailer[:], buf.Bytes()[buf.Len()-sha1.Size:])
+}
+
+func TestIndexWriterRejectsDuplicateObjects(t *testing.T) {
+	name := objectName(TypeBlob, "a")
+
+	w := NewIndexWriter(make([]byte, sha1.Size), sha1.New())
+	w.Add(name, 12, 0)
+	w.Add(name, 24, 0)
+
+	_, err := w.WriteTo(new(bytes.Buffer))
+	assert.Error(t, err)
+}
+
+func TestIndexPackfileMatchesWrittenIndex(t *testing.T) {
+	var pack bytes.Buffer
+
+	w, err := NewWriter(&pack, 2, sha1.New())
+	require.NoError(t, err)
+	for _, data := range []string{"Hello, world!\n", strings.Repeat("four", 64)} {
+		err := w.Add(objectName(TypeBlob, data), TypeBlob, int64(len(data)), strings.NewReader(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	var want bytes.Buffer
+	require.NoError(t, w.WriteIndex(&want))
+
+	iw, err := IndexPackfile(bytes.NewReader(pack.Bytes()), sha1.New())
+	require.NoError(t, err)
+
+	var got bytes.Buffer
+	_, err = iw.WriteTo(&got)
+	require.NoError(t, err)
+
+	assert.Equal(t, want.Bytes(), got.Bytes())
+}
+
+func TestIndexPackfileResolvesDeltas(t *testing.T) {
+	pack, refOffset, baseOffset, ofsOffset := deltaTestPack(t)
+
+	iw, err := IndexPackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = iw.WriteTo(&buf)
+	require.NoError(t, err)
+
+	idx, err := DecodeIndex(bytes.NewReader(buf.Bytes()), sha1.New())
+	require.NoError(t, err)
+	assert.Equal(t, 3, idx.Count())
+
+	for data, offset := range map[string]int{
+		"Hello":           baseOffset,
+		"Hello, world!\n": refOffset,
+		"Hello!\n":        ofsOffset,
+	} {
+		e, err := idx.Entry(objectName(TypeBlob, data))
+		require.NoError(t, err)
+		assert.EqualValues(t, offset, e.PackOffset)
+	}
+}
+
+func TestIndexPackfileRejectsBadChecksums(t *testing.T) {
+	var pack bytes.Buffer
+
+	w, err := NewWriter(&pack, 1, sha1.New())
+	require.NoError(t, err)
+	require.NoError(t, w.Add(objectName(TypeBlob, "a"), TypeBlob, 1, strings.NewReader("a")))
+	require.NoError(t, w.Close())
+
+	data := pack.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	_, err = IndexPackfile(bytes.NewReader(data), sha1.New())
+	assert.EqualError(t, err, "gitobj/pack: packfile checksum mismatch")
+}
+
+// deltaTestPack returns a packfile holding the blob "Hello", an OBJ_REF_DELTA
+// against it (which precedes it) producing "Hello, world!\n", and an
+// OBJ_OFS_DELTA against it producing "Hello!\n", along with the offsets of
+// each of those entries.
+func deltaTestPack(t *testing.T) (pack []byte, refOffset, baseOffset, ofsOffset int) {
+	base, err := compress("Hello")
+	require.NoError(t, err)
+	world, err := compress(string([]byte{
+		0x05, // Source size: 5.
+		0x0e, // Destination size: 14.
+
+		0x91, // (1001 0001) (copy, smask=0001, omask=0001)
+		0x00, // (0000 0000) (offset=0)
+		0x05, // (0000 0101) (size=5)
+
+		0x09, // (0000 1001) (add, length=9)
+		',', ' ', 'w', 'o', 'r', 'l', 'd', '!', '\n',
+	}))
+	require.NoError(t, err)
+	bang, err := compress(string([]byte{
+		0x05, // Source size: 5.
+		0x07, // Destination size: 7.
+
+		0x91, // (1001 0001) (copy, smask=0001, omask=0001)
+		0x00, // (0000 0000) (offset=0)
+		0x05, // (0000 0101) (size=5)
+
+		0x02, // (0000 0010) (add, length=2)
+		'!', '\n',
+	}))
+	require.NoError(t, err)
+
+	pack = []byte{'P', 'A', 'C', 'K', 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0, 0x3}
+
+	// An OBJ_REF_DELTA whose base follows it.
+	refOffset = len(pack)
+	pack = append(pack, encodeEntryHeader(TypeObjectReferenceDelta, 15)...)
+	pack = append(pack, objectName(TypeBlob, "Hello")...)
+	pack = append(pack, world...)
+
+	baseOffset = len(pack)
+	pack = append(pack, encodeEntryHeader(TypeBlob, 5)...)
+	pack = append(pack, base...)
+
+	// An OBJ_OFS_DELTA against the same base.
+	ofsOffset = len(pack)
+	pack = append(pack, encodeEntryHeader(TypeObjectOffsetDelta, 8)...)
+	pack = append(pack, byte(ofsOffset-baseOffset))
+	pack = append(pack, bang...)
+
+	sum := sha1.Sum(pack)
+	pack = append(pack, sum[:]...)
+
+	return pack, refOffset, baseOffset, ofsOffset
+
+}
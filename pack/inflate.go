@@ -0,0 +1,17 @@
+package pack
+
+import (
+	"io"
+
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// newZlibReader returns an io.ReadCloser inflating the zlib-compressed data read
+// from "r". Unless "unpooled" is true, the reader is drawn from a pool, to
+// which it is returned once closed.
+func newZlibReader(r io.Reader, unpooled bool) (io.ReadCloser, error) {
+	if unpooled {
+		return storage.UnpooledZlib.NewReader(r)
+	}
+	return storage.Zlib.NewReader(r)
+}
@@ -0,0 +1,151 @@
+package pack
+
+import (
+	"bufio"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	// infoPackRe is a regular expression that matches the name of a
+	// packfile listed in an "objects/info/packs" file.
+	infoPackRe = regexp.MustCompile(`^pack-[0-9a-f]+\.pack$`)
+)
+
+// File is an io.ReaderAt which must be closed once it is no longer
+// needed, such as a packfile, or its index, read from a remote source.
+type File interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// PackOpener opens the packfile or index with the given name (such as
+// "pack-<hash>.pack" or "pack-<hash>.idx") from a source of packfiles other
+// than the filesystem, such as a mirror served over HTTP which lists its
+// packfiles in "objects/info/packs" (see: NewSetFromInfoPacks).
+//
+// Packfiles are read only as objects are read from them, so a source able to
+// read a range of a file, such as an HTTP server accepting "Range" requests,
+// need not fetch the whole of a packfile which is opened.
+type PackOpener func(name string) (File, error)
+
+// ParseInfoPacks parses an "objects/info/packs" file, as written by "git
+// update-server-info" for the benefit of the "dumb" HTTP transport, and
+// returns the names of the packfiles it lists, in the order in which it lists
+// them. As in Git, lines other than those listing packfiles ("P <name>") are
+// ignored.
+func ParseInfoPacks(r io.Reader) ([]string, error) {
+	var names []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "P ") {
+			continue
+		}
+
+		name := strings.TrimSpace(line[2:])
+		if !infoPackRe.MatchString(name) {
+			return nil, fmt.Errorf("gitobj/pack: invalid packfile name in info/packs: %q", name)
+		}
+		names = append(names, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// NewSetFromInfoPacks creates a new *Set of each packfile listed by the
+// "objects/info/packs" file read from "r" (see: ParseInfoPacks), whose files
+// (and those of their indexes) are opened with "open", as NewSetFromNames
+// does.
+func NewSetFromInfoPacks(r io.Reader, open PackOpener, algo hash.Hash) (*Set, error) {
+	names, err := ParseInfoPacks(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewSetFromNames(names, open, algo)
+}
+
+// NewSetFromNames creates a new *Set of the packfiles with the given names
+// (such as "pack-<hash>.pack"), whose files (and those of their indexes) are
+// opened with "open", rather than being found by listing a pack directory, as
+// for a source of packfiles which cannot be listed.
+//
+// The index of each packfile is opened, and its header read, before the set is
+// returned, but each object's entry is read only when the object is first read.
+// Since the set has no pack directory, it is never rescanned, and the packfiles
+// are never promisor packfiles.
+//
+// Each name must be that of a packfile itself, as ParseInfoPacks requires, so
+// that no file outside of the source's pack directory is opened.
+//
+// If a packfile cannot be opened, those already opened are closed, and the
+// error is returned.
+func NewSetFromNames(names []string, open PackOpener, algo hash.Hash) (*Set, error) {
+	for _, name := range names {
+		if !infoPackRe.MatchString(name) {
+			return nil, fmt.Errorf("gitobj/pack: invalid packfile name %q", name)
+		}
+	}
+
+	packs := make([]*Packfile, 0, len(names))
+	for _, name := range names {
+		pack, err := openListedPack(name, open, algo)
+		if err != nil {
+			for _, p := range packs {
+				p.Close()
+			}
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return NewSetPacks(packs...), nil
+}
+
+// NewStorageFromInfoPacks returns a new storage object based on a pack set
+// created by NewSetFromInfoPacks.
+func NewStorageFromInfoPacks(r io.Reader, open PackOpener, algo hash.Hash) (*Storage, error) {
+	packs, err := NewSetFromInfoPacks(r, open, algo)
+	if err != nil {
+		return nil, err
+	}
+	return &Storage{packs: packs}, nil
+}
+
+// openListedPack opens the packfile with the given name, and its index, with
+// "open".
+func openListedPack(name string, open PackOpener, algo hash.Hash) (*Packfile, error) {
+	idxf, err := open(strings.TrimSuffix(name, ".pack") + ".idx")
+	if err != nil {
+		return nil, err
+	}
+
+	packf, err := open(name)
+	if err != nil {
+		idxf.Close()
+		return nil, err
+	}
+
+	pack, err := DecodePackfile(packf, algo)
+	if err != nil {
+		packf.Close()
+		idxf.Close()
+		return nil, err
+	}
+
+	idx, err := DecodeIndex(idxf, algo)
+	if err != nil {
+		packf.Close()
+		idxf.Close()
+		return nil, err
+	}
+
+	pack.idx = idx
+	pack.path = name
+	return pack, nil
+}
@@ -0,0 +1,106 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInfoPacks(t *testing.T) {
+	names, err := ParseInfoPacks(strings.NewReader(
+		"P pack-1234abcd.pack\n" +
+			"D pack-ffff.pack\n" +
+			"\n" +
+			"P pack-5678.pack\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pack-1234abcd.pack", "pack-5678.pack"}, names)
+}
+
+func TestParseInfoPacksRejectsInvalidNames(t *testing.T) {
+	_, err := ParseInfoPacks(strings.NewReader("P ../pack-1234.pack\n"))
+
+	assert.EqualError(t, err, `gitobj/pack: invalid packfile name in info/packs: "../pack-1234.pack"`)
+}
+
+func TestNewSetFromInfoPacks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-info-packs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	idx, name := writeTestPack(t, dir, "Hello, world!\n")
+	listing := fmt.Sprintf("P %s.pack\n", strings.TrimSuffix(idx, ".idx"))
+
+	var opened []string
+	set, err := NewSetFromInfoPacks(strings.NewReader(listing), func(name string) (File, error) {
+		opened = append(opened, name)
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		return &nopFile{bytes.NewReader(data)}, nil
+	}, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	assert.Len(t, opened, 2)
+
+	o, err := set.Object(name)
+	require.NoError(t, err)
+
+	data, err := o.Unpack()
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(data))
+}
+
+func TestNewSetFromNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-info-packs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	idxA, a := writeTestPack(t, dir, "a\n")
+	idxB, b := writeTestPack(t, dir, "b\n")
+
+	open := func(name string) (File, error) {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		return &nopFile{bytes.NewReader(data)}, nil
+	}
+
+	// Only the packfiles named are read, whatever the directory holds.
+	set, err := NewSetFromNames([]string{strings.TrimSuffix(idxA, ".idx") + ".pack"}, open, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	has, err := set.Has(a)
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	has, err = set.Has(b)
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	_, err = NewSetFromNames([]string{idxB}, open, sha1.New())
+	assert.EqualError(t, err, fmt.Sprintf("gitobj/pack: invalid packfile name %q", idxB))
+
+	_, err = NewSetFromNames([]string{"../pack-1234.pack"}, open, sha1.New())
+	assert.EqualError(t, err, `gitobj/pack: invalid packfile name "../pack-1234.pack"`)
+}
+
+// nopFile is a File whose Close method does nothing.
+type nopFile struct {
+	*bytes.Reader
+}
+
+func (r *nopFile) Close() error { return nil }
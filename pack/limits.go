@@ -0,0 +1,61 @@
+package pack
+
+const (
+	// DefaultMaxDeltaDepth is the greatest number of deltas applied in
+	// reconstructing an object unless another limit is given (see:
+	// Limits). It matches the greatest delta depth with which Git writes
+	// packfiles, and guards against delta-base chains which are cyclic.
+	DefaultMaxDeltaDepth = 4095
+)
+
+// Limits bounds the work done, and the memory allocated, in reading objects from
+// packfiles which may have been crafted to exhaust either, such as those
+// received from an untrusted source (see: Set.SetLimits).
+type Limits struct {
+	// MaxDeltaDepth is the greatest number of deltas which may be applied
+	// in reconstructing an object, beyond which a *DeltaDepthExceededErr is
+	// returned. If it is not positive, DefaultMaxDeltaDepth is used.
+	MaxDeltaDepth int
+	// MaxObjectSize is the greatest size of an object (or of a delta base,
+	// or of the instructions of a delta) which may be unpacked, beyond
+	// which an *ObjectTooLargeErr is returned. It is checked against the
+	// size declared by the entry's header, or by the delta's instructions,
+	// before any memory is allocated to hold its contents. If it is not
+	// positive, the size of objects is not limited.
+	MaxObjectSize int64
+}
+
+// maxDeltaDepth returns the greatest number of deltas which may be applied in
+// reconstructing an object.
+func (l Limits) maxDeltaDepth() int {
+	if l.MaxDeltaDepth > 0 {
+		return l.MaxDeltaDepth
+	}
+	return DefaultMaxDeltaDepth
+}
+
+// checkDepth returns a *DeltaDepthExceededErr if the entry of "p" at the given
+// offset is found at a greater delta depth than is allowed.
+func (p *Packfile) checkDepth(offset int64, depth int) error {
+	if max := p.limits.maxDeltaDepth(); depth > max {
+		return &DeltaDepthExceededErr{Name: p.path, Offset: offset, Limit: max}
+	}
+	return nil
+}
+
+// checkSize returns an *ObjectTooLargeErr if the entry of "p" at the given
+// offset declares a greater size than is allowed.
+func (p *Packfile) checkSize(offset int64, size uint64) error {
+	max := p.limits.MaxObjectSize
+	if max > 0 && size > uint64(max) {
+		return &ObjectTooLargeErr{Name: p.path, Offset: offset, Size: size, Limit: max}
+	}
+	if size > maxInt64 {
+		return &CorruptPackErr{Name: p.path, Offset: offset,
+			Reason: "object size out of range"}
+	}
+	return nil
+}
+
+// maxInt64 is the greatest size which may be held in an int64.
+const maxInt64 = 1<<63 - 1
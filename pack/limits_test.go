@@ -0,0 +1,125 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackObjectExceedingMaxObjectSize(t *testing.T) {
+	compressed, err := compress("Hello, world!\n")
+	require.NoError(t, err)
+
+	p := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"cccccccccccccccccccccccccccccccccccccccc": 12,
+		}),
+		r: bytes.NewReader(append([]byte{
+			'P', 'A', 'C', 'K', 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0, 0x1,
+
+			// (0001 1110) (msb=0, type=commit, size=14)
+			0x1e}, compressed...),
+		),
+		hash:   sha1.New(),
+		limits: Limits{MaxObjectSize: 13},
+	}
+
+	o, err := p.Object(DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"))
+	assert.Nil(t, o)
+	require.IsType(t, &ObjectTooLargeErr{}, err)
+	assert.Equal(t, &ObjectTooLargeErr{Offset: 12, Size: 14, Limit: 13}, err)
+
+	p.limits.MaxObjectSize = 14
+
+	o, err = p.Object(DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"))
+	require.NoError(t, err)
+
+	unpacked, err := o.Unpack()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Hello, world!\n"), unpacked)
+}
+
+func TestPackObjectWithCyclicDeltaChain(t *testing.T) {
+	a := DecodeHex(t, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	b := DecodeHex(t, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+
+	// Each object is stored as an OBJ_REF_DELTA of the other.
+	data := []byte{'P', 'A', 'C', 'K', 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0, 0x2}
+	data = append(append(data, 0x71), b...)
+	data = append(append(data, 0x71), a...)
+
+	p := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa": 12,
+			"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb": 33,
+		}),
+		r:      bytes.NewReader(data),
+		hash:   sha1.New(),
+		limits: Limits{MaxDeltaDepth: 8},
+	}
+
+	_, err := p.Object(a)
+	require.IsType(t, &DeltaDepthExceededErr{}, err)
+	assert.Equal(t, 8, err.(*DeltaDepthExceededErr).Limit)
+
+	_, err = p.typeAt(12)
+	assert.IsType(t, &DeltaDepthExceededErr{}, err)
+}
+
+func TestPackObjectWithInvalidBaseOffset(t *testing.T) {
+	p := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"cccccccccccccccccccccccccccccccccccccccc": 12,
+		}),
+		r: bytes.NewReader(append([]byte{
+			'P', 'A', 'C', 'K', 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0, 0x1,
+
+			0x61, // (0110 0001) (msb=0, type=obj_ofs_delta, size=1)
+			0x00, // (0000 0000) (ofs_delta=0)
+		}, make([]byte, 20)...)),
+		hash: sha1.New(),
+	}
+
+	_, err := p.Object(DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"))
+	assert.Equal(t, &CorruptPackErr{Offset: 12, Reason: "base offset out of range"}, err)
+}
+
+func TestPackObjectWithOffsetOutsidePackfile(t *testing.T) {
+	p := &Packfile{
+		idx: IndexWith(map[string]uint32{
+			"cccccccccccccccccccccccccccccccccccccccc": 100,
+		}),
+		r:    bytes.NewReader([]byte{'P', 'A', 'C', 'K', 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0, 0x1}),
+		hash: sha1.New(),
+	}
+
+	_, err := p.Object(DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"))
+	assert.Equal(t, &CorruptPackErr{Offset: 100, Reason: "entry offset out of range"}, err)
+}
+
+func TestPatchWithTruncatedInstructions(t *testing.T) {
+	for _, delta := range [][]byte{
+		{},
+		{0x80},
+		{0x4, 0x3, 0x80 | 0x01 | 0x10, 0x1},
+		{0x4, 0x3, 0x80 | 0x01 | 0x10, 0x1, 0x7},
+		{0x4, 0x3, 0x3, 0x1},
+	} {
+		data, err := patch([]byte{0x0, 0x1, 0x2, 0x3}, delta)
+		assert.Equal(t, errInvalidDelta, err, "delta %x", delta)
+		assert.Nil(t, data)
+	}
+}
+
+func TestSetSetLimitsAppliesToAddedPacks(t *testing.T) {
+	s := NewSetPacks(&Packfile{idx: IndexWith(nil)})
+	s.SetLimits(Limits{MaxDeltaDepth: 2})
+	s.Add(&Packfile{idx: IndexWith(nil)})
+
+	for _, p := range s.Packs() {
+		assert.Equal(t, Limits{MaxDeltaDepth: 2}, p.limits)
+	}
+}
@@ -0,0 +1,239 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// midxHeaderWidth is the width of the header of a multi-pack-index.
+	midxHeaderWidth = 12
+	// midxChunkEntryWidth is the width of each entry in the table of
+	// chunks following the header.
+	midxChunkEntryWidth = 12
+	// midxObjectOffsetWidth is the width of each entry in the table of
+	// object offsets, which gives the pack and offset of each object.
+	midxObjectOffsetWidth = 8
+	// midxLargeOffsetWidth is the width of each entry in the optional table
+	// of large offsets.
+	midxLargeOffsetWidth = 8
+)
+
+var (
+	// midxHeader is the first four "magic" bytes of a multi-pack-index.
+	midxHeader = []byte("MIDX")
+
+	// midxChunkPackNames, midxChunkFanout, midxChunkLookup,
+	// midxChunkOffsets and midxChunkLargeOffsets are the identifiers of the
+	// chunks which a multi-pack-index may contain and which are understood
+	// by this package. Other chunks are ignored.
+	midxChunkPackNames    = []byte("PNAM")
+	midxChunkFanout       = []byte("OIDF")
+	midxChunkLookup       = []byte("OIDL")
+	midxChunkOffsets      = []byte("OOFF")
+	midxChunkLargeOffsets = []byte("LOFF")
+)
+
+// MultiPackIndex is a single index ("multi-pack-index") of the objects stored
+// across several packfiles in the same directory, which allows an object to be
+// located with a single search, rather than one search per packfile.
+type MultiPackIndex struct {
+	// packs holds the names of the index (".idx") files of the packfiles
+	// covered by this multi-pack-index, in the order in which they are
+	// identified by the table of object offsets.
+	packs []string
+	// fanout is the fanout table of object names, as in Index.fanout.
+	fanout []uint32
+
+	// hashlen is the length of the object names in this index.
+	hashlen int64
+	// lookup, offsets, and largeOffsets are the positions at which the
+	// respective chunks begin. largeOffsets is zero if there is no table
+	// of large offsets.
+	lookup, offsets, largeOffsets int64
+
+	// r is the underlying set of encoded data comprising this index.
+	r io.ReaderAt
+}
+
+// DecodeMultiPackIndex decodes a multi-pack-index whose underlying data is
+// supplied by "r". "hash" is an instance of the hash algorithm used to name
+// objects in the covered packfiles; a multi-pack-index written for a different
+// algorithm is rejected.
+//
+// Like DecodeIndex, it reads only the header, the table of chunks, the names of
+// the covered packfiles, and the fanout table.
+func DecodeMultiPackIndex(r io.ReaderAt, hash hash.Hash) (*MultiPackIndex, error) {
+	var hdr [midxHeaderWidth]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(hdr[:4], midxHeader) {
+		return nil, fmt.Errorf("gitobj/pack: invalid multi-pack-index header")
+	}
+	if hdr[4] != 1 {
+		return nil, &UnsupportedVersionErr{Got: uint32(hdr[4])}
+	}
+	if algo := HashVersion(hash); hdr[5] != algo {
+		return nil, fmt.Errorf("gitobj/pack: multi-pack-index has hash version %d, expected %d", hdr[5], algo)
+	}
+	if hdr[7] != 0 {
+		return nil, fmt.Errorf("gitobj/pack: unsupported incremental multi-pack-index")
+	}
+
+	chunks := make([]byte, (int(hdr[6])+1)*midxChunkEntryWidth)
+	if _, err := r.ReadAt(chunks, midxHeaderWidth); err != nil {
+		return nil, err
+	}
+
+	m := &MultiPackIndex{
+		hashlen: int64(hash.Size()),
+		r:       r,
+	}
+
+	var names, fanout []byte
+	for i := 0; i < int(hdr[6]); i++ {
+		entry := chunks[i*midxChunkEntryWidth:]
+
+		id := entry[:4]
+		start := int64(binary.BigEndian.Uint64(entry[4:]))
+		end := int64(binary.BigEndian.Uint64(entry[midxChunkEntryWidth+4:]))
+		if end < start {
+			return nil, fmt.Errorf("gitobj/pack: invalid multi-pack-index chunk %q", id)
+		}
+
+		switch {
+		case bytes.Equal(id, midxChunkPackNames):
+			// The chunk is read as far as the file extends,
+			// rather than allocated in full, in case its extent is
+			// corrupt.
+			var err error
+			names, err = ioutil.ReadAll(io.NewSectionReader(r, start, end-start))
+			if err != nil {
+				return nil, err
+			}
+			if int64(len(names)) != end-start {
+				return nil, fmt.Errorf("gitobj/pack: invalid multi-pack-index chunk %q", id)
+			}
+		case bytes.Equal(id, midxChunkFanout):
+			fanout = make([]byte, indexFanoutWidth)
+			if _, err := r.ReadAt(fanout, start); err != nil {
+				return nil, err
+			}
+		case bytes.Equal(id, midxChunkLookup):
+			m.lookup = start
+		case bytes.Equal(id, midxChunkOffsets):
+			m.offsets = start
+		case bytes.Equal(id, midxChunkLargeOffsets):
+			m.largeOffsets = start
+		}
+	}
+
+	if names == nil || fanout == nil || m.lookup == 0 || m.offsets == 0 {
+		return nil, fmt.Errorf("gitobj/pack: multi-pack-index is missing a required chunk")
+	}
+
+	m.packs = strings.Split(strings.TrimRight(string(names), "\x00"), "\x00")
+	if n := binary.BigEndian.Uint32(hdr[8:]); n != uint32(len(m.packs)) {
+		return nil, fmt.Errorf("gitobj/pack: multi-pack-index names %d pack(s), expected %d", len(m.packs), n)
+	}
+
+	m.fanout = make([]uint32, indexFanoutEntries)
+	for i := range m.fanout {
+		m.fanout[i] = binary.BigEndian.Uint32(fanout[i*indexFanoutEntryWidth:])
+		if i > 0 && m.fanout[i] < m.fanout[i-1] {
+			return nil, fmt.Errorf("gitobj/pack: multi-pack-index has non-monotonic fanout table at entry %d", i)
+		}
+	}
+
+	return m, nil
+}
+
+// Packs returns the names of the index (".idx") files of the packfiles covered
+// by this multi-pack-index, such as "pack-<checksum>.idx".
+func (m *MultiPackIndex) Packs() []string {
+	return m.packs
+}
+
+// Count returns the number of objects in this multi-pack-index.
+func (m *MultiPackIndex) Count() int {
+	return int(m.fanout[255])
+}
+
+// Close closes the multi-pack-index if the underlying data stream is
+// closeable. If so, it returns any error involved in closing.
+func (m *MultiPackIndex) Close() error {
+	if close, ok := m.r.(io.Closer); ok {
+		return close.Close()
+	}
+	return nil
+}
+
+// Entry returns the position in Packs() of the packfile which holds the object
+// named "name", along with the offset of that object in the packfile.
+//
+// If the object cannot be found, errNotFound is returned (see: IsNotFound).
+func (m *MultiPackIndex) Entry(name []byte) (int, int64, error) {
+	if int64(len(name)) != m.hashlen {
+		return 0, 0, errNotFound
+	}
+
+	var left, right int64
+	if name[0] > 0 {
+		left = int64(m.fanout[name[0]-1])
+	}
+	right = int64(m.fanout[name[0]])
+
+	got := make([]byte, m.hashlen)
+	for left < right {
+		mid := left + ((right - left) / 2)
+
+		if _, err := m.r.ReadAt(got, m.lookup+(mid*m.hashlen)); err != nil {
+			return 0, 0, err
+		}
+
+		if cmp := bytes.Compare(name, got); cmp == 0 {
+			return m.entry(mid)
+		} else if cmp < 0 {
+			right = mid
+		} else {
+			left = mid + 1
+		}
+	}
+	return 0, 0, errNotFound
+}
+
+// entry returns the pack and offset of the object at position "at" in the
+// table of object offsets.
+func (m *MultiPackIndex) entry(at int64) (int, int64, error) {
+	var buf [midxObjectOffsetWidth]byte
+	if _, err := m.r.ReadAt(buf[:], m.offsets+(at*midxObjectOffsetWidth)); err != nil {
+		return 0, 0, err
+	}
+
+	pack := binary.BigEndian.Uint32(buf[:4])
+	if pack >= uint32(len(m.packs)) {
+		return 0, 0, fmt.Errorf("gitobj/pack: multi-pack-index refers to unknown pack %d", pack)
+	}
+
+	offset := binary.BigEndian.Uint32(buf[4:])
+	if offset&0x80000000 == 0 {
+		return int(pack), int64(offset), nil
+	}
+
+	if m.largeOffsets == 0 {
+		return 0, 0, fmt.Errorf("gitobj/pack: multi-pack-index is missing large offsets")
+	}
+
+	at = int64(offset & 0x7fffffff)
+	if _, err := m.r.ReadAt(buf[:], m.largeOffsets+(at*midxLargeOffsetWidth)); err != nil {
+		return 0, 0, err
+	}
+	return int(pack), int64(binary.BigEndian.Uint64(buf[:])), nil
+}
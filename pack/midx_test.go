@@ -0,0 +1,219 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiPackIndexEntry(t *testing.T) {
+	midx, err := DecodeMultiPackIndex(bytes.NewReader(multiPackIndexWith(
+		[]string{"pack-a.idx", "pack-b.idx"},
+		[]*midxTestEntry{
+			{DecodeHex(t, "aa00000000000000000000000000000000000000"), 0, 12},
+			{DecodeHex(t, "bb00000000000000000000000000000000000000"), 1, 34},
+			{DecodeHex(t, "bb11111111111111111111111111111111111111"), 0, 1 << 33},
+		},
+	)), sha1.New())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"pack-a.idx", "pack-b.idx"}, midx.Packs())
+	assert.Equal(t, 3, midx.Count())
+
+	pack, offset, err := midx.Entry(DecodeHex(t, "bb00000000000000000000000000000000000000"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pack)
+	assert.EqualValues(t, 34, offset)
+
+	pack, offset, err = midx.Entry(DecodeHex(t, "bb11111111111111111111111111111111111111"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pack)
+	assert.EqualValues(t, int64(1<<33), offset)
+
+	_, _, err = midx.Entry(DecodeHex(t, "bb22222222222222222222222222222222222222"))
+	assert.True(t, IsNotFound(err))
+}
+
+func TestDecodeMultiPackIndexRejectsOtherHashes(t *testing.T) {
+	_, err := DecodeMultiPackIndex(bytes.NewReader(multiPackIndexWith(
+		[]string{"pack-a.idx"}, nil,
+	)), sha256.New())
+
+	assert.EqualError(t, err, "gitobj/pack: multi-pack-index has hash version 1, expected 2")
+}
+
+func TestSetUsesMultiPackIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-midx")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	a, aName := writeTestPack(t, pd, "a\n")
+	b, bName := writeTestPack(t, pd, "b\n")
+	_, cName := writeTestPack(t, pd, "c\n")
+
+	packs := []string{a, b}
+	sort.Strings(packs)
+	entries := []*midxTestEntry{
+		{aName, uint32(sort.SearchStrings(packs, a)), 12},
+		{bName, uint32(sort.SearchStrings(packs, b)), 12},
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].name, entries[j].name) < 0
+	})
+
+	err = ioutil.WriteFile(filepath.Join(pd, "multi-pack-index"),
+		multiPackIndexWith(packs, entries), 0644)
+	require.NoError(t, err)
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	require.NotNil(t, set.midx)
+	assert.Len(t, set.midxPacks, 2)
+	assert.Len(t, set.uncovered(), 1)
+
+	for name, data := range map[string][]byte{
+		string(aName): []byte("a\n"),
+		string(bName): []byte("b\n"),
+		string(cName): []byte("c\n"),
+	} {
+		o, err := set.Object([]byte(name))
+		require.NoError(t, err)
+
+		unpacked, err := o.Unpack()
+		require.NoError(t, err)
+		assert.Equal(t, data, unpacked)
+	}
+}
+
+func TestSetIgnoresMultiPackIndexWithMissingPacks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-midx")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	a, aName := writeTestPack(t, pd, "a\n")
+
+	err = ioutil.WriteFile(filepath.Join(pd, "multi-pack-index"),
+		multiPackIndexWith([]string{a, "pack-missing.idx"}, []*midxTestEntry{
+			{aName, 0, 12},
+		}), 0644)
+	require.NoError(t, err)
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	assert.Nil(t, set.midx)
+
+	o, err := set.Object(aName)
+	require.NoError(t, err)
+	assert.Equal(t, TypeBlob, o.Type())
+}
+
+// midxTestEntry is an object in a multi-pack-index written by
+// multiPackIndexWith.
+type midxTestEntry struct {
+	name   []byte
+	pack   uint32
+	offset int64
+}
+
+// multiPackIndexWith returns the contents of a multi-pack-index covering the
+// given packs, holding the given entries, which must be sorted by name.
+func multiPackIndexWith(packs []string, entries []*midxTestEntry) []byte {
+	var names, fanout, lookup, offsets, large bytes.Buffer
+
+	names.WriteString(strings.Join(packs, "\x00") + "\x00")
+	for names.Len()%4 != 0 {
+		names.WriteByte(0)
+	}
+
+	var counts [indexFanoutEntries]uint32
+	for _, e := range entries {
+		for i := int(e.name[0]); i < len(counts); i++ {
+			counts[i]++
+		}
+
+		lookup.Write(e.name)
+
+		binary.Write(&offsets, binary.BigEndian, e.pack)
+		if e.offset <= maxSmallOffset {
+			binary.Write(&offsets, binary.BigEndian, uint32(e.offset))
+		} else {
+			binary.Write(&offsets, binary.BigEndian, uint32(large.Len()/8)|0x80000000)
+			binary.Write(&large, binary.BigEndian, uint64(e.offset))
+		}
+	}
+	binary.Write(&fanout, binary.BigEndian, counts[:])
+
+	chunks := []struct {
+		id   []byte
+		data []byte
+	}{
+		{midxChunkPackNames, names.Bytes()},
+		{midxChunkFanout, fanout.Bytes()},
+		{midxChunkLookup, lookup.Bytes()},
+		{midxChunkOffsets, offsets.Bytes()},
+		{midxChunkLargeOffsets, large.Bytes()},
+	}
+
+	var buf bytes.Buffer
+	buf.Write(midxHeader)
+	buf.Write([]byte{1, 1, byte(len(chunks)), 0})
+	binary.Write(&buf, binary.BigEndian, uint32(len(packs)))
+
+	offset := uint64(midxHeaderWidth + (len(chunks)+1)*midxChunkEntryWidth)
+	for _, c := range chunks {
+		buf.Write(c.id)
+		binary.Write(&buf, binary.BigEndian, offset)
+		offset += uint64(len(c.data))
+	}
+	buf.Write([]byte{0, 0, 0, 0})
+	binary.Write(&buf, binary.BigEndian, offset)
+
+	for _, c := range chunks {
+		buf.Write(c.data)
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+	return append(buf.Bytes(), sum[:]...)
+}
+
+// writeTestPack writes a packfile and index holding a single blob with the
+// given contents into the directory "pd", and returns the base name of the
+// index, along with the name of the blob.
+func writeTestPack(t *testing.T, pd, data string) (string, []byte) {
+	var pack, idx bytes.Buffer
+
+	w, err := NewWriter(&pack, 1, sha1.New())
+	require.NoError(t, err)
+
+	name := objectName(TypeBlob, data)
+	require.NoError(t, w.Add(name, TypeBlob, int64(len(data)), strings.NewReader(data)))
+	require.NoError(t, w.Close())
+	require.NoError(t, w.WriteIndex(&idx))
+
+	base := filepath.Join(pd, fmt.Sprintf("pack-%x", w.Checksum()))
+	require.NoError(t, ioutil.WriteFile(base+".pack", pack.Bytes(), 0644))
+	require.NoError(t, ioutil.WriteFile(base+".idx", idx.Bytes(), 0644))
+
+	return filepath.Base(base) + ".idx", name
+}
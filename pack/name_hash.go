@@ -0,0 +1,115 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// NameHash returns the hash of the path "path" at which an object was found, as
+// computed by Git when choosing which objects to delta against one another.
+//
+// The hash is dominated by the last characters of the path, so that objects
+// with the same name (or extension) in different directories have similar
+// hashes, and sort near one another when ordered by hash.
+func NameHash(path string) uint32 {
+	var hash uint32
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		hash = (hash >> 2) + (uint32(c) << 24)
+	}
+	return hash
+}
+
+// NameHashes returns the name hash (see: NameHash) of each object in the
+// packfile whose hash is recorded in the reachability bitmap file beside it
+// (as written by a *BitmapWriter, or by Git), keyed by the object's name.
+//
+// If the bitmap file does not record any name hashes, NameHashes returns nil.
+//
+// The hashes are only persisted and read back here, for use by tools which
+// choose deltas, such as "git repack"; the *Writer does not write deltas, and
+// so does not consult them.
+func (p *Packfile) NameHashes() (map[string]uint32, error) {
+	if p.idx == nil || len(p.path) == 0 {
+		return nil, fmt.Errorf("gitobj/pack: cannot read name hashes without bitmap")
+	}
+
+	f, err := os.Open(strings.TrimSuffix(p.path, ".pack") + ".bitmap")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readNameHashes(f, p.idx)
+}
+
+// readNameHashes reads the name hashes recorded in the bitmap file "r" of the
+// packfile indexed by "idx".
+func readNameHashes(r io.ReaderAt, idx *Index) (map[string]uint32, error) {
+	checksum, err := idx.PackChecksum()
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := make([]byte, 12+len(checksum))
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr[:4], bitmapHeader) {
+		return nil, fmt.Errorf("gitobj/pack: invalid bitmap header")
+	}
+	if v := binary.BigEndian.Uint16(hdr[4:]); v != bitmapVersion {
+		return nil, fmt.Errorf("gitobj/pack: unsupported bitmap version %d", v)
+	}
+	if !bytes.Equal(hdr[12:], checksum) {
+		return nil, fmt.Errorf("gitobj/pack: bitmap does not match packfile %x", checksum)
+	}
+
+	if binary.BigEndian.Uint16(hdr[6:])&bitmapOptHashCache == 0 {
+		return nil, nil
+	}
+
+	// Skip the type bitmaps, followed by each commit's bitmap (and the
+	// six bytes which precede it), to reach the hash cache.
+	at := int64(len(hdr))
+	entries := int(binary.BigEndian.Uint32(hdr[8:]))
+	for i := 0; i < 4+entries; i++ {
+		if i >= 4 {
+			at += bitmapEntryHeaderWidth
+		}
+
+		var err error
+		if at, err = skipEWAH(r, at); err != nil {
+			return nil, err
+		}
+	}
+
+	total := idx.count()
+	buf := make([]byte, 4*total)
+	if _, err := r.ReadAt(buf, at); err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]uint32, total)
+	for i := int64(0); i < total; i++ {
+		hash := binary.BigEndian.Uint32(buf[4*i:])
+		if hash == 0 {
+			continue
+		}
+
+		name, _, err := idx.entryAt(i)
+		if err != nil {
+			return nil, err
+		}
+		hashes[string(name)] = hash
+	}
+	return hashes, nil
+}
@@ -0,0 +1,83 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameHash(t *testing.T) {
+	assert.EqualValues(t, 0, NameHash(""))
+	assert.EqualValues(t, 0x61000000, NameHash("a"))
+	assert.EqualValues(t, 0x7a400000, NameHash("ab"))
+}
+
+func TestNameHashIgnoresWhitespace(t *testing.T) {
+	assert.Equal(t, NameHash("ab"), NameHash(" a\tb\r\n"))
+}
+
+func TestBitmapWriterWritesNameHashes(t *testing.T) {
+	p := packWithObjects(t, []packedTestObject{
+		{"cccccccccccccccccccccccccccccccccccccccc", TypeCommit, "commit 1"},
+		{"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", TypeTree, "tree 1"},
+		{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", TypeBlob, "blob 1"},
+	})
+
+	w, err := NewBitmapWriter(p, sha1.New())
+	require.NoError(t, err)
+
+	require.NoError(t, w.Add(
+		DecodeHex(t, "cccccccccccccccccccccccccccccccccccccccc"),
+		[][]byte{
+			DecodeHex(t, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+			DecodeHex(t, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		}))
+	require.NoError(t, w.SetNameHash(
+		DecodeHex(t, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"), NameHash("a.txt")))
+	require.NoError(t, w.SetNameHash(
+		DecodeHex(t, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"), NameHash("sub")))
+
+	var buf bytes.Buffer
+	_, err = w.WriteTo(&buf)
+	require.NoError(t, err)
+
+	data := buf.Bytes()
+	assert.EqualValues(t, bitmapOptFullDAG|bitmapOptHashCache, binary.BigEndian.Uint16(data[6:]))
+
+	// The hashes precede the trailing checksum, in index order: a, b, c.
+	hashes := data[len(data)-20-12 : len(data)-20]
+	assert.EqualValues(t, NameHash("a.txt"), binary.BigEndian.Uint32(hashes[0:]))
+	assert.EqualValues(t, NameHash("sub"), binary.BigEndian.Uint32(hashes[4:]))
+	assert.EqualValues(t, 0, binary.BigEndian.Uint32(hashes[8:]))
+
+	got, err := readNameHashes(bytes.NewReader(data), p.idx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint32{
+		string(DecodeHex(t, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")): NameHash("a.txt"),
+		string(DecodeHex(t, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")): NameHash("sub"),
+	}, got)
+}
+
+func TestBitmapWriterOmitsMissingNameHashes(t *testing.T) {
+	p := packWithObjects(t, []packedTestObject{
+		{"cccccccccccccccccccccccccccccccccccccccc", TypeCommit, "commit 1"},
+	})
+
+	w, err := NewBitmapWriter(p, sha1.New())
+	require.NoError(t, err)
+
+	err = w.SetNameHash(DecodeHex(t, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"), 1)
+	assert.EqualError(t, err, "gitobj/pack: object aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa not in pack")
+
+	var buf bytes.Buffer
+	_, err = w.WriteTo(&buf)
+	require.NoError(t, err)
+
+	got, err := readNameHashes(bytes.NewReader(buf.Bytes()), p.idx)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
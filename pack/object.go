@@ -1,5 +1,13 @@
 package pack
 
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
 // Object is an encapsulation of an object found in a packfile, or a packed
 // object.
 type Object struct {
@@ -20,8 +28,279 @@ func (o *Object) Unpack() ([]byte, error) {
 	return o.data.Unpack()
 }
 
+// UnpackContext is as Unpack, but abandons resolving the delta-base chain, and
+// returns the context's error, once the given context is done.
+//
+// If the context carries a *Budget (see: WithBudget), the memory allocated
+// while resolving the chain is reserved from it, and released once the
+// object's contents are returned.
+func (o *Object) UnpackContext(ctx context.Context) ([]byte, error) {
+	data, err := unpackChain(ctx, o.data)
+	if err != nil {
+		return nil, err
+	}
+
+	BudgetFromContext(ctx).Release(int64(len(data)))
+	return data, nil
+}
+
+// unpackChain resolves the given delta-base chain, stopping once the given
+// context is done.
+//
+// The contents of each element used as the base of a delta are added to the
+// delta base cache of the packfile holding it, if it has one, so that other
+// chains sharing those elements need not resolve them again.
+//
+// If the context carries a *Budget, memory is reserved from it before the
+// contents of each element are allocated, and released once they are no longer
+// needed. The contents returned remain reserved, and it is the caller's
+// responsibility to release them. If it carries a *Stats, the length of the
+// chain is recorded in it.
+func unpackChain(ctx context.Context, chain Chain) ([]byte, error) {
+	budget := BudgetFromContext(ctx)
+
+	var deltas []*ChainDelta
+	var instructions int64
+	for {
+		delta, ok := chain.(*ChainDelta)
+		if !ok {
+			break
+		}
+		deltas = append(deltas, delta)
+		instructions += int64(len(delta.delta))
+		chain = delta.base
+	}
+
+	// The delta instructions were loaded along with the chain, but are
+	// held until it has been resolved.
+	if err := budget.Reserve(instructions); err != nil {
+		return nil, err
+	}
+	defer budget.Release(instructions)
+
+	// held is the number of bytes reserved for "data".
+	var held int64
+
+	var data []byte
+	var err error
+	switch base := chain.(type) {
+	case *ChainBase:
+		if err = budget.Reserve(base.size); err == nil {
+			held = base.size
+			if data, err = base.unpack(ctx); err == nil && len(deltas) > 0 {
+				base.loc.cache(base.typ, data)
+			}
+		}
+	case *cachedChain:
+		if err = ctx.Err(); err == nil {
+			if len(deltas) > 0 {
+				// The cached contents are only read by patch,
+				// and so need not be copied.
+				data = base.data
+			} else if err = budget.Reserve(int64(len(base.data))); err == nil {
+				held = int64(len(base.data))
+				data, err = base.Unpack()
+			}
+		}
+	default:
+		if err = ctx.Err(); err == nil {
+			if data, err = chain.Unpack(); err == nil {
+				// The size of the contents is not known
+				// until they have been unpacked.
+				if err = budget.Reserve(int64(len(data))); err == nil {
+					held = int64(len(data))
+				}
+			}
+		}
+	}
+
+	// Apply each delta in turn, beginning with the one nearest to the
+	// base.
+	for i := len(deltas) - 1; i >= 0 && err == nil; i-- {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		var size int64
+		if budget != nil {
+			var pos int
+			if _, pos, err = patchDeltaHeader(deltas[i].delta, 0); err != nil {
+				break
+			}
+			if size, _, err = patchDeltaHeader(deltas[i].delta, pos); err != nil {
+				break
+			}
+		}
+		if err = budget.Reserve(size); err != nil {
+			break
+		}
+
+		var dest []byte
+		if dest, err = patch(data, deltas[i].delta); err != nil {
+			budget.Release(size)
+			break
+		}
+		budget.Release(held)
+		data, held = dest, size
+
+		if i > 0 {
+			deltas[i].loc.cache(chain.Type(), data)
+		}
+	}
+
+	if err != nil {
+		budget.Release(held)
+		return nil, err
+	}
+
+	StatsFromContext(ctx).record(len(deltas))
+	return data, nil
+}
+
+// Reader returns a reader over the unpacked contents of this object, along with
+// their size.
+//
+// If the object is stored as a base (i.e., it is not deltified), its contents
+// are inflated as they are read, rather than being held in memory all at once,
+// so that even very large objects may be read cheaply. Otherwise, the
+// delta-base chain is resolved in full before the reader is returned.
+//
+// The returned reader must be closed once it is no longer needed.
+func (o *Object) Reader() (io.ReadCloser, int64, error) {
+	return o.ReaderContext(context.Background())
+}
+
+// ReaderContext is as Reader, but abandons resolving the delta-base chain, or
+// inflating the object's contents, and returns the context's error, once the
+// given context is done.
+//
+// If the context carries a *Budget (see: WithBudget), the memory holding the
+// contents of a deltified object remains reserved from it until the returned
+// reader is closed.
+func (o *Object) ReaderContext(ctx context.Context) (io.ReadCloser, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	base, ok := o.data.(*ChainBase)
+	if !ok {
+		data, err := unpackChain(ctx, o.data)
+		if err != nil {
+			return nil, 0, err
+		}
+		return &budgetedReader{
+			Reader: bytes.NewReader(data),
+			budget: BudgetFromContext(ctx),
+			n:      int64(len(data)),
+		}, int64(len(data)), nil
+	}
+
+	zr, err := newZlibReader(&OffsetReaderAt{
+		r: base.r,
+		o: base.offset,
+	}, base.unpooled)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &baseReader{ctx: ctx, zr: zr, remaining: base.size}, base.size, nil
+}
+
+// baseReader inflates the contents of a *ChainBase as they are read.
+type baseReader struct {
+	// ctx is the context, once done, after which reads fail.
+	ctx context.Context
+	// zr inflates the compressed contents of the base.
+	zr io.ReadCloser
+	// remaining is the number of bytes of the base not yet read.
+	remaining int64
+}
+
+// Read implements io.Reader. It returns io.ErrUnexpectedEOF if the compressed
+// data ends before the expected number of bytes have been read.
+func (r *baseReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.zr.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF {
+		if r.remaining > 0 {
+			return n, io.ErrUnexpectedEOF
+		}
+		err = nil
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (r *baseReader) Close() error {
+	return r.zr.Close()
+}
+
 // Type returns the underlying object's type. Rather than the type of the
 // front-most delta-base component, it is the type of the object itself.
 func (o *Object) Type() PackedObjectType {
 	return o.typ
 }
+
+// Range returns a reader over at most "n" bytes of the unpacked contents of
+// this object, beginning at offset "off".
+//
+// If the object is stored as a base (i.e., it is not deltified), only as much
+// of the compressed data as is necessary to satisfy the range is inflated.
+// Otherwise, the delta-base chain is resolved in full, and the range is taken
+// from the result.
+func (o *Object) Range(off, n int64) (io.ReadCloser, error) {
+	if off < 0 || n < 0 {
+		return nil, fmt.Errorf("gitobj/pack: invalid range: %d+%d", off, n)
+	}
+
+	base, ok := o.data.(*ChainBase)
+	if !ok {
+		data, err := o.Unpack()
+		if err != nil {
+			return nil, err
+		}
+
+		if off > int64(len(data)) {
+			off = int64(len(data))
+		}
+		if n > int64(len(data))-off {
+			n = int64(len(data)) - off
+		}
+		return ioutil.NopCloser(bytes.NewReader(data[off : off+n])), nil
+	}
+
+	if off >= base.size {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if base.size-off < n {
+		n = base.size - off
+	}
+
+	zr, err := newZlibReader(&OffsetReaderAt{
+		r: base.r,
+		o: base.offset,
+	}, base.unpooled)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, zr, off); err != nil {
+		zr.Close()
+		return nil, err
+	}
+
+	return &struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(zr, n), zr}, nil
+}
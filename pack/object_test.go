@@ -1,7 +1,12 @@
 package pack
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,3 +49,224 @@ func TestObjectUnpackPropogatesErrors(t *testing.T) {
 	assert.Nil(t, data)
 	assert.Equal(t, expected, err)
 }
+
+func TestObjectRangeReadsPartOfABase(t *testing.T) {
+	const contents = "Hello, world!\n"
+
+	compressed, err := compress(contents)
+	assert.NoError(t, err)
+
+	o := &Object{
+		data: &ChainBase{
+			offset: 0,
+			size:   int64(len(contents)),
+
+			r: bytes.NewReader(compressed),
+		},
+		typ: TypeBlob,
+	}
+
+	r, err := o.Range(7, 5)
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(data))
+	assert.NoError(t, r.Close())
+}
+
+func TestObjectRangeReadsPartOfADelta(t *testing.T) {
+	o := &Object{
+		data: &ChainSimple{
+			X: []byte("Hello, world!\n"),
+		},
+	}
+
+	r, err := o.Range(7, 100)
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "world!\n", string(data))
+}
+
+func TestObjectRangePastTheEndIsEmpty(t *testing.T) {
+	o := &Object{
+		data: &ChainSimple{
+			X: []byte("Hello"),
+		},
+	}
+
+	r, err := o.Range(10, 1)
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestObjectRangeClampsLargeLengths(t *testing.T) {
+	o := &Object{
+		data: &ChainSimple{
+			X: []byte("Hello, world!\n"),
+		},
+	}
+
+	r, err := o.Range(7, math.MaxInt64)
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "world!\n", string(data))
+}
+
+func TestObjectRangeRejectsNegativeValues(t *testing.T) {
+	o := &Object{
+		data: &ChainSimple{
+			X: []byte("Hello"),
+		},
+	}
+
+	_, err := o.Range(-1, 1)
+	assert.EqualError(t, err, "gitobj/pack: invalid range: -1+1")
+
+	_, err = o.Range(0, -1)
+	assert.EqualError(t, err, "gitobj/pack: invalid range: 0+-1")
+}
+
+func TestObjectUnpackContextResolvesDeltas(t *testing.T) {
+	o := &Object{
+		data: &ChainDelta{
+			base: &ChainSimple{X: []byte("Hello")},
+			delta: []byte{
+				0x05, // Source size: 5.
+				0x07, // Destination size: 7.
+
+				0x91, // (1001 0001) (copy, smask=0001, omask=0001)
+				0x00, // (0000 0000) (offset=0)
+				0x05, // (0000 0101) (size=5)
+
+				0x02, // (0000 0010) (add, length=2)
+				'!', '\n',
+			},
+		},
+	}
+
+	data, err := o.UnpackContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello!\n", string(data))
+}
+
+func TestObjectUnpackContextStopsWhenCancelled(t *testing.T) {
+	compressed, err := compress("Hello, world!\n")
+	assert.NoError(t, err)
+
+	o := &Object{
+		data: &ChainBase{
+			offset: 0,
+			size:   14,
+			typ:    TypeBlob,
+
+			r: bytes.NewReader(compressed),
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	data, err := o.UnpackContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, data)
+}
+
+func TestObjectReaderStreamsBases(t *testing.T) {
+	const contents = "Hello, world!\n"
+
+	compressed, err := compress(contents)
+	assert.NoError(t, err)
+
+	o := &Object{
+		data: &ChainBase{
+			offset: 0,
+			size:   int64(len(contents)),
+			typ:    TypeBlob,
+
+			r: bytes.NewReader(compressed),
+		},
+		typ: TypeBlob,
+	}
+
+	r, size, err := o.Reader()
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(contents), size)
+	assert.IsType(t, &baseReader{}, r)
+
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, contents, string(data))
+	assert.NoError(t, r.Close())
+}
+
+func TestObjectReaderResolvesDeltas(t *testing.T) {
+	o := &Object{
+		data: &ChainSimple{
+			X: []byte("Hello, world!\n"),
+		},
+	}
+
+	r, size, err := o.Reader()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 14, size)
+
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!\n", string(data))
+}
+
+func TestObjectReaderDetectsTruncatedBases(t *testing.T) {
+	compressed, err := compress("Hello")
+	assert.NoError(t, err)
+
+	o := &Object{
+		data: &ChainBase{
+			offset: 0,
+			size:   14,
+			typ:    TypeBlob,
+
+			r: bytes.NewReader(compressed),
+		},
+	}
+
+	r, _, err := o.Reader()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	_, err = ioutil.ReadAll(r)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestObjectReaderContextStopsWhenCancelled(t *testing.T) {
+	compressed, err := compress("Hello, world!\n")
+	assert.NoError(t, err)
+
+	o := &Object{
+		data: &ChainBase{
+			offset: 0,
+			size:   14,
+			typ:    TypeBlob,
+
+			r: bytes.NewReader(compressed),
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r, _, err := o.ReaderContext(ctx)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	cancel()
+
+	_, err = ioutil.ReadAll(r)
+	assert.Equal(t, context.Canceled, err)
+}
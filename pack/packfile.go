@@ -1,11 +1,12 @@
 package pack
 
 import (
-	"compress/zlib"
+	"encoding/binary"
 	"fmt"
 	"hash"
 	"io"
 	"io/ioutil"
+	"sync"
 )
 
 // Packfile encapsulates the behavior of accessing an unpacked representation of
@@ -24,6 +25,61 @@ type Packfile struct {
 
 	// r is an io.ReaderAt that allows read access to the packfile itself.
 	r io.ReaderAt
+	// path is the path of the packfile, if it was opened from disk.
+	path string
+	// promisor indicates whether the packfile was received from a
+	// promisor remote (see: IsPromisor).
+	promisor bool
+	// unpooled indicates whether a new zlib reader is allocated for each
+	// object inflated, rather than one being drawn from a pool.
+	unpooled bool
+	// cache holds the contents of objects in this packfile which have been
+	// used as delta bases, or is nil if they are not cached.
+	cache *DeltaBaseCache
+
+	// verify indicates whether the checksums of the packfile and of the
+	// entries read from it are verified (see: Set.EnableVerification).
+	verify bool
+	// limits bounds the delta depth and the size of the objects read from
+	// the packfile (see: Set.SetLimits).
+	limits Limits
+	// verified guards the verification of the packfile's trailing
+	// checksums, which is done when it is first read with "verify" set.
+	// verifyErr holds the result.
+	verified  sync.Once
+	verifyErr error
+
+	// revOnce guards reading (or generating) "rev", the reverse index of
+	// the packfile, the first time it is needed (see: ReverseIndex).
+	// revErr holds any error in doing so.
+	revOnce sync.Once
+	rev     *ReverseIndex
+	revErr  error
+	// bitmapOnce guards reading "bitmap", the reachability bitmap file of
+	// the packfile, the first time it is needed (see: BitmapIndex).
+	// bitmapErr holds any error in doing so.
+	bitmapOnce sync.Once
+	bitmap     *BitmapIndex
+	bitmapErr  error
+}
+
+// Path returns the path of the packfile, if it was opened from disk (as by
+// OpenPackfile or NewSet), or the empty string otherwise.
+func (p *Packfile) Path() string {
+	return p.path
+}
+
+// Index returns the index of the packfile, or nil if it has none.
+func (p *Packfile) Index() *Index {
+	return p.idx
+}
+
+// IsPromisor returns whether the packfile is a promisor pack, which was received
+// from a promisor remote by a partial clone (or fetch), as is indicated by a
+// corresponding ".promisor" file alongside it. Objects referred to by those
+// in a promisor pack may be missing, and fetched from that remote on demand.
+func (p *Packfile) IsPromisor() bool {
+	return p.promisor
 }
 
 // Close closes the packfile if the underlying data stream is closeable. If so,
@@ -66,7 +122,14 @@ func (p *Packfile) Object(name []byte) (*Object, error) {
 	}
 
 	// If all goes well, then unpack the object at that given offset.
-	r, err := p.find(int64(entry.PackOffset))
+	return p.objectAt(int64(entry.PackOffset))
+}
+
+// objectAt returns a reference to the object packed at the given offset, as in
+// Object(), for callers which have already located it by other means (such as
+// a *MultiPackIndex).
+func (p *Packfile) objectAt(offset int64) (*Object, error) {
+	r, err := p.find(offset)
 	if err != nil {
 		return nil, err
 	}
@@ -84,38 +147,46 @@ func (p *Packfile) Object(name []byte) (*Object, error) {
 // zlib-flate it. Otherwise, if find returns a ChainDelta, it loads all of the
 // leading elements in the chain recursively, but does not apply one delta to
 // another.
+//
+// If the chain is deeper than is allowed by the packfile's limits, or any
+// element of it is larger, a *DeltaDepthExceededErr or an *ObjectTooLargeErr is
+// returned (see: Limits).
 func (p *Packfile) find(offset int64) (Chain, error) {
-	// Read the first byte in the chain element.
-	buf := make([]byte, 1)
-	if _, err := p.r.ReadAt(buf, offset); err != nil {
+	return p.findAt(offset, 0)
+}
+
+// findAt is as find, but for the element at the given depth of the delta-base
+// chain of another object, counting from the object itself.
+func (p *Packfile) findAt(offset int64, depth int) (Chain, error) {
+	if err := p.checkDepth(offset, depth); err != nil {
 		return nil, err
 	}
 
 	// Store the original offset; this will be compared to when loading
 	// chain elements of type OBJ_OFS_DELTA.
 	objectOffset := offset
+	loc := chainLocation{p: p, offset: offset}
 
-	// Of the first byte, (0123 4567):
-	//   - Bit 0 is the M.S.B., and indicates whether there is more data
-	//     encoded in the length.
-	//   - Bits 1-3 ((buf[0] >> 4) & 0x7) are the object type.
-	//   - Bits 4-7 (buf[0] & 0xf) are the first 4 bits of the variable
-	//     length size of the encoded delta or base.
-	typ := PackedObjectType((buf[0] >> 4) & 0x7)
-	size := uint64(buf[0] & 0xf)
-	shift := uint(4)
-	offset += 1
+	// If the object has already been unpacked as the base of another
+	// delta, there is no need to load the remainder of its chain.
+	if p.cache != nil {
+		if typ, data, ok := p.cache.get(loc); ok {
+			return &cachedChain{typ: typ, data: data}, nil
+		}
+	}
 
-	for buf[0]&0x80 != 0 {
-		// If there is more data to be read, read it.
-		if _, err := p.r.ReadAt(buf, offset); err != nil {
+	if p.verify {
+		if err := p.verifyEntry(offset); err != nil {
 			return nil, err
 		}
+	}
 
-		// And update the size, bitshift, and offset accordingly.
-		size |= (uint64(buf[0]&0x7f) << shift)
-		shift += 7
-		offset += 1
+	typ, size, offset, err := p.header(offset)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkSize(objectOffset, size); err != nil {
+		return nil, err
 	}
 
 	switch typ {
@@ -127,7 +198,7 @@ func (p *Packfile) find(offset int64) (Chain, error) {
 		//
 		// Recursively load the base, and keep track of the updated
 		// offset.
-		base, offset, err := p.findBase(typ, offset, objectOffset)
+		base, offset, err := p.findBase(typ, offset, objectOffset, depth+1)
 		if err != nil {
 			return nil, err
 		}
@@ -137,23 +208,40 @@ func (p *Packfile) find(offset int64) (Chain, error) {
 		//
 		// NB: The delta instructions are zlib compressed, so ensure
 		// that we uncompress the instructions first.
-		zr, err := zlib.NewReader(&OffsetReaderAt{
+		zr, err := newZlibReader(&OffsetReaderAt{
 			o: offset,
 			r: p.r,
-		})
+		}, p.unpooled)
 		if err != nil {
 			return nil, err
 		}
 
-		delta, err := ioutil.ReadAll(zr)
+		delta, err := p.readDelta(zr, objectOffset)
+		zr.Close()
 		if err != nil {
 			return nil, err
 		}
 
+		// The size of the object resulting from the delta is given
+		// by its instructions, and must be checked before they are
+		// applied.
+		_, pos, err := patchDeltaHeader(delta, 0)
+		if err != nil {
+			return nil, err
+		}
+		destSize, _, err := patchDeltaHeader(delta, pos)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkSize(objectOffset, uint64(destSize)); err != nil {
+			return nil, err
+		}
+
 		// Then compose the two and return it as a *ChainDelta.
 		return &ChainDelta{
 			base:  base,
 			delta: delta,
+			loc:   loc,
 		}, nil
 	case TypeCommit, TypeTree, TypeBlob, TypeTag:
 		// Otherwise, the object's contents are given to be the
@@ -167,22 +255,196 @@ func (p *Packfile) find(offset int64) (Chain, error) {
 			size:   int64(size),
 			typ:    typ,
 
-			r: p.r,
+			r:        p.r,
+			unpooled: p.unpooled,
+			loc:      loc,
 		}, nil
 	}
 	// Otherwise, we received an invalid object type.
 	return nil, errUnrecognizedObjectType
 }
 
+// readDelta reads the instructions of the delta at the given offset from "r",
+// which inflates them. If the packfile's limits bound the size of objects, no
+// more than that many bytes are read, whatever size is declared by the delta's
+// header, and an *ObjectTooLargeErr is returned if there are more.
+func (p *Packfile) readDelta(r io.Reader, offset int64) ([]byte, error) {
+	max := p.limits.MaxObjectSize
+	if max <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	delta, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(delta)) > max {
+		return nil, &ObjectTooLargeErr{Name: p.path, Offset: offset,
+			Size: uint64(len(delta)), Limit: max}
+	}
+	return delta, nil
+}
+
+// header reads the header of the packed object beginning at "offset", and
+// returns the type and (uncompressed) size given in that header, as well as
+// the offset of the first byte following it.
+func (p *Packfile) header(offset int64) (PackedObjectType, uint64, int64, error) {
+	// Read the first byte in the chain element, which must lie within the
+	// packfile, whatever offset an index (or delta) has given for it.
+	buf := make([]byte, 1)
+	if offset < 0 {
+		return TypeNone, 0, offset, &CorruptPackErr{Name: p.path,
+			Offset: offset, Reason: "entry offset out of range"}
+	}
+	if _, err := p.r.ReadAt(buf, offset); err != nil {
+		if err == io.EOF {
+			err = &CorruptPackErr{Name: p.path, Offset: offset,
+				Reason: "entry offset out of range"}
+		}
+		return TypeNone, 0, offset, err
+	}
+
+	// Of the first byte, (0123 4567):
+	//   - Bit 0 is the M.S.B., and indicates whether there is more data
+	//     encoded in the length.
+	//   - Bits 1-3 ((buf[0] >> 4) & 0x7) are the object type.
+	//   - Bits 4-7 (buf[0] & 0xf) are the first 4 bits of the variable
+	//     length size of the encoded delta or base.
+	typ := PackedObjectType((buf[0] >> 4) & 0x7)
+	size := uint64(buf[0] & 0xf)
+	shift := uint(4)
+	offset += 1
+
+	for buf[0]&0x80 != 0 {
+		if shift > 63 {
+			return TypeNone, 0, offset, &CorruptPackErr{Name: p.path,
+				Offset: offset, Reason: "object size out of range"}
+		}
+
+		// If there is more data to be read, read it.
+		if _, err := p.r.ReadAt(buf, offset); err != nil {
+			return TypeNone, 0, offset, err
+		}
+
+		// And update the size, bitshift, and offset accordingly.
+		size |= (uint64(buf[0]&0x7f) << shift)
+		shift += 7
+		offset += 1
+	}
+
+	return typ, size, offset, nil
+}
+
+// typeAt returns the type of the object packed at the given offset, resolving
+// the types of delta-base chain elements without inflating any of them.
+func (p *Packfile) typeAt(offset int64) (PackedObjectType, error) {
+	for depth := 0; ; depth++ {
+		if err := p.checkDepth(offset, depth); err != nil {
+			return TypeNone, err
+		}
+
+		typ, _, dataOffset, err := p.header(offset)
+		if err != nil {
+			return TypeNone, err
+		}
+
+		switch typ {
+		case TypeObjectOffsetDelta, TypeObjectReferenceDelta:
+			if offset, _, err = p.baseOffset(typ, dataOffset, offset); err != nil {
+				return TypeNone, err
+			}
+		case TypeCommit, TypeTree, TypeBlob, TypeTag:
+			return typ, nil
+		default:
+			return TypeNone, errUnrecognizedObjectType
+		}
+	}
+}
+
+// infoAt returns the type and size of the object packed at the given offset
+// (see: objectInfo).
+func (p *Packfile) infoAt(offset int64) (PackedObjectType, int64, error) {
+	if p.verify {
+		if err := p.verifyOnce(); err != nil {
+			return TypeNone, 0, err
+		}
+	}
+
+	hdr, err := p.entryHeader(offset)
+	if err != nil {
+		return TypeNone, 0, err
+	}
+	return p.objectInfo(hdr)
+}
+
+// objectInfo returns the type and size of the object stored in the entry with
+// the given header. The type of a delta is that of its base, and its size is
+// read from the beginning of its instructions, so no more than the first few
+// bytes of any entry are inflated.
+func (p *Packfile) objectInfo(hdr *EntryHeader) (PackedObjectType, int64, error) {
+	switch hdr.Type {
+	case TypeCommit, TypeTree, TypeBlob, TypeTag:
+		return hdr.Type, int64(hdr.Size), nil
+	}
+
+	typ, err := p.typeAt(hdr.Offset)
+	if err != nil {
+		return TypeNone, 0, err
+	}
+
+	zr, err := newZlibReader(&OffsetReaderAt{r: p.r, o: hdr.DataOffset}, p.unpooled)
+	if err != nil {
+		return TypeNone, 0, err
+	}
+	defer zr.Close()
+
+	// The delta instructions begin with the sizes of the base and of the
+	// result, each of which is encoded in at most binary.MaxVarintLen64
+	// bytes.
+	buf := make([]byte, 2*binary.MaxVarintLen64)
+	n, err := io.ReadFull(zr, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return TypeNone, 0, err
+	}
+	buf = buf[:n]
+
+	_, w := binary.Uvarint(buf)
+	if w <= 0 {
+		return TypeNone, 0, fmt.Errorf("gitobj/pack: invalid delta header at offset %d", hdr.Offset)
+	}
+	size, v := binary.Uvarint(buf[w:])
+	if v <= 0 {
+		return TypeNone, 0, fmt.Errorf("gitobj/pack: invalid delta header at offset %d", hdr.Offset)
+	}
+	return typ, int64(size), nil
+}
+
 // findBase finds the base (an object, or another delta) for a given
-// OBJ_OFS_DELTA or OBJ_REFS_DELTA at the given offset.
+// OBJ_OFS_DELTA or OBJ_REFS_DELTA at the given offset, which is found at the
+// given depth of the delta-base chain (see: findAt).
 //
 // It returns the preceding Chain, as well as an updated read offset into the
 // underlying packfile data.
 //
 // If any of the above could not be completed successfully, findBase returns an
 // error.
-func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chain, int64, error) {
+func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64, depth int) (Chain, int64, error) {
+	baseOffset, offset, err := p.baseOffset(typ, offset, objOffset)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	// Once we have determined the base offset of the object's chain base,
+	// read the delta-base chain beginning at that offset.
+	r, err := p.findAt(baseOffset, depth)
+	return r, offset, err
+}
+
+// baseOffset returns the offset of the base of the OBJ_OFS_DELTA or
+// OBJ_REFS_DELTA whose data (following the object header) begins at the given
+// offset, along with the offset of the delta instructions that follow the
+// encoded base.
+func (p *Packfile) baseOffset(typ PackedObjectType, offset, objOffset int64) (int64, int64, error) {
 	var baseOffset int64
 
 	hashlen := p.hash.Size()
@@ -192,7 +454,7 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 	// length of the base offset encoded in an OBJ_OFS_DELTA).
 	var sha [MaxHashSize]byte
 	if _, err := p.r.ReadAt(sha[:hashlen], offset); err != nil {
-		return nil, baseOffset, err
+		return baseOffset, offset, err
 	}
 
 	switch typ {
@@ -206,6 +468,10 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 
 		for c&0x80 != 0 {
 			i += 1
+			if i == hashlen || baseOffset >= maxInt64>>7 {
+				return baseOffset, offset, &CorruptPackErr{Name: p.path,
+					Offset: objOffset, Reason: "base offset out of range"}
+			}
 			c = int64(sha[i])
 
 			baseOffset += 1
@@ -213,6 +479,13 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 			baseOffset |= c & 0x7f
 		}
 
+		// The base must precede the delta, so that a chain of
+		// OBJ_OFS_DELTAs cannot be cyclic.
+		if baseOffset <= 0 || baseOffset >= objOffset {
+			return baseOffset, offset, &CorruptPackErr{Name: p.path,
+				Offset: objOffset, Reason: "base offset out of range"}
+		}
+
 		baseOffset = objOffset - baseOffset
 		offset += int64(i) + 1
 	case TypeObjectReferenceDelta:
@@ -221,7 +494,7 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 		// corresponding pack index file.
 		e, err := p.idx.Entry(sha[:hashlen])
 		if err != nil {
-			return nil, baseOffset, err
+			return baseOffset, offset, err
 		}
 
 		baseOffset = int64(e.PackOffset)
@@ -229,12 +502,8 @@ func (p *Packfile) findBase(typ PackedObjectType, offset, objOffset int64) (Chai
 	default:
 		// If we did not receive an OBJ_OFS_DELTA, or OBJ_REF_DELTA, the
 		// type given is not a delta-fied type. Return an error.
-		return nil, baseOffset, fmt.Errorf(
+		return baseOffset, offset, fmt.Errorf(
 			"gitobj/pack: type %s is not deltafied", typ)
 	}
-
-	// Once we have determined the base offset of the object's chain base,
-	// read the delta-base chain beginning at that offset.
-	r, err := p.find(baseOffset)
-	return r, offset, err
+	return baseOffset, offset, nil
 }
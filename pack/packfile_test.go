@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPackObjectReturnsObjectWithSingleBaseAtLowOffset(t *testing.T) {
@@ -278,3 +279,36 @@ func DecodeHex(t *testing.T, str string) []byte {
 
 	return b
 }
+
+func TestPackfileObjectInfoResolvesDeltas(t *testing.T) {
+	pack, _, _, _ := deltaTestPack(t)
+
+	iw, err := IndexPackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+
+	var idx bytes.Buffer
+	_, err = iw.WriteTo(&idx)
+	require.NoError(t, err)
+
+	p, err := DecodePackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+	p.idx, err = DecodeIndex(bytes.NewReader(idx.Bytes()), sha1.New())
+	require.NoError(t, err)
+
+	for _, hdr := range scanAll(t, p) {
+		typ, size, err := p.objectInfo(hdr)
+		require.NoError(t, err)
+		assert.Equal(t, TypeBlob, typ)
+
+		switch string(hdr.Name) {
+		case string(objectName(TypeBlob, "Hello, world!\n")):
+			assert.EqualValues(t, 14, size)
+		case string(objectName(TypeBlob, "Hello")):
+			assert.EqualValues(t, 5, size)
+		case string(objectName(TypeBlob, "Hello!\n")):
+			assert.EqualValues(t, 7, size)
+		default:
+			t.Errorf("unexpected object %x", hdr.Name)
+		}
+	}
+}
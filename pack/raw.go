@@ -0,0 +1,170 @@
+package pack
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+)
+
+// RawEntry returns the header of the entry holding the object named "name" in
+// the packfile, along with a reader over the entry exactly as it is stored:
+// its header, the reference to its delta base, if it is a delta, and its
+// compressed contents, which together occupy hdr.Length bytes beginning at
+// hdr.Offset. It allows objects to be copied (as by a mirror, or a backup)
+// without being inflated and compressed again.
+//
+// Since an OBJ_OFS_DELTA entry refers to its base by its distance from the
+// entry, such entries may only be copied as they are into a packfile holding
+// the base at the same distance.
+//
+// If the packfile's index records the CRC32 of each entry (as version 2
+// indexes do), the data read is checked against it, and a *CorruptPackErr is
+// returned in place of io.EOF if they differ. When verification is enabled
+// (see: Set.EnableVerification), the packfile's trailing checksum is also
+// verified before the entry is first read.
+//
+// If the object is not in the packfile, (nil, nil, errNotFound) is returned.
+func (p *Packfile) RawEntry(name []byte) (*EntryHeader, io.ReadCloser, error) {
+	entry, err := p.idx.Entry(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hdr, r, err := p.rawEntryAt(int64(entry.PackOffset))
+	if err != nil {
+		return nil, nil, err
+	}
+	hdr.Name = name
+	return hdr, r, nil
+}
+
+// rawEntryAt returns the header of the entry beginning at "offset", and a
+// reader over its packed data (see: RawEntry).
+func (p *Packfile) rawEntryAt(offset int64) (*EntryHeader, io.ReadCloser, error) {
+	if p.verify {
+		if err := p.verifyOnce(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	hdr, err := p.entryHeader(offset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rev, n, end, err := p.entryExtent(offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	hdr.Length = end - offset
+
+	r := io.NewSectionReader(p.r, offset, hdr.Length)
+
+	expected, ok, err := p.idx.crcAt(int64(rev.positions[n]))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return hdr, ioutil.NopCloser(r), nil
+	}
+
+	return hdr, &crcReader{
+		r:        r,
+		crc:      crc32.NewIEEE(),
+		expected: expected,
+		name:     p.path,
+		offset:   offset,
+	}, nil
+}
+
+// crcReader is an io.ReadCloser over the packed data of an entry, which checks
+// the CRC32 of the data read once it has all been read.
+type crcReader struct {
+	r   io.Reader
+	crc hash.Hash32
+	// expected is the CRC32 recorded for the entry in the index.
+	expected uint32
+
+	// name and offset locate the entry, for reporting a mismatch.
+	name   string
+	offset int64
+}
+
+// Read implements io.Reader, returning a *CorruptPackErr in place of io.EOF if
+// the data read does not match its CRC32.
+func (r *crcReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.crc.Write(p[:n])
+
+	if err == io.EOF {
+		if got := r.crc.Sum32(); got != r.expected {
+			return n, &CorruptPackErr{
+				Name:   r.name,
+				Offset: r.offset,
+				Reason: fmt.Sprintf("CRC32 %08x, index expects %08x", got, r.expected),
+			}
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (r *crcReader) Close() error {
+	return nil
+}
+
+// RawEntry returns the header of the entry holding the object named "name" in
+// the first packfile in the set which holds it, along with a reader over the
+// entry as it is stored (see: Packfile.RawEntry). Packfiles are searched as by
+// Object, and the pack directory is likewise rescanned if the object is not
+// found.
+//
+// If the object was unable to be found in any of the packfiles, an error
+// satisfying errors.IsNoSuchObject is returned.
+func (s *Set) RawEntry(name []byte) (*EntryHeader, io.ReadCloser, error) {
+	hdr, r, err := s.rawEntry(name)
+	if errors.IsNoSuchObject(err) && s.rescan() {
+		return s.rawEntry(name)
+	}
+	return hdr, r, err
+}
+
+// rawEntry returns the raw entry of the given object as RawEntry does, without
+// rescanning the pack directory.
+func (s *Set) rawEntry(name []byte) (*EntryHeader, io.ReadCloser, error) {
+	s.mu.RLock()
+	midx, packs := s.midx, s.midxPacks
+	s.mu.RUnlock()
+
+	if midx != nil {
+		pack, offset, err := midx.Entry(name)
+		if err == nil {
+			hdr, r, err := packs[pack].rawEntryAt(offset)
+			if err != nil {
+				return nil, nil, err
+			}
+			hdr.Name = name
+			return hdr, r, nil
+		}
+		if !IsNotFound(err) {
+			return nil, nil, err
+		}
+	}
+
+	var hdr *EntryHeader
+	var r io.ReadCloser
+
+	_, err := s.each(name, func(p *Packfile) (*Object, error) {
+		var err error
+		hdr, r, err = p.RawEntry(name)
+		return nil, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return hdr, r, nil
+}
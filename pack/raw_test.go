@@ -0,0 +1,72 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackfileRawEntry(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	idx, name := writeTestPack(t, pd, "Hello, world!\n")
+	path := filepath.Join(pd, strings.TrimSuffix(idx, ".idx")+".pack")
+
+	p, err := OpenPackfile(path, sha1.New())
+	require.NoError(t, err)
+	defer p.Close()
+
+	hdr, r, err := p.RawEntry(name)
+	require.NoError(t, err)
+	defer r.Close()
+
+	assert.Equal(t, name, hdr.Name)
+	assert.Equal(t, TypeBlob, hdr.Type)
+	assert.EqualValues(t, 12, hdr.Offset)
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.EqualValues(t, hdr.Length, len(data))
+
+	pack, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, pack[hdr.Offset:hdr.Offset+hdr.Length], data)
+
+	_, _, err = p.RawEntry(bytes.Repeat([]byte{0xff}, 20))
+	assert.True(t, IsNotFound(err))
+}
+
+func TestPackfileRawEntryDetectsCorruption(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	idx, name := writeTestPack(t, pd, "Hello, world!\n")
+	path := filepath.Join(pd, strings.TrimSuffix(idx, ".idx")+".pack")
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-21] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	p, err := OpenPackfile(path, sha1.New())
+	require.NoError(t, err)
+	defer p.Close()
+
+	_, r, err := p.RawEntry(name)
+	require.NoError(t, err)
+	defer r.Close()
+
+	_, err = ioutil.ReadAll(r)
+	require.IsType(t, &CorruptPackErr{}, err)
+	assert.EqualValues(t, 12, err.(*CorruptPackErr).Offset)
+}
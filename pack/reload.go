@@ -0,0 +1,98 @@
+package pack
+
+import (
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultRescanInterval is the minimum interval between the rescans
+	// of a set's pack directory made when an object is not found, unless
+	// another is set (see: Set.SetRescanInterval).
+	DefaultRescanInterval = time.Second
+)
+
+// Reload rescans the set's pack directory, and adds each packfile written to it
+// since it was last scanned, as by a concurrent fetch or repack, to the set.
+// Packfiles which have since been removed remain in the set, and may still be
+// read from for as long as they remain open.
+//
+// Reload returns an error if a new packfile cannot be opened, in which case
+// none are added. It does nothing if the set was not read from disk (see:
+// NewSetPacks).
+func (s *Set) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	_, err := s.reload()
+	return err
+}
+
+// SetRescanInterval sets the minimum interval between the rescans of the set's
+// pack directory made when an object is not found in any of its packfiles
+// (see: Object). A negative interval disables them, in which case only Reload
+// adds new packfiles to the set.
+func (s *Set) SetRescanInterval(interval time.Duration) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.rescanInterval = interval
+}
+
+// rescan reloads the set once an object has not been found, unless it was last
+// scanned within the rescan interval, and returns whether any packfiles were
+// added to it. Errors in opening new packfiles are ignored, as the object
+// would not have been found in them either.
+func (s *Set) rescan() bool {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if len(s.dir) == 0 || s.rescanInterval < 0 {
+		return false
+	}
+	if time.Since(s.scanned) < s.rescanInterval {
+		return false
+	}
+
+	added, err := s.reload()
+	return err == nil && len(added) > 0
+}
+
+// refresh reloads the set, unless rescans are disabled, regardless of when it
+// was last scanned. Errors in opening new packfiles are ignored, as by rescan.
+func (s *Set) refresh() {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if s.rescanInterval < 0 {
+		return
+	}
+	s.reload()
+}
+
+// reload adds each new packfile in the set's pack directory to the set, and
+// returns them. The caller must hold "reloadMu".
+func (s *Set) reload() ([]*Packfile, error) {
+	if len(s.dir) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	known := make(map[string]struct{}, len(s.packs))
+	for _, pack := range s.packs {
+		known[filepath.Base(pack.path)] = struct{}{}
+	}
+	s.mu.RUnlock()
+
+	s.scanned = time.Now()
+	packs, _, err := openPacks(s.dir, s.algo, known, s.files)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(packs) == 0 {
+		return nil, nil
+	}
+	s.Add(packs...)
+	return packs, nil
+}
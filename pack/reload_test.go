@@ -0,0 +1,160 @@
+package pack
+
+import (
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetReloadAddsNewPacks(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-pack-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	pd := filepath.Join(root, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+	_, a := writeTestPack(t, pd, "a\n")
+
+	set, err := NewSet(root, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+	set.SetRescanInterval(-1)
+
+	_, b := writeTestPack(t, pd, "b\n")
+
+	_, err = set.Object(b)
+	assert.True(t, errors.IsNoSuchObject(err))
+
+	require.NoError(t, set.Reload())
+	assert.Len(t, set.Packs(), 2)
+
+	for _, name := range [][]byte{a, b} {
+		typ, _, err := set.ObjectInfo(name)
+		require.NoError(t, err)
+		assert.Equal(t, TypeBlob, typ)
+	}
+
+	// Packfiles already in the set are not added again.
+	require.NoError(t, set.Reload())
+	assert.Len(t, set.Packs(), 2)
+}
+
+func TestSetRescansWhenObjectsAreMissing(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-pack-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	pd := filepath.Join(root, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	set, err := NewSet(root, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+	set.SetRescanInterval(0)
+
+	_, name := writeTestPack(t, pd, "Hello, world!\n")
+
+	o, err := set.Object(name)
+	require.NoError(t, err)
+
+	data, err := o.Unpack()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("Hello, world!\n"), data)
+}
+
+func TestSetThrottlesRescans(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-pack-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	pd := filepath.Join(root, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	set, err := NewSet(root, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	_, name := writeTestPack(t, pd, "Hello, world!\n")
+
+	// The directory was scanned within the default interval, when the set
+	// was opened.
+	_, _, err = set.ObjectInfo(name)
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Empty(t, set.Packs())
+}
+
+func TestSetForEachScansPacksWrittenDuringScan(t *testing.T) {
+	for _, rescan := range []bool{true, false} {
+		root, err := ioutil.TempDir("", "gitobj-pack-reload")
+		require.NoError(t, err)
+		defer os.RemoveAll(root)
+
+		pd := filepath.Join(root, "pack")
+		require.NoError(t, os.Mkdir(pd, 0755))
+		_, a := writeTestPack(t, pd, "a\n")
+
+		set, err := NewSet(root, sha1.New())
+		require.NoError(t, err)
+		defer set.Close()
+		if !rescan {
+			set.SetRescanInterval(-1)
+		}
+
+		var b []byte
+		var seen [][]byte
+		require.NoError(t, set.ForEachObject(func(name []byte, typ PackedObjectType, size int64) error {
+			if b == nil {
+				_, b = writeTestPack(t, pd, "b\n")
+			}
+			seen = append(seen, name)
+			return nil
+		}))
+
+		if rescan {
+			assert.Equal(t, [][]byte{a, b}, seen)
+		} else {
+			assert.Equal(t, [][]byte{a}, seen)
+		}
+	}
+}
+
+func TestSetForEachSkipsRemovedPacks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("open files cannot be removed on Windows")
+	}
+
+	root, err := ioutil.TempDir("", "gitobj-pack-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	pd := filepath.Join(root, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+	a, _ := writeTestPack(t, pd, "a\n")
+	_, b := writeTestPack(t, pd, "b\n")
+
+	// At most one file is held open, so those of a removed packfile
+	// cannot all be read.
+	set, err := NewSetWithFileCache(root, sha1.New(), NewFileCache(1))
+	require.NoError(t, err)
+	defer set.Close()
+
+	base := filepath.Join(pd, strings.TrimSuffix(a, ".idx"))
+	require.NoError(t, os.Remove(base+".pack"))
+	require.NoError(t, os.Remove(base+".idx"))
+
+	var seen [][]byte
+	require.NoError(t, set.ForEachObject(func(name []byte, typ PackedObjectType, size int64) error {
+		seen = append(seen, name)
+		return nil
+	}))
+	assert.Equal(t, [][]byte{b}, seen)
+}
@@ -0,0 +1,226 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// reverseIndexHeaderWidth is the width of the header of a reverse
+	// index: its magic bytes, version, and hash version.
+	reverseIndexHeaderWidth = 12
+	// reverseIndexEntryWidth is the width of each index position stored in
+	// a reverse index.
+	reverseIndexEntryWidth = 4
+	// reverseIndexVersion is the only supported version of reverse
+	// indexes.
+	reverseIndexVersion = 1
+)
+
+var (
+	// reverseIndexHeader is the first four "magic" bytes of a reverse
+	// index.
+	reverseIndexHeader = []byte("RIDX")
+)
+
+// ReverseIndex maps the objects in a packfile, in the order in which they are
+// stored ("pack order"), to their positions in the packfile's index, so that
+// the object stored at a given offset can be found, and the objects in the
+// packfile enumerated in pack order, without inflating any of them.
+//
+// It is read from the reverse index (".rev") file written beside the packfile
+// by Git, or else generated from the packfile's index (see: NewReverseIndex).
+type ReverseIndex struct {
+	// idx is the index of the packfile.
+	idx *Index
+	// positions holds the position in "idx" of each object in the
+	// packfile, in pack order.
+	positions []uint32
+}
+
+// NewReverseIndex generates the reverse index of the packfile indexed by "idx",
+// reading the offset of each object in it.
+func NewReverseIndex(idx *Index) (*ReverseIndex, error) {
+	total := idx.count()
+
+	offsets := make([]uint64, total)
+	positions := make([]uint32, total)
+	for at := int64(0); at < total; at++ {
+		entry, err := idx.version.Entry(idx, at)
+		if err != nil {
+			return nil, err
+		}
+		offsets[at] = entry.PackOffset
+		positions[at] = uint32(at)
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		return offsets[positions[i]] < offsets[positions[j]]
+	})
+	return &ReverseIndex{idx: idx, positions: positions}, nil
+}
+
+// DecodeReverseIndex decodes the reverse index whose underlying data is supplied
+// by "r", of the packfile indexed by "idx". "hash" is an instance of the hash
+// algorithm used to name objects in the packfile.
+//
+// A reverse index written for a different packfile, or for a different hash
+// algorithm, is rejected. Its own trailing checksum is not verified.
+func DecodeReverseIndex(r io.ReaderAt, idx *Index, hash hash.Hash) (*ReverseIndex, error) {
+	var hdr [reverseIndexHeaderWidth]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(hdr[:4], reverseIndexHeader) {
+		return nil, fmt.Errorf("gitobj/pack: invalid reverse index header")
+	}
+	if v := binary.BigEndian.Uint32(hdr[4:]); v != reverseIndexVersion {
+		return nil, &UnsupportedVersionErr{Got: v}
+	}
+	if v, algo := binary.BigEndian.Uint32(hdr[8:]), HashVersion(hash); v != uint32(algo) {
+		return nil, fmt.Errorf("gitobj/pack: reverse index has hash version %d, expected %d", v, algo)
+	}
+
+	total := idx.count()
+	buf := make([]byte, reverseIndexEntryWidth*total+int64(hash.Size()))
+	if _, err := r.ReadAt(buf, reverseIndexHeaderWidth); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("gitobj/pack: reverse index is truncated")
+		}
+		return nil, err
+	}
+
+	checksum, err := idx.PackChecksum()
+	if err != nil {
+		return nil, err
+	}
+	if sum := buf[reverseIndexEntryWidth*total:]; !bytes.Equal(sum, checksum) {
+		return nil, fmt.Errorf("gitobj/pack: reverse index does not match packfile %x", checksum)
+	}
+
+	positions := make([]uint32, total)
+	for i := range positions {
+		positions[i] = binary.BigEndian.Uint32(buf[reverseIndexEntryWidth*i:])
+		if int64(positions[i]) >= total {
+			return nil, fmt.Errorf("gitobj/pack: reverse index position %d out of range", positions[i])
+		}
+	}
+	return &ReverseIndex{idx: idx, positions: positions}, nil
+}
+
+// Count returns the number of objects in the packfile.
+func (r *ReverseIndex) Count() int {
+	return len(r.positions)
+}
+
+// Entry returns the name and offset of the "n"th object in the packfile, in
+// pack order.
+func (r *ReverseIndex) Entry(n int) ([]byte, int64, error) {
+	if n < 0 || n >= len(r.positions) {
+		return nil, 0, fmt.Errorf("gitobj/pack: reverse index position %d out of range", n)
+	}
+
+	name, entry, err := r.idx.entryAt(int64(r.positions[n]))
+	if err != nil {
+		return nil, 0, err
+	}
+	return name, int64(entry.PackOffset), nil
+}
+
+// Find returns the position in pack order of the object stored at "offset".
+//
+// If no object begins at that offset, (0, errNotFound) is returned (see:
+// IsNotFound).
+func (r *ReverseIndex) Find(offset int64) (int, error) {
+	var err error
+	n := sort.Search(len(r.positions), func(i int) bool {
+		if err != nil {
+			return true
+		}
+
+		var at int64
+		at, err = r.offset(i)
+		return at >= offset
+	})
+	if err != nil {
+		return 0, err
+	}
+	if n == len(r.positions) {
+		return 0, errNotFound
+	}
+
+	if at, err := r.offset(n); err != nil {
+		return 0, err
+	} else if at != offset {
+		return 0, errNotFound
+	}
+	return n, nil
+}
+
+// offset returns the offset of the "n"th object in the packfile, in pack order.
+func (r *ReverseIndex) offset(n int) (int64, error) {
+	entry, err := r.idx.version.Entry(r.idx, int64(r.positions[n]))
+	if err != nil {
+		return 0, err
+	}
+	return int64(entry.PackOffset), nil
+}
+
+// ReverseIndex returns the reverse index of the packfile. It is read from the
+// packfile's reverse index (".rev") file, if it has one which is usable, or is
+// otherwise generated from its index, as by Git, the first time that it is
+// called.
+func (p *Packfile) ReverseIndex() (*ReverseIndex, error) {
+	p.revOnce.Do(func() {
+		p.rev, p.revErr = p.openReverseIndex()
+	})
+	return p.rev, p.revErr
+}
+
+// openReverseIndex reads or generates the packfile's reverse index (see:
+// ReverseIndex).
+func (p *Packfile) openReverseIndex() (*ReverseIndex, error) {
+	if p.idx == nil {
+		return nil, fmt.Errorf("gitobj/pack: cannot read reverse index without index")
+	}
+
+	if len(p.path) > 0 {
+		if f, err := os.Open(strings.TrimSuffix(p.path, ".pack") + ".rev"); err == nil {
+			rev, err := DecodeReverseIndex(f, p.idx, p.hash)
+			f.Close()
+
+			if err == nil {
+				return rev, nil
+			}
+			// Otherwise, the reverse index is unusable, and is
+			// generated instead.
+		}
+	}
+	return NewReverseIndex(p.idx)
+}
+
+// NameAt returns the name of the object stored at "offset" in the packfile.
+//
+// If no object begins at that offset, (nil, errNotFound) is returned (see:
+// IsNotFound).
+func (p *Packfile) NameAt(offset int64) ([]byte, error) {
+	rev, err := p.ReverseIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := rev.Find(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _, err := rev.Entry(n)
+	return name, err
+}
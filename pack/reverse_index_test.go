@@ -0,0 +1,176 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseIndexOrdersObjectsByOffset(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	contents := []string{"a\n", "b\n", "c\n", "d\n"}
+	p := writeTestReverseIndexPack(t, pd, contents...)
+	defer p.Close()
+
+	rev, err := NewReverseIndex(p.idx)
+	require.NoError(t, err)
+	require.Equal(t, len(contents), rev.Count())
+
+	var last int64
+	for n, data := range contents {
+		name, offset, err := rev.Entry(n)
+		require.NoError(t, err)
+		assert.Equal(t, objectName(TypeBlob, data), name)
+		assert.True(t, offset > last)
+		last = offset
+
+		found, err := rev.Find(offset)
+		assert.NoError(t, err)
+		assert.Equal(t, n, found)
+
+		name, err = p.NameAt(offset)
+		assert.NoError(t, err)
+		assert.Equal(t, objectName(TypeBlob, data), name)
+	}
+
+	_, err = rev.Find(13)
+	assert.True(t, IsNotFound(err))
+	_, err = p.NameAt(last + 1)
+	assert.True(t, IsNotFound(err))
+	_, _, err = rev.Entry(len(contents))
+	assert.Error(t, err)
+}
+
+func TestPackfileReadsReverseIndexFile(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	p := writeTestReverseIndexPack(t, pd, "a\n", "b\n", "c\n")
+	defer p.Close()
+
+	generated, err := NewReverseIndex(p.idx)
+	require.NoError(t, err)
+
+	data := encodeTestReverseIndex(t, generated)
+	rev, err := DecodeReverseIndex(bytes.NewReader(data), p.idx, sha1.New())
+	require.NoError(t, err)
+	assert.Equal(t, generated.positions, rev.positions)
+
+	// Swap the first two positions, so that the reverse index read from
+	// disk can be told apart from one which was generated.
+	swapped := &ReverseIndex{idx: p.idx, positions: append([]uint32(nil), generated.positions...)}
+	swapped.positions[0], swapped.positions[1] = swapped.positions[1], swapped.positions[0]
+
+	path := strings.TrimSuffix(p.Path(), ".pack") + ".rev"
+	require.NoError(t, ioutil.WriteFile(path, encodeTestReverseIndex(t, swapped), 0644))
+
+	reopened, err := OpenPackfile(p.Path(), sha1.New())
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	rev, err = reopened.ReverseIndex()
+	require.NoError(t, err)
+	assert.Equal(t, swapped.positions, rev.positions)
+}
+
+func TestDecodeReverseIndexRejectsMismatchedFiles(t *testing.T) {
+	pd, err := ioutil.TempDir("", "gitobj-pack")
+	require.NoError(t, err)
+	defer os.RemoveAll(pd)
+
+	p := writeTestReverseIndexPack(t, pd, "a\n", "b\n")
+	defer p.Close()
+
+	rev, err := NewReverseIndex(p.idx)
+	require.NoError(t, err)
+	data := encodeTestReverseIndex(t, rev)
+
+	for desc, corrupt := range map[string]func([]byte){
+		"invalid reverse index header": func(data []byte) {
+			data[0] = 'X'
+		},
+		"unsupported version: 2": func(data []byte) {
+			data[7] = 2
+		},
+		"reverse index has hash version 2, expected 1": func(data []byte) {
+			data[11] = 2
+		},
+		"reverse index position 7 out of range": func(data []byte) {
+			data[reverseIndexHeaderWidth+3] = 7
+		},
+		"reverse index does not match packfile": func(data []byte) {
+			data[len(data)-2*sha1.Size] ^= 0xff
+		},
+	} {
+		corrupted := append([]byte(nil), data...)
+		corrupt(corrupted)
+
+		_, err := DecodeReverseIndex(bytes.NewReader(corrupted), p.idx, sha1.New())
+		if assert.Error(t, err, desc) {
+			assert.Contains(t, err.Error(), desc)
+		}
+	}
+
+	// An unusable reverse index is generated instead.
+	data[0] = 'X'
+	require.NoError(t, ioutil.WriteFile(strings.TrimSuffix(p.Path(), ".pack")+".rev", data, 0644))
+
+	got, err := p.ReverseIndex()
+	require.NoError(t, err)
+	assert.Equal(t, rev.positions, got.positions)
+}
+
+// writeTestReverseIndexPack writes a packfile holding a blob with each of the
+// given contents, in order, along with its index, to the directory "pd", and
+// returns it opened.
+func writeTestReverseIndexPack(t *testing.T, pd string, contents ...string) *Packfile {
+	var pack, idx bytes.Buffer
+
+	w, err := NewWriter(&pack, uint32(len(contents)), sha1.New())
+	require.NoError(t, err)
+	for _, data := range contents {
+		require.NoError(t, w.Add(objectName(TypeBlob, data), TypeBlob, int64(len(data)), strings.NewReader(data)))
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, w.WriteIndex(&idx))
+
+	base := filepath.Join(pd, fmt.Sprintf("pack-%x", w.Checksum()))
+	require.NoError(t, ioutil.WriteFile(base+".pack", pack.Bytes(), 0644))
+	require.NoError(t, ioutil.WriteFile(base+".idx", idx.Bytes(), 0644))
+
+	p, err := OpenPackfile(base+".pack", sha1.New())
+	require.NoError(t, err)
+	return p
+}
+
+// encodeTestReverseIndex returns the contents of a reverse index file holding
+// the given reverse index.
+func encodeTestReverseIndex(t *testing.T, rev *ReverseIndex) []byte {
+	data := append([]byte(nil), reverseIndexHeader...)
+	data = append(data, 0x0, 0x0, 0x0, reverseIndexVersion, 0x0, 0x0, 0x0, 0x1)
+	for _, at := range rev.positions {
+		var buf [reverseIndexEntryWidth]byte
+		binary.BigEndian.PutUint32(buf[:], at)
+		data = append(data, buf[:]...)
+	}
+
+	checksum, err := rev.idx.PackChecksum()
+	require.NoError(t, err)
+	data = append(data, checksum...)
+
+	sum := sha1.Sum(data)
+	return append(data, sum[:]...)
+}
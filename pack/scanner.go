@@ -0,0 +1,245 @@
+package pack
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// EntryHeader describes a single entry in a packfile, as given by its header,
+// without its contents.
+type EntryHeader struct {
+	// Name is the name of the object stored in the entry, if known. It is
+	// only known when the packfile is scanned along with its index.
+	Name []byte
+	// Offset is the offset of the entry (that is, of its header) in the
+	// packfile.
+	Offset int64
+	// Length is the number of bytes occupied by the entry in the packfile,
+	// including its header and compressed contents.
+	Length int64
+	// DataOffset is the offset in the packfile of the entry's compressed
+	// contents.
+	DataOffset int64
+
+	// Type is the type of the entry, which is either the type of the
+	// object it holds, or TypeObjectOffsetDelta or
+	// TypeObjectReferenceDelta.
+	Type PackedObjectType
+	// Size is the size of the entry's uncompressed contents. For deltas,
+	// this is the size of the delta instructions, not of the object they
+	// produce.
+	Size uint64
+
+	// BaseOffset is the offset in the packfile of the base of an
+	// OBJ_OFS_DELTA entry, and zero for other entries.
+	BaseOffset int64
+	// BaseName is the name of the base of an OBJ_REF_DELTA entry, and nil
+	// for other entries.
+	BaseName []byte
+}
+
+// Scanner reads the header of each entry in a packfile, in the order in which
+// they are stored, without reading their contents or resolving their
+// delta-base chains.
+//
+// If the packfile was opened along with its index (as by OpenPackfile), the
+// location of each entry is read from the index, and no entry is inflated.
+// Otherwise, each entry must be inflated (but not stored) to find the entry
+// following it.
+//
+// Its use is similar to that of a bufio.Scanner:
+//
+//	s := pack.NewScanner(p)
+//	for s.Scan() {
+//		hdr := s.Header()
+//		// ...
+//	}
+//	if err := s.Err(); err != nil {
+//		// ...
+//	}
+type Scanner struct {
+	// p is the packfile being scanned.
+	p *Packfile
+
+	// entries holds the name and offset of each entry, in order of their
+	// offsets, if they were read from the packfile's index.
+	entries []*indexedEntry
+	// n is the number of entries scanned so far.
+	n uint32
+	// next is the offset of the next entry to be scanned.
+	next int64
+
+	// hdr is the header of the most recently scanned entry.
+	hdr *EntryHeader
+	// err is the error encountered while scanning, if any.
+	err error
+}
+
+// NewScanner returns a new *Scanner which reads the headers of the entries in
+// the given packfile.
+func NewScanner(p *Packfile) *Scanner {
+	return &Scanner{p: p, next: 12}
+}
+
+// Scan advances the *Scanner to the next entry, which is then available through
+// the Header method. It returns false when every entry has been scanned, or an
+// error was encountered, in which case it is returned by Err.
+func (s *Scanner) Scan() bool {
+	s.hdr = nil
+	if s.err != nil || s.n >= s.p.Objects {
+		return false
+	}
+
+	if s.n == 0 && s.p.idx != nil {
+		if s.entries, s.err = indexedEntries(s.p); s.err != nil {
+			return false
+		}
+	}
+
+	hdr, err := s.p.entryHeader(s.next)
+	if err != nil {
+		s.err = err
+		return false
+	}
+
+	end, err := s.end(hdr)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	hdr.Length = end - hdr.Offset
+
+	if s.entries != nil {
+		hdr.Name = s.entries[s.n].name
+	}
+
+	s.hdr = hdr
+	s.next = end
+	s.n++
+	return true
+}
+
+// Header returns the header of the entry most recently scanned by Scan.
+func (s *Scanner) Header() *EntryHeader {
+	return s.hdr
+}
+
+// Err returns the first error encountered while scanning, if any.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// end returns the offset at which the given entry ends.
+func (s *Scanner) end(hdr *EntryHeader) (int64, error) {
+	if s.entries != nil {
+		if s.entries[s.n].offset != hdr.Offset {
+			return 0, fmt.Errorf("gitobj/pack: index does not match packfile at offset %d", hdr.Offset)
+		}
+		if int(s.n)+1 < len(s.entries) {
+			return s.entries[s.n+1].offset, nil
+		}
+		if size, ok := readerSize(s.p.r); ok {
+			return size - int64(s.p.hash.Size()), nil
+		}
+	}
+
+	br := &byteReaderAt{r: s.p.r, o: hdr.DataOffset}
+	zr, err := newZlibReader(br, s.p.unpooled)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(ioutil.Discard, zr); err != nil {
+		zr.Close()
+		return 0, err
+	}
+	if err := zr.Close(); err != nil {
+		return 0, err
+	}
+	return br.Offset(), nil
+}
+
+// entryHeader reads the header of the entry beginning at the given offset,
+// including the location of its base, if it is a delta. Its Name and Length
+// are not known, and are left unset.
+func (p *Packfile) entryHeader(offset int64) (*EntryHeader, error) {
+	typ, size, dataOffset, err := p.header(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := &EntryHeader{
+		Offset: offset,
+		Type:   typ,
+		Size:   size,
+	}
+
+	switch typ {
+	case TypeObjectOffsetDelta:
+		if hdr.BaseOffset, dataOffset, err = p.baseOffset(typ, dataOffset, offset); err != nil {
+			return nil, err
+		}
+	case TypeObjectReferenceDelta:
+		hdr.BaseName = make([]byte, p.hash.Size())
+		if _, err := p.r.ReadAt(hdr.BaseName, dataOffset); err != nil {
+			return nil, err
+		}
+		dataOffset += int64(len(hdr.BaseName))
+	case TypeCommit, TypeTree, TypeBlob, TypeTag:
+	default:
+		return nil, errUnrecognizedObjectType
+	}
+
+	hdr.DataOffset = dataOffset
+	return hdr, nil
+}
+
+// indexedEntries returns the name and offset of each object in the given
+// packfile's index, in order of their offsets.
+func indexedEntries(p *Packfile) ([]*indexedEntry, error) {
+	total := p.idx.count()
+	if total != int64(p.Objects) {
+		return nil, fmt.Errorf("gitobj/pack: index has %d object(s), expected %d", total, p.Objects)
+	}
+
+	entries := make([]*indexedEntry, 0, total)
+	for at := int64(0); at < total; at++ {
+		name, entry, err := p.idx.entryAt(at)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &indexedEntry{
+			name:   name,
+			offset: int64(entry.PackOffset),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].offset < entries[j].offset
+	})
+	return entries, nil
+}
+
+// readerSize returns the size of the data underlying the given io.ReaderAt, and
+// whether it could be determined.
+func readerSize(r io.ReaderAt) (int64, bool) {
+	type sizer interface {
+		Size() int64
+	}
+	type stater interface {
+		Stat() (os.FileInfo, error)
+	}
+
+	if s, ok := r.(sizer); ok {
+		return s.Size(), true
+	}
+	if s, ok := r.(stater); ok {
+		if fi, err := s.Stat(); err == nil {
+			return fi.Size(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,102 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScannerReadsEntryHeaders(t *testing.T) {
+	pack, refOffset, baseOffset, ofsOffset := deltaTestPack(t)
+
+	p, err := DecodePackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+
+	hdrs := scanAll(t, p)
+	require.Len(t, hdrs, 3)
+
+	assert.EqualValues(t, refOffset, hdrs[0].Offset)
+	assert.Equal(t, TypeObjectReferenceDelta, hdrs[0].Type)
+	assert.EqualValues(t, 15, hdrs[0].Size)
+	assert.Equal(t, objectName(TypeBlob, "Hello"), hdrs[0].BaseName)
+	assert.EqualValues(t, refOffset+1+sha1.Size, hdrs[0].DataOffset)
+	assert.EqualValues(t, baseOffset-refOffset, hdrs[0].Length)
+
+	assert.EqualValues(t, baseOffset, hdrs[1].Offset)
+	assert.Equal(t, TypeBlob, hdrs[1].Type)
+	assert.EqualValues(t, 5, hdrs[1].Size)
+	assert.Nil(t, hdrs[1].BaseName)
+	assert.EqualValues(t, 0, hdrs[1].BaseOffset)
+	assert.EqualValues(t, ofsOffset-baseOffset, hdrs[1].Length)
+
+	assert.EqualValues(t, ofsOffset, hdrs[2].Offset)
+	assert.Equal(t, TypeObjectOffsetDelta, hdrs[2].Type)
+	assert.EqualValues(t, baseOffset, hdrs[2].BaseOffset)
+	assert.EqualValues(t, ofsOffset+2, hdrs[2].DataOffset)
+	assert.EqualValues(t, len(pack)-sha1.Size-ofsOffset, hdrs[2].Length)
+
+	for _, hdr := range hdrs {
+		assert.Nil(t, hdr.Name)
+	}
+}
+
+func TestScannerReadsEntryLocationsFromTheIndex(t *testing.T) {
+	pack, _, _, _ := deltaTestPack(t)
+
+	iw, err := IndexPackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+
+	var idx bytes.Buffer
+	_, err = iw.WriteTo(&idx)
+	require.NoError(t, err)
+
+	p, err := DecodePackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+
+	withoutIndex := scanAll(t, p)
+
+	p.idx, err = DecodeIndex(bytes.NewReader(idx.Bytes()), sha1.New())
+	require.NoError(t, err)
+
+	withIndex := scanAll(t, p)
+	require.Len(t, withIndex, len(withoutIndex))
+
+	for i, hdr := range withIndex {
+		assert.Equal(t, withoutIndex[i].Offset, hdr.Offset)
+		assert.Equal(t, withoutIndex[i].Length, hdr.Length)
+	}
+
+	assert.Equal(t, objectName(TypeBlob, "Hello, world!\n"), withIndex[0].Name)
+	assert.Equal(t, objectName(TypeBlob, "Hello"), withIndex[1].Name)
+	assert.Equal(t, objectName(TypeBlob, "Hello!\n"), withIndex[2].Name)
+}
+
+func TestScannerReportsErrors(t *testing.T) {
+	pack, _, baseOffset, _ := deltaTestPack(t)
+	pack[baseOffset] = 0x50 // OBJ_5, which is reserved.
+
+	p, err := DecodePackfile(bytes.NewReader(pack), sha1.New())
+	require.NoError(t, err)
+
+	s := NewScanner(p)
+	assert.True(t, s.Scan())
+	assert.False(t, s.Scan())
+	assert.Nil(t, s.Header())
+	assert.Equal(t, errUnrecognizedObjectType, s.Err())
+}
+
+// scanAll returns the header of each entry in the given packfile.
+func scanAll(t *testing.T, p *Packfile) []*EntryHeader {
+	var hdrs []*EntryHeader
+
+	s := NewScanner(p)
+	for s.Scan() {
+		hdrs = append(hdrs, s.Header())
+	}
+	require.NoError(t, s.Err())
+
+	return hdrs
+}
@@ -3,21 +3,75 @@ package pack
 import (
 	"fmt"
 	"hash"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/git-lfs/gitobj/v2/errors"
 )
 
 // Set allows access of objects stored across a set of packfiles.
+//
+// A *Set is safe for concurrent use: objects may be read from it by many
+// goroutines at once, each inflating its object with a zlib reader of its own,
+// and reading the packfiles through their io.ReaderAt at offsets of its own.
+// The delta base cache shared by its packfiles is safe for concurrent use, and
+// packfiles found by a rescan are added under a lock. Methods which configure
+// the set, such as SetLimits and EnableVerification, must be called before any
+// objects are read.
 type Set struct {
+	// mu guards "m", "packs", "unpooled", "verify", "limits", and "cache"
+	// below, which change
+	// when packfiles are added to the set.
+	mu sync.RWMutex
 	// m maps the leading byte of a SHA-1 object name to a set of packfiles
 	// that might contain that object, in order of which packfile is most
 	// likely to contain that object.
 	m map[byte][]*Packfile
+	// packs holds every packfile in the set.
+	packs []*Packfile
+
+	// midx is the multi-pack-index covering some of the packfiles in the
+	// set, or nil if there is none. Packfiles which it covers are searched
+	// using it, rather than their own indexes, and are not present in "m".
+	midx *MultiPackIndex
+	// midxPacks holds the packfiles covered by "midx", in the order in
+	// which they are named by it.
+	midxPacks []*Packfile
+	// unpooled indicates whether pooling has been disabled for the
+	// packfiles in the set (see: DisablePooling).
+	unpooled bool
+	// verify indicates whether the packfiles in the set verify their
+	// checksums as they are read (see: EnableVerification).
+	verify bool
+	// limits bounds the objects read from the packfiles in the set (see:
+	// SetLimits).
+	limits Limits
+	// cache is the delta base cache shared by the packfiles in the set, or
+	// nil if delta bases are not cached.
+	cache *DeltaBaseCache
+
+	// files is the cache through which the files of the packfiles read
+	// from "dir" are opened, or nil if they are held open.
+	files *FileCache
+	// dir is the pack directory from which the packfiles were read, and
+	// algo the hash algorithm with which they were read, or the empty
+	// string and nil if the set was not read from disk (see: Reload).
+	dir  string
+	algo hash.Hash
+	// reloadMu serializes reloads of the set, and guards "scanned" and
+	// "rescanInterval".
+	reloadMu sync.Mutex
+	// scanned is the time at which "dir" was last scanned for packfiles.
+	scanned time.Time
+	// rescanInterval is the minimum interval between rescans of "dir"
+	// made when an object is not found (see: SetRescanInterval).
+	rescanInterval time.Duration
 
 	// closeFn is a function that is run by Close(), designated to free
 	// resources held by the *Set, like open packfiles.
@@ -39,17 +93,67 @@ var (
 // containing them. If there was an error parsing the packfiles in that
 // directory, or the directory was otherwise unable to be observed, NewSet
 // returns that error.
+//
+// If that directory also holds a multi-pack-index ("multi-pack-index"), objects
+// in the packfiles it covers are located using it, and only the remaining
+// packfiles are searched individually. As in Git, a multi-pack-index which
+// cannot be read, or which names a packfile that is not present, is ignored.
+//
+// Packfiles and their indexes are read with ReadAt (that is, pread(2)), and
+// are never memory-mapped, so that reading from a file truncated while it is
+// open (as by a concurrent repack on a network filesystem) fails with an error,
+// rather than raising SIGBUS.
 func NewSet(db string, algo hash.Hash) (*Set, error) {
+	return NewSetWithFileCache(db, algo, nil)
+}
+
+// NewSetWithFileCache creates a new *Set of all packfiles found in the given
+// object database's root, as NewSet does, but which reads the files of each
+// packfile (and of its index) through the given *FileCache, rather than
+// holding them open. A nil *FileCache holds them open, as does NewSet.
+//
+// Packfiles added to the set later (see: Add) are held open regardless.
+func NewSetWithFileCache(db string, algo hash.Hash, files *FileCache) (*Set, error) {
 	pd := filepath.Join(db, "pack")
 
-	paths, err := filepath.Glob(filepath.Join(escapeGlobPattern(pd), "*.pack"))
+	packs, byName, err := openPacks(pd, algo, nil, files)
 	if err != nil {
 		return nil, err
 	}
 
+	s := NewSetPacks(packs...)
+	s.dir, s.algo, s.files = pd, algo, files
+	s.scanned = time.Now()
+	if midx, covered := openMultiPackIndex(pd, algo, byName); midx != nil {
+		s.midx = midx
+		s.midxPacks = covered
+		s.m = indexPacks(s.uncovered())
+	}
+	return s, nil
+}
+
+// openPacks opens each packfile in the pack directory "pd" which has an index,
+// other than those whose basenames (ending in ".pack") are in "known", and
+// returns them, along with a mapping of the basename of each one's index to
+// the packfile.
+//
+// If "files" is not nil, the packfiles are read through it, rather than being
+// held open (see: NewSetWithFileCache). If a packfile cannot be opened, those
+// already opened are closed, and the error is returned.
+func openPacks(pd string, algo hash.Hash, known map[string]struct{}, files *FileCache) ([]*Packfile, map[string]*Packfile, error) {
+	paths, err := filepath.Glob(filepath.Join(escapeGlobPattern(pd), "*.pack"))
+	if err != nil {
+		return nil, nil, err
+	}
+
 	packs := make([]*Packfile, 0, len(paths))
+	byName := make(map[string]*Packfile, len(paths))
 
 	for _, path := range paths {
+		if _, ok := known[filepath.Base(path)]; ok {
+			continue
+		}
+
 		submatch := nameRe.FindStringSubmatch(filepath.Base(path))
 		if len(submatch) != 2 {
 			continue
@@ -57,40 +161,138 @@ func NewSet(db string, algo hash.Hash) (*Set, error) {
 
 		name := submatch[1]
 
-		idxf, err := os.Open(filepath.Join(pd, fmt.Sprintf("%s.idx", name)))
+		idxf, err := openPackFile(filepath.Join(pd, fmt.Sprintf("%s.idx", name)), files)
 		if err != nil {
 			// We have a pack (since it matched the regex), but the
 			// index is missing or unusable.  Skip this pack and
 			// continue on with the next one, as Git does.
-			if idxf != nil {
-				// In the unlikely event that we did open a
-				// file, close it, but discard any error in
-				// doing so.
-				idxf.Close()
-			}
 			continue
 		}
 
-		packf, err := os.Open(filepath.Join(pd, fmt.Sprintf("%s.pack", name)))
-		if err != nil {
-			return nil, err
+		pack, err := openPackfileWith(path, idxf, algo, files)
+		if os.IsNotExist(err) {
+			// The packfile was removed since the directory was
+			// read, as by a concurrent repack.
+			continue
 		}
-
-		pack, err := DecodePackfile(packf, algo)
 		if err != nil {
-			return nil, err
+			for _, p := range packs {
+				p.Close()
+			}
+			return nil, nil, err
 		}
 
-		idx, err := DecodeIndex(idxf, algo)
-		if err != nil {
-			return nil, err
+		packs = append(packs, pack)
+		byName[fmt.Sprintf("%s.idx", name)] = pack
+	}
+	return packs, byName, nil
+}
+
+// openMultiPackIndex opens the multi-pack-index in the given pack directory,
+// if there is one, and returns it along with the packfiles it covers, in the
+// order in which it names them, which are looked up by the name of their index
+// in "byName".
+//
+// If there is no usable multi-pack-index, nil is returned.
+func openMultiPackIndex(pd string, algo hash.Hash, byName map[string]*Packfile) (*MultiPackIndex, []*Packfile) {
+	f, err := os.Open(filepath.Join(pd, "multi-pack-index"))
+	if err != nil {
+		return nil, nil
+	}
+
+	midx, err := DecodeMultiPackIndex(f, algo)
+	if err != nil {
+		f.Close()
+		return nil, nil
+	}
+
+	covered := make([]*Packfile, 0, len(midx.Packs()))
+	for _, name := range midx.Packs() {
+		pack, ok := byName[name]
+		if !ok {
+			midx.Close()
+			return nil, nil
 		}
+		covered = append(covered, pack)
+	}
+	return midx, covered
+}
+
+// OpenPackfile opens the packfile at the given path (ending in ".pack") for
+// reading, along with its corresponding index (".idx") file.
+//
+// It is the caller's responsibility to close the returned *Packfile.
+func OpenPackfile(path string, algo hash.Hash) (*Packfile, error) {
+	idxf, err := os.Open(strings.TrimSuffix(path, ".pack") + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	return openPackfile(path, idxf, algo)
+}
 
-		pack.idx = idx
+// openPackFile opens the file at the given path, which belongs to a packfile,
+// either directly, or through "files", if it is not nil.
+func openPackFile(path string, files *FileCache) (readerAtCloser, error) {
+	if files != nil {
+		return files.open(path)
+	}
 
-		packs = append(packs, pack)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
-	return NewSetPacks(packs...), nil
+	return f, nil
+}
+
+// readerAtCloser is an io.ReaderAt which must be closed once it is no longer
+// needed, such as the file of a packfile or of its index.
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// openPackfile opens the packfile at the given path, and decodes it along with
+// the already-opened index "idxf". If an error is returned, "idxf" is closed.
+func openPackfile(path string, idxf *os.File, algo hash.Hash) (*Packfile, error) {
+	return openPackfileWith(path, idxf, algo, nil)
+}
+
+// openPackfileWith is as openPackfile, but opens the packfile through "files",
+// if it is not nil (see: openPackFile).
+func openPackfileWith(path string, idxf readerAtCloser, algo hash.Hash, files *FileCache) (*Packfile, error) {
+	packf, err := openPackFile(path, files)
+	if err != nil {
+		idxf.Close()
+		return nil, err
+	}
+	return decodePackfile(path, packf, idxf, algo)
+}
+
+// decodePackfile decodes the packfile at the given path from "packf", along with
+// its index "idxf". If an error is returned, both are closed.
+func decodePackfile(path string, packf, idxf readerAtCloser, algo hash.Hash) (*Packfile, error) {
+	pack, err := DecodePackfile(packf, algo)
+	if err != nil {
+		packf.Close()
+		idxf.Close()
+		return nil, err
+	}
+
+	idx, err := DecodeIndex(idxf, algo)
+	if err != nil {
+		packf.Close()
+		idxf.Close()
+		return nil, err
+	}
+
+	pack.idx = idx
+	pack.path = path
+
+	if _, err := os.Stat(strings.TrimSuffix(path, ".pack") + ".promisor"); err == nil {
+		pack.promisor = true
+	}
+
+	return pack, nil
 }
 
 // globEscapes uses these escapes because filepath.Glob does not understand
@@ -109,7 +311,201 @@ func escapeGlobPattern(s string) string {
 }
 
 // NewSetPacks creates a new *Set from the given packfiles.
+//
+// The packfiles share a *DeltaBaseCache holding up to
+// DefaultDeltaBaseCacheLimit bytes (see: SetDeltaBaseCacheLimit).
 func NewSetPacks(packs ...*Packfile) *Set {
+	s := &Set{
+		packs: packs,
+		cache: NewDeltaBaseCache(DefaultDeltaBaseCacheLimit),
+
+		rescanInterval: DefaultRescanInterval,
+	}
+	for _, pack := range packs {
+		pack.cache = s.cache
+	}
+	s.m = indexPacks(packs)
+	s.closeFn = func() error {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		for _, pack := range s.packs {
+			if err := pack.Close(); err != nil {
+				return err
+			}
+		}
+		if s.midx != nil {
+			return s.midx.Close()
+		}
+		return nil
+	}
+	return s
+}
+
+// Add adds the given packfiles to the set, for instance once they have been
+// newly written. The set takes ownership of them, and closes them when it is
+// closed.
+func (s *Set) Add(packs ...*Packfile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pack := range packs {
+		pack.unpooled = pack.unpooled || s.unpooled
+		pack.verify = pack.verify || s.verify
+		pack.limits = s.limits
+		pack.cache = s.cache
+	}
+
+	s.packs = append(s.packs, packs...)
+	s.m = indexPacks(s.uncovered())
+}
+
+// Snapshot returns a new *Set of the packfiles currently in the set, which is
+// never rescanned or reloaded, so that the objects read from it remain the same
+// while packfiles are added to (or removed from) the set's pack directory. The
+// packfiles are shared with the set: closing the snapshot does not close them,
+// and it may not be used once the set is closed.
+//
+// Packfiles removed from the pack directory after the snapshot is taken remain
+// readable only for as long as they are held open, so not if they are read
+// through a *FileCache (see: NewSetWithFileCache).
+func (s *Set) Snapshot() *Set {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &Set{
+		m:         s.m,
+		packs:     append([]*Packfile(nil), s.packs...),
+		midx:      s.midx,
+		midxPacks: s.midxPacks,
+		unpooled:  s.unpooled,
+		verify:    s.verify,
+		limits:    s.limits,
+		cache:     s.cache,
+
+		rescanInterval: -1,
+	}
+}
+
+// Packs returns each of the packfiles in the set, including any covered by its
+// multi-pack-index, in no particular order.
+func (s *Set) Packs() []*Packfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]*Packfile(nil), s.packs...)
+}
+
+// HasPromisorPacks returns whether any of the packfiles in the set is a promisor
+// pack (see: Packfile.IsPromisor).
+func (s *Set) HasPromisorPacks() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, pack := range s.packs {
+		if pack.IsPromisor() {
+			return true
+		}
+	}
+	return false
+}
+
+// DisablePooling causes the packfiles in the set, including any added later, to
+// allocate a new zlib reader for each object inflated, rather than drawing one
+// from a pool shared between them. It must be called before any objects are
+// read.
+func (s *Set) DisablePooling() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unpooled = true
+	for _, pack := range s.packs {
+		pack.unpooled = true
+	}
+}
+
+// EnableVerification causes the packfiles in the set, including any added later,
+// to verify their checksums as they are read, rather than trusting their
+// contents. The trailing checksums of each packfile and of its index are
+// verified when it is first read from (see: Packfile.VerifyChecksums), and the
+// packed data of each entry read is checked against the CRC32 recorded for it
+// in a version 2 index. A *CorruptPackErr (or *CorruptIndexErr) is returned
+// when they do not match. It must be called before any objects are read.
+func (s *Set) EnableVerification() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.verify = true
+	for _, pack := range s.packs {
+		pack.verify = true
+	}
+}
+
+// SetLimits bounds the delta depth and the size of the objects read from the
+// packfiles in the set, including any added later, so that packfiles crafted to
+// exhaust the memory or time of the reader are instead reported with a
+// *DeltaDepthExceededErr or an *ObjectTooLargeErr (see: Limits). It must be
+// called before any objects are read.
+func (s *Set) SetLimits(limits Limits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limits = limits
+	for _, pack := range s.packs {
+		pack.limits = limits
+	}
+}
+
+// SetDeltaBaseCacheLimit replaces the delta base cache shared by the packfiles
+// in the set, including any added later, with one holding at most "limit"
+// bytes, or disables caching delta bases if "limit" is not positive. It must be
+// called before any objects are read.
+func (s *Set) SetDeltaBaseCacheLimit(limit int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cache = nil
+	if limit > 0 {
+		s.cache = NewDeltaBaseCache(limit)
+	}
+	for _, pack := range s.packs {
+		pack.cache = s.cache
+	}
+}
+
+// DeltaBaseCache returns the delta base cache shared by the packfiles in the
+// set, or nil if delta bases are not cached.
+func (s *Set) DeltaBaseCache() *DeltaBaseCache {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cache
+}
+
+// uncovered returns the packfiles in the set which are not covered by its
+// multi-pack-index, if it has one. The caller must hold "mu".
+func (s *Set) uncovered() []*Packfile {
+	if s.midx == nil {
+		return s.packs
+	}
+
+	covered := make(map[*Packfile]struct{}, len(s.midxPacks))
+	for _, pack := range s.midxPacks {
+		covered[pack] = struct{}{}
+	}
+
+	packs := make([]*Packfile, 0, len(s.packs)-len(s.midxPacks))
+	for _, pack := range s.packs {
+		if _, ok := covered[pack]; !ok {
+			packs = append(packs, pack)
+		}
+	}
+	return packs
+}
+
+// indexPacks returns a mapping of the leading byte of a SHA-1 object name to
+// the packfiles that might contain that object (see: Set.m).
+func indexPacks(packs []*Packfile) map[byte][]*Packfile {
 	m := make(map[byte][]*Packfile)
 
 	for i := 0; i < 256; i++ {
@@ -137,20 +533,79 @@ func NewSetPacks(packs ...*Packfile) *Set {
 			return ni > nj
 		})
 	}
+	return m
+}
 
-	return &Set{
-		m:    m,
-		closeFn: func() error {
-			for _, pack := range packs {
-				if err := pack.Close(); err != nil {
-					return err
-				}
+// ForEach calls "fn" with the header of each entry in each packfile in the set
+// (see: Scanner), along with the packfile holding it. Since each packfile is
+// scanned along with its index, the name of each entry is known.
+//
+// ForEach tolerates packfiles being written and removed while it runs, as by a
+// concurrent "git gc": once the packfiles in the set have been scanned, the
+// set's pack directory is reloaded (unless rescans are disabled, see:
+// SetRescanInterval), and any packfiles added to the set in the meantime are
+// scanned in turn, until none remain. A packfile whose files are removed
+// before they can be read is skipped, having been replaced by another, so that
+// every entry of every packfile present throughout is visited, while those of
+// packfiles written or removed during the scan may or may not be.
+//
+// If "fn" returns an error, ForEach stops, and returns that error.
+func (s *Set) ForEach(fn func(p *Packfile, hdr *EntryHeader) error) error {
+	scanned := make(map[*Packfile]struct{})
+	packs := s.Packs()
+	for {
+		for _, p := range packs {
+			scanned[p] = struct{}{}
+			if err := scan(p, fn); err != nil {
+				return err
+			}
+		}
+		s.refresh()
+
+		packs = nil
+		for _, p := range s.Packs() {
+			if _, ok := scanned[p]; !ok {
+				packs = append(packs, p)
 			}
+		}
+		if len(packs) == 0 {
 			return nil
-		},
+		}
 	}
 }
 
+// scan calls "fn" with the header of each entry in the given packfile (see:
+// ForEach). If the packfile's files have been removed, as by a concurrent
+// repack, no error is returned.
+func scan(p *Packfile, fn func(p *Packfile, hdr *EntryHeader) error) error {
+	scanner := NewScanner(p)
+	for scanner.Scan() {
+		if err := fn(p, scanner.Header()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ForEachObject calls "fn" with the name, type, and size of each object in each
+// packfile in the set, resolving the types and sizes of deltas without
+// applying them. An object stored in more than one packfile is visited once
+// for each, including packfiles written during the enumeration (see: ForEach).
+//
+// If "fn" returns an error, ForEachObject stops, and returns that error.
+func (s *Set) ForEachObject(fn func(name []byte, typ PackedObjectType, size int64) error) error {
+	return s.ForEach(func(p *Packfile, hdr *EntryHeader) error {
+		typ, size, err := p.objectInfo(hdr)
+		if err != nil {
+			return err
+		}
+		return fn(hdr.Name, typ, size)
+	})
+}
+
 // Close closes all open packfiles, returning an error if one was encountered.
 func (s *Set) Close() error {
 	if s.closeFn == nil {
@@ -162,8 +617,9 @@ func (s *Set) Close() error {
 // Object opens (but does not unpack, or, apply the delta-base chain) a given
 // object in the first packfile that matches it.
 //
-// Object searches packfiles contained in the set in order of how many objects
-// they have that begin with the first by of the given SHA-1 "name", in
+// Object first searches the set's multi-pack-index, if it has one, and then
+// searches the remaining packfiles contained in the set in order of how many
+// objects they have that begin with the first by of the given SHA-1 "name", in
 // descending order.
 //
 // If the object was unable to be found in any of the packfiles, (nil,
@@ -173,12 +629,145 @@ func (s *Set) Close() error {
 // the packfiles, it will be returned, and no other packfiles will be searched.
 //
 // Otherwise, the object will be returned without error.
+//
+// If the object is not found, the set's pack directory is first rescanned for
+// packfiles written since it was read, as by a concurrent fetch or repack, and
+// the search repeated if any are found (see: SetRescanInterval).
 func (s *Set) Object(name []byte) (*Object, error) {
+	o, err := s.object(name)
+	if errors.IsNoSuchObject(err) && s.rescan() {
+		return s.object(name)
+	}
+	return o, err
+}
+
+// object opens the given object as Object does, without rescanning the pack
+// directory.
+func (s *Set) object(name []byte) (*Object, error) {
+	if o, err := s.midxObject(name); !IsNotFound(err) {
+		return o, err
+	}
+
 	return s.each(name, func(p *Packfile) (*Object, error) {
 		return p.Object(name)
 	})
 }
 
+// ObjectInfo returns the type and size of the object with the given name, as
+// would be given by Object(name).Type() and the length of its unpacked
+// contents, without reading the object's contents or resolving its delta-base
+// chain (see: Set.ForEachObject).
+//
+// If the object was unable to be found in any of the packfiles, an error
+// satisfying errors.IsNoSuchObject is returned, once the pack directory has
+// been rescanned as by Object.
+func (s *Set) ObjectInfo(name []byte) (PackedObjectType, int64, error) {
+	typ, size, err := s.objectInfo(name)
+	if errors.IsNoSuchObject(err) && s.rescan() {
+		return s.objectInfo(name)
+	}
+	return typ, size, err
+}
+
+// objectInfo returns the type and size of the given object as ObjectInfo does,
+// without rescanning the pack directory.
+func (s *Set) objectInfo(name []byte) (PackedObjectType, int64, error) {
+	s.mu.RLock()
+	midx, packs := s.midx, s.midxPacks
+	s.mu.RUnlock()
+
+	if midx != nil {
+		pack, offset, err := midx.Entry(name)
+		if err == nil {
+			return packs[pack].infoAt(offset)
+		}
+		if !IsNotFound(err) {
+			return TypeNone, 0, err
+		}
+	}
+
+	var typ PackedObjectType
+	var size int64
+
+	_, err := s.each(name, func(p *Packfile) (*Object, error) {
+		entry, err := p.idx.Entry(name)
+		if err != nil {
+			return nil, err
+		}
+
+		typ, size, err = p.infoAt(int64(entry.PackOffset))
+		return nil, err
+	})
+	if err != nil {
+		return TypeNone, 0, err
+	}
+	return typ, size, nil
+}
+
+// Has returns whether the object with the given name is held in any of the
+// packfiles, as found from their indexes alone, without reading any entry.
+//
+// As with Object, the pack directory is rescanned before the object is reported
+// missing.
+func (s *Set) Has(name []byte) (bool, error) {
+	ok, err := s.has(name)
+	if err == nil && !ok && s.rescan() {
+		return s.has(name)
+	}
+	return ok, err
+}
+
+// has returns whether the given object is held in any of the packfiles as Has
+// does, without rescanning the pack directory.
+func (s *Set) has(name []byte) (bool, error) {
+	s.mu.RLock()
+	midx := s.midx
+	s.mu.RUnlock()
+
+	if midx != nil {
+		_, _, err := midx.Entry(name)
+		if err == nil {
+			return true, nil
+		}
+		if !IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	_, err := s.each(name, func(p *Packfile) (*Object, error) {
+		_, err := p.idx.Entry(name)
+		return nil, err
+	})
+	if err != nil {
+		if errors.IsNoSuchObject(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// midxObject opens the given object from the packfile in which the set's
+// multi-pack-index locates it.
+//
+// If the set has no multi-pack-index, or the object is not present in it,
+// (nil, errNotFound) is returned.
+func (s *Set) midxObject(name []byte) (*Object, error) {
+	s.mu.RLock()
+	midx, packs := s.midx, s.midxPacks
+	s.mu.RUnlock()
+
+	if midx == nil {
+		return nil, errNotFound
+	}
+
+	pack, offset, err := midx.Entry(name)
+	if err != nil {
+		return nil, err
+	}
+	return packs[pack].objectAt(offset)
+}
+
 // iterFn is a function that takes a given packfile and opens an object from it.
 type iterFn func(p *Packfile) (o *Object, err error)
 
@@ -198,7 +787,11 @@ func (s *Set) each(name []byte, fn iterFn) (*Object, error) {
 		key = name[0]
 	}
 
-	for _, pack := range s.m[key] {
+	s.mu.RLock()
+	packs := s.m[key]
+	s.mu.RUnlock()
+
+	for _, pack := range packs {
 		o, err := fn(pack)
 		if err != nil {
 			if IsNotFound(err) {
@@ -2,8 +2,15 @@ package pack
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/git-lfs/gitobj/v2/errors"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -76,3 +83,272 @@ func TestSetOpenOpensPackedObjectsInPackOrder(t *testing.T) {
 	assert.EqualValues(t, visited[1].Objects, 2)
 	assert.EqualValues(t, visited[2].Objects, 1)
 }
+
+func TestSetWithAnEmptyPack(t *testing.T) {
+	p, err := DecodePackfile(bytes.NewReader(emptyPack()), sha1.New())
+	require.NoError(t, err)
+	p.idx, err = DecodeIndex(bytes.NewReader(emptyIndex()), sha1.New())
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 0, p.Objects)
+	assert.Equal(t, 0, p.idx.Count())
+
+	set := NewSetPacks(p)
+
+	o, err := set.Object(DecodeHex(t, "decafdecafdecafdecafdecafdecafdecafdecaf"))
+	assert.True(t, errors.IsNoSuchObject(err))
+	assert.Nil(t, o)
+
+	o, err = p.Object(DecodeHex(t, "0000000000000000000000000000000000000000"))
+	assert.True(t, IsNotFound(err))
+	assert.Nil(t, o)
+}
+
+func TestEmptyIndexRecordsPackChecksum(t *testing.T) {
+	idx, err := DecodeIndex(bytes.NewReader(emptyIndex()), sha1.New())
+	require.NoError(t, err)
+
+	sum, err := idx.PackChecksum()
+	require.NoError(t, err)
+
+	pack := emptyPack()
+	assert.Equal(t, pack[len(pack)-sha1.Size:], sum)
+}
+
+// emptyPack returns the contents of a version 2 packfile holding no objects.
+func emptyPack() []byte {
+	pack := []byte{'P', 'A', 'C', 'K', 0x0, 0x0, 0x0, 0x2, 0x0, 0x0, 0x0, 0x0}
+	sum := sha1.Sum(pack)
+
+	return append(pack, sum[:]...)
+}
+
+// emptyIndex returns the contents of a version 2 index for the packfile
+// returned by emptyPack().
+func emptyIndex() []byte {
+	idx := []byte{0xff, 0x74, 0x4f, 0x63, 0x0, 0x0, 0x0, 0x2}
+	idx = append(idx, make([]byte, indexFanoutWidth)...)
+
+	pack := emptyPack()
+	idx = append(idx, pack[len(pack)-sha1.Size:]...)
+
+	sum := sha1.Sum(idx)
+	return append(idx, sum[:]...)
+}
+
+func TestSetForEachVisitsEachPackedEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-set")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	_, aName := writeTestPack(t, pd, "a\n")
+	_, bName := writeTestPack(t, pd, "b\n")
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	seen := make(map[string]string)
+	err = set.ForEach(func(p *Packfile, hdr *EntryHeader) error {
+		assert.Equal(t, TypeBlob, hdr.Type)
+		seen[string(hdr.Name)] = p.Path()
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, seen, 2)
+	assert.Equal(t, pd, filepath.Dir(seen[string(aName)]))
+	assert.Equal(t, pd, filepath.Dir(seen[string(bName)]))
+	assert.NotEqual(t, seen[string(aName)], seen[string(bName)])
+}
+
+func TestSetObjectInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-set")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	_, name := writeTestPack(t, pd, "Hello, world!\n")
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	typ, size, err := set.ObjectInfo(name)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeBlob, typ)
+	assert.EqualValues(t, 14, size)
+
+	_, _, err = set.ObjectInfo(objectName(TypeBlob, "missing"))
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+func TestSetHasReadsOnlyIndexes(t *testing.T) {
+	const sha = "decafdecafdecafdecafdecafdecafdecafdecaf"
+
+	// The packfile holds no entries, so reading any would fail.
+	set := NewSetPacks(&Packfile{
+		idx: IndexWith(map[string]uint32{
+			sha: 0,
+		}),
+		r: bytes.NewReader(nil),
+	})
+
+	ok, err := set.Has(DecodeHex(t, sha))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = set.Has(DecodeHex(t, "cafecafecafecafecafecafecafecafecafecafe"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSetSnapshotIsNotReloaded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-set")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	_, first := writeTestPack(t, pd, "first\n")
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	snap := set.Snapshot()
+	defer snap.Close()
+
+	_, second := writeTestPack(t, pd, "second\n")
+	require.NoError(t, set.Reload())
+	require.NoError(t, snap.Reload())
+
+	_, err = set.Object(second)
+	assert.NoError(t, err)
+
+	_, err = snap.Object(first)
+	assert.NoError(t, err)
+	_, err = snap.Object(second)
+	assert.True(t, errors.IsNoSuchObject(err))
+}
+
+func TestSetDetectsPromisorPacks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-set")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	writeTestPack(t, pd, "a\n")
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	assert.False(t, set.HasPromisorPacks())
+	require.NoError(t, set.Close())
+
+	idx, _ := writeTestPack(t, pd, "b\n")
+	promisor := strings.TrimSuffix(idx, ".idx") + ".promisor"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pd, promisor), nil, 0644))
+
+	set, err = NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	assert.True(t, set.HasPromisorPacks())
+	for _, p := range set.Packs() {
+		assert.Equal(t, strings.HasSuffix(p.Path(), strings.TrimSuffix(promisor, ".promisor")+".pack"), p.IsPromisor())
+	}
+}
+
+func TestSetDisablePooling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-set")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	_, aName := writeTestPack(t, pd, "a\n")
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	set.DisablePooling()
+
+	bIdx, bName := writeTestPack(t, pd, "b\n")
+	b, err := OpenPackfile(filepath.Join(pd, strings.TrimSuffix(bIdx, ".idx")+".pack"), sha1.New())
+	require.NoError(t, err)
+	set.Add(b)
+
+	for _, pack := range set.packs {
+		assert.True(t, pack.unpooled, pack.Path())
+	}
+
+	for _, name := range [][]byte{aName, bName} {
+		o, err := set.Object(name)
+		require.NoError(t, err)
+		_, err = o.Unpack()
+		assert.NoError(t, err)
+	}
+}
+
+func TestSetDeltaBaseCacheLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-set")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pd := filepath.Join(dir, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+
+	writeTestPack(t, pd, "a\n")
+
+	set, err := NewSet(dir, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	cache := set.DeltaBaseCache()
+	require.NotNil(t, cache)
+	assert.EqualValues(t, DefaultDeltaBaseCacheLimit, cache.limit)
+	for _, pack := range set.packs {
+		assert.Equal(t, cache, pack.cache)
+	}
+
+	set.SetDeltaBaseCacheLimit(0)
+	assert.Nil(t, set.DeltaBaseCache())
+	for _, pack := range set.packs {
+		assert.Nil(t, pack.cache)
+	}
+}
+
+func TestSetReadingATruncatedPackReturnsAnError(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-pack-truncated")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	pd := filepath.Join(root, "pack")
+	require.NoError(t, os.Mkdir(pd, 0755))
+	idx, name := writeTestPack(t, pd, "Hello, world!\n")
+
+	set, err := NewSet(root, sha1.New())
+	require.NoError(t, err)
+	defer set.Close()
+
+	// Truncate the packfile while it is open, as a concurrent repack
+	// might.
+	pack := filepath.Join(pd, strings.TrimSuffix(idx, ".idx")+".pack")
+	require.NoError(t, os.Truncate(pack, 14))
+
+	o, err := set.Object(name)
+	if err == nil {
+		_, err = o.Unpack()
+	}
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package pack
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Stats counts the delta-base chains resolved as objects are unpacked, to help
+// diagnose slow reads: objects stored at the end of long chains are costly to
+// read, particularly when their bases are not held by the delta base cache. A
+// *Stats is carried by the context given when reading objects (see: WithStats).
+//
+// The zero value is ready to use. A nil *Stats counts nothing. A *Stats is safe
+// for concurrent use.
+type Stats struct {
+	// chains, deltas, and maxDepth are managed by sync/atomic.
+	chains   uint64
+	deltas   uint64
+	maxDepth uint64
+}
+
+// Chains returns the number of objects unpacked which were stored as deltas.
+func (s *Stats) Chains() uint64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&s.chains)
+}
+
+// Deltas returns the number of deltas applied in unpacking objects, which,
+// divided by the number of chains, gives their average length.
+func (s *Stats) Deltas() uint64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&s.deltas)
+}
+
+// MaxDepth returns the greatest number of deltas applied in unpacking a single
+// object.
+func (s *Stats) MaxDepth() int {
+	if s == nil {
+		return 0
+	}
+	return int(atomic.LoadUint64(&s.maxDepth))
+}
+
+// record records that an object was unpacked by applying "depth" deltas.
+func (s *Stats) record(depth int) {
+	if s == nil || depth == 0 {
+		return
+	}
+
+	atomic.AddUint64(&s.chains, 1)
+	atomic.AddUint64(&s.deltas, uint64(depth))
+	for {
+		max := atomic.LoadUint64(&s.maxDepth)
+		if uint64(depth) <= max ||
+			atomic.CompareAndSwapUint64(&s.maxDepth, max, uint64(depth)) {
+			return
+		}
+	}
+}
+
+// statsKey is the key under which a *Stats is stored in a context.
+type statsKey struct{}
+
+// WithStats returns a copy of the given context carrying the given *Stats, in
+// which the delta-base chains resolved as objects are read using it are
+// counted.
+func WithStats(ctx context.Context, s *Stats) context.Context {
+	return context.WithValue(ctx, statsKey{}, s)
+}
+
+// StatsFromContext returns the *Stats carried by the given context, or nil if
+// it carries none.
+func StatsFromContext(ctx context.Context) *Stats {
+	s, _ := ctx.Value(statsKey{}).(*Stats)
+	return s
+}
@@ -0,0 +1,51 @@
+package pack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsRecordsDeltaChains(t *testing.T) {
+	var stats Stats
+	ctx := WithStats(context.Background(), &stats)
+
+	chain := &ChainDelta{
+		base: &ChainDelta{
+			base: &ChainSimple{X: []byte{0x1, 0x2}},
+			delta: []byte{
+				0x2, 0x2, // Source size: 2, destination size: 2.
+				0x2, 0x3, 0x4, // Add: 0x3, 0x4.
+			},
+		},
+		delta: []byte{
+			0x2, 0x1, // Source size: 2, destination size: 1.
+			0x1, 0x5, // Add: 0x5.
+		},
+	}
+
+	data, err := unpackChain(ctx, chain)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x5}, data)
+
+	_, err = unpackChain(ctx, chain.base)
+	require.NoError(t, err)
+	_, err = unpackChain(ctx, &ChainSimple{X: []byte{0x1}})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 2, stats.Chains())
+	assert.EqualValues(t, 3, stats.Deltas())
+	assert.Equal(t, 2, stats.MaxDepth())
+}
+
+func TestNilStatsCountsNothing(t *testing.T) {
+	var stats *Stats
+	stats.record(1)
+
+	assert.Nil(t, StatsFromContext(context.Background()))
+	assert.EqualValues(t, 0, stats.Chains())
+	assert.EqualValues(t, 0, stats.Deltas())
+	assert.Equal(t, 0, stats.MaxDepth())
+}
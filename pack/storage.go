@@ -1,6 +1,7 @@
 package pack
 
 import (
+	"context"
 	"hash"
 	"io"
 )
@@ -8,24 +9,160 @@ import (
 // Storage implements the storage.Storage interface.
 type Storage struct {
 	packs *Set
+	// root is the objects directory holding the packfiles.
+	root string
 }
 
 // NewStorage returns a new storage object based on a pack set.
 func NewStorage(root string, algo hash.Hash) (*Storage, error) {
-	packs, err := NewSet(root, algo)
+	return NewStorageWithFileCache(root, algo, nil)
+}
+
+// NewStorageWithFileCache returns a new storage object based on a pack set
+// whose files are read through the given *FileCache (see:
+// NewSetWithFileCache).
+func NewStorageWithFileCache(root string, algo hash.Hash, files *FileCache) (*Storage, error) {
+	packs, err := NewSetWithFileCache(root, algo, files)
 	if err != nil {
 		return nil, err
 	}
-	return &Storage{packs: packs}, nil
+	return &Storage{packs: packs, root: root}, nil
+}
+
+// Snapshot returns a new storage object based on a snapshot of the storage's
+// pack set (see: Set.Snapshot).
+func (f *Storage) Snapshot() *Storage {
+	return &Storage{packs: f.packs.Snapshot(), root: f.root}
+}
+
+// Root returns the objects directory from which the packfiles were read.
+func (f *Storage) Root() string {
+	return f.root
 }
 
 // Open implements the storage.Storage.Open interface.
 func (f *Storage) Open(oid []byte) (r io.ReadCloser, err error) {
+	return f.OpenContext(context.Background(), oid)
+}
+
+// OpenContext implements the storage.ContextStorage.OpenContext interface.
+// Resolving the object's delta-base chain, which is deferred until it is
+// first read, is abandoned once the given context is done.
+func (f *Storage) OpenContext(ctx context.Context, oid []byte) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	obj, err := f.packs.Object(oid)
+	if err != nil {
+		return nil, err
+	}
+	return &delayedObjectReader{obj: obj, ctx: ctx}, nil
+}
+
+// ReadRange implements the storage.RangeStorage.ReadRange interface.
+func (f *Storage) ReadRange(oid []byte, off, n int64) (io.ReadCloser, error) {
 	obj, err := f.packs.Object(oid)
 	if err != nil {
 		return nil, err
 	}
-	return &delayedObjectReader{obj: obj}, nil
+	return obj.Range(off, n)
+}
+
+// ObjectInfo returns the type and size of the object with the given name,
+// without unpacking it (see: Set.ObjectInfo).
+func (f *Storage) ObjectInfo(oid []byte) (PackedObjectType, int64, error) {
+	return f.packs.ObjectInfo(oid)
+}
+
+// RawEntry returns the header of the packfile entry holding the object with the
+// given name, along with a reader over the entry as it is stored, without
+// inflating it (see: Set.RawEntry).
+func (f *Storage) RawEntry(oid []byte) (*EntryHeader, io.ReadCloser, error) {
+	return f.packs.RawEntry(oid)
+}
+
+// Add makes the objects in the given packfiles available for reading, for
+// instance once they have been newly written. The *Storage takes ownership of
+// them, and closes them when it is closed.
+func (f *Storage) Add(packs ...*Packfile) {
+	f.packs.Add(packs...)
+}
+
+// Reload adds each packfile written to the storage's pack directory since it was
+// last scanned to the storage (see: Set.Reload).
+func (f *Storage) Reload() error {
+	return f.packs.Reload()
+}
+
+// Packs returns each of the packfiles in the storage (see: Set.Packs).
+func (f *Storage) Packs() []*Packfile {
+	return f.packs.Packs()
+}
+
+// HasPromisorPacks returns whether any of the packfiles in the storage is a
+// promisor pack (see: Set.HasPromisorPacks).
+func (f *Storage) HasPromisorPacks() bool {
+	return f.packs.HasPromisorPacks()
+}
+
+// DisablePooling causes the packfiles in the storage, including any added later,
+// to allocate a new zlib reader for each object inflated (see:
+// Set.DisablePooling).
+func (f *Storage) DisablePooling() {
+	f.packs.DisablePooling()
+}
+
+// EnableVerification causes the packfiles in the storage, including any added
+// later, to verify their checksums as they are read (see:
+// Set.EnableVerification).
+func (f *Storage) EnableVerification() {
+	f.packs.EnableVerification()
+}
+
+// SetLimits bounds the delta depth and the size of the objects read from the
+// packfiles in the storage, including any added later (see: Set.SetLimits).
+func (f *Storage) SetLimits(limits Limits) {
+	f.packs.SetLimits(limits)
+}
+
+// SetDeltaBaseCacheLimit sets the number of bytes of delta bases cached while
+// reading from the packfiles in the storage (see: Set.SetDeltaBaseCacheLimit).
+func (f *Storage) SetDeltaBaseCacheLimit(limit int64) {
+	f.packs.SetDeltaBaseCacheLimit(limit)
+}
+
+// DeltaBaseCache returns the delta base cache shared by the packfiles in the
+// storage, or nil if delta bases are not cached (see: Set.DeltaBaseCache).
+func (f *Storage) DeltaBaseCache() *DeltaBaseCache {
+	return f.packs.DeltaBaseCache()
+}
+
+// ForEach calls "fn" with the header of each entry in each packfile, along with
+// the packfile holding it (see: Set.ForEach).
+func (f *Storage) ForEach(fn func(p *Packfile, hdr *EntryHeader) error) error {
+	return f.packs.ForEach(fn)
+}
+
+// ForEachObject calls "fn" with the name, type, and size of each object in each
+// packfile (see: Set.ForEachObject).
+func (f *Storage) ForEachObject(fn func(name []byte, typ PackedObjectType, size int64) error) error {
+	return f.packs.ForEachObject(fn)
+}
+
+// Has implements the storage.HasStorage interface by returning whether an
+// object with the given name is held in any packfile, as found from their
+// indexes alone (see: Set.Has).
+func (f *Storage) Has(oid []byte) (bool, error) {
+	return f.packs.Has(oid)
+}
+
+// Enumerate implements the storage.EnumerableStorage interface by calling "fn"
+// with the name of each object in each packfile (see: ForEachObject).
+func (f *Storage) Enumerate(fn func(oid []byte) error) error {
+	return f.packs.ForEachObject(func(name []byte, typ PackedObjectType, size int64) error {
+		return fn(name)
+	})
 }
 
 // Open implements the storage.Storage.Open interface.
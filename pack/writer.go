@@ -0,0 +1,195 @@
+package pack
+
+import (
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// packVersion is the version of the packfile format written by the
+	// Writer.
+	packVersion = 2
+)
+
+// Writer writes a stream of objects into a packfile, and can write a
+// corresponding (version 2) index once the packfile is complete.
+//
+// Each object is stored in its entirety (that is, not as a delta against any
+// other object) and compressed.
+type Writer struct {
+	// w is the underlying writer to which the packfile is written.
+	w io.Writer
+	// sum is the in-progress hash calculation of the packfile's contents,
+	// which is written as its trailer.
+	sum hash.Hash
+
+	// count is the number of objects declared in the packfile header.
+	count uint32
+	// offset is the number of bytes written so far.
+	offset int64
+
+	// entries holds the name, offset, and CRC of each object written so
+	// far, in order.
+	entries []*indexedEntry
+	// seen holds the name of each object written so far.
+	seen map[string]struct{}
+
+	// checksum is the trailing checksum of the packfile, which is non-nil
+	// once the packfile is closed.
+	checksum []byte
+}
+
+// NewWriter returns a new *Writer which writes a packfile holding exactly
+// "count" objects to "w", and writes the header of that packfile. "sum" is a
+// new instance of the hash algorithm used to name objects in the packfile.
+func NewWriter(w io.Writer, count uint32, sum hash.Hash) (*Writer, error) {
+	sum.Reset()
+
+	pw := &Writer{
+		w:     io.MultiWriter(w, sum),
+		sum:   sum,
+		count: count,
+		seen:  make(map[string]struct{}),
+	}
+
+	var hdr [12]byte
+	copy(hdr[:], packHeader)
+	binary.BigEndian.PutUint32(hdr[4:], packVersion)
+	binary.BigEndian.PutUint32(hdr[8:], count)
+
+	if err := pw.write(hdr[:]); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// Add writes the object named "name", of type "typ", whose "size" bytes of
+// uncompressed contents are read from "r", into the packfile.
+//
+// It returns an error if the object has already been written, if more objects
+// are written than were declared, or if "r" holds fewer than "size" bytes.
+func (w *Writer) Add(name []byte, typ PackedObjectType, size int64, r io.Reader) error {
+	if w.checksum != nil {
+		return fmt.Errorf("gitobj/pack: cannot write to closed packfile")
+	}
+	if typ < TypeCommit || typ > TypeTag {
+		return fmt.Errorf("gitobj/pack: cannot write object of type %s", typ)
+	}
+	if uint32(len(w.entries)) >= w.count {
+		return fmt.Errorf("gitobj/pack: too many objects, expected %d", w.count)
+	}
+	if _, ok := w.seen[string(name)]; ok {
+		return fmt.Errorf("gitobj/pack: duplicate object %x", name)
+	}
+
+	entry := &indexedEntry{
+		name:   append([]byte(nil), name...),
+		offset: w.offset,
+	}
+
+	crc := crc32.NewIEEE()
+	to := &entryWriter{w: w, crc: crc}
+
+	if _, err := to.Write(encodeEntryHeader(typ, size)); err != nil {
+		return err
+	}
+
+	zw := zlib.NewWriter(to)
+	if _, err := io.CopyN(zw, r, size); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	entry.crc = crc.Sum32()
+
+	w.entries = append(w.entries, entry)
+	w.seen[string(entry.name)] = struct{}{}
+	return nil
+}
+
+// Close writes the trailing checksum of the packfile. It returns an error if
+// fewer objects were written than were declared.
+//
+// Close does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.checksum != nil {
+		return nil
+	}
+	if n := uint32(len(w.entries)); n != w.count {
+		return fmt.Errorf("gitobj/pack: wrote %d object(s), expected %d", n, w.count)
+	}
+
+	checksum := w.sum.Sum(nil)
+	if _, err := w.w.Write(checksum); err != nil {
+		return err
+	}
+
+	w.checksum = checksum
+	return nil
+}
+
+// Checksum returns the trailing checksum of the packfile, by which it is
+// conventionally named, or nil if the packfile has not been closed.
+func (w *Writer) Checksum() []byte {
+	return w.checksum
+}
+
+// WriteIndex writes a version 2 index of the packfile to "to". The packfile
+// must have been closed.
+func (w *Writer) WriteIndex(to io.Writer) error {
+	if w.checksum == nil {
+		return fmt.Errorf("gitobj/pack: cannot index unfinished packfile")
+	}
+
+	iw := NewIndexWriter(w.checksum, w.sum)
+	iw.entries = w.entries
+
+	_, err := iw.WriteTo(to)
+	return err
+}
+
+// write writes "p" to the packfile, keeping track of the current offset.
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	return err
+}
+
+// entryWriter writes an object into a packfile, computing the CRC32 of its
+// encoded form as it does so.
+type entryWriter struct {
+	w   *Writer
+	crc hash.Hash32
+}
+
+// Write implements io.Writer.
+func (e *entryWriter) Write(p []byte) (int, error) {
+	if err := e.w.write(p); err != nil {
+		return 0, err
+	}
+	e.crc.Write(p)
+	return len(p), nil
+}
+
+// encodeEntryHeader returns the header of an object in a packfile, which
+// encodes its type and (uncompressed) size.
+func encodeEntryHeader(typ PackedObjectType, size int64) []byte {
+	hdr := []byte{byte(typ)<<4 | byte(size&0x0f)}
+	size >>= 4
+
+	for size > 0 {
+		hdr[len(hdr)-1] |= 0x80
+		hdr = append(hdr, byte(size&0x7f))
+		size >>= 7
+	}
+	return hdr
+}
@@ -0,0 +1,123 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterWritesReadablePackfiles(t *testing.T) {
+	objects := []struct {
+		typ  PackedObjectType
+		data string
+	}{
+		{TypeBlob, "Hello, world!\n"},
+		{TypeBlob, strings.Repeat("four", 64)},
+		{TypeCommit, "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\ninitial\n"},
+	}
+
+	var pack bytes.Buffer
+
+	w, err := NewWriter(&pack, uint32(len(objects)), sha1.New())
+	require.NoError(t, err)
+
+	names := make([][]byte, 0, len(objects))
+	for _, o := range objects {
+		name := objectName(o.typ, o.data)
+		names = append(names, name)
+
+		err := w.Add(name, o.typ, int64(len(o.data)), strings.NewReader(o.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	sum := sha1.Sum(pack.Bytes()[:pack.Len()-sha1.Size])
+	assert.Equal(t, sum[:], w.Checksum())
+	assert.Equal(t, sum[:], pack.Bytes()[pack.Len()-sha1.Size:])
+
+	var idx bytes.Buffer
+	require.NoError(t, w.WriteIndex(&idx))
+
+	sum = sha1.Sum(idx.Bytes()[:idx.Len()-sha1.Size])
+	assert.Equal(t, sum[:], idx.Bytes()[idx.Len()-sha1.Size:])
+
+	p, err := DecodePackfile(bytes.NewReader(pack.Bytes()), sha1.New())
+	require.NoError(t, err)
+	p.idx, err = DecodeIndex(bytes.NewReader(idx.Bytes()), sha1.New())
+	require.NoError(t, err)
+
+	assert.EqualValues(t, len(objects), p.Objects)
+	assert.Equal(t, len(objects), p.idx.Count())
+
+	checksum, err := p.idx.PackChecksum()
+	require.NoError(t, err)
+	assert.Equal(t, w.Checksum(), checksum)
+
+	for i, o := range objects {
+		obj, err := p.Object(names[i])
+		require.NoError(t, err)
+
+		assert.Equal(t, o.typ, obj.Type())
+
+		data, err := obj.Unpack()
+		require.NoError(t, err)
+		assert.Equal(t, o.data, string(data))
+	}
+}
+
+func TestWriterRejectsDuplicateObjects(t *testing.T) {
+	w, err := NewWriter(new(bytes.Buffer), 2, sha1.New())
+	require.NoError(t, err)
+
+	name := objectName(TypeBlob, "a")
+	require.NoError(t, w.Add(name, TypeBlob, 1, strings.NewReader("a")))
+
+	err = w.Add(name, TypeBlob, 1, strings.NewReader("a"))
+	assert.EqualError(t, err, fmt.Sprintf("gitobj/pack: duplicate object %x", name))
+}
+
+func TestWriterRejectsTooManyObjects(t *testing.T) {
+	w, err := NewWriter(new(bytes.Buffer), 1, sha1.New())
+	require.NoError(t, err)
+
+	require.NoError(t, w.Add(objectName(TypeBlob, "a"), TypeBlob, 1, strings.NewReader("a")))
+
+	err = w.Add(objectName(TypeBlob, "b"), TypeBlob, 1, strings.NewReader("b"))
+	assert.EqualError(t, err, "gitobj/pack: too many objects, expected 1")
+}
+
+func TestWriterRejectsTooFewObjects(t *testing.T) {
+	w, err := NewWriter(new(bytes.Buffer), 2, sha1.New())
+	require.NoError(t, err)
+
+	require.NoError(t, w.Add(objectName(TypeBlob, "a"), TypeBlob, 1, strings.NewReader("a")))
+
+	assert.EqualError(t, w.Close(), "gitobj/pack: wrote 1 object(s), expected 2")
+	assert.Nil(t, w.Checksum())
+}
+
+func TestWriterRejectsShortContents(t *testing.T) {
+	w, err := NewWriter(new(bytes.Buffer), 1, sha1.New())
+	require.NoError(t, err)
+
+	err = w.Add(objectName(TypeBlob, "ab"), TypeBlob, 2, strings.NewReader("a"))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestWriterEncodesEntryHeaders(t *testing.T) {
+	assert.Equal(t, []byte{0x3e}, encodeEntryHeader(TypeBlob, 14))
+	assert.Equal(t, []byte{0x90, 0x10}, encodeEntryHeader(TypeCommit, 256))
+	assert.Equal(t, []byte{0xbf, 0xff, 0x7f}, encodeEntryHeader(TypeBlob, 1<<18-1))
+}
+
+// objectName returns the name of an object of the given type and contents.
+func objectName(typ PackedObjectType, data string) []byte {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s %d\x00%s", typ, len(data), data)))
+	return sum[:]
+}
@@ -0,0 +1,9 @@
+// +build !windows
+
+package gitobj
+
+// longPath returns the given path as-is, since only Windows limits the length
+// of paths (see: path_windows.go).
+func longPath(path string) string {
+	return path
+}
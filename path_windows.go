@@ -0,0 +1,33 @@
+// +build windows
+
+package gitobj
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// longPath returns the given path in its extended-length form, prefixed by
+// `\\?\` (or `\\?\UNC\`, for a path on a network share), so that objects may be
+// read and written beneath directories nested deeper than MAX_PATH allows, as
+// Git for Windows does with "core.longPaths" set. The path is made absolute and
+// cleaned first, since extended-length paths are given to the file system
+// as-is, without "." and ".." being resolved, or "/" being taken as a
+// separator.
+//
+// Paths which are empty, or already in extended-length (or device) form, are
+// returned as-is, as are those which cannot be made absolute.
+func longPath(path string) string {
+	if len(path) == 0 || strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,45 @@
+//go:build windows
+// +build windows
+
+package gitobj
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLongPath(t *testing.T) {
+	for path, want := range map[string]string{
+		``:                         ``,
+		`C:\repo\.git\objects`:     `\\?\C:\repo\.git\objects`,
+		`C:/repo/.git/../objects`:  `\\?\C:\repo\objects`,
+		`\\server\share\objects`:   `\\?\UNC\server\share\objects`,
+		`//server/share/objects`:   `\\?\UNC\server\share\objects`,
+		`\\?\C:\repo\.git\objects`: `\\?\C:\repo\.git\objects`,
+		`\\?\UNC\server\share\x`:   `\\?\UNC\server\share\x`,
+		`\\.\pipe\not-a-directory`: `\\.\pipe\not-a-directory`,
+	} {
+		assert.Equal(t, want, longPath(path), path)
+	}
+}
+
+func TestRootIsReportedAsGiven(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	dir, ok := db.Root()
+	assert.True(t, ok)
+	assert.Equal(t, root, dir)
+}
@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package gitobj
+
+// raceEnabled indicates whether the tests were built with the race detector,
+// under which allocation counts are not reliable.
+const raceEnabled = true
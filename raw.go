@@ -0,0 +1,175 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// RawObject is an object exactly as it is stored, without having been inflated,
+// so that it may be copied (as by a mirror, or a backup) without being inflated
+// and compressed again (see: OpenRaw).
+type RawObject struct {
+	// Entry is the header of the packfile entry holding the object, if it
+	// is packed, in which case Contents holds the entry as it is stored in
+	// the packfile (see: pack.Packfile.RawEntry). It is nil if the object
+	// is stored loose, in which case Contents holds the compressed loose
+	// object, as it is stored in the objects directory.
+	Entry *pack.EntryHeader
+	// Contents is the stored form of the object.
+	Contents io.ReadCloser
+}
+
+// Close closes the object's contents.
+func (r *RawObject) Close() error {
+	return r.Contents.Close()
+}
+
+// OpenRaw opens the stored form of the object named "sha" for reading, which is
+// searched for in the same order as when reading the object itself, but is not
+// replaced (see: ReplaceObjects). The contents of the object are not inflated,
+// and so not decoded.
+//
+// The checksums of the object are verified as it is read, and an error is
+// returned in place of io.EOF if they do not match: the CRC32 recorded in the
+// index of a packed object's packfile, where there is one, or, for a loose
+// object, the checksum trailing its compressed data, and its name. Verifying
+// a loose object requires inflating it as it is read, though it is not
+// compressed again.
+func (o *ObjectDatabase) OpenRaw(sha []byte) (*RawObject, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	for _, s := range storages(o.ro) {
+		var raw *RawObject
+
+		switch s := s.(type) {
+		case *pack.Storage:
+			hdr, r, err := s.RawEntry(sha)
+			if err != nil {
+				if errors.IsNoSuchObject(err) {
+					continue
+				}
+				return nil, err
+			}
+			raw = &RawObject{Entry: hdr, Contents: r}
+		default:
+			if !s.IsCompressed() {
+				continue
+			}
+
+			r, err := s.Open(sha)
+			if err != nil {
+				if errors.IsNoSuchObject(err) {
+					continue
+				}
+				return nil, err
+			}
+
+			if raw, err = o.verifiedLoose(sha, r); err != nil {
+				r.Close()
+				return nil, err
+			}
+		}
+		return raw, nil
+	}
+
+	if o.hasPromisorPacks() {
+		return nil, errors.MissingPromisorObject(sha)
+	}
+	return nil, errors.NoSuchObject(sha)
+}
+
+// verifiedLoose returns a *RawObject over the compressed loose object "r",
+// which verifies the object as it is read.
+func (o *ObjectDatabase) verifiedLoose(sha []byte, r io.ReadCloser) (*RawObject, error) {
+	v := &verifiedLooseReader{
+		r:    r,
+		sha:  sha,
+		hash: o.Hasher(),
+		buf:  make([]byte, 32*1024),
+	}
+
+	zr, err := o.compressor.NewReader(io.TeeReader(r, &v.raw))
+	if err != nil {
+		return nil, err
+	}
+	v.zr = zr
+
+	return &RawObject{Contents: v}, nil
+}
+
+// verifiedLooseReader is an io.ReadCloser over a compressed loose object, which
+// inflates (and hashes) the object as its compressed data is read, to verify
+// the compressed data's checksum, and the object's name.
+type verifiedLooseReader struct {
+	// r is the compressed loose object, and zr the decompressor over it,
+	// whose input is captured in "raw".
+	r  io.ReadCloser
+	zr io.ReadCloser
+	// raw holds compressed data read by "zr" which has yet to be returned.
+	raw bytes.Buffer
+
+	// sha is the name of the object, and hash the hash of its inflated
+	// contents so far.
+	sha  []byte
+	hash hash.Hash
+	// buf receives the inflated contents of the object, which are
+	// discarded once hashed.
+	buf []byte
+
+	// done indicates whether the object has been inflated in full, and
+	// err holds the error encountered in doing so, if any.
+	done bool
+	err  error
+}
+
+// Read implements io.Reader, returning an error in place of io.EOF if the
+// object cannot be inflated, or is misnamed.
+func (v *verifiedLooseReader) Read(p []byte) (int, error) {
+	for v.raw.Len() == 0 && !v.done {
+		n, err := v.zr.Read(v.buf)
+		v.hash.Write(v.buf[:n])
+
+		if err == io.EOF {
+			v.done = true
+			v.err = v.finish()
+		} else if err != nil {
+			v.done = true
+			v.err = err
+		}
+	}
+
+	if v.raw.Len() > 0 {
+		return v.raw.Read(p)
+	}
+	if v.err != nil {
+		return 0, v.err
+	}
+	return 0, io.EOF
+}
+
+// finish verifies the object's name once it has been inflated in full, and
+// captures any data trailing its compressed contents, so that it too is
+// copied.
+func (v *verifiedLooseReader) finish() error {
+	if _, err := io.Copy(&v.raw, v.r); err != nil {
+		return err
+	}
+
+	if sum := v.hash.Sum(nil); !bytes.Equal(sum, v.sha) {
+		return fmt.Errorf("gitobj: loose object %x hashes to %x", v.sha, sum)
+	}
+	return nil
+}
+
+// Close implements io.Closer.
+func (v *verifiedLooseReader) Close() error {
+	v.zr.Close()
+	return v.r.Close()
+}
@@ -0,0 +1,171 @@
+package gitobj
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// WriteRawObject stores the object of the given type whose encoded contents,
+// of the given size, are read from "r", and returns the name under which it is
+// stored, or an error if one was encountered.
+//
+// The contents are streamed into storage, and hashed as they are, without being
+// decoded and encoded again, as WriteTree, WriteCommit, and WriteTag would, so
+// that objects relayed from another repository are stored byte-for-byte (and
+// so keep any signatures over them valid). For the same reason, the contents
+// are neither validated (see: StrictWrites) nor sorted (see: CanonicalTrees).
+//
+// If "r" yields more or fewer than "size" bytes, nothing is written, and an
+// error is returned.
+func (o *ObjectDatabase) WriteRawObject(typ ObjectType, size int64, r io.Reader) ([]byte, error) {
+	return o.WriteRawObjectContext(context.Background(), typ, size, r)
+}
+
+// WriteRawObjectContext is as WriteRawObject, but abandons writing the object,
+// and returns the context's error, once the given context is done.
+func (o *ObjectDatabase) WriteRawObjectContext(ctx context.Context, typ ObjectType, size int64, r io.Reader) ([]byte, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	switch typ {
+	case BlobObjectType, TreeObjectType, CommitObjectType, TagObjectType:
+	default:
+		return nil, fmt.Errorf("gitobj: cannot write object of type %q", typ)
+	}
+	return o.writeRaw(ctx, nil, typ, size, r)
+}
+
+const (
+	// spoolMemoryLimit is the number of bytes of a blob of unknown size
+	// which are held in memory while finding its size, beyond which it is
+	// spooled to a temporary file instead (see: WriteBlobFromReader).
+	spoolMemoryLimit = 1 << 20
+)
+
+// WriteBlobFromReader stores the blob whose contents are read from "r", whose
+// size is not known in advance, as when reading from a pipe or a filter, and
+// returns the name under which it is stored, or an error if one was
+// encountered, as with "git hash-object -w --stdin".
+//
+// Since an object's header gives its size, the contents are first read in
+// full: into memory, if they are small, and otherwise into a temporary file,
+// which is removed once the blob is written.
+func (o *ObjectDatabase) WriteBlobFromReader(r io.Reader) ([]byte, error) {
+	return o.WriteBlobFromReaderContext(context.Background(), r)
+}
+
+// WriteBlobFromReaderContext is as WriteBlobFromReader, but abandons writing
+// the blob, and returns the context's error, once the given context is done.
+func (o *ObjectDatabase) WriteBlobFromReaderContext(ctx context.Context, r io.Reader) ([]byte, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+	r = &contextReader{ctx: ctx, r: r}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, spoolMemoryLimit)
+	if err == io.EOF {
+		return o.writeRaw(ctx, nil, BlobObjectType, n, &buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	spool, err := ioutil.TempFile(o.tmp, "")
+	if err != nil {
+		return nil, err
+	}
+	defer o.cleanup(spool)
+
+	rest, err := io.Copy(spool, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return o.writeRaw(ctx, nil, BlobObjectType, n+rest, io.MultiReader(&buf, spool))
+}
+
+// writeRaw writes the object of the given type and size, whose encoded contents
+// are read from "r", and returns its name. If "want" is not nil, the object is
+// written only if it is named "want"; otherwise, it is not written, and an
+// error is returned.
+func (d *ObjectDatabase) writeRaw(ctx context.Context, want []byte, typ ObjectType, size int64, r io.Reader) ([]byte, error) {
+	// Read one byte more than the size given, so that an object which is
+	// longer than it claims to be is detected.
+	r = io.LimitReader(r, size+1)
+
+	var compat []byte
+	if d.compat != nil {
+		// The object must be read twice to compute its name in
+		// the compatibility object format, so hold it in memory.
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, &contextReader{ctx: ctx, r: r}); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if compat, err = d.encodedCompatSha(typ, size, &buf); err != nil {
+			return nil, err
+		}
+		r = &buf
+	}
+
+	tmp, err := ioutil.TempFile(d.tmp, "")
+	if err != nil {
+		return nil, err
+	}
+	defer d.cleanup(tmp)
+
+	zw, err := d.compressor.NewWriter(tmp, d.compressionLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	to := newObjectWriteCloser(&nopCloser{tmp}, zw, d.Hasher())
+	if _, err = to.WriteHeader(typ, size); err != nil {
+		return nil, err
+	}
+
+	n, err := io.Copy(to, &contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return nil, err
+	}
+	if n != size {
+		if want == nil {
+			return nil, fmt.Errorf("gitobj: %s has %d byte(s), expected %d", typ, n, size)
+		}
+		return nil, fmt.Errorf("gitobj: object %x has %d byte(s), expected %d", want, n, size)
+	}
+
+	if err = to.Close(); err != nil {
+		return nil, err
+	}
+	sha := to.Sha()
+	if want != nil && !bytes.Equal(sha, want) {
+		return nil, fmt.Errorf("gitobj: object %x has contents of %x", want, sha)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	_, n, err = d.save(ctx, sha, tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	if compat != nil {
+		if err = d.compat.add(sha, compat); err != nil {
+			return nil, err
+		}
+	}
+
+	d.writes.add(typ, size, n)
+	return sha, nil
+}
@@ -0,0 +1,81 @@
+package gitobj
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRawObjectStoresContentsExactly(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	// The double space after the author would not survive decoding and
+	// encoding the commit again.
+	contents := fmt.Sprintf("tree %x\n"+
+		"author A U Thor  <author@example.com> 1234567890 +0000\n"+
+		"committer A U Thor <author@example.com> 1234567890 +0000\n"+
+		"\n"+
+		"Initial commit\n", make([]byte, 20))
+
+	sha, err := db.WriteRawObject(CommitObjectType, int64(len(contents)), strings.NewReader(contents))
+	require.NoError(t, err)
+
+	want := sha1.Sum([]byte(fmt.Sprintf("commit %d\x00%s", len(contents), contents)))
+	assert.Equal(t, want[:], sha)
+
+	r, err := db.open(context.Background(), sha)
+	require.NoError(t, err)
+	defer r.Close()
+
+	typ, size, err := r.Header()
+	require.NoError(t, err)
+	assert.Equal(t, CommitObjectType, typ)
+	assert.EqualValues(t, len(contents), size)
+
+	stored, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, contents, string(stored))
+}
+
+func TestWriteRawObjectRejectsIncorrectSize(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	_, err := db.WriteRawObject(BlobObjectType, 5, strings.NewReader("Hello, world!\n"))
+	assert.EqualError(t, err, "gitobj: blob has 6 byte(s), expected 5")
+
+	_, err = db.WriteRawObject(BlobObjectType, 20, strings.NewReader("Hello, world!\n"))
+	assert.EqualError(t, err, "gitobj: blob has 14 byte(s), expected 20")
+
+	assert.Empty(t, db.rw.(*memoryStorer).fs)
+}
+
+func TestWriteRawObjectRejectsUnknownTypes(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	_, err := db.WriteRawObject(UnknownObjectType, 0, strings.NewReader(""))
+	assert.EqualError(t, err, `gitobj: cannot write object of type "unknown"`)
+}
+
+func TestWriteBlobFromReader(t *testing.T) {
+	for _, size := range []int{0, 14, spoolMemoryLimit, spoolMemoryLimit + 1} {
+		db := newTestMemoryDatabase(t)
+		data := strings.Repeat("x", size)
+
+		sha, err := db.WriteBlobFromReader(strings.NewReader(data))
+		require.NoError(t, err)
+
+		want := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", size, data)))
+		assert.Equal(t, want[:], sha, "size %d", size)
+
+		blob, err := db.Blob(sha)
+		require.NoError(t, err)
+		assert.EqualValues(t, size, blob.Size)
+		require.NoError(t, blob.Close())
+	}
+}
@@ -0,0 +1,142 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenRawLooseObject(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-raw")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	raw, err := db.OpenRaw(sha)
+	require.NoError(t, err)
+	defer raw.Close()
+
+	assert.Nil(t, raw.Entry)
+
+	data, err := ioutil.ReadAll(raw.Contents)
+	require.NoError(t, err)
+
+	hexSha := hex.EncodeToString(sha)
+	stored, err := ioutil.ReadFile(filepath.Join(root, hexSha[:2], hexSha[2:]))
+	require.NoError(t, err)
+	assert.Equal(t, stored, data)
+}
+
+func TestOpenRawDetectsMisnamedLooseObjects(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	// Store a different object under the blob's name.
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte("blob 6\x00other\n"))
+	require.NoError(t, zw.Close())
+
+	misnamed := make([]byte, len(sha))
+	copy(misnamed, sha)
+	misnamed[0] ^= 0xff
+	_, err = db.rw.Store(misnamed, &buf)
+	require.NoError(t, err)
+
+	raw, err := db.OpenRaw(misnamed)
+	require.NoError(t, err)
+	defer raw.Close()
+
+	_, err = ioutil.ReadAll(raw.Contents)
+	assert.Contains(t, err.Error(), "hashes to")
+}
+
+func TestOpenRawPackedObject(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-raw")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	sha, err := packed.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, packed.Close())
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	raw, err := db.OpenRaw(sha)
+	require.NoError(t, err)
+	defer raw.Close()
+
+	require.NotNil(t, raw.Entry)
+	assert.Equal(t, pack.TypeBlob, raw.Entry.Type)
+	assert.EqualValues(t, 14, raw.Entry.Size)
+
+	data, err := ioutil.ReadAll(raw.Contents)
+	require.NoError(t, err)
+	require.EqualValues(t, raw.Entry.Length, len(data))
+
+	zr, err := zlib.NewReader(bytes.NewReader(data[raw.Entry.DataOffset-raw.Entry.Offset:]))
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("Hello, world!\n"), contents)
+}
+
+func TestOpenRawDetectsCorruptPackEntries(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-raw")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+	sha, err := packed.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	require.NoError(t, packed.Close())
+
+	paths, err := filepath.Glob(filepath.Join(root, "pack", "*.pack"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	data, err := ioutil.ReadFile(paths[0])
+	require.NoError(t, err)
+	data[len(data)-packed.Hasher().Size()-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(paths[0], data, 0644))
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	raw, err := db.OpenRaw(sha)
+	require.NoError(t, err)
+	defer raw.Close()
+
+	_, err = ioutil.ReadAll(raw.Contents)
+	assert.IsType(t, &pack.CorruptPackErr{}, err)
+}
+
+func TestOpenRawMissingObject(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	_, err := db.OpenRaw(make([]byte, 20))
+	assert.True(t, errors.IsNoSuchObject(err))
+}
@@ -0,0 +1,143 @@
+package gitobj
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// ObjectReadFunc is a callback given to ReadObjects, which is called with the
+// name of each object read (as it was given to ReadObjects), its type and
+// (uncompressed) size, and a reader of its contents. The reader is only valid
+// until the callback returns, and need not be read in full.
+//
+// If it returns an error, reading stops, and the error is returned by
+// ReadObjects.
+type ObjectReadFunc func(oid []byte, typ ObjectType, size int64, contents io.Reader) error
+
+// objectRead is an object to be read by ReadObjects.
+type objectRead struct {
+	// oid is the name of the object, as it was given to ReadObjects.
+	oid []byte
+	// sha is the name of the object as it is stored, after any
+	// translation or replacement.
+	sha []byte
+	// offset is the offset of the object's entry in the packfile
+	// holding it, if it is packed.
+	offset uint64
+}
+
+// ReadObjects reads each of the objects with the given names, calling "fn" with
+// the contents of each, in the order in which they are stored, rather than that
+// in which they are given, so as to read packfiles sequentially, which is much
+// faster than reading them at random when extracting many objects from a cold
+// cache. Packed objects are read first, grouped by packfile, in the order of
+// their offsets, followed by loose objects (and any held by other storage) in
+// the order in which they were given. Each object is read only once, however
+// many times it is given.
+//
+// Packed objects whose entries are deltas are still read in full, and may
+// require their delta bases to be read from elsewhere in the packfile.
+//
+// If any object is missing, or cannot be read, an error is returned once it is
+// reached, and no further objects are read.
+func (o *ObjectDatabase) ReadObjects(oids [][]byte, fn ObjectReadFunc) error {
+	return o.ReadObjectsContext(context.Background(), oids, fn)
+}
+
+// ReadObjectsContext is as ReadObjects, but stops reading, and returns the
+// context's error, once the given context is done.
+func (o *ObjectDatabase) ReadObjectsContext(ctx context.Context, oids [][]byte, fn ObjectReadFunc) error {
+	if o.isClosed() {
+		return ErrDatabaseClosed
+	}
+
+	var packs []*pack.Packfile
+	for _, s := range storages(o.ro) {
+		if s, ok := s.(*pack.Storage); ok {
+			packs = append(packs, s.Packs()...)
+		}
+	}
+
+	byPack := make([][]*objectRead, len(packs))
+	var unpacked []*objectRead
+
+	seen := make(map[string]struct{}, len(oids))
+	for _, oid := range oids {
+		if _, ok := seen[string(oid)]; ok {
+			continue
+		}
+		seen[string(oid)] = struct{}{}
+
+		sha, err := o.replace(oid)
+		if err != nil {
+			return err
+		}
+
+		r := &objectRead{oid: oid, sha: sha}
+		found := false
+		for i, p := range packs {
+			if p.Index() == nil {
+				continue
+			}
+
+			e, err := p.Index().Entry(sha)
+			if err != nil {
+				if pack.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+
+			r.offset = e.PackOffset
+			byPack[i] = append(byPack[i], r)
+			found = true
+			break
+		}
+		if !found {
+			unpacked = append(unpacked, r)
+		}
+	}
+
+	var ordered []*objectRead
+	for _, reads := range byPack {
+		sort.Slice(reads, func(i, j int) bool {
+			return reads[i].offset < reads[j].offset
+		})
+		ordered = append(ordered, reads...)
+	}
+	ordered = append(ordered, unpacked...)
+
+	for _, r := range ordered {
+		if err := o.readObject(ctx, r, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readObject opens and reads the object to be read by ReadObjects, calling "fn"
+// with its contents.
+func (o *ObjectDatabase) readObject(ctx context.Context, r *objectRead, fn ObjectReadFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	or, err := o.openExact(ctx, r.sha)
+	if err != nil {
+		return err
+	}
+	defer or.Close()
+
+	if err := o.checkAllowed(r.sha, or); err != nil {
+		return err
+	}
+
+	typ, size, err := or.Header()
+	if err != nil {
+		return err
+	}
+	return fn(r.oid, typ, size, io.LimitReader(or, size))
+}
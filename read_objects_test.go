@@ -0,0 +1,98 @@
+package gitobj
+
+import (
+	"crypto/sha1"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadObjectsOrdersByPackOffset(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+
+	contents := make(map[string]string)
+	var shas [][]byte
+	for _, s := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		sha, err := packed.WriteBlob(NewBlobFromBytes([]byte(s)))
+		require.NoError(t, err)
+		contents[string(sha)] = s
+		shas = append(shas, sha)
+	}
+	require.NoError(t, packed.Flush())
+	require.NoError(t, packed.Close())
+
+	paths, err := filepath.Glob(filepath.Join(root, "pack", "*.pack"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	p, err := pack.OpenPackfile(paths[0], sha1.New())
+	require.NoError(t, err)
+	defer p.Close()
+
+	want := append([][]byte(nil), shas...)
+	sort.Slice(want, func(i, j int) bool {
+		a, err := p.Index().Entry(want[i])
+		require.NoError(t, err)
+		b, err := p.Index().Entry(want[j])
+		require.NoError(t, err)
+		return a.PackOffset < b.PackOffset
+	})
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	loose, err := db.WriteBlob(NewBlobFromBytes([]byte("loose\n")))
+	require.NoError(t, err)
+	contents[string(loose)] = "loose\n"
+	want = append(want, loose)
+
+	// Give the objects in reverse order, with the loose object first,
+	// and one of them twice.
+	oids := [][]byte{loose}
+	for i := len(shas) - 1; i >= 0; i-- {
+		oids = append(oids, shas[i])
+	}
+	oids = append(oids, shas[2])
+
+	var got [][]byte
+	err = db.ReadObjects(oids, func(oid []byte, typ ObjectType, size int64, r io.Reader) error {
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+
+		assert.Equal(t, BlobObjectType, typ)
+		assert.Equal(t, int64(len(data)), size)
+		assert.Equal(t, contents[string(oid)], string(data))
+
+		got = append(got, oid)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestReadObjectsMissingObject(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sha, err := db.WriteBlob(NewBlobFromBytes([]byte("present\n")))
+	require.NoError(t, err)
+
+	var got [][]byte
+	err = db.ReadObjects([][]byte{sha, make([]byte, 20)}, func(oid []byte, typ ObjectType, size int64, r io.Reader) error {
+		got = append(got, oid)
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, [][]byte{sha}, got)
+}
@@ -0,0 +1,52 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// maxReplaceDepth is the maximum number of replacements followed when
+	// reading an object, as with Git's MAXREPLACEDEPTH.
+	maxReplaceDepth = 5
+)
+
+// replace returns the name of the object which replaces the object named "sha"
+// (see: ReplaceObjects), following replacements of replacements, or "sha"
+// itself if it has not been replaced.
+//
+// If "sha" is the name of an object in the database's compatibility object
+// format (see: CompatObjectFormat), it is first translated to the object's name
+// in the database's own object format.
+func (o *ObjectDatabase) replace(sha []byte) ([]byte, error) {
+	sha, err := o.storageOid(sha)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.replacements) == 0 {
+		return sha, nil
+	}
+
+	name := hex.EncodeToString(sha)
+	replaced := false
+	for depth := 0; ; depth++ {
+		next, ok := o.replacements[name]
+		if !ok {
+			break
+		}
+		if depth >= maxReplaceDepth {
+			return nil, fmt.Errorf("gitobj: replace depth too high for object %x", sha)
+		}
+		name, replaced = next, true
+	}
+
+	if !replaced {
+		return sha, nil
+	}
+
+	replacement, err := hex.DecodeString(name)
+	if err != nil || len(replacement) != len(sha) {
+		return nil, fmt.Errorf("gitobj: invalid replacement for object %x: %q", sha, name)
+	}
+	return replacement, nil
+}
@@ -0,0 +1,127 @@
+package gitobj
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceObjectsReadsReplacements(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	plain, err := FromBackend(b)
+	require.NoError(t, err)
+
+	root := writeTestCommit(t, plain, map[string]string{"a.txt": "a\n"}, 1)
+	original := writeTestCommit(t, plain, map[string]string{"a.txt": "b\n"}, 2, root)
+	replacement := writeTestCommit(t, plain, map[string]string{"a.txt": "c\n"}, 3)
+
+	db, err := FromBackend(b, ReplaceObjects(map[string]string{
+		hex.EncodeToString(original): hex.EncodeToString(replacement),
+	}))
+	require.NoError(t, err)
+
+	want, err := plain.Commit(replacement)
+	require.NoError(t, err)
+
+	got, err := db.Commit(original)
+	require.NoError(t, err)
+	assert.True(t, want.Equal(got))
+	assert.Empty(t, got.ParentIDs)
+
+	obj, err := db.Object(original)
+	require.NoError(t, err)
+	assert.True(t, want.Equal(obj.(*Commit)))
+
+	info, err := db.CommitInfo(original)
+	require.NoError(t, err)
+	assert.Equal(t, want.TreeID, info.TreeID)
+
+	_, size, err := db.ObjectInfo(original)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, _, err = db.Copy(original, &buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), size)
+
+	// Views read replacements, too.
+	got, err = db.View().Commit(original)
+	require.NoError(t, err)
+	assert.True(t, want.Equal(got))
+
+	// Objects which are not replaced are read as usual.
+	got, err = db.Commit(root)
+	require.NoError(t, err)
+	assert.Empty(t, got.ParentIDs)
+
+	// Without the option, the original is read.
+	got, err = plain.Commit(original)
+	require.NoError(t, err)
+	assert.Len(t, got.ParentIDs, 1)
+}
+
+func TestReplaceObjectsFollowsReplacementsOfReplacements(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	plain, err := FromBackend(b)
+	require.NoError(t, err)
+
+	var shas []string
+	for _, contents := range []string{"a\n", "b\n", "c\n"} {
+		sha, err := plain.WriteBlob(NewBlobFromBytes([]byte(contents)))
+		require.NoError(t, err)
+		shas = append(shas, hex.EncodeToString(sha))
+	}
+
+	db, err := FromBackend(b, ReplaceObjects(map[string]string{
+		shas[0]: shas[1],
+	}), ReplaceObjects(map[string]string{
+		shas[1]: shas[2],
+	}))
+	require.NoError(t, err)
+
+	oid, _ := hex.DecodeString(shas[0])
+	blob, err := db.Blob(oid)
+	require.NoError(t, err)
+	defer blob.Close()
+
+	contents, err := ioutil.ReadAll(blob.Contents)
+	require.NoError(t, err)
+	assert.Equal(t, "c\n", string(contents))
+}
+
+func TestReplaceObjectsLimitsDepth(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	name := func(i int) string {
+		return strings.Repeat(string('0'+byte(i)), 40)
+	}
+
+	replacements := make(map[string]string)
+	for i := 0; i < maxReplaceDepth; i++ {
+		replacements[name(i+1)] = name(i)
+	}
+
+	db, err := FromBackend(b, ReplaceObjects(replacements))
+	require.NoError(t, err)
+
+	oid, _ := hex.DecodeString(name(maxReplaceDepth))
+	got, err := db.replace(oid)
+	require.NoError(t, err)
+	assert.Equal(t, name(0), hex.EncodeToString(got))
+
+	replacements[name(maxReplaceDepth+1)] = name(maxReplaceDepth)
+
+	db, err = FromBackend(b, ReplaceObjects(replacements))
+	require.NoError(t, err)
+
+	oid, _ = hex.DecodeString(name(maxReplaceDepth + 1))
+	_, err = db.Commit(oid)
+	assert.EqualError(t, err, "gitobj: replace depth too high for object 6666666666666666666666666666666666666666")
+}
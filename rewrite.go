@@ -0,0 +1,89 @@
+package gitobj
+
+// signatureHeaders are the extra commit headers which hold a signature over the
+// rest of the commit, and are invalidated by any change to it.
+var signatureHeaders = map[string]struct{}{
+	"gpgsig":        {},
+	"gpgsig-sha256": {},
+}
+
+// RewriteOption is a function which configures a rewrite performed by
+// RewriteCommit.
+type RewriteOption func(*rewriteOptions)
+
+type rewriteOptions struct {
+	keepSignatures bool
+}
+
+// KeepSignatures is a RewriteOption which keeps the signatures ("gpgsig" and
+// "gpgsig-sha256" headers) of rewritten commits, rather than removing them.
+// Since a signature covers the whole commit, those kept will no longer verify
+// unless the mutation replaces them.
+func KeepSignatures() RewriteOption {
+	return func(args *rewriteOptions) {
+		args.keepSignatures = true
+	}
+}
+
+// RewriteCommit reads the commit named by "sha", calls "mutate" with it, and
+// writes the result, returning its name. Everything which "mutate" leaves
+// untouched, including any extra headers and the exact bytes of the author,
+// committer, and message, is written unchanged.
+//
+// Signatures are removed from the rewritten commit before "mutate" is called,
+// as they would no longer verify, unless KeepSignatures is given. If the
+// rewritten commit is identical to the original, it is not written again, and
+// "sha" is returned.
+func (o *ObjectDatabase) RewriteCommit(sha []byte, mutate func(*Commit), setters ...RewriteOption) ([]byte, error) {
+	args := &rewriteOptions{}
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	original, err := o.Commit(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten := copyCommit(original)
+	if !args.keepSignatures {
+		headers := rewritten.ExtraHeaders[:0]
+		for _, hdr := range rewritten.ExtraHeaders {
+			if _, ok := signatureHeaders[hdr.K]; !ok {
+				headers = append(headers, hdr)
+			}
+		}
+		rewritten.ExtraHeaders = headers
+	}
+
+	mutate(rewritten)
+
+	if rewritten.Equal(original) {
+		return sha, nil
+	}
+	return o.WriteCommit(rewritten)
+}
+
+// copyCommit returns a deep copy of the given commit, which may be modified
+// without affecting the original.
+func copyCommit(c *Commit) *Commit {
+	parents := make([][]byte, 0, len(c.ParentIDs))
+	for _, parent := range c.ParentIDs {
+		parents = append(parents, append([]byte(nil), parent...))
+	}
+
+	headers := make([]*ExtraHeader, 0, len(c.ExtraHeaders))
+	for _, hdr := range c.ExtraHeaders {
+		headers = append(headers, &ExtraHeader{K: hdr.K, V: hdr.V})
+	}
+
+	return &Commit{
+		Author:       c.Author,
+		Committer:    c.Committer,
+		ParentIDs:    parents,
+		TreeID:       append([]byte(nil), c.TreeID...),
+		ExtraHeaders: headers,
+		Message:      c.Message,
+		unterminated: c.unterminated,
+	}
+}
@@ -0,0 +1,77 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestSignedCommit(t *testing.T, db *ObjectDatabase) []byte {
+	tree, err := db.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	sha, err := db.WriteCommit(&Commit{
+		Author:    "A U Thor <author@example.com> 1234567890 -0700 cruft",
+		Committer: "C O Mitter <committer@example.com> 1234567890 -0700",
+		TreeID:    tree,
+		ExtraHeaders: []*ExtraHeader{
+			{K: "encoding", V: "ISO-8859-1"},
+			{K: "gpgsig", V: "-----BEGIN PGP SIGNATURE-----\n\nsignature\n-----END PGP SIGNATURE-----"},
+			{K: "x-unknown", V: "value"},
+		},
+		Message: "Initial commit",
+	})
+	require.NoError(t, err)
+	return sha
+}
+
+func TestRewriteCommitPreservesUnknownData(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	sha := writeTestSignedCommit(t, db)
+
+	rewritten, err := db.RewriteCommit(sha, func(c *Commit) {
+		c.Message = "Rewritten commit"
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, sha, rewritten)
+
+	commit, err := db.Commit(rewritten)
+	require.NoError(t, err)
+
+	assert.Equal(t, "A U Thor <author@example.com> 1234567890 -0700 cruft", commit.Author)
+	assert.Equal(t, "Rewritten commit", commit.Message)
+	assert.Equal(t, []*ExtraHeader{
+		{K: "encoding", V: "ISO-8859-1"},
+		{K: "x-unknown", V: "value"},
+	}, commit.ExtraHeaders)
+
+	original, err := db.Commit(sha)
+	require.NoError(t, err)
+	assert.Equal(t, "Initial commit", original.Message)
+	assert.Len(t, original.ExtraHeaders, 3)
+}
+
+func TestRewriteCommitKeepsSignatures(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	sha := writeTestSignedCommit(t, db)
+
+	rewritten, err := db.RewriteCommit(sha, func(c *Commit) {
+		c.Message = "Rewritten commit"
+	}, KeepSignatures())
+	require.NoError(t, err)
+
+	commit, err := db.Commit(rewritten)
+	require.NoError(t, err)
+	require.Len(t, commit.ExtraHeaders, 3)
+	assert.Equal(t, "gpgsig", commit.ExtraHeaders[1].K)
+}
+
+func TestRewriteCommitWithoutChanges(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	sha := writeTestSignedCommit(t, db)
+
+	rewritten, err := db.RewriteCommit(sha, func(*Commit) {}, KeepSignatures())
+	require.NoError(t, err)
+	assert.Equal(t, sha, rewritten)
+}
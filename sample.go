@@ -0,0 +1,155 @@
+package gitobj
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// sampleSource is a collection of object names from which SampleObjects may
+// choose, such as the index of a packfile, or the loose objects in a directory.
+type sampleSource struct {
+	// count is the number of names in the source.
+	count int64
+	// name returns the "n"th name in the source.
+	name func(n int64) ([]byte, error)
+}
+
+// SampleObjects returns the names of up to "n" objects chosen at random from
+// the database, for spot-checking the integrity of a database too large to be
+// verified in full. Each loose object, and each entry in the index of each
+// packfile, including those of alternate object databases, is equally likely
+// to be chosen, and each is chosen at most once, so that all of the objects in
+// the database are returned if it holds no more than "n".
+//
+// An object stored more than once is more likely to be chosen, but is returned
+// only once, in which case fewer than "n" objects may be returned. Staged
+// objects which have not yet been flushed are not included.
+//
+// Packed objects are chosen from the indexes of their packfiles, without
+// reading the packfiles themselves. If "rnd" is nil, a source seeded with the
+// current time is used.
+func (o *ObjectDatabase) SampleObjects(n int, rnd *rand.Rand) ([][]byte, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	sources, err := o.sampleSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, src := range sources {
+		total += src.count
+	}
+
+	k := int64(n)
+	if k > total {
+		k = total
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	// Choose "k" distinct positions among all of the names, giving each
+	// equal probability (see: Bentley and Floyd, "A sample of
+	// brilliance", 1987).
+	chosen := make(map[int64]struct{}, k)
+	for j := total - k; j < total; j++ {
+		at := rnd.Int63n(j + 1)
+		if _, ok := chosen[at]; ok {
+			at = j
+		}
+		chosen[at] = struct{}{}
+	}
+
+	positions := make([]int64, 0, k)
+	for at := range chosen {
+		positions = append(positions, at)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i] < positions[j]
+	})
+
+	names := make([][]byte, 0, k)
+	seen := make(map[string]struct{}, k)
+
+	var src int
+	var base int64
+	for _, at := range positions {
+		for at >= base+sources[src].count {
+			base += sources[src].count
+			src++
+		}
+
+		name, err := sources[src].name(at - base)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[string(name)]; ok {
+			continue
+		}
+		seen[string(name)] = struct{}{}
+		names = append(names, name)
+	}
+
+	// Positions were visited in order, so shuffle the names to avoid
+	// returning those in the same packfile together.
+	rnd.Shuffle(len(names), func(i, j int) {
+		names[i], names[j] = names[j], names[i]
+	})
+	return names, nil
+}
+
+// sampleSources returns a sampleSource for the index of each of the packfiles in
+// the database, and for the objects in each of its other storages which may be
+// enumerated, such as the loose objects in each objects directory.
+func (o *ObjectDatabase) sampleSources() ([]*sampleSource, error) {
+	hashlen := o.Hasher().Size()
+
+	var sources []*sampleSource
+	loose := func(names [][]byte) {
+		sources = append(sources, &sampleSource{
+			count: int64(len(names)),
+			name: func(n int64) ([]byte, error) {
+				return names[n], nil
+			},
+		})
+	}
+
+	for _, s := range storages(o.ro) {
+		var names [][]byte
+		var err error
+
+		switch s := s.(type) {
+		case *pack.Storage:
+			for _, p := range s.Packs() {
+				idx := p.Index()
+				sources = append(sources, &sampleSource{
+					count: int64(idx.Count()),
+					name:  idx.Name,
+				})
+			}
+		case storage.EnumerableStorage:
+			err = s.Enumerate(func(sha []byte) error {
+				if len(sha) == hashlen {
+					names = append(names, sha)
+				}
+				return nil
+			})
+			loose(names)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
@@ -0,0 +1,66 @@
+package gitobj
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleObjectsReturnsDistinctObjects(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	all := make(map[string]struct{})
+	for i := 0; i < 10; i++ {
+		sha, err := db.WriteBlob(NewBlobFromBytes([]byte(fmt.Sprintf("%d\n", i))))
+		require.NoError(t, err)
+		all[string(sha)] = struct{}{}
+	}
+
+	sample, err := db.SampleObjects(4, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	require.Len(t, sample, 4)
+
+	seen := make(map[string]struct{})
+	for _, sha := range sample {
+		assert.Contains(t, all, string(sha))
+		assert.NotContains(t, seen, string(sha))
+		seen[string(sha)] = struct{}{}
+	}
+}
+
+func TestSampleObjectsIncludesLooseAndPackedObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-sample")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	packed, err := FromFilesystem(root, "", PackedWrites())
+	require.NoError(t, err)
+
+	a, err := packed.WriteBlob(NewBlobFromBytes([]byte("packed\n")))
+	require.NoError(t, err)
+	require.NoError(t, packed.Close())
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	b, err := db.WriteBlob(NewBlobFromBytes([]byte("loose\n")))
+	require.NoError(t, err)
+
+	sample, err := db.SampleObjects(10, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, [][]byte{a, b}, sample)
+}
+
+func TestSampleObjectsFromAnEmptyDatabase(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	sample, err := db.SampleObjects(10, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, sample)
+}
@@ -0,0 +1,129 @@
+package gitobj
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// FindRepositories returns the object directories of every Git repository
+// beneath "dir", in lexical order, for use with ScanRepositories. Both bare
+// repositories and those with a working tree (whose objects are in
+// ".git/objects") are found. Repositories nested within another repository are
+// not.
+func FindRepositories(dir string) ([]string, error) {
+	var roots []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		for _, gitdir := range []string{path, filepath.Join(path, ".git")} {
+			if isRepository(gitdir) {
+				roots = append(roots, filepath.Join(gitdir, "objects"))
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(roots)
+	return roots, nil
+}
+
+// isRepository returns whether "dir" looks like a Git directory: that is,
+// whether it has both a "HEAD" file and an "objects" directory.
+func isRepository(dir string) bool {
+	if fi, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil || fi.IsDir() {
+		return false
+	}
+	fi, err := os.Stat(filepath.Join(dir, "objects"))
+	return err == nil && fi.IsDir()
+}
+
+// ScanRepositories opens an *ObjectDatabase for each of the object directories
+// in "roots" (see: FindRepositories) with the given options, and calls "fn"
+// with each, from up to "concurrency" goroutines at once. If "concurrency" is
+// not positive, the number of CPUs is used.
+//
+// Each database is closed as soon as "fn" returns, so no more than
+// "concurrency" databases (and their packfiles) are open at any one time, no
+// matter how many repositories are scanned.
+//
+// If opening a database or calling "fn" fails, no further repositories are
+// scanned, and the first such error is returned once those already being
+// scanned are finished.
+func ScanRepositories(roots []string, tmp string, concurrency int, fn func(root string, db *ObjectDatabase) error, setters ...Option) error {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	var (
+		mu   sync.Mutex
+		next int
+		err  error
+	)
+
+	// claim returns the next root to be scanned, or false if every
+	// root has been claimed or scanning has failed.
+	claim := func() (string, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil || next >= len(roots) {
+			return "", false
+		}
+		next++
+		return roots[next-1], true
+	}
+
+	fail := func(e error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err == nil {
+			err = e
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for root, ok := claim(); ok; root, ok = claim() {
+				if e := scanRepository(root, tmp, fn, setters); e != nil {
+					fail(e)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return err
+}
+
+// scanRepository opens the *ObjectDatabase at "root", calls "fn" with it, and
+// closes it.
+func scanRepository(root, tmp string, fn func(root string, db *ObjectDatabase) error, setters []Option) error {
+	db, err := FromFilesystem(root, tmp, setters...)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(root, db); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
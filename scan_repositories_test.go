@@ -0,0 +1,97 @@
+package gitobj
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindRepositories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-repositories")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bare := filepath.Join(dir, "a.git")
+	worktree := filepath.Join(dir, "b", ".git")
+	nested := filepath.Join(bare, "nested.git")
+	for _, gitdir := range []string{bare, worktree, nested} {
+		writeTestRepository(t, gitdir)
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "c", "objects"), 0755))
+
+	roots, err := FindRepositories(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(bare, "objects"),
+		filepath.Join(worktree, "objects"),
+	}, roots)
+}
+
+func TestScanRepositories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitobj-repositories")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var roots []string
+	for _, name := range []string{"a.git", "b.git", "c.git"} {
+		gitdir := filepath.Join(dir, name)
+		writeTestRepository(t, gitdir)
+		roots = append(roots, filepath.Join(gitdir, "objects"))
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+	)
+	err = ScanRepositories(roots, "", 2, func(root string, db *ObjectDatabase) error {
+		sha, err := db.WriteBlob(NewBlobFromBytes([]byte(root)))
+		if err != nil {
+			return err
+		}
+		_, err = db.Blob(sha)
+
+		mu.Lock()
+		defer mu.Unlock()
+		seen[root] = true
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, seen, 3)
+	for _, root := range roots {
+		assert.True(t, seen[root], root)
+	}
+}
+
+func TestScanRepositoriesStopsAtFirstError(t *testing.T) {
+	roots := make([]string, 10)
+	for i := range roots {
+		dir, err := ioutil.TempDir("", "gitobj-objects")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		roots[i] = dir
+	}
+
+	var calls int
+	err := ScanRepositories(roots, "", 1, func(root string, db *ObjectDatabase) error {
+		calls++
+		return errors.New("gitobj: scan failed")
+	})
+
+	assert.EqualError(t, err, "gitobj: scan failed")
+	assert.Equal(t, 1, calls)
+}
+
+func writeTestRepository(t *testing.T, gitdir string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(gitdir, "objects"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(gitdir, "HEAD"),
+		[]byte("ref: refs/heads/main\n"), 0644))
+}
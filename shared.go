@@ -0,0 +1,113 @@
+package gitobj
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SharedPerm is a policy for the permissions of the files and directories
+// created in a filesystem backend, as given by Git's "core.sharedRepository"
+// setting (see: SharedRepository).
+type SharedPerm int
+
+const (
+	// SharedUmask leaves the permissions of new files and directories to
+	// the process's umask, as Git does by default.
+	SharedUmask SharedPerm = 0
+	// SharedGroup makes new files and directories readable (and, unless
+	// they are read-only, writable) by the group owning them, as with
+	// "core.sharedRepository=group".
+	SharedGroup SharedPerm = 0660
+	// SharedEverybody is as SharedGroup, but also makes new files and
+	// directories readable by all users, as with
+	// "core.sharedRepository=all".
+	SharedEverybody SharedPerm = 0664
+)
+
+// SharedMode returns the SharedPerm giving new files exactly the given read and
+// write permissions, less any write permissions for files which are read-only,
+// as with "core.sharedRepository=0xxx". Execute permissions are ignored, but
+// directories (and executable files) are given execute permission wherever they
+// are given read permission.
+func SharedMode(mode os.FileMode) SharedPerm {
+	return -SharedPerm(mode.Perm() & 0666)
+}
+
+// ParseSharedRepository parses a value of Git's "core.sharedRepository" setting
+// into the SharedPerm it gives: "umask", "false", or "0" for SharedUmask;
+// "group", "true", or "1" for SharedGroup; "all", "world", "everybody", or "2"
+// for SharedEverybody; or an octal mode, such as "0640", for SharedMode.
+func ParseSharedRepository(value string) (SharedPerm, error) {
+	switch strings.ToLower(value) {
+	case "", "umask", "false", "no", "off", "0":
+		return SharedUmask, nil
+	case "group", "true", "yes", "on", "1":
+		return SharedGroup, nil
+	case "all", "world", "everybody", "2":
+		return SharedEverybody, nil
+	}
+
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode&^0777 != 0 {
+		return SharedUmask, fmt.Errorf("gitobj: invalid core.sharedRepository value %q", value)
+	}
+	if mode&0600 != 0600 {
+		return SharedUmask, fmt.Errorf("gitobj: core.sharedRepository mode %#o is not readable and writable by its owner", mode)
+	}
+	return SharedMode(os.FileMode(mode)), nil
+}
+
+// apply returns the given mode of a file or directory adjusted according to the
+// policy, as Git's adjust_shared_perm() does.
+func (p SharedPerm) apply(mode os.FileMode) os.FileMode {
+	tweak := p
+	if p < 0 {
+		tweak = -p
+	}
+
+	if mode&0200 == 0 {
+		tweak &^= 0222
+	}
+	if mode&0100 != 0 || mode.IsDir() {
+		// Copy read bits to execute bits.
+		tweak |= (tweak & 0444) >> 2
+	}
+
+	if p < 0 {
+		mode = (mode &^ 0777) | os.FileMode(tweak)
+	} else {
+		mode |= os.FileMode(tweak)
+	}
+	if mode.IsDir() {
+		mode |= os.ModeSetgid
+	}
+	return mode
+}
+
+// adjustSharedPerm changes the permissions of the file or directory at "path"
+// according to the policy "p", if it is not SharedUmask.
+//
+// Files are only ever objects and packs, which are never modified once written,
+// and so they are made read-only, as Git creates them, while directories keep
+// their write permissions.
+func adjustSharedPerm(path string, p SharedPerm) error {
+	if p == SharedUmask {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	perm := fi.Mode()
+	if !perm.IsDir() {
+		perm &^= 0222
+	}
+	if mode := p.apply(perm); mode != fi.Mode() {
+		return os.Chmod(path, mode&(os.ModePerm|os.ModeSetgid))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSharedRepository(t *testing.T) {
+	for value, want := range map[string]SharedPerm{
+		"umask":     SharedUmask,
+		"false":     SharedUmask,
+		"0":         SharedUmask,
+		"group":     SharedGroup,
+		"true":      SharedGroup,
+		"1":         SharedGroup,
+		"all":       SharedEverybody,
+		"everybody": SharedEverybody,
+		"2":         SharedEverybody,
+		"0640":      SharedMode(0640),
+		"0755":      SharedMode(0644),
+	} {
+		got, err := ParseSharedRepository(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+
+	for _, value := range []string{"sometimes", "0400", "01777", "0x660"} {
+		_, err := ParseSharedRepository(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestSharedPermApply(t *testing.T) {
+	for _, test := range []struct {
+		perm SharedPerm
+		mode os.FileMode
+		want os.FileMode
+	}{
+		{SharedGroup, 0600, 0660},
+		{SharedGroup, 0444, 0444},
+		{SharedGroup, 0400, 0440},
+		{SharedEverybody, 0600, 0664},
+		{SharedGroup, os.ModeDir | 0755, os.ModeDir | os.ModeSetgid | 0775},
+		{SharedMode(0640), 0666, 0640},
+		{SharedMode(0640), 0444, 0440},
+		{SharedMode(0640), os.ModeDir | 0700, os.ModeDir | os.ModeSetgid | 0750},
+	} {
+		assert.Equal(t, test.want, test.perm.apply(test.mode), "%#o: %s", test.perm, test.mode)
+	}
+}
+
+func TestSharedRepositoryAdjustsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not supported on Windows")
+	}
+
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", SharedRepository(SharedGroup))
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	dir := filepath.Join(root, hex.EncodeToString(sha)[:2])
+	fi, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0070), fi.Mode()&0070)
+	assert.NotZero(t, fi.Mode()&os.ModeSetgid)
+
+	fi, err = os.Stat(filepath.Join(dir, hex.EncodeToString(sha)[2:]))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0040), fi.Mode()&0070)
+}
+
+func TestSharedModeMakesLooseObjectsReadOnly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not supported on Windows")
+	}
+
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	odb, err := FromFilesystem(root, "", SharedRepository(SharedMode(0660)))
+	require.NoError(t, err)
+	defer odb.Close()
+
+	sha, err := odb.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	dir := filepath.Join(root, hex.EncodeToString(sha)[:2])
+	fi, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0770), fi.Mode().Perm())
+
+	fi, err = os.Stat(filepath.Join(dir, hex.EncodeToString(sha)[2:]))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0440), fi.Mode().Perm())
+}
@@ -0,0 +1,160 @@
+package gitobj
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// normalizeSignatures returns the given object with the identities in its
+// headers normalized (see: normalizeSignature), or an *InvalidSignature if any
+// of them is invalid. Objects other than commits and tags are returned as-is.
+//
+// The given object is not modified; if any identity is normalized, a copy of
+// the object is returned.
+func normalizeSignatures(object Object) (Object, error) {
+	var err error
+
+	switch obj := object.(type) {
+	case *Commit:
+		c := *obj
+		if c.Author, err = normalizeSignature("author", c.Author); err != nil {
+			return nil, err
+		}
+		if c.Committer, err = normalizeSignature("committer", c.Committer); err != nil {
+			return nil, err
+		}
+		return &c, nil
+	case *Tag:
+		// Very old tags were written without a tagger, which Git
+		// permits, so only a tagger which is present is validated.
+		if obj.Tagger == "" {
+			return obj, nil
+		}
+
+		t := *obj
+		if t.Tagger, err = normalizeSignature("tagger", t.Tagger); err != nil {
+			return nil, err
+		}
+		return &t, nil
+	}
+	return object, nil
+}
+
+// normalizeSignature validates the timestamp and timezone offset at the end of
+// the given identity, which is held by the named header, and returns the
+// identity with any zero-padding removed from its timestamp, and with a single
+// space before each of them.
+func normalizeSignature(header, ident string) (string, error) {
+	i, when, tz, _, err := splitSignature(header, ident)
+	if err != nil {
+		return "", err
+	}
+	return ident[:i+1] + " " + strconv.FormatInt(when, 10) + " " + tz, nil
+}
+
+// ParseSignature parses an identity as it is found in the author, committer,
+// and tagger headers of commits and tags, for instance:
+//
+//	Taylor Blau <ttaylorr@github.com> 1494258422 -0600
+//
+// The time of the returned *Signature is in a fixed time zone with the
+// identity's offset from UTC, so that formatting it (see: Signature.String)
+// produces the same identity, less any extraneous whitespace or zero-padding.
+// An *InvalidSignature is returned if the identity is malformed.
+func ParseSignature(ident string) (*Signature, error) {
+	return parseSignature("signature", ident)
+}
+
+// AuthorSignature parses the commit's author (see: ParseSignature).
+func (c *Commit) AuthorSignature() (*Signature, error) {
+	return parseSignature("author", c.Author)
+}
+
+// CommitterSignature parses the commit's committer (see: ParseSignature).
+func (c *Commit) CommitterSignature() (*Signature, error) {
+	return parseSignature("committer", c.Committer)
+}
+
+// TaggerSignature parses the tag's tagger (see: ParseSignature). Since very old
+// tags were written without a tagger, (nil, nil) is returned if it has none.
+func (t *Tag) TaggerSignature() (*Signature, error) {
+	if t.Tagger == "" {
+		return nil, nil
+	}
+	return parseSignature("tagger", t.Tagger)
+}
+
+// parseSignature parses the given identity, which is held by the named header,
+// as ParseSignature does.
+func parseSignature(header, ident string) (*Signature, error) {
+	i, when, _, offset, err := splitSignature(header, ident)
+	if err != nil {
+		return nil, err
+	}
+
+	j := strings.LastIndexByte(ident[:i], '<')
+	if j < 0 {
+		return nil, &InvalidSignature{Header: header, Ident: ident, Reason: "missing email"}
+	}
+
+	return &Signature{
+		Name:  strings.TrimSpace(ident[:j]),
+		Email: ident[j+1 : i],
+		When:  time.Unix(when, 0).In(time.FixedZone("", offset)),
+	}, nil
+}
+
+// splitSignature validates the timestamp and timezone offset at the end of the
+// given identity, which is held by the named header, and returns the index of
+// the '>' ending its email, along with its timestamp, its timezone, and the
+// offset of that timezone from UTC, in seconds. If the identity is invalid, an
+// *InvalidSignature is returned.
+func splitSignature(header, ident string) (int, int64, string, int, error) {
+	invalid := func(reason string) (int, int64, string, int, error) {
+		return 0, 0, "", 0, &InvalidSignature{Header: header, Ident: ident, Reason: reason}
+	}
+
+	i := strings.LastIndexByte(ident, '>')
+	if i < 0 {
+		return invalid("missing email")
+	}
+
+	fields := strings.Fields(ident[i+1:])
+	switch {
+	case len(fields) < 2:
+		return invalid("missing timestamp or timezone")
+	case len(fields) > 2:
+		return invalid("trailing data after timezone")
+	}
+
+	when, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+			return invalid("timestamp overflows")
+		}
+		return invalid("malformed timestamp")
+	}
+	if when > 1<<63-1 {
+		// Git stores timestamps as a time_t, which is signed.
+		return invalid("timestamp overflows")
+	}
+
+	tz := fields[1]
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') {
+		return invalid("malformed timezone")
+	}
+	hhmm, err := strconv.ParseUint(tz[1:], 10, 16)
+	if err != nil {
+		return invalid("malformed timezone")
+	}
+	if hhmm/100 >= 24 || hhmm%100 >= 60 {
+		return invalid("timezone out of range")
+	}
+
+	offset := int(hhmm/100)*60*60 + int(hhmm%100)*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return i, int64(when), tz, offset, nil
+}
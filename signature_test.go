@@ -0,0 +1,167 @@
+package gitobj
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeSignature(t *testing.T) {
+	for desc, c := range map[string]struct {
+		ident  string
+		want   string
+		reason string
+	}{
+		"valid":             {"A U Thor <a@b.c> 1234567890 -0700", "A U Thor <a@b.c> 1234567890 -0700", ""},
+		"zero-padded":       {"A U Thor <a@b.c> 0001234 +0000", "A U Thor <a@b.c> 1234 +0000", ""},
+		"extra whitespace":  {"A U Thor <a@b.c>  1234\t+0130", "A U Thor <a@b.c> 1234 +0130", ""},
+		"zero timestamp":    {"A U Thor <a@b.c> 0 +0000", "A U Thor <a@b.c> 0 +0000", ""},
+		"missing email":     {"A U Thor 1234 +0000", "", "missing email"},
+		"missing timezone":  {"A U Thor <a@b.c> 1234", "", "missing timestamp or timezone"},
+		"trailing data":     {"A U Thor <a@b.c> 1234 +0000 x", "", "trailing data after timezone"},
+		"negative time":     {"A U Thor <a@b.c> -1234 +0000", "", "malformed timestamp"},
+		"overflowing time":  {"A U Thor <a@b.c> 99999999999999999999 +0000", "", "timestamp overflows"},
+		"signed overflow":   {"A U Thor <a@b.c> 9223372036854775808 +0000", "", "timestamp overflows"},
+		"unsigned timezone": {"A U Thor <a@b.c> 1234 00000", "", "malformed timezone"},
+		"short timezone":    {"A U Thor <a@b.c> 1234 +000", "", "malformed timezone"},
+		"bad hours":         {"A U Thor <a@b.c> 1234 +2400", "", "timezone out of range"},
+		"bad minutes":       {"A U Thor <a@b.c> 1234 -0060", "", "timezone out of range"},
+	} {
+		got, err := normalizeSignature("author", c.ident)
+		if c.reason == "" {
+			assert.NoError(t, err, desc)
+			assert.Equal(t, c.want, got, desc)
+			continue
+		}
+
+		assert.Equal(t, &InvalidSignature{
+			Header: "author", Ident: c.ident, Reason: c.reason,
+		}, err, desc)
+	}
+}
+
+func TestStrictSignaturesRejectsInvalidCommits(t *testing.T) {
+	db, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(db, StrictSignatures())
+	require.NoError(t, err)
+
+	_, err = odb.WriteCommit(&Commit{
+		Author:    "A U Thor <author@example.com> 1234 +0000",
+		Committer: "C O Mitter <committer@example.com> 1234 +9900",
+		TreeID:    make([]byte, 20),
+		Message:   "initial commit",
+	})
+	assert.EqualError(t, err, `gitobj: invalid committer "C O Mitter <committer@example.com> 1234 +9900": timezone out of range`)
+}
+
+func TestStrictSignaturesNormalizesTags(t *testing.T) {
+	db, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	odb, err := FromBackend(db, StrictSignatures())
+	require.NoError(t, err)
+
+	tag := &Tag{
+		Object:     make([]byte, 20),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "A U Thor <author@example.com> 01234 +0000",
+		Message:    "v1.0.0",
+	}
+
+	sha, err := odb.WriteTag(tag)
+	require.NoError(t, err)
+
+	got, err := odb.Tag(sha)
+	require.NoError(t, err)
+	assert.Equal(t, "A U Thor <author@example.com> 1234 +0000", got.Tagger)
+
+	// The tag given is left as-is.
+	assert.Equal(t, "A U Thor <author@example.com> 01234 +0000", tag.Tagger)
+}
+
+func TestSignaturesAreNotValidatedByDefault(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	_, err := db.WriteCommit(&Commit{
+		Author:    "A U Thor <author@example.com> 1234 +9900",
+		Committer: "A U Thor <author@example.com>",
+		TreeID:    make([]byte, 20),
+		Message:   "initial commit",
+	})
+	assert.NoError(t, err)
+}
+
+func TestParseSignature(t *testing.T) {
+	sig, err := ParseSignature("A U Thor <a@b.c>  1494620424 -0630")
+	require.NoError(t, err)
+
+	assert.Equal(t, "A U Thor", sig.Name)
+	assert.Equal(t, "a@b.c", sig.Email)
+	assert.Equal(t, int64(1494620424), sig.When.Unix())
+
+	_, offset := sig.When.Zone()
+	assert.Equal(t, -(6*60*60 + 30*60), offset)
+
+	assert.Equal(t, "A U Thor <a@b.c> 1494620424 -0630", sig.String())
+}
+
+func TestParseSignatureRoundTrips(t *testing.T) {
+	sig := &Signature{
+		Name:  "Jane Doe",
+		Email: "jane@example.com",
+		When:  time.Unix(1257894000, 0).In(time.FixedZone("", 5*60*60+45*60)),
+	}
+
+	parsed, err := ParseSignature(sig.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, sig.Name, parsed.Name)
+	assert.Equal(t, sig.Email, parsed.Email)
+	assert.True(t, sig.When.Equal(parsed.When))
+	assert.Equal(t, sig.String(), parsed.String())
+}
+
+func TestParseSignatureRejectsInvalidIdentities(t *testing.T) {
+	_, err := ParseSignature("A U Thor a@b.c> 1234 +0000")
+	require.Error(t, err)
+
+	invalid, ok := err.(*InvalidSignature)
+	require.True(t, ok)
+	assert.Equal(t, "signature", invalid.Header)
+	assert.Equal(t, "missing email", invalid.Reason)
+}
+
+func TestCommitAndTagSignatures(t *testing.T) {
+	c := &Commit{
+		Author:    "A U Thor <author@example.com> 1234 +0100",
+		Committer: "C O Mitter <committer@example.com> 5678 -0200",
+	}
+
+	author, err := c.AuthorSignature()
+	require.NoError(t, err)
+	assert.Equal(t, "A U Thor", author.Name)
+	assert.Equal(t, int64(1234), author.When.Unix())
+
+	committer, err := c.CommitterSignature()
+	require.NoError(t, err)
+	assert.Equal(t, "committer@example.com", committer.Email)
+
+	c.Committer = "C O Mitter <committer@example.com>"
+	_, err = c.CommitterSignature()
+	require.Error(t, err)
+	assert.Equal(t, "committer", err.(*InvalidSignature).Header)
+
+	tag := &Tag{Tagger: "T Agger <tagger@example.com> 42 +0000"}
+	tagger, err := tag.TaggerSignature()
+	require.NoError(t, err)
+	assert.Equal(t, "T Agger", tagger.Name)
+
+	tagger, err = (&Tag{}).TaggerSignature()
+	assert.NoError(t, err)
+	assert.Nil(t, tagger)
+}
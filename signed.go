@@ -0,0 +1,171 @@
+package gitobj
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// signaturePrefixes are the lines with which the signatures trailing the
+// messages of signed tags begin, in each of the formats supported by Git
+// (OpenPGP, X.509, and SSH).
+var signaturePrefixes = [][]byte{
+	[]byte("-----BEGIN PGP SIGNATURE-----"),
+	[]byte("-----BEGIN PGP MESSAGE-----"),
+	[]byte("-----BEGIN SIGNED MESSAGE-----"),
+	[]byte("-----BEGIN SSH SIGNATURE-----"),
+}
+
+// Signature returns the signature held by the commit's "gpgsig" header, or,
+// failing that, by its "gpgsig-sha256" header, ending in a newline, as it is
+// given to gpg (or ssh-keygen) to be verified, along with whether the commit
+// is signed. The signature is made over the commit's signed payload (see:
+// SignedPayload).
+func (c *Commit) Signature() (string, bool) {
+	for _, k := range []string{"gpgsig", "gpgsig-sha256"} {
+		if values := c.ExtraHeaderValues(k); len(values) > 0 {
+			return values[0] + "\n", true
+		}
+	}
+	return "", false
+}
+
+// SignedPayload returns the bytes over which the commit's signature is made,
+// as Git verifies (and creates) it: the commit as it is encoded, without any of
+// its signature headers ("gpgsig" or "gpgsig-sha256").
+func (c *Commit) SignedPayload() ([]byte, error) {
+	payload := copyCommit(c)
+	payload.ExtraHeaders = payload.ExtraHeaders[:0]
+	for _, hdr := range c.ExtraHeaders {
+		if _, ok := signatureHeaders[hdr.K]; !ok {
+			payload.ExtraHeaders = append(payload.ExtraHeaders, hdr)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := payload.Encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AttachSignature attaches the given signature, made externally over the
+// commit's signed payload (see: SignedPayload), to the commit in its "gpgsig"
+// header, after its other extra headers, as Git does. Any "gpgsig" header the
+// commit already has is replaced. Commits in SHA-256 repositories are instead
+// signed in a "gpgsig-sha256" header (see: AddExtraHeader).
+//
+// It returns an error if the signature is empty.
+func (c *Commit) AttachSignature(sig string) error {
+	sig = strings.TrimSuffix(sig, "\n")
+	if len(sig) == 0 {
+		return fmt.Errorf("gitobj: empty commit signature")
+	}
+
+	headers := c.ExtraHeaders[:0]
+	for _, hdr := range c.ExtraHeaders {
+		if hdr.K != "gpgsig" {
+			headers = append(headers, hdr)
+		}
+	}
+	c.ExtraHeaders = append(headers, &ExtraHeader{K: "gpgsig", V: sig})
+	return nil
+}
+
+// Signature returns the signature trailing the tag's message, as it is given to
+// gpg (or ssh-keygen) to be verified, along with whether the tag is signed. The
+// signature is made over the tag's signed payload (see: SignedPayload).
+func (t *Tag) Signature() (string, bool) {
+	data, err := t.encoded()
+	if err != nil {
+		return "", false
+	}
+
+	i := tagSignature(data)
+	if i == len(data) {
+		return "", false
+	}
+	return string(data[i:]), true
+}
+
+// SignedPayload returns the bytes over which the tag's signature is made, as Git
+// verifies (and creates) it: the tag as it is encoded, up to the signature
+// trailing its message, if it has one.
+func (t *Tag) SignedPayload() ([]byte, error) {
+	data, err := t.encoded()
+	if err != nil {
+		return nil, err
+	}
+	return data[:tagSignature(data)], nil
+}
+
+// AttachSignature attaches the given signature, made externally over the tag's
+// signed payload (see: SignedPayload), to the tag, after its message, replacing
+// any signature the tag already has. The signature must begin with one of the
+// lines with which Git recognizes signatures in the OpenPGP, X.509, or SSH
+// formats, such as "-----BEGIN PGP SIGNATURE-----".
+func (t *Tag) AttachSignature(sig string) error {
+	if !isSignatureLine([]byte(sig)) {
+		return fmt.Errorf("gitobj: tag signature has no recognized header")
+	}
+
+	payload, err := t.SignedPayload()
+	if err != nil {
+		return err
+	}
+
+	signed := bytes.NewBuffer(payload)
+	if len(payload) > 0 && payload[len(payload)-1] != '\n' {
+		signed.WriteByte('\n')
+	}
+	signed.WriteString(sig)
+	if !strings.HasSuffix(sig, "\n") {
+		signed.WriteByte('\n')
+	}
+
+	var tag Tag
+	if _, err := tag.Decode(nil, signed, int64(signed.Len())); err != nil {
+		return err
+	}
+	*t = tag
+	return nil
+}
+
+// encoded returns the tag as it is encoded.
+func (t *Tag) encoded() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := t.Encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tagSignature returns the offset in the encoded tag "data" of the signature
+// trailing its message, which begins at the last line which begins as a
+// signature does, as Git finds it, or len(data) if there is none.
+func tagSignature(data []byte) int {
+	at := len(data)
+	for i := 0; i < len(data); {
+		if isSignatureLine(data[i:]) {
+			at = i
+		}
+
+		eol := bytes.IndexByte(data[i:], '\n')
+		if eol < 0 {
+			break
+		}
+		i += eol + 1
+	}
+	return at
+}
+
+// isSignatureLine returns whether "line" begins as a signature does (see:
+// signaturePrefixes).
+func isSignatureLine(line []byte) bool {
+	for _, prefix := range signaturePrefixes {
+		if bytes.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package gitobj
+
+import (
+	"crypto/sha1"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSignature = "-----BEGIN SSH SIGNATURE-----\n" +
+	"U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAguY4Rv6vcfu+BS8S7Z4/7f9rI1o\n" +
+	"-----END SSH SIGNATURE-----\n"
+
+func TestCommitSignatureAndPayload(t *testing.T) {
+	unsigned := "tree 2aedfd35087c75d17bdbaf4dd56069d44fc75b71\n" +
+		"author Jane Doe <jane@example.com> 1503956287 -0400\n" +
+		"committer Jane Doe <jane@example.com> 1503956287 -0400\n" +
+		"encoding UTF-8\n"
+	cc := unsigned +
+		"gpgsig " + strings.Replace(strings.TrimSuffix(testSignature, "\n"), "\n", "\n ", -1) + "\n" +
+		"\nsigned commit\n"
+
+	var c Commit
+	_, err := c.Decode(sha1.New(), strings.NewReader(cc), int64(len(cc)))
+	require.NoError(t, err)
+
+	sig, ok := c.Signature()
+	require.True(t, ok)
+	assert.Equal(t, testSignature, sig)
+
+	payload, err := c.SignedPayload()
+	require.NoError(t, err)
+	assert.Equal(t, unsigned+"\nsigned commit\n", string(payload))
+
+	// The payload does not change as the signature is replaced.
+	require.NoError(t, c.AttachSignature(testSignature))
+	again, err := c.SignedPayload()
+	require.NoError(t, err)
+	assert.Equal(t, payload, again)
+	assert.Len(t, c.ExtraHeaderValues("gpgsig"), 1)
+}
+
+func TestCommitAttachSignature(t *testing.T) {
+	c := &Commit{
+		TreeID:    make([]byte, 20),
+		Author:    "Jane Doe <jane@example.com> 1503956287 -0400",
+		Committer: "Jane Doe <jane@example.com> 1503956287 -0400",
+		Message:   "signed commit",
+	}
+
+	_, ok := c.Signature()
+	assert.False(t, ok)
+	assert.EqualError(t, c.AttachSignature("\n"), "gitobj: empty commit signature")
+
+	require.NoError(t, c.AttachSignature(testSignature))
+
+	sig, ok := c.Signature()
+	require.True(t, ok)
+	assert.Equal(t, testSignature, sig)
+	assert.Equal(t, []*ExtraHeader{
+		{K: "gpgsig", V: strings.TrimSuffix(testSignature, "\n")},
+	}, c.ExtraHeaders)
+}
+
+func TestTagSignatureAndPayload(t *testing.T) {
+	unsigned := "object 6161616161616161616161616161616161616161\n" +
+		"type commit\n" +
+		"tag v1.0\n" +
+		"tagger Jane Doe <jane@example.com> 1503956287 -0400\n" +
+		"\n" +
+		"signed tag\n"
+	tt := unsigned + testSignature
+
+	var tag Tag
+	_, err := tag.Decode(sha1.New(), strings.NewReader(tt), int64(len(tt)))
+	require.NoError(t, err)
+
+	sig, ok := tag.Signature()
+	require.True(t, ok)
+	assert.Equal(t, testSignature, sig)
+
+	payload, err := tag.SignedPayload()
+	require.NoError(t, err)
+	assert.Equal(t, unsigned, string(payload))
+}
+
+func TestTagAttachSignature(t *testing.T) {
+	tag := &Tag{
+		Object:     []byte("aaaaaaaaaaaaaaaaaaaa"),
+		ObjectType: CommitObjectType,
+		Name:       "v1.0",
+		Tagger:     "Jane Doe <jane@example.com> 1503956287 -0400",
+		Message:    "signed tag",
+	}
+
+	_, ok := tag.Signature()
+	assert.False(t, ok)
+	assert.EqualError(t, tag.AttachSignature("not a signature"),
+		"gitobj: tag signature has no recognized header")
+
+	payload, err := tag.SignedPayload()
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, tag.AttachSignature(testSignature))
+
+		sig, ok := tag.Signature()
+		require.True(t, ok)
+		assert.Equal(t, testSignature, sig)
+
+		signed, err := tag.SignedPayload()
+		require.NoError(t, err)
+		assert.Equal(t, string(payload)+"\n", string(signed))
+	}
+	assert.Equal(t, "signed tag\n"+strings.TrimSuffix(testSignature, "\n"), tag.Message)
+}
@@ -0,0 +1,144 @@
+package gitobj
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/pack"
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// Snapshot returns a read-only view (see: View) of the database as it is now,
+// whose reads remain consistent while concurrent maintenance, such as a repack
+// and prune by gitobj or by Git itself, adds and removes files beneath it.
+//
+// The snapshot reads from the packfiles which the database (and each of its
+// alternates) holds now, which are never rescanned (see: pack.Set.Snapshot),
+// and from the loose objects present now. Loose objects written later are not
+// visible to it, and those which are later removed, having been packed by a
+// repack, are read from the packfiles which then hold them. Objects which are
+// staged but not yet flushed (see: BatchedWrites) are not included, and
+// storages other than packfiles and loose objects (such as those of a
+// database created with NewMemoryBackend) are read as they are.
+//
+// Objects cannot be written to the snapshot. Like any view, closing it leaves
+// the database open, and closing the database closes the snapshot; unlike
+// other views, it may not be reopened (see: Reopen).
+func (o *ObjectDatabase) Snapshot() (*ObjectDatabase, error) {
+	if o.isClosed() {
+		return nil, ErrDatabaseClosed
+	}
+
+	view := o.View()
+
+	names := make(map[string]struct{})
+	var (
+		pinned []storage.Storage
+		live   []storage.Storage
+	)
+	for _, s := range storages(view.ro) {
+		switch s := s.(type) {
+		case *fileStorer:
+			err := s.each(func(sha []byte, path string, size int64) error {
+				names[string(sha)] = struct{}{}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			pinned = append(pinned, &pinnedStorage{s: s, names: names})
+		case *pack.Storage:
+			pinned = append(pinned, s.Snapshot())
+			live = append(live, &pinnedStorage{s: s, names: names})
+		default:
+			pinned = append(pinned, s)
+		}
+	}
+
+	// Loose objects since packed and pruned are found in the packfiles
+	// which now hold them, after those in the snapshot.
+	view.ro = storage.MultiStorage(append(pinned, live...)...)
+	view.rw = &readOnlyStorer{view.ro}
+	view.snapshot = true
+	return view, nil
+}
+
+// pinnedStorage is a storage.Storage reading only those objects of another
+// storage whose names were pinned when a snapshot was taken (see: Snapshot).
+type pinnedStorage struct {
+	s     storage.Storage
+	names map[string]struct{}
+}
+
+// Open implements the storage.Storage.Open interface. If a pinned object is not
+// found in packfiles, as when it was removed from loose storage by a repack
+// which has only just finished, they are reloaded before it is looked for
+// again.
+func (p *pinnedStorage) Open(oid []byte) (io.ReadCloser, error) {
+	type reloader interface {
+		Reload() error
+	}
+
+	if _, ok := p.names[string(oid)]; !ok {
+		return nil, errors.NoSuchObject(oid)
+	}
+
+	r, err := p.s.Open(oid)
+	if errors.IsNoSuchObject(err) {
+		if rl, ok := p.s.(reloader); ok && rl.Reload() == nil {
+			return p.s.Open(oid)
+		}
+	}
+	return r, err
+}
+
+// Has implements the storage.HasStorage interface.
+func (p *pinnedStorage) Has(oid []byte) (bool, error) {
+	if _, ok := p.names[string(oid)]; !ok {
+		return false, nil
+	}
+	return storage.Has(p.s, oid)
+}
+
+// Enumerate implements the storage.EnumerableStorage interface by calling "fn"
+// with the name of each pinned object.
+func (p *pinnedStorage) Enumerate(fn func(oid []byte) error) error {
+	for name := range p.names {
+		if err := fn([]byte(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements the storage.Storage.Close interface. The underlying storage
+// belongs to the database from which the snapshot was taken, and is left open.
+func (p *pinnedStorage) Close() error {
+	return nil
+}
+
+// IsCompressed implements the storage.Storage.IsCompressed interface.
+func (p *pinnedStorage) IsCompressed() bool {
+	return p.s.IsCompressed()
+}
+
+// Compressor implements the storage.CompressorStorage interface.
+func (p *pinnedStorage) Compressor() storage.Compressor {
+	if cs, ok := p.s.(storage.CompressorStorage); ok {
+		return cs.Compressor()
+	}
+	return nil
+}
+
+// readOnlyStorer is the writable storage of a snapshot, to which objects cannot
+// be written.
+type readOnlyStorer struct {
+	storage.Storage
+}
+
+// Store implements the storage.WritableStorage.Store interface by returning an
+// error.
+func (r *readOnlyStorer) Store(oid []byte, _ io.Reader) (int64, error) {
+	return 0, fmt.Errorf("gitobj: cannot write object %x to a snapshot", oid)
+}
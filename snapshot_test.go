@@ -0,0 +1,85 @@
+package gitobj
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotIsConsistentDuringRepack(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	loose, err := db.WriteBlob(NewBlobFromBytes([]byte("loose\n")))
+	require.NoError(t, err)
+
+	snap, err := db.Snapshot()
+	require.NoError(t, err)
+	defer snap.Close()
+
+	// Repack the loose object, along with one written since the snapshot
+	// was taken, and prune it.
+	repack, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(repack)
+
+	packed, err := FromFilesystem(repack, "", PackedWrites())
+	require.NoError(t, err)
+	_, err = packed.WriteBlob(NewBlobFromBytes([]byte("loose\n")))
+	require.NoError(t, err)
+	later, err := packed.WriteBlob(NewBlobFromBytes([]byte("later\n")))
+	require.NoError(t, err)
+	require.NoError(t, packed.Flush())
+	require.NoError(t, packed.Close())
+	require.NoError(t, os.Rename(filepath.Join(repack, "pack"), filepath.Join(root, "pack")))
+
+	hexed := hex.EncodeToString(loose)
+	require.NoError(t, os.Remove(filepath.Join(root, hexed[:2], hexed[2:])))
+
+	blob, err := snap.Blob(loose)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(blob.Contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "loose\n", string(contents))
+	require.NoError(t, blob.Close())
+
+	_, err = snap.Blob(later)
+	assert.True(t, errors.IsNoSuchObject(err))
+
+	ok, err := snap.Has(later)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	var seen [][]byte
+	require.NoError(t, snap.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		seen = append(seen, oid)
+		return nil
+	}))
+	assert.Equal(t, [][]byte{loose}, seen)
+}
+
+func TestSnapshotIsReadOnly(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	snap, err := db.Snapshot()
+	require.NoError(t, err)
+	defer snap.Close()
+
+	_, err = snap.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	assert.Error(t, err)
+	assert.Empty(t, db.rw.(*memoryStorer).fs)
+
+	require.NoError(t, snap.Close())
+	assert.EqualError(t, snap.Reopen(), "gitobj: snapshot cannot be reopened")
+}
@@ -0,0 +1,72 @@
+package gitobj
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// spillingStorer is an implementation of the storer interface which holds
+// objects up to a given size in memory, and writes larger objects to another
+// writable storage (see: NewSpillingBackend).
+type spillingStorer struct {
+	// ms holds the objects whose stored form is no larger than
+	// "threshold" bytes.
+	ms *memoryStorer
+	// spill is the storage to which larger objects are written.
+	spill storage.WritableStorage
+	// threshold is the size, in bytes, of the largest object held in
+	// memory.
+	threshold int64
+}
+
+// Store implements the storer.Store function, and copies the data given in "r"
+// into memory, unless it is larger than the threshold, in which case it is
+// written to the spill storage instead. At most the threshold (and one more
+// byte) is buffered in memory in order to decide.
+func (s *spillingStorer) Store(sha []byte, r io.Reader) (int64, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, s.threshold+1); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	if int64(buf.Len()) <= s.threshold {
+		return s.ms.Store(sha, &buf)
+	}
+	return s.spill.Store(sha, io.MultiReader(&buf, r))
+}
+
+// Open implements the storage.Storage.Open function, and opens the object with
+// the given SHA from memory, or from the spill storage.
+func (s *spillingStorer) Open(sha []byte) (io.ReadCloser, error) {
+	f, err := s.ms.Open(sha)
+	if errors.IsNoSuchObject(err) {
+		return s.spill.Open(sha)
+	}
+	return f, err
+}
+
+// Flush flushes any objects staged by the spill storage (see:
+// ObjectDatabase.Flush).
+func (s *spillingStorer) Flush() error {
+	type flusher interface {
+		Flush() error
+	}
+
+	if f, ok := s.spill.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close closes the spill storage. Objects held in memory remain readable.
+func (s *spillingStorer) Close() error {
+	return s.spill.Close()
+}
+
+// IsCompressed returns true, because objects are stored compressed.
+func (s *spillingStorer) IsCompressed() bool {
+	return true
+}
@@ -0,0 +1,93 @@
+package gitobj
+
+import (
+	"crypto/sha1"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillingBackendSpillsLargeObjects(t *testing.T) {
+	spill, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	b, err := NewSpillingBackend(spill, 64)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Random contents do not compress, and so are stored at more than
+	// the threshold.
+	large := make([]byte, 1024)
+	rand.New(rand.NewSource(1)).Read(large)
+
+	small, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	spilled, err := db.WriteBlob(NewBlobFromBytes(large))
+	require.NoError(t, err)
+
+	_, rw := spill.Storage()
+	has, err := rw.(*memoryStorer).Has(small)
+	require.NoError(t, err)
+	assert.False(t, has)
+	has, err = rw.(*memoryStorer).Has(spilled)
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	for sha, contents := range map[string][]byte{
+		string(small):   []byte("Hello, world!\n"),
+		string(spilled): large,
+	} {
+		blob, err := db.Blob([]byte(sha))
+		require.NoError(t, err)
+
+		data, err := ioutil.ReadAll(blob.Contents)
+		blob.Close()
+		require.NoError(t, err)
+		assert.Equal(t, contents, data)
+	}
+
+	var n int
+	require.NoError(t, db.ForEachObject(func(oid []byte, typ ObjectType, size int64) error {
+		n++
+		return nil
+	}))
+	assert.Equal(t, 2, n)
+}
+
+func TestSpillingBackendSpillsToTheFilesystem(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	spill, err := NewFilesystemBackend(root, "", "", sha1.New())
+	require.NoError(t, err)
+
+	b, err := NewSpillingBackend(spill, 0)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b)
+	require.NoError(t, err)
+	defer db.Close()
+
+	blob := writeTestBlob(t, db)
+	require.NoError(t, db.Flush())
+
+	objects, err := ioutil.ReadDir(root)
+	require.NoError(t, err)
+	assert.NotEmpty(t, objects)
+
+	_, err = db.Blob(blob)
+	assert.NoError(t, err)
+}
+
+func TestNewSpillingBackendRequiresASpillBackend(t *testing.T) {
+	_, err := NewSpillingBackend(nil, 64)
+	assert.EqualError(t, err, "gitobj: no backend to spill objects to")
+}
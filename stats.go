@@ -0,0 +1,95 @@
+package gitobj
+
+import (
+	"sync/atomic"
+
+	"github.com/git-lfs/gitobj/v2/pack"
+)
+
+// Stats summarizes the work done in reading objects from a database, to help
+// diagnose slow scans without instrumenting the program making them: where
+// objects were read from, how much was inflated, how long the delta-base chains
+// resolved were, and how effective the caches were.
+type Stats struct {
+	// LooseReads, PackedReads, and AlternateReads are the number of
+	// objects read from the repository's own loose objects and packfiles,
+	// and from those of its alternates (see: LookupStats).
+	LooseReads     uint64
+	PackedReads    uint64
+	AlternateReads uint64
+	// InflatedBytes is the number of bytes of object contents read, once
+	// decompressed (and, for packed objects, once their deltas have been
+	// applied).
+	InflatedBytes uint64
+
+	// DeltaChains is the number of packed objects read which were stored
+	// as deltas, and Deltas the number of deltas applied to read them.
+	// MaxDeltaChain is the greatest number of deltas applied to read any
+	// one of them.
+	DeltaChains   uint64
+	Deltas        uint64
+	MaxDeltaChain int
+
+	// ObjectCacheHits and ObjectCacheMisses are the number of trees and
+	// commits which were, and were not, found in the object cache (see:
+	// ObjectCache).
+	ObjectCacheHits   uint64
+	ObjectCacheMisses uint64
+	// DeltaBaseCacheHits and DeltaBaseCacheMisses are the number of packed
+	// objects (and delta bases) which were, and were not, found in the
+	// delta base caches of the database's packfiles (see:
+	// DeltaBaseCacheLimit).
+	DeltaBaseCacheHits   uint64
+	DeltaBaseCacheMisses uint64
+}
+
+// readCounters counts the work done in reading objects from a database, and is
+// shared between it and its views.
+type readCounters struct {
+	// inflated is the number of bytes of object contents read. It is
+	// managed by sync/atomic.
+	inflated uint64
+	// packs counts the delta-base chains resolved in reading packed
+	// objects.
+	packs pack.Stats
+}
+
+// Stats returns a summary of the work done in reading objects through the
+// database, and all of its views, since it was opened. Objects whose types and
+// sizes were read by ObjectInfo or ForEachObject are not counted as having
+// been read.
+func (o *ObjectDatabase) Stats() *Stats {
+	stats := &Stats{
+		InflatedBytes: atomic.LoadUint64(&o.reads.inflated),
+		DeltaChains:   o.reads.packs.Chains(),
+		Deltas:        o.reads.packs.Deltas(),
+		MaxDeltaChain: o.reads.packs.MaxDepth(),
+	}
+
+	for _, stat := range o.LookupStats() {
+		switch {
+		case stat.Alternate:
+			stats.AlternateReads += stat.Lookups
+		case stat.Packed:
+			stats.PackedReads += stat.Lookups
+		default:
+			stats.LooseReads += stat.Lookups
+		}
+	}
+
+	stats.ObjectCacheHits, stats.ObjectCacheMisses = o.cache.stats()
+
+	seen := make(map[*pack.DeltaBaseCache]bool)
+	for _, s := range storages(o.ro) {
+		packs, ok := s.(*pack.Storage)
+		if !ok {
+			continue
+		}
+		if c := packs.DeltaBaseCache(); c != nil && !seen[c] {
+			seen[c] = true
+			stats.DeltaBaseCacheHits += c.Hits()
+			stats.DeltaBaseCacheMisses += c.Misses()
+		}
+	}
+	return stats
+}
@@ -0,0 +1,49 @@
+package gitobj
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCountsReads(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "", ObjectCache(1<<20))
+	require.NoError(t, err)
+	defer db.Close()
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	tree, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "hello.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	b, err := db.Blob(blob)
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(b.Contents)
+	require.NoError(t, err)
+	require.NoError(t, b.Close())
+
+	// The tree is read from the object cache the second time, and through
+	// a view, whose reads are also counted.
+	_, err = db.Tree(tree)
+	require.NoError(t, err)
+	_, err = db.View().Tree(tree)
+	require.NoError(t, err)
+
+	stats := db.Stats()
+	assert.EqualValues(t, 2, stats.LooseReads)
+	assert.EqualValues(t, 0, stats.PackedReads)
+	assert.EqualValues(t, 0, stats.AlternateReads)
+	assert.EqualValues(t, 14+len("100644 hello.txt\x00")+len(blob), stats.InflatedBytes)
+	assert.EqualValues(t, 1, stats.ObjectCacheHits)
+	assert.EqualValues(t, 1, stats.ObjectCacheMisses)
+	assert.EqualValues(t, 0, stats.DeltaChains)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// errReaderClosed is returned when reading from a pooled reader once it has been
+// closed.
+var errReaderClosed = fmt.Errorf("gitobj/storage: read from closed reader")
+
+// Compressor is an implementation of the zlib format, which is used to compress
+// the contents of loose objects. Implementations other than Zlib may be used
+// to trade compression ratio for speed, but must read and write data which is
+// compatible with it.
+type Compressor interface {
+	// NewReader returns an io.ReadCloser which decompresses the data read
+	// from "r". Closing it does not close "r".
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	// NewWriter returns an io.WriteCloser which compresses the data written
+	// to it at the given level (as understood by compress/zlib) into "w".
+	// Closing it flushes any buffered data, but does not close "w".
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// CompressorStorage is an optional interface implemented by Storage types whose
+// data is compressed, but should be decompressed with a Compressor other than
+// Zlib.
+type CompressorStorage interface {
+	// Compressor returns the Compressor with which the storage's data is
+	// decompressed.
+	Compressor() Compressor
+}
+
+var (
+	// Zlib is the Compressor implemented by the standard library's
+	// compress/zlib package, and is used unless another is given.
+	//
+	// Its readers are pooled, and reused once closed, so that reading
+	// many objects does not allocate a new reader for each. A reader
+	// must therefore not be used after it is closed.
+	Zlib Compressor = &zlibCompressor{pooled: true}
+	// UnpooledZlib is as Zlib, but allocates a new reader for each
+	// stream decompressed.
+	UnpooledZlib Compressor = &zlibCompressor{}
+
+	// zlibReaders is the pool of readers used by Zlib.
+	zlibReaders sync.Pool
+)
+
+type zlibCompressor struct {
+	pooled bool
+}
+
+// NewReader implements Compressor.NewReader.
+func (c *zlibCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	if !c.pooled {
+		return zlib.NewReader(r)
+	}
+
+	zr, ok := zlibReaders.Get().(io.ReadCloser)
+	if !ok {
+		var err error
+		if zr, err = zlib.NewReader(r); err != nil {
+			return nil, err
+		}
+		return &pooledZlibReader{zr: zr}, nil
+	}
+
+	if err := zr.(zlib.Resetter).Reset(r, nil); err != nil {
+		zlibReaders.Put(zr)
+		return nil, err
+	}
+	return &pooledZlibReader{zr: zr}, nil
+}
+
+// NewWriter implements Compressor.NewWriter.
+func (c *zlibCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, level)
+}
+
+// pooledZlibReader is a zlib reader which returns itself to the pool from which
+// it came once closed.
+type pooledZlibReader struct {
+	zr io.ReadCloser
+}
+
+// Read implements io.Reader.
+func (p *pooledZlibReader) Read(b []byte) (int, error) {
+	if p.zr == nil {
+		return 0, errReaderClosed
+	}
+	return p.zr.Read(b)
+}
+
+// Close implements io.Closer by closing the reader and returning it to the
+// pool. Subsequent calls to Read fail, and to Close do nothing.
+func (p *pooledZlibReader) Close() error {
+	if p.zr == nil {
+		return nil
+	}
+
+	err := p.zr.Close()
+	zlibReaders.Put(p.zr)
+	p.zr = nil
+
+	return err
+}
+
+// compressor returns the Compressor with which to decompress the data read from
+// "s".
+func compressor(s Storage) Compressor {
+	if cs, ok := s.(CompressorStorage); ok {
+		if c := cs.Compressor(); c != nil {
+			return c
+		}
+	}
+	return Zlib
+}
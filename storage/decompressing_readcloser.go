@@ -1,20 +1,20 @@
 package storage
 
 import (
-	"compress/zlib"
 	"io"
 )
 
-// decompressingReadCloser wraps zlib.NewReader to ensure that both the zlib
-// reader and its underlying type are closed.
+// decompressingReadCloser wraps a Compressor's reader to ensure that both the
+// decompressing reader and its underlying type are closed.
 type decompressingReadCloser struct {
 	r  io.ReadCloser
 	zr io.ReadCloser
 }
 
-// newDecompressingReadCloser creates a new wrapped zlib reader
-func newDecompressingReadCloser(r io.ReadCloser) (io.ReadCloser, error) {
-	zr, err := zlib.NewReader(r)
+// newDecompressingReadCloser creates a new wrapped reader, decompressing the
+// data read from "r" with the given Compressor.
+func newDecompressingReadCloser(c Compressor, r io.ReadCloser) (io.ReadCloser, error) {
+	zr, err := c.NewReader(r)
 	if err != nil {
 		return nil, err
 	}
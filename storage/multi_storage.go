@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"context"
 	"io"
+	"sync/atomic"
 
 	"github.com/git-lfs/gitobj/v2/errors"
 )
@@ -10,31 +12,116 @@ import (
 // object database.
 type multiStorage struct {
 	impls []Storage
+	// lookups counts the number of objects opened from each of "impls",
+	// and is managed by sync/atomic.
+	lookups []uint64
 }
 
 func MultiStorage(args ...Storage) Storage {
-	return &multiStorage{impls: args}
+	return &multiStorage{impls: args, lookups: make([]uint64, len(args))}
 }
 
 // Open returns a handle on an existing object keyed by the given object
 // ID.  It returns an error if that file does not already exist.
 func (m *multiStorage) Open(oid []byte) (f io.ReadCloser, err error) {
-	for _, s := range m.impls {
-		f, err := s.Open(oid)
+	return m.OpenContext(context.Background(), oid)
+}
+
+// OpenContext implements the storage.ContextStorage interface by opening the
+// first object keyed by the given object ID in any of the underlying storage
+// implementations, unless the given context is done before it is found.
+func (m *multiStorage) OpenContext(ctx context.Context, oid []byte) (io.ReadCloser, error) {
+	for i, s := range m.impls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		f, err := Open(ctx, s, oid)
 		if err != nil {
 			if errors.IsNoSuchObject(err) {
 				continue
 			}
 			return nil, err
 		}
+		atomic.AddUint64(&m.lookups[i], 1)
 		if s.IsCompressed() {
-			return newDecompressingReadCloser(f)
+			return newDecompressingReadCloser(compressor(s), f)
 		}
 		return f, nil
 	}
 	return nil, errors.NoSuchObject(oid)
 }
 
+// ReadRange implements the storage.RangeStorage interface by returning a
+// handle on a range of the contents of the first object keyed by the given
+// object ID in any of the underlying storage implementations.
+func (m *multiStorage) ReadRange(oid []byte, off, n int64) (io.ReadCloser, error) {
+	for i, s := range m.impls {
+		r, err := ReadRange(s, oid, off, n)
+		if err != nil {
+			if errors.IsNoSuchObject(err) {
+				continue
+			}
+			return nil, err
+		}
+		atomic.AddUint64(&m.lookups[i], 1)
+		return r, nil
+	}
+	return nil, errors.NoSuchObject(oid)
+}
+
+// Has implements the storage.HasStorage interface by returning whether an
+// object keyed by the given object ID exists in any of the underlying storage
+// implementations.
+func (m *multiStorage) Has(oid []byte) (bool, error) {
+	for _, s := range m.impls {
+		ok, err := Has(s, oid)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// Enumerate implements the storage.EnumerableStorage interface by calling "fn"
+// with the ID of each object held by each of the underlying storage
+// implementations which are themselves enumerable. An object held by more than
+// one of them is visited more than once.
+func (m *multiStorage) Enumerate(fn func(oid []byte) error) error {
+	for _, s := range m.impls {
+		if es, ok := s.(EnumerableStorage); ok {
+			if err := es.Enumerate(fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Storages returns the underlying storage implementations, in the order in
+// which they are searched.
+func (m *multiStorage) Storages() []Storage {
+	return m.impls
+}
+
+// Lookups returns the number of objects opened (or read from, as by ReadRange)
+// from each of the underlying storage implementations, in the same order as
+// Storages.
+func (m *multiStorage) Lookups() []uint64 {
+	lookups := make([]uint64, len(m.lookups))
+	for i := range m.lookups {
+		lookups[i] = atomic.LoadUint64(&m.lookups[i])
+	}
+	return lookups
+}
+
+// ResetLookups resets the counts returned by Lookups to zero.
+func (m *multiStorage) ResetLookups() {
+	for i := range m.lookups {
+		atomic.StoreUint64(&m.lookups[i], 0)
+	}
+}
+
 // Close closes the filesystem, after which no more operations are
 // allowed.
 func (m *multiStorage) Close() error {
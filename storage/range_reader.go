@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ReadRange returns a handle on at most "n" bytes of the uncompressed contents
+// of the object keyed by "oid" in the given storage "s", beginning at offset
+// "off" into the object's contents (after the object header).
+//
+// If "s" implements RangeStorage, the request is delegated to it. Otherwise,
+// the object is opened, and read only as far as is necessary to reach the end
+// of the requested range.
+func ReadRange(s Storage, oid []byte, off, n int64) (io.ReadCloser, error) {
+	if off < 0 || n < 0 {
+		return nil, fmt.Errorf("gitobj/storage: invalid range: %d+%d", off, n)
+	}
+
+	if rs, ok := s.(RangeStorage); ok {
+		return rs.ReadRange(oid, off, n)
+	}
+
+	f, err := s.Open(oid)
+	if err != nil {
+		return nil, err
+	}
+	if s.IsCompressed() {
+		zf, err := newDecompressingReadCloser(compressor(s), f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		f = zf
+	}
+
+	r := bufio.NewReader(f)
+
+	// Skip past the object header, which is terminated by a NUL byte.
+	if _, err := r.ReadSlice('\x00'); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return newRangeReadCloser(r, f, off, n)
+}
+
+// rangeReadCloser is an io.ReadCloser yielding a bounded window of an
+// underlying stream, and closing that stream upon Close().
+type rangeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// newRangeReadCloser discards the first "off" bytes of "r", and returns an
+// io.ReadCloser that yields at most the following "n" bytes, closing "c" when
+// it is itself closed.
+//
+// If "r" ends before "off" bytes could be discarded, the returned reader is
+// empty.
+func newRangeReadCloser(r io.Reader, c io.Closer, off, n int64) (io.ReadCloser, error) {
+	if _, err := io.CopyN(ioutil.Discard, r, off); err != nil {
+		if err != io.EOF {
+			c.Close()
+			return nil, err
+		}
+		n = 0
+	}
+	return &rangeReadCloser{
+		Reader: io.LimitReader(r, n),
+		Closer: c,
+	}, nil
+}
@@ -1,12 +1,29 @@
 package storage
 
-import "io"
+import (
+	"context"
+	"io"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+)
 
 // Storage implements an interface for reading, but not writing, objects in an
 // object database.
+//
+// Storage may be implemented outside of this module, as for an object store
+// backed by S3 or by a database, and given to gitobj.FromBackend through a
+// Backend. Objects are keyed by their binary object IDs, and each is held in
+// its loose form: the header "<type> <size>\x00", followed by its contents,
+// compressed if IsCompressed returns true. Implementations may also implement
+// the optional interfaces in this package (RangeStorage, ContextStorage,
+// HasStorage, EnumerableStorage, and CompressorStorage) to offer more
+// efficient access. The storagetest package checks that an implementation
+// behaves as the database expects.
 type Storage interface {
 	// Open returns a handle on an existing object keyed by the given object
-	// ID.  It returns an error if that file does not already exist.
+	// ID.  It returns an error if that file does not already exist, which
+	// must satisfy errors.IsNoSuchObject, so that the object may be looked
+	// for elsewhere.
 	Open(oid []byte) (f io.ReadCloser, err error)
 
 	// Close closes the filesystem, after which no more operations are
@@ -26,5 +43,86 @@ type WritableStorage interface {
 	// Store copies the data given in "r" to the unique object path given by
 	// "oid". It returns an error if that file already exists (acting as if
 	// the `os.O_EXCL` mode is given in a bitmask to os.Open).
+	//
+	// The data is the object in its loose form, compressed as the database
+	// compresses it (see: Compressor), so writable storage should report
+	// that it IsCompressed. Since an object's contents are given by its
+	// ID, implementations may instead discard the data of an object which
+	// is already stored, and succeed, as the filesystem backend does.
 	Store(oid []byte, r io.Reader) (n int64, err error)
 }
+
+// HasStorage is an optional interface implemented by Storage types that are
+// able to report whether they hold an object more cheaply than by opening it.
+type HasStorage interface {
+	// Has returns whether an object keyed by the given object ID exists.
+	Has(oid []byte) (bool, error)
+}
+
+// EnumerableStorage is an optional interface implemented by Storage types that
+// are able to list the objects they hold, so that they may be included when
+// enumerating the objects in a database (as by gitobj's ForEachObject).
+type EnumerableStorage interface {
+	// Enumerate calls "fn" with the ID of each object held, in no
+	// particular order. If "fn" returns an error, Enumerate stops, and
+	// returns that error.
+	Enumerate(fn func(oid []byte) error) error
+}
+
+// RangeStorage is an optional interface implemented by Storage types that are
+// able to serve a portion of an object's contents without reading (or
+// transferring) the object in its entirety.
+type RangeStorage interface {
+	// ReadRange returns a handle on at most "n" bytes of the uncompressed
+	// contents of the object keyed by "oid", beginning at offset "off".
+	// The offset is relative to the start of the object's contents, and
+	// does not include the "<type> <size>\x00" object header.
+	//
+	// It returns an error if the object does not exist.
+	ReadRange(oid []byte, off, n int64) (io.ReadCloser, error)
+}
+
+// ContextStorage is an optional interface implemented by Storage types that are
+// able to abandon opening (and reading) an object once a context is done, for
+// instance because resolving it may take some time.
+type ContextStorage interface {
+	// OpenContext is as Open, but returns the context's error once it is
+	// done, including from the returned handle.
+	OpenContext(ctx context.Context, oid []byte) (io.ReadCloser, error)
+}
+
+// Open returns a handle on the object keyed by "oid" in the given storage "s",
+// as by s.Open, unless the given context is done.
+//
+// If "s" implements ContextStorage, the request is delegated to it. Otherwise,
+// the context is checked only before the object is opened.
+func Open(ctx context.Context, s Storage, oid []byte) (io.ReadCloser, error) {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.OpenContext(ctx, oid)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Open(oid)
+}
+
+// Has returns whether an object keyed by "oid" exists in the given storage "s".
+//
+// If "s" implements HasStorage, the request is delegated to it. Otherwise, the
+// object is opened (and closed), and is taken to exist unless opening it fails
+// with an error satisfying errors.IsNoSuchObject.
+func Has(s Storage, oid []byte) (bool, error) {
+	if hs, ok := s.(HasStorage); ok {
+		return hs.Has(oid)
+	}
+
+	f, err := s.Open(oid)
+	if err != nil {
+		if errors.IsNoSuchObject(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, f.Close()
+}
@@ -0,0 +1,267 @@
+// Package storagetest implements a suite of tests of the behavior expected of
+// implementations of the storage interfaces, so that custom storage (as for an
+// object store backed by S3 or by a database) may be checked before it is given
+// to gitobj.FromBackend.
+package storagetest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/git-lfs/gitobj/v2/errors"
+	"github.com/git-lfs/gitobj/v2/storage"
+)
+
+// object is a loose object with which the storage is tested.
+type object struct {
+	// oid is the name of the object.
+	oid []byte
+	// contents is the contents of the object, without its header.
+	contents []byte
+	// data is the object in its loose form, compressed, as it is given to
+	// WritableStorage.Store.
+	data []byte
+}
+
+// newObject returns the loose blob with the given contents.
+func newObject(contents string) *object {
+	loose := fmt.Sprintf("blob %d\x00%s", len(contents), contents)
+
+	oid := sha1.Sum([]byte(loose))
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte(loose))
+	zw.Close()
+
+	return &object{oid: oid[:], contents: []byte(contents), data: buf.Bytes()}
+}
+
+// TestStorage runs each of the tests in the suite against the storage returned
+// by "newStorage", which is called to create new, empty storage for each test,
+// and which is closed once it is done. Tests of the optional interfaces which
+// the storage does not implement are skipped.
+//
+// Objects are stored as the database stores them: in their loose form, and
+// compressed with zlib.
+func TestStorage(t *testing.T, newStorage func(t *testing.T) storage.WritableStorage) {
+	for _, test := range []struct {
+		name string
+		fn   func(t *testing.T, s storage.WritableStorage)
+	}{
+		{"OpenMissingObject", testOpenMissingObject},
+		{"StoreAndOpen", testStoreAndOpen},
+		{"StoreExistingObject", testStoreExistingObject},
+		{"OpenTwice", testOpenTwice},
+		{"Has", testHas},
+		{"Enumerate", testEnumerate},
+		{"ReadRange", testReadRange},
+		{"OpenContext", testOpenContext},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := newStorage(t)
+			defer func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("Close: %s", err)
+				}
+			}()
+			test.fn(t, s)
+		})
+	}
+}
+
+// store stores the given objects in "s", failing the test if they cannot be.
+func store(t *testing.T, s storage.WritableStorage, objects ...*object) {
+	for _, o := range objects {
+		if _, err := s.Store(o.oid, bytes.NewReader(o.data)); err != nil {
+			t.Fatalf("Store(%x): %s", o.oid, err)
+		}
+	}
+}
+
+// read opens and reads the object named "oid" from "s", decompressing it if
+// the storage is compressed, and returns it in its loose form.
+func read(t *testing.T, s storage.Storage, oid []byte) []byte {
+	f, err := s.Open(oid)
+	if err != nil {
+		t.Fatalf("Open(%x): %s", oid, err)
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("reading %x: %s", oid, err)
+	}
+	if !s.IsCompressed() {
+		return data
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decompressing %x: %s", oid, err)
+	}
+	loose, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decompressing %x: %s", oid, err)
+	}
+	return loose
+}
+
+// loose returns the given object in its loose form, uncompressed.
+func loose(o *object) []byte {
+	return append([]byte(fmt.Sprintf("blob %d\x00", len(o.contents))), o.contents...)
+}
+
+func testOpenMissingObject(t *testing.T, s storage.WritableStorage) {
+	o := newObject("missing\n")
+
+	_, err := s.Open(o.oid)
+	if !errors.IsNoSuchObject(err) {
+		t.Errorf("Open of missing object: got error %v, expected one satisfying errors.IsNoSuchObject", err)
+	}
+}
+
+func testStoreAndOpen(t *testing.T, s storage.WritableStorage) {
+	a, b := newObject("Hello, world!\n"), newObject("Goodbye, world!\n")
+	store(t, s, a, b)
+
+	for _, o := range []*object{a, b} {
+		if got := read(t, s, o.oid); !bytes.Equal(got, loose(o)) {
+			t.Errorf("Open(%x): got %q, expected %q", o.oid, got, loose(o))
+		}
+	}
+}
+
+func testStoreExistingObject(t *testing.T, s storage.WritableStorage) {
+	o := newObject("Hello, world!\n")
+	store(t, s, o)
+	store(t, s, o)
+
+	if got := read(t, s, o.oid); !bytes.Equal(got, loose(o)) {
+		t.Errorf("Open(%x) after storing twice: got %q, expected %q", o.oid, got, loose(o))
+	}
+}
+
+func testOpenTwice(t *testing.T, s storage.WritableStorage) {
+	o := newObject("Hello, world!\n")
+	store(t, s, o)
+
+	// Each handle must be independent of the others, and of any opened
+	// before it.
+	for i := 0; i < 2; i++ {
+		if got := read(t, s, o.oid); !bytes.Equal(got, loose(o)) {
+			t.Errorf("Open(%x) #%d: got %q, expected %q", o.oid, i+1, got, loose(o))
+		}
+	}
+}
+
+func testHas(t *testing.T, s storage.WritableStorage) {
+	hs, ok := s.(storage.HasStorage)
+	if !ok {
+		t.Skip("storage does not implement storage.HasStorage")
+	}
+
+	present, missing := newObject("present\n"), newObject("missing\n")
+	store(t, s, present)
+
+	for _, test := range []struct {
+		o    *object
+		want bool
+	}{
+		{present, true},
+		{missing, false},
+	} {
+		got, err := hs.Has(test.o.oid)
+		if err != nil {
+			t.Errorf("Has(%x): %s", test.o.oid, err)
+		} else if got != test.want {
+			t.Errorf("Has(%x): got %t, expected %t", test.o.oid, got, test.want)
+		}
+	}
+}
+
+func testEnumerate(t *testing.T, s storage.WritableStorage) {
+	es, ok := s.(storage.EnumerableStorage)
+	if !ok {
+		t.Skip("storage does not implement storage.EnumerableStorage")
+	}
+
+	objects := []*object{newObject("a\n"), newObject("b\n"), newObject("c\n")}
+	store(t, s, objects...)
+
+	seen := make(map[string]int)
+	err := es.Enumerate(func(oid []byte) error {
+		seen[string(oid)]++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Enumerate: %s", err)
+	}
+	for _, o := range objects {
+		if seen[string(o.oid)] != 1 {
+			t.Errorf("Enumerate: visited %x %d time(s), expected once", o.oid, seen[string(o.oid)])
+		}
+	}
+
+	stop := fmt.Errorf("stop")
+	calls := 0
+	err = es.Enumerate(func(oid []byte) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Enumerate: got error %v after %d call(s), expected %v after one", err, calls, stop)
+	}
+}
+
+func testReadRange(t *testing.T, s storage.WritableStorage) {
+	if _, ok := s.(storage.RangeStorage); !ok {
+		t.Skip("storage does not implement storage.RangeStorage")
+	}
+
+	o := newObject("Hello, world!\n")
+	store(t, s, o)
+
+	r, err := storage.ReadRange(s, o.oid, 7, 5)
+	if err != nil {
+		t.Fatalf("ReadRange(%x): %s", o.oid, err)
+	}
+	defer r.Close()
+
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading range of %x: %s", o.oid, err)
+	}
+	if want := o.contents[7:12]; !bytes.Equal(got, want) {
+		t.Errorf("ReadRange(%x): got %q, expected %q", o.oid, got, want)
+	}
+}
+
+func testOpenContext(t *testing.T, s storage.WritableStorage) {
+	cs, ok := s.(storage.ContextStorage)
+	if !ok {
+		t.Skip("storage does not implement storage.ContextStorage")
+	}
+
+	o := newObject("Hello, world!\n")
+	store(t, s, o)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	f, err := cs.OpenContext(ctx, o.oid)
+	if err == nil {
+		// The error may instead be returned by the handle.
+		_, err = ioutil.ReadAll(f)
+		f.Close()
+	}
+	if err != context.Canceled {
+		t.Errorf("OpenContext with canceled context: got error %v, expected %v", err, context.Canceled)
+	}
+}
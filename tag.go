@@ -17,6 +17,14 @@ type Tag struct {
 	Tagger     string
 
 	Message string
+
+	// terminated indicates whether the decoded tag's message ended with a
+	// newline, which is not held by Message, so that it is encoded again
+	// with one, and keeps its object ID (and any signature over it).
+	terminated bool
+	// decodeStats describes how the tag was read, if it was read with
+	// RecordDecodeStats.
+	decodeStats *DecodeStats
 }
 
 // Decode implements Object.Decode and decodes the uncompressed tag being
@@ -31,8 +39,13 @@ func (t *Tag) Decode(hash hash.Hash, r io.Reader, size int64) (int, error) {
 	var (
 		finishedHeaders bool
 		message         []string
+		// unterminated indicates whether the last line lacked a
+		// newline.
+		unterminated bool
 	)
 
+	scanner.Split(scanObjectLines(&unterminated))
+
 	for scanner.Scan() {
 		if finishedHeaders {
 			message = append(message, scanner.Text())
@@ -72,6 +85,7 @@ func (t *Tag) Decode(hash hash.Hash, r io.Reader, size int64) (int, error) {
 	}
 
 	t.Message = strings.Join(message, "\n")
+	t.terminated = len(message) > 0 && !unterminated
 
 	return int(size), nil
 }
@@ -88,7 +102,11 @@ func (t *Tag) Encode(w io.Writer) (int, error) {
 		fmt.Sprintf("tagger %s", t.Tagger),
 	}
 
-	return fmt.Fprintf(w, "%s\n\n%s", strings.Join(headers, "\n"), t.Message)
+	format := "%s\n\n%s"
+	if t.terminated {
+		format += "\n"
+	}
+	return fmt.Fprintf(w, format, strings.Join(headers, "\n"), t.Message)
 }
 
 // Equal returns whether the receiving and given Tags are equal, or in other
@@ -104,7 +122,8 @@ func (t *Tag) Equal(other *Tag) bool {
 			t.ObjectType == other.ObjectType &&
 			t.Name == other.Name &&
 			t.Tagger == other.Tagger &&
-			t.Message == other.Message
+			t.Message == other.Message &&
+			t.terminated == other.terminated
 	}
 
 	return true
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTagTypeReturnsCorrectObjectType(t *testing.T) {
@@ -64,3 +66,27 @@ func TestTagDecode(t *testing.T) {
 	assert.Equal(t, "A U Thor <author@example.com>", tag.Tagger)
 	assert.Equal(t, "The quick brown fox jumps over the lazy dog.", tag.Message)
 }
+
+func TestTagDecodingRoundTripsExactly(t *testing.T) {
+	headers := "object 6161616161616161616161616161616161616161\n" +
+		"type commit\n" +
+		"tag v2.4.0\n" +
+		"tagger A U Thor <author@example.com> 1503956287 -0400\n"
+
+	for desc, tt := range map[string]string{
+		"message":              headers + "\nThe quick brown fox.\n",
+		"carriage returns":     headers + "\nThe quick brown fox.\r\n",
+		"unterminated message": headers + "\nThe quick brown fox.",
+		"blank lines":          headers + "\nThe quick brown fox.\n\n",
+		"empty message":        headers + "\n",
+	} {
+		var tag Tag
+		_, err := tag.Decode(sha1.New(), strings.NewReader(tt), int64(len(tt)))
+		require.NoError(t, err, desc)
+
+		var buf bytes.Buffer
+		_, err = tag.Encode(&buf)
+		require.NoError(t, err, desc)
+		assert.Equal(t, tt, buf.String(), desc)
+	}
+}
@@ -28,6 +28,10 @@ const (
 type Tree struct {
 	// Entries is the list of entries held by this tree.
 	Entries []*TreeEntry
+
+	// decodeStats describes how the tree was read, if it was read with
+	// RecordDecodeStats.
+	decodeStats *DecodeStats
 }
 
 // Type implements Object.ObjectType by returning the correct object type for
@@ -230,12 +234,37 @@ func (e *TreeEntry) Type() ObjectType {
 	}
 }
 
+// NewGitlinkEntry returns a new *TreeEntry with the given name for a submodule
+// whose checked-out commit is "commit", as Git records a submodule: with a
+// filemode of 0160000, naming a commit which is not held by the database.
+func NewGitlinkEntry(name string, commit []byte) *TreeEntry {
+	return &TreeEntry{
+		Name:     name,
+		Oid:      append([]byte(nil), commit...),
+		Filemode: sIFGITLINK,
+	}
+}
+
 // IsLink returns true if the given TreeEntry is a blob which represents a
 // symbolic link (i.e., with a filemode of 0120000.
 func (e *TreeEntry) IsLink() bool {
 	return e.Filemode & sIFMT == sIFLNK
 }
 
+// IsSymlink returns true if the given TreeEntry is a blob which represents a
+// symbolic link, as IsLink does.
+func (e *TreeEntry) IsSymlink() bool {
+	return e.IsLink()
+}
+
+// IsSubmodule returns true if the given TreeEntry is a gitlink, which records
+// the commit checked out in a submodule (i.e., with a filemode of 0160000).
+// That commit is held by the submodule's repository, rather than by the
+// database holding the tree, and so should not be read from it.
+func (e *TreeEntry) IsSubmodule() bool {
+	return e.Filemode&sIFMT == sIFGITLINK
+}
+
 // SubtreeOrder is an implementation of sort.Interface that sorts a set of
 // `*TreeEntry`'s according to "subtree" order. This ordering is required to
 // write trees in a correct, readable format to the Git object database.
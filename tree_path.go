@@ -0,0 +1,187 @@
+package gitobj
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EntryAtPath returns the entry at the given "/"-separated path beneath the
+// tree named by "sha", descending into nested trees as needed, as with:
+//
+//	git ls-tree <tree> -- <path>
+//
+// Leading and trailing slashes in the path are ignored. If there is no entry at
+// the path (including when one of its leading components is not a tree), a
+// *PathNotFound is returned.
+func (o *ObjectDatabase) EntryAtPath(sha []byte, path string) (*TreeEntry, error) {
+	trimmed := strings.Trim(path, "/")
+	if len(trimmed) == 0 {
+		return nil, &PathNotFound{Tree: sha, Path: path}
+	}
+
+	entry, err := o.entryAtPath(sha, trimmed)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, &PathNotFound{Tree: sha, Path: path}
+	}
+	return entry, nil
+}
+
+// entryAtPath returns the entry at the given "/"-separated path beneath the
+// tree named by "sha", or nil if there is no such entry. A nil "sha" is
+// treated as if it named an empty tree.
+func (o *ObjectDatabase) entryAtPath(sha []byte, path string) (*TreeEntry, error) {
+	if sha == nil {
+		return nil, nil
+	}
+
+	tree, err := o.Tree(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	name, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		name, rest = path[:i], path[i+1:]
+	}
+
+	for _, entry := range tree.Entries {
+		if entry.Name != name {
+			continue
+		}
+		if len(rest) == 0 {
+			return entry, nil
+		}
+		if entry.Filemode&sIFMT != sIFDIR {
+			return nil, nil
+		}
+		return o.entryAtPath(entry.Oid, rest)
+	}
+	return nil, nil
+}
+
+// MoveEntry moves the entry at "oldPath" beneath the tree named by "sha" (which
+// may itself be a tree, and so move a whole directory) to "newPath", creating
+// any trees leading to "newPath" which do not already exist, and returns the
+// name of the resulting tree.
+//
+// Only the trees leading to "oldPath" and "newPath" are rewritten; every other
+// tree is reused as-is. Trees left empty by the move are removed, as Git does
+// not record empty directories.
+//
+// If there is no entry at "oldPath", a *PathNotFound is returned. It is an
+// error for an entry to already exist at "newPath", or for "newPath" to lie
+// within "oldPath".
+func (o *ObjectDatabase) MoveEntry(sha []byte, oldPath, newPath string) ([]byte, error) {
+	from, to := strings.Trim(oldPath, "/"), strings.Trim(newPath, "/")
+	if len(from) == 0 {
+		return nil, &PathNotFound{Tree: sha, Path: oldPath}
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("gitobj: cannot move %q to the root of tree %x", oldPath, sha)
+	}
+
+	entry, err := o.EntryAtPath(sha, from)
+	if err != nil {
+		return nil, err
+	}
+	if from == to {
+		return sha, nil
+	}
+	if strings.HasPrefix(to, from+"/") {
+		return nil, fmt.Errorf("gitobj: cannot move %q into itself", oldPath)
+	}
+
+	removed, err := o.updatePath(sha, from, func(*TreeEntry) (*TreeEntry, error) {
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The moved entry is inserted afterwards, and so the resulting tree is
+	// never empty.
+	return o.updatePath(removed, to, func(existing *TreeEntry) (*TreeEntry, error) {
+		if existing != nil {
+			return nil, fmt.Errorf("gitobj: %q already exists in tree %x", newPath, sha)
+		}
+		return &TreeEntry{
+			Name:     to[strings.LastIndexByte(to, '/')+1:],
+			Oid:      entry.Oid,
+			Filemode: entry.Filemode,
+		}, nil
+	})
+}
+
+// updatePath replaces the entry at the given "/"-separated path beneath the tree
+// named by "sha" with the one returned by "fn", which is given the existing
+// entry, or nil if there is none, and may return nil to remove the entry. It
+// writes, and returns the name of, each tree leading to the entry.
+//
+// Missing trees leading to the entry are created, and those left empty are
+// removed. A nil "sha" is treated as if it named an empty tree, and nil is
+// returned in place of an empty tree.
+func (o *ObjectDatabase) updatePath(sha []byte, path string, fn func(*TreeEntry) (*TreeEntry, error)) ([]byte, error) {
+	tree := &Tree{}
+	if sha != nil {
+		var err error
+		if tree, err = o.Tree(sha); err != nil {
+			return nil, err
+		}
+	}
+
+	name, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		name, rest = path[:i], path[i+1:]
+	}
+
+	var existing *TreeEntry
+	for _, entry := range tree.Entries {
+		if entry.Name == name {
+			existing = entry
+			break
+		}
+	}
+
+	var updated *TreeEntry
+	if len(rest) == 0 {
+		var err error
+		if updated, err = fn(existing); err != nil {
+			return nil, err
+		}
+	} else {
+		var subtree []byte
+		if existing != nil {
+			if existing.Filemode&sIFMT != sIFDIR {
+				return nil, fmt.Errorf("gitobj: %q is not a tree", name)
+			}
+			subtree = existing.Oid
+		}
+
+		oid, err := o.updatePath(subtree, rest, fn)
+		if err != nil {
+			return nil, err
+		}
+
+		if oid != nil {
+			updated = &TreeEntry{Name: name, Oid: oid, Filemode: 040000}
+		}
+	}
+
+	entries := make([]*TreeEntry, 0, len(tree.Entries)+1)
+	for _, entry := range tree.Entries {
+		if entry.Name != name {
+			entries = append(entries, entry)
+		}
+	}
+	if updated != nil {
+		entries = append(entries, updated)
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return o.WriteTree((&Tree{}).Merge(entries...))
+}
@@ -0,0 +1,104 @@
+package gitobj
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryAtPath(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	for _, path := range []string{"sub/deeper/c.txt", "/sub/deeper/c.txt", "sub/deeper/c.txt/"} {
+		entry, err := db.EntryAtPath(root, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, "c.txt", entry.Name, path)
+		assert.EqualValues(t, 0100644, entry.Filemode, path)
+	}
+
+	entry, err := db.EntryAtPath(root, "sub/deeper")
+	require.NoError(t, err)
+	assert.Equal(t, TreeObjectType, entry.Type())
+}
+
+func TestEntryAtPathReportsMissingPaths(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	for _, path := range []string{"missing.txt", "sub/missing.txt", "a.txt/b.txt", ""} {
+		_, err := db.EntryAtPath(root, path)
+		assert.Equal(t, &PathNotFound{Tree: root, Path: path}, err, path)
+	}
+}
+
+func TestMoveEntryMovesSubtrees(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	deeper, err := db.EntryAtPath(root, "sub/deeper")
+	require.NoError(t, err)
+
+	moved, err := db.MoveEntry(root, "sub/deeper", "other/place")
+	require.NoError(t, err)
+
+	entry, err := db.EntryAtPath(moved, "other/place")
+	require.NoError(t, err)
+	assert.Equal(t, deeper.Oid, entry.Oid)
+	assert.EqualValues(t, 040000, entry.Filemode)
+
+	_, err = db.EntryAtPath(moved, "sub/deeper")
+	assert.Equal(t, &PathNotFound{Tree: moved, Path: "sub/deeper"}, err)
+
+	tree, err := db.Tree(moved)
+	require.NoError(t, err)
+	require.Len(t, tree.Entries, 4)
+	assert.Equal(t, "a.txt", tree.Entries[0].Name)
+	assert.Equal(t, "other", tree.Entries[1].Name)
+	assert.Equal(t, "sub", tree.Entries[2].Name)
+	assert.Equal(t, "z.txt", tree.Entries[3].Name)
+
+	sub, err := db.Tree(tree.Entries[2].Oid)
+	require.NoError(t, err)
+	require.Len(t, sub.Entries, 1)
+	assert.Equal(t, "b.txt", sub.Entries[0].Name)
+}
+
+func TestMoveEntryRemovesEmptyTrees(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	moved, err := db.MoveEntry(root, "sub/deeper/c.txt", "c.txt")
+	require.NoError(t, err)
+
+	_, err = db.EntryAtPath(moved, "c.txt")
+	assert.NoError(t, err)
+	_, err = db.EntryAtPath(moved, "sub/deeper")
+	assert.Equal(t, &PathNotFound{Tree: moved, Path: "sub/deeper"}, err)
+	_, err = db.EntryAtPath(moved, "sub/b.txt")
+	assert.NoError(t, err)
+
+	// Moving the file back restores the original tree.
+	restored, err := db.MoveEntry(moved, "c.txt", "sub/deeper/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, root, restored)
+}
+
+func TestMoveEntryRejectsInvalidMoves(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	_, err := db.MoveEntry(root, "missing.txt", "b.txt")
+	assert.Equal(t, &PathNotFound{Tree: root, Path: "missing.txt"}, err)
+
+	_, err = db.MoveEntry(root, "a.txt", "z.txt")
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: \"z.txt\" already exists in tree %x", root))
+
+	_, err = db.MoveEntry(root, "sub", "sub/deeper/sub")
+	assert.EqualError(t, err, "gitobj: cannot move \"sub\" into itself")
+
+	_, err = db.MoveEntry(root, "sub", "a.txt/sub")
+	assert.EqualError(t, err, "gitobj: \"a.txt\" is not a tree")
+}
@@ -165,9 +165,10 @@ func TestMergeInsertElementsInSubtreeOrder(t *testing.T) {
 }
 
 type TreeEntryTypeTestCase struct {
-	Filemode int32
-	Expected ObjectType
-	IsLink bool
+	Filemode    int32
+	Expected    ObjectType
+	IsLink      bool
+	IsSubmodule bool
 }
 
 func (c *TreeEntryTypeTestCase) AssertType(t *testing.T) {
@@ -188,15 +189,35 @@ func (c *TreeEntryTypeTestCase) AssertIsLink(t *testing.T) {
 		"gitobj: expected link: %v, got: %v, for type %s", c.IsLink, isLink, c.Expected)
 }
 
+func (c *TreeEntryTypeTestCase) AssertIsSymlink(t *testing.T) {
+	e := &TreeEntry{Filemode: c.Filemode}
+
+	isSymlink := e.IsSymlink()
+
+	assert.Equal(t, c.IsLink, isSymlink,
+		"gitobj: expected symlink: %v, got: %v, for type %s", c.IsLink, isSymlink, c.Expected)
+}
+
+func (c *TreeEntryTypeTestCase) AssertIsSubmodule(t *testing.T) {
+	e := &TreeEntry{Filemode: c.Filemode}
+
+	isSubmodule := e.IsSubmodule()
+
+	assert.Equal(t, c.IsSubmodule, isSubmodule,
+		"gitobj: expected submodule: %v, got: %v, for type %s", c.IsSubmodule, isSubmodule, c.Expected)
+}
+
 func TestTreeEntryTypeResolution(t *testing.T) {
 	for desc, c := range map[string]*TreeEntryTypeTestCase{
-		"blob":    {0100644, BlobObjectType, false},
-		"subtree": {040000, TreeObjectType, false},
-		"symlink": {0120000, BlobObjectType, true},
-		"commit":  {0160000, CommitObjectType, false},
+		"blob":    {0100644, BlobObjectType, false, false},
+		"subtree": {040000, TreeObjectType, false, false},
+		"symlink": {0120000, BlobObjectType, true, false},
+		"commit":  {0160000, CommitObjectType, false, true},
 	} {
 		t.Run(desc, c.AssertType)
 		t.Run(desc, c.AssertIsLink)
+		t.Run(desc, c.AssertIsSymlink)
+		t.Run(desc, c.AssertIsSubmodule)
 	}
 }
 
@@ -0,0 +1,205 @@
+package gitobj
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// SkipSubtree is used as a return value from a TreeWalkFunc to indicate
+	// that the subtree named in the call is to be skipped. It is not
+	// returned as an error by any function.
+	SkipSubtree = errors.New("gitobj: skip this subtree")
+
+	// StopWalk is used as a return value from a TreeWalkFunc or a
+	// CommitWalkFunc to indicate that nothing further should be visited. It
+	// is not returned as an error by any function.
+	StopWalk = errors.New("gitobj: stop this walk")
+)
+
+const (
+	// DefaultMaxTreeDepth is the maximum depth to which WalkTree descends
+	// unless the MaxTreeDepth option is given. It matches the default value
+	// of Git's "core.maxTreeDepth".
+	DefaultMaxTreeDepth = 4096
+)
+
+// TreeWalkFunc is the type of the function called by WalkTree for each entry
+// visited. The "path" argument is the full path of the entry, relative to the
+// root of the walk, with components separated by "/".
+//
+// If the function returns SkipSubtree when invoked on an entry which is a
+// subtree, WalkTree will not descend into that subtree. If it returns
+// StopWalk, WalkTree stops visiting entries and returns nil. Any other non-nil
+// error stops the walk, and is returned by WalkTree.
+type TreeWalkFunc func(path string, entry *TreeEntry) error
+
+// TreeWalkBytesFunc is the type of the function called by WalkTreeBytes for
+// each entry visited. It is as TreeWalkFunc, except that "path" is held in a
+// buffer which is reused for every entry, and so is only valid until the
+// function returns. It must not be modified, and must be copied if it is to be
+// retained.
+type TreeWalkBytesFunc func(path []byte, entry *TreeEntry) error
+
+// SubmoduleResolver is a function which, given the full path and commit of a
+// submodule (gitlink) entry encountered during a tree walk, returns the
+// *ObjectDatabase holding that submodule's objects.
+//
+// If it returns a nil *ObjectDatabase and a nil error, the submodule is not
+// descended into. If it returns an error, the walk stops and returns it.
+type SubmoduleResolver func(path string, commit []byte) (*ObjectDatabase, error)
+
+// TreeWalkOption is an option which configures the behavior of WalkTree.
+type TreeWalkOption func(*treeWalker)
+
+// WalkSubmodules is a TreeWalkOption which resolves each submodule (gitlink)
+// entry visited by WalkTree using "resolve", and then walks the root tree of
+// the submodule's commit in the *ObjectDatabase it returns, as if it were a
+// subtree.
+//
+// By default, submodule entries are visited, but are never descended into,
+// since the commits they name are not expected to be stored in the same
+// object database.
+func WalkSubmodules(resolve SubmoduleResolver) TreeWalkOption {
+	return func(w *treeWalker) {
+		w.resolve = resolve
+	}
+}
+
+// MaxTreeDepth is a TreeWalkOption which limits the depth to which WalkTree
+// descends to "depth" subtrees below the root, returning an error if a deeper
+// subtree would be descended into, rather than DefaultMaxTreeDepth.
+func MaxTreeDepth(depth int) TreeWalkOption {
+	return func(w *treeWalker) {
+		w.maxDepth = depth
+	}
+}
+
+// treeWalker holds the state of a single call to WalkTree.
+type treeWalker struct {
+	// fn is the function called for each entry visited.
+	fn TreeWalkBytesFunc
+	// resolve, if non-nil, resolves submodules to be descended into.
+	resolve SubmoduleResolver
+	// maxDepth is the maximum depth of subtrees descended into.
+	maxDepth int
+
+	// ancestors holds the name of each tree currently being walked, from
+	// the root to the most deeply nested, so that a tree which (directly
+	// or indirectly) contains itself can be detected.
+	ancestors map[string]struct{}
+	// path holds the path of the entry being visited, and is extended
+	// with the name of each entry (and a trailing "/") as subtrees are
+	// descended into, and truncated as they are left.
+	path []byte
+}
+
+// WalkTree walks the tree named by "sha" in depth-first order, calling "fn"
+// for each entry, including subtrees. A subtree is visited before any of the
+// entries it contains, and entries are visited in the order in which they
+// appear in their tree.
+//
+// Subtrees are only loaded from the object database when they are about to
+// be descended into, so subtrees skipped by "fn" are never read. Submodule
+// (gitlink) entries are visited, but are not loaded unless the WalkSubmodules
+// option is given.
+//
+// Since a damaged (or malicious) object database may hold trees which contain
+// themselves, or which are nested arbitrarily deeply, WalkTree returns an error
+// rather than descending into a tree which contains itself, or into subtrees
+// nested more deeply than DefaultMaxTreeDepth (see: MaxTreeDepth).
+func (o *ObjectDatabase) WalkTree(sha []byte, fn TreeWalkFunc, setters ...TreeWalkOption) error {
+	return o.WalkTreeBytes(sha, func(path []byte, entry *TreeEntry) error {
+		return fn(string(path), entry)
+	}, setters...)
+}
+
+// WalkTreeBytes is as WalkTree, but passes the path of each entry to "fn" in a
+// reused buffer (see: TreeWalkBytesFunc), rather than allocating a new string
+// for each entry. This saves a great deal of garbage when walking every tree in
+// a large repository, particularly when "fn" only inspects most paths (for
+// instance, matching them against a pattern) without retaining them.
+func (o *ObjectDatabase) WalkTreeBytes(sha []byte, fn TreeWalkBytesFunc, setters ...TreeWalkOption) error {
+	w := &treeWalker{
+		fn:        fn,
+		maxDepth:  DefaultMaxTreeDepth,
+		ancestors: make(map[string]struct{}),
+	}
+	for _, setter := range setters {
+		setter(w)
+	}
+
+	err := w.walk(o, sha, 0)
+	if err == StopWalk {
+		return nil
+	}
+	return err
+}
+
+// walk visits each entry in the tree named by "sha" in the given database,
+// recursively, as described above, prefixing each path with the contents of
+// "w.path". The tree is nested "depth" subtrees below the root.
+func (w *treeWalker) walk(db *ObjectDatabase, sha []byte, depth int) error {
+	if depth > w.maxDepth {
+		return fmt.Errorf("gitobj: tree %x at %q exceeds maximum depth %d", sha, w.path, w.maxDepth)
+	}
+	if _, ok := w.ancestors[string(sha)]; ok {
+		return fmt.Errorf("gitobj: tree %x at %q contains itself", sha, w.path)
+	}
+	w.ancestors[string(sha)] = struct{}{}
+	defer delete(w.ancestors, string(sha))
+
+	tree, err := db.Tree(sha)
+	if err != nil {
+		return err
+	}
+
+	prefix := len(w.path)
+	defer func() { w.path = w.path[:prefix] }()
+
+	for _, entry := range tree.Entries {
+		w.path = append(w.path[:prefix], entry.Name...)
+
+		if err := w.fn(w.path, entry); err != nil {
+			if err == SkipSubtree {
+				continue
+			}
+			return err
+		}
+
+		switch entry.Filemode & sIFMT {
+		case sIFDIR:
+			w.path = append(w.path, '/')
+			if err := w.walk(db, entry.Oid, depth+1); err != nil {
+				return err
+			}
+		case sIFGITLINK:
+			if err := w.walkSubmodule(entry.Oid, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walkSubmodule walks the root tree of the given submodule commit, whose path
+// is held by "w.path", if the submodule can be resolved, as a subtree at the
+// given depth.
+func (w *treeWalker) walkSubmodule(commit []byte, depth int) error {
+	if w.resolve == nil {
+		return nil
+	}
+
+	db, err := w.resolve(string(w.path), commit)
+	if err != nil || db == nil {
+		return err
+	}
+
+	c, err := db.Commit(commit)
+	if err != nil {
+		return err
+	}
+
+	w.path = append(w.path, '/')
+	return w.walk(db, c.TreeID, depth)
+}
@@ -0,0 +1,303 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestTree writes the following hierarchy to the given *ObjectDatabase,
+// returning the root tree's object ID:
+//
+//	a.txt
+//	sub/
+//	sub/b.txt
+//	sub/deeper/
+//	sub/deeper/c.txt
+//	z.txt
+func writeTestTree(t *testing.T, db *ObjectDatabase) []byte {
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	deeper, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "c.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	sub, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "b.txt", Oid: blob, Filemode: 0100644},
+		{Name: "deeper", Oid: deeper, Filemode: 040000},
+	}})
+	require.NoError(t, err)
+
+	root, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: blob, Filemode: 0100644},
+		{Name: "sub", Oid: sub, Filemode: 040000},
+		{Name: "z.txt", Oid: blob, Filemode: 0100644},
+	}})
+	require.NoError(t, err)
+
+	return root
+}
+
+func TestWalkTreeVisitsAllEntries(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	var paths []string
+	err := db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"a.txt", "sub", "sub/b.txt", "sub/deeper", "sub/deeper/c.txt",
+		"z.txt",
+	}, paths)
+}
+
+func TestWalkTreeBytesVisitsAllEntries(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	var paths []string
+	err := db.WalkTreeBytes(root, func(path []byte, entry *TreeEntry) error {
+		paths = append(paths, string(path))
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"a.txt", "sub", "sub/b.txt", "sub/deeper", "sub/deeper/c.txt",
+		"z.txt",
+	}, paths)
+}
+
+func TestWalkTreeBytesAllocatesLessThanWalkTree(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not reliable under the race detector")
+	}
+
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	withStrings := testing.AllocsPerRun(10, func() {
+		db.WalkTree(root, func(path string, entry *TreeEntry) error {
+			return nil
+		})
+	})
+	withBytes := testing.AllocsPerRun(10, func() {
+		db.WalkTreeBytes(root, func(path []byte, entry *TreeEntry) error {
+			return nil
+		})
+	})
+
+	assert.True(t, withBytes < withStrings, "%v allocation(s), expected fewer than %v", withBytes, withStrings)
+}
+
+func TestWalkTreeSkipsSubtrees(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	var paths []string
+	err := db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		if path == "sub/deeper" {
+			return SkipSubtree
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"a.txt", "sub", "sub/b.txt", "sub/deeper", "z.txt",
+	}, paths)
+}
+
+func TestWalkTreeStopsEarly(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	var paths []string
+	err := db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		if path == "sub/b.txt" {
+			return StopWalk
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "sub", "sub/b.txt"}, paths)
+}
+
+func TestWalkTreePropagatesErrors(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	expected := errors.New("gitobj: testing")
+	err := db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		return expected
+	})
+
+	assert.Equal(t, expected, err)
+}
+
+func TestWalkTreeDoesNotLoadSubmodules(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	missing := make([]byte, 20)
+	missing[0] = 0xff
+
+	root, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: writeTestBlob(t, db), Filemode: 0100644},
+		{Name: "vendor", Oid: missing, Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	var paths []string
+	err = db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "vendor"}, paths)
+}
+
+func TestWalkTreeResolvesSubmodules(t *testing.T) {
+	sdb := newTestMemoryDatabase(t)
+	commit, err := sdb.WriteCommit(&Commit{
+		Author:    "Jane Doe <jane@example.com> 1257894000 -0700",
+		Committer: "Jane Doe <jane@example.com> 1257894000 -0700",
+		TreeID:    writeTestTree(t, sdb),
+		Message:   "Initial commit\n",
+	})
+	require.NoError(t, err)
+
+	db := newTestMemoryDatabase(t)
+	root, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: writeTestBlob(t, db), Filemode: 0100644},
+		{Name: "vendor", Oid: commit, Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	var resolved []string
+	var paths []string
+	err = db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		paths = append(paths, path)
+		if path == "vendor/sub" {
+			return SkipSubtree
+		}
+		return nil
+	}, WalkSubmodules(func(path string, sha []byte) (*ObjectDatabase, error) {
+		assert.Equal(t, commit, sha)
+		resolved = append(resolved, path)
+		return sdb, nil
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vendor"}, resolved)
+	assert.Equal(t, []string{
+		"a.txt", "vendor", "vendor/a.txt", "vendor/sub", "vendor/z.txt",
+	}, paths)
+}
+
+func TestWalkTreeSkipsUnresolvedSubmodules(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	missing := make([]byte, 20)
+	missing[0] = 0xff
+
+	root, err := db.WriteTree(&Tree{Entries: []*TreeEntry{
+		{Name: "skipped", Oid: missing, Filemode: 0160000},
+		{Name: "vendor", Oid: missing, Filemode: 0160000},
+	}})
+	require.NoError(t, err)
+
+	var resolved []string
+	err = db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		if path == "skipped" {
+			return SkipSubtree
+		}
+		return nil
+	}, WalkSubmodules(func(path string, sha []byte) (*ObjectDatabase, error) {
+		resolved = append(resolved, path)
+		return nil, nil
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vendor"}, resolved)
+}
+
+func TestWalkTreeDetectsCycles(t *testing.T) {
+	// No valid tree can contain itself, so write one by naming it
+	// incorrectly.
+	sha := bytes.Repeat([]byte{0xaa}, 20)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	fmt.Fprintf(zw, "tree %d\x00040000 loop\x00%s", len("040000 loop\x00")+len(sha), sha)
+	zw.Close()
+
+	b, err := NewMemoryBackend(map[string]io.ReadWriter{
+		hex.EncodeToString(sha): &buf,
+	})
+	require.NoError(t, err)
+
+	db, err := FromBackend(b)
+	require.NoError(t, err)
+
+	var visited []string
+	err = db.WalkTree(sha, func(path string, entry *TreeEntry) error {
+		visited = append(visited, path)
+		return nil
+	})
+
+	assert.EqualError(t, err, fmt.Sprintf("gitobj: tree %x at \"loop/\" contains itself", sha))
+	assert.Equal(t, []string{"loop"}, visited)
+}
+
+func TestWalkTreeLimitsDepth(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+	root := writeTestTree(t, db)
+
+	var visited []string
+	err := db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		visited = append(visited, path)
+		return nil
+	}, MaxTreeDepth(1))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `at "sub/deeper/" exceeds maximum depth 1`)
+	assert.Equal(t, []string{"a.txt", "sub", "sub/b.txt", "sub/deeper"}, visited)
+
+	assert.NoError(t, db.WalkTree(root, func(path string, entry *TreeEntry) error {
+		return nil
+	}, MaxTreeDepth(2)))
+}
+
+func writeTestBlob(t *testing.T, db *ObjectDatabase) []byte {
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	return blob
+}
+
+func newTestMemoryDatabase(t *testing.T) *ObjectDatabase {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+
+	db, err := FromBackend(b)
+	require.NoError(t, err)
+
+	return db
+}
@@ -0,0 +1,428 @@
+package gitobj
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// VerifySeverity is the severity of a problem found by Verify, as with the
+// severities of the messages reported by "git fsck".
+type VerifySeverity int
+
+const (
+	// VerifyError is the severity of a problem which Git rejects, for
+	// instance when receiving a push with "receive.fsckObjects" set.
+	VerifyError VerifySeverity = iota
+	// VerifyWarning is the severity of a problem which Git rejects by
+	// default when receiving objects, but which is known to occur in
+	// existing repositories.
+	VerifyWarning
+	// VerifyInfo is the severity of a problem which Git reports, but
+	// otherwise tolerates.
+	VerifyInfo
+)
+
+// String returns the name of the severity, as it is given by "git fsck".
+func (s VerifySeverity) String() string {
+	switch s {
+	case VerifyError:
+		return "error"
+	case VerifyWarning:
+		return "warning"
+	case VerifyInfo:
+		return "info"
+	}
+	return "<unknown>"
+}
+
+// VerifyFinding is a problem found with an object by Verify.
+type VerifyFinding struct {
+	// Oid is the name of the object.
+	Oid []byte
+	// Type is the type of the object.
+	Type ObjectType
+	// ID identifies the kind of problem, using the message IDs of "git
+	// fsck" (such as "treeNotSorted") where there is one.
+	ID string
+	// Severity is the severity of the problem.
+	Severity VerifySeverity
+	// Message describes the problem.
+	Message string
+}
+
+// String returns a description of the finding, in the same form as the
+// messages reported by "git fsck".
+func (f *VerifyFinding) String() string {
+	return fmt.Sprintf("%s in %s %x: %s: %s", f.Severity, f.Type, f.Oid, f.ID, f.Message)
+}
+
+// Verify checks the object named by "sha" as "git fsck" does, and returns the
+// problems which were found with it, if any. This is useful for validating
+// objects which were constructed by hand (and written with WriteTree,
+// WriteCommit, and so on) before they are pushed to a server which may check
+// them more strictly.
+//
+// The object's contents are checked against its name, and against the size
+// given by its header. Beyond that:
+//
+//   - the entries of a tree must be well-formed, have valid modes and names,
+//     and be sorted, without duplicates;
+//   - the headers of a commit must include its tree, parents, author, and
+//     committer, in that order, and its identities must be valid;
+//   - the headers of a tag must include its object, the object's type, and
+//     its name, and its tagger (if any) must be valid.
+//
+// The object itself is checked, even if it has been replaced (see:
+// ReplaceObjects). The objects to which it refers are not checked, nor is
+// their presence (see: CheckConnectivity). An error is returned only if the
+// object could not be read.
+func (o *ObjectDatabase) Verify(sha []byte) ([]*VerifyFinding, error) {
+	r, err := o.openExact(context.Background(), sha)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	typ, size, err := r.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &verifier{oid: sha, typ: typ, hashlen: o.Hasher().Size()}
+
+	h := o.Hasher()
+	fmt.Fprintf(h, "%s %d\x00", typ, size)
+
+	// Blobs are not otherwise inspected, so need not be held in memory.
+	var buf bytes.Buffer
+	w := io.Writer(h)
+	if typ != BlobObjectType {
+		w = io.MultiWriter(h, &buf)
+	}
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if n != size {
+		v.add("sizeMismatch", VerifyError,
+			"object has %d byte(s), but its header gives %d", n, size)
+	}
+	if sum := h.Sum(nil); !bytes.Equal(sum, sha) {
+		v.add("hashMismatch", VerifyError, "object hashes to %x", sum)
+	}
+
+	switch typ {
+	case BlobObjectType:
+	case TreeObjectType:
+		v.tree(buf.Bytes())
+	case CommitObjectType:
+		v.commit(buf.Bytes())
+	case TagObjectType:
+		v.tag(buf.Bytes())
+	default:
+		v.add("badType", VerifyError, "unknown object type")
+	}
+	return v.findings, nil
+}
+
+// VerifyAll checks each object in the database (see: ForEachObject) as Verify
+// does, and returns the problems which were found with them, as with "git fsck"
+// run over the whole database.
+//
+// Objects which cannot be read do not stop the check: once every other object
+// has been checked, an *ObjectErrors is returned (along with the problems found
+// with the others) describing each one, and the number of objects which were
+// checked. Any other error is returned as soon as it is encountered.
+func (o *ObjectDatabase) VerifyAll() ([]*VerifyFinding, error) {
+	var findings []*VerifyFinding
+	errs := new(ObjectErrors)
+
+	failed := func(sha []byte, err error) error {
+		var source string
+		if stats, serr := o.storedSource(sha); serr == nil {
+			source = stats.Source
+		}
+		errs.add(sha, source, err)
+		return nil
+	}
+
+	err := o.forEachObject(func(sha []byte, typ ObjectType, size int64) error {
+		f, err := o.Verify(sha)
+		if err != nil {
+			return failed(sha, err)
+		}
+		findings = append(findings, f...)
+		errs.Succeeded++
+		return nil
+	}, failed)
+	if err != nil {
+		return findings, err
+	}
+	return findings, errs.err()
+}
+
+// verifier accumulates the problems found with a single object by Verify.
+type verifier struct {
+	oid     []byte
+	typ     ObjectType
+	hashlen int
+
+	findings []*VerifyFinding
+}
+
+// add records a problem of the given kind and severity, described by the given
+// format string and arguments.
+func (v *verifier) add(id string, severity VerifySeverity, format string, args ...interface{}) {
+	v.findings = append(v.findings, &VerifyFinding{
+		Oid:      v.oid,
+		Type:     v.typ,
+		ID:       id,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// tree checks the entries of a tree, whose contents are "data".
+func (v *verifier) tree(data []byte) {
+	var prev string
+	seen := make(map[string]struct{})
+
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp <= 0 || nul < sp || len(data) < nul+1+v.hashlen {
+			v.add("badTree", VerifyError, "cannot be parsed as a tree")
+			return
+		}
+
+		modes := string(data[:sp])
+		name := string(data[sp+1 : nul])
+		oid := data[nul+1 : nul+1+v.hashlen]
+		data = data[nul+1+v.hashlen:]
+
+		mode, err := strconv.ParseInt(modes, 8, 32)
+		if err != nil {
+			v.add("badTree", VerifyError, "cannot be parsed as a tree")
+			return
+		}
+		if modes[0] == '0' {
+			v.add("zeroPaddedFilemode", VerifyWarning, "contains zero-padded file modes")
+		}
+		switch int32(mode) {
+		case sIFREG | 0644, sIFREG | 0755, sIFLNK, sIFDIR, sIFGITLINK:
+		default:
+			v.add("badFilemode", VerifyInfo, "contains bad file modes")
+		}
+
+		switch {
+		case len(name) == 0:
+			v.add("emptyName", VerifyWarning, "contains empty pathname")
+		case strings.IndexByte(name, '/') >= 0:
+			v.add("fullPathname", VerifyWarning, "contains full pathnames")
+		case name == ".":
+			v.add("hasDot", VerifyWarning, "contains '.'")
+		case name == "..":
+			v.add("hasDotdot", VerifyWarning, "contains '..'")
+		case strings.EqualFold(name, ".git"):
+			v.add("hasDotgit", VerifyWarning, "contains '.git'")
+		}
+
+		if bytes.Count(oid, []byte{0}) == len(oid) {
+			v.add("nullSha1", VerifyWarning, "contains entries pointing to null sha1")
+		}
+
+		// Entries are ordered as by SubtreeOrder, and a name may
+		// not be used twice, even by a file and a subtree.
+		key := name + "\x00"
+		if int32(mode)&sIFMT == sIFDIR {
+			key = name + "/"
+		}
+		if _, ok := seen[name]; ok {
+			v.add("duplicateEntries", VerifyError, "contains duplicate file entries")
+		} else if key < prev {
+			v.add("treeNotSorted", VerifyError, "not properly sorted")
+		}
+		seen[name] = struct{}{}
+		prev = key
+	}
+}
+
+// commit checks the headers of a commit, whose contents are "data".
+func (v *verifier) commit(data []byte) {
+	headers, ok := v.headers(data)
+	if !ok {
+		return
+	}
+
+	if len(headers) == 0 || headers[0].K != "tree" {
+		v.add("missingTree", VerifyError, "invalid format - expected 'tree' line")
+		return
+	}
+	if !v.validOid(headers[0].V) {
+		v.add("badTreeSha1", VerifyError, "invalid 'tree' line format - bad sha1")
+	}
+	headers = headers[1:]
+
+	for len(headers) > 0 && headers[0].K == "parent" {
+		if !v.validOid(headers[0].V) {
+			v.add("badParentSha1", VerifyError, "invalid 'parent' line format - bad sha1")
+		}
+		headers = headers[1:]
+	}
+
+	if len(headers) == 0 || headers[0].K != "author" {
+		v.add("missingAuthor", VerifyError, "invalid format - expected 'author' line")
+		return
+	}
+	v.ident(headers[0].K, headers[0].V)
+	headers = headers[1:]
+
+	if len(headers) > 0 && headers[0].K == "author" {
+		v.add("multipleAuthors", VerifyError, "invalid format - multiple 'author' lines")
+		for len(headers) > 0 && headers[0].K == "author" {
+			headers = headers[1:]
+		}
+	}
+
+	if len(headers) == 0 || headers[0].K != "committer" {
+		v.add("missingCommitter", VerifyError, "invalid format - expected 'committer' line")
+		return
+	}
+	v.ident(headers[0].K, headers[0].V)
+}
+
+// tag checks the headers of a tag, whose contents are "data".
+func (v *verifier) tag(data []byte) {
+	headers, ok := v.headers(data)
+	if !ok {
+		return
+	}
+
+	if len(headers) == 0 || headers[0].K != "object" {
+		v.add("missingObject", VerifyError, "invalid format - expected 'object' line")
+		return
+	}
+	if !v.validOid(headers[0].V) {
+		v.add("badObjectSha1", VerifyError, "invalid 'object' line format - bad sha1")
+	}
+	headers = headers[1:]
+
+	if len(headers) == 0 || headers[0].K != "type" {
+		v.add("missingTypeEntry", VerifyError, "invalid format - expected 'type' line")
+		return
+	}
+	switch ObjectTypeFromString(headers[0].V) {
+	case BlobObjectType, TreeObjectType, CommitObjectType, TagObjectType:
+	default:
+		v.add("badType", VerifyError, "invalid 'type' value")
+	}
+	headers = headers[1:]
+
+	if len(headers) == 0 || headers[0].K != "tag" {
+		v.add("missingTagEntry", VerifyError, "invalid format - expected 'tag' line")
+		return
+	}
+	if !validTagName(headers[0].V) {
+		v.add("badTagName", VerifyInfo, "invalid 'tag' name: %s", headers[0].V)
+	}
+	headers = headers[1:]
+
+	if len(headers) == 0 || headers[0].K != "tagger" {
+		v.add("missingTaggerEntry", VerifyInfo, "invalid format - expected 'tagger' line")
+		return
+	}
+	v.ident(headers[0].K, headers[0].V)
+}
+
+// headers returns the headers of a commit or tag, whose contents are "data", or
+// false if they could not be parsed. Continuation lines (as in signatures) are
+// not returned.
+func (v *verifier) headers(data []byte) ([]*ExtraHeader, bool) {
+	end := bytes.Index(data, []byte("\n\n"))
+	if end < 0 {
+		if len(data) == 0 || data[len(data)-1] != '\n' {
+			v.add("unterminatedHeader", VerifyError, "unterminated header")
+			return nil, false
+		}
+		end = len(data) - 1
+	}
+	if i := bytes.IndexByte(data[:end], 0); i >= 0 {
+		v.add("nulInHeader", VerifyError, "unterminated header: NUL at offset %d", i)
+		return nil, false
+	}
+
+	var headers []*ExtraHeader
+	for _, line := range strings.Split(string(data[:end]), "\n") {
+		if strings.HasPrefix(line, " ") {
+			continue
+		}
+		k, val := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			k, val = line[:i], line[i+1:]
+		}
+		headers = append(headers, &ExtraHeader{K: k, V: val})
+	}
+	return headers, true
+}
+
+// validOid returns whether "s" is a hex-encoded object ID of the expected
+// length, in lowercase, as Git writes them.
+func (v *verifier) validOid(s string) bool {
+	if len(s) != 2*v.hashlen || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// ident checks the identity "ident", held by the named header.
+func (v *verifier) ident(header, ident string) {
+	_, err := validateIdent(header, ident, "")
+	if err == nil {
+		return
+	}
+
+	reason := err.(*InvalidSignature).Reason
+	switch {
+	case strings.Contains(reason, "email"):
+		v.add("badEmail", VerifyError, "invalid %s line - %s", header, reason)
+	case strings.Contains(reason, "timezone"):
+		v.add("badTimezone", VerifyError, "invalid %s line - %s", header, reason)
+	case strings.Contains(reason, "overflows"):
+		v.add("badDateOverflow", VerifyError, "invalid %s line - %s", header, reason)
+	default:
+		v.add("badDate", VerifyError, "invalid %s line - %s", header, reason)
+	}
+}
+
+// validTagName returns whether "name" is valid as the name of a reference in
+// "refs/tags", following the rules of git-check-ref-format(1).
+func validTagName(name string) bool {
+	if len(name) == 0 || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") {
+		return false
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, "@{") || strings.Contains(name, "//") {
+		return false
+	}
+	if strings.ContainsAny(name, " ~^:?*[\\\x7f") {
+		return false
+	}
+	for _, c := range name {
+		if c < 0x20 {
+			return false
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,319 @@
+package gitobj
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAcceptsWellFormedObjects(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	tree := writeTestTree(t, db)
+	commit := writeTestCommit(t, db, map[string]string{"a.txt": "a\n"}, 1)
+	child := writeTestCommit(t, db, map[string]string{"a.txt": "b\n"}, 2, commit)
+	tag, err := db.WriteTag(&Tag{
+		Object:     child,
+		ObjectType: CommitObjectType,
+		Name:       "v1.0.0",
+		Tagger:     "Jane Doe <jane@example.com> 1234567890 +0000",
+		Message:    "v1.0.0\n",
+	})
+	require.NoError(t, err)
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	for _, sha := range [][]byte{tree, commit, child, tag, blob} {
+		findings, err := db.Verify(sha)
+		require.NoError(t, err)
+		assert.Empty(t, findings, "%x", sha)
+	}
+}
+
+func TestVerifyReportsFindings(t *testing.T) {
+	oid := hex.EncodeToString(bytes.Repeat([]byte{0xaa}, 20))
+	raw := string(bytes.Repeat([]byte{0xaa}, 20))
+	null := string(make([]byte, 20))
+
+	ident := "Jane Doe <jane@example.com> 1234567890 +0000"
+
+	for _, test := range []struct {
+		typ  string
+		data string
+		ids  []string
+	}{
+		{"tree", "100644 b\x00" + raw + "100644 a\x00" + raw, []string{"treeNotSorted"}},
+		{"tree", "100644 a\x00" + raw + "40000 a\x00" + raw, []string{"duplicateEntries"}},
+		{"tree", "0100644 a\x00" + raw, []string{"zeroPaddedFilemode"}},
+		{"tree", "100664 a\x00" + raw, []string{"badFilemode"}},
+		{"tree", "100644 a/b\x00" + raw, []string{"fullPathname"}},
+		{"tree", "40000 .GIT\x00" + raw, []string{"hasDotgit"}},
+		{"tree", "100644 a\x00" + null, []string{"nullSha1"}},
+		{"tree", "100644 a\x00" + raw[:10], []string{"badTree"}},
+		{
+			"commit",
+			"author " + ident + "\ncommitter " + ident + "\n\nmessage\n",
+			[]string{"missingTree"},
+		},
+		{
+			"commit",
+			"tree " + oid + "\nparent xyz\nauthor " + ident + "\ncommitter " + ident + "\n\nmessage\n",
+			[]string{"badParentSha1"},
+		},
+		{
+			"commit",
+			"tree " + oid + "\nauthor Jane Doe 1234567890 +0000\ncommitter " + ident + "\n\nmessage\n",
+			[]string{"badEmail"},
+		},
+		{
+			"commit",
+			"tree " + oid + "\nauthor " + ident + "\ncommitter Jane Doe <jane@example.com> 1234567890 +2500\n\nmessage\n",
+			[]string{"badTimezone"},
+		},
+		{
+			"commit",
+			"tree " + oid + "\nauthor " + ident + "\n\nmessage\n",
+			[]string{"missingCommitter"},
+		},
+		{
+			"commit",
+			"tree " + oid + "\nauthor " + ident + "\ncommitter " + ident,
+			[]string{"unterminatedHeader"},
+		},
+		{
+			"tag",
+			"object " + oid + "\ntype commit\ntag v1.0.0\n\nmessage\n",
+			[]string{"missingTaggerEntry"},
+		},
+		{
+			"tag",
+			"object " + oid + "\ntype widget\ntag v1.0.0\ntagger " + ident + "\n\nmessage\n",
+			[]string{"badType"},
+		},
+		{
+			"tag",
+			"object " + oid + "\ntype commit\ntag v1..0\ntagger " + ident + "\n\nmessage\n",
+			[]string{"badTagName"},
+		},
+		{
+			"tag",
+			"object " + oid + "\ntype commit\ntagger " + ident + "\n\nmessage\n",
+			[]string{"missingTagEntry"},
+		},
+	} {
+		db, sha := newTestRawObjectDatabase(t, test.typ, len(test.data), test.data)
+
+		findings, err := db.Verify(sha)
+		require.NoError(t, err)
+
+		var ids []string
+		for _, f := range findings {
+			ids = append(ids, f.ID)
+			assert.Equal(t, sha, f.Oid)
+			assert.Equal(t, ObjectTypeFromString(test.typ), f.Type)
+		}
+		assert.Equal(t, test.ids, ids, "%s %q", test.typ, test.data)
+	}
+}
+
+func TestVerifyReportsSizeAndHashMismatches(t *testing.T) {
+	db, sha := newTestRawObjectDatabase(t, "blob", 5, "Hello, world!\n")
+	sha[0] ^= 0xff
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := fmt.Fprintf(zw, "blob 5\x00Hello, world!\n")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	db.rw.(*memoryStorer).fs[hex.EncodeToString(sha)] = &bufCloser{&buf}
+
+	findings, err := db.Verify(sha)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+
+	assert.Equal(t, "sizeMismatch", findings[0].ID)
+	assert.Equal(t, VerifyError, findings[0].Severity)
+	assert.Equal(t, "hashMismatch", findings[1].ID)
+	assert.Equal(t, fmt.Sprintf("error in blob %x: sizeMismatch: object has 14 byte(s), but its header gives 5", sha),
+		findings[0].String())
+}
+
+func TestVerifyAllContinuesPastUnreadableObjects(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitobj-objects")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	db, err := FromFilesystem(root, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	bad := bytes.Repeat([]byte{0xab}, 20)
+	path := filepath.Join(root, "ab", hex.EncodeToString(bad[1:]))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte("not zlib"), 0644))
+
+	findings, err := db.VerifyAll()
+	assert.Empty(t, findings)
+
+	errs, ok := err.(*ObjectErrors)
+	require.True(t, ok, "expected *ObjectErrors, got %T", err)
+	assert.Equal(t, 1, errs.Succeeded)
+	require.Len(t, errs.Errors, 1)
+	assert.Equal(t, bad, errs.Errors[0].Oid)
+	assert.Equal(t, path, errs.Errors[0].Source)
+}
+
+// newTestRawObjectDatabase returns a database holding only an object of the
+// given type and contents, whose header gives the given size, along with the
+// name of that object.
+func newTestRawObjectDatabase(t *testing.T, typ string, size int, data string) (*ObjectDatabase, []byte) {
+	contents := fmt.Sprintf("%s %d\x00%s", typ, size, data)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := io.WriteString(zw, contents)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	sum := sha1.Sum([]byte(contents))
+	b, err := NewMemoryBackend(map[string]io.ReadWriter{
+		hex.EncodeToString(sum[:]): &buf,
+	})
+	require.NoError(t, err)
+
+	db, err := FromBackend(b)
+	require.NoError(t, err)
+	return db, sum[:]
+}
+
+func TestStrictWritesRejectsInvalidTrees(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	db, err := FromBackend(b, StrictWrites())
+	require.NoError(t, err)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		entries []*TreeEntry
+		err     string
+	}{
+		{
+			[]*TreeEntry{
+				{Name: "b.txt", Oid: blob, Filemode: 0100644},
+				{Name: "a.txt", Oid: blob, Filemode: 0100644},
+			},
+			"gitobj: invalid tree: treeNotSorted: not properly sorted",
+		},
+		{
+			[]*TreeEntry{
+				{Name: "a.txt", Oid: blob, Filemode: 0100644},
+				{Name: "a.txt", Oid: blob, Filemode: 0100644},
+			},
+			"gitobj: invalid tree: duplicateEntries: contains duplicate file entries",
+		},
+		{
+			[]*TreeEntry{{Name: "..", Oid: blob, Filemode: 040000}},
+			"gitobj: invalid tree: hasDotdot: contains '..'",
+		},
+		{
+			[]*TreeEntry{{Name: "a.txt", Oid: make([]byte, 20), Filemode: 0100644}},
+			"gitobj: invalid tree: nullSha1: contains entries pointing to null sha1",
+		},
+		{
+			[]*TreeEntry{
+				{Name: "b", Oid: make([]byte, 20), Filemode: 0100644},
+				{Name: "a", Oid: blob, Filemode: 0100644},
+			},
+			"gitobj: invalid tree: nullSha1: contains entries pointing to null sha1 (and 1 more)",
+		},
+	} {
+		tree := &Tree{Entries: test.entries}
+
+		_, err := db.WriteTree(tree)
+		assert.EqualError(t, err, test.err)
+		assert.IsType(t, &InvalidObject{}, err)
+
+		_, err = db.HashTree(tree)
+		assert.EqualError(t, err, test.err)
+	}
+
+	assert.Len(t, b.(*memoryBackend).ms.fs, 1)
+}
+
+func TestStrictWritesRejectsInvalidCommits(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	db, err := FromBackend(b, StrictWrites())
+	require.NoError(t, err)
+
+	tree, err := db.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	_, err = db.WriteCommit(&Commit{
+		TreeID:    tree,
+		Author:    "Jane Doe <jane@example.com> 1234567890 +0000",
+		Committer: "Jane Doe 1234567890 +0000",
+		Message:   "message\n",
+	})
+	assert.EqualError(t, err, "gitobj: invalid commit: badEmail: invalid committer line - missing email")
+
+	// Since a tag is always written with a "tagger" header, one without a
+	// tagger has an empty identity.
+	_, err = db.WriteTag(&Tag{
+		Object:     tree,
+		ObjectType: TreeObjectType,
+		Name:       "v1.0.0",
+		Message:    "message\n",
+	})
+	assert.EqualError(t, err, "gitobj: invalid tag: badEmail: invalid tagger line - missing email")
+}
+
+func TestCanonicalTreesSortsEntries(t *testing.T) {
+	b, err := NewMemoryBackend(nil)
+	require.NoError(t, err)
+	db, err := FromBackend(b, StrictWrites(), CanonicalTrees())
+	require.NoError(t, err)
+
+	blob, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+
+	tree := &Tree{Entries: []*TreeEntry{
+		{Name: "a.txt", Oid: blob, Filemode: 0100644},
+		{Name: "a", Oid: blob, Filemode: 040000},
+		{Name: "a-b", Oid: blob, Filemode: 0100644},
+	}}
+
+	sha, err := db.WriteTree(tree)
+	require.NoError(t, err)
+
+	// The given tree is left as is.
+	assert.Equal(t, "a.txt", tree.Entries[0].Name)
+
+	got, err := db.Tree(sha)
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range got.Entries {
+		names = append(names, e.Name)
+	}
+	assert.Equal(t, []string{"a-b", "a.txt", "a"}, names)
+
+	findings, err := db.Verify(sha)
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
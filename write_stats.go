@@ -0,0 +1,57 @@
+package gitobj
+
+import (
+	"sync/atomic"
+)
+
+// WriteStat records the objects of a single type written to a database.
+type WriteStat struct {
+	// Type is the type of the objects.
+	Type ObjectType
+	// Objects is the number of objects written.
+	Objects uint64
+	// Size is the total size of the contents of the objects written,
+	// before compression.
+	Size uint64
+	// Stored is the total number of bytes passed to storage for the
+	// objects written, after compression. Objects which were already
+	// present may not have been stored again, and so may not be counted.
+	Stored uint64
+}
+
+// writeCounters counts the objects written to a database, and is shared between
+// it and its views. Its members are managed by sync/atomic.
+type writeCounters struct {
+	// counts holds the number of objects, their total size, and the
+	// number of bytes stored, for each ObjectType.
+	counts [TagObjectType + 1][3]uint64
+}
+
+// add records an object of the given type and size, of which "stored" bytes
+// were passed to storage.
+func (c *writeCounters) add(typ ObjectType, size, stored int64) {
+	if typ > TagObjectType {
+		typ = UnknownObjectType
+	}
+
+	atomic.AddUint64(&c.counts[typ][0], 1)
+	atomic.AddUint64(&c.counts[typ][1], uint64(size))
+	atomic.AddUint64(&c.counts[typ][2], uint64(stored))
+}
+
+// WriteStats returns the number and size of the objects of each type (blobs,
+// trees, commits, and tags, in that order) written since the database was
+// opened, so that tools may report their throughput without wrapping every
+// write. Objects written through every view of the database are included.
+func (o *ObjectDatabase) WriteStats() []*WriteStat {
+	stats := make([]*WriteStat, 0, TagObjectType)
+	for typ := BlobObjectType; typ <= TagObjectType; typ++ {
+		stats = append(stats, &WriteStat{
+			Type:    typ,
+			Objects: atomic.LoadUint64(&o.writes.counts[typ][0]),
+			Size:    atomic.LoadUint64(&o.writes.counts[typ][1]),
+			Stored:  atomic.LoadUint64(&o.writes.counts[typ][2]),
+		})
+	}
+	return stats
+}
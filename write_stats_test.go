@@ -0,0 +1,39 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteStatsCountObjectsWritten(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	_, err := db.WriteBlob(NewBlobFromBytes([]byte("Hello, world!\n")))
+	require.NoError(t, err)
+	_, err = db.View().WriteBlob(NewBlobFromBytes([]byte("Goodbye\n")))
+	require.NoError(t, err)
+	_, err = db.WriteTree(&Tree{})
+	require.NoError(t, err)
+
+	stats := db.WriteStats()
+	require.Len(t, stats, 4)
+
+	assert.Equal(t, BlobObjectType, stats[0].Type)
+	assert.EqualValues(t, 2, stats[0].Objects)
+	assert.EqualValues(t, 22, stats[0].Size)
+	assert.True(t, stats[0].Stored > 0)
+
+	assert.Equal(t, TreeObjectType, stats[1].Type)
+	assert.EqualValues(t, 1, stats[1].Objects)
+	assert.EqualValues(t, 0, stats[1].Size)
+
+	for _, stat := range stats[2:] {
+		assert.Equal(t, &WriteStat{Type: stat.Type}, stat)
+	}
+	assert.Equal(t, CommitObjectType, stats[2].Type)
+	assert.Equal(t, TagObjectType, stats[3].Type)
+
+	assert.Equal(t, stats, db.View().WriteStats())
+}
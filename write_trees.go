@@ -0,0 +1,61 @@
+package gitobj
+
+import (
+	"bytes"
+)
+
+// WriteTrees writes each of the given trees to the database, as WriteTree does,
+// and returns their object IDs, in the order in which the trees were given.
+//
+// Trees identical to one written earlier in the batch are recognized by their
+// encoding, and are neither hashed nor stored again, which saves much of the
+// work of rewrites producing many copies of the same subtree, such as those of
+// wide but shallow directory structures.
+func (o *ObjectDatabase) WriteTrees(trees []*Tree) ([][]byte, error) {
+	batch := o.newTreeBatch()
+
+	oids := make([][]byte, 0, len(trees))
+	for _, t := range trees {
+		oid, err := batch.write(t)
+		if err != nil {
+			return nil, err
+		}
+		oids = append(oids, oid)
+	}
+	return oids, nil
+}
+
+// treeBatch writes a batch of trees to a database, writing each distinct tree
+// only once.
+type treeBatch struct {
+	db *ObjectDatabase
+	// written maps the encoding of each tree written in the batch to its
+	// object ID.
+	written map[string][]byte
+
+	buf bytes.Buffer
+}
+
+// newTreeBatch returns a new, empty *treeBatch writing to the database.
+func (o *ObjectDatabase) newTreeBatch() *treeBatch {
+	return &treeBatch{db: o, written: make(map[string][]byte)}
+}
+
+// write writes the given tree to the database, unless an identical tree has
+// already been written in the batch, and returns its object ID.
+func (b *treeBatch) write(t *Tree) ([]byte, error) {
+	b.buf.Reset()
+	if _, err := t.Encode(&b.buf); err != nil {
+		return nil, err
+	}
+	if oid, ok := b.written[b.buf.String()]; ok {
+		return append([]byte(nil), oid...), nil
+	}
+
+	oid, err := b.db.WriteTree(t)
+	if err != nil {
+		return nil, err
+	}
+	b.written[b.buf.String()] = oid
+	return append([]byte(nil), oid...), nil
+}
@@ -0,0 +1,57 @@
+package gitobj
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// treesWritten returns the number of trees written to the database.
+func treesWritten(db *ObjectDatabase) uint64 {
+	return db.WriteStats()[TreeObjectType-BlobObjectType].Objects
+}
+
+func TestWriteTreesWritesIdenticalTreesOnce(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob := writeTestBlob(t, db)
+	a := &Tree{Entries: []*TreeEntry{{Name: "a.txt", Oid: blob, Filemode: 0100644}}}
+	b := &Tree{Entries: []*TreeEntry{{Name: "b.txt", Oid: blob, Filemode: 0100644}}}
+
+	oids, err := db.WriteTrees([]*Tree{a, b, a.Merge(), b})
+	require.NoError(t, err)
+	require.Len(t, oids, 4)
+
+	expected, err := db.WriteTree(a)
+	require.NoError(t, err)
+	assert.Equal(t, expected, oids[0])
+	assert.Equal(t, oids[0], oids[2])
+	assert.Equal(t, oids[1], oids[3])
+	assert.NotEqual(t, oids[0], oids[1])
+
+	// Two trees were written by WriteTrees, and one by WriteTree.
+	assert.EqualValues(t, 3, treesWritten(db))
+}
+
+func TestTreeBuilderWritesIdenticalSubtreesOnce(t *testing.T) {
+	db := newTestMemoryDatabase(t)
+
+	blob := writeTestBlob(t, db)
+
+	b := db.NewTreeBuilder()
+	require.NoError(t, b.AddPath("x/same/a.txt", blob, 0100644))
+	require.NoError(t, b.AddPath("y/same/a.txt", blob, 0100644))
+
+	root, err := b.Write()
+	require.NoError(t, err)
+
+	// "x" and "y" are identical, as are their subtrees "same", and so
+	// only those, and the root, are written.
+	assert.EqualValues(t, 3, treesWritten(db))
+
+	tree, err := db.Tree(root)
+	require.NoError(t, err)
+	require.Len(t, tree.Entries, 2)
+	assert.Equal(t, tree.Entries[0].Oid, tree.Entries[1].Oid)
+}